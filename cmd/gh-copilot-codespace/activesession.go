@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// activeSessionPrefix starts the files that record which copilot sessions are
+// using a codespace. Each launcher writes one per codespace, named after its
+// PID, which becomes copilot's PID after syscall.Exec.
+const activeSessionPrefix = ".session-"
+
+// activeSession is another launcher's record of a codespace it is using.
+type activeSession struct {
+	PID       int       `json:"pid"`
+	Codespace string    `json:"codespace"`
+	ExecAgent string    `json:"execAgent,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// activeSessionDir is where session records live, next to the SSH control
+// sockets they share.
+func activeSessionDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return ssh.WorkdirsDir(homeDir)
+}
+
+func activeSessionPath(dir, codespaceName string, pid int) string {
+	return filepath.Join(dir, activeSessionPrefix+codespaceName+"-"+strconv.Itoa(pid)+".json")
+}
+
+// findActiveSessions returns the live sessions, other than this process, that
+// are using codespaceName. Records of exited processes are removed.
+func findActiveSessions(dir, codespaceName string) []activeSession {
+	if dir == "" {
+		return nil
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, activeSessionPrefix+codespaceName+"-*.json"))
+	var sessions []activeSession
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s activeSession
+		if err := json.Unmarshal(data, &s); err != nil || s.Codespace != codespaceName {
+			// The glob also matches codespaces whose names extend this one.
+			if err != nil {
+				os.Remove(path)
+			}
+			continue
+		}
+		if s.PID == os.Getpid() {
+			continue
+		}
+		if !isLocalPIDRunning(s.PID) {
+			os.Remove(path)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// recordActiveSession marks codespaceName as used by this process. The record
+// outlives the launcher and is cleaned up by a later findActiveSessions once
+// copilot exits.
+func recordActiveSession(dir, codespaceName, execAgent string) {
+	if dir == "" {
+		return
+	}
+	data, err := json.Marshal(activeSession{PID: os.Getpid(), Codespace: codespaceName, ExecAgent: execAgent, StartedAt: time.Now().UTC()})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(activeSessionPath(dir, codespaceName, os.Getpid()), data, 0o600)
+}
+
+// warnSharedCodespace tells the user that another session is already using
+// the codespace. Its SSH master is reused by SetupMultiplexing, and both
+// sessions' background shells live in the same tmux namespace.
+func warnSharedCodespace(codespaceName string, others []activeSession) {
+	pids := make([]string, len(others))
+	for i, s := range others {
+		pids[i] = strconv.Itoa(s.PID)
+	}
+	progress.Warn("codespace_shared", progressFields{"codespace": codespaceName, "pids": strings.Join(pids, ",")},
+		"  ⚠ %s is already in use by another copilot session (pid %s); reusing its SSH connection and exec agent.\n"+
+			"    Background shells are shared: remote_list_bash shows both sessions' jobs, so use distinct shellIds.\n",
+		codespaceName, strings.Join(pids, ", "))
+}
+
+// deployOrReuseBinary returns the exec agent deployed by another live session
+// on the codespace, or deploys one. Redeploying under a running session could
+// replace the binary while it is executing.
+func deployOrReuseBinary(sshClient *ssh.Client, codespaceName string, others []activeSession) (string, error) {
+	for _, s := range others {
+		if s.ExecAgent != "" {
+			progress.Step("deploy_reused", progressFields{"codespace": codespaceName, "pid": s.PID},
+				"  ✓ Reusing exec agent deployed by session %d\n", s.PID)
+			return s.ExecAgent, nil
+		}
+	}
+	return deployBinary(sshClient, codespaceName)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeActiveSession(t *testing.T, dir string, s activeSession) string {
+	t.Helper()
+	data, _ := json.Marshal(s)
+	path := activeSessionPath(dir, s.Codespace, s.PID)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindActiveSessions(t *testing.T) {
+	dir := t.TempDir()
+
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	deadPath := writeActiveSession(t, dir, activeSession{PID: exited.Process.Pid, Codespace: "cs-1"})
+	writeActiveSession(t, dir, activeSession{PID: os.Getppid(), Codespace: "cs-1", ExecAgent: "/tmp/agent"})
+	writeActiveSession(t, dir, activeSession{PID: os.Getppid(), Codespace: "cs-1-other"})
+	recordActiveSession(dir, "cs-1", "/tmp/mine")
+
+	got := findActiveSessions(dir, "cs-1")
+	if len(got) != 1 || got[0].PID != os.Getppid() || got[0].ExecAgent != "/tmp/agent" {
+		t.Errorf("findActiveSessions() = %+v, want only the parent process", got)
+	}
+	if _, err := os.Stat(deadPath); !os.IsNotExist(err) {
+		t.Errorf("record of exited process was not removed: %v", err)
+	}
+	if _, err := os.Stat(activeSessionPath(dir, "cs-1-other", os.Getppid())); err != nil {
+		t.Errorf("record of another codespace was touched: %v", err)
+	}
+	if _, err := os.Stat(activeSessionPath(dir, "cs-1", os.Getpid())); err != nil {
+		t.Errorf("own record missing: %v", err)
+	}
+	if got := findActiveSessions(filepath.Join(dir, "missing"), "cs-1"); got != nil {
+		t.Errorf("missing dir = %+v", got)
+	}
+}
+
+func TestDeployOrReuseBinaryReusesLiveSessionAgent(t *testing.T) {
+	quietProgress(t)
+	got, err := deployOrReuseBinary(nil, "cs-1", []activeSession{{PID: 1}, {PID: 2, ExecAgent: "/tmp/gh-copilot-codespace-bin/gh-copilot-codespace"}})
+	if err != nil || got != "/tmp/gh-copilot-codespace-bin/gh-copilot-codespace" {
+		t.Errorf("deployOrReuseBinary() = %q, %v", got, err)
+	}
+}
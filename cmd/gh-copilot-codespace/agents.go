@@ -0,0 +1,166 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codespaceShellTools are the remote tools that stand in for a local shell.
+var codespaceShellTools = []string{
+	"remote_bash", "remote_write_bash", "remote_read_bash",
+	"remote_stop_bash", "remote_list_bash", "remote_cd", "remote_cwd",
+}
+
+// agentToolEquivalents maps local tool names and Copilot's tool aliases, as
+// they appear in a custom agent's tools: list, to the codespace MCP tools that
+// replace them. Keys are lowercase.
+var agentToolEquivalents = map[string][]string{
+	"bash":       codespaceShellTools,
+	"shell":      codespaceShellTools,
+	"execute":    codespaceShellTools,
+	"powershell": codespaceShellTools,
+	"write_bash": {"remote_write_bash"},
+	"read_bash":  {"remote_read_bash"},
+	"stop_bash":  {"remote_stop_bash"},
+	"list_bash":  {"remote_list_bash"},
+	"view":       {"remote_view", "remote_view_many", "remote_stat"},
+	"read":       {"remote_view", "remote_view_many", "remote_stat"},
+	"edit":       {"remote_edit", "remote_create", "remote_scaffold"},
+	"create":     {"remote_create", "remote_scaffold"},
+	"write":      {"remote_create", "remote_scaffold"},
+	"grep":       {"remote_grep"},
+	"glob":       {"remote_glob"},
+	"search":     {"remote_grep", "remote_glob"},
+}
+
+var (
+	agentToolsKeyRe  = regexp.MustCompile(`^tools:\s*(.*?)\s*$`)
+	agentToolsItemRe = regexp.MustCompile(`^(\s*)-\s*(.*?)\s*$`)
+)
+
+// isAgentFile reports whether a mirrored path is a custom agent definition.
+func isAgentFile(relPath string) bool {
+	return strings.HasSuffix(relPath, ".agent.md") &&
+		(strings.HasPrefix(relPath, ".github/agents/") || strings.HasPrefix(relPath, ".claude/agents/"))
+}
+
+// rewriteAgentTools adds the codespace equivalents of local tools named in an
+// agent's tools: frontmatter, so restricting an agent to e.g. "bash" and "view"
+// still lets it run commands and read files on the codespace. Original entries
+// are kept. Returns nil when the file has no tools list to change.
+func rewriteAgentTools(content []byte) []byte {
+	text := string(content)
+	eol := "\n"
+	if strings.Contains(text, "\r\n") {
+		eol = "\r\n"
+	}
+	lines := strings.Split(text, eol)
+	if len(lines) < 3 || strings.TrimSpace(lines[0]) != "---" {
+		return nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	for i := 1; i < end; i++ {
+		m := agentToolsKeyRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		value := m[1]
+
+		var replacement []string
+		switch {
+		case value == "":
+			// Block list: "tools:" followed by "  - name" lines.
+			j := i + 1
+			var items []string
+			indent := "  "
+			for ; j < end; j++ {
+				im := agentToolsItemRe.FindStringSubmatch(lines[j])
+				if im == nil {
+					break
+				}
+				if len(items) == 0 {
+					indent = im[1]
+				}
+				items = append(items, unquoteYAML(im[2]))
+			}
+			added := agentToolAdditions(items)
+			if len(added) == 0 {
+				return nil
+			}
+			for _, name := range added {
+				replacement = append(replacement, indent+"- "+name)
+			}
+			lines = append(lines[:j], append(replacement, lines[j:]...)...)
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := splitToolList(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+			added := agentToolAdditions(items)
+			if len(added) == 0 {
+				return nil
+			}
+			quoted := make([]string, 0, len(items)+len(added))
+			for _, name := range append(items, added...) {
+				quoted = append(quoted, "'"+name+"'")
+			}
+			lines[i] = "tools: [" + strings.Join(quoted, ", ") + "]"
+		default:
+			items := splitToolList(value)
+			added := agentToolAdditions(items)
+			if len(added) == 0 {
+				return nil
+			}
+			lines[i] = "tools: " + strings.Join(append(items, added...), ", ")
+		}
+		return []byte(strings.Join(lines, eol))
+	}
+	return nil
+}
+
+// agentToolAdditions returns the codespace tool references to append for items,
+// skipping any the list already grants.
+func agentToolAdditions(items []string) []string {
+	have := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item == "*" || item == "codespace/*" {
+			return nil
+		}
+		have[item] = true
+	}
+	var added []string
+	for _, item := range items {
+		for _, tool := range agentToolEquivalents[strings.ToLower(item)] {
+			ref := "codespace/" + tool
+			if !have[ref] {
+				have[ref] = true
+				added = append(added, ref)
+			}
+		}
+	}
+	return added
+}
+
+func splitToolList(s string) []string {
+	var items []string
+	for _, part := range strings.Split(s, ",") {
+		if item := unquoteYAML(strings.TrimSpace(part)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
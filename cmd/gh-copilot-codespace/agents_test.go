@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestRewriteAgentTools(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "block list",
+			in:   "---\nname: reviewer\ntools:\n  - view\n  - grep\ndescription: x\n---\n\nBody\n",
+			want: "---\nname: reviewer\ntools:\n  - view\n  - grep\n  - codespace/remote_view\n  - codespace/remote_view_many\n  - codespace/remote_stat\n  - codespace/remote_grep\ndescription: x\n---\n\nBody\n",
+		},
+		{
+			name: "inline list",
+			in:   "---\nname: runner\ntools: [\"bash\", 'codespace/remote_bash']\n---\n",
+			want: "---\nname: runner\ntools: ['bash', 'codespace/remote_bash', 'codespace/remote_write_bash', 'codespace/remote_read_bash', 'codespace/remote_stop_bash', 'codespace/remote_list_bash', 'codespace/remote_cd', 'codespace/remote_cwd']\n---\n",
+		},
+		{
+			name: "comma string with aliases",
+			in:   "---\ntools: read, search\n---\n",
+			want: "---\ntools: read, search, codespace/remote_view, codespace/remote_view_many, codespace/remote_stat, codespace/remote_grep, codespace/remote_glob\n---\n",
+		},
+		{
+			name: "CRLF line endings",
+			in:   "---\r\ntools:\r\n- edit\r\n---\r\n",
+			want: "---\r\ntools:\r\n- edit\r\n- codespace/remote_edit\r\n- codespace/remote_create\r\n- codespace/remote_scaffold\r\n---\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rewriteAgentTools([]byte(tt.in))
+			if string(got) != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteAgentTools_Unchanged(t *testing.T) {
+	tests := map[string]string{
+		"no frontmatter":      "# Agent\n\ntools: bash\n",
+		"no tools key":        "---\nname: x\n---\n",
+		"all tools":           "---\ntools: ['*']\n---\n",
+		"codespace wildcard":  "---\ntools:\n  - codespace/*\n  - bash\n---\n",
+		"only unmapped tools": "---\ntools: [web, todo]\n---\n",
+		"tools in body only":  "---\nname: x\n---\ntools:\n  - bash\n",
+	}
+	for name, in := range tests {
+		if got := rewriteAgentTools([]byte(in)); got != nil {
+			t.Errorf("%s: expected no rewrite, got %q", name, got)
+		}
+	}
+}
+
+func TestIsAgentFile(t *testing.T) {
+	for path, want := range map[string]bool{
+		".github/agents/reviewer.agent.md": true,
+		".claude/agents/a.agent.md":        true,
+		".github/agents/README.md":         false,
+		"docs/x.agent.md":                  false,
+	} {
+		if got := isAgentFile(path); got != want {
+			t.Errorf("isAgentFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// requiredTokenScopes are the gh token scopes the launcher depends on. Without
+// codespace, listing codespaces and gh codespace ssh fail with errors that
+// don't name the cause.
+var requiredTokenScopes = []string{"codespace"}
+
+// ghAuthStatus describes the active account in gh auth status output.
+type ghAuthStatus struct {
+	Host      string
+	Account   string
+	LoggedIn  bool
+	Scopes    []string
+	HasScopes bool // false for tokens that don't report scopes, e.g. fine-grained
+}
+
+// checkGHAuthScopes warns when gh is not logged in or its token lacks a
+// required scope, naming the gh auth command that fixes it. It never fails the
+// launch: a check that can't tell (older gh, tokens without scopes) stays
+// quiet.
+func checkGHAuthScopes() {
+	out, _ := exec.Command("gh", "auth", "status").CombinedOutput()
+	status, ok := parseGHAuthStatus(string(out))
+	if !ok {
+		return
+	}
+	if !status.LoggedIn {
+		progress.Warn("gh_auth_missing", progressFields{"host": status.Host},
+			"Warning: gh is not logged in to %s. Run: gh auth login -h %s -s %s\n",
+			status.Host, status.Host, strings.Join(requiredTokenScopes, ","))
+		return
+	}
+	if !status.HasScopes {
+		return
+	}
+	if missing := missingScopes(status.Scopes, requiredTokenScopes); len(missing) > 0 {
+		progress.Warn("gh_scope_missing", progressFields{"host": status.Host, "account": status.Account, "missing": missing},
+			"Warning: the gh token for %s is missing the %s scope, so codespaces can't be listed or reached over SSH.\n  Fix it with: %s\n",
+			status.Account, strings.Join(missing, ", "), formatScopeFix(status.Host, missing))
+	}
+}
+
+// parseGHAuthStatus reads the active github.com account (or the first host's)
+// from gh auth status output. ok is false when no host is listed.
+func parseGHAuthStatus(out string) (status ghAuthStatus, ok bool) {
+	var hosts []ghAuthStatus
+	var cur *ghAuthStatus
+	active := -1
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		// Host names start at column 0; account details are indented.
+		if line[0] != ' ' && line[0] != '\t' && !strings.ContainsAny(trimmed, " :") {
+			hosts = append(hosts, ghAuthStatus{Host: trimmed})
+			cur = &hosts[len(hosts)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		trimmed = strings.TrimLeft(trimmed, "✓✗X!-* ")
+		switch {
+		case strings.HasPrefix(trimmed, "Logged in to "), strings.HasPrefix(trimmed, "Failed to log in to "):
+			if cur.Account != "" {
+				// A further account on the same host.
+				hosts = append(hosts, ghAuthStatus{Host: cur.Host})
+				cur = &hosts[len(hosts)-1]
+			}
+			cur.LoggedIn = strings.HasPrefix(trimmed, "Logged in to ")
+			for _, sep := range []string{" account ", " as "} {
+				if _, after, found := strings.Cut(trimmed, sep); found {
+					cur.Account = strings.Fields(after)[0]
+					break
+				}
+			}
+		case strings.HasPrefix(trimmed, "The token in "):
+			cur.LoggedIn = false
+		case strings.HasPrefix(trimmed, "Active account: true"):
+			if active < 0 {
+				active = len(hosts) - 1
+			}
+		case strings.HasPrefix(trimmed, "Token scopes:"):
+			cur.HasScopes = true
+			for _, s := range strings.Split(strings.TrimPrefix(trimmed, "Token scopes:"), ",") {
+				s = strings.Trim(strings.TrimSpace(s), "'\"")
+				if s != "" && s != "none" {
+					cur.Scopes = append(cur.Scopes, s)
+				}
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		if strings.Contains(out, "not logged in") {
+			return ghAuthStatus{Host: "github.com"}, true
+		}
+		return ghAuthStatus{}, false
+	}
+	if active >= 0 {
+		return hosts[active], true
+	}
+	for _, h := range hosts {
+		if h.Host == "github.com" {
+			return h, true
+		}
+	}
+	return hosts[0], true
+}
+
+// missingScopes returns the required scopes not in have, in order.
+func missingScopes(have, required []string) []string {
+	granted := make(map[string]bool, len(have))
+	for _, s := range have {
+		granted[s] = true
+	}
+	var missing []string
+	for _, s := range required {
+		if !granted[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// formatScopeFix returns the command that adds missing scopes to the token.
+func formatScopeFix(host string, missing []string) string {
+	return fmt.Sprintf("gh auth refresh -h %s -s %s", host, strings.Join(missing, ","))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGHAuthStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		want   ghAuthStatus
+		wantOK bool
+	}{
+		{
+			name: "current gh",
+			out: `github.com
+  ✓ Logged in to github.com account octocat (keyring)
+  - Active account: true
+  - Git operations protocol: https
+  - Token: gho_************************************
+  - Token scopes: 'gist', 'read:org', 'repo', 'workflow'
+`,
+			want:   ghAuthStatus{Host: "github.com", Account: "octocat", LoggedIn: true, Scopes: []string{"gist", "read:org", "repo", "workflow"}, HasScopes: true},
+			wantOK: true,
+		},
+		{
+			name: "older gh",
+			out: `github.com
+  ✓ Logged in to github.com as octocat (oauth_token)
+  ✓ Git operations for github.com configured to use https protocol.
+  ✓ Token: gho_************************************
+  ✓ Token scopes: codespace, gist, read:org, repo
+`,
+			want:   ghAuthStatus{Host: "github.com", Account: "octocat", LoggedIn: true, Scopes: []string{"codespace", "gist", "read:org", "repo"}, HasScopes: true},
+			wantOK: true,
+		},
+		{
+			name: "active account among several",
+			out: `github.com
+  ✓ Logged in to github.com account work (keyring)
+  - Active account: false
+  - Token scopes: 'repo'
+
+  ✓ Logged in to github.com account octocat (keyring)
+  - Active account: true
+  - Token scopes: 'codespace', 'repo'
+`,
+			want:   ghAuthStatus{Host: "github.com", Account: "octocat", LoggedIn: true, Scopes: []string{"codespace", "repo"}, HasScopes: true},
+			wantOK: true,
+		},
+		{
+			name: "invalid token",
+			out: `github.com
+  X Failed to log in to github.com account octocat (GH_TOKEN)
+  - Active account: true
+  - The token in GH_TOKEN is invalid.
+`,
+			want:   ghAuthStatus{Host: "github.com", Account: "octocat"},
+			wantOK: true,
+		},
+		{
+			name: "fine-grained token reports no scopes",
+			out: `github.com
+  ✓ Logged in to github.com account octocat (GH_TOKEN)
+  - Active account: true
+  - Token: github_pat_**********
+`,
+			want:   ghAuthStatus{Host: "github.com", Account: "octocat", LoggedIn: true},
+			wantOK: true,
+		},
+		{
+			name:   "not logged in",
+			out:    "You are not logged into any GitHub hosts. To log in, run: gh auth login\n",
+			want:   ghAuthStatus{Host: "github.com"},
+			wantOK: true,
+		},
+		{
+			name: "unrecognized output",
+			out:  "unknown command \"auth\" for \"gh\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseGHAuthStatus(tt.out)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGHAuthStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	if got := missingScopes([]string{"repo", "codespace"}, requiredTokenScopes); len(got) != 0 {
+		t.Errorf("missingScopes() = %v, want none", got)
+	}
+	got := missingScopes([]string{"repo"}, []string{"codespace", "repo", "read:org"})
+	if want := []string{"codespace", "read:org"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingScopes() = %v, want %v", got, want)
+	}
+	if fix := formatScopeFix("github.com", got); fix != "gh auth refresh -h github.com -s codespace,read:org" {
+		t.Errorf("formatScopeFix() = %q", fix)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// codespaceListPath lists the user's codespaces through the REST API, a full
+// page at a time. gh codespace list only prints once it has every page, so
+// the launcher pages itself to show progress and stop early.
+const codespaceListPath = "/user/codespaces?per_page=100"
+
+// pickerFilterThreshold is how many codespaces the picker shows before it
+// asks for a filter first.
+const pickerFilterThreshold = 30
+
+// codespaceListPage is one page of GET /user/codespaces.
+type codespaceListPage struct {
+	TotalCount int                `json:"total_count"`
+	Codespaces []apiCodespaceInfo `json:"codespaces"`
+}
+
+type apiCodespaceInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Repository  struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	State      string    `json:"state"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Machine    *struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+	} `json:"machine"`
+}
+
+// codespace converts an API entry to the fields gh codespace list reports.
+func (c apiCodespaceInfo) codespace() codespace {
+	cs := codespace{
+		Name:        c.Name,
+		DisplayName: c.DisplayName,
+		Repository:  c.Repository.FullName,
+		State:       c.State,
+		LastUsedAt:  c.LastUsedAt,
+	}
+	if c.Machine != nil {
+		cs.MachineName = c.Machine.Name
+		cs.MachineDisplayName = c.Machine.DisplayName
+	}
+	return cs
+}
+
+// readCodespacePages decodes the pages gh api --paginate writes one after
+// another, calling onPage with each page's codespaces and the total the API
+// reports. It stops early, without error, when onPage returns false.
+func readCodespacePages(r io.Reader, onPage func(page []codespace, total int) bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var page codespaceListPage
+		if err := dec.Decode(&page); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("parsing codespace list: %w", err)
+		}
+		codespaces := make([]codespace, len(page.Codespaces))
+		for i, c := range page.Codespaces {
+			codespaces[i] = c.codespace()
+		}
+		if !onPage(codespaces, page.TotalCount) {
+			return nil
+		}
+	}
+}
+
+// listCodespaces fetches every codespace, page by page. onPage, if set, sees
+// each page as it arrives and can return false to stop fetching; the
+// codespaces read so far are returned either way.
+func listCodespaces(onPage func(page []codespace, total int) bool) ([]codespace, error) {
+	cmd := exec.Command("gh", "api", "--paginate", codespaceListPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("listing codespaces: %w", err)
+	}
+
+	var all []codespace
+	stopped := false
+	readErr := readCodespacePages(stdout, func(page []codespace, total int) bool {
+		all = append(all, page...)
+		if onPage != nil && !onPage(page, total) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if stopped {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return all, nil
+	}
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("listing codespaces: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("listing codespaces: %w", err)
+	}
+	return all, readErr
+}
+
+// maxSuggestedCodespaces caps how many names a --codespace error lists.
+const maxSuggestedCodespaces = 10
+
+// matchCodespace finds the codespace --codespace names: an exact name, else
+// the only codespace whose name or display name starts with it. The error for
+// no match or an ambiguous one lists the candidates, so a script fails with
+// something to act on instead of launching against the wrong codespace.
+func matchCodespace(codespaces []codespace, name string) (codespace, error) {
+	var prefixed []codespace
+	for _, cs := range codespaces {
+		if cs.Name == name {
+			return cs, nil
+		}
+		if strings.HasPrefix(cs.Name, name) || strings.HasPrefix(cs.DisplayName, name) {
+			prefixed = append(prefixed, cs)
+		}
+	}
+	switch len(prefixed) {
+	case 1:
+		return prefixed[0], nil
+	case 0:
+		if len(codespaces) == 0 {
+			return codespace{}, fmt.Errorf("codespace %q not found: you have no codespaces", name)
+		}
+		return codespace{}, fmt.Errorf("codespace %q not found; available: %s (see gh codespace list)", name, codespaceNameList(codespaces))
+	default:
+		return codespace{}, fmt.Errorf("codespace %q is ambiguous; it matches %s", name, codespaceNameList(prefixed))
+	}
+}
+
+// codespaceNameList joins the codespaces' names, with display names where
+// they differ, up to maxSuggestedCodespaces.
+func codespaceNameList(codespaces []codespace) string {
+	var names []string
+	for i, cs := range codespaces {
+		if i == maxSuggestedCodespaces {
+			names = append(names, fmt.Sprintf("and %d more", len(codespaces)-i))
+			break
+		}
+		if cs.DisplayName != "" && cs.DisplayName != cs.Name {
+			names = append(names, fmt.Sprintf("%s (%s)", cs.Name, cs.DisplayName))
+		} else {
+			names = append(names, cs.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// filterChoices returns the indexes of the picker lines that contain every
+// word of query, ignoring case. A blank query keeps every line.
+func filterChoices(lines []string, query string) []int {
+	words := strings.Fields(strings.ToLower(query))
+	var kept []int
+	for i, l := range lines {
+		l = strings.ToLower(l)
+		match := true
+		for _, w := range words {
+			if !strings.Contains(l, w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
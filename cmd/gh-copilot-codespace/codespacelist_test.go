@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const twoCodespacePages = `{"total_count": 3, "codespaces": [
+  {"name": "cs-api", "display_name": "api", "repository": {"full_name": "octo/api"}, "state": "Available",
+   "last_used_at": "2026-03-01T10:00:00Z", "machine": {"name": "largePremiumLinux", "display_name": "8 cores"}},
+  {"name": "cs-web", "display_name": "web", "repository": {"full_name": "octo/web"}, "state": "Shutdown", "machine": null}
+]}
+{"total_count": 3, "codespaces": [
+  {"name": "cs-docs", "display_name": "docs", "repository": {"full_name": "octo/docs"}, "state": "Available"}
+]}
+`
+
+func TestReadCodespacePages(t *testing.T) {
+	var got []codespace
+	var totals []int
+	err := readCodespacePages(strings.NewReader(twoCodespacePages), func(page []codespace, total int) bool {
+		got = append(got, page...)
+		totals = append(totals, total)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readCodespacePages() error = %v", err)
+	}
+	if !reflect.DeepEqual(totals, []int{3, 3}) {
+		t.Errorf("totals = %v, want a callback per page", totals)
+	}
+	want := codespace{
+		Name:               "cs-api",
+		DisplayName:        "api",
+		Repository:         "octo/api",
+		State:              "Available",
+		LastUsedAt:         time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		MachineName:        "largePremiumLinux",
+		MachineDisplayName: "8 cores",
+	}
+	if len(got) != 3 || !reflect.DeepEqual(got[0], want) || got[1].MachineName != "" || got[2].Name != "cs-docs" {
+		t.Errorf("codespaces = %+v", got)
+	}
+}
+
+func TestReadCodespacePagesStopsEarly(t *testing.T) {
+	pages := 0
+	err := readCodespacePages(strings.NewReader(twoCodespacePages+"not json"), func([]codespace, int) bool {
+		pages++
+		return false
+	})
+	if err != nil || pages != 1 {
+		t.Errorf("readCodespacePages() = %v after %d pages, want nil after 1", err, pages)
+	}
+}
+
+func TestReadCodespacePagesInvalidJSON(t *testing.T) {
+	err := readCodespacePages(strings.NewReader(`{"codespaces": [`), func([]codespace, int) bool { return true })
+	if err == nil || !strings.Contains(err.Error(), "parsing codespace list") {
+		t.Errorf("readCodespacePages() error = %v", err)
+	}
+}
+
+func TestFilterChoices(t *testing.T) {
+	lines := []string{
+		"cs-api\t🟢 octo/api: api [Available]",
+		"cs-web\t⏸️ octo/web: web [Shutdown]",
+		"cs-api-2\t⏸️ octo/api: api v2 [Shutdown]",
+	}
+	for _, tt := range []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2}},
+		{"  ", []int{0, 1, 2}},
+		{"API", []int{0, 2}},
+		{"api shutdown", []int{2}},
+		{"mobile", nil},
+	} {
+		if got := filterChoices(lines, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterChoices(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCodespace(t *testing.T) {
+	codespaces := []codespace{
+		{Name: "cs-api", DisplayName: "api"},
+		{Name: "cs-api-2", DisplayName: "api v2"},
+		{Name: "cs-web", DisplayName: "web"},
+	}
+	for _, tt := range []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{name: "cs-api", want: "cs-api"},
+		{name: "cs-w", want: "cs-web"},
+		{name: "web", want: "cs-web"},
+		{name: "api v", want: "cs-api-2"},
+		{name: "cs-a", wantErr: `codespace "cs-a" is ambiguous; it matches cs-api (api), cs-api-2 (api v2)`},
+		{name: "mobile", wantErr: `codespace "mobile" not found; available: cs-api (api), cs-api-2 (api v2), cs-web (web) (see gh codespace list)`},
+	} {
+		got, err := matchCodespace(codespaces, tt.name)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("matchCodespace(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.Name != tt.want {
+			t.Errorf("matchCodespace(%q) = %q, %v; want %q", tt.name, got.Name, err, tt.want)
+		}
+	}
+
+	if _, err := matchCodespace(nil, "cs-api"); err == nil || !strings.Contains(err.Error(), "no codespaces") {
+		t.Errorf("matchCodespace(nil) error = %v", err)
+	}
+	many := make([]codespace, maxSuggestedCodespaces+3)
+	for i := range many {
+		many[i] = codespace{Name: fmt.Sprintf("cs-%02d", i)}
+	}
+	if _, err := matchCodespace(many, "x"); err == nil || !strings.Contains(err.Error(), "cs-09, and 3 more (see") {
+		t.Errorf("matchCodespace(many) error = %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// copilotConfigLockTimeout bounds how long an update waits for another
+// launcher to finish with Copilot's config.json.
+const copilotConfigLockTimeout = 10 * time.Second
+
+func copilotConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".copilot", "config.json"), nil
+}
+
+// ensureTrustedFolder adds the directory to copilot's trusted_folders config if not already present.
+func ensureTrustedFolder(dir string) error {
+	configPath, err := copilotConfigPath()
+	if err != nil {
+		return err
+	}
+	return updateCopilotConfig(configPath, func(config map[string]any) bool {
+		trusted, _ := config["trusted_folders"].([]any)
+		for _, f := range trusted {
+			if s, ok := f.(string); ok && s == dir {
+				return false // already trusted
+			}
+		}
+		config["trusted_folders"] = append(trusted, dir)
+		return true
+	})
+}
+
+// updateCopilotConfig runs a read-modify-write of Copilot's config.json.
+// Launchers serialize on an flock of config.json.lock, and the new file
+// replaces the old one by rename, so Copilot never reads a half-written
+// config. update reports whether it changed anything; the file is left alone
+// otherwise. The file must already exist: Copilot creates it on first run.
+func updateCopilotConfig(configPath string, update func(config map[string]any) bool) error {
+	unlock, err := lockFile(configPath+".lock", copilotConfigLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return err
+	}
+	config, err := readCopilotConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if !update(config) {
+		return nil
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if err := writeFileAtomic(configPath, out, info.Mode().Perm()); err != nil {
+		return err
+	}
+	// The backup is what recovery falls back to if a later writer truncates
+	// the file, so it only ever holds a config that parsed.
+	if err := writeFileAtomic(configPath+".bak", out, info.Mode().Perm()); err != nil {
+		progress.Warn("copilot_config_backup_failed", progressFields{"path": configPath, "error": err.Error()}, "Warning: could not back up %s: %v\n", configPath, err)
+	}
+	return nil
+}
+
+// readCopilotConfig parses config.json, recovering from a truncated or
+// otherwise unreadable file: it is copied to config.json.corrupt and the
+// last good copy in config.json.bak is used instead, or an empty config when
+// there is none. An empty file is an empty config.
+func readCopilotConfig(configPath string) (map[string]any, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	config := map[string]any{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return config, nil
+	}
+	parseErr := json.Unmarshal(data, &config)
+	if parseErr == nil && config != nil {
+		return config, nil
+	}
+	if parseErr == nil {
+		parseErr = errors.New("not a JSON object")
+	}
+
+	corrupt := configPath + ".corrupt"
+	if err := os.WriteFile(corrupt, data, 0o600); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w (and saving a copy failed: %v)", configPath, parseErr, err)
+	}
+	config = map[string]any{}
+	source := "an empty config"
+	if backup, err := os.ReadFile(configPath + ".bak"); err == nil && json.Unmarshal(backup, &config) == nil && config != nil {
+		source = configPath + ".bak"
+	} else {
+		config = map[string]any{}
+	}
+	progress.Warn("copilot_config_recovered", progressFields{"path": configPath, "error": parseErr.Error(), "saved": corrupt, "source": source},
+		"Warning: %s is unreadable (%v); saved it as %s and continuing from %s\n", configPath, parseErr, corrupt, source)
+	return config, nil
+}
+
+// lockFile takes an exclusive flock on path, creating it if needed, and
+// returns the function that releases it. It gives up after timeout rather
+// than hang a launch behind a stuck process.
+func lockFile(path string, timeout time.Duration) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock: %w", err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for the lock on %s", timeout, path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place, so readers see either the old or the new content.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
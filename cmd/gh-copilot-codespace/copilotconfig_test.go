@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeCopilotConfig(t *testing.T, content string) string {
+	t.Helper()
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	configPath := filepath.Join(tmpHome, ".copilot", "config.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestEnsureTrustedFolder_ConcurrentCallsKeepEveryFolder(t *testing.T) {
+	configPath := writeCopilotConfig(t, `{"model": "gpt-5", "trusted_folders": ["/existing"]}`)
+
+	var want []string
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		dir := fmt.Sprintf("/mirror/%02d", i)
+		want = append(want, dir)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ensureTrustedFolder(dir)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ensureTrustedFolder() error = %v", err)
+		}
+	}
+
+	got, err := readTrustedFolders(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want = append([]string{"/existing"}, want...)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("trusted_folders = %v, want %v", got, want)
+	}
+	info, err := os.Stat(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, want 0600 kept", info.Mode().Perm())
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(configPath), ".config.json.*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestEnsureTrustedFolder_RecoversTruncatedConfig(t *testing.T) {
+	progress = newProgressReporter(progressQuiet, os.Stderr, os.Stderr)
+	configPath := writeCopilotConfig(t, `{"trusted_folders": []}`)
+	if err := ensureTrustedFolder("/first"); err != nil {
+		t.Fatal(err)
+	}
+
+	truncated := `{"trusted_folders": ["/fir`
+	if err := os.WriteFile(configPath, []byte(truncated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureTrustedFolder("/second"); err != nil {
+		t.Fatalf("ensureTrustedFolder() on truncated config: %v", err)
+	}
+	assertTrustedFolders(t, configPath, []string{"/first", "/second"})
+	if saved, err := os.ReadFile(configPath + ".corrupt"); err != nil || string(saved) != truncated {
+		t.Errorf("corrupt copy = %q, %v", saved, err)
+	}
+
+	// Without a backup the launcher starts over rather than failing.
+	os.Remove(configPath + ".bak")
+	if err := os.WriteFile(configPath, []byte(`{"trusted`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureTrustedFolder("/third"); err != nil {
+		t.Fatal(err)
+	}
+	assertTrustedFolders(t, configPath, []string{"/third"})
+}
+
+func TestLockFileTimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json.lock")
+	unlock, err := lockFile(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockFile(path, 100*time.Millisecond); err == nil {
+		t.Fatal("second lockFile() succeeded while the lock was held")
+	}
+	unlock()
+	unlock, err = lockFile(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("lockFile() after unlock: %v", err)
+	}
+	unlock()
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
 )
@@ -43,7 +48,7 @@ func deployBinary(sshClient *ssh.Client, codespaceName string) (string, error) {
 		return remotePath, nil
 	}
 
-	fmt.Println("Deploying exec agent to codespace...")
+	progress.Step("deploy_started", progressFields{"codespace": codespaceName}, "Deploying exec agent to codespace...\n")
 
 	// Get a linux binary for the codespace
 	linuxBinary, cleanup, err := getLinuxBinary(arch)
@@ -54,23 +59,14 @@ func deployBinary(sshClient *ssh.Client, codespaceName string) (string, error) {
 		defer cleanup()
 	}
 
-	// Transfer via base64 over SSH (gh codespace cp uses SCP which is unreliable)
-	binData, err := os.ReadFile(linuxBinary)
+	// Links to codespaces drop long transfers now and then, so try each
+	// method a few times before falling back to the next one.
+	method, err := transferBinary(context.Background(), sshClient, binaryTransfers(sshClient, codespaceName), linuxBinary, remotePath)
 	if err != nil {
-		return "", fmt.Errorf("reading binary: %w", err)
+		return "", fmt.Errorf("copying binary to codespace: %w", err)
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(binData)
-	installCmd := fmt.Sprintf("mkdir -p %s && base64 -d > %s && chmod +x %s",
-		remoteBinaryDir, remotePath, remotePath)
-
-	cmd := exec.Command("gh", "codespace", "ssh", "-c", codespaceName, "--", installCmd)
-	cmd.Stdin = strings.NewReader(encoded)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("copying binary to codespace: %w: %s", err, out)
-	}
-
-	fmt.Printf("  ✓ Deployed exec agent (%s)\n", arch)
+	progress.Step("deployed", progressFields{"codespace": codespaceName, "arch": arch, "method": method}, "  ✓ Deployed exec agent (%s, via %s)\n", arch, method)
 	return remotePath, nil
 }
 
@@ -143,7 +139,7 @@ func crossCompile(arch string) (string, func(), error) {
 		return "", nil, fmt.Errorf("cross-compile failed: %w", err)
 	}
 
-	fmt.Printf("  ✓ Cross-compiled for linux/%s\n", arch)
+	progress.Step("agent_built", progressFields{"arch": arch}, "  ✓ Cross-compiled for linux/%s\n", arch)
 	return outPath, cleanup, nil
 }
 
@@ -187,7 +183,9 @@ func findModuleRoot() (string, error) {
 	return "", fmt.Errorf("go.mod not found")
 }
 
-// downloadReleaseBinary downloads the linux binary from the latest GitHub release.
+// downloadReleaseBinary downloads the linux binary from the latest release.
+// By default it uses the upstream GitHub repository; releaseRepoEnv points at
+// a fork or GHES repository, and releaseURLEnv at an artifact server.
 func downloadReleaseBinary(arch string) (string, func(), error) {
 	tmpDir, err := os.MkdirTemp("", "gh-copilot-codespace-download-*")
 	if err != nil {
@@ -195,16 +193,17 @@ func downloadReleaseBinary(arch string) (string, func(), error) {
 	}
 	cleanup := func() { os.RemoveAll(tmpDir) }
 
-	pattern := fmt.Sprintf("gh-copilot-codespace-linux-%s", arch)
+	asset := fmt.Sprintf("gh-copilot-codespace-linux-%s", arch)
 	outPath := filepath.Join(tmpDir, "gh-copilot-codespace")
 
-	cmd := exec.Command("gh", "release", "download",
-		"--repo", "ekroon/gh-copilot-codespace",
-		"--pattern", pattern,
-		"--output", outPath)
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
+	source := releaseRepo()
+	if base := os.Getenv(releaseURLEnv); base != "" {
+		source = base
+		err = downloadArtifact(base, asset, outPath)
+	} else {
+		err = downloadGitHubRelease(source, asset, outPath)
+	}
+	if err != nil {
 		cleanup()
 		return "", nil, fmt.Errorf("download failed: %w", err)
 	}
@@ -214,6 +213,93 @@ func downloadReleaseBinary(arch string) (string, func(), error) {
 		return "", nil, err
 	}
 
-	fmt.Printf("  ✓ Downloaded linux/%s binary from release\n", arch)
+	progress.Step("agent_downloaded", progressFields{"arch": arch, "source": source}, "  ✓ Downloaded linux/%s binary from %s\n", arch, source)
 	return outPath, cleanup, nil
 }
+
+const (
+	defaultReleaseRepo = "ekroon/gh-copilot-codespace"
+	releaseRepoEnv     = "COPILOT_CODESPACE_RELEASE_REPO"
+	releaseURLEnv      = "COPILOT_CODESPACE_RELEASE_URL"
+	releaseTokenEnv    = "COPILOT_CODESPACE_RELEASE_TOKEN"
+
+	// releaseChecksumsFile lists "<sha256>  <asset>" lines next to the binaries
+	// on an artifact server.
+	releaseChecksumsFile = "checksums.txt"
+)
+
+// releaseRepo returns the [HOST/]OWNER/REPO that gh release download uses.
+func releaseRepo() string {
+	if repo := os.Getenv(releaseRepoEnv); repo != "" {
+		return repo
+	}
+	return defaultReleaseRepo
+}
+
+func downloadGitHubRelease(repo, asset, outPath string) error {
+	cmd := exec.Command("gh", "release", "download",
+		"--repo", repo,
+		"--pattern", asset,
+		"--output", outPath)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// downloadArtifact fetches baseURL/asset and verifies it against the SHA-256
+// listed in baseURL/checksums.txt. Binaries without a checksum are rejected.
+func downloadArtifact(baseURL, asset, outPath string) error {
+	base := strings.TrimSuffix(baseURL, "/")
+	client := &http.Client{Timeout: 5 * time.Minute}
+
+	sums, err := fetchArtifact(client, base+"/"+releaseChecksumsFile)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", releaseChecksumsFile, err)
+	}
+	want, ok := checksumFor(sums, asset)
+	if !ok {
+		return fmt.Errorf("%s has no entry for %s", releaseChecksumsFile, asset)
+	}
+	data, err := fetchArtifact(client, base+"/"+asset)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", asset, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset, got, want)
+	}
+	return os.WriteFile(outPath, data, 0o755)
+}
+
+func fetchArtifact(client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv(releaseTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// checksumFor finds asset in sha256sum-style output ("<hex>  <name>", with an
+// optional "*" binary marker before the name).
+func checksumFor(sums []byte, asset string) (string, bool) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(fields[1], "*") == asset {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReleaseRepo(t *testing.T) {
+	t.Setenv(releaseRepoEnv, "")
+	if got := releaseRepo(); got != defaultReleaseRepo {
+		t.Errorf("releaseRepo() = %q, want %q", got, defaultReleaseRepo)
+	}
+	t.Setenv(releaseRepoEnv, "ghe.example.com/tools/copilot-codespace")
+	if got := releaseRepo(); got != "ghe.example.com/tools/copilot-codespace" {
+		t.Errorf("releaseRepo() = %q, want override", got)
+	}
+}
+
+func TestChecksumFor(t *testing.T) {
+	sums := []byte("aaa  gh-copilot-codespace-linux-arm64\nBBB *gh-copilot-codespace-linux-amd64\n\nmalformed line here\n")
+	if got, ok := checksumFor(sums, "gh-copilot-codespace-linux-amd64"); !ok || got != "bbb" {
+		t.Errorf("checksumFor(amd64) = %q, %v; want bbb, true", got, ok)
+	}
+	if _, ok := checksumFor(sums, "gh-copilot-codespace-darwin-arm64"); ok {
+		t.Error("checksumFor should not find a missing asset")
+	}
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	const asset = "gh-copilot-codespace-linux-amd64"
+	binary := []byte("\x7fELF fake binary")
+	sum := sha256.Sum256(binary)
+
+	newServer := func(checksums string, wantToken string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if wantToken != "" && r.Header.Get("Authorization") != "Bearer "+wantToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/releases/" + releaseChecksumsFile:
+				w.Write([]byte(checksums))
+			case "/releases/" + asset:
+				w.Write(binary)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	}
+
+	t.Run("verified download with token", func(t *testing.T) {
+		srv := newServer(hex.EncodeToString(sum[:])+"  "+asset+"\n", "s3cret")
+		defer srv.Close()
+		t.Setenv(releaseTokenEnv, "s3cret")
+
+		out := filepath.Join(t.TempDir(), "bin")
+		if err := downloadArtifact(srv.URL+"/releases/", asset, out); err != nil {
+			t.Fatalf("downloadArtifact: %v", err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || string(got) != string(binary) {
+			t.Errorf("downloaded %q, %v; want the served binary", got, err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		srv := newServer(strings.Repeat("0", 64)+"  "+asset+"\n", "")
+		defer srv.Close()
+		t.Setenv(releaseTokenEnv, "")
+
+		out := filepath.Join(t.TempDir(), "bin")
+		err := downloadArtifact(srv.URL+"/releases", asset, out)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("err = %v, want checksum mismatch", err)
+		}
+		if _, statErr := os.Stat(out); !os.IsNotExist(statErr) {
+			t.Error("binary should not be written when the checksum does not match")
+		}
+	})
+
+	t.Run("asset missing from checksums", func(t *testing.T) {
+		srv := newServer("abc  other-asset\n", "")
+		defer srv.Close()
+		t.Setenv(releaseTokenEnv, "")
+
+		err := downloadArtifact(srv.URL+"/releases", asset, filepath.Join(t.TempDir(), "bin"))
+		if err == nil || !strings.Contains(err.Error(), "no entry for "+asset) {
+			t.Fatalf("err = %v, want missing entry", err)
+		}
+	})
+}
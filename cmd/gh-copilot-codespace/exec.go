@@ -1,27 +1,57 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/ekroon/gh-copilot-codespace/internal/agentd"
 	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
 )
 
 var (
-	applyCodespaceEnv = codespaceenv.ApplyProcessBootstrap
-	execProcess       = syscall.Exec
+	applyCodespaceEnv           = codespaceenv.ApplyProcessBootstrap
+	loadLoginEnv                = codespaceenv.LoginEnv
+	execProcess                 = syscall.Exec
+	execStdout        io.Writer = os.Stdout
+	pickFreePort                = freeTCPPort
 )
 
+// execPortLinePrefix starts the line exec --port prints before running the
+// command, so callers can read the chosen port without parsing server logs.
+const execPortLinePrefix = "gh-copilot-codespace-port: "
+
 // runExec runs a command with optional workdir and env setup.
 // Used on the codespace as a structured alternative to bash -c with shell escaping.
 //
-// Usage: gh-copilot-codespace exec [--workdir DIR] [--env K=V]... -- COMMAND [ARGS...]
+// Usage: gh-copilot-codespace exec [--workdir DIR] [--env K=V]... [--port] [--login-env] -- COMMAND [ARGS...]
+//
+//	or: gh-copilot-codespace exec [--workdir DIR] [--env K=V]... [--port] [--login-env] --steps ENCODED
+//
+// --steps runs the remote_bash steps encoded by mcp.EncodeSteps in one bash,
+// printing the per-step markers of mcp.StepsScript.
+//
+// --port picks an unused TCP port, exports it as $PORT, and prints it on a
+// line starting with execPortLinePrefix before running the command, for
+// servers that should listen wherever there is room.
+//
+// --login-env starts from the user's login environment (profiles and mise),
+// read from the snapshot codespaceenv.LoginEnv caches.
 func runExec(args []string) error {
 	var workdir string
 	var envVars []string
 	var cmdArgs []string
+	var encodedSteps string
+	var withPort bool
+	var withLoginEnv bool
 
 	// Parse flags before --
 	i := 0
@@ -33,6 +63,15 @@ func runExec(args []string) error {
 		case args[i] == "--env" && i+1 < len(args):
 			envVars = append(envVars, args[i+1])
 			i += 2
+		case args[i] == "--steps" && i+1 < len(args):
+			encodedSteps = args[i+1]
+			i += 2
+		case args[i] == "--port":
+			withPort = true
+			i++
+		case args[i] == "--login-env":
+			withLoginEnv = true
+			i++
 		case args[i] == "--":
 			cmdArgs = args[i+1:]
 			i = len(args) // break out of loop
@@ -41,10 +80,28 @@ func runExec(args []string) error {
 		}
 	}
 
+	if encodedSteps != "" {
+		if len(cmdArgs) > 0 {
+			return fmt.Errorf("--steps and -- COMMAND are mutually exclusive")
+		}
+		steps, err := mcp.DecodeSteps(encodedSteps)
+		if err != nil {
+			return err
+		}
+		cmdArgs = []string{"bash", "-c", mcp.StepsScript(steps)}
+	}
+
 	if len(cmdArgs) == 0 {
-		return fmt.Errorf("no command specified (use: exec [--workdir DIR] [--env K=V]... -- COMMAND [ARGS...])")
+		return fmt.Errorf("no command specified (use: exec [--workdir DIR] [--env K=V]... [--port] [--login-env] -- COMMAND [ARGS...])")
 	}
 
+	if withLoginEnv {
+		for _, kv := range loginEnv() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				os.Setenv(k, v)
+			}
+		}
+	}
 	applyCodespaceEnv()
 
 	// Change to workdir if specified
@@ -63,6 +120,15 @@ func runExec(args []string) error {
 		os.Setenv(parts[0], parts[1])
 	}
 
+	if withPort {
+		port, err := pickFreePort()
+		if err != nil {
+			return fmt.Errorf("finding a free port: %w", err)
+		}
+		os.Setenv("PORT", fmt.Sprint(port))
+		fmt.Fprintf(execStdout, "%s%d\n", execPortLinePrefix, port)
+	}
+
 	// Find the command in PATH
 	command := cmdArgs[0]
 	path, err := lookPath(command)
@@ -74,6 +140,18 @@ func runExec(args []string) error {
 	return execProcess(path, cmdArgs, os.Environ())
 }
 
+// freeTCPPort asks the kernel for an unused port on all interfaces. The port
+// is released before the command starts, so another process could take it in
+// between; ephemeral ports are handed out round-robin, which makes that rare.
+func freeTCPPort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
 // lookPath finds the full path to a command, handling absolute paths.
 func lookPath(cmd string) (string, error) {
 	if strings.Contains(cmd, "/") {
@@ -95,3 +173,82 @@ func lookPath(cmd string) (string, error) {
 	}
 	return "", fmt.Errorf("%s: not found in PATH", cmd)
 }
+
+// agentDaemonEnv turns off the agent daemon when set to 0, so every command
+// gets its own ssh process as before.
+const agentDaemonEnv = "COPILOT_CODESPACE_AGENT_DAEMON"
+
+func agentDaemonEnabled() bool {
+	return os.Getenv(agentDaemonEnv) != "0"
+}
+
+// loginEnvEnv makes the agent daemon, hooks, and MCP servers run in the
+// codespace user's login environment when set to 1.
+const loginEnvEnv = "COPILOT_CODESPACE_LOGIN_ENV"
+
+func loginEnvEnabled() bool {
+	return os.Getenv(loginEnvEnv) == "1"
+}
+
+// loginEnv returns the cached login environment, or none when it cannot be
+// captured, in which case commands keep the environment they had.
+func loginEnv() []string {
+	env, err := loadLoginEnv(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gh-copilot-codespace: login environment: %v\n", err)
+	}
+	return env
+}
+
+// runAgentDaemon serves commands on a Unix socket until killed. It exits
+// quietly when a daemon already serves the socket, so every session can
+// start one without checking first. With --login-env, each command starts
+// from the login environment, refreshed when a profile changes.
+//
+// Usage: gh-copilot-codespace agent --socket PATH [--login-env]
+func runAgentDaemon(args []string) error {
+	var socketPath string
+	var server agentd.Server
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--socket" && i+1 < len(args):
+			socketPath = args[i+1]
+			i++
+		case args[i] == "--login-env":
+			server.Environ = func() []string { return codespaceenv.Overlay(os.Environ(), loginEnv()) }
+		default:
+			return fmt.Errorf("usage: agent --socket PATH [--login-env]")
+		}
+	}
+	if socketPath == "" {
+		return fmt.Errorf("usage: agent --socket PATH [--login-env]")
+	}
+	applyCodespaceEnv()
+	err := server.ListenAndServe(socketPath)
+	if errors.Is(err, agentd.ErrAlreadyRunning) {
+		return nil
+	}
+	return err
+}
+
+// stopAgentDaemons cancels the MCP server's socket forwardings to the agent
+// daemons. The daemons keep running for other sessions.
+func stopAgentDaemons(reg *registry.Registry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, cs := range reg.All() {
+		if client, ok := cs.Executor.(interface{ StopAgentDaemon(context.Context) }); ok {
+			client.StopAgentDaemon(ctx)
+		}
+	}
+}
+
+// closeNativeSSH closes the MCP server's native SSH connections, which end
+// their ProxyCommands. The ssh masters keep running for other sessions.
+func closeNativeSSH(reg *registry.Registry) {
+	for _, cs := range reg.All() {
+		if client, ok := cs.Executor.(interface{ Close() error }); ok {
+			_ = client.Close()
+		}
+	}
+}
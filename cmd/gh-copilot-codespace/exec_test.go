@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/agentd"
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
 )
 
 func TestRewriteMCPServerForSSH_WithRemoteBinary(t *testing.T) {
@@ -189,6 +196,25 @@ func TestRewriteHooksForSSH_WithRemoteBinary(t *testing.T) {
 	}
 }
 
+func TestRewriteForSSH_LoginEnv(t *testing.T) {
+	const agent = "/tmp/gh-copilot-codespace-bin/gh-copilot-codespace"
+	server := map[string]any{"command": "node", "args": []any{"server.js"}}
+	hooksJSON := []byte(`{"version":1,"hooks":{"sessionStart":[{"type":"command","bash":"echo hi"}]}}`)
+
+	t.Setenv(loginEnvEnv, "")
+	if args := fmt.Sprint(rewriteMCPServerForSSH(server, "my-cs", "/workspaces/repo", agent)["args"]); strings.Contains(args, "--login-env") {
+		t.Errorf("MCP server args without %s = %s", loginEnvEnv, args)
+	}
+
+	t.Setenv(loginEnvEnv, "1")
+	if args := fmt.Sprint(rewriteMCPServerForSSH(server, "my-cs", "/workspaces/repo", agent)["args"]); !strings.Contains(args, "exec --workdir /workspaces/repo --login-env -- node server.js") {
+		t.Errorf("MCP server args = %s, want --login-env", args)
+	}
+	if hooks := string(rewriteHooksForSSH(hooksJSON, "my-cs", "/workspaces/repo", agent)); !strings.Contains(hooks, "exec --workdir '/workspaces/repo' --login-env") {
+		t.Errorf("hooks = %s, want --login-env", hooks)
+	}
+}
+
 func TestRewriteHooksForSSH_FallbackWithoutBinary(t *testing.T) {
 	hooksJSON := `{"version":1,"hooks":{"sessionStart":[{"type":"command","bash":"echo hi","cwd":"."}]}}`
 
@@ -271,6 +297,104 @@ func TestRunExecExplicitEnvOverridesBootstrap(t *testing.T) {
 	}
 }
 
+func TestRunExecLoginEnv(t *testing.T) {
+	originalApply, originalExec, originalLogin := applyCodespaceEnv, execProcess, loadLoginEnv
+	t.Cleanup(func() {
+		applyCodespaceEnv, execProcess, loadLoginEnv = originalApply, originalExec, originalLogin
+	})
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("GITHUB_TOKEN", "")
+
+	applyCodespaceEnv = func() {
+		_ = os.Setenv("GITHUB_TOKEN", "bootstrap-token")
+	}
+	loadLoginEnv = func(context.Context) ([]string, error) {
+		return []string{"PATH=/home/codespace/.local/share/mise/shims:/usr/bin", "GITHUB_TOKEN=cached-token"}, nil
+	}
+	var gotEnv map[string]string
+	execProcess = func(_ string, _ []string, env []string) error {
+		gotEnv = envSliceToMap(env)
+		return errors.New("stop exec")
+	}
+
+	if err := runExec([]string{"--login-env", "--", "sh"}); err == nil || err.Error() != "stop exec" {
+		t.Fatalf("runExec() error = %v, want stop exec", err)
+	}
+	if gotEnv["PATH"] != "/home/codespace/.local/share/mise/shims:/usr/bin" {
+		t.Errorf("PATH = %q, want the login PATH", gotEnv["PATH"])
+	}
+	if gotEnv["GITHUB_TOKEN"] != "bootstrap-token" {
+		t.Errorf("GITHUB_TOKEN = %q, want the bootstrap to win over the login environment", gotEnv["GITHUB_TOKEN"])
+	}
+}
+
+func TestRunExecSteps(t *testing.T) {
+	originalApply := applyCodespaceEnv
+	originalExec := execProcess
+	t.Cleanup(func() {
+		applyCodespaceEnv = originalApply
+		execProcess = originalExec
+	})
+	applyCodespaceEnv = func() {}
+
+	var gotArgs []string
+	execProcess = func(_ string, args []string, _ []string) error {
+		gotArgs = args
+		return errors.New("stop exec")
+	}
+
+	steps := []string{"cd web", "npm ci"}
+	if err := runExec([]string{"--steps", mcp.EncodeSteps(steps)}); err == nil || err.Error() != "stop exec" {
+		t.Fatalf("runExec() error = %v, want stop exec", err)
+	}
+	if len(gotArgs) != 3 || gotArgs[0] != "bash" || gotArgs[1] != "-c" || gotArgs[2] != mcp.StepsScript(steps) {
+		t.Fatalf("exec args = %q, want bash -c with the steps script", gotArgs)
+	}
+
+	if err := runExec([]string{"--steps", mcp.EncodeSteps(steps), "--", "sh"}); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("runExec() with both forms error = %v", err)
+	}
+}
+
+func TestRunExecPort(t *testing.T) {
+	originalApply, originalExec := applyCodespaceEnv, execProcess
+	originalStdout, originalPick := execStdout, pickFreePort
+	t.Cleanup(func() {
+		applyCodespaceEnv, execProcess = originalApply, originalExec
+		execStdout, pickFreePort = originalStdout, originalPick
+	})
+	applyCodespaceEnv = func() {}
+	pickFreePort = func() (int, error) { return 41234, nil }
+	var stdout strings.Builder
+	execStdout = &stdout
+
+	var gotEnv map[string]string
+	execProcess = func(_ string, _ []string, env []string) error {
+		gotEnv = envSliceToMap(env)
+		return errors.New("stop exec")
+	}
+
+	if err := runExec([]string{"--port", "--", "sh"}); err == nil || err.Error() != "stop exec" {
+		t.Fatalf("runExec() error = %v, want stop exec", err)
+	}
+	if gotEnv["PORT"] != "41234" {
+		t.Errorf("PORT = %q, want 41234", gotEnv["PORT"])
+	}
+	if stdout.String() != execPortLinePrefix+"41234\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+}
+
+func TestFreeTCPPort(t *testing.T) {
+	port, err := freeTCPPort()
+	if err != nil {
+		t.Fatalf("freeTCPPort() error = %v", err)
+	}
+	if port <= 0 || port > 65535 {
+		t.Fatalf("freeTCPPort() = %d", port)
+	}
+}
+
 func envSliceToMap(env []string) map[string]string {
 	result := make(map[string]string, len(env))
 	for _, kv := range env {
@@ -281,3 +405,44 @@ func envSliceToMap(env []string) map[string]string {
 	}
 	return result
 }
+
+func TestRunAgentDaemon(t *testing.T) {
+	originalApply := applyCodespaceEnv
+	t.Cleanup(func() { applyCodespaceEnv = originalApply })
+	applyCodespaceEnv = func() {}
+
+	for _, args := range [][]string{nil, {"--socket"}, {"--socket", ""}, {"--port", "1"}, {"--login-env"}} {
+		if err := runAgentDaemon(args); err == nil {
+			t.Errorf("runAgentDaemon(%q) error = nil", args)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go agentd.Serve(ln)
+
+	// A second session's start command finds the running daemon and exits.
+	if err := runAgentDaemon([]string{"--socket", socketPath, "--login-env"}); err != nil {
+		t.Fatalf("runAgentDaemon() with a running daemon = %v, want nil", err)
+	}
+}
+
+func TestAgentDaemonEnabled(t *testing.T) {
+	t.Setenv(agentDaemonEnv, "")
+	if !agentDaemonEnabled() {
+		t.Error("agent daemon off by default")
+	}
+	t.Setenv(agentDaemonEnv, "0")
+	if agentDaemonEnabled() {
+		t.Errorf("agent daemon on with %s=0", agentDaemonEnv)
+	}
+}
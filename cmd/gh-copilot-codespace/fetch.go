@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+type fetchOptions struct {
+	codespaceName   string
+	workdirOverride string
+	quiet           bool
+	jsonStatus      bool
+}
+
+func parseFetchArgs(args []string) (fetchOptions, error) {
+	var opts fetchOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
+			opts.codespaceName = args[i+1]
+			i++
+		case (args[i] == "--workdir" || args[i] == "-w") && i+1 < len(args):
+			opts.workdirOverride = args[i+1]
+			i++
+		case args[i] == "--quiet":
+			opts.quiet = true
+		case args[i] == "--json-status":
+			opts.jsonStatus = true
+		default:
+			return fetchOptions{}, fmt.Errorf("unknown fetch argument %q", args[i])
+		}
+	}
+	if opts.codespaceName == "" {
+		return fetchOptions{}, fmt.Errorf("fetch requires --codespace NAME")
+	}
+	return opts, nil
+}
+
+// runFetch mirrors a codespace's instruction files without launching Copilot
+// and prints the mirror directory on stdout, so editors and scripts can reuse
+// the mirror. Human progress goes to stderr to keep stdout parseable.
+func runFetch(args []string) error {
+	opts, err := parseFetchArgs(args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.jsonStatus:
+		progress = newProgressReporter(progressJSON, os.Stdout, os.Stderr)
+	case opts.quiet:
+		progress = newProgressReporter(progressQuiet, os.Stderr, os.Stderr)
+	default:
+		progress = newProgressReporter(progressHuman, os.Stderr, os.Stderr)
+	}
+
+	cs, err := lookupCodespace(opts.codespaceName)
+	if err != nil {
+		return err
+	}
+	progress.Step("codespace_selected", progressFields{"codespace": cs.Name, "repository": cs.Repository}, "Codespace: %s (%s)\n", cs.Name, registry.RepositoryLabel(cs.Repository))
+
+	workdir := opts.workdirOverride
+	if workdir == "" {
+		workdir, err = detectWorkdir(cs.Name, cs.Repository)
+		if err != nil {
+			return err
+		}
+	}
+	progress.Step("workdir_detected", progressFields{"codespace": cs.Name, "workdir": workdir}, "  Workspace: %s\n", workdir)
+
+	sshClient := ssh.NewClient(cs.Name)
+	if err := sshClient.SetupMultiplexing(context.Background()); err != nil {
+		progress.Warn("ssh_multiplexing_failed", progressFields{"codespace": cs.Name}, "Warning: SSH multiplexing failed for %s: %v\n", cs.Name, err)
+	}
+
+	mirrorDir, _, err := fetchInstructionFiles(sshClient, cs.Name, workdir, "", loadLauncherConfig().FetchPatterns)
+	if err != nil {
+		return err
+	}
+	recordMirrorManifest(mirrorDir)
+	if opts.jsonStatus {
+		progress.Step("mirror_ready", progressFields{"codespace": cs.Name, "workdir": workdir, "path": mirrorDir}, "%s", mirrorDir)
+		return nil
+	}
+	fmt.Println(mirrorDir)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFetchArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    fetchOptions
+		wantErr bool
+	}{
+		{
+			name: "codespace only",
+			args: []string{"--codespace", "cs-1"},
+			want: fetchOptions{codespaceName: "cs-1"},
+		},
+		{
+			name: "short flags with workdir and json",
+			args: []string{"-c", "cs-1", "-w", "/workspaces/app", "--json-status"},
+			want: fetchOptions{codespaceName: "cs-1", workdirOverride: "/workspaces/app", jsonStatus: true},
+		},
+		{
+			name:    "missing codespace",
+			args:    []string{"--workdir", "/workspaces/app"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-c", "cs-1", "--model", "x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFetchArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fetchProgress describes the batch transfer after a file arrives.
+type fetchProgress struct {
+	Done       int
+	Total      int
+	Bytes      int64
+	TotalBytes int64
+	Path       string
+}
+
+type batchFetchState int
+
+const (
+	batchFetchCount batchFetchState = iota
+	batchFetchManifestPath
+	batchFetchManifestSize
+	batchFetchPath
+	batchFetchSum
+	batchFetchBody
+)
+
+// batchFetchParser consumes the output of instructionFetchScript as it
+// streams in. The script first prints a discovery manifest (file count, then
+// path and size per file) and then transfers each file as path, SHA-256 of
+// its content and base64 content. All fields are NUL-terminated. A file whose
+// checksum does not match is dropped rather than mirrored, and failures
+// reports it along with files the stream was cut off before.
+type batchFetchParser struct {
+	files map[string][]byte
+	// failed holds files that arrived corrupted, in arrival order.
+	failed []mirrorWriteFailure
+
+	// onManifest is called once discovery is complete; onFile after each file.
+	onManifest func(total int, totalBytes int64)
+	onFile     func(fetchProgress)
+
+	buf        []byte
+	state      batchFetchState
+	remaining  int
+	path       string
+	sum        string
+	expected   []string
+	progress   fetchProgress
+	manifested bool
+}
+
+func newBatchFetchParser() *batchFetchParser {
+	return &batchFetchParser{files: make(map[string][]byte)}
+}
+
+// Write implements io.Writer, parsing every complete field in b.
+func (p *batchFetchParser) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, 0)
+		if i < 0 {
+			break
+		}
+		p.field(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+func (p *batchFetchParser) field(f string) {
+	switch p.state {
+	case batchFetchCount:
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 0 {
+			p.finishManifest()
+			return
+		}
+		p.progress.Total = n
+		p.remaining = n
+		p.state = batchFetchManifestPath
+		if n == 0 {
+			p.finishManifest()
+		}
+	case batchFetchManifestPath:
+		p.expected = append(p.expected, f)
+		p.state = batchFetchManifestSize
+	case batchFetchManifestSize:
+		if size, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64); err == nil {
+			p.progress.TotalBytes += size
+		}
+		p.remaining--
+		p.state = batchFetchManifestPath
+		if p.remaining == 0 {
+			p.finishManifest()
+		}
+	case batchFetchPath:
+		p.path = f
+		p.state = batchFetchSum
+	case batchFetchSum:
+		// Empty when the codespace has neither sha256sum nor shasum.
+		p.sum = strings.ToLower(strings.TrimSpace(f))
+		p.state = batchFetchBody
+	case batchFetchBody:
+		p.state = batchFetchPath
+		// Paths are taken verbatim: spaces and newlines are valid in file names.
+		if p.path == "" {
+			return
+		}
+		// base64 wraps its output; the decoder ignores the embedded newlines.
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(f))
+		if err != nil {
+			p.failed = append(p.failed, mirrorWriteFailure{path: p.path, err: fmt.Errorf("corrupted in transfer: %w", err)})
+			return
+		}
+		if p.sum != "" {
+			if sum := sha256.Sum256(decoded); hex.EncodeToString(sum[:]) != p.sum {
+				p.failed = append(p.failed, mirrorWriteFailure{path: p.path, err: errors.New("corrupted in transfer: SHA-256 mismatch")})
+				return
+			}
+		}
+		p.files[p.path] = decoded
+		p.progress.Done++
+		p.progress.Bytes += int64(len(decoded))
+		p.progress.Path = p.path
+		if p.onFile != nil {
+			p.onFile(p.progress)
+		}
+	}
+}
+
+func (p *batchFetchParser) finishManifest() {
+	p.state = batchFetchPath
+	if p.manifested {
+		return
+	}
+	p.manifested = true
+	if p.onManifest != nil {
+		p.onManifest(p.progress.Total, p.progress.TotalBytes)
+	}
+}
+
+// failures returns the files that arrived corrupted, then the files in the
+// manifest that never arrived because the stream ended early.
+func (p *batchFetchParser) failures() []mirrorWriteFailure {
+	failures := append([]mirrorWriteFailure(nil), p.failed...)
+	seen := make(map[string]bool, len(p.failed))
+	for _, f := range p.failed {
+		seen[f.path] = true
+	}
+	for _, path := range p.expected {
+		if _, ok := p.files[path]; ok || seen[path] {
+			continue
+		}
+		seen[path] = true
+		failures = append(failures, mirrorWriteFailure{path: path, err: errors.New("transfer ended before the file arrived")})
+	}
+	return failures
+}
+
+// formatByteSize renders n for progress output, e.g. "512 B" or "1.5 MB".
+func formatByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
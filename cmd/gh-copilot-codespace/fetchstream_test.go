@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestBatchFetchParser_StreamsAcrossChunks(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	output := "2\x00a.md\x005\x00b/c.md\x003\x00" +
+		"a.md\x00" + sha256Hex("hello") + "\x00" + enc("hello") + "\n\x00" +
+		"b/c.md\x00" + sha256Hex("abc") + "\x00" + enc("abc") + "\n\x00"
+
+	p := newBatchFetchParser()
+	var manifestCalls int
+	var gotTotal int
+	var gotTotalBytes int64
+	p.onManifest = func(total int, totalBytes int64) {
+		manifestCalls++
+		gotTotal, gotTotalBytes = total, totalBytes
+	}
+	var events []fetchProgress
+	p.onFile = func(fp fetchProgress) { events = append(events, fp) }
+
+	// Feed a few bytes at a time so fields straddle Write calls.
+	for i := 0; i < len(output); i += 3 {
+		end := min(i+3, len(output))
+		if n, err := p.Write([]byte(output[i:end])); err != nil || n != end-i {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+
+	if manifestCalls != 1 || gotTotal != 2 || gotTotalBytes != 8 {
+		t.Fatalf("manifest = %d calls, total %d, bytes %d; want 1 call, 2 files, 8 bytes", manifestCalls, gotTotal, gotTotalBytes)
+	}
+	want := []fetchProgress{
+		{Done: 1, Total: 2, Bytes: 5, TotalBytes: 8, Path: "a.md"},
+		{Done: 2, Total: 2, Bytes: 8, TotalBytes: 8, Path: "b/c.md"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("progress = %+v, want %+v", events, want)
+	}
+	if string(p.files["b/c.md"]) != "abc" {
+		t.Fatalf("files = %q", p.files)
+	}
+	if failures := p.failures(); len(failures) != 0 {
+		t.Fatalf("failures = %v, want none", failures)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBatchFetchParser_ReportsCorruptAndMissingFiles(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	output := "4\x00ok.md\x002\x00flipped.md\x005\x00nosum.md\x001\x00cut.md\x004\x00" +
+		"ok.md\x00" + sha256Hex("ok") + "\x00" + enc("ok") + "\x00" +
+		"flipped.md\x00" + sha256Hex("hello") + "\x00" + enc("hallo") + "\x00" +
+		"nosum.md\x00\x00" + enc("x") + "\x00" +
+		"cut.md\x00" + sha256Hex("data") + "\x00" + enc("data")[:4]
+
+	p := newBatchFetchParser()
+	p.Write([]byte(output))
+
+	want := map[string][]byte{"ok.md": []byte("ok"), "nosum.md": []byte("x")}
+	if !reflect.DeepEqual(p.files, want) {
+		t.Fatalf("files = %q, want %q", p.files, want)
+	}
+	var got []string
+	for _, f := range p.failures() {
+		got = append(got, f.path+": "+f.err.Error())
+	}
+	wantFailures := []string{
+		"flipped.md: corrupted in transfer: SHA-256 mismatch",
+		"cut.md: transfer ended before the file arrived",
+	}
+	if !reflect.DeepEqual(got, wantFailures) {
+		t.Fatalf("failures = %q, want %q", got, wantFailures)
+	}
+}
+
+func TestBatchFetchParser_EmptyManifest(t *testing.T) {
+	p := newBatchFetchParser()
+	called := false
+	p.onManifest = func(total int, _ int64) { called = total == 0 }
+	p.Write([]byte("0\x00"))
+	if !called || len(p.files) != 0 {
+		t.Fatalf("expected empty manifest callback, got called=%v files=%v", called, p.files)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:       "0 B",
+		512:     "512 B",
+		1536:    "1.5 KB",
+		5 << 20: "5.0 MB",
+	} {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("formatByteSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// mcpHandshakeEnv names the handshake file the MCP server starts from.
+const mcpHandshakeEnv = "CODESPACE_MCP_HANDSHAKE"
+
+// mcpHandshakeFileName is the session-relative path of the handshake file.
+const mcpHandshakeFileName = ".codespace/mcp-handshake.json"
+
+// mcpHandshakeVersion is bumped when a field changes meaning. Adding an
+// optional field does not need a bump: older servers ignore it.
+const mcpHandshakeVersion = 1
+
+// mcpHandshake is the launcher state the MCP server starts from: the
+// connected codespaces (with machine size, clock, exec agent, and remote
+// user) and the session policy. New launcher settings that change server
+// behavior belong in Lifecycle rather than in another environment variable.
+type mcpHandshake struct {
+	Version    int                    `json:"version"`
+	Codespaces []registryEntry        `json:"codespaces"`
+	Lifecycle  lifecycleConfigEnvData `json:"lifecycle"`
+}
+
+// writeMCPHandshake writes the handshake for reg and cfg into the session
+// directory and returns its path.
+func writeMCPHandshake(dir string, reg *registry.Registry, cfg mcp.LifecycleConfig) (string, error) {
+	data, err := json.MarshalIndent(mcpHandshake{
+		Version:    mcpHandshakeVersion,
+		Codespaces: registryEntries(reg),
+		Lifecycle:  newLifecycleConfigEnvData(cfg),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, mcpHandshakeFileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("creating handshake dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return "", fmt.Errorf("writing handshake: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("writing handshake: %w", err)
+	}
+	return path, nil
+}
+
+// loadMCPHandshake reads a handshake written by writeMCPHandshake. It refuses
+// one from a newer launcher, whose fields this server might misread.
+func loadMCPHandshake(path string) (mcpHandshake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return mcpHandshake{}, fmt.Errorf("reading handshake: %w", err)
+	}
+	var h mcpHandshake
+	if err := json.Unmarshal(data, &h); err != nil {
+		return mcpHandshake{}, fmt.Errorf("parsing handshake %s: %w", path, err)
+	}
+	if h.Version < 1 || h.Version > mcpHandshakeVersion {
+		return mcpHandshake{}, fmt.Errorf("handshake %s has version %d; this binary supports up to %d (is the launcher newer than the MCP server?)", path, h.Version, mcpHandshakeVersion)
+	}
+	return h, nil
+}
+
+// sessionMCPConfig builds the MCP config for a session, passing launcher state
+// through a handshake file in dir. If the file can't be written it falls back
+// to environment variables.
+func sessionMCPConfig(selfBinary, dir string, reg *registry.Registry, remoteMCPServers map[string]any, cfg mcp.LifecycleConfig) string {
+	path, err := writeMCPHandshake(dir, reg, cfg)
+	if err != nil {
+		progress.Warn("handshake_write_failed", nil, "Warning: could not write MCP handshake, passing settings via env: %v\n", err)
+		return buildMCPConfigWithRegistry(selfBinary, reg, remoteMCPServers, cfg)
+	}
+	return buildMCPConfigWithHandshake(selfBinary, path, reg, remoteMCPServers, cfg)
+}
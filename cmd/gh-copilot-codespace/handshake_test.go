@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+func TestMCPHandshakeRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "cs-api", Workdir: "/workspaces/api", CPUs: 8, ExecAgent: "/tmp/agent"})
+	cfg := mcp.LifecycleConfig{
+		ReadOnly:         true,
+		NoAutoStart:      true,
+		ToolLog:          os.Stderr,
+		DeniedTools:      []string{"remote_chmod"},
+		RecordingDir:     filepath.Join(dir, "logs"),
+		SealMirrorDir:    filepath.Join(dir, "mirror"),
+		ConfineToWorkdir: true,
+		RemoteTask:       true,
+		ConfirmDiscard:   true,
+		WriteQuota:       1 << 20,
+		AccessPolicy:     mcp.CodespaceAccessPolicy{SelectedOnly: true, AllowedCodespaceNames: []string{"cs-api"}},
+		Workspace:        mcp.WorkspaceSessionContext{Name: "demo", Dir: dir},
+	}
+
+	path, err := writeMCPHandshake(dir, reg, cfg)
+	if err != nil {
+		t.Fatalf("writeMCPHandshake() error = %v", err)
+	}
+	if path != filepath.Join(dir, mcpHandshakeFileName) {
+		t.Errorf("path = %q", path)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("stat = %v, %v; want mode 0600", info, err)
+	}
+
+	h, err := loadMCPHandshake(path)
+	if err != nil {
+		t.Fatalf("loadMCPHandshake() error = %v", err)
+	}
+	if h.Version != mcpHandshakeVersion || len(h.Codespaces) != 1 || h.Codespaces[0].CPUs != 8 || h.Codespaces[0].ExecAgent != "/tmp/agent" {
+		t.Errorf("handshake = %+v", h)
+	}
+	got := h.Lifecycle.lifecycleConfig()
+	if !got.ReadOnly || !got.NoAutoStart || !got.AccessPolicy.SelectedOnly || got.Workspace != cfg.Workspace ||
+		got.ToolLog == nil || !reflect.DeepEqual(got.DeniedTools, cfg.DeniedTools) || got.RecordingDir != cfg.RecordingDir || got.SealMirrorDir != cfg.SealMirrorDir || !got.ConfineToWorkdir || !got.RemoteTask || !got.ConfirmDiscard || got.WriteQuota != cfg.WriteQuota ||
+		!reflect.DeepEqual(got.AccessPolicy.AllowedCodespaceNames, []string{"cs-api"}) {
+		t.Errorf("lifecycle config = %+v, want %+v", got, cfg)
+	}
+}
+
+func TestLoadMCPHandshakeRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "handshake.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "codespaces": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMCPHandshake(path); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("loadMCPHandshake() error = %v, want a version error", err)
+	}
+}
+
+func TestSessionMCPConfigPassesOnlyHandshakePath(t *testing.T) {
+	quietProgress(t)
+	dir := t.TempDir()
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "cs-api"})
+
+	var parsed struct {
+		MCPServers map[string]struct {
+			Env   map[string]string `json:"env"`
+			Tools []string          `json:"tools"`
+		} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal([]byte(sessionMCPConfig("/usr/local/bin/self", dir, reg, nil, mcp.LifecycleConfig{ReadOnly: true})), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	server := parsed.MCPServers["codespace"]
+	want := map[string]string{mcpHandshakeEnv: filepath.Join(dir, mcpHandshakeFileName)}
+	if !reflect.DeepEqual(server.Env, want) {
+		t.Errorf("env = %v, want %v", server.Env, want)
+	}
+	for _, name := range server.Tools {
+		if name == "remote_edit" {
+			t.Error("read-only session should not advertise remote_edit")
+		}
+	}
+
+	// An unwritable session dir falls back to env vars.
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, nil, 0o600)
+	if err := json.Unmarshal([]byte(sessionMCPConfig("/usr/local/bin/self", blocker, reg, nil, mcp.LifecycleConfig{})), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if env := parsed.MCPServers["codespace"].Env; env["CODESPACE_REGISTRY"] == "" || env[mcpHandshakeEnv] != "" {
+		t.Errorf("fallback env = %v, want CODESPACE_REGISTRY", env)
+	}
+}
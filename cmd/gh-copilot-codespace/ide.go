@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"syscall"
@@ -30,6 +31,10 @@ type ideLockFile struct {
 const ideLockDir = "ide"
 const forwardedLockPrefix = "copilot-codespace-"
 
+// ideFoldersDir holds local stand-ins, inside the mirror, for workspace
+// folders that live outside the codespace workdir.
+const ideFoldersDir = "workspace-folders"
+
 // forwardIDEConnections discovers IDE lock files on the codespace, forwards their
 // Unix sockets locally via SSH, and writes modified lock files so copilot CLI can
 // auto-connect.
@@ -74,13 +79,13 @@ func forwardIDEConnections(sshClient *ssh.Client, codespaceName, localWorkdir, r
 
 		// Forward the remote socket to the local one
 		if err := sshClient.ForwardSocket(ctx, localSocket, lf.SocketPath); err != nil {
-			fmt.Fprintf(os.Stderr, "  ⚠ IDE forward failed for %s: %v\n", lf.IDEName, err)
+			progress.Warn("ide_forward_failed", progressFields{"ide": lf.IDEName}, "  ⚠ IDE forward failed for %s: %v\n", lf.IDEName, err)
 			continue
 		}
 
 		// Verify the forwarded socket actually works
 		if !probeSocket(localSocket) {
-			fmt.Fprintf(os.Stderr, "  ⚠ IDE socket for %s not responding, retrying...\n", lf.IDEName)
+			progress.Warn("ide_forward_retry", progressFields{"ide": lf.IDEName}, "  ⚠ IDE socket for %s not responding, retrying...\n", lf.IDEName)
 			// Cancel and retry — the remote IDE may have restarted
 			sshClient.CancelForward(ctx, localSocket, lf.SocketPath)
 			os.Remove(localSocket)
@@ -92,10 +97,10 @@ func forwardIDEConnections(sshClient *ssh.Client, codespaceName, localWorkdir, r
 					// Remote socket changed — retry with new path
 					if err := sshClient.ForwardSocket(ctx, localSocket, freshLF.SocketPath); err == nil && probeSocket(localSocket) {
 						lf = freshLF
-						fmt.Fprintf(os.Stderr, "  ✓ IDE socket for %s recovered (new remote socket)\n", lf.IDEName)
+						progress.Warn("ide_forward_recovered", progressFields{"ide": lf.IDEName}, "  ✓ IDE socket for %s recovered (new remote socket)\n", lf.IDEName)
 					} else {
 						os.Remove(localSocket)
-						fmt.Fprintf(os.Stderr, "  ⚠ IDE %s: retry failed, skipping\n", lf.IDEName)
+						progress.Warn("ide_forward_failed", progressFields{"ide": lf.IDEName}, "  ⚠ IDE %s: retry failed, skipping\n", lf.IDEName)
 						continue
 					}
 				} else {
@@ -105,15 +110,15 @@ func forwardIDEConnections(sshClient *ssh.Client, codespaceName, localWorkdir, r
 						if freshLF, ok := freshLocks[name]; ok {
 							lf = freshLF
 						}
-						fmt.Fprintf(os.Stderr, "  ✓ IDE socket for %s recovered (re-forwarded)\n", lf.IDEName)
+						progress.Warn("ide_forward_recovered", progressFields{"ide": lf.IDEName}, "  ✓ IDE socket for %s recovered (re-forwarded)\n", lf.IDEName)
 					} else {
 						os.Remove(localSocket)
-						fmt.Fprintf(os.Stderr, "  ⚠ IDE %s: retry failed, skipping\n", lf.IDEName)
+						progress.Warn("ide_forward_failed", progressFields{"ide": lf.IDEName}, "  ⚠ IDE %s: retry failed, skipping\n", lf.IDEName)
 						continue
 					}
 				}
 			} else {
-				fmt.Fprintf(os.Stderr, "  ⚠ IDE %s: could not re-fetch lock files, skipping\n", lf.IDEName)
+				progress.Warn("ide_forward_failed", progressFields{"ide": lf.IDEName}, "  ⚠ IDE %s: could not re-fetch lock files, skipping\n", lf.IDEName)
 				continue
 			}
 		} else {
@@ -136,7 +141,7 @@ func forwardIDEConnections(sshClient *ssh.Client, codespaceName, localWorkdir, r
 			PID:              os.Getpid(), // becomes copilot's PID after syscall.Exec
 			IDEName:          lf.IDEName,
 			Timestamp:        time.Now().UnixMilli(),
-			WorkspaceFolders: []string{remoteWorkdir},
+			WorkspaceFolders: mapIDEWorkspaceFolders(lf.WorkspaceFolders, remoteWorkdir, localWorkdir),
 			IsTrusted:        lf.IsTrusted,
 		}
 
@@ -147,17 +152,78 @@ func forwardIDEConnections(sshClient *ssh.Client, codespaceName, localWorkdir, r
 
 		localLockPath := filepath.Join(localIDEDir, forwardedLockPrefix+hash+".lock")
 		if err := os.WriteFile(localLockPath, lockData, 0o644); err != nil {
-			fmt.Fprintf(os.Stderr, "  ⚠ Failed to write IDE lock file: %v\n", err)
+			progress.Warn("ide_forward_failed", progressFields{"ide": lf.IDEName}, "  ⚠ Failed to write IDE lock file: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("  ✓ IDE: %s (forwarded over SSH)\n", lf.IDEName)
+		for _, dir := range localLF.WorkspaceFolders {
+			os.MkdirAll(dir, 0o755)
+		}
+
+		if n := len(localLF.WorkspaceFolders); n > 1 {
+			progress.Step("ide_forwarded", progressFields{"ide": lf.IDEName, "workspaceFolders": n}, "  ✓ IDE: %s (forwarded over SSH, %d workspace folders)\n", lf.IDEName, n)
+		} else {
+			progress.Step("ide_forwarded", progressFields{"ide": lf.IDEName, "workspaceFolders": n}, "  ✓ IDE: %s (forwarded over SSH)\n", lf.IDEName)
+		}
 		forwarded++
 	}
 
 	return forwarded, nil
 }
 
+// mapIDEWorkspaceFolders maps the IDE's remote workspace folders to local
+// paths, keeping their order so a multi-root workspace stays multi-root. The
+// workdir maps to the mirror and folders below it to mirror subdirectories;
+// folders elsewhere on the codespace get a directory under ideFoldersDir. The
+// mirror is always included so copilot, which runs there, still matches the
+// lock file.
+func mapIDEWorkspaceFolders(remoteFolders []string, remoteWorkdir, localWorkdir string) []string {
+	remoteWorkdir = path.Clean(remoteWorkdir)
+	var mapped []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			mapped = append(mapped, dir)
+		}
+	}
+	outside := make(map[string]string)
+	for _, folder := range remoteFolders {
+		folder = path.Clean(folder)
+		switch {
+		case pathWithin(remoteWorkdir, folder):
+			rel := strings.TrimPrefix(strings.TrimPrefix(folder, remoteWorkdir), "/")
+			add(filepath.Join(localWorkdir, filepath.FromSlash(rel)))
+		case pathWithin(folder, remoteWorkdir):
+			// An ancestor of the workdir: the mirror stands in for it.
+			add(localWorkdir)
+		default:
+			if _, ok := outside[folder]; ok {
+				continue
+			}
+			name := path.Base(folder)
+			if name == "/" {
+				name = "root"
+			}
+			dir := filepath.Join(localWorkdir, ideFoldersDir, name)
+			for i := 2; seen[dir]; i++ {
+				dir = filepath.Join(localWorkdir, ideFoldersDir, fmt.Sprintf("%s-%d", name, i))
+			}
+			outside[folder] = dir
+			add(dir)
+		}
+	}
+	if !seen[localWorkdir] {
+		add(localWorkdir)
+	}
+	return mapped
+}
+
+// pathWithin reports whether p is dir or below it.
+func pathWithin(dir, p string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
 // cleanStaleIDEForwards removes forwarded lock files from previous runs whose
 // PID is no longer running. This handles cleanup since syscall.Exec prevents
 // defer-based cleanup.
@@ -206,11 +272,22 @@ func isLocalPIDRunning(pid int) bool {
 func fetchIDELockFiles(sshClient *ssh.Client, codespaceName string) (map[string]ideLockFile, error) {
 	ctx := context.Background()
 
+	// The extension writes lock files under the home of the user running the
+	// VS Code server, which may not be the SSH user.
+	dirs := `"$HOME/.copilot/ide"`
+	if lockDirs := sshClient.IDELockDirs(); len(lockDirs) > 0 {
+		quoted := make([]string, len(lockDirs))
+		for i, d := range lockDirs {
+			quoted[i] = shellQuote(d)
+		}
+		dirs = strings.Join(quoted, " ")
+	}
+
 	// Batch-read all lock files with boundary separators (same pattern as instruction files)
 	script := `
 SEP="===IDE_LOCK_BOUNDARY==="
-DIR="$HOME/.copilot/ide"
-if [ -d "$DIR" ]; then
+for DIR in ` + dirs + `; do
+  [ -d "$DIR" ] || continue
   for f in "$DIR"/*.lock; do
     [ -f "$f" ] || continue
     echo "$SEP"
@@ -218,7 +295,7 @@ if [ -d "$DIR" ]; then
     cat "$f"
   done
   echo "$SEP"
-fi
+done
 `
 	stdout, stderr, exitCode, err := sshClient.Exec(ctx, script)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -207,3 +208,59 @@ func TestCleanStaleIDEForwards(t *testing.T) {
 		t.Error("non-forwarded lock file should not have been removed")
 	}
 }
+
+func TestMapIDEWorkspaceFolders(t *testing.T) {
+	const mirror = "/home/user/.copilot/codespace-workdirs/my-cs"
+	tests := []struct {
+		name    string
+		folders []string
+		want    []string
+	}{
+		{
+			name:    "workdir maps to mirror",
+			folders: []string{"/workspaces/repo"},
+			want:    []string{mirror},
+		},
+		{
+			name:    "multi-root keeps order",
+			folders: []string{"/workspaces/repo/packages/api", "/workspaces/repo", "/workspaces/other"},
+			want: []string{
+				mirror + "/packages/api",
+				mirror,
+				mirror + "/workspace-folders/other",
+			},
+		},
+		{
+			name:    "mirror added when no folder covers it",
+			folders: []string{"/workspaces/repo/web/"},
+			want:    []string{mirror + "/web", mirror},
+		},
+		{
+			name:    "ancestor of workdir maps to mirror",
+			folders: []string{"/workspaces"},
+			want:    []string{mirror},
+		},
+		{
+			name:    "same base name outside workdir stays distinct",
+			folders: []string{"/workspaces/repo", "/srv/lib", "/opt/lib", "/srv/lib"},
+			want:    []string{mirror, mirror + "/workspace-folders/lib", mirror + "/workspace-folders/lib-2"},
+		},
+		{
+			name:    "sibling prefix is not inside workdir",
+			folders: []string{"/workspaces/repo-tools"},
+			want:    []string{mirror + "/workspace-folders/repo-tools", mirror},
+		},
+		{
+			name: "no folders",
+			want: []string{mirror},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mapIDEWorkspaceFolders(tt.folders, "/workspaces/repo", mirror)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapIDEWorkspaceFolders(%q) = %q, want %q", tt.folders, got, tt.want)
+			}
+		})
+	}
+}
@@ -55,7 +55,7 @@ func testFetchInstructionFiles(t *testing.T, cs, wd string) (string, map[string]
 	t.Helper()
 	setupTestFixturesOnce(t, cs, wd)
 	client := testSSHClient(t, cs)
-	return fetchInstructionFiles(client, cs, wd, "")
+	return fetchInstructionFiles(client, cs, wd, "", nil)
 }
 
 var fixturesReady bool
@@ -775,7 +775,19 @@ func readFileContent(t *testing.T, path string) string {
 func setupTestFixtures(t *testing.T, cs, wd string) {
 	t.Helper()
 
-	script := fmt.Sprintf(`
+	out, err := exec.Command("gh", "codespace", "ssh", "-c", cs, "--", "bash", "-c", testFixturesScript(wd)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to set up test fixtures on codespace: %v\nOutput: %s", err, string(out))
+	}
+	if !strings.Contains(string(out), "fixtures-ok") {
+		t.Fatalf("fixture setup did not complete successfully.\nOutput: %s", string(out))
+	}
+}
+
+// testFixturesScript returns the bash script that provisions fixtures under wd.
+// It prints "fixtures-ok" on success.
+func testFixturesScript(wd string) string {
+	return fmt.Sprintf(`
 set -e
 WD=%s
 
@@ -903,14 +915,6 @@ chmod +x "$WD/.github/hooks/scripts/test-hook.sh"
 
 echo "fixtures-ok"
 `, wd)
-
-	out, err := exec.Command("gh", "codespace", "ssh", "-c", cs, "--", "bash", "-c", script).CombinedOutput()
-	if err != nil {
-		t.Fatalf("failed to set up test fixtures on codespace: %v\nOutput: %s", err, string(out))
-	}
-	if !strings.Contains(string(out), "fixtures-ok") {
-		t.Fatalf("fixture setup did not complete successfully.\nOutput: %s", string(out))
-	}
 }
 
 // --- Lifecycle integration tests ---
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/provisioner"
+)
+
+// launcherConfigFile holds launcher defaults in the copilot-codespace config
+// directory, next to provisioners.json.
+const launcherConfigFile = "config.json"
+
+// launcherConfig is the launcher defaults file. Every field is optional, and
+// a flag on the command line wins over the field it overlaps.
+type launcherConfig struct {
+	// Codespace is used when neither --codespace nor --no-codespace is given,
+	// as a name or a comma-separated list like -c takes.
+	Codespace string `json:"codespace,omitempty"`
+	// LocalTools is the --local-tools default.
+	LocalTools *bool `json:"localTools,omitempty"`
+	// ExcludedTools are hidden from Copilot on top of the local tools the
+	// launcher already excludes.
+	ExcludedTools []string `json:"excludedTools,omitempty"`
+	// Terminal is the terminal provisioners install terminfo for and match
+	// against, instead of the detected one.
+	Terminal string `json:"terminal,omitempty"`
+	// FetchPatterns are extra files to mirror: paths relative to the workdir,
+	// where * matches any characters including /.
+	FetchPatterns []string `json:"fetchPatterns,omitempty"`
+}
+
+func launcherConfigPath() (string, error) {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("getting home dir: %w", err)
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, "copilot-codespace", launcherConfigFile), nil
+}
+
+// readLauncherConfig parses the defaults file at path. A missing file is an
+// empty config.
+func readLauncherConfig(path string) (launcherConfig, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return launcherConfig{}, nil
+	}
+	if err != nil {
+		return launcherConfig{}, err
+	}
+	var cfg launcherConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return launcherConfig{}, fmt.Errorf("parsing: %w", err)
+	}
+	for _, pattern := range cfg.FetchPatterns {
+		if err := validateFetchPattern(pattern); err != nil {
+			return launcherConfig{}, err
+		}
+	}
+	return cfg, nil
+}
+
+// validateFetchPattern rejects patterns that could match outside the workdir.
+func validateFetchPattern(pattern string) error {
+	clean := path.Clean(pattern)
+	if pattern == "" || path.IsAbs(pattern) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(pattern, "/../") {
+		return fmt.Errorf("fetchPatterns: %q must be a path inside the workdir", pattern)
+	}
+	return nil
+}
+
+// loadLauncherConfig reads the defaults file, warning about and ignoring one
+// that cannot be read, so a broken file never blocks a launch.
+func loadLauncherConfig() launcherConfig {
+	path, err := launcherConfigPath()
+	if err != nil {
+		return launcherConfig{}
+	}
+	cfg, err := readLauncherConfig(path)
+	if err != nil {
+		progress.Warn("config_invalid", progressFields{"path": path, "error": err.Error()}, "Warning: ignoring %s: %v\n", path, err)
+		return launcherConfig{}
+	}
+	return cfg
+}
+
+// applyGlobal applies the defaults that hold for new and resumed sessions
+// alike. The terminal reaches provisioners, including the MCP server's, through
+// provisioner.TerminalEnv unless that is already set.
+func (cfg launcherConfig) applyGlobal() {
+	if cfg.Terminal != "" && os.Getenv(provisioner.TerminalEnv) == "" {
+		os.Setenv(provisioner.TerminalEnv, cfg.Terminal)
+	}
+}
+
+// applyLaunch fills the options of a new session that no flag set. Resumed
+// sessions keep the settings saved with them instead.
+func (cfg launcherConfig) applyLaunch(opts *launcherOptions) {
+	if len(opts.codespaceNames) == 0 && !opts.noCodespace {
+		opts.codespaceNames = splitCodespaceNames(cfg.Codespace)
+	}
+	if !opts.localTools.set && cfg.LocalTools != nil {
+		opts.localTools = optionalBool{set: true, value: *cfg.LocalTools}
+	}
+}
+
+// excludedTools adds the configured tools to the launcher's own exclusions.
+func (cfg launcherConfig) excludedTools(base []string) []string {
+	return uniqueStrings(append(append([]string(nil), base...), cfg.ExcludedTools...))
+}
+
+// splitCodespaceNames splits a comma-separated list of codespace names.
+func splitCodespaceNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
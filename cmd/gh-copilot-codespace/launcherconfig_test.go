@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/provisioner"
+)
+
+func TestReadLauncherConfig(t *testing.T) {
+	dir := t.TempDir()
+	if cfg, err := readLauncherConfig(filepath.Join(dir, "missing.json")); err != nil || !reflect.DeepEqual(cfg, launcherConfig{}) {
+		t.Fatalf("missing file = %+v, %v; want empty config", cfg, err)
+	}
+
+	write := func(content string) string {
+		path := filepath.Join(dir, launcherConfigFile)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg, err := readLauncherConfig(write(`{"codespace": "cs-api", "localTools": true, "excludedTools": ["web_fetch"],
+		"terminal": "xterm-kitty", "fetchPatterns": ["docs/*.md", ".cursor/rules/*"]}`))
+	if err != nil {
+		t.Fatalf("readLauncherConfig() error = %v", err)
+	}
+	if cfg.Codespace != "cs-api" || cfg.LocalTools == nil || !*cfg.LocalTools || cfg.Terminal != "xterm-kitty" ||
+		!reflect.DeepEqual(cfg.ExcludedTools, []string{"web_fetch"}) || !reflect.DeepEqual(cfg.FetchPatterns, []string{"docs/*.md", ".cursor/rules/*"}) {
+		t.Fatalf("readLauncherConfig() = %+v", cfg)
+	}
+
+	for _, bad := range []string{`{"codespace": 3}`, `{"fetchPatterns": ["/etc/*"]}`, `{"fetchPatterns": ["../other/*"]}`, `{"fetchPatterns": ["a/../../b"]}`} {
+		if _, err := readLauncherConfig(write(bad)); err == nil {
+			t.Errorf("readLauncherConfig(%s) error = nil", bad)
+		}
+	}
+}
+
+func TestLoadLauncherConfigIgnoresBrokenFile(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if err := os.MkdirAll(filepath.Join(configHome, "copilot-codespace"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "copilot-codespace", launcherConfigFile), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	progress = newProgressReporter(progressQuiet, os.Stderr, os.Stderr)
+	if cfg := loadLauncherConfig(); !reflect.DeepEqual(cfg, launcherConfig{}) {
+		t.Fatalf("loadLauncherConfig() = %+v, want empty config", cfg)
+	}
+}
+
+func TestLauncherConfigApplyLaunch(t *testing.T) {
+	yes := true
+	cfg := launcherConfig{Codespace: "cs-api, cs-web", LocalTools: &yes}
+
+	var opts launcherOptions
+	cfg.applyLaunch(&opts)
+	if !reflect.DeepEqual(opts.codespaceNames, []string{"cs-api", "cs-web"}) || opts.localTools != (optionalBool{set: true, value: true}) {
+		t.Fatalf("defaults not applied: %+v", opts)
+	}
+
+	opts, err := parseLauncherArgs([]string{"-c", "cs-docs", "--local-tools=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.applyLaunch(&opts)
+	if !reflect.DeepEqual(opts.codespaceNames, []string{"cs-docs"}) || opts.localTools != (optionalBool{set: true, value: false}) {
+		t.Fatalf("flags overridden by config: %+v", opts)
+	}
+
+	opts = launcherOptions{noCodespace: true}
+	cfg.applyLaunch(&opts)
+	if len(opts.codespaceNames) != 0 {
+		t.Fatalf("--no-codespace overridden by config: %v", opts.codespaceNames)
+	}
+}
+
+func TestLauncherConfigExcludedTools(t *testing.T) {
+	cfg := launcherConfig{ExcludedTools: []string{"web_fetch", "grep"}}
+	got := cfg.excludedTools(launcherExcludedTools(false))
+	want := []string{"bash", "write_bash", "read_bash", "stop_bash", "list_bash", "grep", "glob", "web_fetch"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("excludedTools() = %v, want %v", got, want)
+	}
+	if got := cfg.excludedTools(launcherExcludedTools(true)); !reflect.DeepEqual(got, []string{"web_fetch", "grep"}) {
+		t.Fatalf("excludedTools() with local tools = %v", got)
+	}
+}
+
+func TestLauncherConfigApplyGlobalKeepsExplicitTerminal(t *testing.T) {
+	t.Setenv(provisioner.TerminalEnv, "")
+	launcherConfig{Terminal: "xterm-kitty"}.applyGlobal()
+	if got := os.Getenv(provisioner.TerminalEnv); got != "xterm-kitty" {
+		t.Fatalf("%s = %q after applyGlobal", provisioner.TerminalEnv, got)
+	}
+
+	t.Setenv(provisioner.TerminalEnv, "xterm-ghostty")
+	launcherConfig{Terminal: "xterm-kitty"}.applyGlobal()
+	if got := os.Getenv(provisioner.TerminalEnv); got != "xterm-ghostty" {
+		t.Fatalf("%s = %q, want the explicit value kept", provisioner.TerminalEnv, got)
+	}
+}
+
+func TestInstructionFetchScript_ExtraPatterns(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	wd := t.TempDir()
+	for rel, content := range map[string]string{
+		"AGENTS.md":                "root",
+		"docs/guide.md":            "guide",
+		"docs/deep/notes.md":       "notes",
+		"docs/image.png":           "png",
+		"node_modules/pkg/docs.md": "ignored",
+	} {
+		path := filepath.Join(wd, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("bash", "-c", instructionFetchScript(wd, "docs/*.md", "AGENTS.md")).Output()
+	if err != nil {
+		t.Fatalf("running fetch script: %v", err)
+	}
+	if count, _, _ := strings.Cut(string(out), "\x00"); count != "3" {
+		t.Errorf("manifest count = %s, want 3 (AGENTS.md sent once)", count)
+	}
+	got := parseBatchedOutput(string(out))
+	want := map[string][]byte{
+		"AGENTS.md":          []byte("root"),
+		"docs/guide.md":      []byte("guide"),
+		"docs/deep/notes.md": []byte("notes"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fetched = %q, want %q", got, want)
+	}
+}
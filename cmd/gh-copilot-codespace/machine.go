@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// probeMachine reads the codespace's core count and memory for concurrency
+// hints, warning up front when the machine is too small for heavy builds.
+func probeMachine(ctx context.Context, sshClient *ssh.Client, codespaceName string) (cpus int, memoryBytes int64) {
+	cpus, memoryBytes = mcp.ProbeMachine(ctx, sshClient)
+	summary := mcp.MachineSummary(cpus, memoryBytes)
+	if summary == "" {
+		return cpus, memoryBytes
+	}
+	fields := progressFields{"codespace": codespaceName, "cpus": cpus, "memoryBytes": memoryBytes}
+	progress.Step("machine_detected", fields, "  Machine: %s\n", summary)
+	if cpus <= mcp.SmallMachineCPUs {
+		progress.Warn("machine_small", fields, "  ⚠ %s has only %d cores; full builds and test suites will be slow. Consider a larger machine type.\n", codespaceName, cpus)
+	}
+	return cpus, memoryBytes
+}
+
+// probeClock reads the codespace's time zone and clock offset so remote
+// timestamps can be related to local time, warning when the clocks disagree.
+func probeClock(ctx context.Context, sshClient *ssh.Client, codespaceName string) (zone string, utcOffset int, clockOffset time.Duration) {
+	zone, utcOffset, clockOffset = mcp.ProbeClock(ctx, sshClient)
+	if zone == "" {
+		return zone, utcOffset, clockOffset
+	}
+	fields := progressFields{"codespace": codespaceName, "timeZone": zone, "utcOffset": utcOffset, "clockOffsetMs": clockOffset.Milliseconds()}
+	progress.Step("clock_detected", fields, "  Time zone: %s\n", mcp.ZoneSummary(zone, utcOffset))
+	if clockOffset >= time.Minute || clockOffset <= -time.Minute {
+		progress.Warn("clock_skew", fields, "  ⚠ %s's clock is off from local time by %s; file times will not line up with local events.\n", codespaceName, clockOffset)
+	}
+	return zone, utcOffset, clockOffset
+}
+
+// probeRemoteUser detects who commands run as on the codespace, so
+// home-relative paths (mise shims, IDE lock files) point at the right user.
+func probeRemoteUser(ctx context.Context, sshClient *ssh.Client, codespaceName, workdir string) ssh.RemoteUser {
+	u, err := sshClient.DetectRemoteUser(ctx, workdir)
+	if err != nil {
+		progress.Warn("user_detect_failed", progressFields{"codespace": codespaceName}, "  ⚠ Could not detect the codespace user; assuming $HOME: %v\n", err)
+		return u
+	}
+	fields := progressFields{"codespace": codespaceName, "user": u.Name, "home": u.Home}
+	if u.WorkspaceOwner != "" && u.WorkspaceOwner != u.Name {
+		fields["workspaceOwner"] = u.WorkspaceOwner
+		progress.Step("user_detected", fields, "  User:      %s (%s); workspace owned by %s\n", u.Name, u.Home, u.WorkspaceOwner)
+	} else {
+		progress.Step("user_detected", fields, "  User:      %s (%s)\n", u.Name, u.Home)
+	}
+	return u
+}
+
+// probeGit reads the repository's remotes and default branch on the codespace
+// for the instructions preamble.
+func probeGit(ctx context.Context, sshClient *ssh.Client, codespaceName, repository, workdir string) ([]registry.GitRemote, string) {
+	if repository == "" {
+		// Nothing to find on GitHub, and gh repo view would only fail.
+		progress.Step("git_detected", progressFields{"codespace": codespaceName, "unpublished": true},
+			"  Git:       repository not published to GitHub\n")
+		return nil, ""
+	}
+	remotes, defaultBranch := mcp.ProbeGit(ctx, sshClient, workdir)
+	if len(remotes) == 0 && defaultBranch == "" {
+		return remotes, defaultBranch
+	}
+	summary := mcp.GitSummary(remotes)
+	if defaultBranch != "" {
+		summary = strings.TrimSpace(summary + " (default branch " + defaultBranch + ")")
+	}
+	progress.Step("git_detected", progressFields{"codespace": codespaceName, "remotes": mcp.GitSummary(remotes), "defaultBranch": defaultBranch},
+		"  Git:       %s\n", summary)
+	return remotes, defaultBranch
+}
+
+// probeDevcontainerHooks reads the lifecycle commands declared in the
+// codespace's devcontainer.json, which the devcontainer_* tools re-run.
+func probeDevcontainerHooks(ctx context.Context, sshClient *ssh.Client, codespaceName, workdir string) []registry.DevcontainerHook {
+	file, hooks, err := mcp.ProbeDevcontainerHooks(ctx, sshClient, workdir)
+	if err != nil {
+		progress.Warn("devcontainer_parse_failed", progressFields{"codespace": codespaceName, "path": file},
+			"  ⚠ Could not read lifecycle commands: %v\n", err)
+		return nil
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	properties := make([]string, len(hooks))
+	for i, h := range hooks {
+		properties[i] = h.Property
+	}
+	progress.Step("devcontainer_hooks_detected", progressFields{"codespace": codespaceName, "path": file, "hooks": properties},
+		"  Hooks:     %s\n", strings.Join(properties, ", "))
+	return hooks
+}
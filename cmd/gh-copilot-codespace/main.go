@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +10,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -18,7 +18,9 @@ import (
 	"syscall"
 	"text/tabwriter"
 	"time"
+	"unicode"
 
+	"github.com/ekroon/gh-copilot-codespace/internal/audit"
 	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
 	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
 	"github.com/ekroon/gh-copilot-codespace/internal/registry"
@@ -28,14 +30,37 @@ import (
 )
 
 type codespace struct {
-	Name        string `json:"name"`
-	DisplayName string `json:"displayName"`
-	Repository  string `json:"repository"`
-	State       string `json:"state"`
+	Name               string    `json:"name"`
+	DisplayName        string    `json:"displayName"`
+	Repository         string    `json:"repository"`
+	State              string    `json:"state"`
+	LastUsedAt         time.Time `json:"lastUsedAt"`
+	MachineName        string    `json:"machineName"`
+	MachineDisplayName string    `json:"machineDisplayName"`
+}
+
+// aliasSource is what the codespace's default alias derives from: its
+// repository, or its display name when the repository is unpublished.
+func (cs codespace) aliasSource() string {
+	if cs.Repository != "" {
+		return cs.Repository
+	}
+	if cs.DisplayName != "" {
+		return cs.DisplayName
+	}
+	return cs.Name
 }
 
 const codespaceLifecycleConfigEnv = "CODESPACE_LIFECYCLE_CONFIG"
 
+// recordingsDirName is the mirror subdirectory that collects session
+// recordings made with --record-sessions. It survives mirror refreshes.
+const recordingsDirName = "logs"
+
+// recordingCollectTimeout bounds copying recordings off the codespaces when
+// the MCP server exits.
+const recordingCollectTimeout = 30 * time.Second
+
 func printUsage() {
 	fmt.Fprintf(os.Stderr, `Usage: gh copilot-codespace [flags] [-- copilot-args...]
 
@@ -47,15 +72,54 @@ Flags:
       --selected-only[=BOOL]
                          Restrict existing-codespace connections to codespaces selected at startup
   -w, --workdir PATH     Override workspace directory on the codespace
+      --send PATH[:REMOTE_PATH]
+                         Upload a local file (design doc, screenshot, patch) to the first
+                         codespace before launch and tell the agent where it is (repeatable;
+                         relative REMOTE_PATHs are under the session's scratch directory)
       --name SESSION     Name for the local workspace session
       --resume [SESSION] Re-attach to a previous workspace session, or choose one interactively
       --local-tools[=BOOL]
                          Keep all local tools (bash, grep, glob) enabled alongside remote_* tools
+      --confine-to-workdir[=BOOL]
+                         Reject file tool paths that resolve outside the codespace workdir,
+                         following symlinks (default: true)
+      --read-only[=BOOL] Only advertise non-mutating remote tools (no edit/create/ln/chmod,
+                         no codespace create/delete)
+      --quiet            Suppress the startup banner and progress lines (warnings still print)
+      --json-status      Emit launch progress as JSON lines on stdout instead of text
+      --pin-host-keys    Store each codespace's SSH host key on first connect and refuse
+                         to connect if it changes
+      --trust-mcp-servers
+                         Forward the codespace's MCP servers without asking for each one
+      --no-auto-start    Report a codespace that suspends mid-session instead of starting it
+      --record-sessions  Record async remote sessions and collect them as asciinema casts
+                         in the mirror's logs/ directory when the session ends
 
 Subcommands:
   mcp                    Run as MCP server (used internally by Copilot)
   exec                   Execute a command on the codespace (used internally)
+  agent --socket PATH    Serve commands on a Unix socket on the codespace (used internally)
   workspaces             List available workspace sessions
+  fetch -c NAME [-w PATH] [--quiet|--json-status]
+                         Mirror instruction files from a codespace and print the mirror path
+  ssh-config -c NAME [--host]
+                         Print the generated SSH config path (or its Host alias) for the
+                         codespace's shared connection
+  ssh -c NAME [-w PATH] [--cmd CMD | -- CMD...]
+                         Open a shell or run a command through the shared SSH connection
+  push -c NAME [-w PATH] [--yes] FILE...
+                         Write edited mirror instruction files back to the codespace, after
+                         showing each diff and asking for confirmation
+  validate-hooks -c NAME [-w PATH] [--run]
+                         Check each of the codespace's hooks over SSH without running it
+                         and report which would fail; --run runs each one for real
+  export-state FILE      Write pinned host keys, file owner overrides, mirror summaries,
+                         workspace sessions, configs, and trusted folders to FILE (- for stdout)
+  import-state [--force] FILE
+                         Restore state written by export-state on a new machine and trust its
+                         folders; --force replaces local files that differ
+  statusline [--dir DIR] Print the session's codespaces, branches, and SSH path for a
+                         Copilot statusline command
 `)
 }
 
@@ -84,6 +148,85 @@ func main() {
 		return
 	}
 
+	// If first arg is "agent", serve commands as a daemon (used on codespace)
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		if err := runAgentDaemon(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "agent: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "fetch", mirror instruction files and print the mirror path
+	if len(os.Args) > 1 && os.Args[1] == "fetch" {
+		if err := runFetch(os.Args[2:]); err != nil {
+			progress.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "ssh-config", print the shared SSH config path
+	if len(os.Args) > 1 && os.Args[1] == "ssh-config" {
+		if err := runSSHConfig(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "ssh", open a shell or run a command over the shared connection
+	if len(os.Args) > 1 && os.Args[1] == "ssh" {
+		code, err := runSSH(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(code)
+	}
+
+	// If first arg is "statusline", print the session's connection state
+	if len(os.Args) > 1 && os.Args[1] == "statusline" {
+		if err := runStatusline(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "push", write edited mirror files back to the codespace
+	if len(os.Args) > 1 && os.Args[1] == "push" {
+		if err := runPush(os.Args[2:]); err != nil {
+			progress.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "export-state" or "import-state", carry launcher state to another machine
+	if len(os.Args) > 1 && os.Args[1] == "export-state" {
+		if err := runExportState(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-state" {
+		if err := runImportState(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// If first arg is "validate-hooks", check the codespace's hooks over SSH
+	if len(os.Args) > 1 && os.Args[1] == "validate-hooks" {
+		if err := runValidateHooks(os.Args[2:]); err != nil {
+			progress.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// If first arg is "workspaces", list/manage workspace sessions
 	if len(os.Args) > 1 && os.Args[1] == "workspaces" {
 		if err := runWorkspaces(os.Args[2:]); err != nil {
@@ -95,7 +238,7 @@ func main() {
 
 	// Otherwise, run as interactive launcher
 	if err := runLauncher(os.Args[1:]); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		progress.Error(err)
 		os.Exit(1)
 	}
 }
@@ -103,16 +246,40 @@ func main() {
 func runMCPServer() {
 	// Support multi-codespace via CODESPACE_REGISTRY env var (JSON)
 	// Falls back to single CODESPACE_NAME for backward compatibility
+	// A handshake file from the launcher takes precedence over both.
+	handshakePath := os.Getenv(mcpHandshakeEnv)
 	registryJSON := os.Getenv("CODESPACE_REGISTRY")
-	lifecycleCfg, err := lifecycleConfigFromEnv(os.Getenv(codespaceLifecycleConfigEnv))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "codespace-mcp: invalid %s: %v\n", codespaceLifecycleConfigEnv, err)
-		os.Exit(1)
+	var handshake mcpHandshake
+	var lifecycleCfg mcp.LifecycleConfig
+	var err error
+	if handshakePath != "" {
+		handshake, err = loadMCPHandshake(handshakePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "codespace-mcp: invalid %s: %v\n", mcpHandshakeEnv, err)
+			os.Exit(1)
+		}
+		lifecycleCfg = handshake.Lifecycle.lifecycleConfig()
+	} else {
+		lifecycleCfg, err = lifecycleConfigFromEnv(os.Getenv(codespaceLifecycleConfigEnv))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "codespace-mcp: invalid %s: %v\n", codespaceLifecycleConfigEnv, err)
+			os.Exit(1)
+		}
 	}
 	lifecycleCfg.Provisioners = loadProvisioners()
+	auditLogger := loadAuditLogger()
+	if auditLogger != nil {
+		lifecycleCfg.Audit = auditLogger
+	}
 
 	var reg *registry.Registry
-	if registryJSON != "" {
+	if handshakePath != "" {
+		reg, err = connectRegistryEntries(handshake.Codespaces)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "codespace-mcp: invalid handshake registry: %v\n", err)
+			os.Exit(1)
+		}
+	} else if registryJSON != "" {
 		reg, err = registryFromJSON(registryJSON)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "codespace-mcp: invalid CODESPACE_REGISTRY: %v\n", err)
@@ -142,28 +309,89 @@ func runMCPServer() {
 		}
 	}
 
+	lifecycleCfg.PortForwards = mcp.NewPortForwards()
 	mcpServer := mcp.NewServer(reg, lifecycleCfg)
 
 	log.SetOutput(os.Stderr)
 	log.Printf("codespace-mcp: starting with %d codespace(s)", reg.Len())
 
-	if err := server.ServeStdio(mcpServer); err != nil {
-		log.Fatalf("codespace-mcp: server error: %v", err)
+	serveErr := server.ServeStdio(mcpServer)
+	stopAgentDaemons(reg)
+	closeNativeSSH(reg)
+	lifecycleCfg.PortForwards.StopAll()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := auditLogger.Close(flushCtx); err != nil {
+		log.Printf("codespace-mcp: %v", err)
+	}
+	cancel()
+	collectCtx, cancel := context.WithTimeout(context.Background(), recordingCollectTimeout)
+	casts, err := mcp.CollectRecordings(collectCtx, reg, lifecycleCfg.RecordingDir)
+	if len(casts) > 0 {
+		log.Printf("codespace-mcp: saved %d session recording(s) to %s", len(casts), lifecycleCfg.RecordingDir)
+	}
+	if err != nil {
+		log.Printf("codespace-mcp: %v", err)
+	}
+	cancel()
+	if lifecycleCfg.SealMirrorDir != "" {
+		if files, err := sealMirrorAtExit(lifecycleCfg.SealMirrorDir); err != nil {
+			log.Printf("codespace-mcp: %v", err)
+		} else if files > 0 {
+			log.Printf("codespace-mcp: encrypted %d mirrored file(s) in %s", files, lifecycleCfg.SealMirrorDir)
+		}
+	}
+	if serveErr != nil {
+		log.Fatalf("codespace-mcp: server error: %v", serveErr)
+	}
+}
+
+// loadAuditLogger starts the audit sink from audit.json, if configured. A bad
+// config is reported and disables auditing rather than the server.
+func loadAuditLogger() *audit.Logger {
+	cfg, err := audit.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "codespace-mcp: warning: could not load audit config: %v\n", err)
+		return nil
 	}
+	logger, err := audit.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "codespace-mcp: warning: audit disabled: %v\n", err)
+		return nil
+	}
+	return logger
 }
 
 // registryEntry is the JSON-serializable form of a codespace for MCP config env.
 type registryEntry struct {
-	Alias      string `json:"alias"`
-	Name       string `json:"name"`
-	Repository string `json:"repository"`
-	Branch     string `json:"branch"`
-	Workdir    string `json:"workdir"`
+	Alias       string          `json:"alias"`
+	Name        string          `json:"name"`
+	Repository  string          `json:"repository"`
+	Branch      string          `json:"branch"`
+	Workdir     string          `json:"workdir"`
+	CPUs        int             `json:"cpus,omitempty"`
+	MemoryBytes int64           `json:"memoryBytes,omitempty"`
+	TimeZone    string          `json:"timeZone,omitempty"`
+	UTCOffset   int             `json:"utcOffset,omitempty"`
+	ClockOffset int64           `json:"clockOffsetMs,omitempty"`
+	ExecAgent   string          `json:"execAgent,omitempty"`
+	RemoteUser  *ssh.RemoteUser `json:"remoteUser,omitempty"`
+
+	DevcontainerHooks []registry.DevcontainerHook `json:"devcontainerHooks,omitempty"`
 }
 
 type lifecycleConfigEnvData struct {
-	AccessPolicy *mcp.CodespaceAccessPolicy   `json:"accessPolicy,omitempty"`
-	Workspace    *mcp.WorkspaceSessionContext `json:"workspace,omitempty"`
+	AccessPolicy     *mcp.CodespaceAccessPolicy   `json:"accessPolicy,omitempty"`
+	Workspace        *mcp.WorkspaceSessionContext `json:"workspace,omitempty"`
+	ReadOnly         bool                         `json:"readOnly,omitempty"`
+	NoAutoStart      bool                         `json:"noAutoStart,omitempty"`
+	LogTools         bool                         `json:"logTools,omitempty"`
+	DeniedTools      []string                     `json:"deniedTools,omitempty"`
+	RecordingDir     string                       `json:"recordingDir,omitempty"`
+	SealMirrorDir    string                       `json:"sealMirrorDir,omitempty"`
+	ConfineToWorkdir bool                         `json:"confineToWorkdir,omitempty"`
+	RemoteTask       bool                         `json:"remoteTask,omitempty"`
+	ConfirmDiscard   bool                         `json:"confirmDiscard,omitempty"`
+	WriteQuota       int64                        `json:"writeQuota,omitempty"`
 }
 
 func lifecycleConfigFromEnv(data string) (mcp.LifecycleConfig, error) {
@@ -175,7 +403,12 @@ func lifecycleConfigFromEnv(data string) (mcp.LifecycleConfig, error) {
 	if err := json.Unmarshal([]byte(data), &env); err != nil {
 		return mcp.LifecycleConfig{}, fmt.Errorf("parsing lifecycle config: %w", err)
 	}
+	return env.lifecycleConfig(), nil
+}
 
+// lifecycleConfig converts the serialized launcher settings back into the
+// MCP server's lifecycle config.
+func (env lifecycleConfigEnvData) lifecycleConfig() mcp.LifecycleConfig {
 	var cfg mcp.LifecycleConfig
 	if env.AccessPolicy != nil {
 		cfg.AccessPolicy = mcp.CodespaceAccessPolicy{
@@ -189,10 +422,36 @@ func lifecycleConfigFromEnv(data string) (mcp.LifecycleConfig, error) {
 			Dir:  env.Workspace.Dir,
 		}
 	}
-	return cfg, nil
+	cfg.ReadOnly = env.ReadOnly
+	cfg.NoAutoStart = env.NoAutoStart
+	if env.LogTools {
+		cfg.ToolLog = os.Stderr
+	}
+	cfg.DeniedTools = uniqueStrings(env.DeniedTools)
+	cfg.RecordingDir = env.RecordingDir
+	cfg.SealMirrorDir = env.SealMirrorDir
+	cfg.ConfineToWorkdir = env.ConfineToWorkdir
+	cfg.RemoteTask = env.RemoteTask
+	cfg.ConfirmDiscard = env.ConfirmDiscard
+	cfg.WriteQuota = env.WriteQuota
+	return cfg
 }
 
 func lifecycleConfigEnvJSON(cfg mcp.LifecycleConfig) string {
+	env := newLifecycleConfigEnvData(cfg)
+	if env.empty() {
+		return ""
+	}
+	out, err := json.Marshal(env)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// newLifecycleConfigEnvData captures the launcher settings in cfg that the MCP
+// server needs.
+func newLifecycleConfigEnvData(cfg mcp.LifecycleConfig) lifecycleConfigEnvData {
 	var env lifecycleConfigEnvData
 	if cfg.AccessPolicy.SelectedOnly || len(cfg.AccessPolicy.AllowedCodespaceNames) > 0 {
 		env.AccessPolicy = &mcp.CodespaceAccessPolicy{
@@ -206,14 +465,85 @@ func lifecycleConfigEnvJSON(cfg mcp.LifecycleConfig) string {
 			Dir:  cfg.Workspace.Dir,
 		}
 	}
-	if env.AccessPolicy == nil && env.Workspace == nil {
-		return ""
+	env.ReadOnly = cfg.ReadOnly
+	env.NoAutoStart = cfg.NoAutoStart
+	env.LogTools = cfg.ToolLog != nil
+	if len(cfg.DeniedTools) > 0 {
+		env.DeniedTools = uniqueStrings(cfg.DeniedTools)
+	}
+	env.RecordingDir = cfg.RecordingDir
+	env.SealMirrorDir = cfg.SealMirrorDir
+	env.ConfineToWorkdir = cfg.ConfineToWorkdir
+	env.RemoteTask = cfg.RemoteTask
+	env.ConfirmDiscard = cfg.ConfirmDiscard
+	env.WriteQuota = cfg.WriteQuota
+	return env
+}
+
+// empty reports whether env holds only defaults.
+func (env lifecycleConfigEnvData) empty() bool {
+	return env.AccessPolicy == nil && env.Workspace == nil && !env.ReadOnly && !env.NoAutoStart &&
+		!env.LogTools && len(env.DeniedTools) == 0 && env.RecordingDir == "" &&
+		env.SealMirrorDir == "" && !env.ConfineToWorkdir && !env.RemoteTask && !env.ConfirmDiscard && env.WriteQuota == 0
+}
+
+// Environment variables that configure the MCP server's tool middlewares,
+// remote_bash's discard confirmation, the write quota, and experimental tools.
+const (
+	logToolsEnv       = "COPILOT_CODESPACE_LOG_TOOLS"
+	denyToolsEnv      = "COPILOT_CODESPACE_DENY_TOOLS"
+	remoteTaskEnv     = "COPILOT_CODESPACE_REMOTE_TASK"
+	confirmDiscardEnv = "COPILOT_CODESPACE_CONFIRM_DISCARD"
+	writeQuotaEnv     = "COPILOT_CODESPACE_WRITE_QUOTA"
+)
+
+// applyToolMiddlewareEnv reads logToolsEnv, denyToolsEnv, remoteTaskEnv,
+// confirmDiscardEnv, and writeQuotaEnv into cfg, so the launcher can pass them
+// to the MCP server with the rest of the session settings.
+func applyToolMiddlewareEnv(cfg *mcp.LifecycleConfig) {
+	if v, err := strconv.ParseBool(os.Getenv(remoteTaskEnv)); err == nil && v {
+		cfg.RemoteTask = true
 	}
-	out, err := json.Marshal(env)
-	if err != nil {
-		return ""
+	if v, err := strconv.ParseBool(os.Getenv(confirmDiscardEnv)); err == nil && v {
+		cfg.ConfirmDiscard = true
+	}
+	if v := os.Getenv(writeQuotaEnv); v != "" {
+		if n, err := parseByteSize(v); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", writeQuotaEnv, err)
+		} else {
+			cfg.WriteQuota = n
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv(logToolsEnv)); err == nil && v {
+		cfg.ToolLog = os.Stderr
+	}
+	for _, name := range strings.Split(os.Getenv(denyToolsEnv), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.DeniedTools = append(cfg.DeniedTools, name)
+		}
 	}
-	return string(out)
+}
+
+// parseByteSize parses a size such as 500000, 200K, 50MB, or 1GiB. Units are
+// powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit := strings.ToUpper(s[len(num):])
+	if trimmed := strings.TrimSuffix(unit, "IB"); trimmed != unit {
+		unit = trimmed
+	} else {
+		unit = strings.TrimSuffix(unit, "B")
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: want bytes or a number with K, M, or G", s)
+	}
+	shift, ok := map[string]uint{"": 0, "K": 10, "M": 20, "G": 30}[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: want bytes or a number with K, M, or G", s)
+	}
+	return n << shift, nil
 }
 
 // registryFromJSON deserializes CODESPACE_REGISTRY env var and creates SSH clients.
@@ -222,6 +552,12 @@ func registryFromJSON(data string) (*registry.Registry, error) {
 	if err := json.Unmarshal([]byte(data), &entries); err != nil {
 		return nil, fmt.Errorf("parsing registry: %w", err)
 	}
+	return connectRegistryEntries(entries)
+}
+
+// connectRegistryEntries creates SSH clients for the codespaces the launcher
+// passed to the MCP server.
+func connectRegistryEntries(entries []registryEntry) (*registry.Registry, error) {
 	return registryFromEntries(context.Background(), entries, func(ctx context.Context, e registryEntry) (*registry.ManagedCodespace, error) {
 		sshClient := ssh.NewClient(e.Name)
 		if err := sshClient.SetupMultiplexing(ctx); err != nil {
@@ -230,13 +566,29 @@ func registryFromJSON(data string) (*registry.Registry, error) {
 		if e.Workdir != "" {
 			sshClient.SetWorkdir(e.Workdir)
 		}
+		if e.RemoteUser != nil {
+			sshClient.SetRemoteUser(*e.RemoteUser)
+		}
+		if e.ExecAgent != "" && agentDaemonEnabled() {
+			if err := sshClient.StartAgentDaemon(ctx, e.ExecAgent, loginEnvEnabled()); err != nil {
+				fmt.Fprintf(os.Stderr, "codespace-mcp: agent daemon unavailable for %s, running commands over ssh: %v\n", e.Alias, err)
+			}
+		}
 		return &registry.ManagedCodespace{
-			Alias:      e.Alias,
-			Name:       e.Name,
-			Repository: e.Repository,
-			Branch:     e.Branch,
-			Workdir:    e.Workdir,
-			Executor:   sshClient,
+			Alias:       e.Alias,
+			Name:        e.Name,
+			Repository:  e.Repository,
+			Branch:      e.Branch,
+			Workdir:     e.Workdir,
+			Executor:    sshClient,
+			CPUs:        e.CPUs,
+			MemoryBytes: e.MemoryBytes,
+			TimeZone:    e.TimeZone,
+			UTCOffset:   e.UTCOffset,
+			ClockOffset: time.Duration(e.ClockOffset) * time.Millisecond,
+			ExecAgent:   e.ExecAgent,
+
+			DevcontainerHooks: e.DevcontainerHooks,
 		}, nil
 	})
 }
@@ -264,7 +616,30 @@ type launcherOptions struct {
 	resumeSession     string
 	resumeInteractive bool
 	localTools        optionalBool
+	readOnly          optionalBool
+	confineToWorkdir  optionalBool
+	quiet             bool
+	jsonStatus        bool
+	pinHostKeys       bool
+	trustMCPServers   bool
+	noAutoStart       bool
+	recordSessions    bool
+	sendFiles         []sendSpec
 	copilotArgs       []string
+	// defaults is the launcher config file, read after parsing.
+	defaults launcherConfig
+}
+
+// progressMode returns how launch progress should be reported. --json-status
+// takes precedence over --quiet.
+func (o launcherOptions) progressMode() progressMode {
+	switch {
+	case o.jsonStatus:
+		return progressJSON
+	case o.quiet:
+		return progressQuiet
+	}
+	return progressHuman
 }
 
 type optionalBool struct {
@@ -280,14 +655,21 @@ func (b optionalBool) resolve(defaultValue bool) bool {
 }
 
 type resumeConfig struct {
-	sessionName  string
-	localTools   optionalBool
-	selectedOnly optionalBool
-	copilotArgs  []string
+	sessionName      string
+	localTools       optionalBool
+	readOnly         optionalBool
+	selectedOnly     optionalBool
+	confineToWorkdir optionalBool
+	pinHostKeys      bool
+	noAutoStart      bool
+	recordSessions   bool
+	copilotArgs      []string
+	defaults         launcherConfig
 }
 
 type resolvedResumeConfig struct {
 	localTools   bool
+	readOnly     bool
 	accessPolicy mcp.CodespaceAccessPolicy
 }
 
@@ -315,28 +697,54 @@ func parseLauncherArgs(args []string) (launcherOptions, error) {
 			opts.localTools = parsed
 			continue
 		}
+		if parsed, ok, err := parseOptionalBoolFlag(args[i], "--read-only"); err != nil {
+			return launcherOptions{}, err
+		} else if ok {
+			opts.readOnly = parsed
+			continue
+		}
 		if parsed, ok, err := parseOptionalBoolFlag(args[i], "--selected-only"); err != nil {
 			return launcherOptions{}, err
 		} else if ok {
 			opts.selectedOnly = parsed
 			continue
 		}
+		if parsed, ok, err := parseOptionalBoolFlag(args[i], "--confine-to-workdir"); err != nil {
+			return launcherOptions{}, err
+		} else if ok {
+			opts.confineToWorkdir = parsed
+			continue
+		}
 
 		switch {
 		case args[i] == "--no-codespace":
 			opts.noCodespace = true
+		case args[i] == "--quiet":
+			opts.quiet = true
+		case args[i] == "--json-status":
+			opts.jsonStatus = true
+		case args[i] == "--pin-host-keys":
+			opts.pinHostKeys = true
+		case args[i] == "--trust-mcp-servers":
+			opts.trustMCPServers = true
+		case args[i] == "--no-auto-start":
+			opts.noAutoStart = true
+		case args[i] == "--record-sessions":
+			opts.recordSessions = true
 		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
 			// Support comma-separated: -c cs1,cs2
-			for _, name := range strings.Split(args[i+1], ",") {
-				name = strings.TrimSpace(name)
-				if name != "" {
-					opts.codespaceNames = append(opts.codespaceNames, name)
-				}
-			}
+			opts.codespaceNames = append(opts.codespaceNames, splitCodespaceNames(args[i+1])...)
 			i++
 		case (args[i] == "--workdir" || args[i] == "-w") && i+1 < len(args):
 			opts.workdirOverride = args[i+1]
 			i++
+		case args[i] == "--send" && i+1 < len(args):
+			spec, err := parseSendSpec(args[i+1])
+			if err != nil {
+				return launcherOptions{}, err
+			}
+			opts.sendFiles = append(opts.sendFiles, spec)
+			i++
 		case args[i] == "--name" && i+1 < len(args):
 			opts.sessionName = args[i+1]
 			i++
@@ -358,6 +766,9 @@ func parseLauncherArgs(args []string) (launcherOptions, error) {
 	if opts.noCodespace && (opts.resumeSession != "" || opts.resumeInteractive) {
 		return launcherOptions{}, fmt.Errorf("--no-codespace and --resume are mutually exclusive")
 	}
+	if opts.noCodespace && len(opts.sendFiles) > 0 {
+		return launcherOptions{}, fmt.Errorf("--send needs a codespace to send files to; it cannot be used with --no-codespace")
+	}
 	if opts.resumeSession != "" || opts.resumeInteractive {
 		switch {
 		case len(opts.codespaceNames) > 0:
@@ -366,6 +777,8 @@ func parseLauncherArgs(args []string) (launcherOptions, error) {
 			return launcherOptions{}, fmt.Errorf("--workdir and --resume are mutually exclusive")
 		case opts.sessionName != "":
 			return launcherOptions{}, fmt.Errorf("--name and --resume are mutually exclusive")
+		case len(opts.sendFiles) > 0:
+			return launcherOptions{}, fmt.Errorf("--send and --resume are mutually exclusive")
 		}
 	}
 
@@ -390,10 +803,16 @@ func newResumeConfig(opts launcherOptions) (resumeConfig, error) {
 	}
 
 	return resumeConfig{
-		sessionName:  opts.resumeSession,
-		localTools:   opts.localTools,
-		selectedOnly: opts.selectedOnly,
-		copilotArgs:  append([]string(nil), opts.copilotArgs...),
+		sessionName:      opts.resumeSession,
+		localTools:       opts.localTools,
+		readOnly:         opts.readOnly,
+		selectedOnly:     opts.selectedOnly,
+		confineToWorkdir: opts.confineToWorkdir,
+		pinHostKeys:      opts.pinHostKeys,
+		noAutoStart:      opts.noAutoStart,
+		recordSessions:   opts.recordSessions,
+		copilotArgs:      append([]string(nil), opts.copilotArgs...),
+		defaults:         opts.defaults,
 	}, nil
 }
 
@@ -409,6 +828,7 @@ func resolveResumeConfig(manifest *workspace.Manifest, cfg resumeConfig) resolve
 
 	return resolvedResumeConfig{
 		localTools: cfg.localTools.resolve(stored.LocalTools),
+		readOnly:   cfg.readOnly.resolve(stored.ReadOnly),
 		accessPolicy: mcp.CodespaceAccessPolicy{
 			SelectedOnly:          cfg.selectedOnly.resolve(selectedOnly),
 			AllowedCodespaceNames: uniqueStrings(allowedCodespaceNames),
@@ -445,6 +865,10 @@ func runLauncher(args []string) error {
 	if err != nil {
 		return err
 	}
+	progress = newProgressReporter(opts.progressMode(), os.Stdout, os.Stderr)
+	checkGHAuthScopes()
+	opts.defaults = loadLauncherConfig()
+	opts.defaults.applyGlobal()
 
 	// Handle --resume: load workspace and reconnect to codespaces
 	if opts.resumeSession != "" || opts.resumeInteractive {
@@ -460,6 +884,7 @@ func runLauncher(args []string) error {
 		}
 		return runResume(resumeCfg)
 	}
+	opts.defaults.applyLaunch(&opts)
 
 	// The binary serves as both launcher and MCP server
 	self, err := os.Executable()
@@ -467,13 +892,18 @@ func runLauncher(args []string) error {
 		return fmt.Errorf("finding executable: %w", err)
 	}
 
+	// Check the files to send before connecting to anything
+	if _, err := resolveSendSpecs(opts.sendFiles, sendScratchRoot); err != nil {
+		return err
+	}
+
 	// Select codespace(s): use --codespace flag(s) or interactive picker
 	var selectedList []codespace
 	if len(opts.codespaceNames) > 0 {
 		for _, name := range opts.codespaceNames {
 			cs, err := lookupCodespace(name)
 			if err != nil {
-				return fmt.Errorf("codespace %q: %w", name, err)
+				return err
 			}
 			selectedList = append(selectedList, cs)
 		}
@@ -483,8 +913,14 @@ func runLauncher(args []string) error {
 			return err
 		}
 	}
+	warnCodespaceStates(progress, selectedList)
 
-	lifecycleCfg := mcp.LifecycleConfig{}
+	lifecycleCfg := mcp.LifecycleConfig{
+		ReadOnly:         opts.readOnly.resolve(false),
+		NoAutoStart:      opts.noAutoStart,
+		ConfineToWorkdir: opts.confineToWorkdir.resolve(true),
+	}
+	applyToolMiddlewareEnv(&lifecycleCfg)
 	if opts.selectedOnly.resolve(false) {
 		lifecycleCfg.AccessPolicy = mcp.CodespaceAccessPolicy{
 			SelectedOnly:          true,
@@ -501,7 +937,8 @@ func runLauncher(args []string) error {
 	var allRemoteMCPServers map[string]any
 
 	for _, selected := range selectedList {
-		fmt.Printf("Selected: %s (%s)\n", selected.DisplayName, selected.Repository)
+		progress.Step("codespace_selected", progressFields{"codespace": selected.Name, "repository": selected.Repository},
+			"Selected: %s (%s)\n", selected.DisplayName, registry.RepositoryLabel(selected.Repository))
 
 		// Start codespace if needed
 		if selected.State != "Available" {
@@ -520,33 +957,64 @@ func runLauncher(args []string) error {
 				return err
 			}
 		}
-		fmt.Printf("  Workspace: %s\n", workdir)
+		progress.Step("workdir_detected", progressFields{"codespace": selected.Name, "workdir": workdir}, "  Workspace: %s\n", workdir)
+
+		others := findActiveSessions(activeSessionDir(), selected.Name)
+		if len(others) > 0 {
+			warnSharedCodespace(selected.Name, others)
+		}
 
 		// Set up SSH multiplexing early for fast file fetching
 		sshClient := ssh.NewClient(selected.Name)
-		if err := sshClient.SetupMultiplexing(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: SSH multiplexing failed for %s: %v\n", selected.Name, err)
+		sshClient.SetHostKeyPinning(opts.pinHostKeys)
+		if err := sshClient.SetupMultiplexing(ctx); errors.Is(err, ssh.ErrHostKeyChanged) {
+			return err
+		} else if err != nil {
+			progress.Warn("ssh_multiplexing_failed", progressFields{"codespace": selected.Name}, "Warning: SSH multiplexing failed for %s: %v\n", selected.Name, err)
+		}
+		if checkDevcontainerRebuild(ctx, sshClient, selected.Name, workdir) {
+			if err := sshClient.SetupMultiplexing(ctx); errors.Is(err, ssh.ErrHostKeyChanged) {
+				return err
+			} else if err != nil {
+				progress.Warn("ssh_multiplexing_failed", progressFields{"codespace": selected.Name}, "Warning: SSH multiplexing failed for %s: %v\n", selected.Name, err)
+			}
 		}
 
+		probeRemoteUser(ctx, sshClient, selected.Name, workdir)
+
 		// Deploy exec agent binary
-		remoteBinary, err := deployBinary(sshClient, selected.Name)
+		remoteBinary, err := deployOrReuseBinary(sshClient, selected.Name, others)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not deploy exec agent for %s: %v\n", selected.Name, err)
+			progress.Warn("deploy_failed", progressFields{"codespace": selected.Name}, "Warning: could not deploy exec agent for %s: %v\n", selected.Name, err)
 		}
+		recordActiveSession(activeSessionDir(), selected.Name, remoteBinary)
 
 		// Detect branch
 		branch := detectRemoteBranch(sshClient, selected.Name, workdir)
+		cpus, memoryBytes := probeMachine(ctx, sshClient, selected.Name)
+		zone, utcOffset, clockOffset := probeClock(ctx, sshClient, selected.Name)
+		remotes, defaultBranch := probeGit(ctx, sshClient, selected.Name, selected.Repository, workdir)
+		hooks := probeDevcontainerHooks(ctx, sshClient, selected.Name, workdir)
 
-		alias := registry.DefaultAlias(selected.Repository, reg.Aliases())
+		alias := registry.DefaultAlias(selected.aliasSource(), reg.Aliases())
 		sshClient.SetWorkdir(workdir)
 		if err := reg.Register(&registry.ManagedCodespace{
-			Alias:      alias,
-			Name:       selected.Name,
-			Repository: selected.Repository,
-			Branch:     branch,
-			Workdir:    workdir,
-			Executor:   sshClient,
-			ExecAgent:  remoteBinary,
+			Alias:         alias,
+			Name:          selected.Name,
+			Repository:    selected.Repository,
+			Branch:        branch,
+			Workdir:       workdir,
+			Executor:      sshClient,
+			ExecAgent:     remoteBinary,
+			CPUs:          cpus,
+			MemoryBytes:   memoryBytes,
+			TimeZone:      zone,
+			UTCOffset:     utcOffset,
+			ClockOffset:   clockOffset,
+			Remotes:       remotes,
+			DefaultBranch: defaultBranch,
+
+			DevcontainerHooks: hooks,
 		}); err != nil {
 			return fmt.Errorf("registering selected codespace %q: %w", selected.Name, err)
 		}
@@ -567,16 +1035,34 @@ func runLauncher(args []string) error {
 		primary := selectedList[0]
 
 		// Fetch instruction files into a deterministic dir that acts as the cwd
-		instructionsDir, allRemoteMCPServers, err = fetchInstructionFiles(firstSSHClient, primary.Name, firstWorkdir, firstRemoteBinary)
+		instructionsDir, allRemoteMCPServers, err = fetchInstructionFiles(firstSSHClient, primary.Name, firstWorkdir, firstRemoteBinary, opts.defaults.FetchPatterns)
 		if err != nil {
 			return fmt.Errorf("fetching instructions: %w", err)
 		}
+		opts.copilotArgs = applyRepoSettings(instructionsDir, opts.copilotArgs)
+		if len(allRemoteMCPServers) > 0 {
+			allRemoteMCPServers = vetRemoteMCPServers(ctx, firstSSHClient, firstWorkdir, firstRemoteBinary != "", allRemoteMCPServers, launcherMCPServerConfirmer(opts.trustMCPServers))
+		}
+
+		// Upload --send files to the primary codespace's session scratch dir
+		if len(opts.sendFiles) > 0 {
+			sessionName := primary.Name
+			if wsErr == nil {
+				sessionName = ws.Name
+			}
+			files, err := resolveSendSpecs(opts.sendFiles, path.Join(sendScratchRoot, sessionName))
+			if err != nil {
+				return err
+			}
+			sent := sendFiles(ctx, firstSSHClient, primary.Name, files)
+			prependInstructions(instructionsDir, sentFilesInstructions(reg.FindByName(primary.Name).Alias, sent))
+		}
 
 		// Prepend codespace context to copilot-instructions.md
 		if reg.Len() > 1 {
 			writeMultiCodespaceInstructionsPreamble(instructionsDir, reg)
 		} else {
-			writeCodespaceInstructionsPreamble(instructionsDir, firstWorkdir)
+			writeCodespaceInstructionsPreamble(instructionsDir, reg.FindByName(primary.Name))
 		}
 	} else {
 		if wsErr != nil {
@@ -584,7 +1070,7 @@ func runLauncher(args []string) error {
 		}
 		instructionsDir = ws.Dir
 		writeZeroCodespaceInstructionsPreamble(instructionsDir, lifecycleCfg.AccessPolicy)
-		fmt.Println(zeroCodespaceStartupMessage(lifecycleCfg.AccessPolicy))
+		progress.Step("no_codespace", nil, "%s\n", zeroCodespaceStartupMessage(lifecycleCfg.AccessPolicy))
 	}
 
 	if wsErr == nil {
@@ -593,22 +1079,27 @@ func runLauncher(args []string) error {
 			Dir:  ws.Dir,
 		}
 		ws.Manifest.Settings.LocalTools = opts.localTools.resolve(false)
+		ws.Manifest.Settings.ReadOnly = lifecycleCfg.ReadOnly
 		ws.Manifest.SelectedOnly = lifecycleCfg.AccessPolicy.SelectedOnly
 		ws.Manifest.AllowedCodespaceNames = append([]string(nil), lifecycleCfg.AccessPolicy.AllowedCodespaceNames...)
 	}
 
 	// Ensure the directory is trusted by copilot so it doesn't prompt each time
 	if err := ensureTrustedFolder(instructionsDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not auto-trust directory: %v\n", err)
+		progress.Warn("trust_failed", nil, "Warning: could not auto-trust directory: %v\n", err)
 	}
 
 	// Initialize as git repo so copilot treats it as a repo root and loads instructions
 	exec.Command("git", "-C", instructionsDir, "init", "-q").Run()
+	checkEnclosingGitRepo(instructionsDir)
 
 	// Set local branch to match the primary codespace's current branch
 	if all := reg.All(); len(all) > 0 && all[0].Branch != "" {
 		exec.Command("git", "-C", instructionsDir, "symbolic-ref", "HEAD", "refs/heads/"+all[0].Branch).Run()
 	}
+	if all := reg.All(); len(all) > 0 {
+		setupMirrorClone(instructionsDir, all[0].Repository, all[0].Branch)
+	}
 
 	// Generate a postToolUse hook to keep the branch in sync
 	if len(selectedList) > 0 {
@@ -617,24 +1108,39 @@ func runLauncher(args []string) error {
 
 	// Generate remote-explorer custom agent for codespace file exploration
 	generateRemoteExplorerAgent(instructionsDir)
+	if len(selectedList) > 0 {
+		recordMirrorManifest(instructionsDir)
+	}
 
 	// Change to the instructions dir so copilot finds the instruction files
 	if err := os.Chdir(instructionsDir); err != nil {
 		return fmt.Errorf("changing to instructions dir: %w", err)
 	}
 
+	if opts.recordSessions {
+		lifecycleCfg.RecordingDir = filepath.Join(instructionsDir, recordingsDirName)
+	}
+	if len(selectedList) > 0 && encryptMirrorEnabled() {
+		lifecycleCfg.SealMirrorDir = instructionsDir
+	}
+
 	// Build MCP config with registry serialization for multi-CS support
-	mcpConfig := buildMCPConfigWithRegistry(self, reg, allRemoteMCPServers, lifecycleCfg)
+	var mcpConfig string
+	if wsErr == nil {
+		mcpConfig = sessionMCPConfig(self, ws.Dir, reg, allRemoteMCPServers, lifecycleCfg)
+	} else {
+		mcpConfig = buildMCPConfigWithRegistry(self, reg, allRemoteMCPServers, lifecycleCfg)
+	}
 
 	// Excluded tools
-	excludedTools := launcherExcludedTools(opts.localTools.resolve(false))
+	excludedTools := opts.defaults.excludedTools(launcherExcludedTools(opts.localTools.resolve(false)))
 
 	// Forward IDE connections from all connected codespaces
 	for _, cs := range reg.All() {
 		if sshClient, ok := cs.Executor.(*ssh.Client); ok && sshClient.SSHConfigPath() != "" {
 			_, err = forwardIDEConnections(sshClient, cs.Name, instructionsDir, cs.Workdir)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: IDE forwarding failed for %s: %v\n", cs.Alias, err)
+				progress.Warn("ide_forward_failed", progressFields{"alias": cs.Alias}, "Warning: IDE forwarding failed for %s: %v\n", cs.Alias, err)
 			}
 		}
 	}
@@ -649,145 +1155,245 @@ func runLauncher(args []string) error {
 			})
 		}
 		if err := ws.Save(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: could not save workspace manifest: %v\n", err)
+			progress.Warn("session_save_failed", nil, "Warning: could not save workspace manifest: %v\n", err)
 		} else {
-			fmt.Printf("  Session:   %s (resume with --resume %s)\n", ws.Name, ws.Name)
+			progress.Step("session_saved", progressFields{"session": ws.Name, "dir": ws.Dir}, "  Session:   %s (resume with --resume %s)\n", ws.Name, ws.Name)
 		}
 	}
 
-	fmt.Printf("\nLaunching Copilot CLI with remote codespace tools...\n")
-	if reg.Len() == 0 {
-		fmt.Printf("  Codespace: none connected yet\n")
-	}
-	for _, cs := range reg.All() {
-		fmt.Printf("  Codespace: %s (alias: %s, repo: %s)\n", cs.Name, cs.Alias, cs.Repository)
+	if wsErr == nil {
+		publishSessionDir(ws.Dir, reg)
 	}
-	fmt.Printf("  Excluded:  %d local tools\n", len(excludedTools))
-	fmt.Printf("\n")
+
+	reportLaunch("Launching Copilot CLI with remote codespace tools...", reg, excludedTools)
 
 	// Exec copilot
 	return execCopilot(excludedTools, mcpConfig, opts.copilotArgs)
 }
 
-// lookupCodespace finds a codespace by name (exact or prefix match).
+// lookupCodespace finds a codespace by name (exact or prefix match). Listing
+// stops at the page with an exact match.
 func lookupCodespace(name string) (codespace, error) {
-	out, err := exec.Command("gh", "codespace", "list",
-		"--json", "name,displayName,repository,state",
-		"--limit", "50").Output()
-	if err != nil {
-		return codespace{}, fmt.Errorf("listing codespaces: %w", err)
-	}
-
-	var codespaces []codespace
-	if err := json.Unmarshal(out, &codespaces); err != nil {
-		return codespace{}, fmt.Errorf("parsing codespace list: %w", err)
-	}
-
-	// Try exact match first, then prefix match
-	for _, cs := range codespaces {
-		if cs.Name == name {
-			return cs, nil
+	var exact *codespace
+	codespaces, err := listCodespaces(func(page []codespace, _ int) bool {
+		for i := range page {
+			if page[i].Name == name {
+				exact = &page[i]
+				return false
+			}
 		}
+		return true
+	})
+	if exact != nil {
+		return *exact, nil
 	}
-	for _, cs := range codespaces {
-		if strings.HasPrefix(cs.Name, name) || strings.HasPrefix(cs.DisplayName, name) {
-			return cs, nil
-		}
+	if err != nil {
+		return codespace{}, err
 	}
-	return codespace{}, fmt.Errorf("codespace %q not found", name)
+	return matchCodespace(codespaces, name)
 }
 
 // selectCodespaces lets the user pick zero, one, or many codespaces interactively.
-// Uses gum choose for multi-select if available, otherwise falls back to a numbered list.
+// Uses gum choose for multi-select if available (gum filter past
+// pickerFilterThreshold), otherwise falls back to a numbered list.
 func selectCodespaces() ([]codespace, error) {
-	out, err := exec.Command("gh", "codespace", "list",
-		"--json", "name,displayName,repository,state",
-		"--limit", "50").Output()
+	// Show progress only when the list spans several pages.
+	loaded, paged := 0, false
+	codespaces, err := listCodespaces(func(page []codespace, total int) bool {
+		loaded += len(page)
+		paged = paged || total > loaded
+		if paged {
+			progress.Live("codespaces_loading", progressFields{"loaded": loaded, "total": total}, "  Loading codespaces... %d of %d", loaded, total)
+		}
+		return true
+	})
 	if err != nil {
-		return nil, fmt.Errorf("listing codespaces: %w", err)
+		return nil, err
 	}
-
-	var codespaces []codespace
-	if err := json.Unmarshal(out, &codespaces); err != nil {
-		return nil, fmt.Errorf("parsing codespace list: %w", err)
+	if paged {
+		progress.Step("codespaces_loaded", progressFields{"count": len(codespaces)}, "  Loaded %d codespaces\n", len(codespaces))
 	}
 	if len(codespaces) == 0 {
 		return nil, nil
 	}
 
-	// Sort: available first, then by display name
+	// Sort: ready first, then starting, then stopped; by display name within a group
 	sort.Slice(codespaces, func(i, j int) bool {
-		if (codespaces[i].State == "Available") != (codespaces[j].State == "Available") {
-			return codespaces[i].State == "Available"
+		ri, rj := codespaceStateRank(codespaces[i].State), codespaceStateRank(codespaces[j].State)
+		if ri != rj {
+			return ri < rj
 		}
 		return codespaces[i].DisplayName < codespaces[j].DisplayName
 	})
 
-	// Build display lines: "name\ticon repo: display [state]"
+	// Build display lines: "name\ticon repo: display [state, hint] · machine · last used"
 	lines := make([]string, len(codespaces))
 	for i, cs := range codespaces {
-		icon := "🟢"
-		if cs.State != "Available" {
-			icon = "⏸️"
-		}
-		lines[i] = fmt.Sprintf("%s\t%s %s: %s [%s]", cs.Name, icon, cs.Repository, cs.DisplayName, cs.State)
+		lines[i] = formatCodespaceChoice(cs)
 	}
 
-	// Try gum choose for interactive multi-select.
+	// Try gum choose for interactive multi-select, or gum filter for a long
+	// list so it can be narrowed by typing.
 	if gumPath, err := exec.LookPath("gum"); err == nil {
-		byChoice := make(map[string]codespace, len(lines))
-		for i, l := range lines {
-			byChoice[l] = codespaces[i]
-		}
-
 		cmd := exec.Command(gumPath, "choose", "--no-limit", "--header", "Choose codespace(s) (Space toggles, Enter submits none)")
-		cmd.Stdin = strings.NewReader(strings.Join(lines, "\n"))
+		if len(codespaces) > pickerFilterThreshold {
+			cmd = exec.Command(gumPath, "filter", "--no-limit", "--header", "Type to filter codespaces (Tab toggles, Enter submits none)")
+		}
+		cmd.Stdin = strings.NewReader(strings.Join(numberedChoices(lines), "\n"))
 		cmd.Stderr = os.Stderr
 		selected, err := cmd.Output()
 		if err == nil {
-			return resolveSelectedCodespaces(strings.Split(strings.TrimSpace(string(selected)), "\n"), byChoice), nil
+			return resolveSelectedCodespaces(strings.Split(strings.TrimSpace(string(selected)), "\n"), codespaces), nil
 		}
 		// gum failed (e.g., no TTY), fall through to numbered list.
 	}
 
-	// Fallback: numbered list
-	for i, l := range lines {
-		parts := strings.SplitN(l, "\t", 2)
+	// Fallback: numbered list, narrowed by a filter first when long
+	reader := bufio.NewReader(os.Stdin)
+	shown := filterChoices(lines, "")
+	if len(codespaces) > pickerFilterThreshold {
+		fmt.Printf("%d codespaces. Filter by name, repository, or state (blank for all): ", len(codespaces))
+		query, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading input: %w", err)
+		}
+		shown = filterChoices(lines, query)
+		if len(shown) == 0 {
+			return nil, fmt.Errorf("no codespaces match %q", strings.TrimSpace(query))
+		}
+	}
+	for i, idx := range shown {
+		parts := strings.SplitN(lines[idx], "\t", 2)
 		fmt.Printf("  %2d) %s\n", i+1, parts[1])
 	}
 
-	fmt.Printf("\nSelect [1-%d] (comma-separated, blank for none): ", len(codespaces))
-	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("\nSelect [1-%d] (comma-separated, blank for none): ", len(shown))
 	input, err := reader.ReadString('\n')
 	if err != nil {
 		return nil, fmt.Errorf("reading input: %w", err)
 	}
-	indices, err := parseSelectionIndices(input, len(codespaces))
+	indices, err := parseSelectionIndices(input, len(shown))
 	if err != nil {
 		return nil, err
 	}
 
 	selected := make([]codespace, 0, len(indices))
 	for _, idx := range indices {
-		selected = append(selected, codespaces[idx])
+		selected = append(selected, codespaces[shown[idx]])
 	}
 	return selected, nil
 }
 
-func resolveSelectedCodespaces(selected []string, byDisplay map[string]codespace) []codespace {
+// formatCodespaceChoice renders a picker line: the codespace name, a tab, and
+// its repository, display name, state, machine, and last use.
+func formatCodespaceChoice(cs codespace) string {
+	status := cs.State
+	if hint := codespaceStateHint(cs.State); hint != "" {
+		status += ", " + hint
+	}
+	parts := []string{fmt.Sprintf("%s %s: %s [%s]", codespaceStateIcon(cs.State), registry.RepositoryLabel(cs.Repository), cs.DisplayName, status)}
+	if machine := cs.MachineDisplayName; machine != "" {
+		parts = append(parts, machine)
+	} else if cs.MachineName != "" {
+		parts = append(parts, cs.MachineName)
+	}
+	if !cs.LastUsedAt.IsZero() {
+		parts = append(parts, "last used "+cs.LastUsedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("%s\t%s", cs.Name, strings.Join(parts, " · "))
+}
+
+// codespaceStateRank orders codespace states by how soon they can be used.
+func codespaceStateRank(state string) int {
+	switch state {
+	case "Available":
+		return 0
+	case "Starting", "Provisioning", "Queued", "Awaiting", "Created":
+		return 1
+	case "Shutdown", "ShuttingDown":
+		return 2
+	case "Rebuilding", "Updating", "Exporting":
+		return 3
+	default:
+		return 4
+	}
+}
+
+func codespaceStateIcon(state string) string {
+	switch codespaceStateRank(state) {
+	case 0:
+		return "🟢"
+	case 1:
+		return "🟡"
+	case 2:
+		return "⏸️"
+	case 3:
+		return "🔧"
+	default:
+		return "⚠️"
+	}
+}
+
+// codespaceStateHint gives a rough expectation of how long a codespace in the
+// given state takes to become usable. Timings are typical, not guaranteed.
+func codespaceStateHint(state string) string {
+	switch state {
+	case "Starting", "Provisioning", "Queued", "Awaiting", "Created":
+		return "ready in ~1 min"
+	case "Shutdown":
+		return "starts on connect, ~1-2 min"
+	case "ShuttingDown":
+		return "stopping, restarts on connect"
+	case "Rebuilding", "Updating":
+		return "rebuilding, may take several minutes"
+	case "Exporting":
+		return "exporting"
+	}
+	return ""
+}
+
+// warnCodespaceStates prints a warning for selected codespaces that are not
+// immediately usable, so a slow connect isn't mistaken for a hang.
+func warnCodespaceStates(p *progressReporter, selected []codespace) {
+	for _, cs := range selected {
+		fields := progressFields{"codespace": cs.Name, "state": cs.State}
+		switch codespaceStateRank(cs.State) {
+		case 0:
+			continue
+		case 3:
+			p.Warn("codespace_state", fields, "Warning: codespace %s is %s; connecting will wait until the rebuild finishes and the container may be replaced.\n", cs.Name, cs.State)
+		case 4:
+			p.Warn("codespace_state", fields, "Warning: codespace %s is in state %q and may fail to connect.\n", cs.Name, cs.State)
+		}
+	}
+}
+
+// numberedChoices prefixes each picker line with its 1-based position, so a
+// line gum returns identifies its codespace even when two lines read alike.
+func numberedChoices(lines []string) []string {
+	numbered := make([]string, len(lines))
+	for i, l := range lines {
+		numbered[i] = fmt.Sprintf("%d) %s", i+1, l)
+	}
+	return numbered
+}
+
+// resolveSelectedCodespaces maps lines returned by gum back to codespaces by
+// their numberedChoices prefix, skipping unknown lines and repeats.
+func resolveSelectedCodespaces(selected []string, codespaces []codespace) []codespace {
 	result := make([]codespace, 0, len(selected))
-	seen := make(map[string]bool, len(selected))
+	seen := make(map[int]bool, len(selected))
 	for _, choice := range selected {
-		choice = strings.TrimSpace(choice)
-		if choice == "" {
+		prefix, _, ok := strings.Cut(strings.TrimSpace(choice), ")")
+		if !ok {
 			continue
 		}
-		cs, ok := byDisplay[choice]
-		if !ok || seen[cs.Name] {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 1 || n > len(codespaces) || seen[n] {
 			continue
 		}
-		seen[cs.Name] = true
-		result = append(result, cs)
+		seen[n] = true
+		result = append(result, codespaces[n-1])
 	}
 	return result
 }
@@ -980,7 +1586,7 @@ func legacyCodespaceWorkdirSearchTerms(codespaceNames []string) []string {
 			continue
 		}
 
-		path := filepath.Join(homeDir, ".copilot", "codespace-workdirs", name)
+		path := filepath.Join(ssh.WorkdirsDir(homeDir), name)
 		terms = append(terms, path, shortenHomePath(path))
 	}
 	return terms
@@ -1022,12 +1628,12 @@ func shortenHomePath(path string) string {
 }
 
 func startCodespace(name string) error {
-	fmt.Println("Starting codespace (this may take a moment)...")
+	progress.Step("codespace_starting", progressFields{"codespace": name}, "Starting codespace (this may take a moment)...\n")
 	time.Sleep(3 * time.Second)
 
 	for i := 0; i < 30; i++ {
-		if exec.Command("gh", "codespace", "ssh", "-c", name, "--", "echo ready").Run() == nil {
-			fmt.Println("Codespace is ready!")
+		if exec.Command("gh", append(ssh.GHSSHArgs(name), "--", "echo ready")...).Run() == nil {
+			progress.Step("codespace_ready", progressFields{"codespace": name}, "Codespace is ready!\n")
 			return nil
 		}
 		time.Sleep(2 * time.Second)
@@ -1036,45 +1642,37 @@ func startCodespace(name string) error {
 }
 
 func detectWorkdir(codespaceName, repository string) (string, error) {
-	out, err := exec.Command("gh", "codespace", "ssh", "-c", codespaceName,
-		"--", "ls -d /workspaces/*/ 2>/dev/null",
-	).Output()
+	out, err := exec.Command("gh", append(ssh.GHSSHArgs(codespaceName),
+		"--", codespaceenv.WorkspaceDirsCommand,
+	)...).Output()
 	if err != nil {
 		return "/workspaces", nil
 	}
 
-	raw := strings.TrimSpace(string(out))
-	if raw == "" {
-		return "/workspaces", nil
-	}
-
-	// Parse directory list and strip trailing slashes
-	var dirs []string
-	for _, line := range strings.Split(raw, "\n") {
-		d := strings.TrimRight(strings.TrimSpace(line), "/")
-		if d != "" {
-			dirs = append(dirs, d)
-		}
-	}
+	dirs := codespaceenv.ParseWorkspaceDirs(string(out))
 	if len(dirs) == 0 {
 		return "/workspaces", nil
 	}
 
 	// Try automatic selection based on repository name
 	repoName := repoBaseName(repository)
-	chosen := chooseWorkdir(dirs, repoName)
+	chosen := codespaceenv.ChooseWorkdir(dirs, repoName)
 	if chosen != "" {
 		return chosen, nil
 	}
 
 	// Multiple dirs, no repo match — interactive selection
-	return selectWorkdir(dirs)
+	paths := make([]string, len(dirs))
+	for i, d := range dirs {
+		paths[i] = d.Path
+	}
+	return selectWorkdir(paths)
 }
 
 func sshCommand(codespaceName, command string) (string, error) {
-	out, err := exec.Command("gh", "codespace", "ssh", "-c", codespaceName,
+	out, err := exec.Command("gh", append(ssh.GHSSHArgs(codespaceName),
 		"--", command,
-	).Output()
+	)...).Output()
 	if err != nil {
 		return "", err
 	}
@@ -1098,61 +1696,98 @@ func execSSH(sshClient *ssh.Client, codespaceName, command string) (string, erro
 	return sshCommand(codespaceName, command)
 }
 
-func fetchInstructionFiles(sshClient *ssh.Client, codespaceName, workdir, remoteBinary string) (string, map[string]any, error) {
+// execSSHStream is like execSSH but writes stdout to w as it arrives.
+func execSSHStream(sshClient *ssh.Client, codespaceName, command string, w io.Writer) error {
+	if sshClient != nil {
+		stderr, exitCode, err := sshClient.ExecStream(context.Background(), command, w)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("exit %d: %s", exitCode, strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+	cmd := exec.Command("gh", append(ssh.GHSSHArgs(codespaceName), "--", command)...)
+	cmd.Stdout = w
+	return cmd.Run()
+}
+
+func fetchInstructionFiles(sshClient *ssh.Client, codespaceName, workdir, remoteBinary string, fetchPatterns []string) (string, map[string]any, error) {
 	// Use a deterministic directory so copilot only needs to trust it once per codespace
-	homeDir, err := os.UserHomeDir()
+	baseDir, err := mirrorDirFor(codespaceName)
 	if err != nil {
-		return "", nil, fmt.Errorf("getting home dir: %w", err)
+		return "", nil, err
 	}
-	baseDir := filepath.Join(homeDir, ".copilot", "codespace-workdirs", codespaceName)
 	if err := os.MkdirAll(baseDir, 0o755); err != nil {
 		return "", nil, fmt.Errorf("creating workdir: %w", err)
 	}
-	// Clean all contents except .git/ so stale instruction files don't persist
+	if err := unsealMirrorAtLaunch(baseDir, codespaceName); err != nil {
+		return "", nil, err
+	}
+	previous := loadMirrorSummary(baseDir)
+	// Clean all contents except .git/ so stale instruction files don't persist,
+	// after setting aside files edited locally since the last launch
+	keepLocalMirrorChanges(baseDir, codespaceName)
 	cleanMirrorDir(baseDir)
 
-	fmt.Println("Fetching instruction files from codespace...")
+	progress.Step("fetch_started", progressFields{"codespace": codespaceName}, "Fetching instruction files from codespace...\n")
 
 	// Discover and fetch ALL instruction files, skills, agents, commands,
-	// hooks, and MCP configs in a single SSH call.
-	// Each file is output as: ===FILE_BOUNDARY===\n<relpath>\n<base64-content>
-	batchScript := fmt.Sprintf(`
-WD=%s
-SEP="===FILE_BOUNDARY==="
-files=(
-  $(test -f "$WD/.github/copilot-instructions.md" && echo "$WD/.github/copilot-instructions.md")
-  $(find "$WD/.github/instructions" -name '*.instructions.md' 2>/dev/null)
-  $(find "$WD" \( -name 'AGENTS.md' -o -name 'CLAUDE.md' -o -name 'GEMINI.md' \) 2>/dev/null | grep -v '/\.git/')
-  $(test -f "$WD/.copilot/mcp-config.json" && echo "$WD/.copilot/mcp-config.json")
-  $(find "$WD/.github/agents" -name '*.agent.md' 2>/dev/null)
-  $(find "$WD/.claude/agents" -name '*.agent.md' 2>/dev/null)
-  $(find "$WD/.github/skills" -type f 2>/dev/null)
-  $(find "$WD/.agents/skills" -type f 2>/dev/null)
-  $(find "$WD/.claude/skills" -type f 2>/dev/null)
-  $(test -f "$WD/.vscode/mcp.json" && echo "$WD/.vscode/mcp.json")
-  $(test -f "$WD/.mcp.json" && echo "$WD/.mcp.json")
-  $(test -f "$WD/.github/mcp.json" && echo "$WD/.github/mcp.json")
-  $(find "$WD/.claude/commands" -type f 2>/dev/null)
-  $(find "$WD/.github/hooks" -name '*.json' 2>/dev/null)
-)
-for f in "${files[@]}"; do
-  echo "$SEP"
-  echo "${f#$WD/}"
-  base64 < "$f"
-done
-echo "$SEP"
-`, shellQuote(workdir))
-
-	output, err := execSSH(sshClient, codespaceName, batchScript)
-	if err != nil {
+	// hooks, and MCP configs in a single SSH call, reporting as files arrive.
+	start := time.Now()
+	parser := newBatchFetchParser()
+	parser.onManifest = func(total int, totalBytes int64) {
+		progress.Step("fetch_discovered", progressFields{"files": total, "bytes": totalBytes},
+			"  Found %d files (%s)\n", total, formatByteSize(totalBytes))
+	}
+	parser.onFile = func(fp fetchProgress) {
+		progress.Live("fetch_progress", progressFields{"done": fp.Done, "total": fp.Total, "bytes": fp.Bytes, "totalBytes": fp.TotalBytes, "path": fp.Path},
+			"  [%d/%d] %s/%s  %s", fp.Done, fp.Total, formatByteSize(fp.Bytes), formatByteSize(fp.TotalBytes), fp.Path)
+	}
+	if err := execSSHStream(sshClient, codespaceName, instructionFetchScript(workdir, fetchPatterns...), parser); err != nil {
 		// Non-fatal: continue with empty mirror
-		fmt.Fprintf(os.Stderr, "Warning: failed to fetch instruction files: %v\n", err)
+		progress.Warn("fetch_failed", progressFields{"codespace": codespaceName}, "Warning: failed to fetch instruction files: %v\n", err)
 		return baseDir, nil, nil
 	}
+	files := parser.files
+	var totalBytes int64
+	for _, content := range files {
+		totalBytes += int64(len(content))
+	}
+	fetchedAt := time.Now()
+	elapsed := fetchedAt.Sub(start)
+	progress.Step("fetch_completed", progressFields{"files": len(files), "bytes": totalBytes, "durationMs": elapsed.Milliseconds()},
+		"  Fetched %d files (%s) in %s\n", len(files), formatByteSize(totalBytes), elapsed.Round(100*time.Millisecond))
+
+	remoteMCPConfig, attempted, failures := writeMirrorFiles(baseDir, files, codespaceName, workdir, remoteBinary, fetchedAt)
+	// Files that arrived corrupted or not at all count as failed writes, so a
+	// cut stream trips the same threshold instead of leaving a partial mirror.
+	fetchFailures := parser.failures()
+	failures = append(failures, fetchFailures...)
+	attempted += len(fetchFailures)
+
+	summary := summarizeMirror(files, remoteMCPConfig)
+	progress.Step("mirror_summary", progressFields{"instructions": summary.Instructions, "nested": summary.Nested, "skills": summary.Skills,
+		"agents": summary.Agents, "commands": summary.Commands, "hooks": summary.Hooks, "mcpServers": summary.MCPServers},
+		"  Mirrored: %s\n", summary.format(previous))
+	saveMirrorSummary(baseDir, summary)
+
+	if err := reportMirrorFailures(failures, attempted, mirrorFailureThresholdFromEnv()); err != nil {
+		return baseDir, remoteMCPConfig, err
+	}
+	return baseDir, remoteMCPConfig, nil
+}
 
-	// Parse batched output and write files
+// writeMirrorFiles writes fetched files into baseDir at their paths relative
+// to the workdir, so nested instruction files keep the directory each one
+// applies to. MCP configs are parsed rather than written, and returned
+// merged; hooks and custom agents are rewritten on the way. It also returns
+// how many files it tried to write and which of those failed.
+func writeMirrorFiles(baseDir string, files map[string][]byte, codespaceName, workdir, remoteBinary string, fetchedAt time.Time) (map[string]any, int, []mirrorWriteFailure) {
 	var remoteMCPConfig map[string]any
-	files := parseBatchedOutput(output, workdir)
+	var failures []mirrorWriteFailure
+	attempted := 0
 
 	// MCP config locations to parse (not written to mirror)
 	mcpConfigPaths := map[string]bool{
@@ -1162,6 +1797,7 @@ echo "$SEP"
 		".github/mcp.json":         true,
 	}
 
+	provenance := provenanceModeFromEnv()
 	for relPath, content := range files {
 		if mcpConfigPaths[relPath] {
 			// Parse MCP config for server rewriting instead of writing to mirror
@@ -1173,7 +1809,7 @@ echo "$SEP"
 				for name, server := range parsed {
 					if _, exists := remoteMCPConfig[name]; !exists {
 						remoteMCPConfig[name] = server
-						fmt.Printf("  ✓ MCP server: %s (from %s, forwarded over SSH)\n", name, relPath)
+						progress.Step("mcp_server_forwarded", progressFields{"name": name, "path": relPath}, "  ✓ MCP server: %s (from %s, forwarded over SSH)\n", name, relPath)
 					}
 				}
 			}
@@ -1186,58 +1822,112 @@ echo "$SEP"
 			rewritten := rewriteHooksForSSH(content, codespaceName, workdir, remoteBinary)
 			if rewritten != nil {
 				content = rewritten
-				fmt.Printf("  ✓ %s (hooks forwarded over SSH)\n", relPath)
+				progress.Step("file_fetched", progressFields{"path": relPath, "hooks": true}, "  ✓ %s (hooks forwarded over SSH)\n", relPath)
 			} else {
-				fmt.Fprintf(os.Stderr, "  ⚠ %s (skipped: could not rewrite for SSH)\n", relPath)
+				progress.Warn("hooks_skipped", progressFields{"path": relPath}, "  ⚠ %s (skipped: could not rewrite for SSH)\n", relPath)
 				continue
 			}
+		} else if isAgentFile(relPath) {
+			// Custom agents that list excluded local tools would silently lose
+			// them; grant the remote equivalents as well.
+			if rewritten := rewriteAgentTools(content); rewritten != nil {
+				content = rewritten
+				progress.Step("file_fetched", progressFields{"path": relPath, "agentTools": true}, "  ✓ %s (tools mapped to remote equivalents)\n", relPath)
+			} else {
+				progress.Step("file_fetched", progressFields{"path": relPath}, "  ✓ %s\n", relPath)
+			}
 		} else {
-			fmt.Printf("  ✓ %s\n", relPath)
+			if isInstructionFile(relPath) {
+				content = addProvenanceHeader(content, provenance, codespaceName, path.Join(workdir, relPath), fetchedAt)
+			}
+			progress.Step("file_fetched", progressFields{"path": relPath}, "  ✓ %s\n", relPath)
 		}
+		attempted++
 		localPath := filepath.Join(baseDir, relPath)
 		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			failures = append(failures, mirrorWriteFailure{path: relPath, err: err})
 			continue
 		}
 		if err := os.WriteFile(localPath, content, 0o644); err != nil {
+			failures = append(failures, mirrorWriteFailure{path: relPath, err: err})
 			continue
 		}
 	}
 
-	return baseDir, remoteMCPConfig, nil
+	return remoteMCPConfig, attempted, failures
+}
+
+// instructionFetchScript returns the bash script that discovers and dumps every
+// mirrored file under workdir. Paths are discovered NUL-delimited so names with
+// spaces or newlines survive. Discovery finishes before transfer starts so the
+// launcher can show progress against known totals. Output, all NUL-terminated:
+// <count>, then <relpath> <size> per file, then <relpath> <sha256>
+// <base64-content> per file. extraPatterns are the launcher config's
+// fetchPatterns; a file matched twice is sent once.
+func instructionFetchScript(workdir string, extraPatterns ...string) string {
+	return batchFetchScript(workdir, `  find "$WD" \( -name .git -o -name node_modules \) -prune -o -type f \( -name 'AGENTS.md' -o -name 'CLAUDE.md' -o -name 'GEMINI.md' \
+    -o -path '*/.github/copilot-instructions.md' -o -path '*/.github/instructions/*.instructions.md' \) -print0 2>/dev/null
+  test -f "$WD/.copilot/mcp-config.json" && printf '%s\0' "$WD/.copilot/mcp-config.json"
+  test -f "$WD/.copilot/config.json" && printf '%s\0' "$WD/.copilot/config.json"
+  find "$WD/.github/agents" -name '*.agent.md' -print0 2>/dev/null
+  find "$WD/.claude/agents" -name '*.agent.md' -print0 2>/dev/null
+  find "$WD/.github/skills" -type f -print0 2>/dev/null
+  find "$WD/.agents/skills" -type f -print0 2>/dev/null
+  find "$WD/.claude/skills" -type f -print0 2>/dev/null
+  test -f "$WD/.vscode/mcp.json" && printf '%s\0' "$WD/.vscode/mcp.json"
+  test -f "$WD/.mcp.json" && printf '%s\0' "$WD/.mcp.json"
+  test -f "$WD/.github/mcp.json" && printf '%s\0' "$WD/.github/mcp.json"
+  find "$WD/.claude/commands" -type f -print0 2>/dev/null
+  find "$WD/.github/hooks" -name '*.json' -print0 2>/dev/null`+extraFetchDiscovery(extraPatterns))
+}
+
+// extraFetchDiscovery returns discovery lines for the launcher config's
+// fetchPatterns, pruning .git and node_modules like the instruction search.
+func extraFetchDiscovery(patterns []string) string {
+	var sb strings.Builder
+	for _, pattern := range patterns {
+		fmt.Fprintf(&sb, "\n  find \"$WD\" \\( -name .git -o -name node_modules \\) -prune -o -type f -path \"$WD\"/%s -print0 2>/dev/null", shellQuote(pattern))
+	}
+	return sb.String()
 }
 
-const fileBoundary = "===FILE_BOUNDARY==="
-
-// parseBatchedOutput parses the boundary-delimited output from the batch fetch script.
-// Returns a map of relative paths to file contents (decoded from base64).
-func parseBatchedOutput(output, workdir string) map[string][]byte {
-	files := make(map[string][]byte)
-	parts := strings.Split(output, fileBoundary)
-
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		// First line is the relative path, rest is base64-encoded content
-		lines := strings.SplitN(part, "\n", 2)
-		if len(lines) < 2 {
-			continue
-		}
-		relPath := strings.TrimSpace(lines[0])
-		b64Content := strings.TrimSpace(lines[1])
-		if relPath == "" || b64Content == "" {
-			continue
-		}
+// hookFetchScript dumps only the hook configs under workdir, in the same format
+// as instructionFetchScript.
+func hookFetchScript(workdir string) string {
+	return batchFetchScript(workdir, `  find "$WD/.github/hooks" -name '*.json' -print0 2>/dev/null`)
+}
 
-		decoded, err := base64.StdEncoding.DecodeString(b64Content)
-		if err != nil {
-			continue
-		}
-		files[relPath] = decoded
-	}
+// batchFetchScript wraps discovery, shell lines printing NUL-terminated paths
+// under $WD, in the count/size/checksum/content dump parsed by
+// batchFetchParser.
+func batchFetchScript(workdir, discovery string) string {
+	return fmt.Sprintf(`
+WD=%s
+files=()
+declare -A seen
+while IFS= read -r -d '' f; do [[ -n ${seen["$f"]} ]] || { seen["$f"]=1; files+=("$f"); }; done < <(
+%s
+)
+printf '%%s\0' "${#files[@]}"
+for f in "${files[@]}"; do
+  printf '%%s\0%%s\0' "${f#"$WD"/}" "$(( $(wc -c < "$f") ))"
+done
+for f in "${files[@]}"; do
+  sum=$(sha256sum < "$f" 2>/dev/null || shasum -a 256 < "$f" 2>/dev/null)
+  printf '%%s\0%%s\0' "${f#"$WD"/}" "${sum%%%% *}"
+  base64 < "$f"
+  printf '\0'
+done
+`, shellQuote(workdir), discovery)
+}
 
-	return files
+// parseBatchedOutput parses the complete output of the batch fetch script.
+// Returns a map of relative paths to decoded file contents. Records with an
+// undecodable body or a mismatched checksum are skipped.
+func parseBatchedOutput(output string) map[string][]byte {
+	p := newBatchFetchParser()
+	p.Write([]byte(output))
+	return p.files
 }
 
 // parseMCPConfigJSON parses .copilot/mcp-config.json content and rewrites servers for SSH forwarding.
@@ -1266,7 +1956,7 @@ func parseMCPConfigJSON(content []byte) map[string]any {
 // writeCodespaceInstructionsPreamble prepends a codespace-context section to the
 // copilot-instructions.md in the mirror dir. If the file doesn't exist, it creates it.
 // This tells the agent how to route between local and remote tools.
-func writeCodespaceInstructionsPreamble(mirrorDir, workdir string) {
+func writeCodespaceInstructionsPreamble(mirrorDir string, cs *registry.ManagedCodespace) {
 	preamble := fmt.Sprintf(`# Codespace Remote Development
 
 You are working on a remote GitHub Codespace. Source code lives on the codespace at %s, NOT locally.
@@ -1278,7 +1968,12 @@ You are working on a remote GitHub Codespace. Source code lives on the codespace
 - **Shell commands**: use remote_bash (runs on the codespace), NOT the local bash
 - **Exploring the codebase**: delegate to @remote-explorer instead of the built-in explore agent (the built-in explore agent cannot access remote files)
 
-`, workdir)
+`, cs.Workdir) + pathInstructions(mirrorDir, cs.Workdir) + mcp.MachineInstructions(cs.CPUs, cs.MemoryBytes) + mcp.ClockInstructions(cs.TimeZone, cs.UTCOffset, cs.ClockOffset)
+	if cs.Repository == "" && len(cs.Remotes) == 0 {
+		preamble += mcp.UnpublishedInstructions
+	} else {
+		preamble += mcp.GitInstructions(cs.Remotes, cs.DefaultBranch, cs.Branch)
+	}
 
 	instructionsPath := filepath.Join(mirrorDir, ".github", "copilot-instructions.md")
 	if err := os.MkdirAll(filepath.Dir(instructionsPath), 0o755); err != nil {
@@ -1296,64 +1991,33 @@ You are working on a remote GitHub Codespace. Source code lives on the codespace
 	os.WriteFile(instructionsPath, []byte(combined), 0o644)
 }
 
-// cleanMirrorDir removes all contents of the mirror directory except .git/,
-// files/ (user-created artifacts), and workspace.json (session manifest),
-// ensuring stale instruction files don't persist across fetches.
+// mirrorPreserved are the mirror entries cleanMirrorDir keeps: .git/, files/
+// (user-created artifacts), workspace.json (session manifest), recordings,
+// and local edits kept by keepLocalMirrorChanges.
+var mirrorPreserved = map[string]bool{
+	".git":            true,
+	"files":           true,
+	"workspace.json":  true,
+	recordingsDirName: true,
+	mirrorLocalDir:    true,
+}
+
+// cleanMirrorDir removes all contents of the mirror directory except
+// mirrorPreserved, ensuring stale instruction files don't persist across
+// fetches.
 func cleanMirrorDir(dir string) {
-	preserve := map[string]bool{
-		".git":           true,
-		"files":          true,
-		"workspace.json": true,
-	}
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return
 	}
 	for _, e := range entries {
-		if preserve[e.Name()] {
+		if mirrorPreserved[e.Name()] {
 			continue
 		}
 		os.RemoveAll(filepath.Join(dir, e.Name()))
 	}
 }
 
-// ensureTrustedFolder adds the directory to copilot's trusted_folders config if not already present.
-func ensureTrustedFolder(dir string) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	configPath := filepath.Join(homeDir, ".copilot", "config.json")
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return err
-	}
-
-	var config map[string]any
-	if err := json.Unmarshal(data, &config); err != nil {
-		return err
-	}
-
-	// Check if already trusted
-	trusted, _ := config["trusted_folders"].([]any)
-	for _, f := range trusted {
-		if s, ok := f.(string); ok && s == dir {
-			return nil // already trusted
-		}
-	}
-
-	// Add to trusted folders
-	trusted = append(trusted, dir)
-	config["trusted_folders"] = trusted
-
-	out, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(configPath, out, 0o644)
-}
-
 func buildMCPConfig(selfBinary, codespaceName, workdir string, remoteMCPServers map[string]any, remoteBinary string) string {
 	servers := map[string]any{
 		"codespace": map[string]any{
@@ -1364,7 +2028,7 @@ func buildMCPConfig(selfBinary, codespaceName, workdir string, remoteMCPServers
 				"CODESPACE_NAME":    codespaceName,
 				"CODESPACE_WORKDIR": workdir,
 			},
-			"tools": []string{"*"},
+			"tools": mcp.ToolNames(mcp.LifecycleConfig{}),
 		},
 	}
 
@@ -1388,28 +2052,29 @@ func buildMCPConfig(selfBinary, codespaceName, workdir string, remoteMCPServers
 	return string(b)
 }
 
+// remoteUserOf returns the user detected for cs, or nil if none was.
+func remoteUserOf(cs *registry.ManagedCodespace) *ssh.RemoteUser {
+	if client, ok := cs.Executor.(*ssh.Client); ok {
+		if u := client.RemoteUser(); u.Name != "" {
+			return &u
+		}
+	}
+	return nil
+}
+
 // buildMCPConfigWithRegistry creates the MCP config JSON using the full registry.
 // Uses CODESPACE_REGISTRY env var (JSON array) for zero-, single-, or multi-codespace support.
 func buildMCPConfigWithRegistry(selfBinary string, reg *registry.Registry, remoteMCPServers map[string]any, lifecycleCfg mcp.LifecycleConfig) string {
-	// Serialize registry entries for the MCP server process
-	var entries []registryEntry
-	for _, cs := range reg.All() {
-		entries = append(entries, registryEntry{
-			Alias:      cs.Alias,
-			Name:       cs.Name,
-			Repository: cs.Repository,
-			Branch:     cs.Branch,
-			Workdir:    cs.Workdir,
-		})
-	}
-	registryJSON, _ := json.Marshal(entries)
-	lifecycleJSON := lifecycleConfigEnvJSON(lifecycleCfg)
+	return buildMCPConfigWithHandshake(selfBinary, "", reg, remoteMCPServers, lifecycleCfg)
+}
 
-	env := map[string]string{
-		"CODESPACE_REGISTRY": string(registryJSON),
-	}
-	if lifecycleJSON != "" {
-		env[codespaceLifecycleConfigEnv] = lifecycleJSON
+// buildMCPConfigWithHandshake is like buildMCPConfigWithRegistry, but when
+// handshakePath is set the MCP server reads the registry and launcher
+// settings from that file (see writeMCPHandshake) instead of the env.
+func buildMCPConfigWithHandshake(selfBinary, handshakePath string, reg *registry.Registry, remoteMCPServers map[string]any, lifecycleCfg mcp.LifecycleConfig) string {
+	env := map[string]string{mcpHandshakeEnv: handshakePath}
+	if handshakePath == "" {
+		env = mcpServerEnv(reg, lifecycleCfg)
 	}
 
 	servers := map[string]any{
@@ -1418,7 +2083,7 @@ func buildMCPConfigWithRegistry(selfBinary string, reg *registry.Registry, remot
 			"command": selfBinary,
 			"args":    []string{"mcp"},
 			"env":     env,
-			"tools":   []string{"*"},
+			"tools":   mcp.ToolNames(lifecycleCfg),
 		},
 	}
 
@@ -1445,20 +2110,112 @@ func buildMCPConfigWithRegistry(selfBinary string, reg *registry.Registry, remot
 	return string(b)
 }
 
+// registryEntries serializes the connected codespaces for the MCP server.
+func registryEntries(reg *registry.Registry) []registryEntry {
+	var entries []registryEntry
+	for _, cs := range reg.All() {
+		entries = append(entries, registryEntry{
+			Alias:       cs.Alias,
+			Name:        cs.Name,
+			Repository:  cs.Repository,
+			Branch:      cs.Branch,
+			Workdir:     cs.Workdir,
+			CPUs:        cs.CPUs,
+			MemoryBytes: cs.MemoryBytes,
+			TimeZone:    cs.TimeZone,
+			UTCOffset:   cs.UTCOffset,
+			ClockOffset: cs.ClockOffset.Milliseconds(),
+			ExecAgent:   cs.ExecAgent,
+			RemoteUser:  remoteUserOf(cs),
+
+			DevcontainerHooks: cs.DevcontainerHooks,
+		})
+	}
+	return entries
+}
+
+// mcpServerEnv passes the registry and launcher settings to the MCP server as
+// environment variables, for sessions without a handshake file.
+func mcpServerEnv(reg *registry.Registry, lifecycleCfg mcp.LifecycleConfig) map[string]string {
+	registryJSON, _ := json.Marshal(registryEntries(reg))
+	env := map[string]string{
+		"CODESPACE_REGISTRY": string(registryJSON),
+	}
+	if lifecycleJSON := lifecycleConfigEnvJSON(lifecycleCfg); lifecycleJSON != "" {
+		env[codespaceLifecycleConfigEnv] = lifecycleJSON
+	}
+	return env
+}
+
+// pathInstructions tells the agent which paths mean what. Copilot runs in the
+// mirror, which holds only instruction files, so a source path taken from the
+// local working directory points at nothing. workdir is empty when several
+// codespaces are connected, each with its own workdir in the table.
+func pathInstructions(mirrorDir, workdir string) string {
+	root := "the codespace's workdir listed above"
+	cwd := "that workdir"
+	if workdir != "" {
+		root = "`" + workdir + "`"
+		cwd = root
+	}
+	return fmt.Sprintf(`## Paths
+
+- Your local working directory, %[1]s, is a mirror of the project's instruction files, skills, and agents only. Project source files are not in it.
+- Pass remote_* tools paths on the codespace: absolute paths under %[2]s, or paths relative to the remote cwd (%[3]s until remote_cd changes it).
+- Don't pass remote paths to local tools. They read and write the mirror, not the codespace.
+- Paths printed by remote commands (compiler errors, stack traces, git output) are codespace paths; use them with remote_* tools as they are.
+
+`, "`"+mirrorDir+"`", root, cwd)
+}
+
 // writeMultiCodespaceInstructionsPreamble writes a preamble listing all connected codespaces.
 func writeMultiCodespaceInstructionsPreamble(mirrorDir string, reg *registry.Registry) {
 	var sb strings.Builder
 	sb.WriteString("# Multi-Codespace Remote Development\n\n")
 	sb.WriteString("You are connected to multiple remote GitHub Codespaces. Source code lives on the codespaces, NOT locally.\n\n")
 	sb.WriteString("## Connected codespaces\n\n")
-	sb.WriteString("| Alias | Repository | Branch | Workdir |\n")
-	sb.WriteString("|-------|-----------|--------|--------|\n")
+	sb.WriteString("| Alias | Repository | Branch | Workdir | Machine | Time zone |\n")
+	sb.WriteString("|-------|-----------|--------|--------|---------|-----------|\n")
 	for _, cs := range reg.All() {
 		branch := cs.Branch
 		if branch == "" {
 			branch = "(default)"
 		}
-		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", cs.Alias, cs.Repository, branch, cs.Workdir))
+		machine := mcp.MachineSummary(cs.CPUs, cs.MemoryBytes)
+		if machine == "" {
+			machine = "unknown"
+		}
+		zone := mcp.ZoneSummary(cs.TimeZone, cs.UTCOffset)
+		if zone == "" {
+			zone = "unknown"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n", cs.Alias, registry.RepositoryLabel(cs.Repository), branch, cs.Workdir, machine, zone))
+	}
+	sb.WriteString("\nSize build and test concurrency to each codespace's cores (e.g. `make -jN`, `go test -p N`); on 2-core machines prefer targeted builds and tests.\n")
+	if local := mcp.LocalZoneSummary(); local != "" {
+		sb.WriteString(fmt.Sprintf("Timestamps printed by remote commands are in that codespace's time zone; the user's local time zone is %s. Convert before comparing with times the user mentions.\n", local))
+	}
+	var git strings.Builder
+	published := false
+	for _, cs := range reg.All() {
+		switch {
+		case cs.DefaultBranch != "" || len(cs.Remotes) > 0:
+			published = true
+			fmt.Fprintf(&git, "- %s: %s", cs.Alias, mcp.GitSummary(cs.Remotes))
+			if cs.DefaultBranch != "" {
+				fmt.Fprintf(&git, " (default branch `%s`)", cs.DefaultBranch)
+			}
+			git.WriteString("\n")
+		case cs.Repository == "":
+			fmt.Fprintf(&git, "- %s: not published to GitHub yet, so `gh pr` and `gh repo` commands fail there\n", cs.Alias)
+		}
+	}
+	if git.Len() > 0 {
+		sb.WriteString("\n## Git repositories\n\n")
+		sb.WriteString(git.String())
+		if published {
+			sb.WriteString("\nOpen pull requests with `gh pr create --base <default branch>` run through `remote_bash` on that codespace.\n")
+		}
 	}
 	sb.WriteString("\n## Tool routing\n\n")
 	sb.WriteString("- **All remote_* tools** accept an optional `codespace` parameter. Use the alias name to target a specific codespace.\n")
@@ -1467,6 +2224,7 @@ func writeMultiCodespaceInstructionsPreamble(mirrorDir string, reg *registry.Reg
 	sb.WriteString("- **Shell commands**: use `remote_bash` with the `codespace` parameter\n")
 	sb.WriteString("- For `remote_bash`, `remote_grep`, and `remote_glob`, pass `cwd` explicitly when you need parallel-safe or targeted execution; `remote_cd` only changes the default cwd for later sequential calls.\n")
 	sb.WriteString("- **Exploring the codebase**: delegate to @remote-explorer instead of the built-in explore agent\n\n")
+	sb.WriteString(pathInstructions(mirrorDir, ""))
 
 	instructionsPath := filepath.Join(mirrorDir, ".github", "copilot-instructions.md")
 	if err := os.MkdirAll(filepath.Dir(instructionsPath), 0o755); err != nil {
@@ -1555,8 +2313,11 @@ func rewriteMCPServerForSSH(server map[string]any, codespaceName, workdir, remot
 
 	// When remote binary is deployed, use structured exec (no shell escaping needed)
 	if remoteBinary != "" {
-		args := []string{"codespace", "ssh", "-c", codespaceName, "--",
-			remoteBinary, "exec", "--workdir", workdir}
+		args := append(ssh.GHSSHArgs(codespaceName), "--",
+			remoteBinary, "exec", "--workdir", workdir)
+		if loginEnvEnabled() {
+			args = append(args, "--login-env")
+		}
 
 		// Add env vars as structured flags
 		if env, ok := server["env"].(map[string]any); ok {
@@ -1607,13 +2368,15 @@ func rewriteMCPServerForSSH(server map[string]any, codespaceName, workdir, remot
 	return map[string]any{
 		"type":    "local",
 		"command": "gh",
-		"args":    []string{"codespace", "ssh", "-c", codespaceName, "--", "bash", "-c", shellQuote(remoteCmd)},
+		"args":    append(ssh.GHSSHArgs(codespaceName), "--", "bash", "-c", shellQuote(remoteCmd)),
 	}
 }
 
 // rewriteHooksForSSH rewrites hook commands in a hooks JSON file to execute
 // on the codespace via SSH. When remoteBinary is available, uses structured
-// exec args. Otherwise falls back to shell assembly.
+// exec args. Otherwise falls back to shell assembly. Handlers given as a
+// "bash" string and as an executable "command" with an "args" array are both
+// rewritten; the latter keep each argument intact.
 func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary string) []byte {
 	var config map[string]any
 	if err := json.Unmarshal(content, &config); err != nil {
@@ -1636,9 +2399,12 @@ func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary str
 			if !ok {
 				continue
 			}
-			bashCmd, ok := h["bash"].(string)
-			if !ok || bashCmd == "" {
-				continue
+			bashCmd, _ := h["bash"].(string)
+			var argv []string
+			if bashCmd == "" {
+				if argv = hookCommandArgv(h); argv == nil {
+					continue
+				}
 			}
 
 			// Build remote command with cd to workdir (+ optional cwd)
@@ -1652,6 +2418,9 @@ func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary str
 				// Double-quote the bash command: once for local shell (which consumes
 				// the hook's bash field), once for the remote shell (SSH).
 				execArgs := remoteBinary + " exec --workdir " + shellQuote(remoteCwd)
+				if loginEnvEnabled() {
+					execArgs += " --login-env"
+				}
 				if env, ok := h["env"].(map[string]any); ok {
 					for k, v := range env {
 						if s, ok := v.(string); ok {
@@ -1659,8 +2428,15 @@ func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary str
 						}
 					}
 				}
-				execArgs += " -- bash -c " + shellQuote(shellQuote(bashCmd))
-				h["bash"] = fmt.Sprintf("gh codespace ssh -c %s -- %s", codespaceName, execArgs)
+				if argv != nil {
+					execArgs += " --"
+					for _, arg := range argv {
+						execArgs += " " + shellQuote(shellQuote(arg))
+					}
+				} else {
+					execArgs += " -- bash -c " + shellQuote(shellQuote(bashCmd))
+				}
+				h["bash"] = fmt.Sprintf("%s -- %s", ssh.GHSSHCommand(codespaceName), execArgs)
 			} else {
 				// Fallback: shell assembly
 				envPrefix := ""
@@ -1671,13 +2447,25 @@ func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary str
 						}
 					}
 				}
+				if argv != nil {
+					quoted := make([]string, len(argv))
+					for j, arg := range argv {
+						quoted[j] = shellQuote(arg)
+					}
+					bashCmd = "exec " + strings.Join(quoted, " ")
+				}
 				remoteCmd := fmt.Sprintf("%s && cd %s && %s%s", codespaceenv.BuildShellBootstrap(), shellQuote(remoteCwd), envPrefix, bashCmd)
-				h["bash"] = fmt.Sprintf("gh codespace ssh -c %s -- bash -c %s", codespaceName, shellQuote(shellQuote(remoteCmd)))
+				h["bash"] = fmt.Sprintf("%s -- bash -c %s", ssh.GHSSHCommand(codespaceName), shellQuote(shellQuote(remoteCmd)))
 			}
 
-			// Clear cwd and env since they're baked into the SSH command
+			// Clear cwd, env, and the executable form since they're baked
+			// into the SSH command
 			delete(h, "cwd")
 			delete(h, "env")
+			if argv != nil {
+				delete(h, "command")
+				delete(h, "args")
+			}
 			handlerList[i] = h
 			modified = true
 		}
@@ -1696,6 +2484,31 @@ func rewriteHooksForSSH(content []byte, codespaceName, workdir, remoteBinary str
 	return out
 }
 
+// hookCommandArgv returns the argv of a hook handler written as an executable
+// "command" with optional "args", or nil if it has none or an argument is
+// not a string.
+func hookCommandArgv(h map[string]any) []string {
+	command, _ := h["command"].(string)
+	if command == "" {
+		return nil
+	}
+	argv := []string{command}
+	if raw, ok := h["args"]; ok {
+		args, ok := raw.([]any)
+		if !ok {
+			return nil
+		}
+		for _, arg := range args {
+			s, ok := arg.(string)
+			if !ok {
+				return nil
+			}
+			argv = append(argv, s)
+		}
+	}
+	return argv
+}
+
 // repoBaseName extracts the repository name from an "owner/repo" string.
 func repoBaseName(repository string) string {
 	if i := strings.LastIndex(repository, "/"); i >= 0 {
@@ -1704,24 +2517,6 @@ func repoBaseName(repository string) string {
 	return repository
 }
 
-// chooseWorkdir picks the best workspace directory from a list given the repo name.
-// Returns the best match or "" if interactive selection is needed.
-func chooseWorkdir(dirs []string, repoName string) string {
-	if len(dirs) == 1 {
-		return dirs[0]
-	}
-	if repoName == "" {
-		return ""
-	}
-	for _, d := range dirs {
-		base := filepath.Base(d)
-		if base == repoName {
-			return d
-		}
-	}
-	return ""
-}
-
 // selectWorkdir lets the user pick a workspace directory interactively.
 func selectWorkdir(dirs []string) (string, error) {
 	if len(dirs) == 0 {
@@ -1764,6 +2559,9 @@ func shellQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\"'\"'") + "'"
 }
 
+// execCopilot replaces the launcher with copilot. The environment is passed
+// through unchanged and no GitHub token is captured up front, so copilot
+// keeps refreshing its own credentials during long sessions.
 func execCopilot(excludedTools []string, mcpConfig string, extraArgs []string) error {
 	copilotArgs := buildCopilotArgs(excludedTools, mcpConfig, extraArgs)
 
@@ -1784,6 +2582,15 @@ func execCopilot(excludedTools []string, mcpConfig string, extraArgs []string) e
 	return syscall.Exec(ghPath, args, os.Environ())
 }
 
+// publishSessionDir records the connected codespaces for the statusline and
+// exports the session directory to Copilot and the commands it runs.
+func publishSessionDir(dir string, reg *registry.Registry) {
+	if err := mcp.WriteConnectionStatus(dir, reg); err != nil {
+		progress.Warn("status_write_failed", nil, "Warning: could not write connection status: %v\n", err)
+	}
+	os.Setenv(sessionDirEnv, dir)
+}
+
 func launcherExcludedTools(localTools bool) []string {
 	if localTools {
 		return nil
@@ -1824,15 +2631,17 @@ func generateBranchSyncHook(mirrorDir, codespaceName, workdir string, sshClient
 	}
 
 	// Build SSH command: prefer multiplexed SSH for speed (~0.1s vs ~3s)
-	sshCmd := fmt.Sprintf("gh codespace ssh -c %s -- git -C %s rev-parse --abbrev-ref HEAD", shellQuote(codespaceName), shellQuote(workdir))
+	sshCmd := fmt.Sprintf("%s -- git -C %s rev-parse --abbrev-ref HEAD", ssh.GHSSHCommand(codespaceName), shellQuote(workdir))
 	if sshClient.SSHConfigPath() != "" {
 		sshCmd = fmt.Sprintf("ssh -F %s %s git -C %s rev-parse --abbrev-ref HEAD",
 			shellQuote(sshClient.SSHConfigPath()), shellQuote(sshClient.SSHHost()), shellQuote(workdir))
 	}
 
-	// Use lenient matching: MCP tools may be namespaced (e.g., mcp__codespace__remote_bash)
+	// Use lenient matching: MCP tools may be namespaced (e.g., mcp__codespace__remote_bash).
+	// A branch the mirror does not have yet starts at the current commit, so a
+	// cloned mirror (see cloneMirror) keeps a consistent index on the new branch.
 	script := fmt.Sprintf(
-		`INPUT=$(cat); echo "$INPUT" | grep -Eq 'remote_(bash|write_bash|read_bash|stop_bash)' || exit 0; branch=$(%s 2>/dev/null); [ -n "$branch" ] && git -C %s symbolic-ref HEAD "refs/heads/$branch" 2>/dev/null; exit 0`,
+		`INPUT=$(cat); echo "$INPUT" | grep -Eq 'remote_(bash|write_bash|read_bash|stop_bash)' || exit 0; branch=$(%s 2>/dev/null); [ -n "$branch" ] && { git -C %[2]s show-ref -q --verify "refs/heads/$branch" || git -C %[2]s update-ref "refs/heads/$branch" HEAD; git -C %[2]s symbolic-ref HEAD "refs/heads/$branch"; } 2>/dev/null; exit 0`,
 		sshCmd, shellQuote(mirrorDir),
 	)
 
@@ -1887,6 +2696,8 @@ Use these remote tools to explore the codespace:
 - **remote_grep** — search for patterns in files (ripgrep)
 - **remote_glob** — find files by name patterns
 - **remote_view** — read file contents with line numbers
+- **remote_view_many** — read several related files in one call
+- **remote_stat** — check whether a path exists, its type, size, mtime, and line count
 - **remote_bash** — run commands (e.g., find, wc, head, git log)
 - **remote_cwd** — check the default working directory used when cwd is omitted
 
@@ -1896,7 +2707,7 @@ Use these remote tools to explore the codespace:
 - Search broadly first, then narrow down
 - Use remote_grep for content search, remote_glob for file discovery
 - Pass cwd explicitly on remote_bash/remote_grep/remote_glob when you need predictable parallel calls instead of relying on remote_cd ordering
-- Read only the relevant portions of files (use view_range)
+- Read only the relevant portions of files (use view_range); read several files at once with remote_view_many
 - When exploring structure, use remote_bash with find or ls
 `
 
@@ -1911,9 +2722,10 @@ func runResume(cfg resumeConfig) error {
 	}
 	resolvedCfg := resolveResumeConfig(ws.Manifest, cfg)
 	ws.Manifest.Settings.LocalTools = resolvedCfg.localTools
+	ws.Manifest.Settings.ReadOnly = resolvedCfg.readOnly
 	ws.Manifest.SetAccessPolicy(resolvedCfg.accessPolicy.SelectedOnly, resolvedCfg.accessPolicy.AllowedCodespaceNames)
 
-	fmt.Printf("Resuming workspace %q...\n", cfg.sessionName)
+	progress.Step("resume_started", progressFields{"session": cfg.sessionName}, "Resuming workspace %q...\n", cfg.sessionName)
 
 	self, err := os.Executable()
 	if err != nil {
@@ -1926,33 +2738,59 @@ func runResume(cfg resumeConfig) error {
 	provisioners := loadProvisioners()
 
 	for alias, entry := range ws.Manifest.Codespaces {
-		fmt.Printf("  Reconnecting %s (%s)...\n", alias, entry.Name)
+		progress.Step("codespace_reconnecting", progressFields{"alias": alias, "codespace": entry.Name}, "  Reconnecting %s (%s)...\n", alias, entry.Name)
 
 		// Check if codespace still exists and start if needed
 		if err := startCodespace(entry.Name); err != nil {
-			fmt.Fprintf(os.Stderr, "  ⚠ Codespace %s unavailable: %v (skipping)\n", alias, err)
+			progress.Warn("codespace_unavailable", progressFields{"alias": alias, "codespace": entry.Name}, "  ⚠ Codespace %s unavailable: %v (skipping)\n", alias, err)
 			continue
 		}
 
+		if others := findActiveSessions(activeSessionDir(), entry.Name); len(others) > 0 {
+			warnSharedCodespace(entry.Name, others)
+		}
+
 		sshClient := ssh.NewClient(entry.Name)
+		sshClient.SetHostKeyPinning(cfg.pinHostKeys)
 		if err := sshClient.SetupMultiplexing(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "  ⚠ SSH failed for %s: %v (skipping)\n", alias, err)
+			progress.Warn("ssh_failed", progressFields{"alias": alias, "codespace": entry.Name}, "  ⚠ SSH failed for %s: %v (skipping)\n", alias, err)
 			continue
 		}
 
+		if checkDevcontainerRebuild(ctx, sshClient, entry.Name, entry.Workdir) {
+			if err := sshClient.SetupMultiplexing(ctx); err != nil {
+				progress.Warn("ssh_failed", progressFields{"alias": alias, "codespace": entry.Name}, "  ⚠ SSH failed for %s after rebuild: %v (skipping)\n", alias, err)
+				continue
+			}
+		}
+
 		sshClient.SetWorkdir(entry.Workdir)
+		probeRemoteUser(ctx, sshClient, entry.Name, entry.Workdir)
+		cpus, memoryBytes := probeMachine(ctx, sshClient, entry.Name)
+		zone, utcOffset, clockOffset := probeClock(ctx, sshClient, entry.Name)
+		remotes, defaultBranch := probeGit(ctx, sshClient, entry.Name, entry.Repository, entry.Workdir)
+		hooks := probeDevcontainerHooks(ctx, sshClient, entry.Name, entry.Workdir)
 		if err := reg.Register(&registry.ManagedCodespace{
-			Alias:      alias,
-			Name:       entry.Name,
-			Repository: entry.Repository,
-			Branch:     entry.Branch,
-			Workdir:    entry.Workdir,
-			Executor:   sshClient,
+			Alias:         alias,
+			Name:          entry.Name,
+			Repository:    entry.Repository,
+			Branch:        entry.Branch,
+			Workdir:       entry.Workdir,
+			Executor:      sshClient,
+			CPUs:          cpus,
+			MemoryBytes:   memoryBytes,
+			TimeZone:      zone,
+			UTCOffset:     utcOffset,
+			ClockOffset:   clockOffset,
+			Remotes:       remotes,
+			DefaultBranch: defaultBranch,
+
+			DevcontainerHooks: hooks,
 		}); err != nil {
 			return fmt.Errorf("registering resumed codespace %q: %w", entry.Name, err)
 		}
 		runProvisioners(ctx, provisioners, entry.Name, entry.Repository, entry.Workdir, sshClient, false)
-		fmt.Printf("  ✓ %s connected\n", alias)
+		progress.Step("codespace_connected", progressFields{"alias": alias, "codespace": entry.Name}, "  ✓ %s connected\n", alias)
 	}
 
 	if hadCodespaces && reg.Len() == 0 {
@@ -1965,13 +2803,24 @@ func runResume(cfg resumeConfig) error {
 	// Re-fetch instructions (branches may have changed)
 	if all := reg.All(); len(all) > 0 {
 		primary := all[0]
-		remoteBinary, _ := deployBinary(primary.Executor.(*ssh.Client), primary.Name)
-		fetchInstructionFiles(primary.Executor.(*ssh.Client), primary.Name, primary.Workdir, remoteBinary)
+		remoteBinary, _ := deployOrReuseBinary(primary.Executor.(*ssh.Client), primary.Name, findActiveSessions(activeSessionDir(), primary.Name))
+		primary.ExecAgent = remoteBinary
+		for _, cs := range reg.All() {
+			execAgent := ""
+			if cs == primary {
+				execAgent = remoteBinary
+			}
+			recordActiveSession(activeSessionDir(), cs.Name, execAgent)
+		}
+		if mirrorDir, _, err := fetchInstructionFiles(primary.Executor.(*ssh.Client), primary.Name, primary.Workdir, remoteBinary, cfg.defaults.FetchPatterns); err == nil {
+			cfg.copilotArgs = applyRepoSettings(mirrorDir, cfg.copilotArgs)
+			recordMirrorManifest(mirrorDir)
+		}
 
 		if reg.Len() > 1 {
 			writeMultiCodespaceInstructionsPreamble(instructionsDir, reg)
 		} else {
-			writeCodespaceInstructionsPreamble(instructionsDir, primary.Workdir)
+			writeCodespaceInstructionsPreamble(instructionsDir, primary)
 		}
 	} else {
 		writeZeroCodespaceInstructionsPreamble(instructionsDir, mcp.CodespaceAccessPolicy{
@@ -1981,39 +2830,47 @@ func runResume(cfg resumeConfig) error {
 	}
 
 	if err := ensureTrustedFolder(instructionsDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not auto-trust directory: %v\n", err)
+		progress.Warn("trust_failed", nil, "Warning: could not auto-trust directory: %v\n", err)
 	}
 
 	generateRemoteExplorerAgent(instructionsDir)
+	checkEnclosingGitRepo(instructionsDir)
 
 	if err := os.Chdir(instructionsDir); err != nil {
 		return fmt.Errorf("changing to workspace dir: %w", err)
 	}
 
 	lifecycleCfg := mcp.LifecycleConfig{
-		AccessPolicy: resolvedCfg.accessPolicy,
+		AccessPolicy:     resolvedCfg.accessPolicy,
+		ReadOnly:         resolvedCfg.readOnly,
+		NoAutoStart:      cfg.noAutoStart,
+		ConfineToWorkdir: cfg.confineToWorkdir.resolve(true),
 		Workspace: mcp.WorkspaceSessionContext{
 			Name: ws.Name,
 			Dir:  ws.Dir,
 		},
 	}
+	applyToolMiddlewareEnv(&lifecycleCfg)
+	if cfg.recordSessions {
+		lifecycleCfg.RecordingDir = filepath.Join(instructionsDir, recordingsDirName)
+	}
+	if all := reg.All(); len(all) > 0 && encryptMirrorEnabled() {
+		if mirrorDir, err := mirrorDirFor(all[0].Name); err == nil {
+			lifecycleCfg.SealMirrorDir = mirrorDir
+		}
+	}
 
 	if err := ws.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not refresh workspace last-used time: %v\n", err)
+		progress.Warn("session_save_failed", nil, "Warning: could not refresh workspace last-used time: %v\n", err)
 	}
 
-	mcpConfig := buildMCPConfigWithRegistry(self, reg, nil, lifecycleCfg)
+	mcpConfig := sessionMCPConfig(self, ws.Dir, reg, nil, lifecycleCfg)
 
-	excludedTools := launcherExcludedTools(resolvedCfg.localTools)
+	excludedTools := cfg.defaults.excludedTools(launcherExcludedTools(resolvedCfg.localTools))
 
-	fmt.Printf("\nResuming with %d codespace(s)...\n", reg.Len())
-	if reg.Len() == 0 {
-		fmt.Printf("  none connected yet\n")
-	}
-	for _, cs := range reg.All() {
-		fmt.Printf("  %s: %s (%s)\n", cs.Alias, cs.Name, cs.Repository)
-	}
-	fmt.Printf("\n")
+	publishSessionDir(ws.Dir, reg)
+
+	reportLaunch(fmt.Sprintf("Resuming with %d codespace(s)...", reg.Len()), reg, excludedTools)
 
 	return execCopilot(excludedTools, mcpConfig, cfg.copilotArgs)
 }
@@ -3,11 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -170,6 +174,146 @@ func containsHelper(s, substr string) bool {
 	return false
 }
 
+func TestParseBatchedOutput(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	output := "4\x00AGENTS.md\x006\x00docs/my notes/CLAUDE.md\x006\x00odd\nname/GEMINI.md\x007\x00broken.md\x003\x00" +
+		"AGENTS.md\x00" + sha256Hex("# Root") + "\x00" + enc("# Root") + "\n\x00" +
+		"docs/my notes/CLAUDE.md\x00" + sha256Hex("spaced") + "\x00" + enc("spaced") + "\x00" +
+		"odd\nname/GEMINI.md\x00\x00" + enc("newline") + "\x00" +
+		"broken.md\x00\x00!!!not-base64\x00"
+
+	got := parseBatchedOutput(output)
+	want := map[string][]byte{
+		"AGENTS.md":               []byte("# Root"),
+		"docs/my notes/CLAUDE.md": []byte("spaced"),
+		"odd\nname/GEMINI.md":     []byte("newline"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseBatchedOutput() = %q, want %q", got, want)
+	}
+}
+
+func TestInstructionFetchScript_PathsWithSpacesAndNewlines(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	wd := filepath.Join(t.TempDir(), "repo dir")
+	files := map[string]string{
+		"AGENTS.md":                                "root",
+		"docs/my notes/AGENTS.md":                  "spaced",
+		"odd\nname/CLAUDE.md":                      "newline",
+		".github/instructions/a b.instructions.md": "instr",
+		".github/skills/my skill/SKILL.md":         "skill",
+		".git/AGENTS.md":                           "ignored",
+	}
+	for rel, content := range files {
+		path := filepath.Join(wd, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("bash", "-c", instructionFetchScript(wd)).Output()
+	if err != nil {
+		t.Fatalf("running fetch script: %v", err)
+	}
+	got := parseBatchedOutput(string(out))
+
+	for rel, content := range files {
+		if strings.HasPrefix(rel, ".git/") {
+			if _, ok := got[rel]; ok {
+				t.Errorf("did not expect %q to be fetched", rel)
+			}
+			continue
+		}
+		if string(got[rel]) != content {
+			t.Errorf("got[%q] = %q, want %q", rel, got[rel], content)
+		}
+	}
+}
+
+func TestMirrorKeepsInstructionPrecedence(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	quietProgress(t)
+	t.Setenv(provenanceEnv, "off")
+	wd := t.TempDir()
+	files := map[string]string{
+		"AGENTS.md":                                            "root",
+		"services/AGENTS.md":                                   "services",
+		"services/api/AGENTS.md":                               "api",
+		"services/api/internal/handler.go":                     "package internal",
+		"deep/a/b/c/d/e/CLAUDE.md":                             "deep",
+		"packages/web/.github/copilot-instructions.md":         "web",
+		"packages/web/.github/instructions/ts.instructions.md": "ts",
+		"node_modules/dep/AGENTS.md":                           "dependency",
+	}
+	for rel, content := range files {
+		path := filepath.Join(wd, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("bash", "-c", instructionFetchScript(wd)).Output()
+	if err != nil {
+		t.Fatalf("running fetch script: %v", err)
+	}
+	mirror := t.TempDir()
+	writeMirrorFiles(mirror, parseBatchedOutput(string(out)), "cs", wd, "", time.Now())
+
+	for _, rel := range []string{"packages/web/.github/copilot-instructions.md", "packages/web/.github/instructions/ts.instructions.md"} {
+		if got, _ := os.ReadFile(filepath.Join(mirror, rel)); string(got) != files[rel] {
+			t.Errorf("mirror %s = %q, want %q", rel, got, files[rel])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "services/api/internal/handler.go")); err == nil {
+		t.Error("source files must not be mirrored")
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "node_modules")); err == nil {
+		t.Error("node_modules must not be mirrored")
+	}
+
+	// The nearest instruction file to each directory is the same file on the
+	// codespace and in the mirror.
+	for dir, want := range map[string]string{
+		"services/api/internal": "services/api/AGENTS.md",
+		"services/web":          "services/AGENTS.md",
+		"docs":                  "AGENTS.md",
+		"deep/a/b/c/d/e/f":      "deep/a/b/c/d/e/CLAUDE.md",
+	} {
+		remote := nearestInstructionFile(t, wd, dir)
+		local := nearestInstructionFile(t, mirror, dir)
+		if remote != want || local != want {
+			t.Errorf("nearest to %s: codespace %q, mirror %q, want %q", dir, remote, local, want)
+		}
+	}
+}
+
+// nearestInstructionFile walks up from dir under root and returns the first
+// AGENTS.md or CLAUDE.md, relative to root.
+func nearestInstructionFile(t *testing.T, root, dir string) string {
+	t.Helper()
+	for {
+		for _, name := range []string{"AGENTS.md", "CLAUDE.md"} {
+			if _, err := os.Stat(filepath.Join(root, dir, name)); err == nil {
+				return filepath.ToSlash(filepath.Join(dir, name))
+			}
+		}
+		if dir == "." {
+			return ""
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
 func TestCleanMirrorDir(t *testing.T) {
 	dir := t.TempDir()
 
@@ -427,65 +571,201 @@ func TestRepoBaseName(t *testing.T) {
 	}
 }
 
-func TestChooseWorkdir(t *testing.T) {
+// runRewrittenHook runs a rewritten hook's bash field with a fake gh that
+// passes its remote command to a local shell, like gh codespace ssh does.
+func runRewrittenHook(t *testing.T, hook map[string]any) string {
+	t.Helper()
+	binDir := t.TempDir()
+	fakeGH := "#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do shift; done; shift\nexec bash -c \"$*\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "gh"), []byte(fakeGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("bash", "-c", hook["bash"].(string))
+	cmd.Env = append(os.Environ(), "PATH="+binDir+":"+os.Getenv("PATH"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running hook: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestRewriteHooksForSSH_CommandArgs(t *testing.T) {
+	workdir := t.TempDir()
+	printArgs := filepath.Join(workdir, "print-args")
+	if err := os.WriteFile(printArgs, []byte("#!/bin/sh\nfor a in \"$@\"; do printf '[%s]\\n' \"$a\"; done\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A stand-in exec agent that honors --workdir and runs the command.
+	agent := filepath.Join(workdir, "agent")
+	if err := os.WriteFile(agent, []byte("#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do [ \"$1\" = --workdir ] && cd \"$2\"; shift; done; shift\nexec \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hooksJSON := `{"version": 1, "hooks": {"preToolUse": [
+		{"type": "command", "command": "./print-args", "args": ["--mode", "it's strict", "$HOME;rm -rf /"], "timeoutSec": 5}
+	]}}`
+	want := "[--mode]\n[it's strict]\n[$HOME;rm -rf /]\n"
+
+	for _, remoteBinary := range []string{agent, ""} {
+		result := rewriteHooksForSSH([]byte(hooksJSON), "my-cs", workdir, remoteBinary)
+		if result == nil {
+			t.Fatalf("remoteBinary=%q: rewriteHooksForSSH returned nil", remoteBinary)
+		}
+		var parsed struct {
+			Hooks map[string][]map[string]any `json:"hooks"`
+		}
+		if err := json.Unmarshal(result, &parsed); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		hook := parsed.Hooks["preToolUse"][0]
+		if _, ok := hook["command"]; ok {
+			t.Errorf("remoteBinary=%q: command should be replaced by bash: %v", remoteBinary, hook)
+		}
+		if _, ok := hook["args"]; ok {
+			t.Errorf("remoteBinary=%q: args should be baked into bash: %v", remoteBinary, hook)
+		}
+		if hook["timeoutSec"] != float64(5) {
+			t.Errorf("remoteBinary=%q: timeoutSec = %v, want it kept", remoteBinary, hook["timeoutSec"])
+		}
+		if remoteBinary != "" && !contains(hook["bash"].(string), agent+" exec --workdir") {
+			t.Errorf("bash should run through the exec agent, got %q", hook["bash"])
+		}
+		if got := runRewrittenHook(t, hook); got != want {
+			t.Errorf("remoteBinary=%q: hook printed %q, want %q", remoteBinary, got, want)
+		}
+	}
+}
+
+func TestRewriteHooksForSSH_SkipsInvalidCommandArgs(t *testing.T) {
+	result := rewriteHooksForSSH([]byte(`{"hooks": {"preToolUse": [{"type": "command", "command": "lint", "args": ["ok", 3]}]}}`), "cs", "/workspaces/repo", "")
+	if result != nil {
+		t.Errorf("expected nil when the only handler has a non-string argument, got %s", result)
+	}
+}
+
+func TestRewriteHooksForSSH_NoHooks(t *testing.T) {
+	result := rewriteHooksForSSH([]byte(`{"version": 1}`), "cs", "/workspaces/repo", "")
+	if result != nil {
+		t.Error("expected nil for config with no hooks")
+	}
+}
+
+func TestRewriteHooksForSSH_InvalidJSON(t *testing.T) {
+	result := rewriteHooksForSSH([]byte(`{invalid`), "cs", "/workspaces/repo", "")
+	if result != nil {
+		t.Error("expected nil for invalid JSON")
+	}
+}
+
+func TestFormatCodespaceChoice(t *testing.T) {
+	lastUsed := time.Date(2026, 3, 4, 12, 30, 0, 0, time.Local)
 	tests := []struct {
-		name     string
-		dirs     []string
-		repoName string
-		want     string
+		name string
+		cs   codespace
+		want string
 	}{
 		{
-			name:     "single dir",
-			dirs:     []string{"/workspaces/github"},
-			repoName: "github",
-			want:     "/workspaces/github",
-		},
-		{
-			name:     "single dir no match needed",
-			dirs:     []string{"/workspaces/other"},
-			repoName: "github",
-			want:     "/workspaces/other",
+			name: "available with machine and last used",
+			cs: codespace{
+				Name: "cs-1", DisplayName: "fuzzy-bear", Repository: "acme/app", State: "Available",
+				MachineDisplayName: "4 cores, 16 GB RAM", LastUsedAt: lastUsed,
+			},
+			want: "cs-1\t🟢 acme/app: fuzzy-bear [Available] · 4 cores, 16 GB RAM · last used 2026-03-04 12:30",
 		},
 		{
-			name:     "multiple dirs with match",
-			dirs:     []string{"/workspaces/github-ui", "/workspaces/github"},
-			repoName: "github",
-			want:     "/workspaces/github",
+			name: "shutdown falls back to machine name",
+			cs:   codespace{Name: "cs-2", DisplayName: "calm-owl", Repository: "acme/app", State: "Shutdown", MachineName: "standardLinux32gb"},
+			want: "cs-2\t⏸️ acme/app: calm-owl [Shutdown, starts on connect, ~1-2 min] · standardLinux32gb",
 		},
 		{
-			name:     "multiple dirs no match",
-			dirs:     []string{"/workspaces/foo", "/workspaces/bar"},
-			repoName: "github",
-			want:     "",
+			name: "starting is distinguished from shutdown",
+			cs:   codespace{Name: "cs-3", DisplayName: "quick-fox", Repository: "acme/app", State: "Starting"},
+			want: "cs-3\t🟡 acme/app: quick-fox [Starting, ready in ~1 min]",
 		},
 		{
-			name:     "empty repo name",
-			dirs:     []string{"/workspaces/foo", "/workspaces/bar"},
-			repoName: "",
-			want:     "",
+			name: "unpublished repository",
+			cs:   codespace{Name: "cs-4", DisplayName: "bold-elk", State: "Available"},
+			want: "cs-4\t🟢 (unpublished): bold-elk [Available]",
 		},
 	}
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got := chooseWorkdir(tc.dirs, tc.repoName)
-			if got != tc.want {
-				t.Errorf("chooseWorkdir(%v, %q) = %q, want %q", tc.dirs, tc.repoName, got, tc.want)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCodespaceChoice(tt.cs); got != tt.want {
+				t.Fatalf("formatCodespaceChoice() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestRewriteHooksForSSH_NoHooks(t *testing.T) {
-	result := rewriteHooksForSSH([]byte(`{"version": 1}`), "cs", "/workspaces/repo", "")
-	if result != nil {
-		t.Error("expected nil for config with no hooks")
+func TestCodespaceAliasSource(t *testing.T) {
+	tests := []struct {
+		cs   codespace
+		want string
+	}{
+		{codespace{Name: "cs-1", DisplayName: "fuzzy-bear", Repository: "acme/app"}, "acme/app"},
+		{codespace{Name: "cs-2", DisplayName: "bold-elk"}, "bold-elk"},
+		{codespace{Name: "cs-3"}, "cs-3"},
+	}
+	for _, tt := range tests {
+		if got := tt.cs.aliasSource(); got != tt.want {
+			t.Errorf("aliasSource(%+v) = %q, want %q", tt.cs, got, tt.want)
+		}
 	}
 }
 
-func TestRewriteHooksForSSH_InvalidJSON(t *testing.T) {
-	result := rewriteHooksForSSH([]byte(`{invalid`), "cs", "/workspaces/repo", "")
-	if result != nil {
-		t.Error("expected nil for invalid JSON")
+func TestWriteMultiCodespaceInstructionsPreambleUnpublished(t *testing.T) {
+	dir := t.TempDir()
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Repository: "acme/app", Remotes: []registry.GitRemote{{Name: "origin", URL: "https://github.com/acme/app"}}})
+	reg.Register(&registry.ManagedCodespace{Alias: "scratch", Name: "cs-scratch"})
+	writeMultiCodespaceInstructionsPreamble(dir, reg)
+	data, err := os.ReadFile(filepath.Join(dir, ".github", "copilot-instructions.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{"| scratch | (unpublished) |", "- scratch: not published to GitHub yet", "- app: origin https://github.com/acme/app", "gh pr create", "absolute paths under the codespace's workdir listed above"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("preamble missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestWriteCodespaceInstructionsPreamblePaths(t *testing.T) {
+	dir := t.TempDir()
+	writeCodespaceInstructionsPreamble(dir, &registry.ManagedCodespace{Alias: "app", Name: "cs-app", Workdir: "/workspaces/app", Repository: "acme/app"})
+	data, err := os.ReadFile(filepath.Join(dir, ".github", "copilot-instructions.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+	for _, want := range []string{
+		"## Paths",
+		"Your local working directory, `" + dir + "`, is a mirror",
+		"absolute paths under `/workspaces/app`, or paths relative to the remote cwd (`/workspaces/app` until remote_cd changes it)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("preamble missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestWarnCodespaceStates(t *testing.T) {
+	var buf bytes.Buffer
+	warnCodespaceStates(newProgressReporter(progressHuman, io.Discard, &buf), []codespace{
+		{Name: "ready", State: "Available"},
+		{Name: "stopped", State: "Shutdown"},
+		{Name: "busy", State: "Rebuilding"},
+		{Name: "broken", State: "Failed"},
+	})
+	out := buf.String()
+	if strings.Contains(out, "ready") || strings.Contains(out, "stopped") {
+		t.Fatalf("unexpected warning for usable codespace: %q", out)
+	}
+	if !strings.Contains(out, "busy is Rebuilding") {
+		t.Fatalf("expected rebuild warning, got %q", out)
+	}
+	if !strings.Contains(out, `broken is in state "Failed"`) {
+		t.Fatalf("expected unknown-state warning, got %q", out)
 	}
 }
 
@@ -551,6 +831,63 @@ func TestParseLauncherArgs(t *testing.T) {
 				copilotArgs:     []string{"--theme", "dark"},
 			},
 		},
+		{
+			name: "parses read-only flag",
+			args: []string{"--read-only", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames: []string{"cs-1"},
+				readOnly:       setBoolFlag(true),
+			},
+		},
+		{
+			name: "parses output mode flags",
+			args: []string{"--quiet", "--json-status", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames: []string{"cs-1"},
+				quiet:          true,
+				jsonStatus:     true,
+			},
+		},
+		{
+			name: "parses host key pinning flag",
+			args: []string{"--pin-host-keys", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames: []string{"cs-1"},
+				pinHostKeys:    true,
+			},
+		},
+		{
+			name: "parses trust MCP servers flag",
+			args: []string{"--trust-mcp-servers", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames:  []string{"cs-1"},
+				trustMCPServers: true,
+			},
+		},
+		{
+			name: "parses no auto-start flag",
+			args: []string{"--no-auto-start", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames: []string{"cs-1"},
+				noAutoStart:    true,
+			},
+		},
+		{
+			name: "parses confine to workdir opt-out",
+			args: []string{"--confine-to-workdir=false", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames:   []string{"cs-1"},
+				confineToWorkdir: optionalBool{set: true, value: false},
+			},
+		},
+		{
+			name: "parses record sessions flag",
+			args: []string{"--record-sessions", "-c", "cs-1"},
+			want: launcherOptions{
+				codespaceNames: []string{"cs-1"},
+				recordSessions: true,
+			},
+		},
 		{
 			name: "repeated codespace flags append selections",
 			args: []string{"-c", "cs-1", "--codespace", "cs-2,cs-3"},
@@ -712,6 +1049,21 @@ func TestResolveResumeConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "uses persisted read-only setting",
+			cfg:  resumeConfig{sessionName: "saved-session"},
+			ws: &workspace.Manifest{
+				Settings: workspace.SessionSettings{
+					ReadOnly: true,
+				},
+			},
+			want: resolvedResumeConfig{
+				readOnly: true,
+				accessPolicy: mcp.CodespaceAccessPolicy{
+					AllowedCodespaceNames: []string{},
+				},
+			},
+		},
 		{
 			name: "explicit false overrides persisted true",
 			cfg: resumeConfig{
@@ -1075,12 +1427,10 @@ func mustParseTime(t *testing.T, value string) time.Time {
 func TestResolveSelectedCodespaces(t *testing.T) {
 	alpha := codespace{Name: "alpha", DisplayName: "Alpha", Repository: "owner/alpha", State: "Available"}
 	beta := codespace{Name: "beta", DisplayName: "Beta", Repository: "owner/beta", State: "Available"}
-
-	alphaChoice := "alpha\t🟢 owner/alpha: Alpha [Available]"
-	betaChoice := "beta\t🟢 owner/beta: Beta [Available]"
-	byChoice := map[string]codespace{
-		alphaChoice: alpha,
-		betaChoice:  beta,
+	codespaces := []codespace{alpha, beta}
+	choices := numberedChoices([]string{formatCodespaceChoice(alpha), formatCodespaceChoice(beta)})
+	if choices[0] != "1) alpha\t🟢 owner/alpha: Alpha [Available]" {
+		t.Fatalf("numberedChoices()[0] = %q", choices[0])
 	}
 
 	tests := []struct {
@@ -1089,13 +1439,13 @@ func TestResolveSelectedCodespaces(t *testing.T) {
 		want     []codespace
 	}{
 		{name: "blank means none", selected: []string{""}, want: []codespace{}},
-		{name: "resolves known choices", selected: []string{alphaChoice, betaChoice}, want: []codespace{alpha, beta}},
-		{name: "ignores unknown and duplicates", selected: []string{alphaChoice, "missing", alphaChoice}, want: []codespace{alpha}},
+		{name: "resolves known choices", selected: []string{choices[0], choices[1]}, want: []codespace{alpha, beta}},
+		{name: "ignores unknown and duplicates", selected: []string{choices[0], "missing", "3) gamma", choices[0]}, want: []codespace{alpha}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := resolveSelectedCodespaces(tt.selected, byChoice)
+			got := resolveSelectedCodespaces(tt.selected, codespaces)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("got %v, want %v", got, tt.want)
 			}
@@ -1103,6 +1453,25 @@ func TestResolveSelectedCodespaces(t *testing.T) {
 	}
 }
 
+func TestResolveSelectedCodespacesWithIdenticalLines(t *testing.T) {
+	// Three codespaces whose picker lines read the same apart from the number.
+	codespaces := []codespace{
+		{Name: "cs-1", DisplayName: "main", Repository: "owner/app", State: "Available"},
+		{Name: "cs-2", DisplayName: "main", Repository: "owner/app", State: "Available"},
+		{Name: "cs-3", DisplayName: "main", Repository: "owner/app", State: "Available"},
+	}
+	lines := make([]string, len(codespaces))
+	for i := range codespaces {
+		lines[i] = "🟢 owner/app: main [Available]"
+	}
+	choices := numberedChoices(lines)
+
+	got := resolveSelectedCodespaces([]string{choices[2], choices[1]}, codespaces)
+	if len(got) != 2 || got[0].Name != "cs-3" || got[1].Name != "cs-2" {
+		t.Fatalf("got %v, want cs-3 and cs-2", got)
+	}
+}
+
 func TestSelectedCodespaceNames(t *testing.T) {
 	selected := []codespace{
 		{Name: "cs-1"},
@@ -1124,6 +1493,7 @@ func TestBuildMCPConfigWithRegistry(t *testing.T) {
 		Repository: "github/github",
 		Branch:     "main",
 		Workdir:    "/workspaces/github",
+		ExecAgent:  "/tmp/gh-copilot-codespace-bin/gh-copilot-codespace",
 	})
 
 	result := buildMCPConfigWithRegistry("/usr/local/bin/self", reg, nil, mcp.LifecycleConfig{})
@@ -1160,6 +1530,53 @@ func TestBuildMCPConfigWithRegistry(t *testing.T) {
 	if entries[0].Alias != "github" {
 		t.Errorf("alias = %q, want %q", entries[0].Alias, "github")
 	}
+	if entries[0].ExecAgent != "/tmp/gh-copilot-codespace-bin/gh-copilot-codespace" {
+		t.Errorf("execAgent = %q, want the deployed agent path", entries[0].ExecAgent)
+	}
+	if entries[0].RemoteUser != nil {
+		t.Errorf("remoteUser = %+v, want nil for a non-SSH executor", entries[0].RemoteUser)
+	}
+}
+
+func TestBuildMCPConfigWithRegistryPassesRemoteUser(t *testing.T) {
+	client := ssh.NewClient("cs-abc")
+	client.SetRemoteUser(ssh.RemoteUser{Name: "root", Home: "/root", WorkspaceOwner: "vscode", WorkspaceOwnerHome: "/home/vscode"})
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "github", Name: "cs-abc", Executor: client})
+
+	var parsed struct {
+		MCPServers map[string]struct {
+			Env map[string]string `json:"env"`
+		} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal([]byte(buildMCPConfigWithRegistry("/usr/local/bin/self", reg, nil, mcp.LifecycleConfig{})), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var entries []registryEntry
+	if err := json.Unmarshal([]byte(parsed.MCPServers["codespace"].Env["CODESPACE_REGISTRY"]), &entries); err != nil {
+		t.Fatalf("invalid CODESPACE_REGISTRY JSON: %v", err)
+	}
+	if len(entries) != 1 || entries[0].RemoteUser == nil || *entries[0].RemoteUser != client.RemoteUser() {
+		t.Fatalf("entries = %+v, want the detected remote user", entries)
+	}
+}
+
+func TestRegistryEntriesPassDevcontainerHooks(t *testing.T) {
+	hooks := []registry.DevcontainerHook{{Property: "postCreateCommand", Commands: []registry.DevcontainerCommand{{Shell: "npm ci"}}}}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "github", Name: "cs-abc", DevcontainerHooks: hooks})
+
+	data, err := json.Marshal(registryEntries(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []registryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].DevcontainerHooks, hooks) {
+		t.Fatalf("entries = %+v, want the devcontainer hooks", entries)
+	}
 }
 
 func TestBuildMCPConfigWithRegistry_EmptyRegistry(t *testing.T) {
@@ -1387,3 +1804,100 @@ func TestRegistryFromEntries_DuplicateCodespaceName(t *testing.T) {
 		t.Fatalf("expected existing alias in error, got %q", err)
 	}
 }
+
+func TestBuildMCPConfigWithRegistry_ToolAllowlistFollowsReadOnly(t *testing.T) {
+	toolsFor := func(cfg mcp.LifecycleConfig) []string {
+		result := buildMCPConfigWithRegistry("/usr/local/bin/self", registry.New(), nil, cfg)
+		var parsed struct {
+			MCPServers map[string]struct {
+				Tools []string `json:"tools"`
+			} `json:"mcpServers"`
+		}
+		if err := json.Unmarshal([]byte(result), &parsed); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return parsed.MCPServers["codespace"].Tools
+	}
+
+	full := toolsFor(mcp.LifecycleConfig{})
+	readOnly := toolsFor(mcp.LifecycleConfig{ReadOnly: true})
+
+	if slices.Contains(full, "*") || slices.Contains(readOnly, "*") {
+		t.Fatalf("tools should be an explicit allowlist, got %v / %v", full, readOnly)
+	}
+	if !slices.Contains(full, "remote_edit") || !slices.Contains(full, "create_codespace") {
+		t.Fatalf("full tool list missing mutating tools: %v", full)
+	}
+	for _, name := range []string{"remote_edit", "remote_create", "delete_codespace"} {
+		if slices.Contains(readOnly, name) {
+			t.Errorf("read-only tool list includes %q", name)
+		}
+	}
+	if !slices.Contains(readOnly, "remote_view") {
+		t.Errorf("read-only tool list missing remote_view: %v", readOnly)
+	}
+
+	cfg, err := lifecycleConfigFromEnv(lifecycleConfigEnvJSON(mcp.LifecycleConfig{ReadOnly: true}))
+	if err != nil {
+		t.Fatalf("parse lifecycle config env: %v", err)
+	}
+	if !cfg.ReadOnly {
+		t.Fatal("expected read-only to round-trip through lifecycle config env")
+	}
+}
+
+func TestLifecycleConfigEnvNoAutoStart(t *testing.T) {
+	if got := lifecycleConfigEnvJSON(mcp.LifecycleConfig{}); got != "" {
+		t.Fatalf("default config env = %q, want empty", got)
+	}
+	cfg, err := lifecycleConfigFromEnv(lifecycleConfigEnvJSON(mcp.LifecycleConfig{NoAutoStart: true}))
+	if err != nil {
+		t.Fatalf("parse lifecycle config env: %v", err)
+	}
+	if !cfg.NoAutoStart {
+		t.Fatal("expected no-auto-start to round-trip through lifecycle config env")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{"500000": 500000, "200K": 200 << 10, "50MB": 50 << 20, "1GiB": 1 << 30, " 2 g ": 2 << 30, "0": 0} {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "lots", "10TB", "-5M", "1.5G"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded", in)
+		}
+	}
+}
+
+func TestApplyToolMiddlewareEnv(t *testing.T) {
+	t.Setenv(logToolsEnv, "1")
+	t.Setenv(denyToolsEnv, "remote_chmod, remote_ln,,")
+	t.Setenv(remoteTaskEnv, "true")
+	t.Setenv(confirmDiscardEnv, "1")
+	t.Setenv(writeQuotaEnv, "50MB")
+	var cfg mcp.LifecycleConfig
+	applyToolMiddlewareEnv(&cfg)
+	if cfg.ToolLog == nil || !reflect.DeepEqual(cfg.DeniedTools, []string{"remote_chmod", "remote_ln"}) || !cfg.RemoteTask || !cfg.ConfirmDiscard || cfg.WriteQuota != 50<<20 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	parsed, err := lifecycleConfigFromEnv(lifecycleConfigEnvJSON(cfg))
+	if err != nil {
+		t.Fatalf("parse lifecycle config env: %v", err)
+	}
+	if parsed.ToolLog == nil || !reflect.DeepEqual(parsed.DeniedTools, cfg.DeniedTools) || !parsed.RemoteTask || !parsed.ConfirmDiscard || parsed.WriteQuota != cfg.WriteQuota {
+		t.Fatalf("parsed = %+v", parsed)
+	}
+
+	t.Setenv(logToolsEnv, "")
+	t.Setenv(denyToolsEnv, "")
+	t.Setenv(remoteTaskEnv, "")
+	t.Setenv(confirmDiscardEnv, "")
+	cfg = mcp.LifecycleConfig{}
+	applyToolMiddlewareEnv(&cfg)
+	if cfg.ToolLog != nil || cfg.DeniedTools != nil || cfg.RemoteTask || cfg.ConfirmDiscard {
+		t.Fatalf("unset env: cfg = %+v", cfg)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxMCPServerEnvVars bounds the env vars one forwarded server may set.
+	maxMCPServerEnvVars = 64
+	// maxMCPServerEnvValueBytes bounds a single env value.
+	maxMCPServerEnvValueBytes = 4 << 10
+	// maxMCPServerEnvBytes bounds all env names and values of one server.
+	maxMCPServerEnvBytes = 32 << 10
+)
+
+// mcpShellMetacharacters are rejected in structured mode. The exec agent runs
+// the command without a shell, but ssh joins its arguments into one string
+// for the remote login shell first, so these would still be interpreted.
+const mcpShellMetacharacters = "|&;<>()$`\\\"'\n\r"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// remoteMCPServer is a validated server from the codespace's mcp-config.json.
+type remoteMCPServer struct {
+	name    string
+	command string
+	args    []string
+	env     map[string]string
+}
+
+// parseRemoteMCPServer validates one server config. Structured mode (an exec
+// agent is deployed) also rejects shell metacharacters.
+func parseRemoteMCPServer(name string, raw any, structured bool) (remoteMCPServer, error) {
+	server, ok := raw.(map[string]any)
+	if !ok {
+		return remoteMCPServer{}, fmt.Errorf("config is not an object")
+	}
+	s := remoteMCPServer{name: name, env: map[string]string{}}
+	s.command, _ = server["command"].(string)
+	if s.command == "" {
+		return remoteMCPServer{}, fmt.Errorf("missing command")
+	}
+	if rawArgs, ok := server["args"]; ok {
+		list, ok := rawArgs.([]any)
+		if !ok {
+			return remoteMCPServer{}, fmt.Errorf("args must be an array of strings")
+		}
+		for i, a := range list {
+			str, ok := a.(string)
+			if !ok {
+				return remoteMCPServer{}, fmt.Errorf("args[%d] is not a string", i)
+			}
+			s.args = append(s.args, str)
+		}
+	}
+	if rawEnv, ok := server["env"]; ok {
+		env, ok := rawEnv.(map[string]any)
+		if !ok {
+			return remoteMCPServer{}, fmt.Errorf("env must be an object of strings")
+		}
+		if len(env) > maxMCPServerEnvVars {
+			return remoteMCPServer{}, fmt.Errorf("sets %d env vars (max %d)", len(env), maxMCPServerEnvVars)
+		}
+		total := 0
+		for k, v := range env {
+			str, ok := v.(string)
+			if !ok {
+				return remoteMCPServer{}, fmt.Errorf("env %s is not a string", k)
+			}
+			if !envNamePattern.MatchString(k) {
+				return remoteMCPServer{}, fmt.Errorf("invalid env var name %q", k)
+			}
+			if len(str) > maxMCPServerEnvValueBytes {
+				return remoteMCPServer{}, fmt.Errorf("env %s is %d bytes (max %d)", k, len(str), maxMCPServerEnvValueBytes)
+			}
+			total += len(k) + len(str)
+			s.env[k] = str
+		}
+		if total > maxMCPServerEnvBytes {
+			return remoteMCPServer{}, fmt.Errorf("env is %d bytes (max %d)", total, maxMCPServerEnvBytes)
+		}
+	}
+
+	if structured {
+		fields := append([]string{s.command}, s.args...)
+		for _, v := range s.env {
+			fields = append(fields, v)
+		}
+		for _, f := range fields {
+			if i := strings.IndexAny(f, mcpShellMetacharacters); i >= 0 {
+				return remoteMCPServer{}, fmt.Errorf("shell metacharacter %q in %q", f[i], f)
+			}
+		}
+	}
+	return s, nil
+}
+
+// commandLine renders what the server will run on the codespace.
+func (s remoteMCPServer) commandLine() string {
+	parts := make([]string, 0, len(s.env)+len(s.args)+1)
+	for _, k := range sortedKeys(s.env) {
+		parts = append(parts, k+"="+displayArg(s.env[k]))
+	}
+	parts = append(parts, displayArg(s.command))
+	for _, a := range s.args {
+		parts = append(parts, displayArg(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// displayArg quotes an argument only when it needs it, so the usual command
+// line reads naturally.
+func displayArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, mcpShellMetacharacters+" \t*?[]{}~#!") {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// missingRemoteCommands returns the commands that do not resolve on the
+// codespace from workdir, checked in a single round trip.
+func missingRemoteCommands(ctx context.Context, runner remoteRunner, workdir string, commands []string) (map[string]bool, error) {
+	quoted := make([]string, len(commands))
+	for i, c := range commands {
+		quoted[i] = shellQuote(c)
+	}
+	script := fmt.Sprintf(`cd %s 2>/dev/null; for c in %s; do command -v -- "$c" >/dev/null 2>&1 && echo ok || echo missing; done`,
+		shellQuote(workdir), strings.Join(quoted, " "))
+	stdout, stderr, exitCode, err := runner.Exec(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("exit code %d: %s", exitCode, strings.TrimSpace(stderr))
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != len(commands) {
+		return nil, fmt.Errorf("unexpected command check output %q", stdout)
+	}
+	missing := make(map[string]bool)
+	for i, c := range commands {
+		if lines[i] != "ok" {
+			missing[c] = true
+		}
+	}
+	return missing, nil
+}
+
+// mcpServerConfirmer asks whether to forward one server.
+type mcpServerConfirmer func(s remoteMCPServer, workdir string) bool
+
+// promptMCPServer returns a confirmer that shows each server's exact command
+// line and reads a y/N answer.
+func promptMCPServer(in io.Reader, out io.Writer) mcpServerConfirmer {
+	reader := bufio.NewReader(in)
+	return func(s remoteMCPServer, workdir string) bool {
+		fmt.Fprintf(out, "\nThe codespace's mcp-config.json declares MCP server %q. It will run on the codespace:\n", s.name)
+		fmt.Fprintf(out, "  cd %s\n  %s\n", displayArg(workdir), s.commandLine())
+		return promptYesNo(reader, out, fmt.Sprintf("Forward MCP server %q?", s.name))
+	}
+}
+
+// vetRemoteMCPServers filters the codespace's MCP servers before they are
+// forwarded: configs that fail validation or whose command does not exist on
+// the codespace are dropped, and each remaining server needs confirm's
+// approval. A nil confirm approves every valid server (--trust-mcp-servers).
+func vetRemoteMCPServers(ctx context.Context, runner remoteRunner, workdir string, structured bool, servers map[string]any, confirm mcpServerConfirmer) map[string]any {
+	if len(servers) == 0 {
+		return servers
+	}
+
+	var valid []remoteMCPServer
+	for _, name := range sortedKeys(servers) {
+		if name == "codespace" {
+			continue
+		}
+		s, err := parseRemoteMCPServer(name, servers[name], structured)
+		if err != nil {
+			progress.Warn("mcp_server_rejected", progressFields{"server": name, "reason": err.Error()}, "  ⚠ Skipping MCP server %q: %v\n", name, err)
+			continue
+		}
+		valid = append(valid, s)
+	}
+
+	if len(valid) > 0 && runner != nil {
+		seen := make(map[string]bool)
+		var commands []string
+		for _, s := range valid {
+			if !seen[s.command] {
+				seen[s.command] = true
+				commands = append(commands, s.command)
+			}
+		}
+		missing, err := missingRemoteCommands(ctx, runner, workdir, commands)
+		if err != nil {
+			progress.Warn("mcp_server_check_failed", nil, "  ⚠ Could not check MCP server commands on the codespace: %v\n", err)
+		}
+		kept := valid[:0]
+		for _, s := range valid {
+			if missing[s.command] {
+				progress.Warn("mcp_server_rejected", progressFields{"server": s.name, "reason": "command not found"}, "  ⚠ Skipping MCP server %q: command %q not found on the codespace\n", s.name, s.command)
+				continue
+			}
+			kept = append(kept, s)
+		}
+		valid = kept
+	}
+
+	approved := make(map[string]any)
+	for _, s := range valid {
+		if confirm != nil && !confirm(s, workdir) {
+			progress.Step("mcp_server_declined", progressFields{"server": s.name}, "  Not forwarding MCP server %q\n", s.name)
+			continue
+		}
+		approved[s.name] = servers[s.name]
+		progress.Step("mcp_server_forwarded", progressFields{"server": s.name, "command": s.commandLine()}, "  ✓ Forwarding MCP server %q: %s\n", s.name, s.commandLine())
+	}
+	return approved
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// launcherMCPServerConfirmer picks how the launcher approves servers: all of
+// them with --trust-mcp-servers, a prompt on a terminal, and none otherwise,
+// since nobody can see what would run.
+func launcherMCPServerConfirmer(trust bool) mcpServerConfirmer {
+	switch {
+	case trust:
+		return nil
+	case isInteractiveTerminal():
+		return promptMCPServer(os.Stdin, os.Stderr)
+	}
+	return func(s remoteMCPServer, workdir string) bool {
+		progress.Warn("mcp_server_unconfirmed", progressFields{"server": s.name, "command": s.commandLine()},
+			"  ⚠ Not forwarding MCP server %q (%s) without confirmation; pass --trust-mcp-servers to allow it\n", s.name, s.commandLine())
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mirrorFailureThresholdEnv sets the fraction of mirrored files (0 to 1) that
+// may fail to write before the fetch itself fails. Below it, failures are
+// listed as warnings and the session starts with an incomplete mirror.
+const mirrorFailureThresholdEnv = "COPILOT_CODESPACE_MIRROR_FAILURE_THRESHOLD"
+
+const defaultMirrorFailureThreshold = 0.5
+
+// mirrorWriteFailure is a fetched file that could not be written to the mirror.
+type mirrorWriteFailure struct {
+	path string
+	err  error
+}
+
+// mirrorFailureThresholdFromEnv reads mirrorFailureThresholdEnv, warning
+// about values that are not a number between 0 and 1.
+func mirrorFailureThresholdFromEnv() float64 {
+	v := strings.TrimSpace(os.Getenv(mirrorFailureThresholdEnv))
+	if v == "" {
+		return defaultMirrorFailureThreshold
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		progress.Warn("mirror_failure_threshold_invalid", progressFields{"value": v},
+			"Warning: ignoring %s=%q (use a fraction between 0 and 1)\n", mirrorFailureThresholdEnv, v)
+		return defaultMirrorFailureThreshold
+	}
+	return threshold
+}
+
+// reportMirrorFailures lists the files that could not be written out of
+// attempted, and returns an error when they reach threshold as a fraction of
+// attempted. A threshold of 0 fails on any write error.
+func reportMirrorFailures(failures []mirrorWriteFailure, attempted int, threshold float64) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].path < failures[j].path })
+	progress.Warn("mirror_incomplete", progressFields{"failed": len(failures), "files": attempted},
+		"  ⚠ %d of %d files could not be written to the mirror:\n", len(failures), attempted)
+	for _, f := range failures {
+		progress.Warn("mirror_write_failed", progressFields{"path": f.path, "error": f.err.Error()},
+			"      %s: %v\n", f.path, f.err)
+	}
+	if float64(len(failures)) >= threshold*float64(attempted) {
+		return fmt.Errorf("%d of %d files could not be written to the mirror (first: %s: %w); set %s to tolerate more",
+			len(failures), attempted, failures[0].path, failures[0].err, mirrorFailureThresholdEnv)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMirrorFilesCollectsFailures(t *testing.T) {
+	quietProgress(t)
+	mirror := t.TempDir()
+	// A file where a directory should be makes every write below it fail.
+	os.WriteFile(filepath.Join(mirror, "docs"), []byte("in the way"), 0o644)
+
+	files := map[string][]byte{
+		"AGENTS.md":                 []byte("root"),
+		"docs/AGENTS.md":            []byte("docs"),
+		"docs/api/CLAUDE.md":        []byte("api"),
+		".copilot/mcp-config.json":  []byte(`{"mcpServers":{}}`),
+		".claude/commands/build.md": []byte("build"),
+	}
+	_, attempted, failures := writeMirrorFiles(mirror, files, "cs", "/workspaces/app", "", time.Now())
+	if attempted != 4 {
+		t.Errorf("attempted = %d, want 4 (MCP configs are parsed, not written)", attempted)
+	}
+	var failed []string
+	for _, f := range failures {
+		failed = append(failed, f.path)
+	}
+	if len(failed) != 2 || !strings.Contains(strings.Join(failed, ","), "docs/AGENTS.md") || !strings.Contains(strings.Join(failed, ","), "docs/api/CLAUDE.md") {
+		t.Errorf("failures = %v", failed)
+	}
+	if _, err := os.Stat(filepath.Join(mirror, ".claude", "commands", "build.md")); err != nil {
+		t.Errorf("other files should still be written: %v", err)
+	}
+}
+
+func TestReportMirrorFailures(t *testing.T) {
+	quietProgress(t)
+	failures := []mirrorWriteFailure{
+		{path: "b.md", err: errors.New("disk full")},
+		{path: "a.md", err: os.ErrPermission},
+	}
+	if err := reportMirrorFailures(nil, 10, 0); err != nil {
+		t.Errorf("no failures: %v", err)
+	}
+	if err := reportMirrorFailures(failures, 10, 0.5); err != nil {
+		t.Errorf("2 of 10 below 0.5: %v", err)
+	}
+	err := reportMirrorFailures(failures, 4, 0.5)
+	if err == nil || !strings.Contains(err.Error(), "2 of 4 files") || !strings.Contains(err.Error(), "a.md") || !errors.Is(err, os.ErrPermission) {
+		t.Errorf("2 of 4 at 0.5 = %v", err)
+	}
+	if err := reportMirrorFailures(failures[:1], 100, 0); err == nil {
+		t.Error("threshold 0 should fail on any write error")
+	}
+}
+
+func TestMirrorFailureThresholdFromEnv(t *testing.T) {
+	quietProgress(t)
+	for value, want := range map[string]float64{"": defaultMirrorFailureThreshold, "0.1": 0.1, "1": 1, "2": defaultMirrorFailureThreshold, "half": defaultMirrorFailureThreshold} {
+		t.Setenv(mirrorFailureThresholdEnv, value)
+		if got := mirrorFailureThresholdFromEnv(); got != want {
+			t.Errorf("%s=%q: got %v, want %v", mirrorFailureThresholdEnv, value, got, want)
+		}
+	}
+}
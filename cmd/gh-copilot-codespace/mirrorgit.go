@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mirrorGitDirEnv pins git in the session to the mirror's own repository
+// when the mirror is inside another git repository.
+const mirrorGitDirEnv = "COPILOT_CODESPACE_MIRROR_GIT_DIR"
+
+// mirrorCloneEnv makes the mirror a shallow, blobless clone of the
+// codespace's repository instead of an empty repository, so git log, blame,
+// and diffs against upstream give real answers in the session.
+const mirrorCloneEnv = "COPILOT_CODESPACE_MIRROR_CLONE"
+
+// mirrorCloneDepthEnv overrides how many commits of history the clone fetches.
+const mirrorCloneDepthEnv = "COPILOT_CODESPACE_MIRROR_CLONE_DEPTH"
+
+const defaultMirrorCloneDepth = 50
+
+// mirrorCloneEnabled reports whether mirrorCloneEnv is set to true.
+func mirrorCloneEnabled() bool {
+	v, err := strconv.ParseBool(os.Getenv(mirrorCloneEnv))
+	return err == nil && v
+}
+
+// mirrorCloneDepth returns the depth from mirrorCloneDepthEnv, or the default
+// when it is unset or not a positive integer.
+func mirrorCloneDepth() int {
+	if n, err := strconv.Atoi(os.Getenv(mirrorCloneDepthEnv)); err == nil && n > 0 {
+		return n
+	}
+	return defaultMirrorCloneDepth
+}
+
+// mirrorRemoteURL returns the HTTPS URL of repository ("owner/name" as gh
+// reports it) on GH_HOST or github.com, or "" for a codespace without one.
+func mirrorRemoteURL(repository string) string {
+	owner, name, ok := strings.Cut(repository, "/")
+	if !ok || owner == "" || name == "" || strings.ContainsAny(repository, " ()") {
+		return ""
+	}
+	host := os.Getenv("GH_HOST")
+	if host == "" {
+		host = "github.com"
+	}
+	return "https://" + host + "/" + repository + ".git"
+}
+
+// cloneMirror turns dir, an initialized repository holding the mirrored
+// files, into a shallow, blobless clone of branch at url without checking
+// anything out. The index is read from the fetched commit and paths missing
+// from the mirror are marked skip-worktree, so git status only reports the
+// mirrored files that differ from the commit and the files the launcher
+// generates. Running it again on the next launch fetches the branch afresh.
+func cloneMirror(dir, url, branch string, depth int) error {
+	git := func(stdin []byte, args ...string) ([]byte, error) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if stdin != nil {
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+
+	remoteRef := "refs/remotes/origin/" + branch
+	for _, args := range [][]string{
+		{"config", "remote.origin.url", url},
+		{"config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"},
+		{"fetch", "-q", "--depth=" + strconv.Itoa(depth), "--filter=blob:none", "origin", "+refs/heads/" + branch + ":" + remoteRef},
+		{"update-ref", "refs/heads/" + branch, remoteRef},
+		{"symbolic-ref", "HEAD", "refs/heads/" + branch},
+		{"config", "branch." + branch + ".remote", "origin"},
+		{"config", "branch." + branch + ".merge", "refs/heads/" + branch},
+		{"read-tree", "HEAD"},
+	} {
+		if _, err := git(nil, args...); err != nil {
+			return err
+		}
+	}
+
+	tracked, err := git(nil, "ls-files", "-z")
+	if err != nil {
+		return err
+	}
+	var missing bytes.Buffer
+	for _, p := range bytes.Split(tracked, []byte{0}) {
+		if len(p) == 0 {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, string(p))); err != nil {
+			missing.Write(p)
+			missing.WriteByte(0)
+		}
+	}
+	if missing.Len() > 0 {
+		if _, err := git(missing.Bytes(), "update-index", "-z", "--skip-worktree", "--stdin"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupMirrorClone runs cloneMirror for the primary codespace when
+// mirrorCloneEnv is set. A failed fetch leaves the empty repository in place.
+func setupMirrorClone(dir, repository, branch string) {
+	if !mirrorCloneEnabled() {
+		return
+	}
+	url := mirrorRemoteURL(repository)
+	fields := progressFields{"dir": dir, "repository": repository, "branch": branch}
+	if url == "" || branch == "" {
+		progress.Warn("mirror_clone_skipped", fields, "Warning: %s is set, but the codespace has no repository or branch to clone; the mirror stays an empty repository\n", mirrorCloneEnv)
+		return
+	}
+	start := time.Now()
+	if err := cloneMirror(dir, url, branch, mirrorCloneDepth()); err != nil {
+		progress.Warn("mirror_clone_failed", fields, "Warning: could not clone %s into the mirror: %v\n", repository, err)
+		return
+	}
+	elapsed := time.Since(start)
+	fields["durationMs"] = elapsed.Milliseconds()
+	progress.Step("mirror_cloned", fields, "  Mirror cloned from %s@%s (last %d commits, no file contents) in %s\n",
+		repository, branch, mirrorCloneDepth(), elapsed.Round(100*time.Millisecond))
+}
+
+// enclosingGitRepo returns the closest directory above dir that holds a .git
+// directory or file, or "" if there is none. dir's own .git does not count.
+func enclosingGitRepo(dir string) string {
+	d := filepath.Clean(dir)
+	for {
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ""
+		}
+		d = parent
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+	}
+}
+
+// checkEnclosingGitRepo warns when dir, the session's working directory, is
+// inside another git repository, such as a home directory tracked for
+// dotfiles. The outer repository then sees the mirror as untracked, and git
+// falls back to it wherever the mirror's own repository is missing. With
+// mirrorGitDirEnv set, GIT_DIR and GIT_WORK_TREE pin Copilot and the
+// commands it runs to dir's repository instead.
+func checkEnclosingGitRepo(dir string) {
+	outer := enclosingGitRepo(dir)
+	if outer == "" {
+		return
+	}
+	fields := progressFields{"dir": dir, "repo": outer}
+	isolate, _ := strconv.ParseBool(os.Getenv(mirrorGitDirEnv))
+	gitDir := filepath.Join(dir, ".git")
+	if _, err := os.Stat(gitDir); err == nil && isolate {
+		os.Setenv("GIT_DIR", gitDir)
+		os.Setenv("GIT_WORK_TREE", dir)
+		progress.Step("mirror_git_isolated", fields, "  Git pinned to %s (it is inside the repository at %s)\n", gitDir, outer)
+		return
+	}
+	progress.Warn("mirror_in_git_repo", fields,
+		"Warning: %s is inside the git repository at %s. That repository shows it as untracked, and git falls back to it wherever the session's own repository is missing. "+
+			"Add the directory to %s, or set %s=1 to pin the session's git to %s.\n",
+		dir, outer, filepath.Join(outer, ".git", "info", "exclude"), mirrorGitDirEnv, gitDir)
+}
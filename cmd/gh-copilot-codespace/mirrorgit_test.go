@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnclosingGitRepo(t *testing.T) {
+	root := t.TempDir()
+	outer := filepath.Join(root, "home")
+	mirror := filepath.Join(outer, ".copilot", "codespace-workdirs", "cs-test")
+	if err := os.MkdirAll(filepath.Join(mirror, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	beyond := enclosingGitRepo(root)
+
+	// The mirror's own .git does not count.
+	if got := enclosingGitRepo(mirror); got != beyond {
+		t.Errorf("enclosingGitRepo() without an outer repo = %q, want %q", got, beyond)
+	}
+
+	if err := os.Mkdir(filepath.Join(outer, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := enclosingGitRepo(mirror); got != outer {
+		t.Errorf("enclosingGitRepo() = %q, want %q", got, outer)
+	}
+
+	// A worktree's .git file counts too, and the closest repository wins.
+	inner := filepath.Join(outer, ".copilot")
+	if err := os.WriteFile(filepath.Join(inner, ".git"), []byte("gitdir: /elsewhere\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := enclosingGitRepo(mirror); got != inner {
+		t.Errorf("enclosingGitRepo() = %q, want %q", got, inner)
+	}
+}
+
+func TestCheckEnclosingGitRepoIsolates(t *testing.T) {
+	quietProgress(t)
+	outer := t.TempDir()
+	mirror := filepath.Join(outer, "mirror")
+	for _, dir := range []string{filepath.Join(outer, ".git"), filepath.Join(mirror, ".git")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	t.Setenv(mirrorGitDirEnv, "")
+	checkEnclosingGitRepo(mirror)
+	if os.Getenv("GIT_DIR") != "" {
+		t.Errorf("GIT_DIR = %q without %s", os.Getenv("GIT_DIR"), mirrorGitDirEnv)
+	}
+
+	t.Setenv(mirrorGitDirEnv, "1")
+	checkEnclosingGitRepo(mirror)
+	if got := os.Getenv("GIT_DIR"); got != filepath.Join(mirror, ".git") {
+		t.Errorf("GIT_DIR = %q", got)
+	}
+	if got := os.Getenv("GIT_WORK_TREE"); got != mirror {
+		t.Errorf("GIT_WORK_TREE = %q", got)
+	}
+}
+
+func TestMirrorRemoteURL(t *testing.T) {
+	t.Setenv("GH_HOST", "")
+	if got, want := mirrorRemoteURL("github/docs"), "https://github.com/github/docs.git"; got != want {
+		t.Errorf("mirrorRemoteURL() = %q, want %q", got, want)
+	}
+	for _, repo := range []string{"", "(unpublished)", "docs"} {
+		if got := mirrorRemoteURL(repo); got != "" {
+			t.Errorf("mirrorRemoteURL(%q) = %q, want empty", repo, got)
+		}
+	}
+	t.Setenv("GH_HOST", "ghe.example.com")
+	if got, want := mirrorRemoteURL("org/app"), "https://ghe.example.com/org/app.git"; got != want {
+		t.Errorf("mirrorRemoteURL() on GH_HOST = %q, want %q", got, want)
+	}
+}
+
+func TestCloneMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upstream := t.TempDir()
+	git(upstream, "init", "-q", "-b", "feature")
+	git(upstream, "config", "uploadpack.allowFilter", "true")
+	write(filepath.Join(upstream, ".github", "copilot-instructions.md"), "Use tabs.\n")
+	write(filepath.Join(upstream, "src", "main.go"), "package main\n")
+	git(upstream, "add", "-A")
+	git(upstream, "commit", "-q", "-m", "first")
+	write(filepath.Join(upstream, "src", "main.go"), "package main\n\nfunc main() {}\n")
+	git(upstream, "commit", "-q", "-am", "second")
+
+	// The mirror holds a fetched instruction file, edited on the codespace,
+	// and a file the launcher generates.
+	mirror := t.TempDir()
+	write(filepath.Join(mirror, ".github", "copilot-instructions.md"), "Use tabs.\nRun make.\n")
+	write(filepath.Join(mirror, ".github", "hooks", "branch-sync.json"), "{}\n")
+	git(mirror, "init", "-q")
+
+	url := "file://" + upstream
+	if err := cloneMirror(mirror, url, "feature", 50); err != nil {
+		t.Fatalf("cloneMirror() error = %v", err)
+	}
+	if got := git(mirror, "log", "--format=%s"); got != "second\nfirst" {
+		t.Errorf("git log = %q", got)
+	}
+	if got := git(mirror, "status", "--short"); got != "M .github/copilot-instructions.md\n?? .github/hooks/" {
+		t.Errorf("git status = %q", got)
+	}
+	if got := git(mirror, "rev-parse", "--abbrev-ref", "@{upstream}"); got != "origin/feature" {
+		t.Errorf("upstream = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "src")); !os.IsNotExist(err) {
+		t.Errorf("src was checked out: %v", err)
+	}
+
+	// The next launch fetches new commits.
+	write(filepath.Join(upstream, "README.md"), "hi\n")
+	git(upstream, "add", "-A")
+	git(upstream, "commit", "-q", "-m", "third")
+	if err := cloneMirror(mirror, url, "feature", 1); err != nil {
+		t.Fatalf("second cloneMirror() error = %v", err)
+	}
+	if got := git(mirror, "log", "-1", "--format=%s"); got != "third" {
+		t.Errorf("git log after refetch = %q", got)
+	}
+	if got := git(mirror, "status", "--short"); got != "M .github/copilot-instructions.md\n?? .github/hooks/" {
+		t.Errorf("git status after refetch = %q", got)
+	}
+
+	if err := cloneMirror(mirror, url, "missing", 1); err == nil {
+		t.Error("cloneMirror() of a missing branch succeeded")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mirrorManifestFile records a hash of every file the launcher left in the
+// mirror, so edits made during the session can be told apart from fetched
+// files before the next fetch cleans the mirror.
+const mirrorManifestFile = "mirror-manifest.json"
+
+// mirrorLocalDir is the mirror subdirectory that keeps local edits found
+// when the mirror is cleaned. It survives mirror refreshes.
+const mirrorLocalDir = ".local"
+
+// mirrorFileHashes hashes the files in dir that cleanMirrorDir would remove,
+// keyed by slash-separated path relative to dir.
+func mirrorFileHashes(dir string) map[string]string {
+	hashes := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return hashes
+	}
+	for _, e := range entries {
+		if mirrorPreserved[e.Name()] || e.Name() == mirrorManifestFile {
+			continue
+		}
+		filepath.WalkDir(filepath.Join(dir, e.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			sum := sha256.Sum256(data)
+			rel, _ := filepath.Rel(dir, path)
+			hashes[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+			return nil
+		})
+	}
+	return hashes
+}
+
+// recordMirrorManifest saves the hashes of dir's files once the launcher has
+// finished writing them.
+func recordMirrorManifest(dir string) {
+	data, err := json.MarshalIndent(mirrorFileHashes(dir), "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(dir, mirrorManifestFile), append(data, '\n'), 0o644)
+}
+
+// localMirrorChanges lists the files in dir that were added or modified
+// since recordMirrorManifest ran. Without a manifest nothing can be told
+// apart, so nothing is reported.
+func localMirrorChanges(dir string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, mirrorManifestFile))
+	if err != nil {
+		return nil
+	}
+	var recorded map[string]string
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil
+	}
+	var changed []string
+	for path, hash := range mirrorFileHashes(dir) {
+		if recorded[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// keepLocalMirrorChanges moves files added or edited in the mirror since the
+// last launch into mirrorLocalDir, at the same relative paths, so cleaning
+// the mirror does not throw them away. A file already kept at that path is
+// replaced.
+func keepLocalMirrorChanges(dir, codespaceName string) {
+	changed := localMirrorChanges(dir)
+	if len(changed) == 0 {
+		return
+	}
+	var kept []string
+	for _, rel := range changed {
+		target := filepath.Join(dir, mirrorLocalDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			continue
+		}
+		if err := os.Rename(filepath.Join(dir, filepath.FromSlash(rel)), target); err != nil {
+			continue
+		}
+		kept = append(kept, rel)
+	}
+	if len(kept) == 0 {
+		return
+	}
+	progress.Warn("mirror_local_changes", progressFields{"codespace": codespaceName, "paths": kept},
+		"  ⚠ Kept %d file(s) added or edited in the mirror since the last launch in %s: %s\n"+
+			"    The mirror is rebuilt from the codespace; use push to send edited instruction files back.\n",
+		len(kept), filepath.Join(dir, mirrorLocalDir), strings.Join(kept, ", "))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeMirrorFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalMirrorChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeMirrorFile(t, dir, "AGENTS.md", "fetched")
+	writeMirrorFile(t, dir, ".github/copilot-instructions.md", "fetched")
+	writeMirrorFile(t, dir, "files/plan.md", "preserved anyway")
+
+	if got := localMirrorChanges(dir); got != nil {
+		t.Errorf("localMirrorChanges() without a manifest = %v, want nil", got)
+	}
+
+	recordMirrorManifest(dir)
+	if got := localMirrorChanges(dir); got != nil {
+		t.Errorf("localMirrorChanges() right after recording = %v, want nil", got)
+	}
+
+	writeMirrorFile(t, dir, "AGENTS.md", "edited")
+	writeMirrorFile(t, dir, "notes/todo.md", "new")
+	writeMirrorFile(t, dir, "files/plan.md", "edited, but preserved")
+	want := []string{"AGENTS.md", "notes/todo.md"}
+	if got := localMirrorChanges(dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("localMirrorChanges() = %v, want %v", got, want)
+	}
+}
+
+func TestKeepLocalMirrorChangesSurvivesClean(t *testing.T) {
+	quietProgress(t)
+	dir := t.TempDir()
+	writeMirrorFile(t, dir, "AGENTS.md", "fetched")
+	writeMirrorFile(t, dir, "docs/AGENTS.md", "fetched")
+	recordMirrorManifest(dir)
+	writeMirrorFile(t, dir, "docs/AGENTS.md", "edited")
+	writeMirrorFile(t, dir, "scratch.md", "new")
+
+	keepLocalMirrorChanges(dir, "cs-test")
+	cleanMirrorDir(dir)
+
+	for rel, want := range map[string]string{".local/docs/AGENTS.md": "edited", ".local/scratch.md": "new"} {
+		if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel))); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", rel, data, err, want)
+		}
+	}
+	for _, rel := range []string{"AGENTS.md", "docs", "scratch.md", mirrorManifestFile} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); err == nil {
+			t.Errorf("%s should have been removed", rel)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// encryptMirrorEnv turns on encryption of the mirror directory between
+// sessions.
+const encryptMirrorEnv = "COPILOT_CODESPACE_ENCRYPT_MIRROR"
+
+// sealedMirrorPrefix starts the file, next to the mirror directories, that
+// holds a codespace's encrypted mirror between sessions.
+const sealedMirrorPrefix = ".sealed-"
+
+// sealedMirrorHeader starts every sealed mirror and is authenticated with it.
+const sealedMirrorHeader = "gh-copilot-codespace sealed mirror v1\n"
+
+// Keychain entry holding the 256-bit mirror key, hex-encoded.
+const (
+	mirrorKeyService = "gh-copilot-codespace"
+	mirrorKeyAccount = "mirror-key"
+)
+
+// mirrorKeyFunc returns the mirror key, creating and storing one if create is
+// set and none exists. Tests replace it.
+var mirrorKeyFunc = keychainMirrorKey
+
+// encryptMirrorEnabled reports whether encryptMirrorEnv is set to true.
+func encryptMirrorEnabled() bool {
+	v, err := strconv.ParseBool(os.Getenv(encryptMirrorEnv))
+	return err == nil && v
+}
+
+// sealedMirrorPath returns where mirrorDir's encrypted copy is kept.
+func sealedMirrorPath(mirrorDir string) string {
+	return filepath.Join(filepath.Dir(mirrorDir), sealedMirrorPrefix+filepath.Base(mirrorDir))
+}
+
+// keychainMirrorKey reads the mirror key from the macOS keychain or, on
+// Linux, the Secret Service (through secret-tool). There is no fallback to a
+// key file: a key stored next to the mirror would protect nothing.
+func keychainMirrorKey(create bool) ([]byte, error) {
+	var lookup *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		lookup = exec.Command("security", "find-generic-password", "-s", mirrorKeyService, "-a", mirrorKeyAccount, "-w")
+	case hasCommand("secret-tool"):
+		lookup = exec.Command("secret-tool", "lookup", "service", mirrorKeyService, "account", mirrorKeyAccount)
+	default:
+		return nil, fmt.Errorf("no OS keychain available (mirror encryption needs the macOS keychain or secret-tool from libsecret)")
+	}
+	var stderr bytes.Buffer
+	lookup.Stderr = &stderr
+	out, err := lookup.Output()
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keychain entry %s/%s is not a 256-bit hex key", mirrorKeyService, mirrorKeyAccount)
+		}
+		return key, nil
+	}
+	// security exits 44 for a missing item; secret-tool exits 1 silently.
+	var exitErr *exec.ExitError
+	missing := errors.As(err, &exitErr) && (exitErr.ExitCode() == 44 || (runtime.GOOS != "darwin" && strings.TrimSpace(stderr.String()) == ""))
+	if !missing {
+		return nil, fmt.Errorf("reading mirror key from keychain: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if !create {
+		return nil, fmt.Errorf("no mirror key in the keychain (service %s, account %s)", mirrorKeyService, mirrorKeyAccount)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	// The key goes over stdin so it never shows up in the process list.
+	var store *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		store = exec.Command("security", "-i")
+		store.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", mirrorKeyService, mirrorKeyAccount, hex.EncodeToString(key)))
+	} else {
+		store = exec.Command("secret-tool", "store", "--label=gh-copilot-codespace mirror key", "service", mirrorKeyService, "account", mirrorKeyAccount)
+		store.Stdin = strings.NewReader(hex.EncodeToString(key))
+	}
+	if out, err := store.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("storing mirror key in keychain: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return key, nil
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// sealMirror encrypts the contents of mirrorDir, except .git, into its
+// sealed file and removes them. The empty directory and its .git stay, so
+// Copilot keeps trusting the folder. It returns the number of files sealed.
+func sealMirror(mirrorDir string, key []byte) (int, error) {
+	sealedPath := sealedMirrorPath(mirrorDir)
+	if _, err := os.Stat(sealedPath); err == nil {
+		// Never overwrite a copy this session could not decrypt.
+		return 0, fmt.Errorf("%s already exists", sealedPath)
+	}
+	entries, err := os.ReadDir(mirrorDir)
+	if err != nil {
+		return 0, err
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	files := 0
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(mirrorDir, e.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			var link string
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			} else if !info.Mode().IsRegular() && !info.IsDir() {
+				return nil
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(mirrorDir, path)
+			hdr.Name = filepath.ToSlash(rel)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files++
+			_, err = tw.Write(data)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("archiving mirror: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if files == 0 {
+		return 0, nil
+	}
+
+	gcm, err := mirrorCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	sealed := append([]byte(sealedMirrorHeader), nonce...)
+	sealed = gcm.Seal(sealed, nonce, archive.Bytes(), []byte(sealedMirrorHeader))
+	tmp := sealedPath + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return 0, fmt.Errorf("writing sealed mirror: %w", err)
+	}
+	if err := os.Rename(tmp, sealedPath); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("writing sealed mirror: %w", err)
+	}
+	for _, e := range entries {
+		if e.Name() != ".git" {
+			os.RemoveAll(filepath.Join(mirrorDir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// unsealMirror restores mirrorDir's sealed file into it and removes the
+// sealed file. It returns the number of files restored.
+func unsealMirror(mirrorDir string, key []byte) (int, error) {
+	sealedPath := sealedMirrorPath(mirrorDir)
+	sealed, err := os.ReadFile(sealedPath)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(sealed, []byte(sealedMirrorHeader)) {
+		return 0, fmt.Errorf("%s is not a sealed mirror", sealedPath)
+	}
+	gcm, err := mirrorCipher(key)
+	if err != nil {
+		return 0, err
+	}
+	body := sealed[len(sealedMirrorHeader):]
+	if len(body) < gcm.NonceSize() {
+		return 0, fmt.Errorf("%s is truncated", sealedPath)
+	}
+	archive, err := gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], []byte(sealedMirrorHeader))
+	if err != nil {
+		return 0, fmt.Errorf("decrypting %s: wrong key or corrupted file", sealedPath)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return 0, err
+	}
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return files, fmt.Errorf("reading sealed mirror: %w", err)
+		}
+		clean := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || clean == ".git" || strings.HasPrefix(clean, ".git"+string(filepath.Separator)) {
+			return files, fmt.Errorf("invalid path %q in sealed mirror", hdr.Name)
+		}
+		target := filepath.Join(mirrorDir, clean)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return files, err
+			}
+		case tar.TypeSymlink:
+			os.MkdirAll(filepath.Dir(target), 0o755)
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return files, err
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return files, err
+			}
+			if err := os.WriteFile(target, data, fs.FileMode(hdr.Mode).Perm()); err != nil {
+				return files, err
+			}
+			files++
+		}
+	}
+	if err := os.Remove(sealedPath); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+func mirrorCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("mirror key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// unsealMirrorAtLaunch decrypts mirrorDir if a previous session sealed it,
+// whether or not encryption is still enabled, so the files the mirror keeps
+// between launches come back before the fetch.
+func unsealMirrorAtLaunch(mirrorDir, codespaceName string) error {
+	sealedPath := sealedMirrorPath(mirrorDir)
+	if _, err := os.Stat(sealedPath); err != nil {
+		return nil
+	}
+	key, err := mirrorKeyFunc(false)
+	if err == nil {
+		var files int
+		if files, err = unsealMirror(mirrorDir, key); err == nil {
+			progress.Step("mirror_decrypted", progressFields{"codespace": codespaceName, "files": files}, "  Decrypted mirror (%d files)\n", files)
+			return nil
+		}
+	}
+	return fmt.Errorf("the mirror for %s is encrypted and could not be decrypted: %v (remove %s to start over without its saved files and recordings)", codespaceName, err, sealedPath)
+}
+
+// sealMirrorAtExit encrypts mirrorDir when the MCP server exits, unless
+// another session still uses the codespace; the last one out seals it.
+func sealMirrorAtExit(mirrorDir string) (int, error) {
+	codespaceName := filepath.Base(mirrorDir)
+	for _, s := range findActiveSessions(activeSessionDir(), codespaceName) {
+		// Copilot, this server's parent, is exiting too.
+		if s.PID != os.Getppid() {
+			return 0, fmt.Errorf("not encrypting %s: session %d still uses it", mirrorDir, s.PID)
+		}
+	}
+	key, err := mirrorKeyFunc(true)
+	if err != nil {
+		return 0, fmt.Errorf("not encrypting %s: %w", mirrorDir, err)
+	}
+	return sealMirror(mirrorDir, key)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMirrorFixture(t *testing.T) string {
+	t.Helper()
+	mirrorDir := filepath.Join(t.TempDir(), "cs-test")
+	for path, content := range map[string]string{
+		".github/copilot-instructions.md": "internal docs",
+		"files/notes.md":                  "user notes",
+		"logs/session.cast":               "recording",
+		".git/HEAD":                       "ref: refs/heads/main\n",
+	} {
+		full := filepath.Join(mirrorDir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("notes.md", filepath.Join(mirrorDir, "files", "latest.md")); err != nil {
+		t.Fatal(err)
+	}
+	return mirrorDir
+}
+
+func TestSealMirrorRoundTrip(t *testing.T) {
+	mirrorDir := writeMirrorFixture(t)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	files, err := sealMirror(mirrorDir, key)
+	if err != nil {
+		t.Fatalf("sealMirror() error = %v", err)
+	}
+	if files != 3 {
+		t.Errorf("sealed %d files, want 3", files)
+	}
+	entries, _ := os.ReadDir(mirrorDir)
+	if len(entries) != 1 || entries[0].Name() != ".git" {
+		t.Errorf("mirror after sealing = %v, want only .git", entries)
+	}
+	sealed, err := os.ReadFile(sealedMirrorPath(mirrorDir))
+	if err != nil {
+		t.Fatalf("reading sealed mirror: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("internal docs")) {
+		t.Error("sealed mirror contains plaintext")
+	}
+	if info, _ := os.Stat(sealedMirrorPath(mirrorDir)); info.Mode().Perm() != 0o600 {
+		t.Errorf("sealed mirror mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	files, err = unsealMirror(mirrorDir, key)
+	if err != nil {
+		t.Fatalf("unsealMirror() error = %v", err)
+	}
+	if files != 3 {
+		t.Errorf("restored %d files, want 3", files)
+	}
+	if data, _ := os.ReadFile(filepath.Join(mirrorDir, ".github", "copilot-instructions.md")); string(data) != "internal docs" {
+		t.Errorf("instructions = %q", data)
+	}
+	if link, _ := os.Readlink(filepath.Join(mirrorDir, "files", "latest.md")); link != "notes.md" {
+		t.Errorf("symlink = %q, want notes.md", link)
+	}
+	if _, err := os.Stat(sealedMirrorPath(mirrorDir)); !os.IsNotExist(err) {
+		t.Errorf("sealed mirror still exists after unsealing: %v", err)
+	}
+}
+
+func TestUnsealMirrorWrongKeyKeepsSealedFile(t *testing.T) {
+	mirrorDir := writeMirrorFixture(t)
+	if _, err := sealMirror(mirrorDir, bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unsealMirror(mirrorDir, bytes.Repeat([]byte{2}, 32)); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("unsealMirror() error = %v, want wrong key", err)
+	}
+	if _, err := os.Stat(sealedMirrorPath(mirrorDir)); err != nil {
+		t.Errorf("sealed mirror removed after failed unseal: %v", err)
+	}
+	// A later session must not replace the copy it could not open.
+	if _, err := sealMirror(mirrorDir, bytes.Repeat([]byte{2}, 32)); err == nil {
+		t.Error("sealMirror() overwrote an existing sealed mirror")
+	}
+}
+
+func TestSealMirrorEmptyWritesNothing(t *testing.T) {
+	mirrorDir := filepath.Join(t.TempDir(), "cs-empty")
+	os.MkdirAll(filepath.Join(mirrorDir, ".git"), 0o755)
+	if files, err := sealMirror(mirrorDir, bytes.Repeat([]byte{1}, 32)); err != nil || files != 0 {
+		t.Errorf("sealMirror() = %d, %v; want 0, nil", files, err)
+	}
+	if _, err := os.Stat(sealedMirrorPath(mirrorDir)); !os.IsNotExist(err) {
+		t.Errorf("sealed mirror written for an empty mirror: %v", err)
+	}
+}
+
+func TestUnsealMirrorAtLaunch(t *testing.T) {
+	quietProgress(t)
+	mirrorDir := writeMirrorFixture(t)
+	key := bytes.Repeat([]byte{9}, 32)
+	oldKeyFunc := mirrorKeyFunc
+	t.Cleanup(func() { mirrorKeyFunc = oldKeyFunc })
+
+	// Nothing sealed: nothing to do, and the keychain is not touched.
+	mirrorKeyFunc = func(bool) ([]byte, error) { t.Fatal("keychain read without a sealed mirror"); return nil, nil }
+	if err := unsealMirrorAtLaunch(mirrorDir, "cs-test"); err != nil {
+		t.Fatalf("unsealMirrorAtLaunch() error = %v", err)
+	}
+
+	if _, err := sealMirror(mirrorDir, key); err != nil {
+		t.Fatal(err)
+	}
+	mirrorKeyFunc = func(create bool) ([]byte, error) { return nil, errors.New("keychain locked") }
+	if err := unsealMirrorAtLaunch(mirrorDir, "cs-test"); err == nil || !strings.Contains(err.Error(), "keychain locked") {
+		t.Errorf("unsealMirrorAtLaunch() error = %v, want keychain error", err)
+	}
+
+	mirrorKeyFunc = func(create bool) ([]byte, error) {
+		if create {
+			t.Error("unsealing asked to create a key")
+		}
+		return key, nil
+	}
+	if err := unsealMirrorAtLaunch(mirrorDir, "cs-test"); err != nil {
+		t.Fatalf("unsealMirrorAtLaunch() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(mirrorDir, "files", "notes.md")); string(data) != "user notes" {
+		t.Errorf("notes = %q", data)
+	}
+}
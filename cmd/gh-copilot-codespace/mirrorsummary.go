@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// mirrorSummaryFile records what the last fetch mirrored, so the next launch
+// can show what changed.
+const mirrorSummaryFile = "mirror-summary.json"
+
+// mirrorSummary counts what a fetch mirrored or forwarded, by kind.
+type mirrorSummary struct {
+	Instructions int `json:"instructions"`
+	Nested       int `json:"nested"` // instruction files below the workdir root
+	Skills       int `json:"skills"`
+	Agents       int `json:"agents"`
+	Commands     int `json:"commands"`
+	Hooks        int `json:"hooks"` // hook config files
+	MCPServers   int `json:"mcpServers"`
+}
+
+// skillRoots are the directories whose subdirectories are skills.
+var skillRoots = []string{".github/skills/", ".agents/skills/", ".claude/skills/"}
+
+func summarizeMirror(files map[string][]byte, mcpServers map[string]any) mirrorSummary {
+	s := mirrorSummary{MCPServers: len(mcpServers)}
+	skills := make(map[string]bool)
+	for relPath := range files {
+		switch {
+		case isInstructionFile(relPath):
+			s.Instructions++
+			if instructionScope(relPath) != "" {
+				s.Nested++
+			}
+		case isAgentFile(relPath):
+			s.Agents++
+		case strings.HasPrefix(relPath, ".claude/commands/"):
+			s.Commands++
+		case strings.HasPrefix(relPath, ".github/hooks/") && strings.HasSuffix(relPath, ".json"):
+			s.Hooks++
+		default:
+			for _, root := range skillRoots {
+				if rest, ok := strings.CutPrefix(relPath, root); ok {
+					if name, _, ok := strings.Cut(rest, "/"); ok {
+						skills[root+name] = true
+					}
+				}
+			}
+		}
+	}
+	s.Skills = len(skills)
+	return s
+}
+
+// instructionScope returns the directory an instruction file applies to,
+// relative to the workdir: "" for the root, "pkg/api" for
+// pkg/api/AGENTS.md or pkg/api/.github/instructions/go.instructions.md.
+func instructionScope(relPath string) string {
+	rooted := "/" + relPath
+	if i := strings.LastIndex(rooted, "/.github/"); i >= 0 {
+		return strings.TrimPrefix(rooted[:i], "/")
+	}
+	if dir := path.Dir(relPath); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// format renders the summary on one line. With a previous summary, counts
+// that changed are followed by the difference, e.g. "3 skills (+1)".
+func (s mirrorSummary) format(prev *mirrorSummary) string {
+	count := func(n, before int, singular, plural string) string {
+		noun := plural
+		if n == 1 {
+			noun = singular
+		}
+		out := fmt.Sprintf("%d %s", n, noun)
+		if prev != nil && n != before {
+			out += fmt.Sprintf(" (%+d)", n-before)
+		}
+		return out
+	}
+	var p mirrorSummary
+	if prev != nil {
+		p = *prev
+	}
+	instructions := count(s.Instructions, p.Instructions, "instruction file", "instruction files")
+	if s.Nested > 0 || (prev != nil && p.Nested > 0) {
+		instructions += ", " + count(s.Nested, p.Nested, "of them nested", "of them nested")
+	}
+	return strings.Join([]string{
+		instructions,
+		count(s.Skills, p.Skills, "skill", "skills"),
+		count(s.Agents, p.Agents, "agent", "agents"),
+		count(s.Commands, p.Commands, "command", "commands"),
+		count(s.Hooks, p.Hooks, "hook config", "hook configs"),
+		count(s.MCPServers, p.MCPServers, "MCP server", "MCP servers"),
+	}, ", ")
+}
+
+// loadMirrorSummary reads the summary saved by the previous fetch into dir,
+// or returns nil if there is none.
+func loadMirrorSummary(dir string) *mirrorSummary {
+	data, err := os.ReadFile(filepath.Join(dir, mirrorSummaryFile))
+	if err != nil {
+		return nil
+	}
+	var s mirrorSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+func saveMirrorSummary(dir string, s mirrorSummary) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(dir, mirrorSummaryFile), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestSummarizeMirror(t *testing.T) {
+	files := map[string][]byte{
+		"AGENTS.md":                                   nil,
+		".github/copilot-instructions.md":             nil,
+		"pkg/api/AGENTS.md":                           nil,
+		"web/.github/instructions/ts.instructions.md": nil,
+		".github/skills/deploy/SKILL.md":              nil,
+		".github/skills/deploy/run.sh":                nil,
+		".claude/skills/review/SKILL.md":              nil,
+		".github/agents/reviewer.agent.md":            nil,
+		".claude/commands/ship.md":                    nil,
+		".github/hooks/hooks.json":                    nil,
+		".copilot/config.json":                        nil,
+	}
+	got := summarizeMirror(files, map[string]any{"db": nil})
+	want := mirrorSummary{Instructions: 4, Nested: 2, Skills: 2, Agents: 1, Commands: 1, Hooks: 1, MCPServers: 1}
+	if got != want {
+		t.Errorf("summarizeMirror() = %+v, want %+v", got, want)
+	}
+}
+
+func TestInstructionScope(t *testing.T) {
+	for relPath, want := range map[string]string{
+		"AGENTS.md":                                   "",
+		".github/copilot-instructions.md":             "",
+		".github/instructions/go.instructions.md":     "",
+		"pkg/api/CLAUDE.md":                           "pkg/api",
+		"web/.github/instructions/ts.instructions.md": "web",
+		"services/a/.github/copilot-instructions.md":  "services/a",
+	} {
+		if got := instructionScope(relPath); got != want {
+			t.Errorf("instructionScope(%q) = %q, want %q", relPath, got, want)
+		}
+	}
+}
+
+func TestMirrorSummaryFormat(t *testing.T) {
+	s := mirrorSummary{Instructions: 4, Nested: 2, Skills: 1, Hooks: 1, MCPServers: 2}
+	if got, want := s.format(nil), "4 instruction files, 2 of them nested, 1 skill, 0 agents, 0 commands, 1 hook config, 2 MCP servers"; got != want {
+		t.Errorf("format(nil) = %q, want %q", got, want)
+	}
+	prev := mirrorSummary{Instructions: 3, Nested: 1, Skills: 1, Hooks: 1, MCPServers: 3}
+	if got, want := s.format(&prev), "4 instruction files (+1), 2 of them nested (+1), 1 skill, 0 agents, 0 commands, 1 hook config, 2 MCP servers (-1)"; got != want {
+		t.Errorf("format(prev) = %q, want %q", got, want)
+	}
+}
+
+func TestMirrorSummaryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if loadMirrorSummary(dir) != nil {
+		t.Fatal("loadMirrorSummary() found a summary in an empty dir")
+	}
+	want := mirrorSummary{Instructions: 2, Skills: 3}
+	saveMirrorSummary(dir, want)
+	if got := loadMirrorSummary(dir); got == nil || *got != want {
+		t.Errorf("loadMirrorSummary() = %+v, want %+v", got, want)
+	}
+}
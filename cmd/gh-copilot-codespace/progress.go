@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// progressMode selects how the launcher reports startup progress.
+type progressMode int
+
+const (
+	progressHuman progressMode = iota // banner and ✓/⚠ status lines
+	progressQuiet                     // warnings and errors only
+	progressJSON                      // one JSON object per line on stdout, nothing else
+)
+
+// progressFields carries structured detail for a progress event. Human output
+// ignores it; JSON output merges it into the event object.
+type progressFields map[string]any
+
+// progressReporter writes launcher progress for humans or for wrappers that
+// embed the launcher (--quiet, --json-status).
+type progressReporter struct {
+	mode progressMode
+	out  io.Writer
+	err  io.Writer
+	now  func() time.Time
+	mu   sync.Mutex
+
+	// live enables the in-place status line; only useful on a terminal.
+	live       bool
+	liveActive bool
+}
+
+func newProgressReporter(mode progressMode, out, errOut io.Writer) *progressReporter {
+	p := &progressReporter{mode: mode, out: out, err: errOut, now: time.Now}
+	if f, ok := out.(*os.File); ok && os.Getenv("TERM") != "dumb" {
+		p.live = term.IsTerminal(f.Fd())
+	}
+	return p
+}
+
+// progress is the launcher-wide reporter, replaced once flags are parsed.
+var progress = newProgressReporter(progressHuman, os.Stdout, os.Stderr)
+
+// Step reports a progress line. event is a stable snake_case name for JSON
+// consumers; format is the human-readable line, including its newline.
+func (p *progressReporter) Step(event string, fields progressFields, format string, args ...any) {
+	switch p.mode {
+	case progressHuman:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.clearLiveLocked()
+		fmt.Fprintf(p.out, format, args...)
+	case progressJSON:
+		p.emit("info", event, fields, fmt.Sprintf(format, args...))
+	}
+}
+
+// Live replaces the in-place status line on a terminal. It is meant for
+// high-frequency updates: human output without a terminal drops them, and
+// the next Step or Warn clears the line.
+func (p *progressReporter) Live(event string, fields progressFields, format string, args ...any) {
+	switch p.mode {
+	case progressHuman:
+		if !p.live {
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		fmt.Fprintf(p.out, "\r\033[K"+format, args...)
+		p.liveActive = true
+	case progressJSON:
+		p.emit("info", event, fields, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *progressReporter) clearLiveLocked() {
+	if p.liveActive {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.liveActive = false
+	}
+}
+
+// Warn reports a non-fatal problem. Text output goes to stderr even in quiet mode.
+func (p *progressReporter) Warn(event string, fields progressFields, format string, args ...any) {
+	if p.mode == progressJSON {
+		p.emit("warning", event, fields, fmt.Sprintf(format, args...))
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearLiveLocked()
+	fmt.Fprintf(p.err, format, args...)
+}
+
+// Error reports the error that ends the launcher.
+func (p *progressReporter) Error(err error) {
+	if p.mode == progressJSON {
+		p.emit("error", "error", nil, err.Error())
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearLiveLocked()
+	fmt.Fprintf(p.err, "Error: %v\n", err)
+}
+
+// emit writes one JSON line. Fields cannot override the envelope keys.
+func (p *progressReporter) emit(level, event string, fields progressFields, message string) {
+	obj := make(map[string]any, len(fields)+4)
+	for k, v := range fields {
+		obj[k] = v
+	}
+	obj["time"] = p.now().UTC().Format(time.RFC3339)
+	obj["level"] = level
+	obj["event"] = event
+	if msg := jsonStatusMessage(message); msg != "" {
+		obj["message"] = msg
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out.Write(append(data, '\n'))
+}
+
+// jsonStatusMessage strips the indentation and status glyphs meant for terminals.
+func jsonStatusMessage(s string) string {
+	s = strings.TrimSpace(s)
+	for _, glyph := range []string{"✓ ", "⚠ "} {
+		s = strings.TrimPrefix(s, glyph)
+	}
+	return s
+}
+
+// reportLaunch prints the banner shown just before Copilot takes over the
+// terminal. In JSON mode it is the final "launch" event.
+func reportLaunch(title string, reg *registry.Registry, excludedTools []string) {
+	codespaces := make([]map[string]string, 0, reg.Len())
+	for _, cs := range reg.All() {
+		codespaces = append(codespaces, map[string]string{
+			"alias":      cs.Alias,
+			"name":       cs.Name,
+			"repository": cs.Repository,
+			"workdir":    cs.Workdir,
+		})
+	}
+	if progress.mode == progressJSON {
+		progress.Step("launch", progressFields{"codespaces": codespaces, "excludedTools": len(excludedTools)}, "%s", title)
+		return
+	}
+
+	progress.Step("launch", nil, "\n%s\n", title)
+	if reg.Len() == 0 {
+		progress.Step("launch", nil, "  Codespace: none connected yet\n")
+	}
+	for _, cs := range reg.All() {
+		progress.Step("launch", nil, "  Codespace: %s (alias: %s, repo: %s)\n", cs.Name, cs.Alias, registry.RepositoryLabel(cs.Repository))
+	}
+	progress.Step("launch", nil, "  Excluded:  %d local tools\n\n", len(excludedTools))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+func TestLauncherOptionsProgressMode(t *testing.T) {
+	tests := []struct {
+		opts launcherOptions
+		want progressMode
+	}{
+		{launcherOptions{}, progressHuman},
+		{launcherOptions{quiet: true}, progressQuiet},
+		{launcherOptions{jsonStatus: true}, progressJSON},
+		{launcherOptions{quiet: true, jsonStatus: true}, progressJSON},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.progressMode(); got != tt.want {
+			t.Errorf("%+v.progressMode() = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestProgressReporter_Human(t *testing.T) {
+	var out, errOut bytes.Buffer
+	p := newProgressReporter(progressHuman, &out, &errOut)
+	p.Step("file_fetched", progressFields{"path": "AGENTS.md"}, "  ✓ %s\n", "AGENTS.md")
+	p.Warn("fetch_failed", nil, "Warning: %s\n", "boom")
+	p.Error(errors.New("bad"))
+
+	if out.String() != "  ✓ AGENTS.md\n" {
+		t.Errorf("stdout = %q", out.String())
+	}
+	if errOut.String() != "Warning: boom\nError: bad\n" {
+		t.Errorf("stderr = %q", errOut.String())
+	}
+}
+
+func TestProgressReporter_Quiet(t *testing.T) {
+	var out, errOut bytes.Buffer
+	p := newProgressReporter(progressQuiet, &out, &errOut)
+	p.Step("file_fetched", nil, "  ✓ AGENTS.md\n")
+	p.Warn("fetch_failed", nil, "Warning: boom\n")
+
+	if out.Len() != 0 {
+		t.Errorf("quiet mode wrote progress: %q", out.String())
+	}
+	if errOut.String() != "Warning: boom\n" {
+		t.Errorf("stderr = %q, want warning kept", errOut.String())
+	}
+}
+
+func TestProgressReporter_JSON(t *testing.T) {
+	var out, errOut bytes.Buffer
+	p := newProgressReporter(progressJSON, &out, &errOut)
+	p.now = func() time.Time { return time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC) }
+	p.Step("file_fetched", progressFields{"path": "AGENTS.md", "event": "ignored"}, "  ✓ %s\n", "AGENTS.md")
+	p.Warn("hooks_skipped", progressFields{"path": ".github/hooks/a.json"}, "  ⚠ skipped\n")
+	p.Error(errors.New("bad"))
+
+	if errOut.Len() != 0 {
+		t.Errorf("json mode wrote to stderr: %q", errOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), out.String())
+	}
+	var events []map[string]any
+	for _, l := range lines {
+		var ev map[string]any
+		if err := json.Unmarshal([]byte(l), &ev); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", l, err)
+		}
+		events = append(events, ev)
+	}
+	if events[0]["event"] != "file_fetched" || events[0]["level"] != "info" || events[0]["path"] != "AGENTS.md" || events[0]["message"] != "AGENTS.md" {
+		t.Errorf("step event = %v", events[0])
+	}
+	if events[0]["time"] != "2026-03-04T12:00:00Z" {
+		t.Errorf("time = %v", events[0]["time"])
+	}
+	if events[1]["level"] != "warning" || events[1]["message"] != "skipped" {
+		t.Errorf("warn event = %v", events[1])
+	}
+	if events[2]["event"] != "error" || events[2]["level"] != "error" || events[2]["message"] != "bad" {
+		t.Errorf("error event = %v", events[2])
+	}
+}
+
+func TestReportLaunch_JSON(t *testing.T) {
+	var out bytes.Buffer
+	orig := progress
+	progress = newProgressReporter(progressJSON, &out, &out)
+	t.Cleanup(func() { progress = orig })
+
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-1", Repository: "octo/app", Workdir: "/workspaces/app"})
+	reportLaunch("Launching Copilot CLI with remote codespace tools...", reg, []string{"bash", "grep"})
+
+	var ev struct {
+		Event         string              `json:"event"`
+		Codespaces    []map[string]string `json:"codespaces"`
+		ExcludedTools int                 `json:"excludedTools"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &ev); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if ev.Event != "launch" || ev.ExcludedTools != 2 || len(ev.Codespaces) != 1 || ev.Codespaces[0]["alias"] != "app" {
+		t.Errorf("launch event = %+v", ev)
+	}
+}
+
+func TestProgressReporter_Live(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgressReporter(progressHuman, &out, &out)
+	p.Live("fetch_progress", nil, "  [1/2] a.md")
+	if out.Len() != 0 {
+		t.Fatalf("live updates without a terminal should be dropped, got %q", out.String())
+	}
+
+	p.live = true
+	p.Live("fetch_progress", nil, "  [1/2] a.md")
+	p.Step("fetch_completed", nil, "  Fetched 2 files\n")
+	if got, want := out.String(), "\r\033[K  [1/2] a.md\r\033[K  Fetched 2 files\n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+
+	var jsonOut bytes.Buffer
+	p = newProgressReporter(progressJSON, &jsonOut, &jsonOut)
+	p.Live("fetch_progress", progressFields{"done": 1}, "  [1/2] a.md")
+	if !strings.Contains(jsonOut.String(), `"event":"fetch_progress"`) || !strings.Contains(jsonOut.String(), `"done":1`) {
+		t.Fatalf("json live event = %q", jsonOut.String())
+	}
+}
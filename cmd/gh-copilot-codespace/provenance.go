@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// provenanceEnv selects the header added to mirrored instruction files:
+// "full" (default) names the source file and fetch time, "path" only the
+// source file, and "off" adds nothing.
+const provenanceEnv = "COPILOT_CODESPACE_PROVENANCE"
+
+type provenanceMode string
+
+const (
+	provenanceFull provenanceMode = "full"
+	provenancePath provenanceMode = "path"
+	provenanceOff  provenanceMode = "off"
+)
+
+// provenanceModeFromEnv reads provenanceEnv, warning about unknown values.
+func provenanceModeFromEnv() provenanceMode {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(provenanceEnv))); v {
+	case "", "full", "1", "true", "on":
+		return provenanceFull
+	case "path":
+		return provenancePath
+	case "off", "0", "false", "none":
+		return provenanceOff
+	default:
+		progress.Warn("provenance_invalid", progressFields{"value": v},
+			"Warning: ignoring %s=%q (use full, path, or off)\n", provenanceEnv, v)
+		return provenanceFull
+	}
+}
+
+// provenanceMarker starts every provenance header, so it can be recognized
+// and stripped.
+const provenanceMarker = "<!-- gh-copilot-codespace: mirrored from "
+
+// isInstructionFile reports whether a mirrored path is an instruction file the
+// agent reads as guidance, as opposed to agents, skills, commands, and hooks.
+// Nested projects' .github instruction files count as well.
+func isInstructionFile(relPath string) bool {
+	switch path.Base(relPath) {
+	case "AGENTS.md", "CLAUDE.md", "GEMINI.md":
+		return true
+	}
+	rooted := "/" + relPath
+	return strings.HasSuffix(rooted, "/.github/copilot-instructions.md") ||
+		(strings.Contains(rooted, "/.github/instructions/") && strings.HasSuffix(relPath, ".instructions.md"))
+}
+
+// addProvenanceHeader inserts an HTML comment naming the file's source on the
+// codespace (and, in full mode, when it was fetched). The comment goes after
+// YAML frontmatter, which must stay first for applyTo and similar keys to
+// keep working.
+func addProvenanceHeader(content []byte, mode provenanceMode, codespaceName, remotePath string, fetchedAt time.Time) []byte {
+	if mode == provenanceOff {
+		return content
+	}
+	header := provenanceMarker + codespaceName + ":" + remotePath
+	if mode == provenanceFull {
+		header += " at " + fetchedAt.UTC().Format(time.RFC3339)
+	}
+	header += " -->\n"
+
+	eol := []byte("\n")
+	if bytes.Contains(content, []byte("\r\n")) {
+		eol = []byte("\r\n")
+		header = strings.TrimSuffix(header, "\n") + "\r\n"
+	}
+	if end := frontmatterEnd(content, eol); end > 0 {
+		out := make([]byte, 0, len(content)+len(header))
+		out = append(out, content[:end]...)
+		out = append(out, header...)
+		return append(out, content[end:]...)
+	}
+	return append([]byte(header), content...)
+}
+
+// frontmatterEnd returns the offset just past a leading "---" frontmatter
+// block's closing line, or 0 if content has none.
+func frontmatterEnd(content, eol []byte) int {
+	open := append([]byte("---"), eol...)
+	if !bytes.HasPrefix(content, open) {
+		return 0
+	}
+	offset := len(open)
+	for offset < len(content) {
+		lineEnd := bytes.Index(content[offset:], eol)
+		line := content[offset:]
+		next := len(content)
+		if lineEnd >= 0 {
+			line = content[offset : offset+lineEnd]
+			next = offset + lineEnd + len(eol)
+		}
+		if string(bytes.TrimRight(line, " \t")) == "---" {
+			return next
+		}
+		offset = next
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddProvenanceHeader(t *testing.T) {
+	fetchedAt := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name    string
+		content string
+		mode    provenanceMode
+		want    string
+	}{
+		{
+			name:    "plain markdown",
+			content: "# Rules\n",
+			mode:    provenanceFull,
+			want:    "<!-- gh-copilot-codespace: mirrored from cs-1:/workspaces/app/AGENTS.md at 2026-03-01T11:30:00Z -->\n# Rules\n",
+		},
+		{
+			name:    "after frontmatter",
+			content: "---\napplyTo: \"**/*.go\"\n---\nUse gofmt.\n",
+			mode:    provenancePath,
+			want:    "---\napplyTo: \"**/*.go\"\n---\n<!-- gh-copilot-codespace: mirrored from cs-1:/workspaces/app/AGENTS.md -->\nUse gofmt.\n",
+		},
+		{
+			name:    "CRLF",
+			content: "---\r\napplyTo: x\r\n---\r\nBody\r\n",
+			mode:    provenancePath,
+			want:    "---\r\napplyTo: x\r\n---\r\n<!-- gh-copilot-codespace: mirrored from cs-1:/workspaces/app/AGENTS.md -->\r\nBody\r\n",
+		},
+		{
+			name:    "unterminated frontmatter is treated as content",
+			content: "---\nnot closed\n",
+			mode:    provenancePath,
+			want:    "<!-- gh-copilot-codespace: mirrored from cs-1:/workspaces/app/AGENTS.md -->\n---\nnot closed\n",
+		},
+		{
+			name:    "off",
+			content: "# Rules\n",
+			mode:    provenanceOff,
+			want:    "# Rules\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(addProvenanceHeader([]byte(tt.content), tt.mode, "cs-1", "/workspaces/app/AGENTS.md", fetchedAt))
+			if got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsInstructionFile(t *testing.T) {
+	for path, want := range map[string]bool{
+		".github/copilot-instructions.md":                      true,
+		".github/instructions/go.instructions.md":              true,
+		"packages/web/.github/copilot-instructions.md":         true,
+		"packages/web/.github/instructions/ts.instructions.md": true,
+		"docs/copilot-instructions.md":                         false,
+		"AGENTS.md":                                            true,
+		"services/api/CLAUDE.md":                               true,
+		".github/agents/reviewer.agent.md":                     false,
+		".github/skills/deploy/SKILL.md":                       false,
+		".github/instructions/README.md":                       false,
+		".github/hooks/hooks.json":                             false,
+	} {
+		if got := isInstructionFile(path); got != want {
+			t.Errorf("isInstructionFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestProvenanceModeFromEnv(t *testing.T) {
+	quietProgress(t)
+	for value, want := range map[string]provenanceMode{"": provenanceFull, "path": provenancePath, "OFF": provenanceOff, "bogus": provenanceFull} {
+		t.Setenv(provenanceEnv, value)
+		if got := provenanceModeFromEnv(); got != want {
+			t.Errorf("%s=%q: got %q, want %q", provenanceEnv, value, got, want)
+		}
+	}
+}
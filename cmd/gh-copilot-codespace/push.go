@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+type pushOptions struct {
+	codespaceName   string
+	workdirOverride string
+	yes             bool
+	files           []string
+}
+
+func parsePushArgs(args []string) (pushOptions, error) {
+	var opts pushOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
+			opts.codespaceName = args[i+1]
+			i++
+		case (args[i] == "--workdir" || args[i] == "-w") && i+1 < len(args):
+			opts.workdirOverride = args[i+1]
+			i++
+		case args[i] == "--yes" || args[i] == "-y":
+			opts.yes = true
+		case strings.HasPrefix(args[i], "-"):
+			return pushOptions{}, fmt.Errorf("unknown push argument %q", args[i])
+		default:
+			opts.files = append(opts.files, args[i])
+		}
+	}
+	if opts.codespaceName == "" {
+		return pushOptions{}, fmt.Errorf("push requires --codespace NAME")
+	}
+	if len(opts.files) == 0 {
+		return pushOptions{}, fmt.Errorf("push requires at least one mirrored file, e.g. AGENTS.md")
+	}
+	return opts, nil
+}
+
+// mirrorDirFor returns the directory a codespace's files are mirrored into.
+func mirrorDirFor(codespaceName string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home dir: %w", err)
+	}
+	return filepath.Join(ssh.WorkdirsDir(homeDir), codespaceName), nil
+}
+
+// mirrorRelPath returns file's path relative to the mirror root. Relative
+// paths are taken as relative to the mirror already. A path inside the
+// codespace workdir names the mirror copy of that file; translated reports
+// such a path.
+func mirrorRelPath(mirrorDir, workdir, file string) (rel string, translated bool, err error) {
+	rel = file
+	if filepath.IsAbs(file) {
+		if rel, err = filepath.Rel(mirrorDir, file); err != nil {
+			return "", false, err
+		}
+		if workdir = path.Clean(workdir); strings.HasPrefix(rel, "..") && strings.HasPrefix(filepath.ToSlash(file), workdir+"/") {
+			rel, translated = strings.TrimPrefix(filepath.ToSlash(file), workdir+"/"), true
+		}
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false, fmt.Errorf("%s is not inside the mirror %s", file, mirrorDir)
+	}
+	return rel, translated, nil
+}
+
+// mirroredSource recovers the codespace copy of a mirrored instruction file.
+// It drops the provenance header and anything before it other than
+// frontmatter: the session preamble the launcher prepends to
+// .github/copilot-instructions.md. remotePath is the source named in the
+// header, or "" when the file has none; it is not trusted as a write target.
+func mirroredSource(content []byte) (source []byte, remotePath string) {
+	at := bytes.Index(content, []byte(provenanceMarker))
+	if at < 0 {
+		return content, ""
+	}
+	lineStart := bytes.LastIndexByte(content[:at], '\n') + 1
+	lineEnd := len(content)
+	if nl := bytes.IndexByte(content[at:], '\n'); nl >= 0 {
+		lineEnd = at + nl + 1
+	}
+	header := strings.TrimSpace(string(content[at:lineEnd]))
+	header = strings.TrimSuffix(strings.TrimPrefix(header, provenanceMarker), "-->")
+	header = strings.TrimSpace(header)
+	if i := strings.LastIndex(header, " at "); i >= 0 {
+		if _, err := time.Parse(time.RFC3339, header[i+len(" at "):]); err == nil {
+			header = header[:i]
+		}
+	}
+	if _, p, ok := strings.Cut(header, ":"); ok {
+		remotePath = p
+	}
+
+	before := content[:lineStart]
+	eol := []byte("\n")
+	if bytes.Contains(content, []byte("\r\n")) {
+		eol = []byte("\r\n")
+	}
+	out := make([]byte, 0, len(content))
+	// The preamble goes above any frontmatter, so keep the trailing part of
+	// before that is a frontmatter block on its own.
+	for start := 0; start < len(before); {
+		if frontmatterEnd(before[start:], eol) == len(before)-start {
+			out = append(out, before[start:]...)
+			break
+		}
+		nl := bytes.Index(before[start:], eol)
+		if nl < 0 {
+			break
+		}
+		start += nl + len(eol)
+	}
+	return append(out, content[lineEnd:]...), remotePath
+}
+
+// pushCandidate is one mirrored file ready to be written back.
+type pushCandidate struct {
+	relPath    string
+	remotePath string
+	content    []byte
+}
+
+// preparePush reads a mirrored file and works out what to write where. Only
+// instruction files can be pushed: other mirrored files are rewritten on
+// fetch (agent tool lists, hook commands), so their mirror copy is not the
+// codespace's.
+func preparePush(mirrorDir, file, workdir string) (pushCandidate, error) {
+	rel, translated, err := mirrorRelPath(mirrorDir, workdir, file)
+	if err != nil {
+		return pushCandidate{}, err
+	}
+	if translated {
+		progress.Step("push_path_translated", progressFields{"path": rel, "remotePath": file}, "  %s is on the codespace; pushing its mirror copy %s\n", file, rel)
+	}
+	if !isInstructionFile(rel) {
+		return pushCandidate{}, fmt.Errorf("%s is not an instruction file; only AGENTS.md, CLAUDE.md, GEMINI.md, and .github instruction files can be pushed", rel)
+	}
+	data, err := os.ReadFile(filepath.Join(mirrorDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return pushCandidate{}, err
+	}
+	// Copies kept in .local/ go back to the path they were mirrored from.
+	remotePath := path.Join(workdir, strings.TrimPrefix(rel, mirrorLocalDir+"/"))
+	content, headerPath := mirroredSource(data)
+	if headerPath == "" && strings.TrimPrefix(rel, mirrorLocalDir+"/") == ".github/copilot-instructions.md" {
+		return pushCandidate{}, fmt.Errorf("%s has no provenance header, so the session preamble can't be told apart from the file; relaunch without %s=off to push it", rel, provenanceEnv)
+	}
+	// The header is only a check: it is part of an editable file, so it
+	// never picks the write target.
+	if headerPath != "" && path.Clean(headerPath) != remotePath {
+		return pushCandidate{}, fmt.Errorf("%s's provenance header names %s, not %s; refusing to push", rel, headerPath, remotePath)
+	}
+	return pushCandidate{relPath: rel, remotePath: remotePath, content: content}, nil
+}
+
+// readRemoteFile returns the file's content on the codespace; exists is false
+// when it is missing.
+func readRemoteFile(ctx context.Context, runner remoteRunner, p string) (content []byte, exists bool, err error) {
+	stdout, stderr, exitCode, err := runner.Exec(ctx, fmt.Sprintf("if [ -e %[1]s ]; then cat -- %[1]s; else exit 3; fi", shellQuote(p)))
+	if err != nil {
+		return nil, false, err
+	}
+	switch exitCode {
+	case 0:
+		return []byte(stdout), true, nil
+	case 3:
+		return nil, false, nil
+	}
+	return nil, false, fmt.Errorf("reading %s failed (exit %d): %s", p, exitCode, strings.TrimSpace(stderr))
+}
+
+// writeRemoteFile replaces p on the codespace with content, through a
+// temporary file so a failed transfer leaves the original intact.
+func writeRemoteFile(ctx context.Context, runner remoteRunner, p string, content []byte) error {
+	tmp := p + ".push.tmp"
+	cmd := fmt.Sprintf("mkdir -p %s && cat > %s && mv -f %s %s", shellQuote(path.Dir(p)), shellQuote(tmp), shellQuote(tmp), shellQuote(p))
+	_, stderr, exitCode, err := runner.ExecWithInput(ctx, cmd, content)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("writing %s failed (exit %d): %s", p, exitCode, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// unifiedDiff renders the change from old to new with the local diff tool.
+func unifiedDiff(oldLabel, newLabel string, old, new []byte) (string, error) {
+	dir, err := os.MkdirTemp("", "copilot-codespace-push-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := os.WriteFile(oldPath, old, 0o600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(newPath, new, 0o600); err != nil {
+		return "", err
+	}
+	out, err := exec.Command("diff", "-u", "--label", oldLabel, "--label", newLabel, oldPath, newPath).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// Exit code 1 means the files differ.
+		err = nil
+	}
+	return string(out), err
+}
+
+// pushFiles writes each candidate back to the codespace after showing its
+// diff and getting confirm's approval. A nil confirm approves every change
+// (--yes). It returns how many files were written.
+func pushFiles(ctx context.Context, runner remoteRunner, codespaceName string, candidates []pushCandidate, out io.Writer, confirm func(pushCandidate) bool) (int, error) {
+	pushed := 0
+	for _, c := range candidates {
+		old, exists, err := readRemoteFile(ctx, runner, c.remotePath)
+		if err != nil {
+			return pushed, err
+		}
+		if exists && bytes.Equal(old, c.content) {
+			progress.Step("push_unchanged", progressFields{"path": c.relPath, "remotePath": c.remotePath}, "  = %s (unchanged on the codespace)\n", c.relPath)
+			continue
+		}
+		diff, err := unifiedDiff(codespaceName+":"+c.remotePath, "mirror:"+c.relPath, old, c.content)
+		if err != nil {
+			fmt.Fprintf(out, "\n(no diff available: %v)\n", err)
+		} else {
+			fmt.Fprintf(out, "\n%s", diff)
+		}
+		if confirm != nil && !confirm(c) {
+			progress.Step("push_skipped", progressFields{"path": c.relPath, "remotePath": c.remotePath}, "  - %s (skipped)\n", c.relPath)
+			continue
+		}
+		if err := writeRemoteFile(ctx, runner, c.remotePath, c.content); err != nil {
+			return pushed, err
+		}
+		pushed++
+		progress.Step("push_written", progressFields{"path": c.relPath, "remotePath": c.remotePath}, "  ✓ %s → %s\n", c.relPath, c.remotePath)
+	}
+	return pushed, nil
+}
+
+// runPush writes mirrored instruction files back to their source on the
+// codespace, so edits made locally (by hand or by Copilot) reach the repo.
+// Each change is shown as a diff and confirmed unless --yes is passed.
+func runPush(args []string) error {
+	opts, err := parsePushArgs(args)
+	if err != nil {
+		return err
+	}
+	progress = newProgressReporter(progressHuman, os.Stderr, os.Stderr)
+
+	cs, err := lookupCodespace(opts.codespaceName)
+	if err != nil {
+		return err
+	}
+	progress.Step("codespace_selected", progressFields{"codespace": cs.Name, "repository": cs.Repository}, "Codespace: %s (%s)\n", cs.Name, registry.RepositoryLabel(cs.Repository))
+
+	workdir := opts.workdirOverride
+	if workdir == "" {
+		workdir, err = detectWorkdir(cs.Name, cs.Repository)
+		if err != nil {
+			return err
+		}
+	}
+	mirrorDir, err := mirrorDirFor(cs.Name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(sealedMirrorPath(mirrorDir)); err == nil {
+		return fmt.Errorf("the mirror for %s is encrypted until the next session starts; push edits while a session is running", cs.Name)
+	}
+	var candidates []pushCandidate
+	for _, file := range opts.files {
+		c, err := preparePush(mirrorDir, file, workdir)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, c)
+	}
+
+	var confirm func(pushCandidate) bool
+	if !opts.yes {
+		if !isInteractiveTerminal() {
+			return fmt.Errorf("push needs a terminal to confirm each change; pass --yes to push without confirmation")
+		}
+		reader := bufio.NewReader(os.Stdin)
+		confirm = func(c pushCandidate) bool {
+			return promptYesNo(reader, os.Stderr, fmt.Sprintf("Write %s to %s on %s?", c.relPath, c.remotePath, cs.Name))
+		}
+	}
+
+	sshClient := ssh.NewClient(cs.Name)
+	if err := sshClient.SetupMultiplexing(context.Background()); err != nil {
+		progress.Warn("ssh_multiplexing_failed", progressFields{"codespace": cs.Name}, "Warning: SSH multiplexing failed for %s: %v\n", cs.Name, err)
+	}
+	_, err = pushFiles(context.Background(), sshClient, cs.Name, candidates, os.Stderr, confirm)
+	return err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePushArgs(t *testing.T) {
+	opts, err := parsePushArgs([]string{"-c", "cs-app", "--yes", "AGENTS.md", "-w", "/workspaces/app", "docs/CLAUDE.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.codespaceName != "cs-app" || opts.workdirOverride != "/workspaces/app" || !opts.yes || strings.Join(opts.files, ",") != "AGENTS.md,docs/CLAUDE.md" {
+		t.Errorf("opts = %+v", opts)
+	}
+	for _, args := range [][]string{{"AGENTS.md"}, {"-c", "cs-app"}, {"-c", "cs-app", "--force", "AGENTS.md"}} {
+		if _, err := parsePushArgs(args); err == nil {
+			t.Errorf("parsePushArgs(%q) succeeded", args)
+		}
+	}
+}
+
+func TestMirroredSourceRoundTrip(t *testing.T) {
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, original := range []string{
+		"# Agents\n\nBe brief.\n",
+		"---\napplyTo: '**/*.go'\n---\nUse gofmt.\n",
+		"---\r\napplyTo: '**'\r\n---\r\nWindows.\r\n",
+	} {
+		for _, mode := range []provenanceMode{provenanceFull, provenancePath} {
+			mirrored := addProvenanceHeader([]byte(original), mode, "cs-app", "/workspaces/app/AGENTS.md", fetchedAt)
+			got, remotePath := mirroredSource(mirrored)
+			if string(got) != original || remotePath != "/workspaces/app/AGENTS.md" {
+				t.Errorf("mode %v: mirroredSource(%q) = %q, %q", mode, mirrored, got, remotePath)
+			}
+		}
+	}
+}
+
+func TestMirroredSourceStripsPreamble(t *testing.T) {
+	original := "---\ndescription: repo\n---\nRepo rules.\n"
+	mirrored := "# Codespace session\n\nUse remote tools.\n\n" +
+		string(addProvenanceHeader([]byte(original), provenancePath, "cs-app", "/workspaces/app/.github/copilot-instructions.md", time.Time{}))
+	got, remotePath := mirroredSource([]byte(mirrored))
+	if string(got) != original || remotePath != "/workspaces/app/.github/copilot-instructions.md" {
+		t.Errorf("mirroredSource() = %q, %q", got, remotePath)
+	}
+
+	if got, remotePath := mirroredSource([]byte("no header\n")); string(got) != "no header\n" || remotePath != "" {
+		t.Errorf("mirroredSource() without header = %q, %q", got, remotePath)
+	}
+}
+
+func TestPreparePush(t *testing.T) {
+	quietProgress(t)
+	mirror := t.TempDir()
+	os.MkdirAll(filepath.Join(mirror, ".github", "agents"), 0o755)
+	os.WriteFile(filepath.Join(mirror, "AGENTS.md"), []byte("edited\n"), 0o644)
+	os.WriteFile(filepath.Join(mirror, ".github", "copilot-instructions.md"), []byte("# Preamble\nRepo rules.\n"), 0o644)
+	os.WriteFile(filepath.Join(mirror, ".github", "agents", "a.agent.md"), []byte("agent\n"), 0o644)
+
+	c, err := preparePush(mirror, filepath.Join(mirror, "AGENTS.md"), "/workspaces/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.relPath != "AGENTS.md" || c.remotePath != "/workspaces/app/AGENTS.md" || string(c.content) != "edited\n" {
+		t.Errorf("candidate = %+v", c)
+	}
+
+	// A codespace path names the mirror copy of the same file.
+	if c, err := preparePush(mirror, "/workspaces/app/AGENTS.md", "/workspaces/app"); err != nil || c.relPath != "AGENTS.md" || string(c.content) != "edited\n" {
+		t.Errorf("preparePush(workdir path) = %+v, %v", c, err)
+	}
+
+	// The header must agree with the path the file was mirrored to.
+	os.WriteFile(filepath.Join(mirror, "CLAUDE.md"), addProvenanceHeader([]byte("rules\n"), provenancePath, "cs-app", "/etc/profile.d/evil.sh", time.Time{}), 0o644)
+	if _, err := preparePush(mirror, "CLAUDE.md", "/workspaces/app"); err == nil || !strings.Contains(err.Error(), "refusing to push") {
+		t.Errorf("preparePush(redirected header) = %v, want a refusal", err)
+	}
+	os.MkdirAll(filepath.Join(mirror, mirrorLocalDir, "docs"), 0o755)
+	os.WriteFile(filepath.Join(mirror, mirrorLocalDir, "docs", "AGENTS.md"), addProvenanceHeader([]byte("kept\n"), provenancePath, "cs-app", "/workspaces/app/docs/AGENTS.md", time.Time{}), 0o644)
+	if c, err := preparePush(mirror, ".local/docs/AGENTS.md", "/workspaces/app"); err != nil || c.remotePath != "/workspaces/app/docs/AGENTS.md" || string(c.content) != "kept\n" {
+		t.Errorf("preparePush(.local copy) = %+v, %v", c, err)
+	}
+
+	for _, file := range []string{".github/agents/a.agent.md", ".github/copilot-instructions.md", "../AGENTS.md", "/workspaces/other/AGENTS.md", "/workspaces/app"} {
+		if _, err := preparePush(mirror, file, "/workspaces/app"); err == nil {
+			t.Errorf("preparePush(%q) succeeded", file)
+		}
+	}
+}
+
+func TestPushFiles(t *testing.T) {
+	quietProgress(t)
+	remote := t.TempDir()
+	os.WriteFile(filepath.Join(remote, "AGENTS.md"), []byte("old\n"), 0o644)
+	os.WriteFile(filepath.Join(remote, "CLAUDE.md"), []byte("same\n"), 0o644)
+	candidates := []pushCandidate{
+		{relPath: "AGENTS.md", remotePath: filepath.Join(remote, "AGENTS.md"), content: []byte("new\n")},
+		{relPath: "CLAUDE.md", remotePath: filepath.Join(remote, "CLAUDE.md"), content: []byte("same\n")},
+		{relPath: "docs/GEMINI.md", remotePath: filepath.Join(remote, "docs", "GEMINI.md"), content: []byte("created\n")},
+	}
+
+	var out strings.Builder
+	var asked []string
+	confirm := func(c pushCandidate) bool {
+		asked = append(asked, c.relPath)
+		return c.relPath == "AGENTS.md"
+	}
+	pushed, err := pushFiles(context.Background(), &localRunner{}, "cs-app", candidates, &out, confirm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed != 1 || strings.Join(asked, ",") != "AGENTS.md,docs/GEMINI.md" {
+		t.Errorf("pushed %d, asked about %v", pushed, asked)
+	}
+	if got, _ := os.ReadFile(filepath.Join(remote, "AGENTS.md")); string(got) != "new\n" {
+		t.Errorf("AGENTS.md = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "docs", "GEMINI.md")); !os.IsNotExist(err) {
+		t.Errorf("declined file was written: %v", err)
+	}
+	if !strings.Contains(out.String(), "-old\n+new\n") || !strings.Contains(out.String(), "--- cs-app:"+filepath.Join(remote, "AGENTS.md")) {
+		t.Errorf("diff output = %q", out.String())
+	}
+
+	if pushed, err := pushFiles(context.Background(), &localRunner{}, "cs-app", candidates[2:], &out, nil); err != nil || pushed != 1 {
+		t.Fatalf("pushFiles with --yes = %d, %v", pushed, err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(remote, "docs", "GEMINI.md")); string(got) != "created\n" {
+		t.Errorf("docs/GEMINI.md = %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// containerBuiltMarker is created by Docker when the container is created, so
+// its mtime is the last (re)build rather than the last start.
+const containerBuiltMarker = "/.dockerenv"
+
+// rebuildSlack ignores devcontainer files touched shortly after the build,
+// such as by a postCreateCommand.
+const rebuildSlack = time.Minute
+
+// devcontainerState compares the container build time with the newest
+// devcontainer file.
+type devcontainerState struct {
+	built   time.Time
+	changed time.Time
+	path    string
+}
+
+// stale reports whether a devcontainer file changed after the build.
+func (s devcontainerState) stale() bool {
+	return !s.built.IsZero() && s.changed.After(s.built.Add(rebuildSlack))
+}
+
+// devcontainerProbeCommand prints the container build time and the newest
+// file under .devcontainer/ (or .devcontainer.json) in workdir.
+func devcontainerProbeCommand(workdir string) string {
+	wd := shellQuote(workdir)
+	return fmt.Sprintf(`echo "built $(stat -c %%Y %s 2>/dev/null || echo 0)"; `+
+		`find %s/.devcontainer %s/.devcontainer.json -maxdepth 3 -type f -printf 'changed %%T@ %%p\n' 2>/dev/null | sort -k2 -rn | head -1`,
+		containerBuiltMarker, wd, wd)
+}
+
+// parseDevcontainerProbe parses devcontainerProbeCommand output, reporting
+// paths relative to workdir.
+func parseDevcontainerProbe(out, workdir string) devcontainerState {
+	var s devcontainerState
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		switch {
+		case len(fields) == 2 && fields[0] == "built":
+			if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil && secs > 0 {
+				s.built = time.Unix(secs, 0)
+			}
+		case len(fields) == 3 && fields[0] == "changed":
+			if secs, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				s.changed = time.Unix(int64(secs), 0)
+				s.path = strings.TrimPrefix(strings.TrimPrefix(fields[2], strings.TrimSuffix(workdir, "/")), "/")
+			}
+		}
+	}
+	return s
+}
+
+// checkDevcontainerRebuild warns when the codespace's devcontainer files
+// changed since its container was built, because the agent would otherwise
+// work in an environment that no longer matches them. On a terminal it offers
+// to rebuild and reports whether it did, in which case the caller must
+// reconnect.
+func checkDevcontainerRebuild(ctx context.Context, runner remoteRunner, codespaceName, workdir string) bool {
+	stdout, _, exitCode, err := runner.Exec(ctx, devcontainerProbeCommand(workdir))
+	if err != nil || exitCode != 0 {
+		return false
+	}
+	state := parseDevcontainerProbe(stdout, workdir)
+	if !state.stale() {
+		return false
+	}
+
+	age := state.changed.Sub(state.built).Round(time.Minute)
+	progress.Warn("devcontainer_stale", progressFields{"codespace": codespaceName, "path": state.path, "builtAt": state.built.UTC(), "changedAt": state.changed.UTC()},
+		"  ⚠ devcontainer changed since last rebuild: %s was modified %s after the container was built.\n", state.path, age)
+
+	if !isInteractiveTerminal() {
+		progress.Warn("devcontainer_rebuild_hint", progressFields{"codespace": codespaceName},
+			"    Run `gh codespace rebuild -c %s` to apply it.\n", codespaceName)
+		return false
+	}
+	if !promptYesNo(bufio.NewReader(os.Stdin), os.Stderr, fmt.Sprintf("Rebuild %s now? This takes a few minutes and restarts the container.", codespaceName)) {
+		return false
+	}
+	if err := rebuildCodespace(codespaceName); err != nil {
+		progress.Warn("devcontainer_rebuild_failed", progressFields{"codespace": codespaceName}, "  ⚠ Rebuild failed: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// rebuildCodespace rebuilds the container and waits until SSH works again.
+func rebuildCodespace(name string) error {
+	progress.Step("codespace_rebuilding", progressFields{"codespace": name}, "Rebuilding codespace %s...\n", name)
+	cmd := exec.Command("gh", "codespace", "rebuild", "-c", name)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gh codespace rebuild: %w", err)
+	}
+	return startCodespace(name)
+}
+
+// promptYesNo asks question and reports whether the answer was yes. EOF and
+// anything else count as no.
+func promptYesNo(reader *bufio.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(input))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDevcontainerProbe(t *testing.T) {
+	out := "built 1700000000\nchanged 1700007200.123 /workspaces/app/.devcontainer/devcontainer.json\n"
+	s := parseDevcontainerProbe(out, "/workspaces/app/")
+	if s.built.Unix() != 1700000000 || s.changed.Unix() != 1700007200 {
+		t.Errorf("times = %v, %v", s.built, s.changed)
+	}
+	if s.path != ".devcontainer/devcontainer.json" {
+		t.Errorf("path = %q", s.path)
+	}
+	if !s.stale() {
+		t.Error("file changed 2h after the build should be stale")
+	}
+
+	if s := parseDevcontainerProbe("built 0\n", "/workspaces/app"); s.stale() {
+		t.Error("unknown build time should not be stale")
+	}
+	fresh := parseDevcontainerProbe("built 1700000000\nchanged 1700000030 /workspaces/app/.devcontainer.json\n", "/workspaces/app")
+	if fresh.stale() {
+		t.Error("change within the slack after the build should not be stale")
+	}
+}
+
+func TestDevcontainerProbeCommand(t *testing.T) {
+	wd := t.TempDir()
+	dir := filepath.Join(wd, ".devcontainer")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old, newest := filepath.Join(dir, "Dockerfile"), filepath.Join(dir, "devcontainer.json")
+	for _, p := range []string{old, newest} {
+		if err := os.WriteFile(p, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, past, past)
+
+	stdout, _, _, err := (&localRunner{}).Exec(context.Background(), devcontainerProbeCommand(wd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := parseDevcontainerProbe(stdout, wd)
+	if s.path != ".devcontainer/devcontainer.json" {
+		t.Errorf("newest file = %q, want .devcontainer/devcontainer.json (output %q)", s.path, stdout)
+	}
+}
+
+type fixedRunner struct {
+	remoteRunner
+	stdout string
+}
+
+func (r fixedRunner) Exec(context.Context, string) (string, string, int, error) {
+	return r.stdout, "", 0, nil
+}
+
+func TestCheckDevcontainerRebuildWarns(t *testing.T) {
+	var out bytes.Buffer
+	old := progress
+	progress = newProgressReporter(progressJSON, &out, &out)
+	t.Cleanup(func() { progress = old })
+
+	runner := fixedRunner{stdout: "built 1700000000\nchanged 1700003600 /workspaces/app/.devcontainer/devcontainer.json\n"}
+	if checkDevcontainerRebuild(context.Background(), runner, "app-123", "/workspaces/app") {
+		t.Error("should not rebuild without a terminal")
+	}
+	for _, want := range []string{`"event":"devcontainer_stale"`, "devcontainer changed since last rebuild", "gh codespace rebuild -c app-123"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPromptYesNo(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("Y\n\nyes\n"))
+	var out bytes.Buffer
+	got := []bool{promptYesNo(reader, &out, "ok?"), promptYesNo(reader, &out, "ok?"), promptYesNo(reader, &out, "ok?"), promptYesNo(reader, &out, "ok?")}
+	want := []bool{true, false, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("answer %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if !strings.HasPrefix(out.String(), "ok? [y/N]: ") {
+		t.Errorf("prompt = %q", out.String())
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// repoSettingsPath is where a repository tracks its Copilot CLI settings. It
+// is fetched into the mirror with the instruction files.
+const repoSettingsPath = ".copilot/config.json"
+
+// repoSettings are the fields of a repository's Copilot CLI settings that
+// carry over to the local launch. Allow lists are deliberately not read: a
+// repository must not pre-approve tools on the machine running copilot.
+type repoSettings struct {
+	Model       string   `json:"model"`
+	DeniedTools []string `json:"denied_tools"`
+}
+
+// parseRepoSettings decodes a repository settings file, ignoring fields the
+// launcher does not forward.
+func parseRepoSettings(data []byte) (repoSettings, error) {
+	var s repoSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return repoSettings{}, err
+	}
+	s.Model = strings.TrimSpace(s.Model)
+	return s, nil
+}
+
+// repoSettingsArgs returns the copilot flags for s, placed before userArgs.
+// A model given on the command line wins over the repository's.
+func repoSettingsArgs(s repoSettings, userArgs []string) []string {
+	var args []string
+	if s.Model != "" && !hasCopilotFlag(userArgs, "--model") {
+		args = append(args, "--model", s.Model)
+	}
+	for _, tool := range s.DeniedTools {
+		if tool = strings.TrimSpace(tool); tool != "" {
+			args = append(args, "--deny-tool", tool)
+		}
+	}
+	return append(args, userArgs...)
+}
+
+// hasCopilotFlag reports whether args set flag, as "--flag value" or
+// "--flag=value".
+func hasCopilotFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRepoSettings merges the settings file fetched into mirrorDir into the
+// copilot arguments. A missing file leaves args unchanged; an unreadable one
+// is reported and ignored.
+func applyRepoSettings(mirrorDir string, args []string) []string {
+	data, err := os.ReadFile(filepath.Join(mirrorDir, repoSettingsPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return args
+	}
+	var s repoSettings
+	if err == nil {
+		s, err = parseRepoSettings(data)
+	}
+	if err != nil {
+		progress.Warn("repo_settings_invalid", progressFields{"path": repoSettingsPath},
+			"Warning: ignoring %s: %v\n", repoSettingsPath, err)
+		return args
+	}
+	merged := repoSettingsArgs(s, args)
+	if added := merged[:len(merged)-len(args)]; len(added) > 0 {
+		progress.Step("repo_settings_applied", progressFields{"path": repoSettingsPath, "args": added},
+			"  Settings:  %s (from %s)\n", strings.Join(added, " "), repoSettingsPath)
+	}
+	return merged
+}
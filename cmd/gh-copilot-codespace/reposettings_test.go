@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRepoSettings(t *testing.T) {
+	s, err := parseRepoSettings([]byte(`{"model": " gpt-5 ", "denied_tools": ["shell(rm)"], "allowed_tools": ["shell"], "theme": "dark"}`))
+	if err != nil {
+		t.Fatalf("parseRepoSettings() error = %v", err)
+	}
+	want := repoSettings{Model: "gpt-5", DeniedTools: []string{"shell(rm)"}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("parseRepoSettings() = %+v, want %+v", s, want)
+	}
+
+	if _, err := parseRepoSettings([]byte(`{"model": 5}`)); err == nil {
+		t.Error("expected error for a non-string model")
+	}
+}
+
+func TestRepoSettingsArgs(t *testing.T) {
+	s := repoSettings{Model: "gpt-5", DeniedTools: []string{"shell(rm)", " "}}
+	tests := []struct {
+		name     string
+		userArgs []string
+		want     []string
+	}{
+		{
+			name: "no user args",
+			want: []string{"--model", "gpt-5", "--deny-tool", "shell(rm)"},
+		},
+		{
+			name:     "user args follow repo settings",
+			userArgs: []string{"--resume"},
+			want:     []string{"--model", "gpt-5", "--deny-tool", "shell(rm)", "--resume"},
+		},
+		{
+			name:     "user model wins",
+			userArgs: []string{"--model=claude-sonnet-4.5"},
+			want:     []string{"--deny-tool", "shell(rm)", "--model=claude-sonnet-4.5"},
+		},
+		{
+			name:     "model after -- is not a flag",
+			userArgs: []string{"--", "--model"},
+			want:     []string{"--model", "gpt-5", "--deny-tool", "shell(rm)", "--", "--model"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repoSettingsArgs(s, tt.userArgs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("repoSettingsArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRepoSettings(t *testing.T) {
+	quietProgress(t)
+	dir := t.TempDir()
+	args := []string{"--resume"}
+
+	if got := applyRepoSettings(dir, args); !reflect.DeepEqual(got, args) {
+		t.Errorf("without settings file = %q", got)
+	}
+
+	path := filepath.Join(dir, repoSettingsPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := applyRepoSettings(dir, args); !reflect.DeepEqual(got, args) {
+		t.Errorf("with invalid settings file = %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"model": "gpt-5"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--model", "gpt-5", "--resume"}
+	if got := applyRepoSettings(dir, args); !reflect.DeepEqual(got, want) {
+		t.Errorf("with settings file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sendScratchRoot holds, per session, the local files sent with --send.
+const sendScratchRoot = "/tmp/gh-copilot-codespace-context"
+
+// sendSpec is one --send flag: a local file and where it goes on the
+// codespace. An empty or relative remote path is under the session's
+// scratch directory.
+type sendSpec struct {
+	local  string
+	remote string
+}
+
+// parseSendSpec parses PATH[:REMOTE_PATH].
+func parseSendSpec(value string) (sendSpec, error) {
+	local, remote, _ := strings.Cut(value, ":")
+	if local == "" {
+		return sendSpec{}, fmt.Errorf("--send %q: missing local path", value)
+	}
+	return sendSpec{local: local, remote: remote}, nil
+}
+
+// sentFile is a local file uploaded to the codespace.
+type sentFile struct {
+	local  string
+	remote string
+	size   int64
+}
+
+// resolveSendSpecs checks that each local file exists and picks its remote
+// path under scratchDir, before any connection is made.
+func resolveSendSpecs(specs []sendSpec, scratchDir string) ([]sentFile, error) {
+	files := make([]sentFile, 0, len(specs))
+	seen := map[string]string{}
+	for _, spec := range specs {
+		info, err := os.Stat(spec.local)
+		if err != nil {
+			return nil, fmt.Errorf("--send: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("--send %s: not a regular file", spec.local)
+		}
+		remote := spec.remote
+		switch {
+		case remote == "":
+			remote = path.Join(scratchDir, filepath.Base(spec.local))
+		case !path.IsAbs(remote):
+			remote = path.Join(scratchDir, remote)
+		}
+		if other, ok := seen[remote]; ok {
+			return nil, fmt.Errorf("--send: %s and %s would both be written to %s", other, spec.local, remote)
+		}
+		seen[remote] = spec.local
+		files = append(files, sentFile{local: spec.local, remote: remote, size: info.Size()})
+	}
+	return files, nil
+}
+
+// sendFiles uploads files over stdin, each through a ".partial" file that is
+// moved into place once complete. It returns the files that arrived, warning
+// about the rest so the launch can go on without them.
+func sendFiles(ctx context.Context, runner remoteRunner, codespaceName string, files []sentFile) []sentFile {
+	var sent []sentFile
+	for _, f := range files {
+		data, err := os.ReadFile(f.local)
+		if err == nil {
+			partial := shellQuote(f.remote + ".partial")
+			cmd := fmt.Sprintf("mkdir -p %s && cat > %s && mv -f %s %s", shellQuote(path.Dir(f.remote)), partial, partial, shellQuote(f.remote))
+			var stderr string
+			var exitCode int
+			_, stderr, exitCode, err = runner.ExecWithInput(ctx, cmd, data)
+			if err == nil && exitCode != 0 {
+				err = fmt.Errorf("exit %d: %s", exitCode, strings.TrimSpace(stderr))
+			}
+		}
+		if err != nil {
+			progress.Warn("send_failed", progressFields{"codespace": codespaceName, "path": f.local}, "  ⚠ Could not send %s: %v\n", f.local, err)
+			continue
+		}
+		progress.Step("file_sent", progressFields{"codespace": codespaceName, "path": f.local, "remotePath": f.remote, "bytes": f.size},
+			"  Sent:      %s → %s\n", f.local, f.remote)
+		sent = append(sent, f)
+	}
+	return sent
+}
+
+// sentFilesInstructions tells the agent which local files were sent and
+// where they are on the codespace.
+func sentFilesInstructions(codespaceAlias string, sent []sentFile) string {
+	if len(sent) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Files sent from the local machine\n\n")
+	fmt.Fprintf(&sb, "The user sent these files to the %s codespace at launch. Read them with remote_view (or remote_bash for binaries such as images and archives), and apply patches with `git apply`:\n", codespaceAlias)
+	for _, f := range sent {
+		fmt.Fprintf(&sb, "- `%s` (from `%s`, %d bytes)\n", f.remote, filepath.Base(f.local), f.size)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// prependInstructions puts text at the top of the mirror's
+// copilot-instructions.md, creating the file if needed.
+func prependInstructions(mirrorDir, text string) {
+	if text == "" {
+		return
+	}
+	instructionsPath := filepath.Join(mirrorDir, ".github", "copilot-instructions.md")
+	if err := os.MkdirAll(filepath.Dir(instructionsPath), 0o755); err != nil {
+		return
+	}
+	existing, _ := os.ReadFile(instructionsPath)
+	os.WriteFile(instructionsPath, append([]byte(text), existing...), 0o644)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLauncherArgsSend(t *testing.T) {
+	opts, err := parseLauncherArgs([]string{"-c", "cs", "--send", "design.md", "--send", "shot.png:ui/shot.png"})
+	if err != nil {
+		t.Fatalf("parseLauncherArgs() error = %v", err)
+	}
+	want := []sendSpec{{local: "design.md"}, {local: "shot.png", remote: "ui/shot.png"}}
+	if len(opts.sendFiles) != 2 || opts.sendFiles[0] != want[0] || opts.sendFiles[1] != want[1] {
+		t.Errorf("sendFiles = %+v, want %+v", opts.sendFiles, want)
+	}
+	for _, args := range [][]string{
+		{"--no-codespace", "--send", "a.md"},
+		{"--resume", "feature", "--send", "a.md"},
+		{"--send", ":remote.md"},
+	} {
+		if _, err := parseLauncherArgs(args); err == nil {
+			t.Errorf("parseLauncherArgs(%q) error = nil", args)
+		}
+	}
+}
+
+func TestResolveSendSpecs(t *testing.T) {
+	dir := t.TempDir()
+	design := filepath.Join(dir, "design.md")
+	os.WriteFile(design, []byte("# Design"), 0o644)
+	patch := filepath.Join(dir, "fix.patch")
+	os.WriteFile(patch, []byte("diff"), 0o644)
+
+	files, err := resolveSendSpecs([]sendSpec{
+		{local: design},
+		{local: patch, remote: "patches/fix.patch"},
+		{local: design, remote: "/workspaces/app/docs/design.md"},
+	}, "/tmp/ctx/feature")
+	if err != nil {
+		t.Fatalf("resolveSendSpecs() error = %v", err)
+	}
+	want := []string{"/tmp/ctx/feature/design.md", "/tmp/ctx/feature/patches/fix.patch", "/workspaces/app/docs/design.md"}
+	for i, f := range files {
+		if f.remote != want[i] {
+			t.Errorf("files[%d].remote = %q, want %q", i, f.remote, want[i])
+		}
+	}
+	if files[0].size != 8 {
+		t.Errorf("size = %d, want 8", files[0].size)
+	}
+
+	for name, specs := range map[string][]sendSpec{
+		"missing":   {{local: filepath.Join(dir, "nope.md")}},
+		"directory": {{local: dir}},
+		"duplicate": {{local: design}, {local: patch, remote: "design.md"}},
+	} {
+		if _, err := resolveSendSpecs(specs, "/tmp/ctx/feature"); err == nil {
+			t.Errorf("%s: resolveSendSpecs() error = nil", name)
+		}
+	}
+}
+
+func TestSendFiles(t *testing.T) {
+	quietProgress(t)
+	local := filepath.Join(t.TempDir(), "shot.png")
+	os.WriteFile(local, []byte{0x89, 'P', 'N', 'G', 0, 1, 2}, 0o644)
+	remoteDir := t.TempDir()
+
+	files := []sentFile{
+		{local: local, remote: filepath.Join(remoteDir, "ui", "shot.png"), size: 7},
+		{local: local, remote: "/proc/not-writable/shot.png", size: 7},
+	}
+	sent := sendFiles(context.Background(), &localRunner{}, "cs", files)
+	if len(sent) != 1 || sent[0].remote != files[0].remote {
+		t.Fatalf("sent = %+v", sent)
+	}
+	if data, _ := os.ReadFile(files[0].remote); string(data) != "\x89PNG\x00\x01\x02" {
+		t.Errorf("uploaded content = %q", data)
+	}
+	if _, err := os.Stat(files[0].remote + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind: %v", err)
+	}
+}
+
+func TestSentFilesInstructions(t *testing.T) {
+	if sentFilesInstructions("app", nil) != "" {
+		t.Error("expected no note without sent files")
+	}
+	note := sentFilesInstructions("app", []sentFile{{local: "/home/me/design.md", remote: "/tmp/ctx/feature/design.md", size: 120}})
+	if !strings.HasPrefix(note, "## Files sent from the local machine\n") || !strings.Contains(note, "- `/tmp/ctx/feature/design.md` (from `design.md`, 120 bytes)\n") {
+		t.Errorf("note = %q", note)
+	}
+
+	mirror := t.TempDir()
+	os.MkdirAll(filepath.Join(mirror, ".github"), 0o755)
+	os.WriteFile(filepath.Join(mirror, ".github", "copilot-instructions.md"), []byte("repo rules\n"), 0o644)
+	prependInstructions(mirror, note)
+	data, _ := os.ReadFile(filepath.Join(mirror, ".github", "copilot-instructions.md"))
+	if string(data) != note+"repo rules\n" {
+		t.Errorf("instructions = %q", data)
+	}
+}
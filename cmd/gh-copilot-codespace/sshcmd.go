@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+type sshOptions struct {
+	codespaceName string
+	workdir       string
+	command       string
+	printHost     bool
+}
+
+// parseSSHArgs parses arguments for the ssh subcommand. The command comes from
+// --cmd or from everything after "--".
+func parseSSHArgs(args []string) (sshOptions, error) {
+	var opts sshOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
+			opts.codespaceName = args[i+1]
+			i++
+		case (args[i] == "--workdir" || args[i] == "-w") && i+1 < len(args):
+			opts.workdir = args[i+1]
+			i++
+		case args[i] == "--cmd" && i+1 < len(args):
+			opts.command = args[i+1]
+			i++
+		case args[i] == "--":
+			if opts.command != "" {
+				return sshOptions{}, fmt.Errorf("use either --cmd or -- COMMAND, not both")
+			}
+			opts.command = strings.Join(args[i+1:], " ")
+			i = len(args)
+		default:
+			return sshOptions{}, fmt.Errorf("unknown ssh argument %q", args[i])
+		}
+	}
+	if opts.codespaceName == "" {
+		return sshOptions{}, fmt.Errorf("ssh requires --codespace NAME")
+	}
+	return opts, nil
+}
+
+// parseSSHConfigArgs parses arguments for the ssh-config subcommand.
+func parseSSHConfigArgs(args []string) (sshOptions, error) {
+	var opts sshOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
+			opts.codespaceName = args[i+1]
+			i++
+		case args[i] == "--host":
+			opts.printHost = true
+		default:
+			return sshOptions{}, fmt.Errorf("unknown ssh-config argument %q", args[i])
+		}
+	}
+	if opts.codespaceName == "" {
+		return sshOptions{}, fmt.Errorf("ssh-config requires --codespace NAME")
+	}
+	return opts, nil
+}
+
+// connectShared resolves a codespace and makes sure its shared SSH master is
+// up, reusing the one a running session already opened. Progress goes to
+// stderr so stdout stays clean for the remote command or printed path.
+func connectShared(ctx context.Context, name string) (*ssh.Client, error) {
+	progress = newProgressReporter(progressHuman, os.Stderr, os.Stderr)
+	cs, err := lookupCodespace(name)
+	if err != nil {
+		return nil, err
+	}
+	if cs.State != "Available" {
+		if err := startCodespace(cs.Name); err != nil {
+			return nil, err
+		}
+	}
+	client := ssh.NewClient(cs.Name)
+	if err := client.SetupMultiplexing(ctx); errors.Is(err, ssh.ErrHostKeyChanged) {
+		return nil, err
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: SSH multiplexing failed for %s: %v\n", cs.Name, err)
+	}
+	return client, nil
+}
+
+// runSSHConfig prints the generated SSH config path (or its Host alias with
+// --host), so `ssh -F "$(gh copilot-codespace ssh-config -c NAME)" HOST`
+// reuses the session's ControlMaster.
+func runSSHConfig(args []string) error {
+	opts, err := parseSSHConfigArgs(args)
+	if err != nil {
+		return err
+	}
+	client, err := connectShared(context.Background(), opts.codespaceName)
+	if err != nil {
+		return err
+	}
+	if client.SSHConfigPath() == "" {
+		return fmt.Errorf("no shared SSH connection for %s; SSH multiplexing could not be set up", opts.codespaceName)
+	}
+	if opts.printHost {
+		fmt.Println(client.SSHHost())
+		return nil
+	}
+	fmt.Println(client.SSHConfigPath())
+	return nil
+}
+
+// runSSH opens a shell or runs a command on the codespace through the shared
+// SSH master and returns the remote exit code.
+func runSSH(args []string) (int, error) {
+	opts, err := parseSSHArgs(args)
+	if err != nil {
+		return 1, err
+	}
+	ctx := context.Background()
+	client, err := connectShared(ctx, opts.codespaceName)
+	if err != nil {
+		return 1, err
+	}
+
+	command := opts.command
+	tty := command == "" || isInteractiveTerminal()
+	if opts.workdir != "" {
+		if command == "" {
+			command = `exec "${SHELL:-bash}" -l`
+		}
+		command = fmt.Sprintf("cd %s && %s", shellQuote(opts.workdir), command)
+	}
+
+	cmd := client.InteractiveCommand(ctx, command, tty)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSSHArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    sshOptions
+		wantErr bool
+	}{
+		{
+			name: "interactive shell",
+			args: []string{"-c", "cs-1"},
+			want: sshOptions{codespaceName: "cs-1"},
+		},
+		{
+			name: "cmd flag with workdir",
+			args: []string{"--codespace", "cs-1", "-w", "/workspaces/app", "--cmd", "make test"},
+			want: sshOptions{codespaceName: "cs-1", workdir: "/workspaces/app", command: "make test"},
+		},
+		{
+			name: "command after double dash",
+			args: []string{"-c", "cs-1", "--", "git", "status", "--short"},
+			want: sshOptions{codespaceName: "cs-1", command: "git status --short"},
+		},
+		{
+			name:    "cmd and double dash",
+			args:    []string{"-c", "cs-1", "--cmd", "ls", "--", "pwd"},
+			wantErr: true,
+		},
+		{
+			name:    "missing codespace",
+			args:    []string{"--cmd", "ls"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"-c", "cs-1", "--tty"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSSHArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSSHConfigArgs(t *testing.T) {
+	got, err := parseSSHConfigArgs([]string{"-c", "cs-1", "--host"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (sshOptions{codespaceName: "cs-1", printHost: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := parseSSHConfigArgs([]string{"--host"}); err == nil {
+		t.Error("expected error without --codespace")
+	}
+	if _, err := parseSSHConfigArgs([]string{"-c", "cs-1", "--cmd", "ls"}); err == nil {
+		t.Error("expected error for ssh-only flag")
+	}
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	"github.com/ekroon/gh-copilot-codespace/internal/sshtest"
+)
+
+// These tests run the fetch pipeline against a throwaway local sshd using the
+// same fixtures as the codespace-backed suite, so no GitHub infrastructure is needed.
+// Run: go test -tags integration -run LocalSSHD -v ./cmd/gh-copilot-codespace/
+
+func localSSHDWithFixtures(t *testing.T) (*ssh.Client, string) {
+	t.Helper()
+	srv := sshtest.Start(t)
+	client := srv.Client("local-sshd")
+	wd := t.TempDir()
+	client.SetWorkdir(wd)
+
+	stdout, stderr, exitCode, err := client.Exec(context.Background(), "bash -c "+shellQuote(testFixturesScript(wd)))
+	if err != nil || exitCode != 0 || !strings.Contains(stdout, "fixtures-ok") {
+		t.Fatalf("fixture setup failed (exit %d, err %v)\nstdout: %s\nstderr: %s", exitCode, err, stdout, stderr)
+	}
+	// Keep the mirror directory out of the real ~/.copilot.
+	t.Setenv("HOME", t.TempDir())
+	return client, wd
+}
+
+func TestLocalSSHD_FetchInstructionFiles(t *testing.T) {
+	client, wd := localSSHDWithFixtures(t)
+
+	dir, _, err := fetchInstructionFiles(client, "local-sshd", wd, "", nil)
+	if err != nil {
+		t.Fatalf("fetchInstructionFiles: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	expectFile(t, dir, ".github/copilot-instructions.md")
+	expectFile(t, dir, "AGENTS.md")
+	expectFile(t, dir, "docs/AGENTS.md")
+	expectFile(t, dir, "teams/backend/AGENTS.md")
+	expectFile(t, dir, ".github/instructions/ruby.instructions.md")
+}
+
+func TestLocalSSHD_ExecRoundTrip(t *testing.T) {
+	client, wd := localSSHDWithFixtures(t)
+
+	out, err := execSSH(client, "local-sshd", "cat "+shellQuote(wd+"/AGENTS.md"))
+	if err != nil {
+		t.Fatalf("execSSH: %v", err)
+	}
+	if !strings.Contains(out, "Root AGENTS") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
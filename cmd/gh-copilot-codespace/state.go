@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateBundleVersion is bumped when the bundle layout changes incompatibly.
+const stateBundleVersion = 1
+
+// stateBundle is the launcher's local state as written by export-state. Paths
+// are relative to a state root and prefixed with its name, so a bundle can be
+// imported under a different home directory.
+type stateBundle struct {
+	Version  int               `json:"version"`
+	Exported time.Time         `json:"exported"`
+	Files    map[string][]byte `json:"files"`
+	// TrustedFolders are the mirror and workspace directories Copilot's
+	// config.json trusts.
+	TrustedFolders []string `json:"trustedFolders,omitempty"`
+}
+
+// stateRoots maps each bundle path prefix to its local directory.
+type stateRoots map[string]string
+
+// statePatterns select the files worth carrying to a new machine: pinned host
+// keys, file owner overrides, mirror summaries, workspace sessions, and the
+// provisioner and audit configs. Mirrored files themselves are fetched again
+// at launch, and SSH configs and sockets are specific to the machine.
+var statePatterns = []string{
+	"copilot/codespace-workdirs/.known_hosts-*",
+	"copilot/codespace-workdirs/.file-owner-*",
+	"copilot/codespace-workdirs/*/" + mirrorSummaryFile,
+	"copilot/workspaces/*/workspace.json",
+	"config/*.json",
+}
+
+// trustedStateDirs are the directories, relative to the copilot root, whose
+// subdirectories the launcher adds to Copilot's trusted folders.
+var trustedStateDirs = []string{"codespace-workdirs", "workspaces"}
+
+// defaultStateRoots returns ~/.copilot and the copilot-codespace config
+// directory under $XDG_CONFIG_HOME (default ~/.config).
+func defaultStateRoots() (stateRoots, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home dir: %w", err)
+	}
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return stateRoots{
+		"copilot": filepath.Join(homeDir, ".copilot"),
+		"config":  filepath.Join(configDir, "copilot-codespace"),
+	}, nil
+}
+
+// local returns the local path for a bundle path, or an error if the path
+// names no known root or escapes it.
+func (r stateRoots) local(bundlePath string) (string, error) {
+	root, rest, _ := strings.Cut(bundlePath, "/")
+	dir, ok := r[root]
+	clean := filepath.Clean(filepath.FromSlash(rest))
+	if !ok || rest == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q in state bundle", bundlePath)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// stateFileAllowed reports whether a bundle file path matches statePatterns,
+// so an edited bundle cannot write anywhere else under the roots.
+func stateFileAllowed(bundlePath string) bool {
+	if path.Clean(bundlePath) != bundlePath || slices.Contains(strings.Split(bundlePath, "/"), "..") {
+		return false
+	}
+	for _, pattern := range statePatterns {
+		if ok, _ := path.Match(pattern, bundlePath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedFolderAllowed reports whether a bundle folder is a direct
+// subdirectory of one of trustedStateDirs.
+func trustedFolderAllowed(bundlePath string) bool {
+	parent, name := path.Split(bundlePath)
+	if path.Clean(bundlePath) != bundlePath || name == ".." {
+		return false
+	}
+	for _, d := range trustedStateDirs {
+		if parent == "copilot/"+d+"/" {
+			return true
+		}
+	}
+	return false
+}
+
+// collectState reads the files matched by statePatterns and the trusted
+// folders that live under the copilot root.
+func collectState(roots stateRoots) (*stateBundle, error) {
+	bundle := &stateBundle{Version: stateBundleVersion, Exported: time.Now().UTC(), Files: map[string][]byte{}}
+	for _, pattern := range statePatterns {
+		root, rest, _ := strings.Cut(pattern, "/")
+		matches, err := filepath.Glob(filepath.Join(roots[root], filepath.FromSlash(rest)))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			rel, _ := filepath.Rel(roots[root], path)
+			bundle.Files[root+"/"+filepath.ToSlash(rel)] = data
+		}
+	}
+
+	trusted, err := readTrustedFolders(filepath.Join(roots["copilot"], "config.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range trusted {
+		rel, err := filepath.Rel(roots["copilot"], dir)
+		if err != nil {
+			continue
+		}
+		parent, name, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		for _, d := range trustedStateDirs {
+			if parent == d && name != "" && !strings.Contains(name, "/") {
+				bundle.TrustedFolders = append(bundle.TrustedFolders, "copilot/"+filepath.ToSlash(rel))
+			}
+		}
+	}
+	sort.Strings(bundle.TrustedFolders)
+	return bundle, nil
+}
+
+// readTrustedFolders returns the trusted_folders entries of Copilot's
+// config.json, or none if the file does not exist.
+func readTrustedFolders(configPath string) ([]string, error) {
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config struct {
+		TrustedFolders []string `json:"trusted_folders"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", configPath, err)
+	}
+	return config.TrustedFolders, nil
+}
+
+// stateImport is the outcome of applyState.
+type stateImport struct {
+	written []string // bundle paths written
+	skipped []string // bundle paths that already existed with different content
+	trusted int      // folders added to, or already in, Copilot's trusted folders
+}
+
+// applyState writes the bundle's files under roots and trusts its folders.
+// Existing files that differ are left alone unless force is set. Trusting
+// needs Copilot's config.json, which only exists once Copilot has run.
+func applyState(bundle *stateBundle, roots stateRoots, force bool) (stateImport, error) {
+	var result stateImport
+	if bundle.Version != stateBundleVersion {
+		return result, fmt.Errorf("unsupported state bundle version %d (this build reads version %d)", bundle.Version, stateBundleVersion)
+	}
+	paths := make([]string, 0, len(bundle.Files))
+	for p := range bundle.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if !stateFileAllowed(p) {
+			return result, fmt.Errorf("state bundle path %q is not one export-state writes", p)
+		}
+		target, err := roots.local(p)
+		if err != nil {
+			return result, err
+		}
+		if existing, err := os.ReadFile(target); err == nil {
+			if bytes.Equal(existing, bundle.Files[p]) {
+				continue
+			}
+			if !force {
+				result.skipped = append(result.skipped, p)
+				continue
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return result, err
+		}
+		// Configs may hold secrets, such as an audit signing key.
+		if err := os.WriteFile(target, bundle.Files[p], 0o600); err != nil {
+			return result, err
+		}
+		result.written = append(result.written, p)
+	}
+
+	if len(bundle.TrustedFolders) == 0 {
+		return result, nil
+	}
+	if _, err := os.Stat(filepath.Join(roots["copilot"], "config.json")); err != nil {
+		return result, fmt.Errorf("no Copilot config.json in %s; run copilot once, then import again to trust %d folders", roots["copilot"], len(bundle.TrustedFolders))
+	}
+	for _, p := range bundle.TrustedFolders {
+		if !trustedFolderAllowed(p) {
+			return result, fmt.Errorf("trusted folder %q in state bundle is not a mirror or workspace directory", p)
+		}
+		dir, err := roots.local(p)
+		if err != nil {
+			return result, err
+		}
+		// Copilot trusts folders by path, so the mirror directory needs to
+		// exist before the first launch fills it.
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return result, err
+		}
+		if err := ensureTrustedFolder(dir); err != nil {
+			return result, fmt.Errorf("trusting %s: %w", dir, err)
+		}
+		result.trusted++
+	}
+	return result, nil
+}
+
+// runExportState writes the launcher's state bundle to a file, or stdout
+// for "-".
+func runExportState(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: export-state FILE (or - for stdout)")
+	}
+	roots, err := defaultStateRoots()
+	if err != nil {
+		return err
+	}
+	bundle, err := collectState(roots)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if args[0] == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[0], data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d files and %d trusted folders to %s\n", len(bundle.Files), len(bundle.TrustedFolders), args[0])
+	return nil
+}
+
+// runImportState applies a bundle written by export-state, from a file or
+// stdin for "-".
+func runImportState(args []string) error {
+	var file string
+	force := false
+	for _, arg := range args {
+		switch {
+		case arg == "--force":
+			force = true
+		case file == "" && (arg == "-" || !strings.HasPrefix(arg, "-")):
+			file = arg
+		default:
+			return fmt.Errorf("unknown import-state argument %q", arg)
+		}
+	}
+	if file == "" {
+		return fmt.Errorf("usage: import-state [--force] FILE (or - for stdin)")
+	}
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	var bundle stateBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("parsing state bundle: %w", err)
+	}
+	roots, err := defaultStateRoots()
+	if err != nil {
+		return err
+	}
+
+	result, err := applyState(&bundle, roots, force)
+	for _, p := range result.skipped {
+		fmt.Fprintf(os.Stderr, "Skipped %s: a different local copy exists (use --force to replace it)\n", p)
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d files; %d skipped; %d folders trusted\n", len(result.written), len(result.skipped), result.trusted)
+	return err
+}
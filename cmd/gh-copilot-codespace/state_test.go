@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stateHome points the home and config directories at a fresh temp dir.
+func stateHome(t *testing.T) (string, stateRoots) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	roots, err := defaultStateRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return home, roots
+}
+
+func writeStateFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	oldHome, oldRoots := stateHome(t)
+	workdirs := filepath.Join(oldHome, ".copilot", "codespace-workdirs")
+	writeStateFile(t, filepath.Join(workdirs, ".known_hosts-cs-app"), "cs-app ssh-ed25519 AAAA\n")
+	writeStateFile(t, filepath.Join(workdirs, ".ssh-config-cs-app"), "Host cs.app\n")
+	writeStateFile(t, filepath.Join(workdirs, "cs-app", mirrorSummaryFile), `{"instructions":2}`)
+	writeStateFile(t, filepath.Join(workdirs, "cs-app", "AGENTS.md"), "mirrored")
+	writeStateFile(t, filepath.Join(oldHome, ".copilot", "workspaces", "feature", "workspace.json"), `{"codespaces":{}}`)
+	writeStateFile(t, filepath.Join(oldHome, ".config", "copilot-codespace", "provisioners.json"), `{"provisioners":[]}`)
+	config, _ := json.Marshal(map[string]any{"trusted_folders": []string{
+		filepath.Join(workdirs, "cs-app"),
+		filepath.Join(oldHome, ".copilot", "workspaces", "feature"),
+		"/home/me/src/project",
+	}})
+	writeStateFile(t, filepath.Join(oldHome, ".copilot", "config.json"), string(config))
+
+	bundle, err := collectState(oldRoots)
+	if err != nil {
+		t.Fatalf("collectState() error = %v", err)
+	}
+	var files []string
+	for p := range bundle.Files {
+		files = append(files, p)
+	}
+	wantFiles := []string{
+		"config/provisioners.json",
+		"copilot/codespace-workdirs/.known_hosts-cs-app",
+		"copilot/codespace-workdirs/cs-app/" + mirrorSummaryFile,
+		"copilot/workspaces/feature/workspace.json",
+	}
+	if strings.Join(sortedStrings(files), ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("files = %v, want %v", sortedStrings(files), wantFiles)
+	}
+	if want := []string{"copilot/codespace-workdirs/cs-app", "copilot/workspaces/feature"}; !reflect.DeepEqual(bundle.TrustedFolders, want) {
+		t.Errorf("trusted folders = %v, want %v", bundle.TrustedFolders, want)
+	}
+
+	// Import on a machine with a different home and Copilot already set up.
+	newHome, newRoots := stateHome(t)
+	writeStateFile(t, filepath.Join(newHome, ".copilot", "config.json"), `{"trusted_folders":[]}`)
+	result, err := applyState(bundle, newRoots, false)
+	if err != nil {
+		t.Fatalf("applyState() error = %v", err)
+	}
+	if len(result.written) != 4 || result.trusted != 2 {
+		t.Errorf("result = %+v", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(newHome, ".copilot", "codespace-workdirs", ".known_hosts-cs-app")); string(data) != "cs-app ssh-ed25519 AAAA\n" {
+		t.Errorf("known hosts = %q", data)
+	}
+	trusted, _ := readTrustedFolders(filepath.Join(newHome, ".copilot", "config.json"))
+	if want := []string{filepath.Join(newHome, ".copilot", "codespace-workdirs", "cs-app"), filepath.Join(newHome, ".copilot", "workspaces", "feature")}; !reflect.DeepEqual(trusted, want) {
+		t.Errorf("trusted after import = %v, want %v", trusted, want)
+	}
+
+	// A second import is a no-op; a differing local file is kept unless forced.
+	writeStateFile(t, filepath.Join(newHome, ".config", "copilot-codespace", "provisioners.json"), `{"provisioners":[{"name":"local"}]}`)
+	result, err = applyState(bundle, newRoots, false)
+	if err != nil || len(result.written) != 0 || !reflect.DeepEqual(result.skipped, []string{"config/provisioners.json"}) {
+		t.Errorf("second import = %+v, %v", result, err)
+	}
+	trusted, _ = readTrustedFolders(filepath.Join(newHome, ".copilot", "config.json"))
+	if len(trusted) != 2 {
+		t.Errorf("trusted folders duplicated: %v", trusted)
+	}
+	if result, err = applyState(bundle, newRoots, true); err != nil || !reflect.DeepEqual(result.written, []string{"config/provisioners.json"}) {
+		t.Errorf("forced import = %+v, %v", result, err)
+	}
+}
+
+func TestApplyStateRejectsBadBundles(t *testing.T) {
+	_, roots := stateHome(t)
+	if _, err := applyState(&stateBundle{Version: 99}, roots, false); err == nil {
+		t.Error("accepted an unknown version")
+	}
+	for _, p := range []string{"copilot/../../.ssh/authorized_keys", "home/.bashrc", "config/", "copilot//etc/passwd",
+		"copilot/config.json", "copilot/mcp-config.json", "copilot/agents/evil.agent.md", "config/hooks/pre.sh",
+		"copilot/codespace-workdirs/cs-app/AGENTS.md", "copilot/codespace-workdirs/../" + mirrorSummaryFile} {
+		bundle := &stateBundle{Version: stateBundleVersion, Files: map[string][]byte{p: []byte("x")}}
+		if _, err := applyState(bundle, roots, false); err == nil {
+			t.Errorf("accepted path %q", p)
+		}
+	}
+	for _, p := range []string{"copilot/agents/x", "copilot/codespace-workdirs", "copilot/codespace-workdirs/..", "copilot/codespace-workdirs/cs-app/sub"} {
+		if trustedFolderAllowed(p) {
+			t.Errorf("allowed trusted folder %q", p)
+		}
+	}
+	bundle := &stateBundle{Version: stateBundleVersion, TrustedFolders: []string{"copilot/codespace-workdirs/cs-app"}}
+	if _, err := applyState(bundle, roots, false); err == nil || !strings.Contains(err.Error(), "run copilot once") {
+		t.Errorf("missing config.json: %v", err)
+	}
+}
+
+func sortedStrings(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+)
+
+// sessionDirEnv points statusline and hook commands that Copilot runs at the
+// session's workspace directory, which holds the .codespace state files.
+const sessionDirEnv = "COPILOT_CODESPACE_SESSION_DIR"
+
+// statuslineProbeTimeout bounds each SSH probe so a dead master cannot stall
+// the statusline.
+const statuslineProbeTimeout = 2 * time.Second
+
+// connectionProbe reports whether a codespace's shared SSH master is up and,
+// if so, its current branch.
+type connectionProbe func(ctx context.Context, conn mcp.CodespaceConnection) (fast bool, branch string)
+
+// probeSharedSSH checks the ControlMaster with `ssh -O check` and reads the
+// branch over it. Without a live master it returns quickly instead of paying
+// for a gh codespace ssh round trip.
+func probeSharedSSH(ctx context.Context, conn mcp.CodespaceConnection) (bool, string) {
+	if conn.SSHConfig == "" {
+		return false, ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, statuslineProbeTimeout)
+	defer cancel()
+	if exec.CommandContext(ctx, "ssh", "-F", conn.SSHConfig, "-O", "check", conn.SSHHost).Run() != nil {
+		return false, ""
+	}
+	if conn.Workdir == "" {
+		return true, ""
+	}
+	out, err := exec.CommandContext(ctx, "ssh", "-F", conn.SSHConfig, "-o", "BatchMode=yes", conn.SSHHost,
+		"git -C "+shellQuote(conn.Workdir)+" rev-parse --abbrev-ref HEAD 2>/dev/null").Output()
+	if err != nil {
+		return true, ""
+	}
+	return true, strings.TrimSpace(string(out))
+}
+
+// runStatusline prints one line describing the session's codespaces for a
+// Copilot statusline command.
+func runStatusline(args []string) error {
+	dir := os.Getenv(sessionDirEnv)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--dir" && i+1 < len(args):
+			dir = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("unknown statusline argument %q", args[i])
+		}
+	}
+	if dir == "" {
+		dir = "."
+	}
+
+	var conn mcp.ConnectionStatus
+	if data, err := os.ReadFile(filepath.Join(dir, mcp.ConnectionFileName)); err == nil {
+		if err := json.Unmarshal(data, &conn); err != nil {
+			return fmt.Errorf("reading %s: %w", mcp.ConnectionFileName, err)
+		}
+	}
+	var last *mcp.CommandStatus
+	if data, err := os.ReadFile(filepath.Join(dir, mcp.StatusFileName)); err == nil {
+		var st mcp.CommandStatus
+		if json.Unmarshal(data, &st) == nil {
+			last = &st
+		}
+	}
+
+	fmt.Println(formatStatusline(context.Background(), conn.Codespaces, last, probeSharedSSH))
+	return nil
+}
+
+// formatStatusline renders the codespaces as "⬢ alias (branch) · fast ssh",
+// separated by " | ", followed by the running remote command if any.
+func formatStatusline(ctx context.Context, codespaces []mcp.CodespaceConnection, last *mcp.CommandStatus, probe connectionProbe) string {
+	if len(codespaces) == 0 {
+		return "⬢ no codespace connected"
+	}
+	parts := make([]string, 0, len(codespaces))
+	for _, cs := range codespaces {
+		fast, branch := probe(ctx, cs)
+		if branch == "" {
+			branch = cs.Branch
+		}
+		label := "⬢ " + cs.Alias
+		if cs.Alias != cs.Name {
+			label += " [" + cs.Name + "]"
+		}
+		if branch != "" {
+			label += " (" + branch + ")"
+		}
+		if fast {
+			label += " · fast ssh"
+		} else {
+			label += " · gh ssh"
+		}
+		parts = append(parts, label)
+	}
+	line := strings.Join(parts, " | ")
+	if last != nil && last.State == "running" {
+		cmd := last.Command
+		if r := []rune(cmd); len(r) > 40 {
+			cmd = string(r[:37]) + "..."
+		}
+		line += " · ▶ " + cmd
+	}
+	return line
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/mcp"
+)
+
+func TestFormatStatusline(t *testing.T) {
+	probe := func(_ context.Context, conn mcp.CodespaceConnection) (bool, string) {
+		if conn.SSHConfig == "" {
+			return false, ""
+		}
+		return true, "feature-x"
+	}
+
+	if got := formatStatusline(context.Background(), nil, nil, probe); got != "⬢ no codespace connected" {
+		t.Errorf("empty statusline = %q", got)
+	}
+
+	codespaces := []mcp.CodespaceConnection{
+		{Alias: "app", Name: "app", Branch: "main", SSHConfig: "/tmp/cfg", SSHHost: "cs.app"},
+		{Alias: "api", Name: "api-456", Branch: "develop"},
+	}
+	want := "⬢ app (feature-x) · fast ssh | ⬢ api [api-456] (develop) · gh ssh"
+	if got := formatStatusline(context.Background(), codespaces, nil, probe); got != want {
+		t.Errorf("statusline = %q, want %q", got, want)
+	}
+
+	running := &mcp.CommandStatus{Command: "go test ./... -run TestSomethingWithAVeryLongName -count=1", State: "running"}
+	got := formatStatusline(context.Background(), codespaces[:1], running, probe)
+	if !strings.HasSuffix(got, " · ▶ go test ./... -run TestSomethingWithA...") {
+		t.Errorf("running command not shown truncated: %q", got)
+	}
+
+	exited := &mcp.CommandStatus{Command: "make", State: "exited"}
+	if got := formatStatusline(context.Background(), codespaces[:1], exited, probe); strings.Contains(got, "make") {
+		t.Errorf("finished command should not be shown: %q", got)
+	}
+}
+
+func TestProbeSharedSSHWithoutMaster(t *testing.T) {
+	fast, branch := probeSharedSSH(context.Background(), mcp.CodespaceConnection{Alias: "app"})
+	if fast || branch != "" {
+		t.Errorf("probe without SSH config = %v, %q; want false, empty", fast, branch)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+const (
+	// transferAttempts is how often each method is tried before falling back.
+	transferAttempts = 3
+	// transferChunkSize is the raw size of one base64 chunk.
+	transferChunkSize = 1 << 20
+)
+
+// transferBackoff is the delay before the second attempt of a method; it
+// doubles for each further attempt. Tests shorten it.
+var transferBackoff = time.Second
+
+// remoteRunner runs commands on the codespace. *ssh.Client implements it.
+type remoteRunner interface {
+	Exec(ctx context.Context, command string) (stdout string, stderr string, exitCode int, err error)
+	ExecWithInput(ctx context.Context, command string, input []byte) (stdout string, stderr string, exitCode int, err error)
+}
+
+// binaryTransfer copies a local file to a remote path.
+type binaryTransfer struct {
+	name string
+	copy func(ctx context.Context, localPath, remotePath string) error
+}
+
+// binaryTransfers returns the upload methods in order of preference: sftp over
+// the shared SSH master, gh codespace cp, then chunked base64 over SSH, which
+// resumes a partial upload instead of starting over.
+func binaryTransfers(sshClient *ssh.Client, codespaceName string) []binaryTransfer {
+	var transfers []binaryTransfer
+	if sshClient.SSHConfigPath() != "" {
+		if _, err := exec.LookPath("sftp"); err == nil {
+			transfers = append(transfers, binaryTransfer{"sftp", func(ctx context.Context, localPath, remotePath string) error {
+				cmd := exec.CommandContext(ctx, "sftp", "-F", sshClient.SSHConfigPath(), "-b", "-", sshClient.SSHHost())
+				cmd.Stdin = strings.NewReader(fmt.Sprintf("put %q %q\n", localPath, remotePath))
+				if out, err := cmd.CombinedOutput(); err != nil {
+					return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+				}
+				return nil
+			}})
+		}
+	}
+	transfers = append(transfers,
+		binaryTransfer{"gh codespace cp", func(ctx context.Context, localPath, remotePath string) error {
+			cmd := exec.CommandContext(ctx, "gh", "codespace", "cp", "-c", codespaceName, localPath, "remote:"+remotePath)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		}},
+		binaryTransfer{"chunked base64", func(ctx context.Context, localPath, remotePath string) error {
+			return chunkedUpload(ctx, sshClient, localPath, remotePath)
+		}},
+	)
+	return transfers
+}
+
+// transferBinary installs localPath at remotePath with the first method that
+// succeeds, retrying each with backoff, and returns the method's name. Uploads
+// land in a ".partial" file that must match the local SHA-256 before it is
+// made executable and moved into place, so a broken transfer never leaves a
+// truncated agent behind.
+func transferBinary(ctx context.Context, runner remoteRunner, transfers []binaryTransfer, localPath, remotePath string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", fmt.Errorf("reading binary: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	partial := remotePath + ".partial"
+
+	if _, stderr, exitCode, err := runner.Exec(ctx, "mkdir -p "+shellQuote(path.Dir(remotePath))); err != nil || exitCode != 0 {
+		return "", fmt.Errorf("creating %s: %v %s", path.Dir(remotePath), err, strings.TrimSpace(stderr))
+	}
+
+	var errs []string
+	for _, t := range transfers {
+		for attempt := 1; attempt <= transferAttempts; attempt++ {
+			if attempt > 1 {
+				select {
+				case <-time.After(transferBackoff << (attempt - 2)):
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+			}
+			err := t.copy(ctx, localPath, partial)
+			if err == nil {
+				err = installPartial(ctx, runner, partial, remotePath, hex.EncodeToString(sum[:]))
+			}
+			if err == nil {
+				return t.name, nil
+			}
+			progress.Warn("deploy_retry", progressFields{"method": t.name, "attempt": attempt}, "  ⚠ Deploy via %s failed (attempt %d/%d): %v\n", t.name, attempt, transferAttempts, err)
+			if attempt == transferAttempts {
+				errs = append(errs, fmt.Sprintf("%s: %v", t.name, err))
+			}
+		}
+	}
+	return "", fmt.Errorf("all transfer methods failed (%s)", strings.Join(errs, "; "))
+}
+
+// installPartial verifies the uploaded file and moves it into place. A file
+// with the wrong checksum is removed so the next attempt starts clean.
+func installPartial(ctx context.Context, runner remoteRunner, partial, remotePath, wantSum string) error {
+	cmd := fmt.Sprintf(`[ "$(sha256sum %[1]s | cut -d' ' -f1)" = %[2]s ] && chmod +x %[1]s && mv -f %[1]s %[3]s || { rm -f %[1]s; exit 1; }`,
+		shellQuote(partial), wantSum, shellQuote(remotePath))
+	_, stderr, exitCode, err := runner.Exec(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("checksum mismatch after upload %s", strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// chunkedUpload appends base64 chunks to remotePath over stdin. It resumes
+// after the last complete chunk already on the codespace, and each chunk
+// truncates the file to its own offset first, so retrying a chunk is safe.
+func chunkedUpload(ctx context.Context, runner remoteRunner, localPath, remotePath string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	quoted := shellQuote(remotePath)
+
+	offset := 0
+	if stdout, _, exitCode, err := runner.Exec(ctx, fmt.Sprintf("stat -c %%s %s 2>/dev/null || echo 0", quoted)); err == nil && exitCode == 0 {
+		if size, err := strconv.Atoi(strings.TrimSpace(stdout)); err == nil && size <= len(data) {
+			offset = size / transferChunkSize * transferChunkSize
+		}
+	}
+	if offset > 0 {
+		progress.Step("deploy_resumed", progressFields{"offset": offset}, "  Resuming upload at %d of %d bytes\n", offset, len(data))
+	}
+
+	for ; offset < len(data); offset += transferChunkSize {
+		end := min(offset+transferChunkSize, len(data))
+		chunk := base64.StdEncoding.EncodeToString(data[offset:end])
+		cmd := fmt.Sprintf("touch %[1]s && truncate -s %[2]d %[1]s && base64 -d >> %[1]s", quoted, offset)
+		_, stderr, exitCode, err := runner.ExecWithInput(ctx, cmd, []byte(chunk))
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("writing chunk at %d failed (exit %d): %s", offset, exitCode, strings.TrimSpace(stderr))
+		}
+	}
+	return nil
+}
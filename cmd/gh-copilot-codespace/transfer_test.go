@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// localRunner runs remote commands with the local bash, standing in for the
+// codespace.
+type localRunner struct {
+	inputs int
+}
+
+func (r *localRunner) Exec(ctx context.Context, command string) (string, string, int, error) {
+	return r.ExecWithInput(ctx, command, nil)
+}
+
+func (r *localRunner) ExecWithInput(ctx context.Context, command string, input []byte) (string, string, int, error) {
+	if input != nil {
+		r.inputs++
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), stderr.String(), exitErr.ExitCode(), nil
+	}
+	return stdout.String(), stderr.String(), 0, err
+}
+
+func quietProgress(t *testing.T) {
+	t.Helper()
+	oldProgress, oldBackoff := progress, transferBackoff
+	progress = newProgressReporter(progressQuiet, io.Discard, io.Discard)
+	transferBackoff = time.Millisecond
+	t.Cleanup(func() { progress, transferBackoff = oldProgress, oldBackoff })
+}
+
+func writeTestBinary(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	p := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(p, data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p, data
+}
+
+func TestChunkedUploadResumes(t *testing.T) {
+	quietProgress(t)
+	local, data := writeTestBinary(t, 2*transferChunkSize+123)
+	remote := filepath.Join(t.TempDir(), "remote", "agent")
+	if err := os.MkdirAll(filepath.Dir(remote), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A previous attempt left one full chunk plus some of the second.
+	if err := os.WriteFile(remote, data[:transferChunkSize+500], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &localRunner{}
+	if err := chunkedUpload(context.Background(), runner, local, remote); err != nil {
+		t.Fatalf("chunkedUpload: %v", err)
+	}
+	if runner.inputs != 2 {
+		t.Errorf("uploaded %d chunks, want 2 after resuming", runner.inputs)
+	}
+	got, _ := os.ReadFile(remote)
+	if !bytes.Equal(got, data) {
+		t.Errorf("remote file has %d bytes, want an exact copy of %d", len(got), len(data))
+	}
+}
+
+func TestTransferBinaryFallsBack(t *testing.T) {
+	quietProgress(t)
+	local, data := writeTestBinary(t, 4096)
+	remote := filepath.Join(t.TempDir(), "bin", "gh-copilot-codespace")
+	runner := &localRunner{}
+
+	var calls []string
+	transfers := []binaryTransfer{
+		{"broken", func(ctx context.Context, localPath, remotePath string) error {
+			calls = append(calls, "broken")
+			return errors.New("connection reset")
+		}},
+		{"corrupting", func(ctx context.Context, localPath, remotePath string) error {
+			calls = append(calls, "corrupting")
+			return os.WriteFile(remotePath, data[:100], 0o644)
+		}},
+		{"chunked base64", func(ctx context.Context, localPath, remotePath string) error {
+			calls = append(calls, "chunked base64")
+			return chunkedUpload(ctx, runner, localPath, remotePath)
+		}},
+	}
+
+	method, err := transferBinary(context.Background(), runner, transfers, local, remote)
+	if err != nil {
+		t.Fatalf("transferBinary: %v", err)
+	}
+	if method != "chunked base64" {
+		t.Errorf("method = %q, want chunked base64", method)
+	}
+	want := "broken broken broken corrupting corrupting corrupting chunked base64"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+
+	got, _ := os.ReadFile(remote)
+	if !bytes.Equal(got, data) {
+		t.Error("installed binary does not match the local one")
+	}
+	if info, err := os.Stat(remote); err != nil || info.Mode()&0o100 == 0 {
+		t.Errorf("installed binary should be executable: %v %v", info, err)
+	}
+	if _, err := os.Stat(remote + ".partial"); !os.IsNotExist(err) {
+		t.Error("partial upload should be moved into place")
+	}
+}
+
+func TestTransferBinaryAllFail(t *testing.T) {
+	quietProgress(t)
+	local, _ := writeTestBinary(t, 16)
+	remote := filepath.Join(t.TempDir(), "agent")
+	transfers := []binaryTransfer{
+		{"sftp", func(context.Context, string, string) error { return errors.New("no sftp subsystem") }},
+		{"gh codespace cp", func(context.Context, string, string) error { return errors.New("scp: timeout") }},
+	}
+
+	_, err := transferBinary(context.Background(), &localRunner{}, transfers, local, remote)
+	if err == nil {
+		t.Fatal("expected an error when every method fails")
+	}
+	for _, want := range []string{"sftp: no sftp subsystem", "gh codespace cp: scp: timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
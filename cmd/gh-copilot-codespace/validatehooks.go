@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// defaultHookTimeout bounds a validated hook that sets no timeoutSec.
+const defaultHookTimeout = 30 * time.Second
+
+type validateHooksOptions struct {
+	codespaceName   string
+	workdirOverride string
+	run             bool
+}
+
+func parseValidateHooksArgs(args []string) (validateHooksOptions, error) {
+	var opts validateHooksOptions
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "--codespace" || args[i] == "-c") && i+1 < len(args):
+			opts.codespaceName = args[i+1]
+			i++
+		case (args[i] == "--workdir" || args[i] == "-w") && i+1 < len(args):
+			opts.workdirOverride = args[i+1]
+			i++
+		case args[i] == "--run":
+			opts.run = true
+		default:
+			return validateHooksOptions{}, fmt.Errorf("unknown validate-hooks argument %q", args[i])
+		}
+	}
+	if opts.codespaceName == "" {
+		return validateHooksOptions{}, fmt.Errorf("validate-hooks requires --codespace NAME")
+	}
+	return opts, nil
+}
+
+// hookResult is the outcome of checking or running one hook handler.
+type hookResult struct {
+	File     string
+	Event    string
+	Index    int
+	Skipped  bool
+	Err      error
+	Duration time.Duration
+}
+
+func (r hookResult) label() string {
+	return fmt.Sprintf("%s %s[%d]", r.File, r.Event, r.Index)
+}
+
+// runValidateHooks fetches the codespace's hook configs, rewrites them for SSH
+// exactly as a session would, and checks each handler without running it: see
+// checkHook. With --run, each handler runs for real once with a synthetic
+// event instead. It fails if any handler fails, so broken paths show up
+// before a session silently loses them.
+func runValidateHooks(args []string) error {
+	opts, err := parseValidateHooksArgs(args)
+	if err != nil {
+		return err
+	}
+	progress = newProgressReporter(progressHuman, os.Stderr, os.Stderr)
+
+	cs, err := lookupCodespace(opts.codespaceName)
+	if err != nil {
+		return err
+	}
+	progress.Step("codespace_selected", progressFields{"codespace": cs.Name, "repository": cs.Repository}, "Codespace: %s (%s)\n", cs.Name, registry.RepositoryLabel(cs.Repository))
+
+	workdir := opts.workdirOverride
+	if workdir == "" {
+		workdir, err = detectWorkdir(cs.Name, cs.Repository)
+		if err != nil {
+			return err
+		}
+	}
+	progress.Step("workdir_detected", progressFields{"codespace": cs.Name, "workdir": workdir}, "  Workspace: %s\n", workdir)
+
+	sshClient := ssh.NewClient(cs.Name)
+	if err := sshClient.SetupMultiplexing(context.Background()); err != nil {
+		progress.Warn("ssh_multiplexing_failed", progressFields{"codespace": cs.Name}, "Warning: SSH multiplexing failed for %s: %v\n", cs.Name, err)
+	}
+	remoteBinary, err := deployOrReuseBinary(sshClient, cs.Name, findActiveSessions(activeSessionDir(), cs.Name))
+	if err != nil {
+		progress.Warn("deploy_failed", progressFields{"codespace": cs.Name}, "Warning: could not deploy exec agent for %s: %v\n", cs.Name, err)
+	}
+
+	parser := newBatchFetchParser()
+	if err := execSSHStream(sshClient, cs.Name, hookFetchScript(workdir), parser); err != nil {
+		return fmt.Errorf("fetching hook configs: %w", err)
+	}
+	if failures := parser.failures(); len(failures) > 0 {
+		return fmt.Errorf("fetching hook configs: %s: %w", failures[0].path, failures[0].err)
+	}
+	if len(parser.files) == 0 {
+		progress.Step("hooks_none", progressFields{"codespace": cs.Name}, "No hook configs under %s/.github/hooks\n", workdir)
+		return nil
+	}
+
+	validate := func(ctx context.Context, event string, h map[string]any) hookResult {
+		return checkHook(ctx, sshClient, event, h, cs.Name, workdir, remoteBinary)
+	}
+	if opts.run {
+		progress.Warn("hooks_run", progressFields{"codespace": cs.Name}, "Running every hook for real with a synthetic event (--run)\n")
+		validate = func(ctx context.Context, event string, h map[string]any) hookResult {
+			return runHook(ctx, event, h, cs.Name, workdir, remoteBinary)
+		}
+	}
+	results := validateHookFiles(context.Background(), parser.files, validate)
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			progress.Warn("hook_skipped", progressFields{"path": r.File, "event": r.Event, "index": r.Index},
+				"  - %s: skipped (%v)\n", r.label(), r.Err)
+		case r.Err != nil:
+			failed++
+			progress.Warn("hook_failed", progressFields{"path": r.File, "event": r.Event, "index": r.Index, "error": r.Err.Error()},
+				"  ✗ %s: %v\n", r.label(), r.Err)
+		default:
+			progress.Step("hook_ok", progressFields{"path": r.File, "event": r.Event, "index": r.Index, "durationMs": r.Duration.Milliseconds()},
+				"  ✓ %s (%s)\n", r.label(), r.Duration.Round(100*time.Millisecond))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hooks failed", failed, len(results))
+	}
+	return nil
+}
+
+// validateHookFiles passes every handler in files to validate, sorted by file,
+// event, and position. A file that is not a hooks config is reported as one
+// failure.
+func validateHookFiles(ctx context.Context, files map[string][]byte, validate func(ctx context.Context, event string, h map[string]any) hookResult) []hookResult {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var results []hookResult
+	for _, p := range paths {
+		var config map[string]any
+		if err := json.Unmarshal(files[p], &config); err != nil {
+			results = append(results, hookResult{File: p, Err: fmt.Errorf("invalid JSON: %w", err)})
+			continue
+		}
+		hooks, ok := config["hooks"].(map[string]any)
+		if !ok {
+			results = append(results, hookResult{File: p, Err: errors.New(`missing "hooks" object`)})
+			continue
+		}
+		events := make([]string, 0, len(hooks))
+		for event := range hooks {
+			events = append(events, event)
+		}
+		sort.Strings(events)
+		for _, event := range events {
+			handlers, _ := hooks[event].([]any)
+			for i, raw := range handlers {
+				h, _ := raw.(map[string]any)
+				r := validate(ctx, event, h)
+				r.File, r.Event, r.Index = p, event, i
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+// rewriteHookForSSH returns the local command a session would run for h. A
+// result with Skipped or Err set means there is nothing to run.
+func rewriteHookForSSH(event string, h map[string]any, codespaceName, workdir, remoteBinary string) (string, hookResult) {
+	if h == nil {
+		return "", hookResult{Skipped: true, Err: errors.New("handler is not an object")}
+	}
+	// rewriteHooksForSSH edits handlers in place; keep the caller's intact.
+	single, _ := json.Marshal(map[string]any{"hooks": map[string]any{event: []any{h}}})
+	rewritten := rewriteHooksForSSH(single, codespaceName, workdir, remoteBinary)
+	if rewritten == nil {
+		return "", hookResult{Skipped: true, Err: errors.New(`no "bash" or "command" to run`)}
+	}
+	var parsed struct {
+		Hooks map[string][]struct {
+			Bash string `json:"bash"`
+		} `json:"hooks"`
+	}
+	if err := json.Unmarshal(rewritten, &parsed); err != nil || len(parsed.Hooks[event]) != 1 {
+		return "", hookResult{Err: errors.New("could not rewrite for SSH")}
+	}
+	return parsed.Hooks[event][0].Bash, hookResult{}
+}
+
+// checkHook validates a handler without running it: the command rewritten
+// for SSH must parse locally, and on the codespace its working directory and
+// the exec agent must exist, a bash handler must parse, and the program it
+// starts must resolve on PATH.
+func checkHook(ctx context.Context, runner remoteRunner, event string, h map[string]any, codespaceName, workdir, remoteBinary string) hookResult {
+	local, r := rewriteHookForSSH(event, h, codespaceName, workdir, remoteBinary)
+	if r.Skipped || r.Err != nil {
+		return r
+	}
+	start := time.Now()
+	var stderr bytes.Buffer
+	syntax := exec.CommandContext(ctx, "bash", "-n", "-c", local)
+	syntax.Stderr = &stderr
+	if err := syntax.Run(); err != nil {
+		return hookResult{Err: fmt.Errorf("rewritten command does not parse: %s", lastLine(stderr.String()))}
+	}
+
+	remoteCwd := workdir
+	if cwd, ok := h["cwd"].(string); ok && cwd != "" && cwd != "." {
+		remoteCwd = workdir + "/" + cwd
+	}
+	bashCmd, _ := h["bash"].(string)
+	var argv []string
+	if bashCmd == "" {
+		argv = hookCommandArgv(h)
+	}
+	_, errOut, exitCode, err := runner.Exec(ctx, hookCheckScript(remoteCwd, bashCmd, argv, remoteBinary))
+	r = hookResult{Duration: time.Since(start)}
+	switch {
+	case err != nil:
+		r.Err = err
+	case exitCode != 0:
+		r.Err = errors.New(lastLine(errOut))
+	}
+	return r
+}
+
+// hookCheckScript checks on the codespace what checkHook describes, printing
+// the first problem to stderr.
+func hookCheckScript(remoteCwd, bashCmd string, argv []string, remoteBinary string) string {
+	checks := []string{fmt.Sprintf(`cd %s 2>/dev/null || { echo "working directory does not exist:" %[1]s >&2; exit 1; }`, shellQuote(remoteCwd))}
+	if remoteBinary != "" {
+		checks = append(checks, fmt.Sprintf(`[ -x %s ] || { echo "exec agent is missing" >&2; exit 1; }`, shellQuote(remoteBinary)))
+	}
+	program := ""
+	if argv != nil {
+		program = argv[0]
+	} else {
+		checks = append(checks, fmt.Sprintf(`bash -n -c %s || exit 1`, shellQuote(bashCmd)))
+		program = hookProgram(bashCmd)
+	}
+	if program != "" {
+		checks = append(checks, fmt.Sprintf(`command -v %[1]s >/dev/null 2>&1 || { echo %[1]s": not found" >&2; exit 1; }`, shellQuote(program)))
+	}
+	return strings.Join(checks, "\n")
+}
+
+// hookProgram returns the program a bash handler starts: its first word after
+// any variable assignments, or "" when that is not a plain word.
+func hookProgram(bashCmd string) string {
+	for _, word := range strings.Fields(bashCmd) {
+		if name, _, ok := strings.Cut(word, "="); ok && name != "" && !strings.ContainsAny(name, "/$") {
+			continue
+		}
+		if strings.ContainsAny(word, "(){}$`;|&<>\"'\\*?[") {
+			return ""
+		}
+		return word
+	}
+	return ""
+}
+
+// runHook rewrites a single handler for SSH and runs the resulting command
+// locally with a synthetic event on stdin, as Copilot would. The hook really
+// runs, side effects included.
+func runHook(ctx context.Context, event string, h map[string]any, codespaceName, workdir, remoteBinary string) hookResult {
+	local, r := rewriteHookForSSH(event, h, codespaceName, workdir, remoteBinary)
+	if r.Skipped || r.Err != nil {
+		return r
+	}
+	timeout := defaultHookTimeout
+	if secs, ok := h["timeoutSec"].(float64); ok && secs > 0 {
+		timeout = time.Duration(secs * float64(time.Second))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", "-c", local)
+	cmd.Stdin = bytes.NewReader(syntheticHookEvent(event, workdir, time.Now()))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// Don't wait on a remote child still holding stderr after the timeout.
+	cmd.WaitDelay = time.Second
+	start := time.Now()
+	err := cmd.Run()
+	r = hookResult{Duration: time.Since(start)}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		r.Err = fmt.Errorf("timed out after %s", timeout)
+	case err != nil:
+		r.Err = err
+		if last := lastLine(stderr.String()); last != "" {
+			r.Err = fmt.Errorf("%w: %s", err, last)
+		}
+	}
+	return r
+}
+
+// syntheticHookEvent returns a plausible JSON payload for event, shaped like
+// the input Copilot passes to hooks on stdin.
+func syntheticHookEvent(event, cwd string, now time.Time) []byte {
+	payload := map[string]any{"timestamp": now.UnixMilli(), "cwd": cwd}
+	switch event {
+	case "sessionStart":
+		payload["source"] = "new"
+		payload["initialPrompt"] = ""
+	case "sessionEnd":
+		payload["reason"] = "complete"
+	case "userPromptSubmitted":
+		payload["prompt"] = "validate hooks"
+	case "preToolUse", "postToolUse":
+		payload["toolName"] = "bash"
+		payload["toolArgs"] = `{"command":"true"}`
+		if event == "postToolUse" {
+			payload["toolResult"] = map[string]any{"resultType": "success", "textResultForLlm": ""}
+		}
+	case "errorOccurred":
+		payload["error"] = map[string]any{"name": "Error", "message": "synthetic error from validate-hooks"}
+	}
+	data, _ := json.Marshal(payload)
+	return data
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
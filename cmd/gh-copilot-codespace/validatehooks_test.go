@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseValidateHooksArgs(t *testing.T) {
+	opts, err := parseValidateHooksArgs([]string{"-c", "my-cs", "--workdir", "/workspaces/r", "--run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.codespaceName != "my-cs" || opts.workdirOverride != "/workspaces/r" || !opts.run {
+		t.Errorf("opts = %+v", opts)
+	}
+	if _, err := parseValidateHooksArgs(nil); err == nil {
+		t.Error("expected error without --codespace")
+	}
+	if _, err := parseValidateHooksArgs([]string{"-c", "x", "--bogus"}); err == nil {
+		t.Error("expected error for unknown argument")
+	}
+}
+
+func TestSyntheticHookEvent(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	var payload map[string]any
+	if err := json.Unmarshal(syntheticHookEvent("postToolUse", "/workspaces/r", now), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["cwd"] != "/workspaces/r" || payload["toolName"] != "bash" || payload["timestamp"] != float64(1700000000000) {
+		t.Errorf("payload = %v", payload)
+	}
+	if _, ok := payload["toolResult"].(map[string]any); !ok {
+		t.Errorf("postToolUse payload has no toolResult: %v", payload)
+	}
+}
+
+func TestValidateHookFiles(t *testing.T) {
+	workdir := t.TempDir()
+	// The hook succeeds only with a JSON event on stdin.
+	script := "#!/bin/sh\ngrep -q '\"toolName\"' || exit 4\n"
+	if err := os.WriteFile(filepath.Join(workdir, "check.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	binDir := t.TempDir()
+	fakeGH := "#!/bin/sh\nwhile [ \"$1\" != \"--\" ]; do shift; done; shift\nexec bash -c \"$*\"\n"
+	if err := os.WriteFile(filepath.Join(binDir, "gh"), []byte(fakeGH), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+
+	files := map[string][]byte{
+		".github/hooks/a.json": []byte(`{"version": 1, "hooks": {"preToolUse": [
+			{"type": "command", "bash": "./check.sh"},
+			{"type": "command", "command": "./missing.sh"},
+			{"type": "command", "powershell": "Write-Host hi"},
+			{"type": "command", "bash": "sleep 5", "timeoutSec": 0.2}
+		]}}`),
+		".github/hooks/b.json": []byte(`not json`),
+	}
+	results := validateHookFiles(context.Background(), files, func(ctx context.Context, event string, h map[string]any) hookResult {
+		return runHook(ctx, event, h, "my-cs", workdir, "")
+	})
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5: %+v", len(results), results)
+	}
+	if r := results[0]; r.Err != nil || r.Skipped || r.label() != ".github/hooks/a.json preToolUse[0]" {
+		t.Errorf("working hook = %+v (%s)", r, r.label())
+	}
+	if r := results[1]; r.Err == nil || r.Skipped {
+		t.Errorf("missing script = %+v, want failure", r)
+	}
+	if r := results[2]; !r.Skipped {
+		t.Errorf("powershell-only hook = %+v, want skipped", r)
+	}
+	if r := results[3]; r.Err == nil || !strings.Contains(r.Err.Error(), "timed out") {
+		t.Errorf("slow hook = %+v, want timeout", r)
+	}
+	if r := results[4]; r.File != ".github/hooks/b.json" || r.Err == nil || !strings.Contains(r.Err.Error(), "invalid JSON") {
+		t.Errorf("invalid file = %+v", r)
+	}
+}
+
+func TestCheckHook(t *testing.T) {
+	workdir := t.TempDir()
+	marker := filepath.Join(workdir, "ran")
+	script := "#!/bin/sh\ntouch " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(workdir, "check.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	check := func(h map[string]any) hookResult {
+		t.Helper()
+		return checkHook(context.Background(), &localRunner{}, "preToolUse", h, "my-cs", workdir, "")
+	}
+
+	for _, h := range []map[string]any{
+		{"type": "command", "bash": "./check.sh --fast"},
+		{"type": "command", "bash": "FOO=1 sh -c 'exit 3'"},
+		{"type": "command", "command": "./check.sh", "args": []any{"x"}},
+	} {
+		if r := check(h); r.Err != nil || r.Skipped {
+			t.Errorf("checkHook(%v) = %+v", h, r)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("checkHook ran the hook")
+	}
+
+	for _, tt := range []struct {
+		h    map[string]any
+		want string
+	}{
+		{map[string]any{"type": "command", "bash": "./missing.sh"}, "./missing.sh: not found"},
+		{map[string]any{"type": "command", "command": "no-such-program-xyz"}, "no-such-program-xyz: not found"},
+		{map[string]any{"type": "command", "bash": "if true; then"}, "syntax error"},
+		{map[string]any{"type": "command", "bash": "true", "cwd": "nope"}, "working directory does not exist"},
+	} {
+		if r := check(tt.h); r.Err == nil || !strings.Contains(r.Err.Error(), tt.want) {
+			t.Errorf("checkHook(%v) = %+v, want %q", tt.h, r, tt.want)
+		}
+	}
+	if r := checkHook(context.Background(), &localRunner{}, "preToolUse", map[string]any{"bash": "true"}, "my-cs", workdir, "/no/such/agent"); r.Err == nil || !strings.Contains(r.Err.Error(), "exec agent is missing") {
+		t.Errorf("missing exec agent = %+v", r)
+	}
+}
+
+func TestHookProgram(t *testing.T) {
+	for cmd, want := range map[string]string{
+		"./scripts/lint.sh --fix": "./scripts/lint.sh",
+		"FOO=1 BAR=2 npm test":    "npm",
+		"$HOME/bin/x":             "",
+		"(cd sub && make)":        "",
+		"":                        "",
+	} {
+		if got := hookProgram(cmd); got != want {
+			t.Errorf("hookProgram(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
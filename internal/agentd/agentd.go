@@ -0,0 +1,318 @@
+// Package agentd is the exec agent's daemon mode: a long-lived process on the
+// codespace that runs commands sent to it as JSON-RPC 2.0 requests over a Unix
+// socket. The MCP server reaches the socket through its SSH master's socket
+// forwarding, so a tool call costs one round trip on an open connection
+// instead of a new ssh process, and the argv crosses the wire as JSON instead
+// of through a remote shell's quoting.
+package agentd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProtocolVersion is reported in the greeting and by ping. A client talks only
+// to a daemon of the same version, so an old daemon left running after a redeploy is bypassed
+// rather than misread.
+const ProtocolVersion = 1
+
+// Method names. The daemon opens every connection with a ready notification
+// carrying a PingResult, before the client sends its one request.
+const (
+	MethodReady = "ready"
+	MethodPing  = "ping"
+	MethodExec  = "exec"
+)
+
+// JSON-RPC error codes the daemon returns.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeExecFailed     = -32000
+)
+
+// ErrUnreachable is returned by the client when no daemon greeted it, so the
+// request was never sent and can safely go another way.
+var ErrUnreachable = errors.New("agent daemon unreachable")
+
+// ErrAlreadyRunning is returned by ListenAndServe when a daemon of the same
+// version already answers on the socket.
+var ErrAlreadyRunning = errors.New("agent daemon already running")
+
+// ExecParams is one command to run. Argv is executed directly, without a
+// shell; Env entries (K=V) are added to the daemon's environment.
+type ExecParams struct {
+	Argv  []string `json:"argv"`
+	Dir   string   `json:"dir,omitempty"`
+	Env   []string `json:"env,omitempty"`
+	Stdin []byte   `json:"stdin,omitempty"`
+}
+
+// ExecResult is a finished command. Output is raw bytes, so binary output
+// survives the JSON encoding.
+type ExecResult struct {
+	Stdout   []byte `json:"stdout"`
+	Stderr   []byte `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// PingResult identifies a running daemon.
+type PingResult struct {
+	Version int `json:"version"`
+	PID     int `json:"pid"`
+}
+
+// Error is a JSON-RPC error returned by the daemon: it was reached but could
+// not run the request.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("agent daemon: %s (code %d)", e.Message, e.Code)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Server runs the requests of a daemon.
+type Server struct {
+	// Environ returns the environment commands start from, before their own
+	// Env. It defaults to the daemon's environment.
+	Environ func() []string
+}
+
+// ListenAndServe serves on socketPath with the default Server.
+func ListenAndServe(socketPath string) error {
+	return (&Server{}).ListenAndServe(socketPath)
+}
+
+// Serve serves ln with the default Server.
+func Serve(ln net.Listener) error {
+	return (&Server{}).Serve(ln)
+}
+
+// ListenAndServe listens on socketPath, readable only by the current user,
+// and serves requests until the listener fails. A stale socket left by a dead
+// daemon is replaced.
+func (s *Server) ListenAndServe(socketPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ping, err := Ping(ctx, socketPath)
+	cancel()
+	if err == nil && ping.Version == ProtocolVersion {
+		return ErrAlreadyRunning
+	}
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return err
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve answers one request per connection on ln until Accept fails.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	ready, _ := json.Marshal(PingResult{Version: ProtocolVersion, PID: os.Getpid()})
+	if err := json.NewEncoder(conn).Encode(request{JSONRPC: "2.0", Method: MethodReady, Params: ready}); err != nil {
+		return
+	}
+	dec := json.NewDecoder(conn)
+	var req request
+	if err := dec.Decode(&req); err != nil {
+		writeResponse(conn, response{Error: &Error{Code: codeParseError, Message: err.Error()}})
+		return
+	}
+	resp := response{ID: req.ID}
+	switch req.Method {
+	case MethodPing:
+		resp.Result, _ = json.Marshal(PingResult{Version: ProtocolVersion, PID: os.Getpid()})
+	case MethodExec:
+		var params ExecParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params.Argv) == 0 {
+			resp.Error = &Error{Code: codeInvalidParams, Message: "exec needs a non-empty argv"}
+			break
+		}
+		// The client closes the connection to cancel. The decoder stops at
+		// the request's closing brace, so the encoder's trailing newline may
+		// still be buffered or in flight; it and anything else the client
+		// sends are discarded until EOF or a read error.
+		gone := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, io.MultiReader(dec.Buffered(), conn))
+			close(gone)
+		}()
+		result, err := s.run(params, gone)
+		if err != nil {
+			resp.Error = &Error{Code: codeExecFailed, Message: err.Error()}
+			break
+		}
+		resp.Result, _ = json.Marshal(result)
+	default:
+		resp.Error = &Error{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+	writeResponse(conn, resp)
+}
+
+func writeResponse(conn net.Conn, resp response) {
+	resp.JSONRPC = "2.0"
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// run executes params in its own process group, killing the group if gone
+// closes before the command finishes.
+func (s *Server) run(params ExecParams, gone <-chan struct{}) (ExecResult, error) {
+	environ := os.Environ
+	if s.Environ != nil {
+		environ = s.Environ
+	}
+	cmd := exec.Command(params.Argv[0], params.Argv[1:]...)
+	cmd.Dir = params.Dir
+	cmd.Env = append(environ(), params.Env...)
+	cmd.Stdin = bytes.NewReader(params.Stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return ExecResult{}, err
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-gone:
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	wg.Wait()
+
+	result := ExecResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return ExecResult{}, err
+	}
+	return result, nil
+}
+
+// Ping asks the daemon on socketPath for its version.
+func Ping(ctx context.Context, socketPath string) (PingResult, error) {
+	var result PingResult
+	err := call(ctx, socketPath, MethodPing, nil, &result)
+	return result, err
+}
+
+// Exec runs params on the daemon. Cancelling ctx closes the connection, and
+// the daemon kills the command. An *Error means the daemon was reached but
+// could not start the command. ErrUnreachable means the command was never
+// sent; any other error is a connection lost while it may have been running.
+func Exec(ctx context.Context, socketPath string, params ExecParams) (ExecResult, error) {
+	var result ExecResult
+	err := call(ctx, socketPath, MethodExec, params, &result)
+	return result, err
+}
+
+func call(ctx context.Context, socketPath, method string, params, result any) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// A forwarded socket accepts the connection even when nothing listens
+	// on the codespace, so only the greeting shows a daemon is there.
+	dec := json.NewDecoder(conn)
+	var ready request
+	if err := dec.Decode(&ready); err != nil || ready.Method != MethodReady {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w: no greeting (%v)", ErrUnreachable, err)
+	}
+	var greeting PingResult
+	if err := json.Unmarshal(ready.Params, &greeting); err != nil || greeting.Version != ProtocolVersion {
+		return fmt.Errorf("%w: daemon speaks protocol %d, want %d", ErrUnreachable, greeting.Version, ProtocolVersion)
+	}
+
+	req := request{JSONRPC: "2.0", ID: 1, Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return contextErr(ctx, err)
+	}
+	var resp response
+	if err := dec.Decode(&resp); err != nil {
+		return contextErr(ctx, fmt.Errorf("reading response: %w", err))
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// contextErr prefers the context's error when cancellation caused err.
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package agentd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// socketDir returns a short temporary directory: macOS limits socket paths to
+// 104 bytes, which t.TempDir can exceed.
+func socketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "agentd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	socketPath := filepath.Join(socketDir(t), "agent.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go Serve(ln)
+	return socketPath
+}
+
+func TestExec(t *testing.T) {
+	socketPath := startServer(t)
+	dir := t.TempDir()
+	res, err := Exec(context.Background(), socketPath, ExecParams{
+		Argv:  []string{"sh", "-c", `pwd; echo "$GREETING"; cat; printf '\377' ; echo oops >&2; exit 3`},
+		Dir:   dir,
+		Env:   []string{"GREETING=it's \"quoted\" $HOME"},
+		Stdin: []byte("from stdin\n"),
+	})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	wantDir, _ := filepath.EvalSymlinks(dir)
+	want := wantDir + "\nit's \"quoted\" $HOME\nfrom stdin\n\xff"
+	if string(res.Stdout) != want || string(res.Stderr) != "oops\n" || res.ExitCode != 3 {
+		t.Fatalf("Exec() = stdout %q, stderr %q, exit %d; want stdout %q", res.Stdout, res.Stderr, res.ExitCode, want)
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	socketPath := startServer(t)
+	var rpcErr *Error
+	if _, err := Exec(context.Background(), socketPath, ExecParams{Argv: []string{"/no/such/command"}}); !errors.As(err, &rpcErr) || rpcErr.Code != codeExecFailed {
+		t.Errorf("missing command: err = %v, want an exec failure", err)
+	}
+	if _, err := Exec(context.Background(), socketPath, ExecParams{}); !errors.As(err, &rpcErr) || rpcErr.Code != codeInvalidParams {
+		t.Errorf("empty argv: err = %v, want invalid params", err)
+	}
+	if _, err := Exec(context.Background(), filepath.Join(socketDir(t), "none.sock"), ExecParams{Argv: []string{"true"}}); err == nil || errors.As(err, &rpcErr) {
+		t.Errorf("no daemon: err = %v, want a transport error", err)
+	}
+}
+
+func TestExecCancelKillsCommand(t *testing.T) {
+	socketPath := startServer(t)
+	marker := filepath.Join(t.TempDir(), "finished")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Exec(ctx, socketPath, ExecParams{Argv: []string{"sh", "-c", "sleep 1; touch " + marker}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Exec() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Exec() returned after %s", elapsed)
+	}
+	time.Sleep(1300 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("cancelled command kept running")
+	}
+}
+
+func TestExecSurvivesTrailingNewline(t *testing.T) {
+	socketPath := startServer(t)
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	var ready request
+	if err := dec.Decode(&ready); err != nil {
+		t.Fatalf("reading greeting: %v", err)
+	}
+
+	// Send the newline json.Encoder adds in a write of its own, after the
+	// daemon has decoded the request and started the command.
+	params, _ := json.Marshal(ExecParams{Argv: []string{"sh", "-c", "sleep 0.3; echo done"}})
+	req, _ := json.Marshal(request{JSONRPC: "2.0", ID: 1, Method: MethodExec, Params: params})
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := conn.Write([]byte("\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp response
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	var res ExecResult
+	if resp.Error != nil || json.Unmarshal(resp.Result, &res) != nil || string(res.Stdout) != "done\n" || res.ExitCode != 0 {
+		t.Fatalf("response = %+v, result %+v; want the command to finish", resp, res)
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	socketPath := filepath.Join(socketDir(t), "run", "agent.sock")
+
+	go ListenAndServe(socketPath)
+	var ping PingResult
+	var err error
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if ping, err = Ping(context.Background(), socketPath); err == nil {
+			break
+		}
+	}
+	if err != nil || ping.Version != ProtocolVersion || ping.PID != os.Getpid() {
+		t.Fatalf("Ping() = %+v, %v", ping, err)
+	}
+	if info, err := os.Stat(socketPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if err := ListenAndServe(socketPath); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second ListenAndServe() = %v, want ErrAlreadyRunning", err)
+	}
+}
+
+func TestServerEnviron(t *testing.T) {
+	socketPath := filepath.Join(socketDir(t), "agent.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	server := &Server{Environ: func() []string { return []string{"PATH=" + os.Getenv("PATH"), "FROM_LOGIN=yes"} }}
+	go server.Serve(ln)
+
+	res, err := Exec(context.Background(), socketPath, ExecParams{Argv: []string{"sh", "-c", `echo "$FROM_LOGIN $EXTRA"`}, Env: []string{"EXTRA=flag"}})
+	if err != nil || string(res.Stdout) != "yes flag\n" {
+		t.Fatalf("Exec() = %q, %v; want the server's environment plus Env", res.Stdout, err)
+	}
+}
@@ -0,0 +1,359 @@
+// Package audit ships signed records of mutating tool calls to external sinks
+// (an HTTP webhook or syslog) for central visibility of what an agent ran.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 20
+	defaultFlushInterval = 5 * time.Second
+	defaultMaxQueue      = 1000
+)
+
+// Config is the audit.json config file structure.
+type Config struct {
+	Webhook       *WebhookConfig `json:"webhook,omitempty"`
+	Syslog        *SyslogConfig  `json:"syslog,omitempty"`
+	Secret        string         `json:"secret,omitempty"`        // HMAC key for signing events
+	SecretEnv     string         `json:"secretEnv,omitempty"`     // env var holding the HMAC key
+	BatchSize     int            `json:"batchSize,omitempty"`     // events per delivery (default 20)
+	FlushInterval string         `json:"flushInterval,omitempty"` // max delay before delivery (default "5s")
+	MaxQueue      int            `json:"maxQueue,omitempty"`      // undelivered events kept per sink (default 1000)
+}
+
+// WebhookConfig posts batches of events as JSON.
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout string            `json:"timeout,omitempty"` // default "10s"
+}
+
+// SyslogConfig sends one message per event. An empty Network and Address
+// use the local syslog daemon.
+type SyslogConfig struct {
+	Network string `json:"network,omitempty"` // "udp", "tcp", or "" for local
+	Address string `json:"address,omitempty"`
+	Tag     string `json:"tag,omitempty"` // default "copilot-codespace"
+}
+
+// Event describes one mutating tool call.
+type Event struct {
+	Time          time.Time      `json:"time"`
+	Sequence      uint64         `json:"sequence"`
+	Tool          string         `json:"tool"`
+	Codespace     string         `json:"codespace,omitempty"`
+	Alias         string         `json:"alias,omitempty"`
+	Session       string         `json:"session,omitempty"`
+	User          string         `json:"user,omitempty"`
+	Host          string         `json:"host,omitempty"`
+	Arguments     map[string]any `json:"arguments,omitempty"`
+	Outcome       string         `json:"outcome"` // "success" or "error"
+	ErrorCategory string         `json:"errorCategory,omitempty"`
+	DurationMs    int64          `json:"durationMs"`
+}
+
+// Record is a signed event as delivered to sinks. Signature is
+// "sha256=<hex HMAC-SHA256 of Event>" so receivers can verify the exact bytes.
+type Record struct {
+	Event     json.RawMessage `json:"event"`
+	Signature string          `json:"signature"`
+}
+
+// Verify reports whether the record was signed with secret.
+func (r Record) Verify(secret []byte) bool {
+	return hmac.Equal([]byte(r.Signature), []byte(sign(secret, r.Event)))
+}
+
+func sign(secret, data []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sink delivers a batch of records. Implementations are called from a single
+// goroutine per sink.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, records []Record) error
+}
+
+// LoadConfig reads the audit config from the default location.
+// Returns an empty config (not error) if no config file exists.
+func LoadConfig() (Config, error) {
+	return LoadConfigFrom(defaultConfigPath())
+}
+
+// LoadConfigFrom reads the audit config from a specific path.
+func LoadConfigFrom(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("reading audit config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parsing audit config: %w", err)
+	}
+	return cfg, nil
+}
+
+func defaultConfigPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "copilot-codespace", "audit.json")
+}
+
+// signingSecret returns the HMAC key, preferring SecretEnv when set.
+func (c Config) signingSecret() string {
+	if c.SecretEnv != "" {
+		return os.Getenv(c.SecretEnv)
+	}
+	return c.Secret
+}
+
+// Logger batches signed events and delivers them to every configured sink in
+// the background. Recording never blocks a tool call: a sink that keeps
+// failing retains its undelivered events up to MaxQueue, then drops the oldest.
+type Logger struct {
+	secret  []byte
+	queues  []*sinkQueue
+	mu      sync.Mutex
+	seq     uint64
+	host    string
+	user    string
+	warn    io.Writer
+	stopped bool
+}
+
+// New builds a Logger from cfg. It returns nil, nil when no sink is configured.
+func New(cfg Config) (*Logger, error) {
+	var sinks []Sink
+	if cfg.Webhook != nil {
+		sink, err := newWebhookSink(*cfg.Webhook, cfg.signingSecret())
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if cfg.Syslog != nil {
+		sinks = append(sinks, newSyslogSink(*cfg.Syslog))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return NewWithSinks(cfg, sinks...)
+}
+
+// NewWithSinks builds a Logger that delivers to the given sinks, using cfg for
+// the secret and batching settings.
+func NewWithSinks(cfg Config, sinks ...Sink) (*Logger, error) {
+	secret := cfg.signingSecret()
+	if secret == "" {
+		return nil, fmt.Errorf("audit config needs a signing secret (secret or secretEnv)")
+	}
+	interval := defaultFlushInterval
+	if cfg.FlushInterval != "" {
+		d, err := time.ParseDuration(cfg.FlushInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("parsing audit flushInterval: invalid duration %q", cfg.FlushInterval)
+		}
+		interval = d
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	maxQueue := cfg.MaxQueue
+	if maxQueue <= 0 {
+		maxQueue = defaultMaxQueue
+	}
+
+	l := &Logger{secret: []byte(secret), warn: os.Stderr}
+	l.host, _ = os.Hostname()
+	l.user = os.Getenv("USER")
+	for _, sink := range sinks {
+		q := &sinkQueue{
+			sink:      sink,
+			batchSize: batchSize,
+			maxQueue:  maxQueue,
+			interval:  interval,
+			warn:      l.warn,
+			wake:      make(chan struct{}, 1),
+			stop:      make(chan struct{}),
+			done:      make(chan struct{}),
+		}
+		l.queues = append(l.queues, q)
+		go q.run()
+	}
+	return l, nil
+}
+
+// Record signs e and queues it for delivery. Time, sequence, host, and user
+// are filled in when unset.
+func (l *Logger) Record(e Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return
+	}
+	l.seq++
+	e.Sequence = l.seq
+	l.mu.Unlock()
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Host == "" {
+		e.Host = l.host
+	}
+	if e.User == "" {
+		e.User = l.user
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	rec := Record{Event: data, Signature: sign(l.secret, data)}
+	for _, q := range l.queues {
+		q.push(rec)
+	}
+}
+
+// Close delivers queued events, waiting until ctx is done at most.
+func (l *Logger) Close(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return nil
+	}
+	l.stopped = true
+	l.mu.Unlock()
+
+	for _, q := range l.queues {
+		close(q.stop)
+	}
+	for _, q := range l.queues {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			return fmt.Errorf("flushing audit events: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// sinkQueue holds undelivered records for one sink.
+type sinkQueue struct {
+	sink      Sink
+	batchSize int
+	maxQueue  int
+	interval  time.Duration
+	warn      io.Writer
+
+	mu      sync.Mutex
+	pending []Record
+	dropped int // dropped since the last successful delivery
+	trimmed int // total dropped, to realign the queue after a send
+	failing bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (q *sinkQueue) push(rec Record) {
+	q.mu.Lock()
+	q.pending = append(q.pending, rec)
+	if over := len(q.pending) - q.maxQueue; over > 0 {
+		q.pending = q.pending[over:]
+		q.dropped += over
+		q.trimmed += over
+	}
+	full := len(q.pending) >= q.batchSize
+	q.mu.Unlock()
+	if full {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q *sinkQueue) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.flush()
+		case <-q.wake:
+			q.flush()
+		case <-q.stop:
+			q.flush()
+			return
+		}
+	}
+}
+
+// flush sends pending records in batches until the queue is empty or the
+// sink fails; failed batches stay queued for the next attempt.
+func (q *sinkQueue) flush() {
+	for {
+		q.mu.Lock()
+		n := min(len(q.pending), q.batchSize)
+		batch := append([]Record(nil), q.pending[:n]...)
+		trimmed := q.trimmed
+		q.mu.Unlock()
+		if n == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := q.sink.Send(ctx, batch)
+		cancel()
+
+		q.mu.Lock()
+		if err != nil {
+			if !q.failing {
+				fmt.Fprintf(q.warn, "codespace-mcp: audit sink %s failed, keeping %d event(s) for retry: %v\n", q.sink.Name(), len(q.pending), err)
+			}
+			q.failing = true
+			q.mu.Unlock()
+			return
+		}
+		// Records dropped while the batch was in flight were part of it.
+		sent := max(n-(q.trimmed-trimmed), 0)
+		q.pending = q.pending[sent:]
+		if q.failing || q.dropped > 0 {
+			fmt.Fprintf(q.warn, "codespace-mcp: audit sink %s recovered (%d event(s) dropped while failing)\n", q.sink.Name(), q.dropped)
+			q.failing = false
+			q.dropped = 0
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]Record
+	fail    bool
+}
+
+func (f *fakeSink) Name() string { return "fake" }
+
+func (f *fakeSink) Send(_ context.Context, records []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("unreachable")
+	}
+	f.batches = append(f.batches, records)
+	return nil
+}
+
+func (f *fakeSink) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func (f *fakeSink) sent() (batches int, events int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, b := range f.batches {
+		events += len(b)
+	}
+	return len(f.batches), events
+}
+
+func TestLoggerSignsAndBatches(t *testing.T) {
+	sink := &fakeSink{}
+	l, err := NewWithSinks(Config{Secret: "s3cret", BatchSize: 2, FlushInterval: "1h"}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Record(Event{Tool: "remote_bash", Arguments: map[string]any{"command": "make"}, Outcome: "success"})
+	l.Record(Event{Tool: "remote_edit", Outcome: "error"})
+	l.Record(Event{Tool: "remote_create", Outcome: "success"})
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	batches, events := sink.sent()
+	if batches != 2 || events != 3 {
+		t.Fatalf("got %d batches / %d events, want 2 / 3", batches, events)
+	}
+	rec := sink.batches[0][0]
+	if !rec.Verify([]byte("s3cret")) {
+		t.Error("record signature does not verify")
+	}
+	if rec.Verify([]byte("other")) {
+		t.Error("record verified with the wrong secret")
+	}
+	var ev Event
+	if err := json.Unmarshal(rec.Event, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Tool != "remote_bash" || ev.Sequence != 1 || ev.Time.IsZero() {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestLoggerKeepsEventsWhileSinkFails(t *testing.T) {
+	sink := &fakeSink{fail: true}
+	l, err := NewWithSinks(Config{Secret: "k", BatchSize: 10, FlushInterval: "10ms", MaxQueue: 3}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Record(Event{Tool: "remote_bash"})
+	}
+	time.Sleep(30 * time.Millisecond)
+	sink.setFail(false)
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, events := sink.sent()
+	if events != 3 {
+		t.Fatalf("delivered %d events, want the newest 3", events)
+	}
+	var first Event
+	json.Unmarshal(sink.batches[0][0].Event, &first)
+	if first.Sequence != 3 {
+		t.Errorf("oldest retained sequence = %d, want 3", first.Sequence)
+	}
+}
+
+func TestNewRequiresSecret(t *testing.T) {
+	if _, err := NewWithSinks(Config{}, &fakeSink{}); err == nil {
+		t.Fatal("expected error without a signing secret")
+	}
+	t.Setenv("AUDIT_TEST_SECRET", "from-env")
+	if _, err := NewWithSinks(Config{SecretEnv: "AUDIT_TEST_SECRET", FlushInterval: "1h"}, &fakeSink{}); err != nil {
+		t.Fatalf("secretEnv should satisfy the secret requirement: %v", err)
+	}
+}
+
+func TestNewWithoutSinksIsDisabled(t *testing.T) {
+	l, err := New(Config{Secret: "x"})
+	if err != nil || l != nil {
+		t.Fatalf("New without sinks = %v, %v; want nil, nil", l, err)
+	}
+	// A nil logger is safe to use.
+	l.Record(Event{Tool: "remote_bash"})
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigFrom(t *testing.T) {
+	dir := t.TempDir()
+	if cfg, err := LoadConfigFrom(filepath.Join(dir, "missing.json")); err != nil || cfg.Webhook != nil {
+		t.Fatalf("missing config = %+v, %v; want empty config", cfg, err)
+	}
+
+	path := filepath.Join(dir, "audit.json")
+	os.WriteFile(path, []byte(`{"webhook":{"url":"https://audit.example.com/hook"},"secretEnv":"AUDIT_KEY","batchSize":50}`), 0o600)
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Webhook == nil || cfg.Webhook.URL != "https://audit.example.com/hook" || cfg.SecretEnv != "AUDIT_KEY" || cfg.BatchSize != 50 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SignatureHeader carries the HMAC of a webhook request body, in the same
+// "sha256=<hex>" form as Record.Signature.
+const SignatureHeader = "X-Copilot-Codespace-Signature"
+
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	secret  []byte
+	client  *http.Client
+}
+
+func newWebhookSink(cfg WebhookConfig, secret string) (*webhookSink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("audit webhook url must be an http(s) URL, got %q", cfg.URL)
+	}
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("parsing audit webhook timeout: invalid duration %q", cfg.Timeout)
+		}
+		timeout = d
+	}
+	return &webhookSink{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (w *webhookSink) Name() string { return "webhook" }
+
+// Send posts {"events": [...]} and treats any non-2xx status as a failure.
+func (w *webhookSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(map[string]any{"events": records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, sign(w.secret, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type syslogSink struct {
+	network string
+	address string
+	tag     string
+	writer  *syslog.Writer
+}
+
+func newSyslogSink(cfg SyslogConfig) *syslogSink {
+	tag := cfg.Tag
+	if tag == "" {
+		tag = "copilot-codespace"
+	}
+	return &syslogSink{network: cfg.Network, address: cfg.Address, tag: tag}
+}
+
+func (s *syslogSink) Name() string { return "syslog" }
+
+// Send writes one message per record. The connection is opened lazily and
+// reopened after a write error.
+func (s *syslogSink) Send(_ context.Context, records []Record) error {
+	if s.writer == nil {
+		w, err := syslog.Dial(s.network, s.address, syslog.LOG_INFO|syslog.LOG_AUTH, s.tag)
+		if err != nil {
+			return err
+		}
+		s.writer = w
+	}
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			continue
+		}
+		if err := s.writer.Info(string(line)); err != nil {
+			s.writer.Close()
+			s.writer = nil
+			return err
+		}
+	}
+	return nil
+}
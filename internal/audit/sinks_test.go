@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookSinkPostsSignedBatch(t *testing.T) {
+	var gotBody []byte
+	var gotSig, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSig = r.Header.Get(SignatureHeader)
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	sink, err := newWebhookSink(WebhookConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t"}}, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := Record{Event: json.RawMessage(`{"tool":"remote_bash"}`), Signature: sign([]byte("key"), []byte(`{"tool":"remote_bash"}`))}
+	if err := sink.Send(context.Background(), []Record{rec}); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotSig != sign([]byte("key"), gotBody) {
+		t.Errorf("body signature = %q, want HMAC of body", gotSig)
+	}
+	if gotAuth != "Bearer t" {
+		t.Errorf("configured header not sent, got %q", gotAuth)
+	}
+	var payload struct {
+		Events []Record `json:"events"`
+	}
+	if err := json.Unmarshal(gotBody, &payload); err != nil || len(payload.Events) != 1 || !payload.Events[0].Verify([]byte("key")) {
+		t.Errorf("unexpected payload %s (%v)", gotBody, err)
+	}
+}
+
+func TestWebhookSinkFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	sink, _ := newWebhookSink(WebhookConfig{URL: srv.URL}, "key")
+	if err := sink.Send(context.Background(), []Record{{Event: json.RawMessage(`{}`)}}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected 503 error, got %v", err)
+	}
+}
+
+func TestNewWebhookSinkValidatesURL(t *testing.T) {
+	for _, u := range []string{"", "ftp://example.com", "example.com/hook"} {
+		if _, err := newWebhookSink(WebhookConfig{URL: u}, "key"); err == nil {
+			t.Errorf("expected error for url %q", u)
+		}
+	}
+}
+
+func TestSyslogSinkSendsOneMessagePerRecord(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	sink := newSyslogSink(SyslogConfig{Network: "udp", Address: conn.LocalAddr().String()})
+	records := []Record{
+		{Event: json.RawMessage(`{"tool":"remote_edit"}`), Signature: "sha256=aa"},
+		{Event: json.RawMessage(`{"tool":"remote_create"}`), Signature: "sha256=bb"},
+	}
+	if err := sink.Send(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	for _, want := range []string{"remote_edit", "remote_create"} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := string(buf[:n])
+		if !strings.Contains(msg, "copilot-codespace") || !strings.Contains(msg, want) {
+			t.Errorf("syslog message %q missing tag or %s", msg, want)
+		}
+	}
+}
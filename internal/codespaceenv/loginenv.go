@@ -0,0 +1,181 @@
+package codespaceenv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// LoginEnvMaxAge is how long a login environment snapshot is used before
+	// it is captured again, even if no profile file changed.
+	LoginEnvMaxAge = time.Hour
+
+	loginEnvCaptureTimeout = 15 * time.Second
+	loginEnvMarker         = "\x00__gh_copilot_codespace_login_env__\x00"
+)
+
+// loginEnvScript runs in a login bash, after the profiles. mise's shims go on
+// PATH so tools it manages resolve per project without a prompt hook. The
+// marker separates the environment from anything the profiles print.
+const loginEnvScript = `if command -v mise >/dev/null 2>&1; then eval "$(mise activate bash --shims 2>/dev/null)"; fi
+printf '\0__gh_copilot_codespace_login_env__\0'
+env -0`
+
+// volatileLoginEnv are variables that describe the capturing shell rather
+// than the login environment.
+var volatileLoginEnv = map[string]bool{"PWD": true, "OLDPWD": true, "SHLVL": true, "_": true}
+
+// LoginEnvPath is where the login environment snapshot is cached on the
+// codespace, per user.
+func LoginEnvPath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "gh-copilot-codespace", "login-env"), nil
+}
+
+// loginEnvSources are the files a login bash reads, plus mise's global
+// config. A snapshot older than any of them is captured again.
+func loginEnvSources() []string {
+	sources := []string{"/etc/profile", "/etc/profile.d", "/etc/bash.bashrc", "/etc/environment"}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{".bash_profile", ".bash_login", ".profile", ".bashrc", ".tool-versions", ".config/mise/config.toml"} {
+			sources = append(sources, filepath.Join(home, name))
+		}
+	}
+	return sources
+}
+
+// LoginEnv returns what the codespace user's login environment adds to this
+// process's: the variables a login bash sets or changes through /etc/profile,
+// ~/.profile and friends, and mise. Overlay them on the process environment
+// to get the login environment. Variables the login shell inherited
+// unchanged, such as the GitHub tokens, are left out, so the snapshot never
+// holds a stale token. It is read from the snapshot at LoginEnvPath while
+// that is fresh, and captured and cached otherwise, so only the first command
+// after a profile change pays for a login shell.
+func LoginEnv(ctx context.Context) ([]string, error) {
+	path, err := LoginEnvPath()
+	if err != nil {
+		return nil, err
+	}
+	return loginEnvAt(ctx, path, loginEnvSources(), LoginEnvMaxAge, time.Now())
+}
+
+func loginEnvAt(ctx context.Context, path string, sources []string, maxAge time.Duration, now time.Time) ([]string, error) {
+	if env, ok := readLoginEnvSnapshot(path, sources, maxAge, now); ok {
+		return env, nil
+	}
+	env, err := captureLoginEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLoginEnvSnapshot(path, env); err != nil {
+		return env, fmt.Errorf("caching login environment: %w", err)
+	}
+	return env, nil
+}
+
+// readLoginEnvSnapshot returns the cached snapshot unless it is missing,
+// older than maxAge, or older than one of sources.
+func readLoginEnvSnapshot(path string, sources []string, maxAge time.Duration, now time.Time) ([]string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || now.Sub(info.ModTime()) > maxAge {
+		return nil, false
+	}
+	for _, source := range sources {
+		if s, err := os.Stat(source); err == nil && s.ModTime().After(info.ModTime()) {
+			return nil, false
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return parseEnvNul(data), true
+}
+
+func writeLoginEnvSnapshot(path string, env []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	for _, kv := range env {
+		data.WriteString(kv)
+		data.WriteByte(0)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// captureLoginEnv runs a login bash in the home directory and returns the
+// variables it has that this process does not.
+func captureLoginEnv(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, loginEnvCaptureTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", "-l", "-c", loginEnvScript)
+	if home, err := os.UserHomeDir(); err == nil {
+		cmd.Dir = home
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("capturing login environment: %w", err)
+	}
+	_, env, ok := bytes.Cut(out, []byte(loginEnvMarker))
+	if !ok {
+		return nil, errors.New("capturing login environment: no environment in the login shell's output")
+	}
+	var changed []string
+	for _, kv := range parseEnvNul(env) {
+		key, value, _ := strings.Cut(kv, "=")
+		if current, ok := os.LookupEnv(key); !ok || current != value {
+			changed = append(changed, kv)
+		}
+	}
+	return changed, nil
+}
+
+// parseEnvNul parses env -0 output, dropping volatileLoginEnv and exported
+// bash functions.
+func parseEnvNul(data []byte) []string {
+	var env []string
+	for _, kv := range strings.Split(string(data), "\x00") {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok || key == "" || volatileLoginEnv[key] || strings.HasPrefix(key, "BASH_FUNC_") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// Overlay returns base with the variables in overlay added or replaced.
+func Overlay(base, overlay []string) []string {
+	index := make(map[string]int, len(base))
+	out := append([]string(nil), base...)
+	for i, kv := range out {
+		key, _, _ := strings.Cut(kv, "=")
+		index[key] = i
+	}
+	for _, kv := range overlay {
+		key, _, _ := strings.Cut(kv, "=")
+		if i, ok := index[key]; ok {
+			out[i] = kv
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, kv)
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package codespaceenv
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envValue(env []string, key string) (string, bool) {
+	for _, kv := range env {
+		if k, v, _ := strings.Cut(kv, "="); k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func TestLoginEnvCapturesAndCachesProfile(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	profile := filepath.Join(home, ".bash_profile")
+	writeProfile := func(value string) {
+		t.Helper()
+		if err := os.WriteFile(profile, []byte("echo 'welcome to the codespace'\nexport FROM_PROFILE='"+value+"'\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeProfile("first")
+	snapshot := filepath.Join(home, ".cache", "gh-copilot-codespace", "login-env")
+	sources := []string{profile}
+	now := time.Now()
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	env, err := loginEnvAt(context.Background(), snapshot, sources, time.Hour, now)
+	if err != nil {
+		t.Fatalf("loginEnvAt() error = %v", err)
+	}
+	if v, _ := envValue(env, "FROM_PROFILE"); v != "first" {
+		t.Fatalf("FROM_PROFILE = %q, want first from the login profile", v)
+	}
+	for _, key := range []string{"PWD", "SHLVL", "_", "HOME", "GITHUB_TOKEN"} {
+		if _, ok := envValue(env, key); ok {
+			t.Errorf("snapshot keeps %s, which the login shell did not change", key)
+		}
+	}
+	if info, err := os.Stat(snapshot); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("snapshot = %v, %v; want a 0600 file", info, err)
+	}
+
+	// An unchanged profile serves the cached snapshot.
+	writeProfile("second")
+	past := now.Add(-time.Minute)
+	os.Chtimes(profile, past, past)
+	env, _ = loginEnvAt(context.Background(), snapshot, sources, time.Hour, now)
+	if v, _ := envValue(env, "FROM_PROFILE"); v != "first" {
+		t.Fatalf("FROM_PROFILE = %q, want the cached first", v)
+	}
+
+	// A profile edited after the snapshot, or an old snapshot, is captured again.
+	future := now.Add(time.Minute)
+	os.Chtimes(profile, future, future)
+	env, _ = loginEnvAt(context.Background(), snapshot, sources, time.Hour, now)
+	if v, _ := envValue(env, "FROM_PROFILE"); v != "second" {
+		t.Fatalf("FROM_PROFILE = %q after a profile change, want second", v)
+	}
+	writeProfile("third")
+	os.Chtimes(profile, past, past)
+	env, _ = loginEnvAt(context.Background(), snapshot, sources, time.Hour, now.Add(2*time.Hour))
+	if v, _ := envValue(env, "FROM_PROFILE"); v != "third" {
+		t.Fatalf("FROM_PROFILE = %q with an expired snapshot, want third", v)
+	}
+}
+
+func TestLoginEnvPath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "/home/codespace/.cache")
+	path, err := LoginEnvPath()
+	if err != nil || path != "/home/codespace/.cache/gh-copilot-codespace/login-env" {
+		t.Fatalf("LoginEnvPath() = %q, %v", path, err)
+	}
+}
+
+func TestParseEnvNul(t *testing.T) {
+	got := parseEnvNul([]byte("PATH=/usr/bin\x00PWD=/home\x00MULTI=a\nb\x00BASH_FUNC_x%%=() { :; }\x00junk\x00EMPTY=\x00"))
+	want := []string{"PATH=/usr/bin", "MULTI=a\nb", "EMPTY="}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseEnvNul() = %q, want %q", got, want)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	got := Overlay([]string{"PATH=/usr/bin", "HOME=/root"}, []string{"PATH=/opt/bin:/usr/bin", "LANG=C.UTF-8"})
+	want := []string{"PATH=/opt/bin:/usr/bin", "HOME=/root", "LANG=C.UTF-8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Overlay() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package codespaceenv
+
+import (
+	"path"
+	"strings"
+)
+
+// WorkspaceDirsCommand lists the directories under /workspaces, marking each
+// with "git" when it holds a repository checkout and "-" otherwise.
+const WorkspaceDirsCommand = `for d in /workspaces/*/; do [ -d "$d" ] || continue; if [ -e "$d.git" ]; then echo "git $d"; else echo "- $d"; fi; done`
+
+// WorkspaceDir is a directory under /workspaces and whether it has a .git.
+type WorkspaceDir struct {
+	Path string
+	Git  bool
+}
+
+// ParseWorkspaceDirs reads WorkspaceDirsCommand output, stripping trailing
+// slashes.
+func ParseWorkspaceDirs(out string) []WorkspaceDir {
+	var dirs []WorkspaceDir
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		marker, p, ok := strings.Cut(strings.TrimSpace(line), " ")
+		p = strings.TrimRight(p, "/")
+		if !ok || p == "" {
+			continue
+		}
+		dirs = append(dirs, WorkspaceDir{Path: p, Git: marker == "git"})
+	}
+	return dirs
+}
+
+// ChooseWorkdir picks the best workspace directory from a list given the repo name.
+// A directory named after the repository is only taken when it is a git
+// checkout; without a match, the only checkout is taken, so dotfiles clones
+// and other leftovers next to the repository are not picked by accident.
+// Returns the best match or "" if the user needs to choose.
+func ChooseWorkdir(dirs []WorkspaceDir, repoName string) string {
+	if len(dirs) == 1 {
+		return dirs[0].Path
+	}
+	var checkouts []string
+	for _, d := range dirs {
+		if !d.Git {
+			continue
+		}
+		if repoName != "" && strings.EqualFold(path.Base(d.Path), repoName) {
+			return d.Path
+		}
+		checkouts = append(checkouts, d.Path)
+	}
+	if len(checkouts) == 1 {
+		return checkouts[0]
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package codespaceenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChooseWorkdir(t *testing.T) {
+	git := func(path string) WorkspaceDir { return WorkspaceDir{Path: path, Git: true} }
+	plain := func(path string) WorkspaceDir { return WorkspaceDir{Path: path} }
+	tests := []struct {
+		name     string
+		dirs     []WorkspaceDir
+		repoName string
+		want     string
+	}{
+		{
+			name:     "single dir",
+			dirs:     []WorkspaceDir{git("/workspaces/github")},
+			repoName: "github",
+			want:     "/workspaces/github",
+		},
+		{
+			name:     "single dir no match needed",
+			dirs:     []WorkspaceDir{plain("/workspaces/other")},
+			repoName: "github",
+			want:     "/workspaces/other",
+		},
+		{
+			name:     "multiple dirs with match",
+			dirs:     []WorkspaceDir{git("/workspaces/github-ui"), git("/workspaces/github")},
+			repoName: "github",
+			want:     "/workspaces/github",
+		},
+		{
+			name:     "match differs in case",
+			dirs:     []WorkspaceDir{git("/workspaces/dotfiles"), git("/workspaces/GitHub")},
+			repoName: "github",
+			want:     "/workspaces/GitHub",
+		},
+		{
+			name:     "match without .git is not taken",
+			dirs:     []WorkspaceDir{plain("/workspaces/github"), git("/workspaces/dotfiles"), git("/workspaces/other")},
+			repoName: "github",
+			want:     "",
+		},
+		{
+			name:     "only checkout is taken without a match",
+			dirs:     []WorkspaceDir{plain("/workspaces/tmp"), git("/workspaces/renamed")},
+			repoName: "github",
+			want:     "/workspaces/renamed",
+		},
+		{
+			name:     "multiple dirs no match",
+			dirs:     []WorkspaceDir{git("/workspaces/foo"), git("/workspaces/bar")},
+			repoName: "github",
+			want:     "",
+		},
+		{
+			name:     "empty repo name",
+			dirs:     []WorkspaceDir{git("/workspaces/foo"), git("/workspaces/bar")},
+			repoName: "",
+			want:     "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ChooseWorkdir(tc.dirs, tc.repoName)
+			if got != tc.want {
+				t.Errorf("ChooseWorkdir(%v, %q) = %q, want %q", tc.dirs, tc.repoName, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseWorkspaceDirs(t *testing.T) {
+	got := ParseWorkspaceDirs("git /workspaces/github/\n- /workspaces/tmp/\n\ngarbage\n")
+	want := []WorkspaceDir{{Path: "/workspaces/github", Git: true}, {Path: "/workspaces/tmp"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseWorkspaceDirs() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/audit"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// AuditRecorder receives one event per mutating tool call. *audit.Logger
+// implements it.
+type AuditRecorder interface {
+	Record(audit.Event)
+}
+
+// auditedTools are the calls that change the codespace or run commands on it.
+var auditedTools = map[string]bool{
+	"remote_bash":         true,
+	"remote_task":         true,
+	"remote_write_bash":   true,
+	"remote_stop_bash":    true,
+	"open_shell":          true,
+	"remote_edit":         true,
+	"remote_create":       true,
+	"remote_scaffold":     true,
+	"remote_ln":           true,
+	"remote_chmod":        true,
+	"remote_gh_run":       true, // dispatch only, see isAuditedCall
+	"remote_ports":        true, // visibility only, see isAuditedCall
+	"remote_compose_up":   true,
+	"remote_compose_down": true,
+	"create_codespace":    true,
+	"delete_codespace":    true,
+	// The devcontainer lifecycle commands run arbitrary repository scripts.
+	"devcontainer_on_create":      true,
+	"devcontainer_update_content": true,
+	"devcontainer_post_create":    true,
+	"devcontainer_post_start":     true,
+	"devcontainer_post_attach":    true,
+}
+
+// auditSizeOnlyArgs hold file contents; events record their size instead.
+var auditSizeOnlyArgs = []string{"file_text", "old_str", "new_str", "archive"}
+
+func isAuditedCall(req mcpsdk.CallToolRequest) bool {
+	name := req.Params.Name
+	switch name {
+	case "remote_gh_run":
+		return optionalString(req, "action") == "dispatch"
+	case "remote_ports":
+		return optionalString(req, "action") == "visibility"
+	}
+	return auditedTools[name]
+}
+
+// auditMiddleware reports audited tool calls to rec after they complete.
+// Arguments are read after the handler runs so they reflect rewrites such as
+// mirror path translation.
+func auditMiddleware(reg *registry.Registry, rec AuditRecorder, session string) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			if !isAuditedCall(req) {
+				return next(ctx, req)
+			}
+			start := time.Now()
+			result, err := next(ctx, req)
+
+			event := audit.Event{
+				Time:       start.UTC(),
+				Tool:       req.Params.Name,
+				Session:    session,
+				Arguments:  auditArguments(req.GetArguments()),
+				Outcome:    "success",
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if cs, rerr := resolveCodespace(reg, req); rerr == nil {
+				event.Codespace = cs.Name
+				event.Alias = cs.Alias
+			}
+			switch {
+			case err != nil:
+				event.Outcome = "error"
+				event.ErrorCategory = string(errInternal)
+			case result != nil && result.IsError:
+				event.Outcome = "error"
+				if result.Meta != nil {
+					if category, ok := result.Meta.AdditionalFields[errorCategoryMetaKey].(string); ok {
+						event.ErrorCategory = category
+					}
+				}
+			}
+			rec.Record(event)
+			return result, err
+		}
+	}
+}
+
+// auditArguments copies args for an audit event, replacing file contents with
+// their size.
+func auditArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+	for _, key := range auditSizeOnlyArgs {
+		if s, ok := out[key].(string); ok {
+			out[key] = fmt.Sprintf("<%d bytes>", len(s))
+		}
+	}
+	// remote_scaffold files map paths to contents.
+	if files, ok := out["files"].(map[string]any); ok {
+		sizes := make(map[string]any, len(files))
+		for p, v := range files {
+			s, _ := v.(string)
+			sizes[p] = fmt.Sprintf("<%d bytes>", len(s))
+		}
+		out["files"] = sizes
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/audit"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+type recordingAuditor struct {
+	events []audit.Event
+}
+
+func (r *recordingAuditor) Record(e audit.Event) { r.events = append(r.events, e) }
+
+func namedReq(name string, args map[string]any) mcpsdk.CallToolRequest {
+	req := makeReq(args)
+	req.Params.Name = name
+	return req
+}
+
+func TestAuditMiddlewareRecordsMutatingCalls(t *testing.T) {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: &mockExecutor{}})
+	rec := &recordingAuditor{}
+	ok := func(_ context.Context, _ mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("done"), nil
+	}
+	fail := func(_ context.Context, _ mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return categorizedError(errPolicyDenied, "outside workdir"), nil
+	}
+	mw := auditMiddleware(reg, rec, "my-session")
+
+	for _, arg := range []string{"path", "file_text"} {
+		if _, ok := createTool().InputSchema.Properties[arg]; !ok {
+			t.Fatalf("remote_create has no %s argument", arg)
+		}
+	}
+	mw(ok)(context.Background(), namedReq("remote_create", map[string]any{"path": "/w/a.txt", "file_text": "hello"}))
+	mw(fail)(context.Background(), namedReq("remote_edit", map[string]any{"path": "/etc/passwd"}))
+	mw(ok)(context.Background(), namedReq("remote_view", map[string]any{"path": "/w/a.txt"}))
+	mw(ok)(context.Background(), namedReq("remote_gh_run", map[string]any{"action": "list"}))
+	mw(ok)(context.Background(), namedReq("remote_gh_run", map[string]any{"action": "dispatch", "workflow": "ci.yml"}))
+	mw(ok)(context.Background(), namedReq("remote_ports", map[string]any{"action": "list"}))
+	mw(ok)(context.Background(), namedReq("remote_ports", map[string]any{"action": "visibility", "port": float64(3000), "visibility": "public"}))
+	mw(ok)(context.Background(), namedReq("devcontainer_post_create", map[string]any{}))
+	mw(ok)(context.Background(), namedReq("remote_compose_up", map[string]any{"services": []any{"web"}}))
+	mw(ok)(context.Background(), namedReq("remote_compose_logs", map[string]any{}))
+
+	if len(rec.events) != 6 {
+		t.Fatalf("recorded %d events, want 6 (create, edit, gh_run dispatch, ports visibility, post_create, compose_up): %+v", len(rec.events), rec.events)
+	}
+	created := rec.events[0]
+	if created.Tool != "remote_create" || created.Codespace != "cs-app" || created.Alias != "app" || created.Session != "my-session" || created.Outcome != "success" {
+		t.Errorf("unexpected create event: %+v", created)
+	}
+	if created.Arguments["file_text"] != "<5 bytes>" {
+		t.Errorf("file_text should be recorded by size, got %v", created.Arguments["file_text"])
+	}
+	if edited := rec.events[1]; edited.Outcome != "error" || edited.ErrorCategory != "policy_denied" {
+		t.Errorf("unexpected edit event: %+v", edited)
+	}
+	if rec.events[2].Tool != "remote_gh_run" {
+		t.Errorf("expected gh_run dispatch event, got %+v", rec.events[2])
+	}
+	if rec.events[3].Tool != "remote_ports" {
+		t.Errorf("expected ports visibility event, got %+v", rec.events[3])
+	}
+	if rec.events[4].Tool != "devcontainer_post_create" {
+		t.Errorf("expected devcontainer_post_create event, got %+v", rec.events[4])
+	}
+	if rec.events[5].Tool != "remote_compose_up" {
+		t.Errorf("expected compose_up event, got %+v", rec.events[5])
+	}
+}
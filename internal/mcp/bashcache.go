@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// bashCacheTTL is how long a read-only remote_bash result is reused.
+	bashCacheTTL = 10 * time.Second
+	// maxBashCacheEntries bounds the cache; the oldest entry is evicted.
+	maxBashCacheEntries = 64
+)
+
+// readOnlyCommandRe matches one pipeline segment that only inspects state.
+// Agents re-run these probes within seconds of each other.
+var readOnlyCommandRe = regexp.MustCompile(`^(?:` +
+	`(?:git (?:status|log|diff|show|rev-parse|ls-files|describe|blame)|` +
+	`ls|cat|head|tail|wc|pwd|stat|file|tree|du|df|grep|rg|find|which|whoami|uname|printenv|` +
+	`go (?:env|list|version)|node --version|npm (?:ls|--version)|python3? --version)(?:\s|$)|` +
+	`git branch(?:\s+(?:-a|-r|-v|-vv|--all|--list|--show-current))*$|` +
+	`git remote(?:\s+-v)?$` +
+	`)`)
+
+// findActionRe matches find actions that run commands or delete files.
+var findActionRe = regexp.MustCompile(`\s-(?:delete|exec|execdir|ok|okdir|fprint\w*|fls)\b`)
+
+// writeOptionRe matches options that make an otherwise read-only probe write
+// a file: git's --output (or an abbreviation of it) and tree's -o.
+var writeOptionRe = regexp.MustCompile(`^git .*\s--out(?:p(?:ut?)?)?(?:=|\s|$)|^tree(?:\s.*)?\s-[A-Za-z]*o`)
+
+// bashSegmentSep splits a command into the segments of its lists and pipelines.
+var bashSegmentSep = regexp.MustCompile(`&&|\|\||[;|]`)
+
+// cacheableCommand reports whether every segment of command is a read-only
+// probe. Redirections, substitutions, background jobs, subshells and groups,
+// find actions, and write options disqualify it.
+func cacheableCommand(command string) bool {
+	if strings.ContainsAny(command, "<>`\n\r({") {
+		return false
+	}
+	// A lone & starts the command before it in the background, so the rest
+	// would not be checked as a segment of its own.
+	if strings.Contains(strings.ReplaceAll(command, "&&", ""), "&") {
+		return false
+	}
+	for _, segment := range bashSegmentSep.Split(command, -1) {
+		segment = strings.TrimSpace(segment)
+		if !readOnlyCommandRe.MatchString(segment) {
+			return false
+		}
+		if strings.HasPrefix(segment, "find ") && findActionRe.MatchString(segment) {
+			return false
+		}
+		if writeOptionRe.MatchString(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+type bashCacheEntry struct {
+	text   string
+	stored time.Time
+}
+
+// bashCache holds recent results of read-only remote_bash commands, keyed by
+// codespace, working directory, command, jq filter, and shell. Any mutating
+// tool call clears it.
+type bashCache struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]bashCacheEntry
+}
+
+func newBashCache() *bashCache {
+	return &bashCache{now: time.Now, entries: make(map[string]bashCacheEntry)}
+}
+
+func (c *bashCache) get(key string) (bashCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || c.now().Sub(e.stored) > bashCacheTTL {
+		delete(c.entries, key)
+		return bashCacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *bashCache) put(key, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxBashCacheEntries {
+		var oldest string
+		for k, e := range c.entries {
+			if oldest == "" || e.stored.Before(c.entries[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = bashCacheEntry{text: text, stored: c.now()}
+}
+
+func (c *bashCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// bashCacheKey returns the cache key for a remote_bash call, or "" if the call
+// must run: async, steps, an explicit shellId or pty, no_cache, or a command
+// that is not read-only.
+func bashCacheKey(reg *registry.Registry, req mcpsdk.CallToolRequest) string {
+	args := req.GetArguments()
+	if optionalBool(req, "no_cache", false) || optionalString(req, "mode") == "async" ||
+		args["steps"] != nil || args["shellId"] != nil || args["pty"] != nil {
+		return ""
+	}
+	command := optionalString(req, "command")
+	if command == "" || !cacheableCommand(command) {
+		return ""
+	}
+	cs, err := resolveCodespace(reg, req)
+	if err != nil {
+		return ""
+	}
+	cwd := optionalString(req, "cwd")
+	if cwd == "" {
+		cwd = cs.Executor.GetWorkdir()
+	}
+	return cs.Alias + "\x00" + cwd + "\x00" + command + "\x00" + optionalString(req, "jq") + "\x00" + optionalString(req, "shell")
+}
+
+// changesCodespace reports whether req may change what read-only commands
+// see: an audited call, or a tool withheld in read-only sessions.
+func changesCodespace(req mcpsdk.CallToolRequest) bool {
+	return isAuditedCall(req) || slices.Contains(mutatingTools, req.Params.Name)
+}
+
+// bashCacheMiddleware answers repeated read-only remote_bash commands from
+// cache for bashCacheTTL, and clears the cache on every mutating call.
+func bashCacheMiddleware(reg *registry.Registry, cache *bashCache) Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			var key string
+			if req.Params.Name == "remote_bash" {
+				key = bashCacheKey(reg, req)
+			}
+			if key == "" {
+				if changesCodespace(req) {
+					cache.clear()
+				}
+				return next(ctx, req)
+			}
+
+			if e, ok := cache.get(key); ok {
+				result := toolSuccess(e.text)
+				age := cache.now().Sub(e.stored).Round(time.Second)
+				prependNote(result, fmt.Sprintf("[cached result from %s ago; pass no_cache: true to re-run]", age))
+				return result, nil
+			}
+			result, err := next(ctx, req)
+			if err == nil && result != nil && !result.IsError && len(result.Content) == 1 {
+				// Only completed commands are cached, not partial output with a shellId.
+				if text, ok := result.Content[0].(mcpsdk.TextContent); ok && !strings.Contains(text.Text, "[shellId: ") {
+					cache.put(key, text.Text)
+				}
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCacheableCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"git status", true},
+		{"git status --short && git log -3 --oneline", true},
+		{"ls -la | head -20", true},
+		{"cat go.mod", true},
+		{"git branch --show-current", true},
+		{"git remote -v", true},
+		{"find . -name '*.go'", true},
+		{"git branch -D old", false},
+		{"git remote add up x", false},
+		{"git commit -m x", false},
+		{"cat a > b", false},
+		{"ls $(rm -rf x)", false},
+		{"ls `rm x`", false},
+		{"ls; rm -rf x", false},
+		{"find . -name '*.tmp' -delete", false},
+		{"find . -exec rm {} +", false},
+		{"lsof", false},
+		{"go test ./...", false},
+		{"ls & rm -rf build", false},
+		{"ls &", false},
+		{"(rm -rf build)", false},
+		{"{ rm -rf build; }", false},
+		{"ls\rrm -rf build", false},
+		{"git diff --output=patch.txt", false},
+		{"git log -p --output patch.txt", false},
+		{"git diff --outp=patch.txt", false},
+		{"git diff --output-indicator-new=+", true},
+		{"tree -o tree.txt", false},
+		{"tree -ao tree.txt", false},
+		{"tree -a --noreport", true},
+	}
+	for _, tt := range tests {
+		if got := cacheableCommand(tt.command); got != tt.want {
+			t.Errorf("cacheableCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestBashCacheMiddleware(t *testing.T) {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: &mockExecutor{workdir: "/workspaces/app"}})
+	cache := newBashCache()
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	runs := 0
+	output := "clean"
+	h := bashCacheMiddleware(reg, cache)(func(_ context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		runs++
+		return toolSuccess(fmt.Sprintf("%s #%d", output, runs)), nil
+	})
+	call := func(name string, args map[string]any) string {
+		t.Helper()
+		result, err := h(context.Background(), namedReq(name, args))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resultText(result)
+	}
+	status := map[string]any{"command": "git status"}
+
+	call("remote_bash", status)
+	now = now.Add(3 * time.Second)
+	if got := call("remote_bash", status); runs != 1 || !strings.Contains(got, "[cached result from 3s ago") || !strings.HasSuffix(got, "clean #1") {
+		t.Errorf("repeat = %q after %d runs, want a cached result", got, runs)
+	}
+	if call("remote_bash", map[string]any{"command": "git status", "no_cache": true}); runs != 2 {
+		t.Errorf("no_cache ran %d times, want 2", runs)
+	}
+	if call("remote_bash", map[string]any{"command": "git status", "cwd": "/workspaces/app/sub"}); runs != 3 {
+		t.Errorf("another cwd ran %d times, want 3", runs)
+	}
+
+	// A mutating call clears the cache.
+	call("remote_bash", status)
+	call("remote_edit", map[string]any{"path": "a"})
+	runs = 0
+	if got := call("remote_bash", status); runs != 1 || strings.Contains(got, "cached") {
+		t.Errorf("after remote_edit = %q, want a fresh run", got)
+	}
+	// So does a mutating remote_bash.
+	call("remote_bash", map[string]any{"command": "git commit -m x"})
+	runs = 0
+	if call("remote_bash", status); runs != 1 {
+		t.Error("after git commit the cached status was reused")
+	}
+	// So does bringing compose services up.
+	call("remote_bash", status)
+	call("remote_compose_up", map[string]any{})
+	runs = 0
+	if call("remote_bash", status); runs != 1 {
+		t.Error("after remote_compose_up the cached status was reused")
+	}
+	// So do the devcontainer lifecycle commands.
+	call("remote_bash", status)
+	call("devcontainer_post_create", map[string]any{})
+	runs = 0
+	if call("remote_bash", status); runs != 1 {
+		t.Error("after devcontainer_post_create the cached status was reused")
+	}
+	// Read-only tools don't.
+	call("remote_view", map[string]any{"path": "a"})
+	runs = 0
+	if call("remote_bash", status); runs != 0 {
+		t.Error("remote_view cleared the cache")
+	}
+
+	now = now.Add(bashCacheTTL + time.Second)
+	if call("remote_bash", status); runs != 1 {
+		t.Error("expired entry was reused")
+	}
+
+	// Partial output of a still-running command is not cached.
+	output = "partial\n\n[shellId: sh-1 — use remote_read_bash to check for more output]"
+	tail := map[string]any{"command": "tail -f log"}
+	call("remote_bash", tail)
+	call("remote_bash", tail)
+	if runs != 3 {
+		t.Errorf("partial output was cached (%d runs)", runs)
+	}
+}
+
+func TestChangesCodespaceCoversMutatingTools(t *testing.T) {
+	// remote_gh_run and remote_ports mutate only for some actions (see
+	// isAuditedCall); remote_cd and connect_codespace change session state,
+	// which cache keys already include, not the codespace.
+	conditional := map[string]bool{"remote_gh_run": true, "remote_ports": true, "remote_cd": true, "connect_codespace": true}
+	for name, tool := range NewServer(registry.New(), LifecycleConfig{RemoteTask: true}).ListTools() {
+		if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly || conditional[name] {
+			continue
+		}
+		if !changesCodespace(namedReq(name, map[string]any{})) {
+			t.Errorf("%s is not read-only but does not clear cached results", name)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// capability is an optional program on the codespace that changes how some
+// tools behave.
+type capability struct {
+	name        string
+	versionArgs string
+	missing     string // what happens without it
+}
+
+var capabilities = []capability{
+	{"rg", "--version", "remote_grep falls back to grep, which is slower and ignores .gitignore"},
+	{"fd", "--version", "remote_glob falls back to find"},
+	{"jq", "--version", "installed with mise the first time remote_bash gets a jq filter"},
+	{"tmux", "-V", "installed with mise the first time a command needs a tmux session; without it, async sessions run under nohup with no terminal"},
+	{"mise", "--version", "installed on demand before jq or tmux"},
+	{"node", "--version", "Node-based MCP servers and remote_task's npx fallback don't run"},
+	{"docker", "--version", "no container builds or runs on the codespace"},
+}
+
+// capabilitiesCommand prints "name<TAB>path<TAB>first version line" per
+// installed program and "name<TAB><TAB>" per missing one, then "agent ok" or
+// "agent missing" when an exec agent path is given. pathSetup puts mise shims
+// on PATH, as for the commands that install with mise.
+func capabilitiesCommand(pathSetup, execAgent string) string {
+	var sb strings.Builder
+	sb.WriteString(pathSetup + "; ")
+	for _, c := range capabilities {
+		fmt.Fprintf(&sb, `p=$(command -v %[1]s 2>/dev/null); if [ -n "$p" ]; then printf '%[1]s\t%%s\t%%s\n' "$p" "$(%[1]s %[2]s 2>&1 | head -n 1)"; else printf '%[1]s\t\t\n'; fi; `, c.name, c.versionArgs)
+	}
+	if execAgent != "" {
+		fmt.Fprintf(&sb, `if [ -x %s ]; then echo 'agent ok'; else echo 'agent missing'; fi; `, shellQuote(execAgent))
+	}
+	return strings.TrimSuffix(sb.String(), " ")
+}
+
+// versionRe finds a dotted version number in a --version line.
+var versionRe = regexp.MustCompile(`\d+(\.\d+)+[0-9A-Za-z.+-]*`)
+
+// formatCapabilities renders capabilitiesCommand output, one line per program
+// and one for the exec agent.
+func formatCapabilities(out, execAgent string) string {
+	found := make(map[string][2]string)
+	agentOK := false
+	for _, line := range strings.Split(out, "\n") {
+		if line == "agent ok" {
+			agentOK = true
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) == 3 && fields[1] != "" {
+			found[fields[0]] = [2]string{fields[1], fields[2]}
+		}
+	}
+
+	var sb strings.Builder
+	for _, c := range capabilities {
+		f, ok := found[c.name]
+		if !ok {
+			fmt.Fprintf(&sb, "%s: not installed (%s)\n", c.name, c.missing)
+			continue
+		}
+		version := versionRe.FindString(f[1])
+		if version == "" {
+			version = "unknown version"
+		}
+		fmt.Fprintf(&sb, "%s: %s (%s)\n", c.name, version, f[0])
+	}
+	switch {
+	case execAgent == "":
+		sb.WriteString("exec agent: not deployed (remote_bash steps run as a plain script and hooks are quoted for the shell)")
+	case agentOK:
+		fmt.Fprintf(&sb, "exec agent: deployed at %s", execAgent)
+	default:
+		fmt.Fprintf(&sb, "exec agent: missing from %s (steps and hooks that use it will fail; relaunch to redeploy)", execAgent)
+	}
+	return sb.String()
+}
+
+// --- remote_capabilities ---
+
+func capabilitiesTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_capabilities",
+		Annotations: readOnlyHints("Probe remote capabilities", false),
+		Description: "Report which optional programs are installed on the codespace (rg, fd, jq, tmux, mise, node, docker) with their versions, and whether the exec agent is deployed. " +
+			"Each missing program is listed with what changes without it, e.g. remote_grep falling back to grep. Use this to explain slow or unexpected tool behavior before working around it.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+			},
+		},
+	}
+}
+
+func capabilitiesHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, capabilitiesCommand(misePathSetup(cs.Executor), cs.ExecAgent), "")
+		if err != nil {
+			return toolError(fmt.Sprintf("probing capabilities: %v", err)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("probing capabilities failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		return toolSuccess(formatCapabilities(stdout, cs.ExecAgent)), nil
+	}
+}
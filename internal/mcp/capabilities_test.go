@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+func TestCapabilitiesCommandRunsLocally(t *testing.T) {
+	// A fake rg and an agent on PATH stand in for the codespace's programs.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "rg"), []byte("#!/bin/sh\necho 'ripgrep 14.1.0 (rev abc)'\necho '+SIMD'\n"), 0o755)
+	agent := filepath.Join(dir, "agent")
+	os.WriteFile(agent, []byte("#!/bin/sh\n"), 0o755)
+
+	cmd := exec.Command("bash", "-c", capabilitiesCommand(ssh.MisePATH, agent))
+	cmd.Env = append(os.Environ(), "PATH="+dir+":/usr/bin:/bin")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running capabilities command: %v", err)
+	}
+	text := formatCapabilities(string(out), agent)
+	for _, want := range []string{"rg: 14.1.0 (" + filepath.Join(dir, "rg") + ")\n", "exec agent: deployed at " + agent} {
+		if !strings.Contains(text, want) {
+			t.Errorf("capabilities missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestFormatCapabilities(t *testing.T) {
+	out := "rg\t/usr/bin/rg\tripgrep 13.0.0\n" +
+		"fd\t\t\n" +
+		"jq\t/usr/bin/jq\tjq-1.7.1\n" +
+		"tmux\t/usr/bin/tmux\ttmux 3.4\n" +
+		"mise\t\t\n" +
+		"node\t/usr/local/bin/node\tv20.11.0\n" +
+		"docker\t/usr/bin/docker\tDocker version 24.0.7, build afdd53b\n" +
+		"agent missing\n"
+	want := "rg: 13.0.0 (/usr/bin/rg)\n" +
+		"fd: not installed (remote_glob falls back to find)\n" +
+		"jq: 1.7.1 (/usr/bin/jq)\n" +
+		"tmux: 3.4 (/usr/bin/tmux)\n" +
+		"mise: not installed (installed on demand before jq or tmux)\n" +
+		"node: 20.11.0 (/usr/local/bin/node)\n" +
+		"docker: 24.0.7 (/usr/bin/docker)\n" +
+		"exec agent: missing from /tmp/agent (steps and hooks that use it will fail; relaunch to redeploy)"
+	if got := formatCapabilities(out, "/tmp/agent"); got != want {
+		t.Errorf("formatCapabilities() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCapabilitiesHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "rg\t/usr/bin/rg\tripgrep 14.1.0\n"}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: mock})
+
+	res, _ := capabilitiesHandler(reg)(context.Background(), makeReq(nil))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	text := resultText(res)
+	if !strings.HasPrefix(text, "rg: 14.1.0 (/usr/bin/rg)\nfd: not installed") || !strings.HasSuffix(text, "exec agent: not deployed (remote_bash steps run as a plain script and hooks are quoted for the shell)") {
+		t.Errorf("result = %q", text)
+	}
+	if strings.Contains(mock.lastRunBashCommand, "agent ok") {
+		t.Errorf("probed an exec agent that was never deployed: %q", mock.lastRunBashCommand)
+	}
+}
+
+func TestCapabilitiesHandler_UsesExecutorMisePath(t *testing.T) {
+	homeMock := &miseHomeExecutor{mockExecutor: &mockExecutor{}}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: homeMock})
+
+	capabilitiesHandler(reg)(context.Background(), makeReq(nil))
+	if !strings.HasPrefix(homeMock.lastRunBashCommand, homeMock.MisePathSetup()+"; ") {
+		t.Errorf("command = %q, want the executor's mise PATH", homeMock.lastRunBashCommand)
+	}
+}
@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// clockProbeCommand prints the codespace clock as fractional epoch seconds,
+// then its UTC offset and zone abbreviation.
+const clockProbeCommand = `date '+%s.%N %z %Z'`
+
+// clockSkewThreshold is the clock offset worth mentioning; smaller offsets are
+// within the measurement error of one SSH round trip.
+const clockSkewThreshold = 2 * time.Second
+
+// ProbeClock reports the codespace's time zone and how far its clock is ahead
+// of the local clock. An empty zone means the probe failed.
+func ProbeClock(ctx context.Context, ex ssh.Executor) (zone string, utcOffset int, clockOffset time.Duration) {
+	before := time.Now()
+	stdout, _, exitCode, err := ex.RunBash(ctx, clockProbeCommand, "")
+	after := time.Now()
+	if err != nil || exitCode != 0 {
+		return "", 0, 0
+	}
+	remote, zone, utcOffset, ok := parseClockProbe(stdout)
+	if !ok {
+		return "", 0, 0
+	}
+	if remote.IsZero() {
+		return zone, utcOffset, 0
+	}
+	// Compare against the midpoint of the round trip.
+	local := before.Add(after.Sub(before) / 2)
+	return zone, utcOffset, remote.Sub(local).Round(time.Second)
+}
+
+// parseClockProbe parses clockProbeCommand output. remote is zero when the
+// timestamp is unusable (e.g. a date without %N support).
+func parseClockProbe(out string) (remote time.Time, zone string, utcOffset int, ok bool) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return time.Time{}, "", 0, false
+	}
+	utcOffset, ok = parseUTCOffset(fields[1])
+	if !ok {
+		return time.Time{}, "", 0, false
+	}
+	if secs, err := strconv.ParseFloat(fields[0], 64); err == nil && secs > 0 {
+		whole, frac := math.Modf(secs)
+		remote = time.Unix(int64(whole), int64(frac*1e9))
+	}
+	return remote, fields[2], utcOffset, true
+}
+
+// parseUTCOffset converts a date +%z offset such as "+0530" to seconds.
+func parseUTCOffset(s string) (int, bool) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(s[1:3])
+	minutes, err2 := strconv.Atoi(s[3:5])
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	secs := hours*3600 + minutes*60
+	if s[0] == '-' {
+		secs = -secs
+	}
+	return secs, true
+}
+
+// formatUTCOffset renders an offset in seconds as "UTC+05:30".
+func formatUTCOffset(secs int) string {
+	sign := "+"
+	if secs < 0 {
+		sign = "-"
+		secs = -secs
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, secs/3600, secs%3600/60)
+}
+
+// ZoneSummary renders a probed time zone, e.g. "UTC (UTC+00:00)". It returns
+// "" when the zone is unknown.
+func ZoneSummary(zone string, utcOffset int) string {
+	if zone == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", zone, formatUTCOffset(utcOffset))
+}
+
+// LocalZoneSummary renders the local time zone like ZoneSummary.
+func LocalZoneSummary() string {
+	zone, offset := time.Now().Zone()
+	return ZoneSummary(zone, offset)
+}
+
+// ClockInstructions returns instruction context about the codespace's time
+// zone relative to the user's, or "" when the zone is unknown.
+func ClockInstructions(zone string, utcOffset int, clockOffset time.Duration) string {
+	localZone, localOffset := time.Now().Zone()
+	return clockInstructions(zone, utcOffset, clockOffset, localZone, localOffset)
+}
+
+func clockInstructions(zone string, utcOffset int, clockOffset time.Duration, localZone string, localOffset int) string {
+	if zone == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Codespace clock\n\n")
+	if utcOffset == localOffset {
+		fmt.Fprintf(&sb, "The codespace and the user's machine are both on %s (%s), so remote timestamps need no conversion.\n", formatUTCOffset(utcOffset), zone)
+	} else {
+		fmt.Fprintf(&sb, "The codespace time zone is %s; the user's local time zone is %s. ", ZoneSummary(zone, utcOffset), ZoneSummary(localZone, localOffset))
+		sb.WriteString("Times printed by remote commands (`date`, `ls -l`, `git log`, log files) are codespace time: convert them before comparing with times the user mentions, and give filters explicit offsets (e.g. `git log --since=\"2024-05-01T09:00" + strings.TrimPrefix(formatUTCOffset(localOffset), "UTC") + "\"`).\n")
+	}
+	if note := clockSkewNote(clockOffset); note != "" {
+		fmt.Fprintf(&sb, "- %s\n", note)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// clockSkewNote describes a clock offset past clockSkewThreshold.
+func clockSkewNote(offset time.Duration) string {
+	switch {
+	case offset >= clockSkewThreshold:
+		return fmt.Sprintf("The codespace clock is %s ahead of the local clock; allow for it when comparing file times with local events.", offset)
+	case offset <= -clockSkewThreshold:
+		return fmt.Sprintf("The codespace clock is %s behind the local clock; allow for it when comparing file times with local events.", -offset)
+	}
+	return ""
+}
+
+// connectedClockNote appends the time zone context to a connect result.
+func connectedClockNote(cs *registry.ManagedCodespace) string {
+	text := ClockInstructions(cs.TimeZone, cs.UTCOffset, cs.ClockOffset)
+	if text == "" {
+		return ""
+	}
+	return "\n\n" + strings.TrimSpace(text)
+}
@@ -0,0 +1,93 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClockProbe(t *testing.T) {
+	tests := []struct {
+		out        string
+		wantOK     bool
+		wantZone   string
+		wantOffset int
+		wantUnix   int64
+	}{
+		{"1700000000.500000000 +0000 UTC\n", true, "UTC", 0, 1700000000},
+		{"1700000000.000000000 +0530 IST\n", true, "IST", 5*3600 + 30*60, 1700000000},
+		{"1700000000.000000000 -0700 PDT\n", true, "PDT", -7 * 3600, 1700000000},
+		{"1700000000.N +0100 CET\n", true, "CET", 3600, 0},
+		{"+0000 UTC\n", false, "", 0, 0},
+		{"", false, "", 0, 0},
+		{"1700000000 0000 UTC\n", false, "", 0, 0},
+	}
+	for _, tt := range tests {
+		remote, zone, offset, ok := parseClockProbe(tt.out)
+		if ok != tt.wantOK || zone != tt.wantZone || offset != tt.wantOffset {
+			t.Errorf("parseClockProbe(%q) = %q, %d, %v; want %q, %d, %v", tt.out, zone, offset, ok, tt.wantZone, tt.wantOffset, tt.wantOK)
+		}
+		var gotUnix int64
+		if !remote.IsZero() {
+			gotUnix = remote.Unix()
+		}
+		if gotUnix != tt.wantUnix {
+			t.Errorf("parseClockProbe(%q) time = %d, want %d", tt.out, gotUnix, tt.wantUnix)
+		}
+	}
+}
+
+func TestProbeClock(t *testing.T) {
+	ahead := time.Now().Add(90 * time.Second)
+	mock := &mockExecutor{runBashStdout: fmt.Sprintf("%d.%09d +0000 UTC\n", ahead.Unix(), ahead.Nanosecond())}
+	zone, offset, clockOffset := ProbeClock(context.Background(), mock)
+	if zone != "UTC" || offset != 0 {
+		t.Errorf("ProbeClock zone = %q, %d; want UTC, 0", zone, offset)
+	}
+	if clockOffset < 89*time.Second || clockOffset > 91*time.Second {
+		t.Errorf("ProbeClock clock offset = %s, want about 90s", clockOffset)
+	}
+	if mock.lastRunBashCommand != clockProbeCommand {
+		t.Errorf("command = %q", mock.lastRunBashCommand)
+	}
+
+	if zone, _, _ := ProbeClock(context.Background(), &mockExecutor{runBashExit: 1}); zone != "" {
+		t.Errorf("failed probe zone = %q, want empty", zone)
+	}
+}
+
+func TestFormatUTCOffset(t *testing.T) {
+	for secs, want := range map[int]string{0: "UTC+00:00", 7200: "UTC+02:00", 19800: "UTC+05:30", -25200: "UTC-07:00"} {
+		if got := formatUTCOffset(secs); got != want {
+			t.Errorf("formatUTCOffset(%d) = %q, want %q", secs, got, want)
+		}
+	}
+}
+
+func TestClockInstructions(t *testing.T) {
+	if got := clockInstructions("", 0, 0, "UTC", 0); got != "" {
+		t.Errorf("unknown zone should produce no instructions, got %q", got)
+	}
+
+	same := clockInstructions("UTC", 0, 0, "UTC", 0)
+	if !strings.Contains(same, "need no conversion") {
+		t.Errorf("same zone instructions = %q", same)
+	}
+
+	differ := clockInstructions("UTC", 0, time.Second, "CEST", 7200)
+	for _, want := range []string{"## Codespace clock", "UTC (UTC+00:00)", "CEST (UTC+02:00)", "--since=\"2024-05-01T09:00+02:00\""} {
+		if !strings.Contains(differ, want) {
+			t.Errorf("instructions missing %q:\n%s", want, differ)
+		}
+	}
+	if strings.Contains(differ, "ahead") {
+		t.Errorf("1s offset is below the skew threshold:\n%s", differ)
+	}
+
+	skewed := clockInstructions("UTC", 0, -3*time.Minute, "UTC", 0)
+	if !strings.Contains(skewed, "3m0s behind the local clock") {
+		t.Errorf("skewed instructions = %q", skewed)
+	}
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// withConfinement wraps a file tool handler so that, when enabled, the paths
+// in the named arguments must resolve inside the codespace workdir. Paths are
+// resolved on the codespace with realpath -m, so ".." segments and symlinks
+// leading out of the workspace (to ~/.ssh, say) are both caught, while files
+// that don't exist yet still resolve. Array arguments may hold paths or
+// objects with a "path" field.
+func withConfinement(reg *registry.Registry, enabled bool, next server.ToolHandlerFunc, keys ...string) server.ToolHandlerFunc {
+	if !enabled {
+		return next
+	}
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return next(ctx, req)
+		}
+		paths := confinedArgPaths(req.GetArguments(), keys)
+		if len(paths) == 0 {
+			return next(ctx, req)
+		}
+
+		root := workspaceRoot(cs)
+		base := optionalString(req, "cwd")
+		if base == "" {
+			base = cs.Executor.GetWorkdir()
+		}
+		if !path.IsAbs(base) {
+			base = path.Join(root, base)
+		}
+		absolute := make([]string, len(paths))
+		for i, p := range paths {
+			absolute[i] = p
+			if !path.IsAbs(p) {
+				absolute[i] = path.Join(base, p)
+			}
+		}
+
+		resolved, err := resolveRemotePaths(ctx, cs, append([]string{root}, absolute...))
+		if err != nil {
+			return categorizedError(errInternal, fmt.Sprintf("could not resolve paths for workdir confinement: %v", err)), nil
+		}
+		realRoot := resolved[0]
+		for i, p := range paths {
+			if !pathWithin(realRoot, resolved[i+1]) {
+				msg := fmt.Sprintf("path %s is outside the workspace %s", p, root)
+				if resolved[i+1] != path.Clean(absolute[i]) {
+					msg = fmt.Sprintf("path %s resolves to %s, outside the workspace %s", p, resolved[i+1], root)
+				}
+				return categorizedError(errPolicyDenied, msg+"; file tools are confined to the workdir in this session"), nil
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// confinedArgPaths collects the non-empty paths in args under keys.
+func confinedArgPaths(args map[string]any, keys []string) []string {
+	var paths []string
+	add := func(p string) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	for _, key := range keys {
+		switch v := args[key].(type) {
+		case string:
+			add(v)
+		case []any:
+			for _, item := range v {
+				switch item := item.(type) {
+				case string:
+					add(item)
+				case map[string]any:
+					if p, ok := item["path"].(string); ok {
+						add(p)
+					}
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// resolveRemotePaths canonicalizes absolute paths on the codespace, following
+// symlinks for the components that exist.
+func resolveRemotePaths(ctx context.Context, cs *registry.ManagedCodespace, paths []string) ([]string, error) {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, "realpath -m -z -- "+strings.Join(quoted, " "), "")
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("realpath exited %d: %s", exitCode, strings.TrimSpace(stderr))
+	}
+	resolved := strings.Split(strings.TrimSuffix(stdout, "\x00"), "\x00")
+	if len(resolved) != len(paths) {
+		return nil, fmt.Errorf("realpath returned %d paths for %d", len(resolved), len(paths))
+	}
+	return resolved, nil
+}
@@ -0,0 +1,83 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestWithConfinement(t *testing.T) {
+	reached := func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("ran"), nil
+	}
+	tests := []struct {
+		name     string
+		args     map[string]any
+		resolved string
+		wantErr  string
+	}{
+		{
+			name:     "inside",
+			args:     map[string]any{"path": "src/main.go"},
+			resolved: "/workspaces/repo\x00/workspaces/repo/src/main.go\x00",
+		},
+		{
+			name:     "dot-dot escape",
+			args:     map[string]any{"path": "../../etc/passwd"},
+			resolved: "/workspaces/repo\x00/etc/passwd\x00",
+			wantErr:  "path ../../etc/passwd is outside the workspace /workspaces/repo",
+		},
+		{
+			name:     "symlink escape",
+			args:     map[string]any{"path": "keys"},
+			resolved: "/workspaces/repo\x00/home/codespace/.ssh\x00",
+			wantErr:  "path keys resolves to /home/codespace/.ssh, outside the workspace",
+		},
+		{
+			name:     "array of objects",
+			args:     map[string]any{"path": []any{map[string]any{"path": "a"}, "/root/.bashrc"}},
+			resolved: "/workspaces/repo\x00/workspaces/repo/a\x00/root/.bashrc\x00",
+			wantErr:  "path /root/.bashrc is outside",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockExecutor{workdir: "/workspaces/repo", runBashStdout: tt.resolved}
+			h := withConfinement(testRegWithWorkdir(mock, "/workspaces/repo"), true, reached, "path")
+			result, err := h(context.Background(), makeReq(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := resultText(result)
+			if tt.wantErr == "" {
+				if text != "ran" {
+					t.Errorf("result = %q, want the handler to run", text)
+				}
+			} else if !result.IsError || !strings.Contains(text, tt.wantErr) || !strings.Contains(text, "policy_denied") {
+				t.Errorf("result = %q, want policy_denied containing %q", text, tt.wantErr)
+			}
+			if !strings.HasPrefix(mock.lastRunBashCommand, "realpath -m -z -- '/workspaces/repo' ") {
+				t.Errorf("command = %q", mock.lastRunBashCommand)
+			}
+		})
+	}
+}
+
+func TestWithConfinementDisabledOrUnresolvable(t *testing.T) {
+	reached := func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("ran"), nil
+	}
+	mock := &mockExecutor{}
+	result, _ := withConfinement(testRegWithWorkdir(mock, "/workspaces/repo"), false, reached, "path")(context.Background(), makeReq(map[string]any{"path": "/etc/passwd"}))
+	if resultText(result) != "ran" || mock.runBashCalls != 0 {
+		t.Errorf("disabled confinement = %q after %d calls", resultText(result), mock.runBashCalls)
+	}
+
+	mock = &mockExecutor{runBashExit: 1, runBashStderr: "realpath: not found"}
+	result, _ = withConfinement(testRegWithWorkdir(mock, "/workspaces/repo"), true, reached, "path")(context.Background(), makeReq(map[string]any{"path": "a"}))
+	if !result.IsError || !strings.Contains(resultText(result), "could not resolve paths") {
+		t.Errorf("unresolvable path = %q, want an error", resultText(result))
+	}
+}
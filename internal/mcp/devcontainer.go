@@ -0,0 +1,293 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// devcontainerHooks are the lifecycle properties of devcontainer.json, in the
+// order a codespace runs them, with the tool that re-runs each.
+var devcontainerHooks = []struct {
+	property, tool, when string
+}{
+	{"onCreateCommand", "devcontainer_on_create", "once, when the container was created"},
+	{"updateContentCommand", "devcontainer_update_content", "after onCreateCommand, and again whenever prebuilds pick up new content"},
+	{"postCreateCommand", "devcontainer_post_create", "once the container was created and assigned to the user"},
+	{"postStartCommand", "devcontainer_post_start", "each time the container starts"},
+	{"postAttachCommand", "devcontainer_post_attach", "each time a client attaches"},
+}
+
+// devcontainerProbeCommand prints the path of the workspace's devcontainer.json
+// on the first line, then its contents. It prints nothing without one.
+const devcontainerProbeCommand = `f=.devcontainer/devcontainer.json; [ -f "$f" ] || f=.devcontainer.json; ` +
+	`if [ -f "$f" ]; then echo "$f"; cat "$f"; fi`
+
+// ProbeDevcontainerHooks reads the lifecycle commands from the devcontainer.json
+// in workdir. It returns the file's path, or "" without one; err reports a file
+// that could not be parsed.
+func ProbeDevcontainerHooks(ctx context.Context, ex ssh.Executor, workdir string) (file string, hooks []registry.DevcontainerHook, err error) {
+	stdout, _, exitCode, err := ex.RunBash(ctx, devcontainerProbeCommand, workdir)
+	if err != nil || exitCode != 0 {
+		return "", nil, nil
+	}
+	file, data, _ := strings.Cut(stdout, "\n")
+	if file == "" {
+		return "", nil, nil
+	}
+	hooks, err = parseDevcontainerHooks([]byte(data), workdir)
+	if err != nil {
+		return file, nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return file, hooks, nil
+}
+
+// parseDevcontainerHooks extracts the lifecycle commands from devcontainer.json,
+// substituting the workspace folder variables with workdir.
+func parseDevcontainerHooks(data []byte, workdir string) ([]registry.DevcontainerHook, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(stripJSONC(data), &config); err != nil {
+		return nil, err
+	}
+	vars := strings.NewReplacer(
+		"${containerWorkspaceFolder}", workdir,
+		"${localWorkspaceFolder}", workdir,
+		"${containerWorkspaceFolderBasename}", path.Base(workdir),
+		"${localWorkspaceFolderBasename}", path.Base(workdir),
+	)
+	var hooks []registry.DevcontainerHook
+	for _, h := range devcontainerHooks {
+		raw, ok := config[h.property]
+		if !ok {
+			continue
+		}
+		commands, err := parseDevcontainerCommand(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.property, err)
+		}
+		for i := range commands {
+			commands[i].Shell = vars.Replace(commands[i].Shell)
+			for j, arg := range commands[i].Args {
+				commands[i].Args[j] = vars.Replace(arg)
+			}
+		}
+		if len(commands) > 0 {
+			hooks = append(hooks, registry.DevcontainerHook{Property: h.property, Commands: commands})
+		}
+	}
+	return hooks, nil
+}
+
+// parseDevcontainerCommand accepts the three forms a lifecycle property can
+// take: a string, an argument array, or an object of named commands in
+// either form. Empty commands are dropped.
+func parseDevcontainerCommand(raw json.RawMessage) ([]registry.DevcontainerCommand, error) {
+	single := func(raw json.RawMessage) (registry.DevcontainerCommand, error) {
+		var shell string
+		if err := json.Unmarshal(raw, &shell); err == nil {
+			return registry.DevcontainerCommand{Shell: strings.TrimSpace(shell)}, nil
+		}
+		var args []string
+		if err := json.Unmarshal(raw, &args); err == nil {
+			return registry.DevcontainerCommand{Args: args}, nil
+		}
+		return registry.DevcontainerCommand{}, fmt.Errorf("want a string, an array of strings, or an object of them")
+	}
+	empty := func(c registry.DevcontainerCommand) bool { return c.Shell == "" && len(c.Args) == 0 }
+
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, nil
+	}
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &named); err != nil {
+		c, err := single(raw)
+		if err != nil || empty(c) {
+			return nil, err
+		}
+		return []registry.DevcontainerCommand{c}, nil
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var commands []registry.DevcontainerCommand
+	for _, name := range names {
+		c, err := single(named[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !empty(c) {
+			c.Name = name
+			commands = append(commands, c)
+		}
+	}
+	return commands, nil
+}
+
+// stripJSONC turns devcontainer.json's JSON with comments into plain JSON:
+// comments become spaces and trailing commas are dropped.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			c = '\n'
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+			c = ' '
+		case c == '}' || c == ']':
+			// Drop a comma left before the closing bracket.
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// devcontainerCommandLine renders one command as shell text: string commands
+// go through sh -c like the devcontainer CLI runs them, argument lists are
+// quoted and run directly.
+func devcontainerCommandLine(c registry.DevcontainerCommand) string {
+	if len(c.Args) == 0 {
+		return "sh -c " + shellQuote(c.Shell)
+	}
+	quoted := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// devcontainerHookScript runs a hook's commands. A single command runs as is;
+// named commands run in parallel with their output prefixed by name, and the
+// script fails if any of them does.
+func devcontainerHookScript(hook registry.DevcontainerHook) string {
+	if len(hook.Commands) == 1 && hook.Commands[0].Name == "" {
+		return devcontainerCommandLine(hook.Commands[0])
+	}
+	var sb strings.Builder
+	sb.WriteString("set -o pipefail; rc=0\n")
+	for i, c := range hook.Commands {
+		fmt.Fprintf(&sb, "{ %s; } 2>&1 | awk -v p=%s '{ print p $0; fflush() }' & p%d=$!\n",
+			devcontainerCommandLine(c), shellQuote("["+c.Name+"] "), i)
+	}
+	for i, c := range hook.Commands {
+		fmt.Fprintf(&sb, "wait $p%d || { rc=$?; echo %s\"$rc\"; }\n", i, shellQuote("["+c.Name+"] failed with exit code "))
+	}
+	sb.WriteString("exit $rc")
+	return sb.String()
+}
+
+// describeDevcontainerHook lists a hook's commands for the tool output.
+func describeDevcontainerHook(hook registry.DevcontainerHook) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s:", hook.Property)
+	for _, c := range hook.Commands {
+		line := c.Shell
+		if len(c.Args) > 0 {
+			line = strings.Join(c.Args, " ")
+		}
+		if c.Name != "" {
+			line = c.Name + ": " + line
+		}
+		sb.WriteString("\n  " + line)
+	}
+	return sb.String()
+}
+
+// --- devcontainer_* ---
+
+func devcontainerHookTool(property, name, when string) mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        name,
+		Annotations: mutatingHints("Run "+property, false, false, false),
+		Description: fmt.Sprintf("Re-run the %s declared in the codespace's devcontainer.json (read when the codespace connected), from the workspace root. "+
+			"The codespace ran it %s. Use it after changing dependencies or setup files to repeat the project's own bootstrap instead of guessing it. "+
+			"Named commands run in parallel with their output prefixed by name. Fails when the devcontainer.json does not declare %s.", property, when, property),
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"mode": map[string]any{
+					"type":        "string",
+					"description": "sync (default) waits for the commands; async starts them in a background shell to follow with read_bash, for long installs",
+					"enum":        []string{"sync", "async"},
+				},
+			},
+		},
+	}
+}
+
+func devcontainerHookHandler(reg *registry.Registry, property string) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		var hook *registry.DevcontainerHook
+		declared := make([]string, 0, len(cs.DevcontainerHooks))
+		for i, h := range cs.DevcontainerHooks {
+			declared = append(declared, h.Property)
+			if h.Property == property {
+				hook = &cs.DevcontainerHooks[i]
+			}
+		}
+		if hook == nil {
+			msg := fmt.Sprintf("%s's devcontainer.json declares no %s", cs.Alias, property)
+			if len(declared) > 0 {
+				msg += " (it declares " + strings.Join(declared, ", ") + ")"
+			}
+			return categorizedError(errNotFound, msg), nil
+		}
+
+		script := devcontainerHookScript(*hook)
+		if optionalString(req, "mode") == "async" {
+			shellId := fmt.Sprintf("%s-%d", property, time.Now().UnixMilli())
+			if err := cs.Executor.StartSession(ctx, shellId, script, ""); err != nil {
+				return toolError(err.Error()), nil
+			}
+			return toolSuccess(fmt.Sprintf("Started async session: %s\n\n%s\n\nFollow it with read_bash.", shellId, describeDevcontainerHook(*hook))), nil
+		}
+		result, _ := runBashSyncFallback(ctx, cs.Executor, script, "", false)
+		if result.IsError {
+			return result, nil
+		}
+		prependNote(result, "Ran "+describeDevcontainerHook(*hook)+"\n")
+		return result, nil
+	}
+}
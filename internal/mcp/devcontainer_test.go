@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+const devcontainerJSON = `{
+	// Comments and trailing commas are allowed in devcontainer.json.
+	"name": "app // not a comment",
+	"image": "mcr.microsoft.com/devcontainers/go", /* block */
+	"onCreateCommand": ["go", "mod", "download", "-C", "${containerWorkspaceFolder}"],
+	"postCreateCommand": "script/bootstrap && echo done",
+	"postStartCommand": {
+		"server": "make serve",
+		"db": ["docker", "compose", "up", "-d",],
+	},
+	"postAttachCommand": "",
+}`
+
+func TestParseDevcontainerHooks(t *testing.T) {
+	hooks, err := parseDevcontainerHooks([]byte(devcontainerJSON), "/workspaces/app")
+	if err != nil {
+		t.Fatalf("parseDevcontainerHooks() error = %v", err)
+	}
+	want := []registry.DevcontainerHook{
+		{Property: "onCreateCommand", Commands: []registry.DevcontainerCommand{{Args: []string{"go", "mod", "download", "-C", "/workspaces/app"}}}},
+		{Property: "postCreateCommand", Commands: []registry.DevcontainerCommand{{Shell: "script/bootstrap && echo done"}}},
+		{Property: "postStartCommand", Commands: []registry.DevcontainerCommand{
+			{Name: "db", Args: []string{"docker", "compose", "up", "-d"}},
+			{Name: "server", Shell: "make serve"},
+		}},
+	}
+	if !reflect.DeepEqual(hooks, want) {
+		t.Errorf("hooks = %+v\nwant %+v", hooks, want)
+	}
+}
+
+func TestParseDevcontainerHooks_RejectsBadCommand(t *testing.T) {
+	if _, err := parseDevcontainerHooks([]byte(`{"postCreateCommand": 42}`), "/w"); err == nil || !strings.Contains(err.Error(), "postCreateCommand") {
+		t.Errorf("error = %v, want one naming postCreateCommand", err)
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	got := string(stripJSONC([]byte("{\"a\": \"x//y, ]\", // c\n\"b\": [1, 2, /* c */ ], }")))
+	want := "{\"a\": \"x//y, ]\", \n\"b\": [1, 2    ] }"
+	if strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(want, " ", "") {
+		t.Errorf("stripJSONC() = %q, want %q", got, want)
+	}
+}
+
+func TestDevcontainerHookScript(t *testing.T) {
+	single := registry.DevcontainerHook{Property: "postCreateCommand", Commands: []registry.DevcontainerCommand{{Shell: "npm ci"}}}
+	if got := devcontainerHookScript(single); got != "sh -c 'npm ci'" {
+		t.Errorf("single = %q", got)
+	}
+	args := registry.DevcontainerHook{Property: "onCreateCommand", Commands: []registry.DevcontainerCommand{{Args: []string{"echo", "a b"}}}}
+	if got := devcontainerHookScript(args); got != "'echo' 'a b'" {
+		t.Errorf("args = %q", got)
+	}
+	named := registry.DevcontainerHook{Property: "postStartCommand", Commands: []registry.DevcontainerCommand{
+		{Name: "db", Args: []string{"docker", "compose", "up"}},
+		{Name: "server", Shell: "make serve"},
+	}}
+	script := devcontainerHookScript(named)
+	for _, want := range []string{
+		"{ 'docker' 'compose' 'up'; } 2>&1 | awk -v p='[db] '",
+		"{ sh -c 'make serve'; } 2>&1 | awk -v p='[server] '",
+		"wait $p1 || { rc=$?;",
+		"exit $rc",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("named script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestProbeDevcontainerHooks(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: ".devcontainer/devcontainer.json\n" + devcontainerJSON}
+	file, hooks, err := ProbeDevcontainerHooks(context.Background(), mock, "/workspaces/app")
+	if err != nil || file != ".devcontainer/devcontainer.json" || len(hooks) != 3 {
+		t.Fatalf("ProbeDevcontainerHooks() = %q, %d hooks, %v", file, len(hooks), err)
+	}
+	if mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("probe cwd = %q", mock.lastRunBashCwd)
+	}
+
+	file, hooks, err = ProbeDevcontainerHooks(context.Background(), &mockExecutor{}, "/workspaces/app")
+	if file != "" || hooks != nil || err != nil {
+		t.Errorf("without devcontainer.json = %q, %v, %v", file, hooks, err)
+	}
+
+	_, _, err = ProbeDevcontainerHooks(context.Background(), &mockExecutor{runBashStdout: ".devcontainer.json\n{oops"}, "/w")
+	if err == nil || !strings.Contains(err.Error(), ".devcontainer.json") {
+		t.Errorf("invalid file error = %v", err)
+	}
+}
+
+func devcontainerReg(mock *mockExecutor) *registry.Registry {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: mock, DevcontainerHooks: []registry.DevcontainerHook{
+		{Property: "postCreateCommand", Commands: []registry.DevcontainerCommand{{Shell: "script/bootstrap"}}},
+	}})
+	return reg
+}
+
+func TestDevcontainerHookHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "bootstrapped\n"}
+	res, _ := devcontainerHookHandler(devcontainerReg(mock), "postCreateCommand")(context.Background(), makeReq(nil))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	if mock.lastRunBashCommand != "sh -c 'script/bootstrap'" {
+		t.Errorf("command = %q", mock.lastRunBashCommand)
+	}
+	if text := resultText(res); !strings.HasPrefix(text, "Ran postCreateCommand:\n  script/bootstrap") || !strings.Contains(text, "bootstrapped") {
+		t.Errorf("result = %q", text)
+	}
+}
+
+func TestDevcontainerHookHandler_Async(t *testing.T) {
+	mock := &mockExecutor{}
+	res, _ := devcontainerHookHandler(devcontainerReg(mock), "postCreateCommand")(context.Background(), makeReq(map[string]any{"mode": "async"}))
+	if res.IsError || mock.startSessionCalls != 1 || mock.lastCommand != "sh -c 'script/bootstrap'" {
+		t.Fatalf("result = %s, sessions = %d, command = %q", resultText(res), mock.startSessionCalls, mock.lastCommand)
+	}
+	if !strings.HasPrefix(mock.lastSessionID, "postCreateCommand-") {
+		t.Errorf("session id = %q", mock.lastSessionID)
+	}
+}
+
+func TestDevcontainerHookHandler_Undeclared(t *testing.T) {
+	mock := &mockExecutor{}
+	res, _ := devcontainerHookHandler(devcontainerReg(mock), "postStartCommand")(context.Background(), makeReq(nil))
+	text := resultText(res)
+	if !res.IsError || !strings.Contains(text, "not_found") || !strings.Contains(text, "declares postCreateCommand") {
+		t.Errorf("result = %s", text)
+	}
+	if mock.runBashCalls != 0 {
+		t.Errorf("ran %d commands for an undeclared hook", mock.runBashCalls)
+	}
+}
@@ -0,0 +1,387 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// maxDockerOutputBytes caps what the docker tools return, keeping the end
+	// of the output, where the latest logs and errors are.
+	maxDockerOutputBytes = 16 * 1024
+	maxDockerContainers  = 50
+	defaultComposeLogs   = 100
+	maxComposeLogs       = 1000
+	// composeUpOutputLines is how much of compose up's progress output is kept.
+	composeUpOutputLines = 40
+	// dockerMissingExit is the exit code the scripts use when docker or
+	// compose is not installed.
+	dockerMissingExit = 127
+)
+
+var (
+	composeServicePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	composeSincePattern   = regexp.MustCompile(`^[0-9A-Za-z:.+-]+$`)
+)
+
+// dockerCheck fails the script with dockerMissingExit when docker is missing.
+const dockerCheck = `command -v docker >/dev/null 2>&1 || { echo 'docker is not installed on the codespace' >&2; exit 127; }`
+
+// composeCheck defines dc as docker compose, or the standalone docker-compose
+// on older images, failing with dockerMissingExit when neither exists.
+const composeCheck = `if docker compose version >/dev/null 2>&1; then dc() { docker compose "$@"; }; ` +
+	`elif command -v docker-compose >/dev/null 2>&1; then dc() { docker-compose "$@"; }; ` +
+	`else echo 'docker compose is not installed on the codespace' >&2; exit 127; fi`
+
+// dockerContainer is one line of docker ps --format '{{json .}}'.
+type dockerContainer struct {
+	Names  string `json:"Names"`
+	Image  string `json:"Image"`
+	Status string `json:"Status"`
+	Ports  string `json:"Ports"`
+}
+
+// parseDockerPS reads docker ps JSON lines, skipping lines that are not JSON.
+func parseDockerPS(out string) []dockerContainer {
+	var containers []dockerContainer
+	for _, line := range strings.Split(out, "\n") {
+		var c dockerContainer
+		if json.Unmarshal([]byte(strings.TrimSpace(line)), &c) == nil && c.Names != "" {
+			containers = append(containers, c)
+		}
+	}
+	return containers
+}
+
+// formatDockerPS renders one line per container, at most maxDockerContainers.
+func formatDockerPS(containers []dockerContainer, all bool) string {
+	if len(containers) == 0 {
+		if all {
+			return "No containers."
+		}
+		return "No running containers. Pass all: true to include stopped ones."
+	}
+	var sb strings.Builder
+	for i, c := range containers {
+		if i == maxDockerContainers {
+			fmt.Fprintf(&sb, "[... %d more containers]\n", len(containers)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "%s  image=%s  status=%q", c.Names, c.Image, c.Status)
+		if c.Ports != "" {
+			fmt.Fprintf(&sb, "  ports=%s", c.Ports)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// tailBytes keeps the last n bytes of s, starting at a line boundary, and
+// notes how much was dropped.
+func tailBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := len(s) - n
+	if i := strings.IndexByte(s[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return fmt.Sprintf("[... %d earlier bytes omitted]\n%s", cut, s[cut:])
+}
+
+// composeArgs returns the compose arguments shared by every compose tool
+// (-f and -p), followed by the subcommand and its arguments, all quoted.
+func composeArgs(req mcpsdk.CallToolRequest, sub ...string) string {
+	var args []string
+	if file := optionalString(req, "file"); file != "" {
+		args = append(args, "-f", file)
+	}
+	if project := optionalString(req, "project"); project != "" {
+		args = append(args, "-p", project)
+	}
+	args = append(args, sub...)
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// composeServices reads the optional services list.
+func composeServices(req mcpsdk.CallToolRequest) ([]string, error) {
+	raw, ok := req.GetArguments()["services"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("services must be an array of service names")
+	}
+	services := make([]string, 0, len(list))
+	for _, v := range list {
+		name, ok := v.(string)
+		if !ok || !composeServicePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid service name %v", v)
+		}
+		services = append(services, name)
+	}
+	return services, nil
+}
+
+// runDockerScript runs script in cwd (default: the workdir) and turns a
+// missing docker into an unavailable error and other failures into
+// command_failed ones carrying the end of the output.
+func runDockerScript(ctx context.Context, reg *registry.Registry, req mcpsdk.CallToolRequest, what, script string) (string, *mcpsdk.CallToolResult) {
+	c, err := resolveExecutor(reg, req)
+	if err != nil {
+		return "", toolError(err.Error())
+	}
+	cwd := optionalString(req, "cwd")
+	if cwd == "" {
+		cwd = c.GetWorkdir()
+	}
+	stdout, stderr, exitCode, err := c.RunBash(ctx, script, cwd)
+	if err != nil {
+		return "", toolError(fmt.Sprintf("%s: %v", what, err))
+	}
+	if exitCode == dockerMissingExit && strings.Contains(stderr, "not installed on the codespace") {
+		return "", categorizedError(errUnavailable, strings.TrimSpace(stderr)+". Docker tools need docker-in-docker or a docker socket in the devcontainer.")
+	}
+	if exitCode != 0 {
+		detail := strings.TrimSpace(tailBytes(strings.TrimSpace(stdout+"\n"+stderr), maxDockerOutputBytes))
+		return "", categorizedError(errCommandFailed, fmt.Sprintf("%s failed with exit code %d:\n%s", what, exitCode, detail))
+	}
+	return stdout, nil
+}
+
+var composeFileParams = map[string]any{
+	"file": map[string]any{
+		"type":        "string",
+		"description": "Compose file, relative to cwd (default: compose's own lookup of compose.yaml / docker-compose.yml)",
+	},
+	"project": map[string]any{
+		"type":        "string",
+		"description": "Compose project name (default: derived from the directory)",
+	},
+	"cwd": map[string]any{
+		"type":        "string",
+		"description": "Directory to run compose in (default: current working directory)",
+	},
+}
+
+// composeSchema merges the shared compose parameters with extra.
+func composeSchema(extra map[string]any) map[string]any {
+	props := map[string]any{"codespace": codespaceParam}
+	for k, v := range composeFileParams {
+		props[k] = v
+	}
+	for k, v := range extra {
+		props[k] = v
+	}
+	return props
+}
+
+var composeServicesParam = map[string]any{
+	"type":        "array",
+	"items":       map[string]any{"type": "string"},
+	"description": "Only these services (default: all services in the project)",
+}
+
+// --- remote_docker_ps ---
+
+func dockerPSTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_docker_ps",
+		Annotations: readOnlyHints("Docker containers", false),
+		Description: "List docker containers on the codespace (docker-in-docker), one line each with name, image, status, and ports. " +
+			"Use it instead of docker ps through remote_bash.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"all": map[string]any{
+					"type":        "boolean",
+					"description": "Include stopped containers (default: false)",
+				},
+			},
+		},
+	}
+}
+
+func dockerPSHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		all := optionalBool(req, "all", false)
+		script := dockerCheck + "; docker ps --format '{{json .}}'"
+		if all {
+			script += " --all"
+		}
+		stdout, failed := runDockerScript(ctx, reg, req, "docker ps", script)
+		if failed != nil {
+			return failed, nil
+		}
+		return toolSuccess(formatDockerPS(parseDockerPS(stdout), all)), nil
+	}
+}
+
+// --- remote_compose_up ---
+
+func composeUpTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_compose_up",
+		Annotations: mutatingHints("Start compose services", false, true, false),
+		Description: "Start docker compose services on the codespace in the background (up -d) and return the end of compose's output and the resulting service status. " +
+			"Follow up with remote_compose_logs rather than reading docker output through remote_bash.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: composeSchema(map[string]any{
+				"services": composeServicesParam,
+				"build": map[string]any{
+					"type":        "boolean",
+					"description": "Build images before starting (default: false)",
+				},
+			}),
+		},
+	}
+}
+
+func composeUpHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		services, err := composeServices(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		sub := []string{"up", "-d"}
+		if optionalBool(req, "build", false) {
+			sub = append(sub, "--build")
+		}
+		sub = append(sub, services...)
+		script := fmt.Sprintf("%s; %s; dc %s 2>&1 || exit $?; echo '== ps'; dc %s 2>&1",
+			dockerCheck, composeCheck, composeArgs(req, sub...), composeArgs(req, "ps"))
+		stdout, failed := runDockerScript(ctx, reg, req, "docker compose up", script)
+		if failed != nil {
+			return failed, nil
+		}
+		up, ps, _ := strings.Cut(stdout, "== ps\n")
+		text := "Started.\n"
+		if up = strings.TrimSpace(up); up != "" {
+			text += tailLines(up, composeUpOutputLines) + "\n"
+		}
+		if ps = strings.TrimSpace(ps); ps != "" {
+			text += "\nServices:\n" + tailBytes(ps, maxDockerOutputBytes)
+		}
+		return toolSuccess(strings.TrimRight(text, "\n")), nil
+	}
+}
+
+// --- remote_compose_down ---
+
+func composeDownTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_compose_down",
+		Annotations: mutatingHints("Stop compose services", true, true, false),
+		Description: "Stop and remove the containers and networks of a docker compose project on the codespace (down). " +
+			"Named volumes, such as database data, are kept unless remove_volumes is true.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: composeSchema(map[string]any{
+				"remove_volumes": map[string]any{
+					"type":        "boolean",
+					"description": "Also remove the project's named volumes, deleting their data (default: false)",
+				},
+			}),
+		},
+	}
+}
+
+func composeDownHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		sub := []string{"down"}
+		if optionalBool(req, "remove_volumes", false) {
+			sub = append(sub, "--volumes")
+		}
+		script := fmt.Sprintf("%s; %s; dc %s 2>&1", dockerCheck, composeCheck, composeArgs(req, sub...))
+		stdout, failed := runDockerScript(ctx, reg, req, "docker compose down", script)
+		if failed != nil {
+			return failed, nil
+		}
+		text := "Stopped."
+		if out := strings.TrimSpace(stdout); out != "" {
+			text += "\n" + tailLines(out, composeUpOutputLines)
+		}
+		return toolSuccess(strings.TrimRight(text, "\n")), nil
+	}
+}
+
+// --- remote_compose_logs ---
+
+func composeLogsTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_compose_logs",
+		Annotations: readOnlyHints("Compose logs", false),
+		Description: fmt.Sprintf("Read the logs of docker compose services on the codespace without color codes. "+
+			"Returns the last 'lines' lines per service and at most %d KB overall, keeping the newest output; narrow with services and since instead of raising the limits.",
+			maxDockerOutputBytes/1024),
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: composeSchema(map[string]any{
+				"services": composeServicesParam,
+				"lines": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Lines per service (default: %d, max: %d)", defaultComposeLogs, maxComposeLogs),
+				},
+				"since": map[string]any{
+					"type":        "string",
+					"description": "Only logs since a timestamp (2026-03-01T10:00:00) or a relative time (10m, 1h)",
+				},
+				"timestamps": map[string]any{
+					"type":        "boolean",
+					"description": "Prefix lines with timestamps (default: false)",
+				},
+			}),
+		},
+	}
+}
+
+func composeLogsHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		services, err := composeServices(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		lines := defaultComposeLogs
+		if v, ok := req.GetArguments()["lines"]; ok {
+			n, ok := toInt(v)
+			if !ok || n < 1 || n > maxComposeLogs {
+				return categorizedError(errInvalidArgument, fmt.Sprintf("lines must be between 1 and %d", maxComposeLogs)), nil
+			}
+			lines = n
+		}
+		sub := []string{"logs", "--no-color", "--tail", fmt.Sprint(lines)}
+		if since := optionalString(req, "since"); since != "" {
+			if !composeSincePattern.MatchString(since) {
+				return categorizedError(errInvalidArgument, fmt.Sprintf("invalid since %q: use a timestamp or a duration like 10m", since)), nil
+			}
+			sub = append(sub, "--since", since)
+		}
+		if optionalBool(req, "timestamps", false) {
+			sub = append(sub, "--timestamps")
+		}
+		sub = append(sub, services...)
+		script := fmt.Sprintf("%s; %s; dc %s 2>&1", dockerCheck, composeCheck, composeArgs(req, sub...))
+		stdout, failed := runDockerScript(ctx, reg, req, "docker compose logs", script)
+		if failed != nil {
+			return failed, nil
+		}
+		if strings.TrimSpace(stdout) == "" {
+			return toolSuccess("No logs."), nil
+		}
+		return toolSuccess(tailBytes(stdout, maxDockerOutputBytes)), nil
+	}
+}
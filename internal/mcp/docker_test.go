@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAndFormatDockerPS(t *testing.T) {
+	out := `{"ID":"abc","Names":"app-db-1","Image":"postgres:16","Status":"Up 2 hours (healthy)","Ports":"0.0.0.0:5432->5432/tcp"}
+WARNING: something unrelated
+{"ID":"def","Names":"app-worker-1","Image":"app-worker","Status":"Exited (1) 5 minutes ago","Ports":""}
+`
+	got := formatDockerPS(parseDockerPS(out), true)
+	want := `app-db-1  image=postgres:16  status="Up 2 hours (healthy)"  ports=0.0.0.0:5432->5432/tcp
+app-worker-1  image=app-worker  status="Exited (1) 5 minutes ago"`
+	if got != want {
+		t.Fatalf("formatDockerPS() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := formatDockerPS(nil, false); !strings.Contains(got, "all: true") {
+		t.Errorf("empty running list = %q", got)
+	}
+	many := make([]dockerContainer, maxDockerContainers+2)
+	for i := range many {
+		many[i] = dockerContainer{Names: fmt.Sprintf("c%d", i)}
+	}
+	if got := formatDockerPS(many, true); !strings.HasSuffix(got, "[... 2 more containers]") {
+		t.Errorf("long list not capped: %q", got[len(got)-40:])
+	}
+}
+
+func TestTailBytes(t *testing.T) {
+	if got := tailBytes("short", 10); got != "short" {
+		t.Errorf("tailBytes(short) = %q", got)
+	}
+	got := tailBytes("line one\nline two\nline three\n", 15)
+	if got != "[... 18 earlier bytes omitted]\nline three\n" {
+		t.Errorf("tailBytes() = %q", got)
+	}
+}
+
+func TestDockerPSHandler(t *testing.T) {
+	mock := &mockExecutor{workdir: "/workspaces/repo", runBashStdout: `{"Names":"web","Image":"nginx","Status":"Up 1 minute"}` + "\n"}
+	res, _ := dockerPSHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"all": true}))
+	if res.IsError || resultText(res) != `web  image=nginx  status="Up 1 minute"` {
+		t.Fatalf("result = %s", resultText(res))
+	}
+	if !strings.Contains(mock.lastRunBashCommand, "docker ps --format '{{json .}}' --all") {
+		t.Errorf("command = %s", mock.lastRunBashCommand)
+	}
+}
+
+func TestDockerHandlers_DockerMissing(t *testing.T) {
+	mock := &mockExecutor{runBashStderr: "docker is not installed on the codespace\n", runBashExit: dockerMissingExit}
+	res, _ := composeLogsHandler(testReg(mock))(context.Background(), makeReq(nil))
+	if !res.IsError || !strings.Contains(resultText(res), "[error:unavailable]") {
+		t.Fatalf("result = %s, want unavailable", resultText(res))
+	}
+}
+
+func TestComposeUpHandler(t *testing.T) {
+	mock := &mockExecutor{
+		workdir:       "/workspaces/repo",
+		runBashStdout: " Container app-db-1  Started\n== ps\nNAME       STATUS\napp-db-1   Up 1 second\n",
+	}
+	res, _ := composeUpHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"file": "dev/compose.yaml", "services": []any{"db"}, "build": true, "cwd": "/workspaces/repo/app",
+	}))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	want := "Started.\nContainer app-db-1  Started\n\nServices:\nNAME       STATUS\napp-db-1   Up 1 second"
+	if resultText(res) != want {
+		t.Errorf("result =\n%s\nwant\n%s", resultText(res), want)
+	}
+	if !strings.Contains(mock.lastRunBashCommand, "dc '-f' 'dev/compose.yaml' 'up' '-d' '--build' 'db' 2>&1") || mock.lastRunBashCwd != "/workspaces/repo/app" {
+		t.Errorf("command = %s in %s", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+}
+
+func TestComposeDownHandler_FailureKeepsOutput(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "no configuration file provided: not found\n", runBashExit: 1}
+	res, _ := composeDownHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"remove_volumes": true}))
+	if !res.IsError || !strings.Contains(resultText(res), "[error:command_failed]") || !strings.Contains(resultText(res), "no configuration file provided") {
+		t.Fatalf("result = %s", resultText(res))
+	}
+	if !strings.Contains(mock.lastRunBashCommand, "dc 'down' '--volumes'") {
+		t.Errorf("command = %s", mock.lastRunBashCommand)
+	}
+}
+
+func TestComposeLogsHandler(t *testing.T) {
+	long := strings.Repeat("web-1  | request handled\n", 2000)
+	mock := &mockExecutor{runBashStdout: long}
+	res, _ := composeLogsHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"services": []any{"web"}, "lines": float64(500), "since": "10m",
+	}))
+	text := resultText(res)
+	if res.IsError || len(text) > maxDockerOutputBytes+100 || !strings.HasPrefix(text, "[... ") || !strings.HasSuffix(text, "request handled\n") {
+		t.Fatalf("result (%d bytes) = %.200s", len(text), text)
+	}
+	if !strings.Contains(mock.lastRunBashCommand, "dc 'logs' '--no-color' '--tail' '500' '--since' '10m' 'web' 2>&1") {
+		t.Errorf("command = %s", mock.lastRunBashCommand)
+	}
+}
+
+func TestComposeHandlers_RejectBadArguments(t *testing.T) {
+	for _, args := range []map[string]any{
+		{"services": []any{"web; rm -rf /"}},
+		{"services": "web"},
+		{"lines": float64(0)},
+		{"lines": float64(maxComposeLogs + 1)},
+		{"since": "$(id)"},
+	} {
+		mock := &mockExecutor{}
+		res, _ := composeLogsHandler(testReg(mock))(context.Background(), makeReq(args))
+		if !res.IsError || !strings.Contains(resultText(res), "invalid_argument") || mock.runBashCalls != 0 {
+			t.Errorf("%v: result = %s, want invalid_argument", args, resultText(res))
+		}
+	}
+}
+
+func TestComposeCheckFallsBackToStandaloneCompose(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose"), []byte("#!"+bash+"\necho \"standalone $*\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bash, "-c", composeCheck+"; dc ps -a")
+	cmd.Env = []string{"PATH=" + dir}
+	out, err := cmd.CombinedOutput()
+	if err != nil || string(out) != "standalone ps -a\n" {
+		t.Fatalf("output = %q, %v", out, err)
+	}
+
+	cmd = exec.Command(bash, "-c", composeCheck+"; dc ps")
+	cmd.Env = []string{"PATH=" + t.TempDir()}
+	out, _ = cmd.CombinedOutput()
+	if cmd.ProcessState.ExitCode() != dockerMissingExit || !strings.Contains(string(out), "not installed on the codespace") {
+		t.Fatalf("without compose: exit %d, output %q", cmd.ProcessState.ExitCode(), out)
+	}
+}
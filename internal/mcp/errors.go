@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// errorCategory classifies tool failures so hooks and the model can branch on
+// the kind of failure. Values are part of the tool output contract: each error
+// result starts with "[error:<category>] " and carries the category in
+// _meta.errorCategory. Do not rename existing categories.
+type errorCategory string
+
+const (
+	errConnection      errorCategory = "connection"       // SSH/transport failures reaching the codespace
+	errNotFound        errorCategory = "not_found"        // missing file, session, codespace, or match
+	errPermission      errorCategory = "permission"       // OS-level permission failures on the codespace
+	errPolicyDenied    errorCategory = "policy_denied"    // blocked by session policy (selected-only, workspace confinement)
+	errTimeout         errorCategory = "timeout"          // deadline exceeded or cancelled
+	errConflict        errorCategory = "conflict"         // already exists / in use / ambiguous match
+	errInvalidArgument errorCategory = "invalid_argument" // bad or missing tool parameters
+	errCommandFailed   errorCategory = "command_failed"   // remote command ran and exited non-zero
+	errUnavailable     errorCategory = "unavailable"      // codespace is suspended or starting; retry later
+	errInternal        errorCategory = "internal"         // anything else
+)
+
+// errorCategoryMetaKey is the _meta field holding the error category.
+const errorCategoryMetaKey = "errorCategory"
+
+var (
+	sshExit255Re  = regexp.MustCompile(`exit(?: code)?:? 255\b`)
+	nonZeroExitRe = regexp.MustCompile(`exit(?: code)?:? -?\d+`)
+	foundTimesRe  = regexp.MustCompile(`found \d+ times`)
+	errorPrefixRe = regexp.MustCompile(`^\[error:[a-z_]+\] `)
+)
+
+// classifyError infers a category from an error message. Checks run from most
+// to least specific; call sites that know the category should use
+// categorizedError instead of relying on this.
+func classifyError(text string) errorCategory {
+	lower := strings.ToLower(text)
+	hasAny := func(subs ...string) bool {
+		for _, s := range subs {
+			if strings.Contains(lower, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case hasAny("timed out", "timeout", "deadline exceeded", "command cancelled", "context canceled"):
+		return errTimeout
+	case hasAny("outside the workspace", "selected-only", "not allowed in this session", "denied by policy"):
+		return errPolicyDenied
+	case hasAny("permission denied", "operation not permitted", "read-only file system"):
+		return errPermission
+	case hasAny("missing required parameter", "must be a", "invalid ", "specify which one"):
+		return errInvalidArgument
+	case hasAny("already in use", "already registered", "already connected", "already exists", "file exists") || foundTimesRe.MatchString(lower):
+		return errConflict
+	case hasAny("not found", "does not exist", "no such file", "no codespaces connected"):
+		return errNotFound
+	case sshExit255Re.MatchString(lower) || hasAny("ssh setup failed", "ssh multiplexing", "connection", "broken pipe", "failed to execute command", "getting ssh config"):
+		return errConnection
+	case nonZeroExitRe.MatchString(lower) || hasAny("failed with exit code"):
+		return errCommandFailed
+	}
+	return errInternal
+}
+
+// categorizedError builds an error result with a stable category prefix.
+func categorizedError(category errorCategory, text string) *mcpsdk.CallToolResult {
+	if !errorPrefixRe.MatchString(text) {
+		text = fmt.Sprintf("[error:%s] %s", category, text)
+	}
+	return &mcpsdk.CallToolResult{
+		Result: mcpsdk.Result{
+			Meta: &mcpsdk.Meta{
+				AdditionalFields: map[string]any{errorCategoryMetaKey: string(category)},
+			},
+		},
+		IsError: true,
+		Content: []mcpsdk.Content{
+			mcpsdk.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"testing"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		text string
+		want errorCategory
+	}{
+		{"command timed out after 30s", errTimeout},
+		{"context deadline exceeded", errTimeout},
+		{"path /etc/passwd is outside the workspace /workspaces/repo", errPolicyDenied},
+		{"cat: /root/x: Permission denied", errPermission},
+		{"missing required parameter: path", errInvalidArgument},
+		{`invalid mode "abc": use octal (755) or symbolic (u+x) notation`, errInvalidArgument},
+		{`multiple codespaces connected (a, b); specify which one with the "codespace" parameter`, errInvalidArgument},
+		{`alias "api" already in use`, errConflict},
+		{"old_str found 3 times in file, must be unique", errConflict},
+		{"session not found: abc", errNotFound},
+		{"directory does not exist: /nope", errNotFound},
+		{"failed to execute command: exit status 255", errConnection},
+		{"SSH setup failed: boom", errConnection},
+		{"ln failed with exit code 1: File exists", errConflict},
+		{"chmod failed with exit code 2: oops", errCommandFailed},
+		{"something odd happened", errInternal},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.text); got != tt.want {
+			t.Errorf("classifyError(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCategorizedError(t *testing.T) {
+	result := categorizedError(errNotFound, "file not found: a.go")
+	if !result.IsError {
+		t.Error("expected IsError to be true")
+	}
+	if got := resultText(result); got != "[error:not_found] file not found: a.go" {
+		t.Errorf("text = %q", got)
+	}
+	if result.Meta == nil || result.Meta.AdditionalFields[errorCategoryMetaKey] != "not_found" {
+		t.Errorf("meta = %+v, want errorCategory=not_found", result.Meta)
+	}
+}
+
+func TestCategorizedError_DoesNotDoublePrefix(t *testing.T) {
+	result := categorizedError(errConflict, "[error:conflict] already exists")
+	tc := result.Content[0].(mcpsdk.TextContent)
+	if tc.Text != "[error:conflict] already exists" {
+		t.Errorf("text = %q", tc.Text)
+	}
+}
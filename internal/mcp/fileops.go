@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultWorkspaceRoot bounds file operations when a codespace has no known workdir.
+const defaultWorkspaceRoot = "/workspaces"
+
+// workspaceRoot returns the directory that confined file operations must stay within.
+func workspaceRoot(cs *registry.ManagedCodespace) string {
+	if cs.Workdir != "" {
+		return path.Clean(cs.Workdir)
+	}
+	return defaultWorkspaceRoot
+}
+
+// confinePath resolves p against cwd and verifies the result stays inside root.
+// The check is lexical: ".." segments are collapsed before comparing, so
+// "src/../../etc" is rejected even though it starts inside the workspace.
+func confinePath(root, cwd, p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path must not be empty")
+	}
+	resolved := p
+	if !path.IsAbs(resolved) {
+		resolved = path.Join(cwd, resolved)
+	}
+	resolved = path.Clean(resolved)
+	if !pathWithin(root, resolved) {
+		return "", fmt.Errorf("path %s is outside the workspace %s", p, root)
+	}
+	return resolved, nil
+}
+
+func pathWithin(root, p string) bool {
+	root = path.Clean(root)
+	if root == "/" {
+		return true
+	}
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+// --- remote_ln ---
+
+func lnTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_ln",
+		Annotations: mutatingHints("Create remote link", true, true, false),
+		Description: "Create a symbolic link on the remote codespace. Both the link and its target must resolve inside the workspace. Relative targets are stored as-is and resolved relative to the link's directory.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"target": map[string]any{
+					"type":        "string",
+					"description": "Path the link points to",
+				},
+				"link_path": map[string]any{
+					"type":        "string",
+					"description": "Path of the symlink to create",
+				},
+				"force": map[string]any{
+					"type":        "boolean",
+					"description": "Replace an existing file or link at link_path (default: false)",
+				},
+			},
+			Required: []string{"target", "link_path"},
+		},
+	}
+}
+
+func lnHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		target, err := requiredString(req, "target")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		linkPath, err := requiredString(req, "link_path")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		c := cs.Executor
+		root := workspaceRoot(cs)
+		cwd := c.GetWorkdir()
+		link, err := confinePath(root, cwd, linkPath)
+		if err != nil {
+			return categorizedError(errPolicyDenied, err.Error()), nil
+		}
+		// Relative symlink targets resolve against the link's directory, not cwd.
+		if _, err := confinePath(root, path.Dir(link), target); err != nil {
+			return categorizedError(errPolicyDenied, fmt.Sprintf("invalid target: %v", err)), nil
+		}
+
+		flags := "-s"
+		if optionalBool(req, "force", false) {
+			flags = "-sfn"
+		}
+		cmd := fmt.Sprintf("ln %s -- %s %s", flags, shellQuote(target), shellQuote(link))
+		_, stderr, exitCode, execErr := c.RunBash(ctx, cmd, cwd)
+		if execErr != nil {
+			return toolError(fmt.Sprintf("failed to create link: %v", execErr)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("ln failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		return toolSuccess(fmt.Sprintf("Created symlink %s -> %s", link, target)), nil
+	}
+}
+
+// --- remote_chmod ---
+
+// chmodModePattern accepts octal modes (755, 0644) and symbolic modes (u+x, go-w, a=rX).
+var chmodModePattern = regexp.MustCompile(`^([0-7]{3,4}|[ugoa]*[-+=][rwxXst]*(,[ugoa]*[-+=][rwxXst]*)*)$`)
+
+func chmodTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_chmod",
+		Annotations: mutatingHints("Change remote file mode", true, true, false),
+		Description: "Change file permissions on the remote codespace (e.g. make a script executable). The path must resolve inside the workspace.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path of the file or directory",
+				},
+				"mode": map[string]any{
+					"type":        "string",
+					"description": "Octal (e.g. \"755\") or symbolic (e.g. \"u+x\", \"go-w\") mode",
+				},
+				"recursive": map[string]any{
+					"type":        "boolean",
+					"description": "Apply recursively to a directory (default: false)",
+				},
+			},
+			Required: []string{"path", "mode"},
+		},
+	}
+}
+
+func chmodHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		p, err := requiredString(req, "path")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		mode, err := requiredString(req, "mode")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if !chmodModePattern.MatchString(mode) {
+			return categorizedError(errInvalidArgument, fmt.Sprintf("invalid mode %q: use octal (755) or symbolic (u+x) notation", mode)), nil
+		}
+
+		c := cs.Executor
+		cwd := c.GetWorkdir()
+		resolved, err := confinePath(workspaceRoot(cs), cwd, p)
+		if err != nil {
+			return categorizedError(errPolicyDenied, err.Error()), nil
+		}
+
+		cmd := "chmod "
+		if optionalBool(req, "recursive", false) {
+			cmd += "-R "
+		}
+		cmd += fmt.Sprintf("%s -- %s", mode, shellQuote(resolved))
+		_, stderr, exitCode, execErr := c.RunBash(ctx, cmd, cwd)
+		if execErr != nil {
+			return toolError(fmt.Sprintf("failed to change mode: %v", execErr)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("chmod failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		return toolSuccess(fmt.Sprintf("Changed mode of %s to %s", resolved, mode)), nil
+	}
+}
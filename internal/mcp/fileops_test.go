@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestConfinePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    string
+		cwd     string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "relative inside", root: "/workspaces/repo", cwd: "/workspaces/repo", path: "bin/run.sh", want: "/workspaces/repo/bin/run.sh"},
+		{name: "absolute inside", root: "/workspaces/repo", cwd: "/tmp", path: "/workspaces/repo/a", want: "/workspaces/repo/a"},
+		{name: "root itself", root: "/workspaces/repo", cwd: "/workspaces/repo", path: ".", want: "/workspaces/repo"},
+		{name: "dotdot escape", root: "/workspaces/repo", cwd: "/workspaces/repo/src", path: "../../etc/passwd", wantErr: "outside the workspace"},
+		{name: "sibling prefix", root: "/workspaces/repo", cwd: "/workspaces/repo", path: "/workspaces/repo2/x", wantErr: "outside the workspace"},
+		{name: "empty", root: "/workspaces/repo", cwd: "/workspaces/repo", path: "", wantErr: "must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := confinePath(tt.root, tt.cwd, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLnHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		mock     *mockExecutor
+		wantErr  bool
+		wantText string
+		wantCmd  string
+	}{
+		{
+			name:     "relative link",
+			args:     map[string]any{"target": "../config/app.yml", "link_path": "web/app.yml"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantText: "Created symlink /workspaces/repo/web/app.yml -> ../config/app.yml",
+			wantCmd:  "ln -s -- '../config/app.yml' '/workspaces/repo/web/app.yml'",
+		},
+		{
+			name:    "force replaces",
+			args:    map[string]any{"target": "/workspaces/repo/a", "link_path": "b", "force": true},
+			mock:    &mockExecutor{workdir: "/workspaces/repo"},
+			wantCmd: "ln -sfn -- '/workspaces/repo/a' '/workspaces/repo/b'",
+		},
+		{
+			name:     "link outside workspace",
+			args:     map[string]any{"target": "a", "link_path": "/etc/a"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantErr:  true,
+			wantText: "outside the workspace",
+		},
+		{
+			name:     "target escapes via link dir",
+			args:     map[string]any{"target": "../../etc/shadow", "link_path": "x"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantErr:  true,
+			wantText: "invalid target",
+		},
+		{
+			name:     "ln fails",
+			args:     map[string]any{"target": "a", "link_path": "b"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo", runBashExit: 1, runBashStderr: "ln: b: File exists\n"},
+			wantErr:  true,
+			wantText: "File exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := lnHandler(testRegWithWorkdir(tt.mock, "/workspaces/repo"))
+			res, err := handler(context.Background(), makeReq(tt.args))
+			if err != nil {
+				t.Fatalf("unexpected Go error: %v", err)
+			}
+			if tt.wantErr != res.IsError {
+				t.Fatalf("IsError = %v, want %v: %s", res.IsError, tt.wantErr, resultText(res))
+			}
+			if !strings.Contains(resultText(res), tt.wantText) {
+				t.Errorf("result text %q does not contain %q", resultText(res), tt.wantText)
+			}
+			if tt.wantCmd != "" && tt.mock.lastRunBashCommand != tt.wantCmd {
+				t.Errorf("command = %q, want %q", tt.mock.lastRunBashCommand, tt.wantCmd)
+			}
+			if tt.wantErr && tt.mock.runBashExit == 0 && tt.mock.runBashCalls != 0 {
+				t.Errorf("expected no remote call for rejected path, got %d", tt.mock.runBashCalls)
+			}
+		})
+	}
+}
+
+func TestChmodHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		mock     *mockExecutor
+		wantErr  bool
+		wantText string
+		wantCmd  string
+	}{
+		{
+			name:     "octal",
+			args:     map[string]any{"path": "bin/run.sh", "mode": "755"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantText: "Changed mode of /workspaces/repo/bin/run.sh to 755",
+			wantCmd:  "chmod 755 -- '/workspaces/repo/bin/run.sh'",
+		},
+		{
+			name:    "symbolic recursive",
+			args:    map[string]any{"path": "scripts", "mode": "u+x,go-w", "recursive": true},
+			mock:    &mockExecutor{workdir: "/workspaces/repo"},
+			wantCmd: "chmod -R u+x,go-w -- '/workspaces/repo/scripts'",
+		},
+		{
+			name:     "invalid mode",
+			args:     map[string]any{"path": "a", "mode": "755; rm -rf /"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantErr:  true,
+			wantText: "invalid mode",
+		},
+		{
+			name:     "outside workspace",
+			args:     map[string]any{"path": "/usr/bin/env", "mode": "777"},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantErr:  true,
+			wantText: "outside the workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := chmodHandler(testRegWithWorkdir(tt.mock, "/workspaces/repo"))
+			res, err := handler(context.Background(), makeReq(tt.args))
+			if err != nil {
+				t.Fatalf("unexpected Go error: %v", err)
+			}
+			if tt.wantErr != res.IsError {
+				t.Fatalf("IsError = %v, want %v: %s", res.IsError, tt.wantErr, resultText(res))
+			}
+			if !strings.Contains(resultText(res), tt.wantText) {
+				t.Errorf("result text %q does not contain %q", resultText(res), tt.wantText)
+			}
+			if tt.wantCmd != "" && tt.mock.lastRunBashCommand != tt.wantCmd {
+				t.Errorf("command = %q, want %q", tt.mock.lastRunBashCommand, tt.wantCmd)
+			}
+			if tt.wantErr && tt.mock.runBashCalls != 0 {
+				t.Errorf("expected no remote call, got %d", tt.mock.runBashCalls)
+			}
+		})
+	}
+}
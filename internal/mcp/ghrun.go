@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ghRunListFields are the run fields returned by the list action.
+const ghRunListFields = "databaseId,status,conclusion,workflowName,displayTitle,headBranch,event,createdAt,url"
+
+// ghRunViewFields are the run fields returned by the view action, including per-job status.
+const ghRunViewFields = "databaseId,status,conclusion,workflowName,displayTitle,headBranch,headSha,event,createdAt,updatedAt,url,jobs"
+
+// defaultGHRunLogLines caps failed-job log output so it fits in the model's context.
+const defaultGHRunLogLines = 200
+
+var (
+	ghRunIDPattern    = regexp.MustCompile(`^[0-9]+$`)
+	ghRepoPattern     = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+	ghInputKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// --- remote_gh_run ---
+
+func ghRunTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_gh_run",
+		Annotations: mutatingHints("GitHub Actions runs", false, false, true),
+		Description: "Work with GitHub Actions runs using gh on the remote codespace, where repository auth already exists. " +
+			"Actions: 'dispatch' triggers a workflow_dispatch run, 'list' shows recent runs, 'view' returns a run's status and jobs as JSON (poll it to watch progress), " +
+			"'logs' returns the logs of failed jobs. Runs against the repository checked out in cwd unless 'repo' is given.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"action": map[string]any{
+					"type":        "string",
+					"enum":        []string{"dispatch", "list", "view", "logs"},
+					"description": "Operation to perform",
+				},
+				"workflow": map[string]any{
+					"type":        "string",
+					"description": "Workflow file name, ID, or name. Required for 'dispatch'; filters 'list'.",
+				},
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Branch or tag to run the workflow on ('dispatch' only, default: the repository's default branch)",
+				},
+				"inputs": map[string]any{
+					"type":                 "object",
+					"description":          "workflow_dispatch inputs as key/value pairs ('dispatch' only)",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+				"run_id": map[string]any{
+					"type":        "string",
+					"description": "Run ID. Required for 'view' and 'logs'.",
+				},
+				"branch": map[string]any{
+					"type":        "string",
+					"description": "Only list runs for this branch ('list' only)",
+				},
+				"limit": map[string]any{
+					"type":        "number",
+					"description": "Maximum number of runs to list (default: 10)",
+				},
+				"max_lines": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Keep only the last N log lines ('logs' only, default: %d)", defaultGHRunLogLines),
+				},
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository as OWNER/REPO (default: inferred from the git checkout in cwd)",
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Directory to run gh in (default: current working directory)",
+				},
+			},
+			Required: []string{"action"},
+		},
+	}
+}
+
+// ghRunHandler builds and runs the gh command for an action. Read-only sessions
+// may inspect runs but not dispatch new ones.
+func ghRunHandler(reg *registry.Registry, readOnly bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		c, err := resolveExecutor(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		action, err := requiredString(req, "action")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if action == "dispatch" && readOnly {
+			return categorizedError(errPolicyDenied, "dispatching workflows is not allowed in this session (read-only)"), nil
+		}
+
+		args, err := ghRunArgs(action, req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		if repo := optionalString(req, "repo"); repo != "" {
+			if !ghRepoPattern.MatchString(repo) {
+				return categorizedError(errInvalidArgument, fmt.Sprintf("invalid repo %q: use OWNER/REPO", repo)), nil
+			}
+			args = append(args, "-R", repo)
+		}
+
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = shellQuote(a)
+		}
+		cmd := "gh " + strings.Join(quoted, " ")
+		cwd := optionalString(req, "cwd")
+		if cwd == "" {
+			cwd = c.GetWorkdir()
+		}
+
+		stdout, stderr, exitCode, execErr := c.RunBash(ctx, cmd, cwd)
+		if execErr != nil {
+			return toolError(fmt.Sprintf("failed to execute command: %v", execErr)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("gh %s failed with exit code %d: %s", args[0]+" "+args[1], exitCode, strings.TrimSpace(stderr))), nil
+		}
+
+		switch action {
+		case "dispatch":
+			workflow := optionalString(req, "workflow")
+			return toolSuccess(fmt.Sprintf("Dispatched workflow %s. Use action 'list' with workflow %q to find the new run ID.", workflow, workflow)), nil
+		case "logs":
+			maxLines := int(optionalFloat(req, "max_lines", defaultGHRunLogLines))
+			out := tailLines(stdout, maxLines)
+			if strings.TrimSpace(out) == "" {
+				return toolSuccess("No failed job logs for this run."), nil
+			}
+			return toolSuccess(out), nil
+		}
+		if strings.TrimSpace(stdout) == "" {
+			return toolSuccess("No runs found."), nil
+		}
+		return toolSuccess(stdout), nil
+	}
+}
+
+// ghRunArgs returns the gh arguments for action, validating the parameters it needs.
+func ghRunArgs(action string, req mcpsdk.CallToolRequest) ([]string, error) {
+	switch action {
+	case "dispatch":
+		workflow, err := requiredString(req, "workflow")
+		if err != nil {
+			return nil, err
+		}
+		if err := checkGHArgValue("workflow", workflow); err != nil {
+			return nil, err
+		}
+		args := []string{"workflow", "run", workflow}
+		if ref := optionalString(req, "ref"); ref != "" {
+			if err := checkGHArgValue("ref", ref); err != nil {
+				return nil, err
+			}
+			args = append(args, "--ref", ref)
+		}
+		inputs, _ := req.GetArguments()["inputs"].(map[string]any)
+		keys := make([]string, 0, len(inputs))
+		for k := range inputs {
+			if !ghInputKeyPattern.MatchString(k) {
+				return nil, fmt.Errorf("invalid input name %q", k)
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			args = append(args, "-f", fmt.Sprintf("%s=%v", k, inputs[k]))
+		}
+		return args, nil
+	case "list":
+		limit := int(optionalFloat(req, "limit", 10))
+		if limit < 1 {
+			return nil, fmt.Errorf("limit must be at least 1")
+		}
+		args := []string{"run", "list", "--limit", fmt.Sprint(limit), "--json", ghRunListFields}
+		if workflow := optionalString(req, "workflow"); workflow != "" {
+			if err := checkGHArgValue("workflow", workflow); err != nil {
+				return nil, err
+			}
+			args = append(args, "--workflow", workflow)
+		}
+		if branch := optionalString(req, "branch"); branch != "" {
+			if err := checkGHArgValue("branch", branch); err != nil {
+				return nil, err
+			}
+			args = append(args, "--branch", branch)
+		}
+		return args, nil
+	case "view", "logs":
+		// Models often send run IDs as numbers; accept both.
+		runID := optionalString(req, "run_id")
+		if n, ok := toInt(req.GetArguments()["run_id"]); ok {
+			runID = fmt.Sprint(n)
+		}
+		if runID == "" {
+			return nil, fmt.Errorf("missing required parameter: run_id")
+		}
+		if !ghRunIDPattern.MatchString(runID) {
+			return nil, fmt.Errorf("invalid run_id %q: must be numeric", runID)
+		}
+		if action == "view" {
+			return []string{"run", "view", runID, "--json", ghRunViewFields}, nil
+		}
+		return []string{"run", "view", runID, "--log-failed"}, nil
+	}
+	return nil, fmt.Errorf("invalid action %q: must be one of dispatch, list, view, logs", action)
+}
+
+// checkGHArgValue rejects a parameter value gh would parse as a flag, such
+// as a workflow of "--repo=other/repo".
+func checkGHArgValue(param, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("invalid %s %q: must not start with '-'", param, value)
+	}
+	return nil
+}
+
+// tailLines keeps the last n lines of s, noting how many were dropped.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if n <= 0 || len(lines) <= n {
+		return s
+	}
+	dropped := len(lines) - n
+	return fmt.Sprintf("[... %d earlier lines omitted]\n%s\n", dropped, strings.Join(lines[dropped:], "\n"))
+}
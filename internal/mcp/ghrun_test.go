@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGHRunHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		readOnly bool
+		mock     *mockExecutor
+		wantErr  string
+		wantText string
+		wantCmd  string
+		wantCwd  string
+	}{
+		{
+			name:     "dispatch with ref and inputs",
+			args:     map[string]any{"action": "dispatch", "workflow": "deploy.yml", "ref": "main", "inputs": map[string]any{"env": "staging", "dry_run": "true"}},
+			mock:     &mockExecutor{workdir: "/workspaces/repo"},
+			wantText: "Dispatched workflow deploy.yml",
+			wantCmd:  "gh 'workflow' 'run' 'deploy.yml' '--ref' 'main' '-f' 'dry_run=true' '-f' 'env=staging'",
+			wantCwd:  "/workspaces/repo",
+		},
+		{
+			name:    "dispatch requires workflow",
+			args:    map[string]any{"action": "dispatch"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] missing required parameter: workflow",
+		},
+		{
+			name:     "dispatch denied in read-only session",
+			args:     map[string]any{"action": "dispatch", "workflow": "deploy.yml"},
+			readOnly: true,
+			mock:     &mockExecutor{},
+			wantErr:  "[error:policy_denied]",
+		},
+		{
+			name:     "list with filters and repo",
+			args:     map[string]any{"action": "list", "workflow": "ci.yml", "branch": "feature", "limit": float64(5), "repo": "octo/app", "cwd": "/tmp"},
+			mock:     &mockExecutor{runBashStdout: `[{"databaseId":1}]`},
+			wantText: `[{"databaseId":1}]`,
+			wantCmd:  "gh 'run' 'list' '--limit' '5' '--json' '" + ghRunListFields + "' '--workflow' 'ci.yml' '--branch' 'feature' '-R' 'octo/app'",
+			wantCwd:  "/tmp",
+		},
+		{
+			name:    "dispatch rejects a workflow that looks like a flag",
+			args:    map[string]any{"action": "dispatch", "workflow": "--repo=other/repo"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] invalid workflow",
+		},
+		{
+			name:    "dispatch rejects a ref that looks like a flag",
+			args:    map[string]any{"action": "dispatch", "workflow": "deploy.yml", "ref": "-Rother/repo"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] invalid ref",
+		},
+		{
+			name:    "list rejects a workflow that looks like a flag",
+			args:    map[string]any{"action": "list", "workflow": "-Rother/repo"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] invalid workflow",
+		},
+		{
+			name:    "list rejects a branch that looks like a flag",
+			args:    map[string]any{"action": "list", "branch": "--repo=other/repo"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] invalid branch",
+		},
+		{
+			name:     "view accepts numeric run id",
+			args:     map[string]any{"action": "view", "run_id": float64(12345)},
+			mock:     &mockExecutor{runBashStdout: `{"status":"completed"}`},
+			wantText: `{"status":"completed"}`,
+			wantCmd:  "gh 'run' 'view' '12345' '--json' '" + ghRunViewFields + "'",
+		},
+		{
+			name:    "view rejects non-numeric run id",
+			args:    map[string]any{"action": "view", "run_id": "1; rm -rf /"},
+			mock:    &mockExecutor{},
+			wantErr: "must be numeric",
+		},
+		{
+			name:     "logs fetches failed jobs",
+			args:     map[string]any{"action": "logs", "run_id": "42"},
+			mock:     &mockExecutor{runBashStdout: "build\tstep\terror: boom\n"},
+			wantText: "error: boom",
+			wantCmd:  "gh 'run' 'view' '42' '--log-failed'",
+		},
+		{
+			name:    "invalid repo",
+			args:    map[string]any{"action": "list", "repo": "not a repo"},
+			mock:    &mockExecutor{},
+			wantErr: "invalid repo",
+		},
+		{
+			name:    "unknown action",
+			args:    map[string]any{"action": "rerun"},
+			mock:    &mockExecutor{},
+			wantErr: "invalid action",
+		},
+		{
+			name:    "gh failure",
+			args:    map[string]any{"action": "view", "run_id": "7"},
+			mock:    &mockExecutor{runBashExit: 1, runBashStderr: "HTTP 404: Not Found"},
+			wantErr: "[error:not_found] gh run view failed with exit code 1: HTTP 404: Not Found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ghRunHandler(testReg(tt.mock), tt.readOnly)
+			result, err := handler(context.Background(), makeReq(tt.args))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			text := resultText(result)
+			if tt.wantErr != "" {
+				if !result.IsError || !strings.Contains(text, tt.wantErr) {
+					t.Fatalf("result = %q (IsError=%v), want error containing %q", text, result.IsError, tt.wantErr)
+				}
+				if tt.mock.runBashCalls != 0 && tt.mock.runBashExit == 0 {
+					t.Errorf("expected no remote command, got %q", tt.mock.lastRunBashCommand)
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("unexpected error result: %s", text)
+			}
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("text = %q, want containing %q", text, tt.wantText)
+			}
+			if tt.mock.lastRunBashCommand != tt.wantCmd {
+				t.Errorf("command = %q, want %q", tt.mock.lastRunBashCommand, tt.wantCmd)
+			}
+			if tt.wantCwd != "" && tt.mock.lastRunBashCwd != tt.wantCwd {
+				t.Errorf("cwd = %q, want %q", tt.mock.lastRunBashCwd, tt.wantCwd)
+			}
+		})
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	in := "a\nb\nc\nd\n"
+	if got := tailLines(in, 10); got != in {
+		t.Errorf("tailLines under limit = %q, want unchanged", got)
+	}
+	if got, want := tailLines(in, 2), "[... 2 earlier lines omitted]\nc\nd\n"; got != want {
+		t.Errorf("tailLines = %q, want %q", got, want)
+	}
+}
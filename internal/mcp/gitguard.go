@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxDirtyFilesListed bounds the uncommitted files named in a discard warning.
+const maxDirtyFilesListed = 10
+
+// gitCommandSep splits a command into simple commands: lists, pipelines,
+// subshells, and lines.
+var gitCommandSep = regexp.MustCompile(`&&|\|\||[;|()\n]`)
+
+// envAssignmentRe matches a leading VAR=value word.
+var envAssignmentRe = regexp.MustCompile(`^\w+=`)
+
+// discardingGitCommand returns the first simple command in command that
+// throws away working-tree changes (git reset --hard, checkout -f/./--,
+// restore, clean -f, switch --discard-changes), the directory it names with
+// git -C, and whether one was found.
+func discardingGitCommand(command string) (segment, dir string, ok bool) {
+	for _, seg := range gitCommandSep.Split(command, -1) {
+		words := strings.Fields(seg)
+		for len(words) > 0 && (envAssignmentRe.MatchString(words[0]) || words[0] == "time" || words[0] == "sudo" || words[0] == "command") {
+			words = words[1:]
+		}
+		if len(words) < 2 || words[0] != "git" {
+			continue
+		}
+		// Skip git's global options to find the subcommand.
+		dir = ""
+		i := 1
+		for i < len(words) && strings.HasPrefix(words[i], "-") {
+			if (words[i] == "-C" || words[i] == "-c") && i+1 < len(words) {
+				if words[i] == "-C" {
+					dir = words[i+1]
+				}
+				i++
+			}
+			i++
+		}
+		if i >= len(words) {
+			continue
+		}
+		sub, args := words[i], words[i+1:]
+		has := func(flags ...string) bool {
+			return slices.ContainsFunc(args, func(a string) bool { return slices.Contains(flags, a) })
+		}
+		discards := false
+		switch sub {
+		case "reset":
+			discards = has("--hard")
+		case "checkout":
+			discards = has("-f", "--force", ".", "--")
+		case "restore":
+			// --staged alone only unstages.
+			discards = !has("--staged", "-S") || has("--worktree", "-W")
+		case "clean":
+			discards = has("--force") || slices.ContainsFunc(args, func(a string) bool {
+				return strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.Contains(a, "f")
+			})
+		case "switch":
+			discards = has("-f", "--force", "--discard-changes")
+		}
+		if discards {
+			return strings.Join(words, " "), strings.Trim(dir, `'"`), true
+		}
+	}
+	return "", "", false
+}
+
+// uncommittedChanges lists git status --porcelain lines for the working tree
+// at cwd (dir, if the command named one with git -C). It returns nil when the
+// tree is clean, is not a git repository, or cannot be checked.
+func uncommittedChanges(ctx context.Context, cs *registry.ManagedCodespace, cwd, dir string) []string {
+	command := "git status --porcelain"
+	if dir != "" {
+		command = "git -C " + shellQuote(dir) + " status --porcelain"
+	}
+	stdout, _, exitCode, err := cs.Executor.RunBash(ctx, command, cwd)
+	if err != nil || exitCode != 0 {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// describeChanges names up to maxDirtyFilesListed porcelain lines.
+func describeChanges(changes []string) string {
+	shown := changes
+	if len(shown) > maxDirtyFilesListed {
+		shown = shown[:maxDirtyFilesListed]
+	}
+	desc := strings.Join(shown, ", ")
+	if len(changes) > len(shown) {
+		desc += fmt.Sprintf(", and %d more", len(changes)-len(shown))
+	}
+	return desc
+}
+
+// withDirtyTreeGuard checks the working tree before remote_bash runs a git
+// command that discards changes. A dirty tree puts a warning at the top of
+// the result; with requireConfirm the command is refused until the call sets
+// confirm_discard.
+func withDirtyTreeGuard(reg *registry.Registry, requireConfirm bool, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		command := optionalString(req, "command")
+		if steps, ok, _ := bashSteps(req); ok {
+			command = strings.Join(steps, "\n")
+		}
+		segment, dir, ok := discardingGitCommand(command)
+		if !ok {
+			return next(ctx, req)
+		}
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return next(ctx, req)
+		}
+		changes := uncommittedChanges(ctx, cs, optionalString(req, "cwd"), dir)
+		if len(changes) == 0 {
+			return next(ctx, req)
+		}
+		if requireConfirm && !optionalBool(req, "confirm_discard", false) {
+			return categorizedError(errPolicyDenied, fmt.Sprintf(
+				"`%s` would discard %d uncommitted changes (%s). Commit or stash them first, or ask the user and re-run with confirm_discard: true.",
+				segment, len(changes), describeChanges(changes))), nil
+		}
+		result, err := next(ctx, req)
+		if err != nil || result == nil {
+			return result, err
+		}
+		prependNote(result, fmt.Sprintf("[warning: the working tree had %d uncommitted changes before `%s`, which may have discarded them: %s]",
+			len(changes), segment, describeChanges(changes)))
+		return result, nil
+	}
+}
@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestDiscardingGitCommand(t *testing.T) {
+	for command, want := range map[string]string{
+		"git reset --hard":                                 "git reset --hard",
+		"git reset --hard HEAD~1 && make":                  "git reset --hard HEAD~1",
+		"git fetch && git reset origin/main --hard":        "git reset origin/main --hard",
+		"git checkout .":                                   "git checkout .",
+		"git checkout main -- src/app.go":                  "git checkout main -- src/app.go",
+		"git clean -fd":                                    "git clean -fd",
+		"git clean -xdf; ls":                               "git clean -xdf",
+		"GIT_TRACE=1 git -C /workspaces/app clean --force": "git -C /workspaces/app clean --force",
+		"git restore src/app.go":                           "git restore src/app.go",
+		"git restore --staged --worktree .":                "git restore --staged --worktree .",
+		"git switch --discard-changes main":                "git switch --discard-changes main",
+		"(cd web && git checkout -f)":                      "git checkout -f",
+	} {
+		got, _, ok := discardingGitCommand(command)
+		if !ok || got != want {
+			t.Errorf("discardingGitCommand(%q) = %q, %v; want %q", command, got, ok, want)
+		}
+	}
+	for _, command := range []string{
+		"git status", "git reset HEAD file.go", "git reset --soft HEAD~1", "git checkout main", "git checkout -b feature",
+		"git clean -n", "git restore --staged file.go", "git switch main", "echo git reset --hard", "rm -f x; git log",
+	} {
+		if got, _, ok := discardingGitCommand(command); ok {
+			t.Errorf("discardingGitCommand(%q) matched %q", command, got)
+		}
+	}
+	if _, dir, _ := discardingGitCommand("git -C '/workspaces/app' reset --hard"); dir != "/workspaces/app" {
+		t.Errorf("dir = %q", dir)
+	}
+}
+
+func TestWithDirtyTreeGuard(t *testing.T) {
+	ran := 0
+	inner := func(_ context.Context, _ mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		ran++
+		return toolSuccess("HEAD is now at abc123"), nil
+	}
+	mock := &mockExecutor{runBashStdout: " M src/app.go\n?? notes.txt\n"}
+	reg := testReg(mock)
+
+	result, _ := withDirtyTreeGuard(reg, false, inner)(context.Background(), makeReq(map[string]any{"command": "git reset --hard", "cwd": "/workspaces/app/web"}))
+	text := resultText(result)
+	if ran != 1 || !strings.HasPrefix(text, "[warning: the working tree had 2 uncommitted changes before `git reset --hard`, which may have discarded them:  M src/app.go, ?? notes.txt]") ||
+		!strings.HasSuffix(text, "\nHEAD is now at abc123") {
+		t.Errorf("result = %q", text)
+	}
+	if mock.lastRunBashCommand != "git status --porcelain" || mock.lastRunBashCwd != "/workspaces/app/web" {
+		t.Errorf("status probe = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	// Under the confirmation policy the command does not run until confirmed.
+	ran = 0
+	guard := withDirtyTreeGuard(reg, true, inner)
+	result, _ = guard(context.Background(), makeReq(map[string]any{"steps": []any{"git fetch", "git clean -fd"}}))
+	if ran != 0 || !strings.HasPrefix(resultText(result), "[error:policy_denied] `git clean -fd` would discard 2 uncommitted changes") {
+		t.Errorf("unconfirmed result = %q (ran %d)", resultText(result), ran)
+	}
+	result, _ = guard(context.Background(), makeReq(map[string]any{"command": "git clean -fd", "confirm_discard": true}))
+	if ran != 1 || !strings.HasPrefix(resultText(result), "[warning: the working tree had 2") {
+		t.Errorf("confirmed result = %q (ran %d)", resultText(result), ran)
+	}
+
+	// A clean tree, or a command that keeps changes, passes through untouched.
+	clean := &mockExecutor{}
+	result, _ = withDirtyTreeGuard(testReg(clean), true, inner)(context.Background(), makeReq(map[string]any{"command": "git reset --hard"}))
+	if resultText(result) != "HEAD is now at abc123" {
+		t.Errorf("clean tree result = %q", resultText(result))
+	}
+	mock.runBashCalls = 0
+	withDirtyTreeGuard(reg, true, inner)(context.Background(), makeReq(map[string]any{"command": "git status"}))
+	if mock.runBashCalls != 0 {
+		t.Errorf("probed the tree for a harmless command")
+	}
+}
@@ -0,0 +1,321 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// defaultGitLogCount and maxGitLogCount bound remote_git_log output.
+	defaultGitLogCount = 20
+	maxGitLogCount     = 200
+	// maxBlameLines caps remote_blame output when no line_range narrows it.
+	maxBlameLines = 500
+)
+
+// gitLogFormat separates hash, author, author time, and subject with unit
+// separators, which never appear in those fields.
+const gitLogFormat = "%h%x1f%an%x1f%at%x1f%s"
+
+// gitLogCommand lists up to count commits touching p (the whole history when
+// p is empty), newest first.
+func gitLogCommand(p, ref, since string, count int) string {
+	cmd := fmt.Sprintf("git log --no-color -n %d --format=%s", count, shellQuote(gitLogFormat))
+	if since != "" {
+		cmd += " --since=" + shellQuote(since)
+	}
+	if ref != "" {
+		cmd += " " + shellQuote(ref)
+	}
+	cmd += " --"
+	if p != "" {
+		cmd += " " + shellQuote(p)
+	}
+	return cmd
+}
+
+// gitCommit is one commit in compact form.
+type gitCommit struct {
+	hash    string
+	author  string
+	time    time.Time
+	subject string
+}
+
+func parseGitLog(out string) []gitCommit {
+	var commits []gitCommit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		c := gitCommit{hash: fields[0], author: fields[1], subject: fields[3]}
+		if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			c.time = time.Unix(secs, 0)
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// format renders the commit as "hash date author: subject", with the date in
+// the codespace's zone.
+func (c gitCommit) format(cs *registry.ManagedCodespace) string {
+	return fmt.Sprintf("%s %s %s: %s", c.hash, remoteDate(c.time, cs), c.author, c.subject)
+}
+
+// remoteDate formats t as a date in the codespace's UTC offset.
+func remoteDate(t time.Time, cs *registry.ManagedCodespace) string {
+	if t.IsZero() {
+		return "????-??-??"
+	}
+	return t.In(time.FixedZone(cs.TimeZone, cs.UTCOffset)).Format(time.DateOnly)
+}
+
+// gitBlameCommand blames p, limited to lines start through end (-1 for the
+// end of the file) when start is positive.
+func gitBlameCommand(p string, start, end int) string {
+	cmd := "git blame --porcelain"
+	if start > 0 {
+		if end < 0 {
+			cmd += fmt.Sprintf(" -L %d,", start)
+		} else {
+			cmd += fmt.Sprintf(" -L %d,%d", start, end)
+		}
+	}
+	return cmd + " -- " + shellQuote(p)
+}
+
+// blameLine is one line of git blame --porcelain output.
+type blameLine struct {
+	commit  gitCommit
+	line    int
+	content string
+}
+
+// parseGitBlame reads git blame --porcelain output. Commit details appear only
+// the first time a commit is seen, so they are remembered by hash.
+func parseGitBlame(out string) []blameLine {
+	commits := make(map[string]*gitCommit)
+	var lines []blameLine
+	var cur *gitCommit
+	var lineNo int
+	for _, line := range strings.Split(out, "\n") {
+		if content, ok := strings.CutPrefix(line, "\t"); ok {
+			if cur != nil {
+				lines = append(lines, blameLine{commit: *cur, line: lineNo, content: content})
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch {
+		case (len(key) == 40 || len(key) == 64) && isHex(key):
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				cur = nil
+				continue
+			}
+			lineNo, _ = strconv.Atoi(fields[1])
+			if commits[key] == nil {
+				commits[key] = &gitCommit{hash: key[:7]}
+			}
+			cur = commits[key]
+		case cur == nil:
+		case key == "author":
+			cur.author = value
+		case key == "author-time":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.time = time.Unix(secs, 0)
+			}
+		case key == "summary":
+			cur.subject = value
+		}
+	}
+	return lines
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatBlame groups consecutive lines from the same commit under one compact
+// commit header, with lines numbered as remote_view numbers them.
+func formatBlame(lines []blameLine, cs *registry.ManagedCodespace) string {
+	var sb strings.Builder
+	prev := ""
+	for _, l := range lines {
+		if l.commit.hash != prev {
+			sb.WriteString(l.commit.format(cs) + "\n")
+			prev = l.commit.hash
+		}
+		fmt.Fprintf(&sb, "  %d. %s\n", l.line, l.content)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// --- remote_git_log ---
+
+func gitLogTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_git_log",
+		Annotations: readOnlyHints("Show remote git history", false),
+		Description: "List recent commits on the remote codespace, optionally only those touching a path, one line each: short hash, date, author, and subject. " +
+			"Use this instead of git log through remote_bash for history-aware tasks; follow up with remote_bash and git show for a full diff.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Optional file or directory to show history for. Relative paths resolve against cwd.",
+				},
+				"max_count": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of commits to return (default: %d, max: %d)", defaultGitLogCount, maxGitLogCount),
+				},
+				"since": map[string]any{
+					"type":        "string",
+					"description": "Optional lower bound understood by git log --since, e.g. '2 weeks ago' or '2026-01-01'",
+				},
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Optional branch, tag, or revision range to list (default: HEAD)",
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory inside the repository (default: current working directory)",
+				},
+			},
+		},
+	}
+}
+
+func gitLogHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		count := defaultGitLogCount
+		if raw, ok := req.GetArguments()["max_count"]; ok {
+			n, isInt := toInt(raw)
+			if !isInt || n < 1 {
+				return categorizedError(errInvalidArgument, "max_count must be a positive integer"), nil
+			}
+			count = min(n, maxGitLogCount)
+		}
+		ref := optionalString(req, "ref")
+		if strings.HasPrefix(ref, "-") {
+			return categorizedError(errInvalidArgument, "ref must not start with '-'"), nil
+		}
+
+		p := optionalString(req, "path")
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, gitLogCommand(p, ref, optionalString(req, "since"), count), optionalString(req, "cwd"))
+		if err != nil {
+			return toolError(fmt.Sprintf("git log: %v", err)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("git log failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		commits := parseGitLog(stdout)
+		if len(commits) == 0 {
+			return toolSuccess("No commits found."), nil
+		}
+		out := make([]string, len(commits))
+		for i, c := range commits {
+			out[i] = c.format(cs)
+		}
+		return toolSuccess(strings.Join(out, "\n")), nil
+	}
+}
+
+// --- remote_blame ---
+
+func blameTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_blame",
+		Annotations: readOnlyHints("Blame remote file", false),
+		Description: "Show which commit last changed each line of a file on the remote codespace. " +
+			"Consecutive lines from one commit are grouped under a header with its short hash, date, author, and subject. " +
+			fmt.Sprintf("Pass line_range to blame only the lines you care about; without it, output stops after %d lines.", maxBlameLines),
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"path": map[string]any{
+					"type":        "string",
+					"description": "File to blame. Relative paths resolve against cwd.",
+				},
+				"line_range": map[string]any{
+					"type":        "array",
+					"description": "Optional [start_line, end_line] range. Use -1 for end_line to blame to the end of the file.",
+					"items":       map[string]any{"type": "integer"},
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory inside the repository (default: current working directory)",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}
+}
+
+func blameHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		p, err := requiredString(req, "path")
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		var start, end int
+		if raw, ok := req.GetArguments()["line_range"]; ok {
+			arr, isArr := raw.([]any)
+			if !isArr || len(arr) != 2 {
+				return categorizedError(errInvalidArgument, "line_range must be [start_line, end_line]"), nil
+			}
+			var ok1, ok2 bool
+			start, ok1 = toInt(arr[0])
+			end, ok2 = toInt(arr[1])
+			if !ok1 || !ok2 || start < 1 || (end != -1 && end < start) {
+				return categorizedError(errInvalidArgument, "line_range must be [start_line, end_line] with 1 <= start_line <= end_line, or end_line -1"), nil
+			}
+		}
+
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, gitBlameCommand(p, start, end), optionalString(req, "cwd"))
+		if err != nil {
+			return toolError(fmt.Sprintf("git blame: %v", err)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("git blame failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		lines := parseGitBlame(stdout)
+		if len(lines) == 0 {
+			return toolSuccess("No lines to blame."), nil
+		}
+		truncated := start == 0 && len(lines) > maxBlameLines
+		if truncated {
+			lines = lines[:maxBlameLines]
+		}
+		text := formatBlame(lines, cs)
+		if truncated {
+			text += fmt.Sprintf("\n[truncated after %d lines; pass line_range to blame the rest]", maxBlameLines)
+		}
+		return toolSuccess(text), nil
+	}
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+func TestGitLogCommand(t *testing.T) {
+	got := gitLogCommand("cmd/main.go", "origin/main", "2 weeks ago", 5)
+	want := "git log --no-color -n 5 --format='%h%x1f%an%x1f%at%x1f%s' --since='2 weeks ago' 'origin/main' -- 'cmd/main.go'"
+	if got != want {
+		t.Errorf("gitLogCommand() = %q, want %q", got, want)
+	}
+	if got := gitLogCommand("", "", "", 20); got != "git log --no-color -n 20 --format='%h%x1f%an%x1f%at%x1f%s' --" {
+		t.Errorf("gitLogCommand() without path = %q", got)
+	}
+}
+
+func TestGitLogHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "abc1234\x1fJane Doe\x1f1772366400\x1fFix parser: handle tabs\n" +
+		"def5678\x1fJohn Roe\x1f1772280000\x1fAdd tests\n"}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: mock, TimeZone: "PST", UTCOffset: -8 * 3600})
+
+	res, _ := gitLogHandler(reg)(context.Background(), makeReq(map[string]any{"path": "parser.go", "max_count": float64(500), "cwd": "/workspaces/app"}))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	want := "abc1234 2026-03-01 Jane Doe: Fix parser: handle tabs\ndef5678 2026-02-28 John Roe: Add tests"
+	if resultText(res) != want {
+		t.Errorf("result = %q, want %q", resultText(res), want)
+	}
+	if mock.lastRunBashCommand != gitLogCommand("parser.go", "", "", maxGitLogCount) || mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("command = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	for _, args := range []map[string]any{{"max_count": float64(0)}, {"ref": "--output=/tmp/x"}} {
+		res, _ := gitLogHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(args))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("%v: result = %q", args, resultText(res))
+		}
+	}
+
+	res, _ = gitLogHandler(testReg(&mockExecutor{runBashExit: 128, runBashStderr: "fatal: not a git repository"}))(context.Background(), makeReq(nil))
+	if !res.IsError || !strings.Contains(resultText(res), "not a git repository") {
+		t.Errorf("git failure result = %q", resultText(res))
+	}
+}
+
+const blamePorcelain = "1111111111111111111111111111111111111111 10 10 2\n" +
+	"author Jane Doe\n" +
+	"author-time 1772366400\n" +
+	"summary Fix parser\n" +
+	"filename parser.go\n" +
+	"\tfunc parse() {\n" +
+	"1111111111111111111111111111111111111111 11 11\n" +
+	"\t\treturn nil\n" +
+	"2222222222222222222222222222222222222222 5 12 1\n" +
+	"author John Roe\n" +
+	"author-time 1772280000\n" +
+	"summary Add parser\n" +
+	"previous 3333333333333333333333333333333333333333 parser.go\n" +
+	"filename parser.go\n" +
+	"\t}\n"
+
+func TestParseAndFormatGitBlame(t *testing.T) {
+	lines := parseGitBlame(blamePorcelain)
+	if len(lines) != 3 {
+		t.Fatalf("parseGitBlame() returned %d lines", len(lines))
+	}
+	if lines[1].commit.author != "Jane Doe" || lines[1].line != 11 || lines[1].content != "\treturn nil" {
+		t.Errorf("second line = %+v", lines[1])
+	}
+	if !lines[2].commit.time.Equal(time.Unix(1772280000, 0)) {
+		t.Errorf("third line time = %v", lines[2].commit.time)
+	}
+
+	got := formatBlame(lines, &registry.ManagedCodespace{})
+	want := "1111111 2026-03-01 Jane Doe: Fix parser\n" +
+		"  10. func parse() {\n" +
+		"  11. \treturn nil\n" +
+		"2222222 2026-02-28 John Roe: Add parser\n" +
+		"  12. }"
+	if got != want {
+		t.Errorf("formatBlame() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBlameHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: blamePorcelain}
+	res, _ := blameHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"path": "parser.go", "line_range": []any{float64(10), float64(-1)}}))
+	if res.IsError || !strings.HasPrefix(resultText(res), "1111111 2026-03-01 Jane Doe: Fix parser\n") {
+		t.Fatalf("result = %q", resultText(res))
+	}
+	if mock.lastRunBashCommand != "git blame --porcelain -L 10, -- 'parser.go'" {
+		t.Errorf("command = %q", mock.lastRunBashCommand)
+	}
+
+	for _, r := range []any{[]any{float64(0), float64(3)}, []any{float64(5), float64(2)}, "1-3"} {
+		res, _ := blameHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(map[string]any{"path": "a.go", "line_range": r}))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("line_range %v: result = %q", r, resultText(res))
+		}
+	}
+}
+
+func TestBlameHandlerTruncatesWholeFile(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("1111111111111111111111111111111111111111 1 1 600\nauthor A\nauthor-time 1772366400\nsummary S\n\tline\n")
+	for i := 2; i <= maxBlameLines+100; i++ {
+		fmt.Fprintf(&sb, "1111111111111111111111111111111111111111 %d %d\n\tline\n", i, i)
+	}
+	res, _ := blameHandler(testReg(&mockExecutor{runBashStdout: sb.String()}))(context.Background(), makeReq(map[string]any{"path": "big.go"}))
+	text := resultText(res)
+	if !strings.HasSuffix(text, "pass line_range to blame the rest]") || strings.Count(text, "\n  ") != maxBlameLines {
+		t.Errorf("result not truncated to %d lines: ...%q", maxBlameLines, text[len(text)-80:])
+	}
+}
@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// gitProbeCommand prints "remote NAME URL" per fetch remote, then
+// "default BRANCH" from gh, falling back to origin's HEAD when gh cannot
+// reach the API.
+const gitProbeCommand = `git remote -v 2>/dev/null | awk '$3 == "(fetch)" {print "remote " $1 " " $2}'; ` +
+	`b=$(gh repo view --json defaultBranchRef -q .defaultBranchRef.name 2>/dev/null); ` +
+	`[ -n "$b" ] || b=$(git symbolic-ref --short refs/remotes/origin/HEAD 2>/dev/null | sed 's|^origin/||'); ` +
+	`echo "default $b"`
+
+// ProbeGit reports the repository's fetch remotes and default branch on the
+// codespace. Empty values mean the probe failed or workdir is not a git
+// checkout.
+func ProbeGit(ctx context.Context, ex ssh.Executor, workdir string) (remotes []registry.GitRemote, defaultBranch string) {
+	stdout, _, _, err := ex.RunBash(ctx, gitProbeCommand, workdir)
+	if err != nil {
+		return nil, ""
+	}
+	return parseGitProbe(stdout)
+}
+
+func parseGitProbe(out string) (remotes []registry.GitRemote, defaultBranch string) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "remote":
+			remotes = append(remotes, registry.GitRemote{Name: fields[1], URL: redactRemoteURL(fields[2])})
+		case len(fields) == 2 && fields[0] == "default":
+			defaultBranch = fields[1]
+		}
+	}
+	return remotes, defaultBranch
+}
+
+// redactRemoteURL drops credentials embedded in an https remote URL, which
+// would otherwise end up in the instructions file.
+func redactRemoteURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil || u.Scheme == "" {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// GitSummary renders remotes compactly, e.g. "origin https://github.com/o/r".
+func GitSummary(remotes []registry.GitRemote) string {
+	parts := make([]string, len(remotes))
+	for i, r := range remotes {
+		parts[i] = r.Name + " " + r.URL
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GitInstructions returns instruction context naming the repository's remotes
+// and default branch, for commands like gh pr create, or "" when neither is
+// known.
+func GitInstructions(remotes []registry.GitRemote, defaultBranch, branch string) string {
+	if len(remotes) == 0 && defaultBranch == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Git repository\n\n")
+	for _, r := range remotes {
+		fmt.Fprintf(&sb, "- Remote `%s`: %s\n", r.Name, r.URL)
+	}
+	if defaultBranch != "" {
+		fmt.Fprintf(&sb, "- Default branch: `%s`", defaultBranch)
+		if branch != "" && branch != defaultBranch {
+			fmt.Fprintf(&sb, " (checked out: `%s`)", branch)
+		}
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "- Open pull requests against it with `gh pr create --base %s` run through remote_bash\n", defaultBranch)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// UnpublishedInstructions tells the agent that a codespace's repository has
+// not been published, so commands that need a GitHub repository will fail.
+const UnpublishedInstructions = "## Git repository\n\n" +
+	"This codespace's repository has not been published to GitHub yet. `gh pr`, `gh repo view`, and other commands that need a GitHub repository will fail, and there is no remote to push to. " +
+	"Commit locally, and ask the user before publishing it (`gh repo create --source . --push`).\n\n"
+
+// connectedGitNote appends the remotes and default branch to a connect result.
+// repoKnown is false when the repository lookup failed, so an empty
+// Repository does not mean the codespace is unpublished.
+func connectedGitNote(cs *registry.ManagedCodespace, repoKnown bool) string {
+	text := GitInstructions(cs.Remotes, cs.DefaultBranch, cs.Branch)
+	if repoKnown && cs.Repository == "" && len(cs.Remotes) == 0 {
+		text = UnpublishedInstructions
+	}
+	if text == "" {
+		return ""
+	}
+	return "\n\n" + strings.TrimSpace(text)
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxPathsFrom caps how many paths a paths_from search passes on one
+// command line.
+const maxPathsFrom = 2000
+
+// grepMatchRe matches a "path:line:" prefix from remote_grep output.
+var grepMatchRe = regexp.MustCompile(`^(.+?):\d+[:-]`)
+
+// grepPathsFrom reads the paths_from argument: an array of paths or result
+// lines, or the text of an earlier remote_glob or remote_grep result. Grep
+// match lines are reduced to their file, and duplicates are dropped. ok is
+// false when the argument is absent.
+func grepPathsFrom(req mcpsdk.CallToolRequest) (paths []string, ok bool, err error) {
+	raw, present := req.GetArguments()["paths_from"]
+	if !present {
+		return nil, false, nil
+	}
+	var entries []string
+	switch v := raw.(type) {
+	case string:
+		entries = strings.Split(v, "\n")
+	case []any:
+		for i, item := range v {
+			s, isString := item.(string)
+			if !isString {
+				return nil, true, fmt.Errorf("paths_from[%d] must be a string", i)
+			}
+			entries = append(entries, s)
+		}
+	default:
+		return nil, true, fmt.Errorf("paths_from must be an array of paths or the text of a previous result")
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || entry == "No matches found." {
+			continue
+		}
+		if m := grepMatchRe.FindStringSubmatch(entry); m != nil {
+			entry = m[1]
+		}
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		paths = append(paths, entry)
+	}
+	if len(paths) == 0 {
+		return nil, true, fmt.Errorf("paths_from contains no paths")
+	}
+	if len(paths) > maxPathsFrom {
+		return nil, true, fmt.Errorf("paths_from has %d paths (max %d); narrow the previous search first", len(paths), maxPathsFrom)
+	}
+	return paths, true, nil
+}
+
+// grepPathsCommand searches only paths with ripgrep, falling back to grep.
+// Filenames are always printed so the output can feed another paths_from.
+func grepPathsCommand(pattern string, paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	files := strings.Join(quoted, " ")
+	return fmt.Sprintf("if command -v rg >/dev/null 2>&1; then rg --color=never -n -H -e %s -- %s; else grep -rn -H -e %s -- %s; fi",
+		shellQuote(pattern), files, shellQuote(pattern), files)
+}
@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrepPathsFrom(t *testing.T) {
+	paths, ok, err := grepPathsFrom(makeReq(map[string]any{"paths_from": []any{
+		"src/a.go", "src/b.go:12:func main() {", "src/b.go:40:}", "  ", "weird:name.go",
+	}}))
+	if err != nil || !ok {
+		t.Fatalf("grepPathsFrom() = %v, %v", ok, err)
+	}
+	if want := []string{"src/a.go", "src/b.go", "weird:name.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+
+	paths, _, err = grepPathsFrom(makeReq(map[string]any{"paths_from": "./x.go\n./y.go\n"}))
+	if err != nil || !reflect.DeepEqual(paths, []string{"./x.go", "./y.go"}) {
+		t.Errorf("text result: paths = %q, err = %v", paths, err)
+	}
+
+	if _, ok, err := grepPathsFrom(makeReq(map[string]any{})); ok || err != nil {
+		t.Errorf("absent: ok = %v, err = %v", ok, err)
+	}
+	tooMany := make([]any, maxPathsFrom+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("f%d.go", i)
+	}
+	for name, raw := range map[string]any{
+		"empty":      "No matches found.",
+		"non-string": []any{1},
+		"too many":   tooMany,
+	} {
+		if _, _, err := grepPathsFrom(makeReq(map[string]any{"paths_from": raw})); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGrepPathsCommand(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n// TODO: fix\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "it's.go"), []byte("// TODO: quote\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "skip.go"), []byte("// TODO: not listed\n"), 0o644)
+
+	cmd := exec.Command("bash", "-c", grepPathsCommand("TODO", []string{"a.go", "it's.go"}))
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("grepPathsCommand: %v", err)
+	}
+	got := string(out)
+	if !strings.Contains(got, "a.go:2:// TODO: fix") || !strings.Contains(got, "it's.go:1:// TODO: quote") || strings.Contains(got, "skip.go") {
+		t.Errorf("output:\n%s", got)
+	}
+}
+
+func TestGrepHandlerPathsFrom(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "src/b.go:3:TODO\n"}
+	result, _ := grepHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"pattern":    "TODO",
+		"paths_from": []any{"src/a.go", "src/b.go:12:x"},
+		"cwd":        "/workspaces/app",
+	}))
+	if result.IsError || resultText(result) != "src/b.go:3:TODO\n" {
+		t.Fatalf("result = %q", resultText(result))
+	}
+	if mock.lastRunBashCommand != grepPathsCommand("TODO", []string{"src/a.go", "src/b.go"}) || mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("command = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	mock = &mockExecutor{runBashExit: 1}
+	result, _ = grepHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"pattern": "x", "paths_from": []any{"a"}}))
+	if result.IsError || resultText(result) != "No matches found." {
+		t.Errorf("no matches result = %q", resultText(result))
+	}
+
+	result, _ = grepHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(map[string]any{"pattern": "x", "paths_from": []any{"a"}, "glob": "*.go"}))
+	if !result.IsError || !strings.HasPrefix(resultText(result), "[error:invalid_argument]") {
+		t.Errorf("paths_from with glob = %q", resultText(result))
+	}
+}
@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// masterCheckTimeout bounds ssh -O check, which only talks to the local
+	// master process.
+	masterCheckTimeout = 3 * time.Second
+	// statusProbeTimeout bounds the round trip to the codespace.
+	statusProbeTimeout = 10 * time.Second
+)
+
+// connectionReporter is implemented by executors that keep an SSH
+// connection to report on (*ssh.Client).
+type connectionReporter interface {
+	SSHConfigPath() string
+	CheckMaster(ctx context.Context) error
+	LastSuccess() time.Time
+}
+
+// agentDaemonReporter is implemented by executors that can send commands to
+// the exec agent's daemon instead of starting ssh per call (*ssh.Client).
+type agentDaemonReporter interface {
+	AgentDaemonSocket() string
+}
+
+// statusProbeCommand answers "ok", plus whether the exec agent is still
+// executable when one was deployed.
+func statusProbeCommand(execAgent string) string {
+	if execAgent == "" {
+		return "echo ok"
+	}
+	return fmt.Sprintf(`echo ok; if [ -x %s ]; then echo 'agent ok'; else echo 'agent missing'; fi`, shellQuote(execAgent))
+}
+
+// codespaceStatus reports one codespace's connection. With probe it also
+// runs a command on the codespace, timing the round trip.
+func codespaceStatus(ctx context.Context, cs *registry.ManagedCodespace, probe bool, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%s):\n", cs.Alias, cs.Name)
+
+	var lastSuccess time.Time
+	if conn, ok := cs.Executor.(connectionReporter); ok {
+		lastSuccess = conn.LastSuccess()
+		if path := conn.SSHConfigPath(); path != "" {
+			fmt.Fprintf(&sb, "  connection: shared SSH master (%s)\n", path)
+			checkCtx, cancel := context.WithTimeout(ctx, masterCheckTimeout)
+			err := conn.CheckMaster(checkCtx)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(&sb, "  master: down (%v); the next command reconnects\n", err)
+			} else {
+				sb.WriteString("  master: up\n")
+			}
+			if daemon, ok := cs.Executor.(agentDaemonReporter); ok && daemon.AgentDaemonSocket() != "" {
+				fmt.Fprintf(&sb, "  commands: exec agent daemon through a forwarded socket (%s)\n", daemon.AgentDaemonSocket())
+			}
+		} else {
+			sb.WriteString("  connection: gh codespace ssh per command (no shared master; each call takes seconds)\n")
+		}
+	}
+
+	var agentLine string
+	if probe {
+		probeCtx, cancel := context.WithTimeout(ctx, statusProbeTimeout)
+		start := time.Now()
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(probeCtx, statusProbeCommand(cs.ExecAgent), "")
+		elapsed := time.Since(start).Round(time.Millisecond)
+		cancel()
+		switch {
+		case err != nil:
+			fmt.Fprintf(&sb, "  round trip: failed after %s (%v)\n", elapsed, err)
+		case exitCode != 0 || !strings.HasPrefix(stdout, "ok"):
+			fmt.Fprintf(&sb, "  round trip: failed after %s (exit %d: %s)\n", elapsed, exitCode, strings.TrimSpace(stderr))
+		default:
+			fmt.Fprintf(&sb, "  round trip: ok in %s\n", elapsed)
+			if strings.Contains(stdout, "agent ok") {
+				agentLine = fmt.Sprintf("ok (%s)", cs.ExecAgent)
+			} else if strings.Contains(stdout, "agent missing") {
+				agentLine = fmt.Sprintf("missing from %s (relaunch to redeploy)", cs.ExecAgent)
+			}
+		}
+	}
+
+	if lastSuccess.IsZero() {
+		sb.WriteString("  last successful command: none yet\n")
+	} else {
+		fmt.Fprintf(&sb, "  last successful command: %s ago\n", now.Sub(lastSuccess).Round(time.Second))
+	}
+
+	switch {
+	case cs.ExecAgent == "":
+		sb.WriteString("  exec agent: not deployed")
+	case agentLine != "":
+		sb.WriteString("  exec agent: " + agentLine)
+	default:
+		fmt.Fprintf(&sb, "  exec agent: deployed at %s (not checked)", cs.ExecAgent)
+	}
+	return sb.String()
+}
+
+// --- remote_status ---
+
+func statusTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_status",
+		Annotations: readOnlyHints("Connection status", false),
+		Description: "Report each codespace's connection: whether commands share an SSH master and whether it is up, the round-trip time of a trivial command, " +
+			"how long ago a command last succeeded, and whether the exec agent is available. Check it before starting long operations, or when tools start failing or hanging.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": map[string]any{
+					"type":        "string",
+					"description": "Codespace alias (default: every connected codespace)",
+				},
+				"probe": map[string]any{
+					"type":        "boolean",
+					"description": "Run a command on the codespace to time the round trip and check the exec agent (default: true). false reports only local state and returns at once.",
+				},
+			},
+		},
+	}
+}
+
+func statusHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		targets := reg.All()
+		if optionalString(req, "codespace") != "" {
+			cs, err := resolveCodespace(reg, req)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			targets = []*registry.ManagedCodespace{cs}
+		}
+		if len(targets) == 0 {
+			return toolSuccess("No codespaces connected. Use connect_codespace or create_codespace first."), nil
+		}
+		probe := optionalBool(req, "probe", true)
+		now := time.Now()
+		reports := make([]string, len(targets))
+		for i, cs := range targets {
+			reports[i] = codespaceStatus(ctx, cs, probe, now)
+		}
+		return toolSuccess(strings.Join(reports, "\n\n")), nil
+	}
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// reportingExecutor adds connection state to mockExecutor.
+type reportingExecutor struct {
+	*mockExecutor
+	sshConfig   string
+	masterErr   error
+	lastSuccess time.Time
+	agentSocket string
+}
+
+func (r *reportingExecutor) SSHConfigPath() string                 { return r.sshConfig }
+func (r *reportingExecutor) CheckMaster(ctx context.Context) error { return r.masterErr }
+func (r *reportingExecutor) LastSuccess() time.Time                { return r.lastSuccess }
+func (r *reportingExecutor) AgentDaemonSocket() string             { return r.agentSocket }
+
+func TestStatusHandler(t *testing.T) {
+	app := &reportingExecutor{
+		mockExecutor: &mockExecutor{runBashStdout: "ok\nagent ok\n"},
+		sshConfig:    "/home/me/.copilot/codespace-workdirs/.ssh-config-cs-app",
+		lastSuccess:  time.Now().Add(-90 * time.Second),
+	}
+	api := &reportingExecutor{
+		mockExecutor: &mockExecutor{runBashErr: errors.New("connection timed out")},
+	}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: app, ExecAgent: "/tmp/agent"})
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "cs-api", Executor: api})
+
+	res, _ := statusHandler(reg)(context.Background(), makeReq(nil))
+	text := resultText(res)
+	for _, want := range []string{
+		"app (cs-app):\n  connection: shared SSH master (/home/me/.copilot/codespace-workdirs/.ssh-config-cs-app)\n  master: up\n  round trip: ok in ",
+		"  last successful command: 1m30s ago\n  exec agent: ok (/tmp/agent)",
+		"api (cs-api):\n  connection: gh codespace ssh per command",
+		"  round trip: failed after ",
+		"(connection timed out)\n  last successful command: none yet\n  exec agent: not deployed",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("status missing %q:\n%s", want, text)
+		}
+	}
+	if app.lastRunBashCommand != "echo ok; if [ -x '/tmp/agent' ]; then echo 'agent ok'; else echo 'agent missing'; fi" {
+		t.Errorf("probe = %q", app.lastRunBashCommand)
+	}
+
+	if strings.Contains(text, "  commands: ") {
+		t.Errorf("status reports an agent daemon that is not in use:\n%s", text)
+	}
+
+	app.masterErr = errors.New("Control socket connect(/tmp/sock): No such file or directory")
+	app.agentSocket = "/home/me/.copilot/codespace-workdirs/.agent-cs-app-42"
+	app.runBashCalls = 0
+	res, _ = statusHandler(reg)(context.Background(), makeReq(map[string]any{"codespace": "app", "probe": false}))
+	text = resultText(res)
+	if !strings.Contains(text, "master: down (Control socket connect(/tmp/sock): No such file or directory); the next command reconnects\n  commands: exec agent daemon through a forwarded socket (/home/me/.copilot/codespace-workdirs/.agent-cs-app-42)\n") ||
+		!strings.HasSuffix(text, "exec agent: deployed at /tmp/agent (not checked)") || strings.Contains(text, "cs-api") {
+		t.Errorf("status without probe:\n%s", text)
+	}
+	if app.runBashCalls != 0 {
+		t.Errorf("probe: false still ran %d commands", app.runBashCalls)
+	}
+}
+
+func TestStatusHandlerNoCodespaces(t *testing.T) {
+	res, _ := statusHandler(registry.New())(context.Background(), makeReq(nil))
+	if res.IsError || !strings.HasPrefix(resultText(res), "No codespaces connected.") {
+		t.Errorf("result = %q", resultText(res))
+	}
+}
@@ -0,0 +1,18 @@
+package mcp
+
+import "fmt"
+
+// jqInstallScript puts jq on PATH, installing it with mise (and mise itself)
+// when the codespace image lacks it. It runs after misePathSetup. Install
+// chatter is discarded so it can't corrupt the filtered output.
+const jqInstallScript = `command -v jq >/dev/null 2>&1 || ` +
+	`{ { command -v mise >/dev/null 2>&1 || curl -fsSL https://mise.jdx.dev/install.sh | sh; } && mise use -g jq; } >/dev/null 2>&1 || ` +
+	`{ echo 'jq is not installed and could not be installed with mise' >&2; exit 127; }`
+
+// jqPipeline wraps command so its stdout is filtered through jq on the
+// codespace, and only the extracted fields come back. pathSetup comes from
+// misePathSetup. With pipefail the command's own failure still wins over jq's
+// exit status.
+func jqPipeline(pathSetup, command, filter string) string {
+	return fmt.Sprintf("%s; %s; set -o pipefail; { %s\n} | jq %s", pathSetup, jqInstallScript, command, shellQuote(filter))
+}
@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+func TestJQPipeline(t *testing.T) {
+	if _, err := exec.LookPath("jq"); err != nil {
+		t.Skip("jq not installed")
+	}
+	run := func(command, filter string) (string, int) {
+		t.Helper()
+		cmd := exec.Command("bash", "-c", jqPipeline(ssh.MisePATH, command, filter))
+		cmd.Env = append(cmd.Environ(), "HOME="+t.TempDir())
+		out, err := cmd.Output()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(out), exitErr.ExitCode()
+		}
+		if err != nil {
+			t.Fatalf("running pipeline: %v", err)
+		}
+		return string(out), 0
+	}
+
+	out, code := run(`echo '{"items":[{"name":"a","big":"x"},{"name":"b"}]}'`, ".items[].name")
+	if code != 0 || out != "\"a\"\n\"b\"\n" {
+		t.Errorf("filtered output = %q (exit %d)", out, code)
+	}
+
+	// The command's failure is reported even though jq succeeds.
+	if _, code := run(`echo '{}'; exit 3`, "."); code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+
+	// A trailing comment in the command doesn't swallow the pipeline.
+	if out, _ := run(`echo '[1,2]' # list`, "length"); out != "2\n" {
+		t.Errorf("output = %q, want 2", out)
+	}
+}
+
+func TestBashHandler_JQ(t *testing.T) {
+	mock := &mockExecutor{readSessionResult: "\"a\"\n[session exited]"}
+	res, err := bashHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"command":      "kubectl get pods -o json",
+		"jq":           ".items[].metadata.name",
+		"initial_wait": 0,
+	}))
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected error: %v %s", err, resultText(res))
+	}
+	if !strings.HasSuffix(mock.lastCommand, "{ kubectl get pods -o json\n} | jq '.items[].metadata.name'") {
+		t.Errorf("started command = %q", mock.lastCommand)
+	}
+
+	// The codespace user's detected home is used for mise's shims.
+	homeMock := &miseHomeExecutor{mockExecutor: &mockExecutor{readSessionResult: "[session exited]"}}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "test", Name: "test-cs", Executor: homeMock})
+	bashHandler(reg)(context.Background(), makeReq(map[string]any{"command": "cat a.json", "jq": ".", "initial_wait": 0}))
+	if !strings.HasPrefix(homeMock.lastCommand, homeMock.MisePathSetup()+"; ") {
+		t.Errorf("started command = %q, want the executor's mise PATH", homeMock.lastCommand)
+	}
+}
+
+// miseHomeExecutor is a mock whose codespace user's home has been detected.
+type miseHomeExecutor struct {
+	*mockExecutor
+}
+
+func (miseHomeExecutor) MisePathSetup() string {
+	return `PATH='/home/dev'/.local/bin:'/home/dev'/.local/share/mise/shims:"$PATH"`
+}
+
+func TestBashCacheKey_JQ(t *testing.T) {
+	reg := testReg(&mockExecutor{})
+	plain := bashCacheKey(reg, makeReq(map[string]any{"command": "cat pods.json"}))
+	filtered := bashCacheKey(reg, makeReq(map[string]any{"command": "cat pods.json", "jq": ".items"}))
+	if plain == "" || filtered == "" || plain == filtered {
+		t.Errorf("cache keys should differ by filter: %q, %q", plain, filtered)
+	}
+}
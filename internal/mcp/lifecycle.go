@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"slices"
@@ -12,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
 	"github.com/ekroon/gh-copilot-codespace/internal/provisioner"
 	"github.com/ekroon/gh-copilot-codespace/internal/registry"
 	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
@@ -43,6 +45,33 @@ type LifecycleConfig struct {
 	Provisioners []provisioner.Provisioner // optional: run after setup
 	AccessPolicy CodespaceAccessPolicy
 	Workspace    WorkspaceSessionContext
+	ReadOnly     bool          // omit file-mutating and codespace create/delete tools
+	Audit        AuditRecorder // optional: receives an event per mutating tool call
+	NoAutoStart  bool          // report suspended codespaces instead of starting them
+	ToolLog      io.Writer     // optional: one line per tool call with its outcome and duration
+	DeniedTools  []string      // tools refused at call time with policy_denied
+	Middlewares  []Middleware  // optional: run around every tool call, inside the built-in chain
+	RecordingDir string        // optional: record async sessions and collect casts here at exit
+	// SealMirrorDir is the mirror directory the server process encrypts when
+	// it exits, after collecting recordings into it.
+	SealMirrorDir string
+	// ConfineToWorkdir rejects file tool paths that resolve outside the
+	// codespace workdir, following symlinks on the codespace.
+	ConfineToWorkdir bool
+	// ConfirmDiscard makes remote_bash refuse git commands that discard
+	// uncommitted changes until the call sets confirm_discard. Without it,
+	// such commands run with a warning listing the changes.
+	ConfirmDiscard bool
+	// WriteQuota caps the bytes remote_create, remote_edit, and
+	// remote_scaffold write in the session; past it, each call needs the
+	// user's approval. Zero is no limit.
+	WriteQuota int64
+	// RemoteTask adds the experimental remote_task tool, which runs a Copilot
+	// CLI sub-agent on the codespace.
+	RemoteTask bool
+	// PortForwards tracks remote_ports forwards. The caller stops them with
+	// StopAll when the server exits; NewServer creates one when it is nil.
+	PortForwards *PortForwards
 }
 
 type lifecycleState struct {
@@ -184,6 +213,7 @@ func (p CodespaceAccessPolicy) deniedConnectMessage(csName string) string {
 func createCodespaceTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "create_codespace",
+		Annotations: mutatingHints("Create codespace", false, false, true),
 		Description: "Create a new GitHub Codespace, wait for it to be ready, and connect to it. This operation may take 1-3 minutes. Use get_codespace_options first to see available machine types and devcontainer configs for the repository.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -252,7 +282,7 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 
 		// Check alias isn't already taken
 		if _, err := reg.Resolve(alias); err == nil {
-			return toolError(fmt.Sprintf("alias %q already in use; specify a different alias", alias)), nil
+			return categorizedError(errConflict, fmt.Sprintf("alias %q already in use; specify a different alias", alias)), nil
 		}
 
 		// Build gh cs create command
@@ -297,12 +327,12 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 
 		// Wait for SSH readiness
 		for i := 0; i < 30; i++ {
-			checkOut, err := state.cfg.GHRunner.Run(ctx, "codespace", "ssh", "-c", csName, "--", "echo ready")
+			checkOut, err := state.cfg.GHRunner.Run(ctx, append(ssh.GHSSHArgs(csName), "--", "echo ready")...)
 			if err == nil && strings.Contains(checkOut, "ready") {
 				break
 			}
 			if i == 29 {
-				return toolError(fmt.Sprintf("codespace %s created but SSH not ready after 30 attempts", csName)), nil
+				return categorizedError(errTimeout, fmt.Sprintf("codespace %s created but SSH not ready after 30 attempts", csName)), nil
 			}
 			time.Sleep(3 * time.Second)
 		}
@@ -310,7 +340,7 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 		// Setup SSH multiplexing
 		sshClient := ssh.NewClient(csName)
 		if err := sshClient.SetupMultiplexing(ctx); err != nil {
-			return toolError(fmt.Sprintf("SSH multiplexing failed: %v", err)), nil
+			return categorizedError(errConnection, fmt.Sprintf("SSH multiplexing failed: %v", err)), nil
 		}
 
 		// Deploy exec agent binary
@@ -326,6 +356,7 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 
 		// Detect workdir
 		workdir := detectCSWorkdir(ctx, sshClient, repo)
+		sshClient.DetectRemoteUser(ctx, workdir)
 
 		// Checkout branch if specified
 		if branch != "" {
@@ -345,6 +376,11 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 			Executor:   sshClient,
 			ExecAgent:  execAgent,
 		}
+		cs.CPUs, cs.MemoryBytes = ProbeMachine(ctx, sshClient)
+		cs.TimeZone, cs.UTCOffset, cs.ClockOffset = ProbeClock(ctx, sshClient)
+		cs.Remotes, cs.DefaultBranch = ProbeGit(ctx, sshClient, workdir)
+		_, cs.DevcontainerHooks, _ = ProbeDevcontainerHooks(ctx, sshClient, workdir)
+		enableRecording(cs, state.cfg.RecordingDir)
 		if err := reg.Register(cs); err != nil {
 			return toolError(fmt.Sprintf("registration failed: %v", err)), nil
 		}
@@ -373,44 +409,28 @@ func createCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 			provisioner.RunAll(ctx, state.cfg.Provisioners, rctx, target)
 		}
 
+		_ = WriteConnectionStatus(state.cfg.Workspace.Dir, reg)
 		return toolSuccess(fmt.Sprintf("Created and connected codespace %q (alias: %s)\nRepository: %s\nWorkdir: %s",
-			csName, alias, repo, workdir)), nil
+			csName, alias, repo, workdir) + connectedMachineNote(cs) + connectedClockNote(cs) + connectedGitNote(cs, true)), nil
 	}
 }
 
-// detectCSWorkdir finds the workspace directory on a codespace.
-func detectCSWorkdir(ctx context.Context, client *ssh.Client, repo string) string {
-	stdout, _, _, err := client.Exec(ctx, "ls -d /workspaces/*/ 2>/dev/null")
+// detectCSWorkdir finds the workspace directory on a codespace, choosing
+// like the launcher does. With no one to ask, several candidates leave the
+// workdir at /workspaces for the agent to change with remote_cd.
+func detectCSWorkdir(ctx context.Context, ex ssh.Executor, repo string) string {
+	stdout, _, _, err := ex.RunBash(ctx, codespaceenv.WorkspaceDirsCommand, "")
 	if err != nil {
 		return "/workspaces"
 	}
-
-	var dirs []string
-	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
-		d := strings.TrimRight(strings.TrimSpace(line), "/")
-		if d != "" {
-			dirs = append(dirs, d)
-		}
-	}
-
-	if len(dirs) == 0 {
-		return "/workspaces"
-	}
-	if len(dirs) == 1 {
-		return dirs[0]
-	}
-
-	// Try matching repo name
 	repoName := repo
 	if i := strings.LastIndex(repo, "/"); i >= 0 {
 		repoName = repo[i+1:]
 	}
-	for _, d := range dirs {
-		if strings.HasSuffix(d, "/"+repoName) || d == "/workspaces/"+repoName {
-			return d
-		}
+	if chosen := codespaceenv.ChooseWorkdir(codespaceenv.ParseWorkspaceDirs(stdout), repoName); chosen != "" {
+		return chosen
 	}
-	return dirs[0]
+	return "/workspaces"
 }
 
 // --- connect_codespace ---
@@ -418,6 +438,7 @@ func detectCSWorkdir(ctx context.Context, client *ssh.Client, repo string) strin
 func connectCodespaceTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "connect_codespace",
+		Annotations: mutatingHints("Connect codespace", false, true, true),
 		Description: "Connect to an existing GitHub Codespace that is not yet in the current session. May take 30-60 seconds for SSH setup and exec agent deployment.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -447,11 +468,11 @@ func connectCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSt
 			return toolError(err.Error()), nil
 		}
 		if existing := reg.FindByName(csName); existing != nil {
-			return toolError(fmt.Sprintf("codespace %q already connected as alias %q", csName, existing.Alias)), nil
+			return categorizedError(errConflict, fmt.Sprintf("codespace %q already connected as alias %q", csName, existing.Alias)), nil
 		}
 		policy := state.accessPolicy()
 		if !policy.allowsExistingCodespace(csName) {
-			return toolError(policy.deniedConnectMessage(csName)), nil
+			return categorizedError(errPolicyDenied, policy.deniedConnectMessage(csName)), nil
 		}
 		alias := optionalString(req, "alias")
 		if alias == "" {
@@ -460,16 +481,19 @@ func connectCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSt
 
 		// Check alias isn't already taken
 		if _, err := reg.Resolve(alias); err == nil {
-			return toolError(fmt.Sprintf("alias %q already in use", alias)), nil
+			return categorizedError(errConflict, fmt.Sprintf("alias %q already in use", alias)), nil
 		}
 
 		// Look up the codespace to get its repository
-		repoInfo := lookupCSRepository(csName)
+		repoInfo, repoErr := lookupCSRepository(csName)
+		if repoErr != nil {
+			fmt.Fprintf(os.Stderr, "  ⚠ repository lookup failed for %s: %v\n", csName, repoErr)
+		}
 
 		// Setup SSH
 		sshClient := ssh.NewClient(csName)
 		if err := sshClient.SetupMultiplexing(ctx); err != nil {
-			return toolError(fmt.Sprintf("SSH setup failed: %v", err)), nil
+			return categorizedError(errConnection, fmt.Sprintf("SSH setup failed: %v", err)), nil
 		}
 
 		// Deploy exec agent binary
@@ -484,6 +508,7 @@ func connectCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSt
 		}
 
 		workdir := detectCSWorkdir(ctx, sshClient, repoInfo)
+		sshClient.DetectRemoteUser(ctx, workdir)
 
 		cs := &registry.ManagedCodespace{
 			Alias:      alias,
@@ -493,6 +518,11 @@ func connectCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSt
 			Executor:   sshClient,
 			ExecAgent:  execAgent,
 		}
+		cs.CPUs, cs.MemoryBytes = ProbeMachine(ctx, sshClient)
+		cs.TimeZone, cs.UTCOffset, cs.ClockOffset = ProbeClock(ctx, sshClient)
+		cs.Remotes, cs.DefaultBranch = ProbeGit(ctx, sshClient, workdir)
+		_, cs.DevcontainerHooks, _ = ProbeDevcontainerHooks(ctx, sshClient, workdir)
+		enableRecording(cs, state.cfg.RecordingDir)
 		if err := reg.Register(cs); err != nil {
 			return toolError(fmt.Sprintf("registration failed: %v", err)), nil
 		}
@@ -519,28 +549,37 @@ func connectCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSt
 			provisioner.RunAll(ctx, state.cfg.Provisioners, rctx, target)
 		}
 
-		return toolSuccess(fmt.Sprintf("Connected to codespace %q (alias: %s)\nWorkdir: %s", csName, alias, workdir)), nil
+		_ = WriteConnectionStatus(state.cfg.Workspace.Dir, reg)
+		return toolSuccess(fmt.Sprintf("Connected to codespace %q (alias: %s)\nWorkdir: %s", csName, alias, workdir) + connectedMachineNote(cs) + connectedClockNote(cs) + connectedGitNote(cs, repoErr == nil)), nil
 	}
 }
 
 // lookupCSRepository fetches the repository name for a codespace via gh CLI.
-func lookupCSRepository(csName string) string {
+// It returns "" without an error when the codespace has no repository.
+func lookupCSRepository(csName string) (string, error) {
 	out, err := exec.Command("gh", "codespace", "list",
-		"--json", "name,repository", "--limit", "50").Output()
+		"--json", "name,repository", "--limit", codespaceListLimit).Output()
 	if err != nil {
-		return ""
+		return "", fmt.Errorf("listing codespaces: %w", err)
 	}
+	return parseCSRepository(out, csName)
+}
+
+// parseCSRepository finds csName's repository in gh codespace list JSON.
+func parseCSRepository(out []byte, csName string) (string, error) {
 	var csList []struct {
 		Name       string `json:"name"`
 		Repository string `json:"repository"`
 	}
-	json.Unmarshal(out, &csList)
+	if err := json.Unmarshal(out, &csList); err != nil {
+		return "", fmt.Errorf("parsing codespace list: %w", err)
+	}
 	for _, cs := range csList {
 		if cs.Name == csName {
-			return cs.Repository
+			return cs.Repository, nil
 		}
 	}
-	return ""
+	return "", fmt.Errorf("codespace %q not found in codespace list", csName)
 }
 
 // --- delete_codespace ---
@@ -548,6 +587,7 @@ func lookupCSRepository(csName string) string {
 func deleteCodespaceTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "delete_codespace",
+		Annotations: mutatingHints("Delete codespace", true, true, true),
 		Description: "Disconnect and optionally delete a codespace from the current session.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -600,6 +640,7 @@ func deleteCodespaceHandlerWithState(reg *registry.Registry, state *lifecycleSta
 		}
 
 		reg.Deregister(alias)
+		_ = WriteConnectionStatus(state.cfg.Workspace.Dir, reg)
 
 		if shouldDelete {
 			if _, err := state.cfg.GHRunner.Run(ctx, "codespace", "delete", "-c", csName, "--force"); err != nil {
@@ -678,6 +719,7 @@ func extractURL(s string) string {
 func getCodespaceOptionsTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "get_codespace_options",
+		Annotations: readOnlyHints("Get codespace creation options", true),
 		Description: "Get available machine types and devcontainer configurations for a repository. Use this before create_codespace to see what options are available.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
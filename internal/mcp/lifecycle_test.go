@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
 	"github.com/ekroon/gh-copilot-codespace/internal/registry"
 	"github.com/ekroon/gh-copilot-codespace/internal/workspace"
 	mcpsdk "github.com/mark3labs/mcp-go/mcp"
@@ -100,8 +101,8 @@ EOF
     done
 
     case "$cmd" in
-      *"ls -d /workspaces/*/ 2>/dev/null"*)
-        printf '%s\n' "${FAKE_GH_WORKDIR:-/workspaces/repo/}"
+      *"for d in /workspaces/*/"*)
+        printf 'git %s\n' "${FAKE_GH_WORKDIR:-/workspaces/repo/}"
         ;;
       *)
         printf '%s\n' "${FAKE_GH_REMOTE_STDOUT:-ok}"
@@ -792,3 +793,38 @@ func TestGetCodespaceOptions_MissingRepo(t *testing.T) {
 		t.Fatal("expected error for missing repository")
 	}
 }
+
+func TestParseCSRepository(t *testing.T) {
+	list := []byte(`[{"name":"cs-app","repository":"acme/app"},{"name":"cs-scratch","repository":""}]`)
+	if repo, err := parseCSRepository(list, "cs-app"); err != nil || repo != "acme/app" {
+		t.Errorf("cs-app = %q, %v; want acme/app", repo, err)
+	}
+	if repo, err := parseCSRepository(list, "cs-scratch"); err != nil || repo != "" {
+		t.Errorf("unpublished = %q, %v; want no repository and no error", repo, err)
+	}
+	if _, err := parseCSRepository(list, "cs-gone"); err == nil {
+		t.Error("missing codespace: want an error")
+	}
+	if _, err := parseCSRepository([]byte("not json"), "cs-app"); err == nil {
+		t.Error("invalid JSON: want an error")
+	}
+}
+
+func TestDetectCSWorkdir(t *testing.T) {
+	for _, tc := range []struct {
+		name, out, want string
+	}{
+		{"repo checkout beside dotfiles", "git /workspaces/dotfiles/\ngit /workspaces/app/\n", "/workspaces/app"},
+		{"match without .git is not taken", "- /workspaces/app/\ngit /workspaces/dotfiles/\ngit /workspaces/other/\n", "/workspaces"},
+		{"only checkout", "- /workspaces/tmp/\ngit /workspaces/renamed/\n", "/workspaces/renamed"},
+		{"no directories", "", "/workspaces"},
+	} {
+		mock := &mockExecutor{runBashStdout: tc.out}
+		if got := detectCSWorkdir(context.Background(), mock, "acme/app"); got != tc.want {
+			t.Errorf("%s: workdir = %q, want %q", tc.name, got, tc.want)
+		}
+		if mock.lastRunBashCommand != codespaceenv.WorkspaceDirsCommand {
+			t.Errorf("%s: command = %q, want the launcher's workspace listing", tc.name, mock.lastRunBashCommand)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// machineProbeCommand prints the CPU count, then total memory in bytes.
+const machineProbeCommand = `nproc 2>/dev/null; awk '/^MemTotal:/ {printf "%d\n", $2 * 1024}' /proc/meminfo 2>/dev/null`
+
+// SmallMachineCPUs is the core count at or below which heavy builds get a warning.
+const SmallMachineCPUs = 2
+
+// heavyBuildRe matches commands that typically saturate every core for
+// minutes, in command position (start of line or after ;, &&, ||, |, or an
+// opening parenthesis, with optional env assignments).
+var heavyBuildRe = regexp.MustCompile(`(?m)(?:^|[;&|(])\s*(?:\w+=\S*\s+)*(?:time\s+)?(make|ninja|bazel|gradlew?|\./gradlew|mvn|cargo (build|test)|go (build|test)|docker (build|compose build)|(npm|yarn|pnpm) (run )?(build|test)|tsc|webpack|script/(bootstrap|build|test|cibuild))($|[;&|)\s])`)
+
+// ProbeMachine reports the codespace's CPU count and total memory. Zero values
+// mean the probe failed; callers treat the machine size as unknown.
+func ProbeMachine(ctx context.Context, ex ssh.Executor) (cpus int, memoryBytes int64) {
+	stdout, _, exitCode, err := ex.RunBash(ctx, machineProbeCommand, "")
+	if err != nil || exitCode != 0 {
+		return 0, 0
+	}
+	return parseMachineProbe(stdout)
+}
+
+func parseMachineProbe(out string) (cpus int, memoryBytes int64) {
+	fields := strings.Fields(out)
+	if len(fields) > 0 {
+		cpus, _ = strconv.Atoi(fields[0])
+	}
+	if len(fields) > 1 {
+		memoryBytes, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	return max(cpus, 0), max(memoryBytes, 0)
+}
+
+// MachineSummary renders a probed machine size, e.g. "4 cores, 16 GB RAM".
+// It returns "" when the size is unknown.
+func MachineSummary(cpus int, memoryBytes int64) string {
+	if cpus <= 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("%d cores", cpus)
+	if memoryBytes > 0 {
+		summary += fmt.Sprintf(", %d GB RAM", (memoryBytes+(1<<29))>>30)
+	}
+	return summary
+}
+
+// MachineInstructions returns instruction context that sizes build and test
+// concurrency to the codespace, or "" when the machine size is unknown.
+func MachineInstructions(cpus int, memoryBytes int64) string {
+	if cpus <= 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("## Codespace machine\n\n")
+	fmt.Fprintf(&sb, "The codespace has %s. Size parallel work to match:\n", MachineSummary(cpus, memoryBytes))
+	fmt.Fprintf(&sb, "- Build and test concurrency: `make -j%d`, `go test -p %d`, `pytest -n %d`, Jest `--maxWorkers=%d`\n", cpus, cpus, cpus, cpus)
+	if cpus <= SmallMachineCPUs {
+		sb.WriteString("- This is a small machine: prefer targeted builds and tests (one package or file) over full-repository runs, and don't start several heavy commands at once\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// connectedMachineNote appends the machine size to a connect result, with the
+// concurrency guidance the launcher would otherwise put in the instructions.
+func connectedMachineNote(cs *registry.ManagedCodespace) string {
+	if cs.CPUs <= 0 {
+		return ""
+	}
+	return "\n\n" + strings.TrimSpace(MachineInstructions(cs.CPUs, cs.MemoryBytes))
+}
+
+// heavyBuildWarning returns a note for heavy build commands on small machines.
+func heavyBuildWarning(cs *registry.ManagedCodespace, command string) string {
+	if cs.CPUs <= 0 || cs.CPUs > SmallMachineCPUs || !heavyBuildRe.MatchString(command) {
+		return ""
+	}
+	return fmt.Sprintf("[warning: heavy build on a %d-core codespace; expect it to be slow. Prefer targeted builds/tests, or recreate the codespace with a larger machine type]", cs.CPUs)
+}
+
+// withHeavyBuildWarning prepends heavyBuildWarning to remote_bash results.
+func withHeavyBuildWarning(reg *registry.Registry, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		result, err := next(ctx, req)
+		if err != nil || result == nil {
+			return result, err
+		}
+		cs, rerr := resolveCodespace(reg, req)
+		if rerr != nil {
+			return result, nil
+		}
+		command := optionalString(req, "command")
+		if steps, ok, _ := bashSteps(req); ok {
+			command = strings.Join(steps, " && ")
+		}
+		if note := heavyBuildWarning(cs, command); note != "" {
+			prependNote(result, note)
+		}
+		return result, nil
+	}
+}
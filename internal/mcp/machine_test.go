@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestParseMachineProbe(t *testing.T) {
+	tests := []struct {
+		out      string
+		wantCPUs int
+		wantMem  int64
+	}{
+		{"4\n16777216000\n", 4, 16777216000},
+		{"2\n", 2, 0},
+		{"", 0, 0},
+		{"garbage\n", 0, 0},
+	}
+	for _, tt := range tests {
+		cpus, mem := parseMachineProbe(tt.out)
+		if cpus != tt.wantCPUs || mem != tt.wantMem {
+			t.Errorf("parseMachineProbe(%q) = %d, %d; want %d, %d", tt.out, cpus, mem, tt.wantCPUs, tt.wantMem)
+		}
+	}
+}
+
+func TestProbeMachine(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "8\n34359738368\n"}
+	cpus, mem := ProbeMachine(context.Background(), mock)
+	if cpus != 8 || mem != 34359738368 {
+		t.Errorf("ProbeMachine = %d, %d; want 8, 32 GiB", cpus, mem)
+	}
+	if got := MachineSummary(cpus, mem); got != "8 cores, 32 GB RAM" {
+		t.Errorf("MachineSummary = %q", got)
+	}
+}
+
+func TestMachineInstructions(t *testing.T) {
+	if got := MachineInstructions(0, 0); got != "" {
+		t.Errorf("unknown machine should produce no instructions, got %q", got)
+	}
+	big := MachineInstructions(16, 64<<30)
+	if !strings.Contains(big, "`make -j16`") || strings.Contains(big, "small machine") {
+		t.Errorf("unexpected 16-core instructions:\n%s", big)
+	}
+	small := MachineInstructions(2, 8<<30)
+	if !strings.Contains(small, "`go test -p 2`") || !strings.Contains(small, "small machine") {
+		t.Errorf("unexpected 2-core instructions:\n%s", small)
+	}
+}
+
+func TestHeavyBuildWarning(t *testing.T) {
+	small := &registry.ManagedCodespace{CPUs: 2}
+	for _, cmd := range []string{"make", "make -j2 all", "go test ./...", "cd web && npm run build", "cargo build --release", "docker build ."} {
+		if heavyBuildWarning(small, cmd) == "" {
+			t.Errorf("expected warning for %q on 2 cores", cmd)
+		}
+	}
+	for _, cmd := range []string{"git status", "ls make", "cat Makefile", "go version"} {
+		if got := heavyBuildWarning(small, cmd); got != "" {
+			t.Errorf("unexpected warning for %q: %s", cmd, got)
+		}
+	}
+	if heavyBuildWarning(&registry.ManagedCodespace{CPUs: 8}, "make") != "" {
+		t.Error("no warning expected on an 8-core machine")
+	}
+	if heavyBuildWarning(&registry.ManagedCodespace{}, "make") != "" {
+		t.Error("no warning expected when the machine size is unknown")
+	}
+}
+
+func TestWithHeavyBuildWarning(t *testing.T) {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs", CPUs: 2, Executor: &mockExecutor{}})
+	inner := func(_ context.Context, _ mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("ok"), nil
+	}
+	result, _ := withHeavyBuildWarning(reg, inner)(context.Background(), makeReq(map[string]any{"command": "make test"}))
+	if text := resultText(result); !strings.HasPrefix(text, "[warning: heavy build on a 2-core codespace") || !strings.HasSuffix(text, "\nok") {
+		t.Errorf("result = %q, want warning before output", text)
+	}
+}
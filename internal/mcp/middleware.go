@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Middleware runs around every tool call. Forks can add their own through
+// LifecycleConfig.Middlewares instead of editing each handler.
+type Middleware = server.ToolHandlerMiddleware
+
+// durationMetaKey is the _meta field holding how long a tool call took.
+const durationMetaKey = "durationMs"
+
+// toolMiddlewares returns the chain NewServer installs, outermost first:
+// audit, logging, timing, policy, redaction, the read-only remote_bash cache,
+// file prefetching for remote_view, waking suspended codespaces, and finally
+// cfg.Middlewares. Custom middlewares therefore only see calls that
+// passed policy, and their output is still redacted, timed, and audited.
+func toolMiddlewares(reg *registry.Registry, cfg LifecycleConfig) []Middleware {
+	var chain []Middleware
+	if cfg.Audit != nil {
+		chain = append(chain, auditMiddleware(reg, cfg.Audit, cfg.Workspace.Name))
+	}
+	if cfg.ToolLog != nil {
+		chain = append(chain, loggingMiddleware(cfg.ToolLog))
+	}
+	chain = append(chain, timingMiddleware())
+	if len(cfg.DeniedTools) > 0 {
+		chain = append(chain, policyMiddleware(cfg.DeniedTools))
+	}
+	chain = append(chain, redactionMiddleware())
+	chain = append(chain, bashCacheMiddleware(reg, newBashCache()))
+	chain = append(chain, prefetchMiddleware(reg, cfg.ConfineToWorkdir, newViewPrefetcher()))
+	chain = append(chain, wakeMiddleware(reg, newCodespaceWaker(cfg.GHRunner, !cfg.NoAutoStart)))
+	return append(chain, cfg.Middlewares...)
+}
+
+// loggingMiddleware writes one line per tool call to w with its outcome and
+// duration.
+func loggingMiddleware(w io.Writer) Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, req)
+			outcome := "ok"
+			switch {
+			case err != nil:
+				outcome = "error: " + err.Error()
+			case result != nil && result.IsError:
+				outcome = "error"
+				if result.Meta != nil {
+					if category, ok := result.Meta.AdditionalFields[errorCategoryMetaKey].(string); ok {
+						outcome = "error:" + category
+					}
+				}
+			}
+			fmt.Fprintf(w, "codespace-mcp: %s %s (%dms)\n", req.Params.Name, outcome, time.Since(start).Milliseconds())
+			return result, err
+		}
+	}
+}
+
+// timingMiddleware records each call's duration in the result's _meta.
+func timingMiddleware() Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, req)
+			if result != nil {
+				if result.Meta == nil {
+					result.Meta = &mcpsdk.Meta{}
+				}
+				if result.Meta.AdditionalFields == nil {
+					result.Meta.AdditionalFields = make(map[string]any)
+				}
+				result.Meta.AdditionalFields[durationMetaKey] = time.Since(start).Milliseconds()
+			}
+			return result, err
+		}
+	}
+}
+
+// policyMiddleware refuses calls to denied tools before they reach the
+// codespace. Denied tools stay advertised so the refusal explains itself.
+func policyMiddleware(denied []string) Middleware {
+	deny := make(map[string]bool, len(denied))
+	for _, name := range denied {
+		deny[name] = true
+	}
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			if deny[req.Params.Name] {
+				return categorizedError(errPolicyDenied, fmt.Sprintf("%s is denied by policy in this session", req.Params.Name)), nil
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// secretTokenRe matches GitHub tokens, which show up in output such as env or
+// git remote -v run on the codespace.
+var secretTokenRe = regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)
+
+// redactedToken replaces secretTokenRe matches.
+const redactedToken = "[REDACTED]"
+
+// redactionMiddleware removes GitHub tokens from text results before they
+// reach the model.
+func redactionMiddleware() Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			result, err := next(ctx, req)
+			if result == nil {
+				return result, err
+			}
+			for i, c := range result.Content {
+				if text, ok := c.(mcpsdk.TextContent); ok && secretTokenRe.MatchString(text.Text) {
+					text.Text = secretTokenRe.ReplaceAllString(text.Text, redactedToken)
+					result.Content[i] = text
+				}
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// applyChain wraps h with mws the way the server does: the first is outermost.
+func applyChain(mws []Middleware, h server.ToolHandlerFunc) server.ToolHandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+func TestToolMiddlewaresChain(t *testing.T) {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: &mockExecutor{}})
+	var log bytes.Buffer
+	rec := &recordingAuditor{}
+	var custom []string
+	cfg := LifecycleConfig{
+		GHRunner:    &wakeGHRunner{},
+		Audit:       rec,
+		ToolLog:     &log,
+		DeniedTools: []string{"remote_chmod"},
+		Middlewares: []Middleware{func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+			return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+				custom = append(custom, req.Params.Name)
+				return next(ctx, req)
+			}
+		}},
+	}
+	handler := applyChain(toolMiddlewares(reg, cfg), func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("GITHUB_TOKEN=ghs_" + strings.Repeat("a", 36)), nil
+	})
+
+	result, _ := handler(context.Background(), namedReq("remote_bash", map[string]any{"command": "env"}))
+	if got := resultText(result); got != "GITHUB_TOKEN=[REDACTED]" {
+		t.Errorf("result = %q, want the token redacted", got)
+	}
+	if _, ok := result.Meta.AdditionalFields[durationMetaKey].(int64); !ok {
+		t.Errorf("meta = %v, want %s", result.Meta.AdditionalFields, durationMetaKey)
+	}
+
+	result, _ = handler(context.Background(), namedReq("remote_chmod", map[string]any{"path": "x", "mode": "755"}))
+	if !result.IsError || !strings.HasPrefix(resultText(result), "[error:policy_denied] remote_chmod is denied") {
+		t.Errorf("denied result = %q", resultText(result))
+	}
+
+	if len(custom) != 1 || custom[0] != "remote_bash" {
+		t.Errorf("custom middleware saw %v, want only the allowed call", custom)
+	}
+	if len(rec.events) != 2 || rec.events[1].ErrorCategory != string(errPolicyDenied) {
+		t.Errorf("audit events = %+v", rec.events)
+	}
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "codespace-mcp: remote_bash ok (") || !strings.HasPrefix(lines[1], "codespace-mcp: remote_chmod error:policy_denied (") {
+		t.Errorf("log = %q", log.String())
+	}
+}
+
+func TestRedactionMiddlewareLeavesOtherTextAlone(t *testing.T) {
+	text := "ghp_short and github_pat_ are not tokens"
+	result, _ := redactionMiddleware()(func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess(text), nil
+	})(context.Background(), makeReq(nil))
+	if resultText(result) != text {
+		t.Errorf("result = %q", resultText(result))
+	}
+
+	token := "github_pat_" + strings.Repeat("Ab1_", 20)
+	result, _ = redactionMiddleware()(func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolError("push failed for https://x:" + token + "@github.com/o/r"), nil
+	})(context.Background(), makeReq(nil))
+	if strings.Contains(resultText(result), token) || !strings.Contains(resultText(result), "x:[REDACTED]@github.com") {
+		t.Errorf("result = %q", resultText(result))
+	}
+}
@@ -0,0 +1,129 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// userHomeDir is swapped out in tests.
+var userHomeDir = os.UserHomeDir
+
+// mirrorDir returns the local instruction mirror the launcher creates for a
+// codespace. Copilot runs there, so it sometimes leaks these paths into
+// remote tool calls.
+func mirrorDir(codespaceName string) string {
+	home, err := userHomeDir()
+	if err != nil || home == "" || codespaceName == "" {
+		return ""
+	}
+	return filepath.Join(ssh.WorkdirsDir(home), codespaceName)
+}
+
+// mirrorTranslator rewrites local mirror paths to the codespace workdir. Both
+// the absolute and ~-relative spellings of the mirror are matched.
+type mirrorTranslator struct {
+	workdir string
+	re      *regexp.Regexp
+}
+
+func newMirrorTranslator(cs *registry.ManagedCodespace) *mirrorTranslator {
+	mirror := mirrorDir(cs.Name)
+	if mirror == "" || cs.Workdir == "" {
+		return nil
+	}
+	forms := []string{regexp.QuoteMeta(mirror)}
+	if home, err := userHomeDir(); err == nil && strings.HasPrefix(mirror, home+"/") {
+		forms = append(forms, regexp.QuoteMeta("~"+strings.TrimPrefix(mirror, home)))
+	}
+	// The trailing group stops "cs" from matching a sibling mirror like "cs-2".
+	const boundary = `([^A-Za-z0-9_.-]|$)`
+	return &mirrorTranslator{
+		workdir: path.Clean(cs.Workdir),
+		re:      regexp.MustCompile(`(?:` + strings.Join(forms, "|") + `)` + boundary),
+	}
+}
+
+// toRemote replaces mirror prefixes in s with the workdir.
+func (t *mirrorTranslator) toRemote(s string) (string, bool) {
+	if t == nil || !t.re.MatchString(s) {
+		return s, false
+	}
+	return t.re.ReplaceAllString(s, t.workdir+"$1"), true
+}
+
+// withMirrorPaths wraps a remote tool handler so string arguments named in keys
+// that refer to the local mirror are rewritten to the codespace workdir before
+// the handler runs. Each rewrite is noted at the top of the result.
+func withMirrorPaths(reg *registry.Registry, next server.ToolHandlerFunc, keys ...string) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return next(ctx, req)
+		}
+		t := newMirrorTranslator(cs)
+		args := req.GetArguments()
+		if t == nil || args == nil {
+			return next(ctx, req)
+		}
+
+		var notes []string
+		translate := func(name, v string) string {
+			translated, changed := t.toRemote(v)
+			if changed {
+				notes = append(notes, fmt.Sprintf("[%s translated from local mirror: %s -> %s]", name, v, translated))
+			}
+			return translated
+		}
+		for _, key := range keys {
+			switch v := args[key].(type) {
+			case string:
+				args[key] = translate(key, v)
+			case []any:
+				// Arrays of paths, or of objects with a "path" field.
+				for i, item := range v {
+					switch item := item.(type) {
+					case string:
+						v[i] = translate(fmt.Sprintf("%s[%d]", key, i), item)
+					case map[string]any:
+						if p, ok := item["path"].(string); ok {
+							item["path"] = translate(fmt.Sprintf("%s[%d].path", key, i), p)
+						}
+					}
+				}
+			}
+		}
+
+		result, err := next(ctx, req)
+		if err != nil || result == nil || len(notes) == 0 {
+			return result, err
+		}
+		prependNote(result, strings.Join(notes, "\n"))
+		return result, nil
+	}
+}
+
+// prependNote adds note as a leading line of the result's first text content.
+func prependNote(result *mcpsdk.CallToolResult, note string) {
+	for i, c := range result.Content {
+		if tc, ok := c.(mcpsdk.TextContent); ok {
+			// Keep the error category prefix first so callers can still match on it.
+			if prefix := errorPrefixRe.FindString(tc.Text); prefix != "" {
+				tc.Text = prefix + note + "\n" + strings.TrimPrefix(tc.Text, prefix)
+			} else {
+				tc.Text = note + "\n" + tc.Text
+			}
+			result.Content[i] = tc
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+func stubHomeDir(t *testing.T, home string) {
+	t.Helper()
+	orig := userHomeDir
+	userHomeDir = func() (string, error) { return home, nil }
+	t.Cleanup(func() { userHomeDir = orig })
+}
+
+func TestMirrorTranslator(t *testing.T) {
+	stubHomeDir(t, "/home/me")
+	tr := newMirrorTranslator(&registry.ManagedCodespace{Name: "cs", Workdir: "/workspaces/repo"})
+
+	tests := []struct {
+		in      string
+		want    string
+		changed bool
+	}{
+		{"/home/me/.copilot/codespace-workdirs/cs/src/main.go", "/workspaces/repo/src/main.go", true},
+		{"~/.copilot/codespace-workdirs/cs/README.md", "/workspaces/repo/README.md", true},
+		{"/home/me/.copilot/codespace-workdirs/cs", "/workspaces/repo", true},
+		{"cat /home/me/.copilot/codespace-workdirs/cs/a.txt ~/.copilot/codespace-workdirs/cs/b.txt", "cat /workspaces/repo/a.txt /workspaces/repo/b.txt", true},
+		{"/home/me/.copilot/codespace-workdirs/cs-2/a.txt", "/home/me/.copilot/codespace-workdirs/cs-2/a.txt", false},
+		{"/workspaces/repo/src", "/workspaces/repo/src", false},
+		{"src/main.go", "src/main.go", false},
+	}
+	for _, tt := range tests {
+		got, changed := tr.toRemote(tt.in)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("toRemote(%q) = %q, %v; want %q, %v", tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestMirrorTranslator_NoWorkdir(t *testing.T) {
+	stubHomeDir(t, "/home/me")
+	if tr := newMirrorTranslator(&registry.ManagedCodespace{Name: "cs"}); tr != nil {
+		t.Fatal("expected no translator without a workdir")
+	}
+}
+
+func TestWithMirrorPaths(t *testing.T) {
+	stubHomeDir(t, "/home/me")
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "test", Name: "cs", Workdir: "/workspaces/repo", Executor: &mockExecutor{}})
+
+	var gotPath string
+	inner := func(_ context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		gotPath = optionalString(req, "path")
+		return toolSuccess("contents"), nil
+	}
+	handler := withMirrorPaths(reg, inner, "path")
+
+	result, err := handler(context.Background(), makeReq(map[string]any{"path": "~/.copilot/codespace-workdirs/cs/main.go"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPath != "/workspaces/repo/main.go" {
+		t.Errorf("handler saw path %q, want translated workdir path", gotPath)
+	}
+	text := resultText(result)
+	if !strings.HasPrefix(text, "[path translated from local mirror: ~/.copilot/codespace-workdirs/cs/main.go -> /workspaces/repo/main.go]\ncontents") {
+		t.Errorf("result = %q, want translation note before contents", text)
+	}
+
+	result, _ = handler(context.Background(), makeReq(map[string]any{"path": "/workspaces/repo/main.go"}))
+	if gotPath != "/workspaces/repo/main.go" || resultText(result) != "contents" {
+		t.Errorf("workdir path should pass through untouched, got path %q result %q", gotPath, resultText(result))
+	}
+}
+
+func TestWithMirrorPaths_KeepsErrorPrefixFirst(t *testing.T) {
+	stubHomeDir(t, "/home/me")
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "test", Name: "cs", Workdir: "/workspaces/repo", Executor: &mockExecutor{}})
+
+	inner := func(_ context.Context, _ mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return categorizedError(errNotFound, "file not found"), nil
+	}
+	result, _ := withMirrorPaths(reg, inner, "path")(context.Background(), makeReq(map[string]any{"path": "/home/me/.copilot/codespace-workdirs/cs/x"}))
+	if text := resultText(result); !strings.HasPrefix(text, "[error:not_found] [path translated") {
+		t.Errorf("result = %q, want error prefix first", text)
+	}
+}
+
+func TestWithMirrorPaths_Arrays(t *testing.T) {
+	stubHomeDir(t, "/home/me")
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "test", Name: "cs", Workdir: "/workspaces/repo", Executor: &mockExecutor{}})
+
+	var gotFiles []any
+	inner := func(_ context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		gotFiles, _ = req.GetArguments()["files"].([]any)
+		return toolSuccess("contents"), nil
+	}
+	args := map[string]any{"files": []any{
+		map[string]any{"path": "/home/me/.copilot/codespace-workdirs/cs/a.go"},
+		"~/.copilot/codespace-workdirs/cs/b.go",
+		map[string]any{"path": "c.go"},
+	}}
+	result, _ := withMirrorPaths(reg, inner, "files")(context.Background(), makeReq(args))
+
+	if p := gotFiles[0].(map[string]any)["path"]; p != "/workspaces/repo/a.go" {
+		t.Errorf("files[0].path = %v, want translated", p)
+	}
+	if gotFiles[1] != "/workspaces/repo/b.go" {
+		t.Errorf("files[1] = %v, want translated", gotFiles[1])
+	}
+	if p := gotFiles[2].(map[string]any)["path"]; p != "c.go" {
+		t.Errorf("files[2].path = %v, want untouched", p)
+	}
+	text := resultText(result)
+	if !strings.Contains(text, "[files[0].path translated from local mirror:") || !strings.Contains(text, "[files[1] translated from local mirror:") {
+		t.Errorf("result = %q, want a note per translated item", text)
+	}
+}
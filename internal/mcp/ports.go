@@ -0,0 +1,287 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// portForwardReadyTimeout bounds how long forward waits for the local port to
+// accept connections.
+const portForwardReadyTimeout = 15 * time.Second
+
+// codespacePort is one entry of gh codespace ports --json.
+type codespacePort struct {
+	SourcePort int    `json:"sourcePort"`
+	Label      string `json:"label"`
+	Visibility string `json:"visibility"`
+	BrowseURL  string `json:"browseUrl"`
+}
+
+// portForward is a running gh codespace ports forward process.
+type portForward struct {
+	localPort int
+	cmd       *exec.Cmd
+	stderr    *bytes.Buffer
+	exited    chan struct{}
+}
+
+// PortForwards tracks the local forwards started by remote_ports, keyed by
+// codespace name and remote port, so they can be listed and stopped. The
+// forwarding processes outlive tool calls; StopAll ends them at exit.
+type PortForwards struct {
+	mu       sync.Mutex
+	forwards map[string]*portForward
+	// command builds the forwarding process; tests replace it.
+	command func(codespaceName string, remotePort, localPort int) *exec.Cmd
+}
+
+// NewPortForwards returns an empty tracker that starts gh codespace ports
+// forward processes.
+func NewPortForwards() *PortForwards {
+	return &PortForwards{
+		forwards: make(map[string]*portForward),
+		command: func(codespaceName string, remotePort, localPort int) *exec.Cmd {
+			return exec.Command("gh", "codespace", "ports", "forward", fmt.Sprintf("%d:%d", remotePort, localPort), "-c", codespaceName)
+		},
+	}
+}
+
+func portForwardKey(codespaceName string, remotePort int) string {
+	return codespaceName + ":" + strconv.Itoa(remotePort)
+}
+
+// local returns the local port remotePort is forwarded to, or 0.
+func (p *PortForwards) local(codespaceName string, remotePort int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f := p.forwards[portForwardKey(codespaceName, remotePort)]; f != nil && !f.hasExited() {
+		return f.localPort
+	}
+	return 0
+}
+
+// start launches a forward and waits until localPort accepts connections.
+// The process outlives the tool call; stop ends it.
+func (p *PortForwards) start(ctx context.Context, codespaceName string, remotePort, localPort int) error {
+	key := portForwardKey(codespaceName, remotePort)
+	p.mu.Lock()
+	if f := p.forwards[key]; f != nil && !f.hasExited() {
+		p.mu.Unlock()
+		return fmt.Errorf("port %d is already forwarded to localhost:%d", remotePort, f.localPort)
+	}
+	f := &portForward{localPort: localPort, cmd: p.command(codespaceName, remotePort, localPort), stderr: &bytes.Buffer{}, exited: make(chan struct{})}
+	f.cmd.Stderr = f.stderr
+	if err := f.cmd.Start(); err != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("starting port forward: %w", err)
+	}
+	p.forwards[key] = f
+	p.mu.Unlock()
+
+	go func() {
+		f.cmd.Wait()
+		close(f.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, portForwardReadyTimeout)
+	defer cancel()
+	for {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)), time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-f.exited:
+			return fmt.Errorf("port forward exited: %s", strings.TrimSpace(f.stderr.String()))
+		case <-ctx.Done():
+			p.stop(codespaceName, remotePort)
+			return fmt.Errorf("localhost:%d did not accept connections within %s", localPort, portForwardReadyTimeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// stop ends the forward of remotePort, reporting whether one was running.
+func (p *PortForwards) stop(codespaceName string, remotePort int) bool {
+	key := portForwardKey(codespaceName, remotePort)
+	p.mu.Lock()
+	f := p.forwards[key]
+	delete(p.forwards, key)
+	p.mu.Unlock()
+	if f == nil || f.hasExited() {
+		return false
+	}
+	f.cmd.Process.Kill()
+	<-f.exited
+	return true
+}
+
+// StopAll ends every running forward.
+func (p *PortForwards) StopAll() {
+	p.mu.Lock()
+	forwards := p.forwards
+	p.forwards = make(map[string]*portForward)
+	p.mu.Unlock()
+	for _, f := range forwards {
+		if !f.hasExited() {
+			f.cmd.Process.Kill()
+			<-f.exited
+		}
+	}
+}
+
+func (f *portForward) hasExited() bool {
+	select {
+	case <-f.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatPorts renders the codespace's ports one per line, sorted by port,
+// noting local forwards started by this session.
+func formatPorts(ports []codespacePort, codespaceName string, forwards *PortForwards) string {
+	if len(ports) == 0 {
+		return "No ports detected on the codespace."
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].SourcePort < ports[j].SourcePort })
+	lines := make([]string, len(ports))
+	for i, p := range ports {
+		line := fmt.Sprintf("%d", p.SourcePort)
+		if p.Label != "" {
+			line += " (" + p.Label + ")"
+		}
+		line += " " + p.Visibility
+		if p.BrowseURL != "" {
+			line += " " + p.BrowseURL
+		}
+		if local := forwards.local(codespaceName, p.SourcePort); local != 0 {
+			line += fmt.Sprintf(" [forwarded to localhost:%d]", local)
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// --- remote_ports ---
+
+func portsTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_ports",
+		Annotations: mutatingHints("Codespace ports", false, true, true),
+		Description: "Work with the codespace's forwarded ports through gh codespace ports. " +
+			"Actions: 'list' (default) shows each detected port with its label, visibility, and browser URL; 'visibility' sets a port to private, org, or public; " +
+			"'forward' forwards a port to localhost on this machine and keeps it open until 'stop_forward'. " +
+			"Making a port public exposes it to anyone with the URL, so only do that when asked.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"action": map[string]any{
+					"type":        "string",
+					"enum":        []string{"list", "visibility", "forward", "stop_forward"},
+					"description": "Operation to perform (default: list)",
+				},
+				"port": map[string]any{
+					"type":        "integer",
+					"description": "Port on the codespace. Required for every action except 'list'.",
+				},
+				"visibility": map[string]any{
+					"type":        "string",
+					"enum":        []string{"private", "org", "public"},
+					"description": "New visibility ('visibility' only)",
+				},
+				"local_port": map[string]any{
+					"type":        "integer",
+					"description": "Local port to forward to ('forward' only, default: the same as port)",
+				},
+			},
+		},
+	}
+}
+
+// portsHandler runs gh codespace ports locally against the codespace.
+// Read-only sessions may list and forward ports but not change visibility.
+func portsHandler(reg *registry.Registry, ghRunner GHRunner, forwards *PortForwards, readOnly bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		action := optionalString(req, "action")
+		switch action {
+		case "":
+			action = "list"
+		case "list", "visibility", "forward", "stop_forward":
+		default:
+			return categorizedError(errInvalidArgument, fmt.Sprintf("unknown action %q: use list, visibility, forward, or stop_forward", action)), nil
+		}
+		var port int
+		if action != "list" {
+			var ok bool
+			port, ok = toInt(req.GetArguments()["port"])
+			if !ok || port < 1 || port > 65535 {
+				return categorizedError(errInvalidArgument, "port must be an integer between 1 and 65535"), nil
+			}
+		}
+
+		switch action {
+		case "list":
+			out, err := ghRunner.Run(ctx, "codespace", "ports", "-c", cs.Name, "--json", "sourcePort,label,visibility,browseUrl")
+			if err != nil {
+				return toolError(fmt.Sprintf("gh codespace ports: %v", err)), nil
+			}
+			var ports []codespacePort
+			if err := json.Unmarshal([]byte(out), &ports); err != nil {
+				return categorizedError(errInternal, fmt.Sprintf("parsing gh codespace ports output: %v", err)), nil
+			}
+			return toolSuccess(formatPorts(ports, cs.Name, forwards)), nil
+		case "visibility":
+			if readOnly {
+				return categorizedError(errPolicyDenied, "changing port visibility is not allowed in this session (read-only)"), nil
+			}
+			visibility := optionalString(req, "visibility")
+			if visibility != "private" && visibility != "org" && visibility != "public" {
+				return categorizedError(errInvalidArgument, "visibility must be private, org, or public"), nil
+			}
+			if _, err := ghRunner.Run(ctx, "codespace", "ports", "visibility", fmt.Sprintf("%d:%s", port, visibility), "-c", cs.Name); err != nil {
+				return toolError(fmt.Sprintf("gh codespace ports visibility: %v", err)), nil
+			}
+			return toolSuccess(fmt.Sprintf("Port %d is now %s.", port, visibility)), nil
+		case "forward":
+			localPort := port
+			if raw, ok := req.GetArguments()["local_port"]; ok {
+				n, isInt := toInt(raw)
+				if !isInt || n < 1 || n > 65535 {
+					return categorizedError(errInvalidArgument, "local_port must be an integer between 1 and 65535"), nil
+				}
+				localPort = n
+			}
+			if err := forwards.start(ctx, cs.Name, port, localPort); err != nil {
+				return toolError(err.Error()), nil
+			}
+			return toolSuccess(fmt.Sprintf("Forwarding codespace port %d to localhost:%d. Use action 'stop_forward' to close it.", port, localPort)), nil
+		case "stop_forward":
+			if !forwards.stop(cs.Name, port) {
+				return categorizedError(errInvalidArgument, fmt.Sprintf("port %d is not forwarded by this session", port)), nil
+			}
+			return toolSuccess(fmt.Sprintf("Stopped forwarding port %d.", port)), nil
+		}
+		return nil, fmt.Errorf("unhandled action %q", action)
+	}
+}
@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPortsHandlerList(t *testing.T) {
+	gh := &mockGHRunner{results: map[string]mockGHResult{
+		"codespace ports": {output: `[{"sourcePort":8080,"label":"","visibility":"private","browseUrl":"https://cs-app-8080.app.github.dev"},` +
+			`{"sourcePort":3000,"label":"web","visibility":"public","browseUrl":"https://cs-app-3000.app.github.dev"}]`},
+	}}
+	res, _ := portsHandler(testReg(&mockExecutor{}), gh, NewPortForwards(), false)(context.Background(), makeReq(nil))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	want := "3000 (web) public https://cs-app-3000.app.github.dev\n8080 private https://cs-app-8080.app.github.dev"
+	if resultText(res) != want {
+		t.Errorf("result = %q, want %q", resultText(res), want)
+	}
+	if got := strings.Join(gh.calls[0], " "); !strings.HasPrefix(got, "codespace ports -c ") || !strings.HasSuffix(got, "--json sourcePort,label,visibility,browseUrl") {
+		t.Errorf("gh args = %q", got)
+	}
+}
+
+func TestPortsHandlerVisibility(t *testing.T) {
+	gh := &mockGHRunner{}
+	res, _ := portsHandler(testReg(&mockExecutor{}), gh, NewPortForwards(), false)(context.Background(),
+		makeReq(map[string]any{"action": "visibility", "port": float64(3000), "visibility": "org"}))
+	if res.IsError || resultText(res) != "Port 3000 is now org." {
+		t.Fatalf("result = %q", resultText(res))
+	}
+	if got := strings.Join(gh.calls[0][:4], " "); got != "codespace ports visibility 3000:org" {
+		t.Errorf("gh args = %q", got)
+	}
+
+	res, _ = portsHandler(testReg(&mockExecutor{}), &mockGHRunner{}, NewPortForwards(), true)(context.Background(),
+		makeReq(map[string]any{"action": "visibility", "port": float64(3000), "visibility": "public"}))
+	if !res.IsError || !strings.HasPrefix(resultText(res), "[error:policy_denied]") {
+		t.Errorf("read-only result = %q", resultText(res))
+	}
+
+	for _, args := range []map[string]any{
+		{"action": "visibility", "port": float64(3000), "visibility": "everyone"},
+		{"action": "visibility", "visibility": "org"},
+		{"action": "forward", "port": float64(70000)},
+		{"action": "open"},
+	} {
+		res, _ := portsHandler(testReg(&mockExecutor{}), &mockGHRunner{}, NewPortForwards(), false)(context.Background(), makeReq(args))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("%v: result = %q", args, resultText(res))
+		}
+	}
+}
+
+func TestPortsHandlerForward(t *testing.T) {
+	// The test's listener stands in for the local end of the forward.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	localPort := ln.Addr().(*net.TCPAddr).Port
+
+	forwards := NewPortForwards()
+	var forwarded []string
+	forwards.command = func(codespaceName string, remotePort, localPort int) *exec.Cmd {
+		forwarded = append(forwarded, codespaceName)
+		return exec.Command("sleep", "30")
+	}
+	gh := &mockGHRunner{results: map[string]mockGHResult{
+		"codespace ports": {output: `[{"sourcePort":3000,"label":"web","visibility":"private","browseUrl":""}]`},
+	}}
+	handler := portsHandler(testReg(&mockExecutor{}), gh, forwards, true)
+
+	res, _ := handler(context.Background(), makeReq(map[string]any{"action": "forward", "port": float64(3000), "local_port": float64(localPort)}))
+	if res.IsError || len(forwarded) != 1 {
+		t.Fatalf("forward result = %q", resultText(res))
+	}
+	res, _ = handler(context.Background(), makeReq(map[string]any{"action": "forward", "port": float64(3000)}))
+	if !res.IsError || !strings.Contains(resultText(res), "already forwarded") {
+		t.Errorf("second forward = %q", resultText(res))
+	}
+	res, _ = handler(context.Background(), makeReq(nil))
+	if !strings.HasSuffix(resultText(res), "[forwarded to localhost:"+strings.TrimPrefix(ln.Addr().String(), "127.0.0.1:")+"]") {
+		t.Errorf("list = %q", resultText(res))
+	}
+
+	res, _ = handler(context.Background(), makeReq(map[string]any{"action": "stop_forward", "port": float64(3000)}))
+	if res.IsError {
+		t.Fatalf("stop_forward = %q", resultText(res))
+	}
+	res, _ = handler(context.Background(), makeReq(map[string]any{"action": "stop_forward", "port": float64(3000)}))
+	if !res.IsError {
+		t.Errorf("second stop_forward = %q", resultText(res))
+	}
+}
+
+func TestPortForwardReportsEarlyExit(t *testing.T) {
+	forwards := NewPortForwards()
+	forwards.command = func(string, int, int) *exec.Cmd {
+		return exec.Command("sh", "-c", "echo 'port 3000 is already in use' >&2; exit 1")
+	}
+	// Port 1 is privileged and never listening in the test environment.
+	err := forwards.start(context.Background(), "cs-app", 3000, 1)
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("start() = %v", err)
+	}
+}
+
+func TestPortForwardsStopAll(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	forwards := NewPortForwards()
+	forwards.command = func(string, int, int) *exec.Cmd {
+		return exec.Command("sleep", "30")
+	}
+	if err := forwards.start(context.Background(), "cs-app", 3000, ln.Addr().(*net.TCPAddr).Port); err != nil {
+		t.Fatal(err)
+	}
+	f := forwards.forwards[portForwardKey("cs-app", 3000)]
+
+	forwards.StopAll()
+	if !f.hasExited() || forwards.local("cs-app", 3000) != 0 {
+		t.Error("StopAll left the forward running")
+	}
+}
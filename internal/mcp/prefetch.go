@@ -0,0 +1,305 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// prefetchFiles is how many files of a remote_grep or remote_glob result
+	// are read ahead of the remote_view calls that usually follow.
+	prefetchFiles = 5
+	// prefetchTTL is how long a prefetched file is served without re-reading.
+	prefetchTTL = 30 * time.Second
+	// prefetchTimeout bounds each background read.
+	prefetchTimeout = 15 * time.Second
+	// maxPrefetchBytes keeps large files out of memory; they are read on view.
+	maxPrefetchBytes = 512 << 10
+)
+
+// prefetchEntry is a file read in the background. done is closed once text
+// and ok are set.
+type prefetchEntry struct {
+	done    chan struct{}
+	text    string
+	ok      bool
+	started time.Time
+}
+
+// viewPrefetcher holds remote_view output of files read ahead, keyed by
+// codespace and absolute path. Any mutating tool call clears it.
+type viewPrefetcher struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]*prefetchEntry
+	wg      sync.WaitGroup
+}
+
+func newViewPrefetcher() *viewPrefetcher {
+	return &viewPrefetcher{now: time.Now, entries: make(map[string]*prefetchEntry)}
+}
+
+// claim registers a new in-flight entry for key, or returns nil if a fresh
+// one exists.
+func (p *viewPrefetcher) claim(key string) *prefetchEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[key]; ok && p.now().Sub(e.started) <= prefetchTTL {
+		return nil
+	}
+	e := &prefetchEntry{done: make(chan struct{}), started: p.now()}
+	p.entries[key] = e
+	return e
+}
+
+// lookup waits for an in-flight read of key and returns its output.
+func (p *viewPrefetcher) lookup(ctx context.Context, key string) (string, bool) {
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if ok && p.now().Sub(e.started) > prefetchTTL {
+		delete(p.entries, key)
+		ok = false
+	}
+	p.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	select {
+	case <-e.done:
+		return e.text, e.ok
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+func (p *viewPrefetcher) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.entries)
+}
+
+// prefetch reads the first prefetchFiles paths of a remote_grep or
+// remote_glob result in the background. With confine set, paths that resolve
+// outside the workdir are skipped, since a cached view bypasses the
+// confinement check.
+func (p *viewPrefetcher) prefetch(cs *registry.ManagedCodespace, base, result string, confine bool) {
+	paths := prefetchCandidates(result, base)
+	if len(paths) == 0 {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+		defer cancel()
+		if confine {
+			root := workspaceRoot(cs)
+			resolved, err := resolveRemotePaths(ctx, cs, append([]string{root}, paths...))
+			if err != nil {
+				return
+			}
+			var inside []string
+			for i, file := range paths {
+				if pathWithin(resolved[0], resolved[i+1]) {
+					inside = append(inside, file)
+				}
+			}
+			paths = inside
+		}
+		if paths = smallFiles(ctx, cs, paths); len(paths) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, file := range paths {
+			e := p.claim(prefetchKey(cs.Alias, file))
+			if e == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer close(e.done)
+				text, err := cs.Executor.ViewFile(ctx, file, nil)
+				e.text, e.ok = text, err == nil && len(text) <= maxPrefetchBytes
+			}()
+		}
+		wg.Wait()
+	}()
+}
+
+// prefetchSizeCommand prints, NUL-separated, those of paths that are regular
+// files of at most maxPrefetchBytes.
+func prefetchSizeCommand(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	return fmt.Sprintf("find %s -maxdepth 0 -type f -size -%dc -print0 2>/dev/null; true", strings.Join(quoted, " "), maxPrefetchBytes+1)
+}
+
+// smallFiles returns those of paths that are small enough to prefetch, so
+// large files are not read only to be dropped. It returns nil if the sizes
+// can't be checked.
+func smallFiles(ctx context.Context, cs *registry.ManagedCodespace, paths []string) []string {
+	stdout, _, exitCode, err := cs.Executor.RunBash(ctx, prefetchSizeCommand(paths), "")
+	if err != nil || exitCode != 0 {
+		return nil
+	}
+	small := make(map[string]bool)
+	for _, p := range strings.Split(stdout, "\x00") {
+		small[p] = true
+	}
+	var out []string
+	for _, p := range paths {
+		if small[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func prefetchKey(alias, file string) string {
+	return alias + "\x00" + file
+}
+
+// prefetchCandidates returns up to prefetchFiles distinct files named in a
+// remote_grep or remote_glob result, made absolute against base.
+func prefetchCandidates(result, base string) []string {
+	if !path.IsAbs(base) {
+		return nil
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(result, "\n") {
+		line = strings.TrimSpace(line)
+		// Skip blank lines and notes such as mirror path translations.
+		if line == "" || line == "No matches found." || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if m := grepMatchRe.FindStringSubmatch(line); m != nil {
+			line = m[1]
+		}
+		if !path.IsAbs(line) {
+			line = path.Join(base, line)
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		paths = append(paths, line)
+		if len(paths) == prefetchFiles {
+			break
+		}
+	}
+	return paths
+}
+
+// sliceNumberedLines returns lines start through end (-1 for the last) of
+// numbered remote_view output, as a view_range read would.
+func sliceNumberedLines(text string, start, end int) string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start = max(start, 1)
+	if end == -1 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "")
+}
+
+// prefetchMiddleware reads the top files of remote_grep and remote_glob
+// results in the background and answers remote_view calls for absolute paths
+// from them. Mutating calls clear the prefetched files before and after they
+// run.
+func prefetchMiddleware(reg *registry.Registry, confine bool, p *viewPrefetcher) Middleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			switch req.Params.Name {
+			case "remote_view":
+				if text, ok := prefetchedView(ctx, reg, p, req); ok {
+					return toolSuccess(text), nil
+				}
+				return next(ctx, req)
+			case "remote_grep", "remote_glob":
+				result, err := next(ctx, req)
+				if err != nil || result == nil || result.IsError || len(result.Content) != 1 {
+					return result, err
+				}
+				text, ok := result.Content[0].(mcpsdk.TextContent)
+				cs, csErr := resolveCodespace(reg, req)
+				if ok && csErr == nil {
+					base := cs.Executor.GetWorkdir()
+					if cwd := optionalString(req, "cwd"); cwd != "" {
+						base = path.Join(base, cwd)
+						if path.IsAbs(cwd) {
+							base = path.Clean(cwd)
+						}
+					}
+					p.prefetch(cs, base, text.Text, confine)
+				}
+				return result, err
+			}
+			if !mutatingCall(req) {
+				return next(ctx, req)
+			}
+			p.clear()
+			defer p.clear()
+			return next(ctx, req)
+		}
+	}
+}
+
+// prefetchedView answers a remote_view call from a prefetched file.
+func prefetchedView(ctx context.Context, reg *registry.Registry, p *viewPrefetcher, req mcpsdk.CallToolRequest) (string, bool) {
+	file := optionalString(req, "path")
+	if !path.IsAbs(file) {
+		return "", false
+	}
+	cs, err := resolveCodespace(reg, req)
+	if err != nil {
+		return "", false
+	}
+	text, ok := p.lookup(ctx, prefetchKey(cs.Alias, path.Clean(file)))
+	if !ok {
+		return "", false
+	}
+	if raw, present := req.GetArguments()["view_range"]; present {
+		arr, isArr := raw.([]any)
+		if !isArr || len(arr) != 2 {
+			return "", false
+		}
+		start, ok1 := toInt(arr[0])
+		end, ok2 := toInt(arr[1])
+		if !ok1 || !ok2 {
+			return "", false
+		}
+		text = sliceNumberedLines(text, start, end)
+	}
+	return text, true
+}
+
+// mutatingCall reports whether req may change files on a codespace: a call
+// that changesCodespace, other than a read-only remote_bash probe.
+func mutatingCall(req mcpsdk.CallToolRequest) bool {
+	if !changesCodespace(req) {
+		return false
+	}
+	if req.Params.Name == "remote_bash" {
+		command := optionalString(req, "command")
+		return command == "" || !cacheableCommand(command)
+	}
+	return true
+}
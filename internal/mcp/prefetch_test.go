@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// fileExecutor serves ViewFile from files and counts reads per path.
+type fileExecutor struct {
+	*mockExecutor
+	mu    sync.Mutex
+	files map[string]string
+	reads map[string]int
+}
+
+func (f *fileExecutor) ViewFile(_ context.Context, path string, _ []int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads[path]++
+	return f.files[path], nil
+}
+
+// RunBash answers prefetchSizeCommand from the sizes of files and passes
+// other commands to the mock.
+func (f *fileExecutor) RunBash(ctx context.Context, command, cwd string) (string, string, int, error) {
+	if !strings.HasPrefix(command, "find ") {
+		return f.mockExecutor.RunBash(ctx, command, cwd)
+	}
+	var out strings.Builder
+	for p, content := range f.files {
+		if len(content) <= maxPrefetchBytes && strings.Contains(command, shellQuote(p)) {
+			out.WriteString(p + "\x00")
+		}
+	}
+	return out.String(), "", 0, nil
+}
+
+func TestPrefetchSizeCommand(t *testing.T) {
+	dir := t.TempDir()
+	small, big, exact := filepath.Join(dir, "small"), filepath.Join(dir, "big"), filepath.Join(dir, "exact")
+	os.WriteFile(small, []byte("x"), 0o644)
+	os.WriteFile(big, make([]byte, maxPrefetchBytes+1), 0o644)
+	os.WriteFile(exact, make([]byte, maxPrefetchBytes), 0o644)
+	out, err := exec.Command("bash", "-c", prefetchSizeCommand([]string{small, big, exact, dir, filepath.Join(dir, "missing")})).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), small+"\x00"+exact+"\x00"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestPrefetchCandidates(t *testing.T) {
+	result := "[path translated from local mirror: a -> b]\n" +
+		"src/a.go:10:func A()\nsrc/a.go:12:func B()\n/etc/hosts:1:localhost\n" +
+		"src/b.go:3:x\nsrc/c.go:3:x\nsrc/d.go:3:x\nsrc/e.go:3:x\n"
+	got := prefetchCandidates(result, "/workspaces/app")
+	want := []string{"/workspaces/app/src/a.go", "/etc/hosts", "/workspaces/app/src/b.go", "/workspaces/app/src/c.go", "/workspaces/app/src/d.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("grep candidates = %q, want %q", got, want)
+	}
+
+	got = prefetchCandidates("./cmd/main.go\n./go.mod\n", "/workspaces/app")
+	want = []string{"/workspaces/app/cmd/main.go", "/workspaces/app/go.mod"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("glob candidates = %q, want %q", got, want)
+	}
+
+	if got := prefetchCandidates("No matches found.", "/workspaces/app"); len(got) != 0 {
+		t.Errorf("candidates for no matches = %q", got)
+	}
+}
+
+func TestSliceNumberedLines(t *testing.T) {
+	text := "1. a\n2. b\n3. c\n"
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{1, 1, "1. a\n"},
+		{2, -1, "2. b\n3. c\n"},
+		{2, 10, "2. b\n3. c\n"},
+		{4, 5, ""},
+	}
+	for _, tt := range tests {
+		if got := sliceNumberedLines(text, tt.start, tt.end); got != tt.want {
+			t.Errorf("sliceNumberedLines(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestPrefetchMiddleware(t *testing.T) {
+	ex := &fileExecutor{
+		mockExecutor: &mockExecutor{workdir: "/workspaces/app"},
+		files: map[string]string{
+			"/workspaces/app/main.go":  "1. package main\n2. func main() {}\n",
+			"/workspaces/app/huge.txt": strings.Repeat("x", maxPrefetchBytes+1),
+		},
+		reads: make(map[string]int),
+	}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Executor: ex})
+	p := newViewPrefetcher()
+
+	handlerCalls := 0
+	h := prefetchMiddleware(reg, false, p)(func(_ context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		handlerCalls++
+		if req.Params.Name == "remote_grep" {
+			return toolSuccess("main.go:2:func main() {}\nhuge.txt:1:main"), nil
+		}
+		return toolSuccess("from handler"), nil
+	})
+	call := func(name string, args map[string]any) string {
+		t.Helper()
+		result, err := h(context.Background(), namedReq(name, args))
+		if err != nil || result.IsError {
+			t.Fatalf("%s: %v %s", name, err, resultText(result))
+		}
+		return resultText(result)
+	}
+
+	call("remote_grep", map[string]any{"pattern": "main"})
+	p.wg.Wait()
+	if ex.reads["/workspaces/app/main.go"] != 1 || ex.reads["/workspaces/app/huge.txt"] != 0 {
+		t.Fatalf("reads = %v, want main.go prefetched once and huge.txt not read", ex.reads)
+	}
+
+	handlerCalls = 0
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go"}); got != ex.files["/workspaces/app/main.go"] {
+		t.Errorf("view = %q, want prefetched file", got)
+	}
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go", "view_range": []any{float64(2), float64(2)}}); got != "2. func main() {}\n" {
+		t.Errorf("ranged view = %q", got)
+	}
+	if handlerCalls != 0 {
+		t.Errorf("handler ran %d times for prefetched views", handlerCalls)
+	}
+
+	// Relative paths resolve on the codespace, so they are not served.
+	if got := call("remote_view", map[string]any{"path": "main.go"}); got != "from handler" {
+		t.Errorf("relative view = %q, want handler result", got)
+	}
+
+	// A read-only probe keeps the prefetched files; an edit drops them.
+	call("remote_bash", map[string]any{"command": "git status"})
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go"}); got == "from handler" {
+		t.Error("read-only remote_bash cleared prefetched files")
+	}
+	call("remote_edit", map[string]any{"path": "/workspaces/app/main.go", "old_str": "a", "new_str": "b"})
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go"}); got != "from handler" {
+		t.Errorf("view after edit = %q, want handler result", got)
+	}
+
+	// So does stopping compose services.
+	call("remote_grep", map[string]any{"pattern": "main"})
+	p.wg.Wait()
+	call("remote_compose_down", map[string]any{})
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go"}); got != "from handler" {
+		t.Errorf("view after remote_compose_down = %q, want handler result", got)
+	}
+
+	// And so does a devcontainer lifecycle command.
+	call("remote_grep", map[string]any{"pattern": "main"})
+	p.wg.Wait()
+	call("devcontainer_update_content", map[string]any{})
+	if got := call("remote_view", map[string]any{"path": "/workspaces/app/main.go"}); got != "from handler" {
+		t.Errorf("view after devcontainer_update_content = %q, want handler result", got)
+	}
+}
+
+func TestPrefetchMiddlewareConfined(t *testing.T) {
+	ex := &fileExecutor{
+		mockExecutor: &mockExecutor{
+			workdir:       "/workspaces/app",
+			runBashStdout: "/workspaces/app\x00/home/me/.ssh/id_rsa\x00/workspaces/app/main.go\x00",
+		},
+		files: map[string]string{"/home/me/.ssh/id_rsa": "1. secret\n", "/workspaces/app/main.go": "1. package main\n"},
+		reads: make(map[string]int),
+	}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "cs-app", Workdir: "/workspaces/app", Executor: ex})
+	p := newViewPrefetcher()
+	h := prefetchMiddleware(reg, true, p)(func(_ context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("link.txt:1:x\nmain.go:1:package"), nil
+	})
+
+	if _, err := h(context.Background(), namedReq("remote_grep", map[string]any{"pattern": "x"})); err != nil {
+		t.Fatal(err)
+	}
+	p.wg.Wait()
+	if ex.reads["/workspaces/app/link.txt"] != 0 || ex.reads["/workspaces/app/main.go"] != 1 {
+		t.Errorf("reads = %v, want only main.go", ex.reads)
+	}
+}
@@ -0,0 +1,50 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// sessionRecorder is implemented by executors that can record async sessions
+// (*ssh.Client).
+type sessionRecorder interface {
+	SetSessionRecording(enabled bool)
+	CollectRecordings(ctx context.Context, localDir, prefix string) ([]string, error)
+}
+
+// enableRecording turns on session recording for cs when dir is set.
+func enableRecording(cs *registry.ManagedCodespace, dir string) {
+	if dir == "" {
+		return
+	}
+	if r, ok := cs.Executor.(sessionRecorder); ok {
+		r.SetSessionRecording(true)
+	}
+}
+
+// CollectRecordings copies every codespace's session recordings into dir as
+// <time>-<alias>-<shellId>.cast files, returning the files written. Failures
+// for one codespace don't stop the others.
+func CollectRecordings(ctx context.Context, reg *registry.Registry, dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	var written []string
+	var errs []error
+	for _, cs := range reg.All() {
+		r, ok := cs.Executor.(sessionRecorder)
+		if !ok {
+			continue
+		}
+		files, err := r.CollectRecordings(ctx, dir, stamp+"-"+cs.Alias+"-")
+		written = append(written, files...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return written, errors.Join(errs...)
+}
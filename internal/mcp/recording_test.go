@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// recordingExecutor is a mockExecutor that records sessions.
+type recordingExecutor struct {
+	mockExecutor
+	enabled bool
+	prefix  string
+	files   []string
+	err     error
+}
+
+func (r *recordingExecutor) SetSessionRecording(enabled bool) { r.enabled = enabled }
+
+func (r *recordingExecutor) CollectRecordings(ctx context.Context, localDir, prefix string) ([]string, error) {
+	r.prefix = prefix
+	return r.files, r.err
+}
+
+func TestNewServerEnablesRecording(t *testing.T) {
+	exec := &recordingExecutor{}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "cs-api", Executor: exec})
+
+	NewServer(reg, LifecycleConfig{})
+	if exec.enabled {
+		t.Error("recording enabled without RecordingDir")
+	}
+	NewServer(reg, LifecycleConfig{RecordingDir: t.TempDir()})
+	if !exec.enabled {
+		t.Error("recording not enabled with RecordingDir")
+	}
+}
+
+func TestCollectRecordings(t *testing.T) {
+	ok := &recordingExecutor{files: []string{"/logs/a.cast"}}
+	failing := &recordingExecutor{files: []string{"/logs/b.cast"}, err: errors.New("boom")}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "cs-api", Executor: ok})
+	reg.Register(&registry.ManagedCodespace{Alias: "web", Name: "cs-web", Executor: failing})
+	reg.Register(&registry.ManagedCodespace{Alias: "plain", Name: "cs-plain", Executor: &mockExecutor{}})
+
+	files, err := CollectRecordings(context.Background(), reg, "/logs")
+	if len(files) != 2 || err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("CollectRecordings = %v, %v", files, err)
+	}
+	if !strings.HasSuffix(ok.prefix, "-api-") || !strings.HasSuffix(failing.prefix, "-web-") {
+		t.Errorf("prefixes = %q, %q", ok.prefix, failing.prefix)
+	}
+
+	if files, err := CollectRecordings(context.Background(), reg, ""); files != nil || err != nil {
+		t.Errorf("without dir = %v, %v", files, err)
+	}
+}
@@ -0,0 +1,322 @@
+package mcp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxScaffoldBytes caps the total file content of one remote_scaffold call.
+const maxScaffoldBytes = 16 << 20
+
+// maxInlineScaffoldBytes caps the packed payload for executors that can only
+// pass it inside the command line.
+const maxInlineScaffoldBytes = 64 << 10
+
+// inputExecutor is implemented by executors that can feed a payload on stdin,
+// like *ssh.Client.
+type inputExecutor interface {
+	ExecWithInput(ctx context.Context, command string, input []byte) (stdout, stderr string, exitCode int, err error)
+}
+
+// scaffoldEntry is one file, or with dir set an empty directory, relative to
+// the scaffold target.
+type scaffoldEntry struct {
+	path    string
+	content []byte
+	dir     bool
+}
+
+// scaffoldPath validates and cleans a path relative to the scaffold target.
+func scaffoldPath(p string) (string, error) {
+	clean := path.Clean(strings.TrimSuffix(p, "/"))
+	if p == "" || clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid path %q: must be relative to directory and stay inside it", p)
+	}
+	return clean, nil
+}
+
+// scaffoldEntriesFromFiles reads the files argument. Keys ending in "/" are
+// empty directories.
+func scaffoldEntriesFromFiles(files map[string]any) ([]scaffoldEntry, error) {
+	var entries []scaffoldEntry
+	for key, v := range files {
+		content, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("files[%q] must be a string", key)
+		}
+		p, err := scaffoldPath(key)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(key, "/") {
+			entries = append(entries, scaffoldEntry{path: p, dir: true})
+			continue
+		}
+		entries = append(entries, scaffoldEntry{path: p, content: []byte(content)})
+	}
+	return entries, nil
+}
+
+// scaffoldEntriesFromArchive reads a base64 .tar.gz (or plain .tar) payload.
+// Only regular files and directories are accepted.
+func scaffoldEntriesFromArchive(encoded string) ([]scaffoldEntry, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: not base64: %w", err)
+	}
+	var r io.Reader = bytes.NewReader(data)
+	if gz, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	var entries []scaffoldEntry
+	total := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if path.Clean(hdr.Name) == "." {
+			continue
+		}
+		p, err := scaffoldPath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entries = append(entries, scaffoldEntry{path: p, dir: true})
+		case tar.TypeReg:
+			content, err := io.ReadAll(io.LimitReader(tr, maxScaffoldBytes-int64(total)+1))
+			if err != nil {
+				return nil, fmt.Errorf("invalid archive: %w", err)
+			}
+			total += len(content)
+			if total > maxScaffoldBytes {
+				return nil, fmt.Errorf("archive content exceeds %d bytes", maxScaffoldBytes)
+			}
+			entries = append(entries, scaffoldEntry{path: p, content: content})
+		default:
+			return nil, fmt.Errorf("invalid archive: %s is not a regular file or directory", hdr.Name)
+		}
+	}
+	return entries, nil
+}
+
+// checkScaffoldEntries sorts entries and rejects empty, duplicate, oversized,
+// or conflicting sets (a file that is also another entry's parent).
+func checkScaffoldEntries(entries []scaffoldEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("nothing to create: pass files or archive")
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+	total := 0
+	files := make(map[string]bool)
+	for i, e := range entries {
+		if i > 0 && entries[i-1].path == e.path {
+			return fmt.Errorf("duplicate path %q", e.path)
+		}
+		total += len(e.content)
+		if !e.dir {
+			files[e.path] = true
+		}
+	}
+	if total > maxScaffoldBytes {
+		return fmt.Errorf("file content totals %d bytes (max %d)", total, maxScaffoldBytes)
+	}
+	for _, e := range entries {
+		for dir := path.Dir(e.path); dir != "."; dir = path.Dir(dir) {
+			if files[dir] {
+				return fmt.Errorf("%s is both a file and the parent of %s", dir, e.path)
+			}
+		}
+	}
+	return nil
+}
+
+// scaffoldArchive packs entries as a .tar.gz for the remote side to unpack.
+func scaffoldArchive(entries []scaffoldEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.path, Mode: 0o644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr = &tar.Header{Name: e.path + "/", Mode: 0o755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaffoldCommand unpacks a .tar.gz into a staging directory next to target,
+// then renames it into place when target does not exist yet, which is atomic.
+// Otherwise the files are moved in after checking that none of them exist,
+// unless overwrite is set. It prints the created paths. The archive is read
+// from stdin, or from inline when that is set.
+func scaffoldCommand(target string, inline []byte, overwrite bool) string {
+	overwriteFlag := 0
+	if overwrite {
+		overwriteFlag = 1
+	}
+	source := ""
+	if inline != nil {
+		source = fmt.Sprintf("printf '%%s' %s | base64 -d | ", shellQuote(base64.StdEncoding.EncodeToString(inline)))
+	}
+	return fmt.Sprintf(`target=%s; overwrite=%d; `+
+		`case "$target" in /*) ;; *) target="$PWD/$target" ;; esac; `+
+		`parent=$(dirname -- "$target"); mkdir -p -- "$parent" || exit 1; `+
+		`stage=$(mktemp -d "$parent/.scaffold.XXXXXX") || exit 1; trap 'rm -rf -- "$stage"' EXIT; `+
+		`%star -xzf - -C "$stage" --no-same-owner || exit 1; `+
+		`cd -- "$stage" || exit 1; `+
+		`if [ ! -e "$target" ] && [ ! -L "$target" ]; then `+
+		`chmod 755 "$stage" && find . -mindepth 1 ! -type d -print | sed 's|^\./||' | sort && mv -T -- "$stage" "$target" && trap - EXIT; exit; fi; `+
+		`if [ ! -d "$target" ]; then echo "not a directory: $target" >&2; exit 1; fi; `+
+		`if [ "$overwrite" = 0 ]; then `+
+		`conflicts=$(find . -mindepth 1 ! -type d -print | while IFS= read -r f; do if [ -e "$target/$f" ] || [ -L "$target/$f" ]; then printf '%%s\n' "${f#./}"; fi; done); `+
+		`if [ -n "$conflicts" ]; then printf 'already exists (pass overwrite to replace):\n%%s\n' "$conflicts" >&2; exit 3; fi; fi; `+
+		`find . -mindepth 1 -type d -print | while IFS= read -r d; do mkdir -p -- "$target/$d" || exit 1; done || exit 1; `+
+		`find . -mindepth 1 ! -type d -print | sort | while IFS= read -r f; do mv -f -- "$f" "$target/$f" || exit 1; printf '%%s\n' "${f#./}"; done`,
+		shellQuote(target), overwriteFlag, source)
+}
+
+// --- remote_scaffold ---
+
+func scaffoldTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_scaffold",
+		Annotations: mutatingHints("Scaffold remote files", true, false, false),
+		Description: "Create several files under one directory on the remote codespace in a single call, for scaffolding a package or component. " +
+			"Files are staged first: a new directory appears all at once, and nothing is written if any file already exists (unless overwrite is set). " +
+			"Use this instead of a series of remote_create calls.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"directory": map[string]any{
+					"type":        "string",
+					"description": "Target directory. It is created if missing. Relative paths resolve against cwd.",
+				},
+				"files": map[string]any{
+					"type":                 "object",
+					"description":          "Map of paths relative to directory to file contents. A key ending in '/' creates an empty directory.",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+				"archive": map[string]any{
+					"type":        "string",
+					"description": "Alternative to files: a base64-encoded .tar.gz (or .tar) containing only regular files and directories",
+				},
+				"overwrite": map[string]any{
+					"type":        "boolean",
+					"description": "Replace files that already exist (default: false, which fails without writing anything)",
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory for a relative directory (default: current working directory)",
+				},
+				"confirm_write_quota": confirmWriteQuotaParam,
+			},
+			Required: []string{"directory"},
+		},
+	}
+}
+
+func scaffoldHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		dir, err := requiredString(req, "directory")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		cwd := optionalString(req, "cwd")
+		if cwd == "" {
+			cwd = cs.Executor.GetWorkdir()
+		}
+
+		files, hasFiles := req.GetArguments()["files"].(map[string]any)
+		archive := optionalString(req, "archive")
+		var entries []scaffoldEntry
+		switch {
+		case hasFiles && archive != "":
+			return categorizedError(errInvalidArgument, "pass either files or archive, not both"), nil
+		case archive != "":
+			entries, err = scaffoldEntriesFromArchive(archive)
+		default:
+			entries, err = scaffoldEntriesFromFiles(files)
+		}
+		if err == nil {
+			err = checkScaffoldEntries(entries)
+		}
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		payload, err := scaffoldArchive(entries)
+		if err != nil {
+			return categorizedError(errInternal, fmt.Sprintf("packing files: %v", err)), nil
+		}
+
+		target := dir
+		if !path.IsAbs(target) && cwd != "" {
+			target = path.Join(cwd, target)
+		}
+		overwrite := optionalBool(req, "overwrite", false)
+		var stdout, stderr string
+		var exitCode int
+		if ie, ok := cs.Executor.(inputExecutor); ok {
+			stdout, stderr, exitCode, err = ie.ExecWithInput(ctx, scaffoldCommand(target, nil, overwrite), payload)
+		} else if len(payload) > maxInlineScaffoldBytes {
+			return categorizedError(errInvalidArgument, fmt.Sprintf("packed files are %d bytes, over the %d this connection can send in one call; split them across calls", len(payload), maxInlineScaffoldBytes)), nil
+		} else {
+			stdout, stderr, exitCode, err = cs.Executor.RunBash(ctx, scaffoldCommand(dir, payload, overwrite), cwd)
+		}
+		if err != nil {
+			return toolError(fmt.Sprintf("failed to scaffold %s: %v", dir, err)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("scaffold failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+
+		var created []string
+		for _, f := range strings.Split(strings.TrimSpace(stdout), "\n") {
+			if f != "" {
+				created = append(created, f)
+			}
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Created %d file(s) in %s", len(created), target)
+		for _, f := range created {
+			fmt.Fprintf(&sb, "\n  %s", f)
+		}
+		return toolSuccess(sb.String()), nil
+	}
+}
@@ -0,0 +1,167 @@
+package mcp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustScaffoldArchive(t *testing.T, files map[string]any) []byte {
+	t.Helper()
+	entries, err := scaffoldEntriesFromFiles(files)
+	if err == nil {
+		err = checkScaffoldEntries(entries)
+	}
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	payload, err := scaffoldArchive(entries)
+	if err != nil {
+		t.Fatalf("scaffoldArchive() error = %v", err)
+	}
+	return payload
+}
+
+// runScaffold runs scaffoldCommand with the local bash, feeding payload on
+// stdin like ExecWithInput does.
+func runScaffold(t *testing.T, dir, target string, payload []byte, overwrite bool) (string, string, error) {
+	t.Helper()
+	cmd := exec.Command("bash", "-c", scaffoldCommand(target, nil, overwrite))
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestScaffoldCommandNewDirectory(t *testing.T) {
+	dir := t.TempDir()
+	payload := mustScaffoldArchive(t, map[string]any{
+		"go.mod":          "module example\n",
+		"cmd/app/main.go": "package main\n",
+		"testdata/":       "",
+	})
+	stdout, stderr, err := runScaffold(t, dir, "pkg/new", payload, false)
+	if err != nil {
+		t.Fatalf("scaffold: %v\n%s", err, stderr)
+	}
+	if stdout != "cmd/app/main.go\ngo.mod\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pkg/new/cmd/app/main.go")); string(data) != "package main\n" {
+		t.Errorf("main.go = %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "pkg/new/testdata")); err != nil || !info.IsDir() {
+		t.Errorf("testdata: %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "pkg/new")); info.Mode().Perm() != 0o755 {
+		t.Errorf("target mode = %v, want 0755", info.Mode().Perm())
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "pkg/.scaffold.*")); len(leftovers) != 0 {
+		t.Errorf("staging dirs left behind: %v", leftovers)
+	}
+
+	// Executors without stdin get the archive inline.
+	cmd := exec.Command("bash", "-c", scaffoldCommand("inline", payload, false))
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil || string(out) != "cmd/app/main.go\ngo.mod\n" {
+		t.Errorf("inline scaffold = %q, %v", out, err)
+	}
+}
+
+func TestScaffoldCommandExistingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	os.MkdirAll(filepath.Join(target, "src"), 0o755)
+	os.WriteFile(filepath.Join(target, "src/keep.go"), []byte("old\n"), 0o644)
+
+	payload := mustScaffoldArchive(t, map[string]any{"src/keep.go": "new\n", "src/add.go": "add\n"})
+	if _, stderr, err := runScaffold(t, dir, target, payload, false); err == nil || !strings.Contains(stderr, "already exists") || !strings.Contains(stderr, "src/keep.go") {
+		t.Fatalf("conflict: err = %v, stderr = %q", err, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(target, "src/add.go")); !os.IsNotExist(err) {
+		t.Errorf("a conflicting scaffold wrote add.go: %v", err)
+	}
+
+	stdout, stderr, err := runScaffold(t, dir, target, payload, true)
+	if err != nil {
+		t.Fatalf("overwrite: %v\n%s", err, stderr)
+	}
+	if stdout != "src/add.go\nsrc/keep.go\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "src/keep.go")); string(data) != "new\n" {
+		t.Errorf("keep.go = %q", data)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".scaffold.*")); len(leftovers) != 0 {
+		t.Errorf("staging dirs left behind: %v", leftovers)
+	}
+}
+
+func TestScaffoldEntriesValidation(t *testing.T) {
+	for name, files := range map[string]map[string]any{
+		"escape":      {"../x": "a"},
+		"absolute":    {"/etc/x": "a"},
+		"non-string":  {"a": 1},
+		"file parent": {"a": "x", "a/b": "y"},
+		"empty":       {},
+	} {
+		entries, err := scaffoldEntriesFromFiles(files)
+		if err == nil {
+			err = checkScaffoldEntries(entries)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.Close()
+	if _, err := scaffoldEntriesFromArchive(base64.StdEncoding.EncodeToString(buf.Bytes())); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Errorf("symlink archive error = %v", err)
+	}
+
+	payload := mustScaffoldArchive(t, map[string]any{"a/b.txt": "hi"})
+	entries, err := scaffoldEntriesFromArchive(base64.StdEncoding.EncodeToString(payload))
+	if err != nil || len(entries) != 1 || entries[0].path != "a/b.txt" || string(entries[0].content) != "hi" {
+		t.Errorf("archive entries = %+v, %v", entries, err)
+	}
+}
+
+func TestScaffoldHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "a.go\nb/c.go\n"}
+	result, _ := scaffoldHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"directory": "pkg/new",
+		"files":     map[string]any{"a.go": "package a\n", "b/c.go": "package b\n"},
+		"cwd":       "/workspaces/app",
+	}))
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", resultText(result))
+	}
+	if got := resultText(result); got != "Created 2 file(s) in /workspaces/app/pkg/new\n  a.go\n  b/c.go" {
+		t.Errorf("result = %q", got)
+	}
+	if !strings.HasPrefix(mock.lastRunBashCommand, "target='pkg/new'") || mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("command = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	mock = &mockExecutor{runBashStderr: "already exists (pass overwrite to replace):\na.go", runBashExit: 3}
+	result, _ = scaffoldHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"directory": "x", "files": map[string]any{"a.go": ""}}))
+	if !result.IsError || !strings.HasPrefix(resultText(result), "[error:conflict]") {
+		t.Errorf("conflict result = %q", resultText(result))
+	}
+
+	result, _ = scaffoldHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(map[string]any{"directory": "x", "files": map[string]any{"a": "1"}, "archive": "eA=="}))
+	if !result.IsError || !strings.HasPrefix(resultText(result), "[error:invalid_argument]") {
+		t.Errorf("files and archive result = %q", resultText(result))
+	}
+}
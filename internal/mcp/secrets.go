@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// noSecretsFileExit is the exit status of secretNamesCommand when the
+// codespace has no secrets file.
+const noSecretsFileExit = 3
+
+// secretNamesCommand prints the name of each secret in the secrets file at
+// path, one per line. Only the part before "=" is printed, so the values never
+// leave the codespace.
+func secretNamesCommand(path string) string {
+	return fmt.Sprintf(`test -f %[1]s || exit %[2]d; sed -n 's/^\([A-Za-z_][A-Za-z0-9_]*\)=.*/\1/p' %[1]s`, shellQuote(path), noSecretsFileExit)
+}
+
+// --- remote_secret_names ---
+
+func secretNamesTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_secret_names",
+		Annotations: readOnlyHints("List secret names", false),
+		Description: "List the names of the Codespaces secrets available on the codespace, never their values. " +
+			"Pass names to check specific secrets, such as NPM_TOKEN before a publish, instead of failing halfway through a flow that needs them.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"names": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Secret names to report as available or missing, instead of listing every name",
+				},
+			},
+		},
+	}
+}
+
+func secretNamesHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		c, err := resolveExecutor(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		var wanted []string
+		if v, ok := req.GetArguments()["names"]; ok {
+			items, ok := v.([]any)
+			if !ok {
+				return categorizedError(errInvalidArgument, "names must be an array of strings"), nil
+			}
+			for _, item := range items {
+				name, ok := item.(string)
+				if !ok || name == "" {
+					return categorizedError(errInvalidArgument, "names must be an array of strings"), nil
+				}
+				wanted = append(wanted, name)
+			}
+		}
+
+		stdout, stderr, exitCode, err := c.RunBash(ctx, secretNamesCommand(codespaceenv.SecretsPath), "")
+		if err != nil {
+			return toolError(fmt.Sprintf("reading secret names: %v", err)), nil
+		}
+		var names []string
+		switch exitCode {
+		case 0:
+			names = parseSecretNames(stdout)
+		case noSecretsFileExit:
+		default:
+			return categorizedError(errCommandFailed, fmt.Sprintf("reading secret names failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		return toolSuccess(formatSecretNames(names, wanted)), nil
+	}
+}
+
+// parseSecretNames returns the sorted, distinct names secretNamesCommand printed.
+func parseSecretNames(out string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatSecretNames lists names, or with wanted, reports each wanted name as
+// available or missing.
+func formatSecretNames(names, wanted []string) string {
+	if len(wanted) == 0 {
+		if len(names) == 0 {
+			return "No Codespaces secrets are available on this codespace."
+		}
+		return fmt.Sprintf("%d secrets available (names only):\n%s", len(names), strings.Join(names, "\n"))
+	}
+	available := map[string]bool{}
+	for _, name := range names {
+		available[name] = true
+	}
+	var sb strings.Builder
+	for _, name := range wanted {
+		state := "missing"
+		if available[name] {
+			state = "available"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", name, state)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
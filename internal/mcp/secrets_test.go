@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecretNamesCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env-secrets")
+	if err := os.WriteFile(path, []byte("NPM_TOKEN=c2VjcmV0LXZhbHVl\nGITHUB_TOKEN=Z2hzX3h4eA==\n\nnot a secret\nEMPTY=\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("bash", "-c", secretNamesCommand(path)).Output()
+	if err != nil {
+		t.Fatalf("running secret names command: %v", err)
+	}
+	if strings.Contains(string(out), "c2VjcmV0") {
+		t.Fatalf("output %q holds a secret value", out)
+	}
+	if got, want := parseSecretNames(string(out)), []string{"EMPTY", "GITHUB_TOKEN", "NPM_TOKEN"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("names = %q, want %q", got, want)
+	}
+
+	err = exec.Command("bash", "-c", secretNamesCommand(filepath.Join(t.TempDir(), "missing"))).Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != noSecretsFileExit {
+		t.Fatalf("missing file: err = %v, want exit %d", err, noSecretsFileExit)
+	}
+}
+
+func TestSecretNamesHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "NPM_TOKEN\nGITHUB_TOKEN\n"}
+	res, _ := secretNamesHandler(testReg(mock))(context.Background(), makeReq(map[string]any{}))
+	if res.IsError || resultText(res) != "2 secrets available (names only):\nGITHUB_TOKEN\nNPM_TOKEN" {
+		t.Fatalf("list = %q", resultText(res))
+	}
+	if !strings.Contains(mock.lastRunBashCommand, ".env-secrets") {
+		t.Errorf("command = %q, want the codespace secrets file", mock.lastRunBashCommand)
+	}
+
+	res, _ = secretNamesHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"names": []any{"NPM_TOKEN", "PYPI_TOKEN"}}))
+	if res.IsError || resultText(res) != "NPM_TOKEN: available\nPYPI_TOKEN: missing" {
+		t.Fatalf("check = %q", resultText(res))
+	}
+
+	res, _ = secretNamesHandler(testReg(&mockExecutor{runBashExit: noSecretsFileExit}))(context.Background(), makeReq(map[string]any{}))
+	if res.IsError || !strings.HasPrefix(resultText(res), "No Codespaces secrets") {
+		t.Fatalf("no secrets file = %q", resultText(res))
+	}
+
+	res, _ = secretNamesHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(map[string]any{"names": "NPM_TOKEN"}))
+	if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+		t.Fatalf("names as a string = %q", resultText(res))
+	}
+}
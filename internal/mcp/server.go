@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,31 +19,67 @@ import (
 // NewServer creates and configures the MCP server with all remote tools.
 // Uses a registry to support multiple codespaces.
 func NewServer(reg *registry.Registry, lcfg ...LifecycleConfig) *server.MCPServer {
-	s := server.NewMCPServer("codespace-mcp", "0.2.0")
-
 	// Default lifecycle config
 	var cfg LifecycleConfig
 	if len(lcfg) > 0 {
 		cfg = lcfg[0]
 	}
+
 	if cfg.GHRunner == nil {
 		cfg.GHRunner = &RealGHRunner{}
 	}
+	if cfg.PortForwards == nil {
+		cfg.PortForwards = NewPortForwards()
+	}
+	var opts []server.ServerOption
+	for _, mw := range toolMiddlewares(reg, cfg) {
+		opts = append(opts, server.WithToolHandlerMiddleware(mw))
+	}
+	s := server.NewMCPServer("codespace-mcp", "0.2.0", opts...)
+	for _, cs := range reg.All() {
+		enableRecording(cs, cfg.RecordingDir)
+	}
 	state := newLifecycleState(cfg)
-
-	s.AddTool(viewTool(), viewHandler(reg))
-	s.AddTool(editTool(), editHandler(reg))
-	s.AddTool(createTool(), createHandler(reg))
-	s.AddTool(bashTool(), bashHandler(reg))
-	s.AddTool(grepTool(), grepHandler(reg))
-	s.AddTool(globTool(), globHandler(reg))
-	s.AddTool(writeBashTool(), writeBashHandler(reg))
-	s.AddTool(readBashTool(), readBashHandler(reg))
-	s.AddTool(stopBashTool(), stopBashHandler(reg))
+	status := newStatusRecorder(cfg.Workspace.Dir)
+	quota := newWriteQuota(cfg.WriteQuota)
+
+	s.AddTool(viewTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, viewHandler(reg), "path"), "path"))
+	s.AddTool(viewManyTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, viewManyHandler(reg), "files", "cwd"), "files", "cwd"))
+	s.AddTool(editTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, withWriteQuota(quota, stringArgSize("new_str"), editHandler(reg)), "path"), "path"))
+	s.AddTool(createTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, withWriteQuota(quota, stringArgSize("file_text"), createHandler(reg)), "path"), "path"))
+	s.AddTool(scaffoldTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, withWriteQuota(quota, scaffoldWriteSize, scaffoldHandler(reg)), "directory", "cwd"), "directory", "cwd"))
+	s.AddTool(bashTool(), withMirrorPaths(reg, withDirtyTreeGuard(reg, cfg.ConfirmDiscard, withHeavyBuildWarning(reg, bashHandlerWithStatus(reg, status))), "command", "steps", "cwd"))
+	s.AddTool(grepTool(), withMirrorPaths(reg, grepHandler(reg), "path", "paths_from", "cwd"))
+	s.AddTool(globTool(), withMirrorPaths(reg, globHandler(reg), "path", "cwd"))
+	s.AddTool(statTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, statHandler(reg), "path", "cwd"), "path", "cwd"))
+	s.AddTool(gitLogTool(), withMirrorPaths(reg, gitLogHandler(reg), "path", "cwd"))
+	s.AddTool(blameTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, blameHandler(reg), "path", "cwd"), "path", "cwd"))
+	s.AddTool(capabilitiesTool(), capabilitiesHandler(reg))
+	s.AddTool(statusTool(), statusHandler(reg))
+	s.AddTool(topTool(), topHandler(reg))
+	s.AddTool(secretNamesTool(), secretNamesHandler(reg))
+	for _, h := range devcontainerHooks {
+		s.AddTool(devcontainerHookTool(h.property, h.tool, h.when), devcontainerHookHandler(reg, h.property))
+	}
+	s.AddTool(writeBashTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, writeBashHandlerWithStatus(reg, status), "paste_file"), "paste_file"))
+	s.AddTool(readBashTool(), readBashHandlerWithStatus(reg, status))
+	s.AddTool(stopBashTool(), stopBashHandlerWithStatus(reg, status))
 	s.AddTool(listBashTool(), listBashHandler(reg))
 	s.AddTool(openShellTool(), openShellHandler(reg))
-	s.AddTool(cdTool(), cdHandler(reg))
+	s.AddTool(cdTool(), withMirrorPaths(reg, cdHandler(reg), "path"))
 	s.AddTool(cwdTool(), cwdHandler(reg))
+	s.AddTool(lnTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, lnHandler(reg), "link_path"), "target", "link_path"))
+	s.AddTool(chmodTool(), withMirrorPaths(reg, withConfinement(reg, cfg.ConfineToWorkdir, chmodHandler(reg), "path"), "path"))
+	s.AddTool(ghRunTool(), withMirrorPaths(reg, ghRunHandler(reg, cfg.ReadOnly), "cwd"))
+	s.AddTool(portsTool(), portsHandler(reg, cfg.GHRunner, cfg.PortForwards, cfg.ReadOnly))
+	s.AddTool(waitTool(), withMirrorPaths(reg, waitHandler(reg), "path", "cwd"))
+	s.AddTool(dockerPSTool(), dockerPSHandler(reg))
+	s.AddTool(composeUpTool(), withMirrorPaths(reg, composeUpHandler(reg), "file", "cwd"))
+	s.AddTool(composeDownTool(), withMirrorPaths(reg, composeDownHandler(reg), "file", "cwd"))
+	s.AddTool(composeLogsTool(), withMirrorPaths(reg, composeLogsHandler(reg), "file", "cwd"))
+	if cfg.RemoteTask {
+		s.AddTool(remoteTaskTool(), withMirrorPaths(reg, remoteTaskHandler(reg, status), "cwd"))
+	}
 	s.AddTool(listCodespacesTool(), listCodespacesHandler(reg))
 	s.AddTool(listAvailableCodespacesTool(), listAvailableCodespacesHandlerWithState(state))
 	s.AddTool(getCodespaceOptionsTool(), getCodespaceOptionsHandler(state.cfg.GHRunner))
@@ -50,9 +87,45 @@ func NewServer(reg *registry.Registry, lcfg ...LifecycleConfig) *server.MCPServe
 	s.AddTool(connectCodespaceTool(), connectCodespaceHandlerWithState(reg, state))
 	s.AddTool(deleteCodespaceTool(), deleteCodespaceHandlerWithState(reg, state))
 
+	if cfg.ReadOnly {
+		s.DeleteTools(mutatingTools...)
+	}
+
 	return s
 }
 
+// mutatingTools are withheld in read-only sessions. remote_bash stays available,
+// so read-only narrows the advertised tool surface rather than sandboxing the shell.
+var mutatingTools = []string{
+	"remote_edit",
+	"remote_create",
+	"remote_scaffold",
+	"remote_ln",
+	"remote_chmod",
+	"create_codespace",
+	"delete_codespace",
+	"remote_task",
+	"remote_compose_up",
+	"remote_compose_down",
+	"devcontainer_on_create",
+	"devcontainer_update_content",
+	"devcontainer_post_create",
+	"devcontainer_post_start",
+	"devcontainer_post_attach",
+}
+
+// ToolNames returns the sorted names of the tools NewServer registers for cfg.
+// The launcher uses it to build an explicit allowlist for the MCP config.
+func ToolNames(cfg LifecycleConfig) []string {
+	tools := NewServer(registry.New(), cfg).ListTools()
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // NewServerSingle creates an MCP server with a single codespace for backward compatibility.
 func NewServerSingle(executor ssh.Executor, codespaceName string) *server.MCPServer {
 	reg := registry.New()
@@ -66,25 +139,57 @@ func NewServerSingle(executor ssh.Executor, codespaceName string) *server.MCPSer
 
 // resolveExecutor extracts the codespace alias from the request and resolves it via the registry.
 func resolveExecutor(reg *registry.Registry, req mcpsdk.CallToolRequest) (ssh.Executor, error) {
-	alias := optionalString(req, "codespace")
-	cs, err := reg.Resolve(alias)
+	cs, err := resolveCodespace(reg, req)
 	if err != nil {
 		return nil, err
 	}
 	return cs.Executor, nil
 }
 
+// resolveCodespace is like resolveExecutor but returns the full registry entry,
+// for tools that also need the codespace metadata (e.g. its workspace root).
+func resolveCodespace(reg *registry.Registry, req mcpsdk.CallToolRequest) (*registry.ManagedCodespace, error) {
+	return reg.Resolve(optionalString(req, "codespace"))
+}
+
 // codespaceParam is the common "codespace" parameter added to all remote tools.
 var codespaceParam = map[string]any{
 	"type":        "string",
 	"description": "Codespace alias (optional if only one connected). Use list_codespaces to see available aliases.",
 }
 
+// readOnlyHints annotates a tool that only observes the codespace or GitHub.
+// openWorld marks tools that reach beyond the connected codespaces (the GitHub API).
+func readOnlyHints(title string, openWorld bool) mcpsdk.ToolAnnotation {
+	return mcpsdk.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcpsdk.ToBoolPtr(true),
+		DestructiveHint: mcpsdk.ToBoolPtr(false),
+		IdempotentHint:  mcpsdk.ToBoolPtr(true),
+		OpenWorldHint:   mcpsdk.ToBoolPtr(openWorld),
+	}
+}
+
+// mutatingHints annotates a tool that changes state. destructive means it can
+// overwrite or remove existing data; idempotent means repeating the same call
+// has no further effect. Every hint is explicit because the MCP defaults for
+// unset hints are the most conservative ones.
+func mutatingHints(title string, destructive, idempotent, openWorld bool) mcpsdk.ToolAnnotation {
+	return mcpsdk.ToolAnnotation{
+		Title:           title,
+		ReadOnlyHint:    mcpsdk.ToBoolPtr(false),
+		DestructiveHint: mcpsdk.ToBoolPtr(destructive),
+		IdempotentHint:  mcpsdk.ToBoolPtr(idempotent),
+		OpenWorldHint:   mcpsdk.ToBoolPtr(openWorld),
+	}
+}
+
 // --- remote_view ---
 
 func viewTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_view",
+		Annotations: readOnlyHints("View remote file", false),
 		Description: "View a file or directory on the remote codespace. Returns file contents with line numbers. Replaces the local 'view' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -140,6 +245,7 @@ func viewHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func editTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_edit",
+		Annotations: mutatingHints("Edit remote file", true, false, false),
 		Description: "Edit a file on the remote codespace by replacing exactly one occurrence of old_str with new_str. Replaces the local 'edit' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -157,6 +263,7 @@ func editTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "The replacement string",
 				},
+				"confirm_write_quota": confirmWriteQuotaParam,
 			},
 			Required: []string{"path", "old_str", "new_str"},
 		},
@@ -194,6 +301,7 @@ func editHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func createTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_create",
+		Annotations: mutatingHints("Create remote file", true, true, false),
 		Description: "Create a new file on the remote codespace with the given content. Parent directories are created automatically. Replaces the local 'create' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -207,6 +315,7 @@ func createTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "Content of the file to create",
 				},
+				"confirm_write_quota": confirmWriteQuotaParam,
 			},
 			Required: []string{"path", "file_text"},
 		},
@@ -246,7 +355,8 @@ const (
 func bashTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_bash",
-		Description: "Execute a bash command on the remote codespace. By default, it starts a remote session, waits briefly for quick completion, and returns final output when the command exits quickly. If the command is still running, it returns partial output and a shellId for follow-up reads with remote_read_bash. Use mode 'async' for interactive or explicitly backgrounded commands. Replaces the local 'bash' tool.",
+		Annotations: mutatingHints("Run remote command", true, false, true),
+		Description: "Execute a bash command on the remote codespace. By default, it starts a remote session, waits briefly for quick completion, and returns final output when the command exits quickly. If the command is still running, it returns partial output and a shellId for follow-up reads with remote_read_bash. Use mode 'async' for interactive or explicitly backgrounded commands. Pass steps instead of command to run a sequence like an && chain with per-step status: it stops at the first failing step and reports which one failed. Replaces the local 'bash' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -255,6 +365,11 @@ func bashTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "The bash command to execute",
 				},
+				"steps": map[string]any{
+					"type":        "array",
+					"description": fmt.Sprintf("Alternative to command: up to %d commands run in order in one shell (cd and export carry over), stopping at the first failure. Each step's status, duration, and output are reported separately. mode, pty, and shellId do not apply.", maxBashSteps),
+					"items":       map[string]any{"type": "string"},
+				},
 				"description": map[string]any{
 					"type":        "string",
 					"description": "A short description of what this command does",
@@ -276,23 +391,72 @@ func bashTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "Optional working directory for this call. Pass it explicitly for parallel-safe remote_bash usage instead of relying on remote_cd ordering.",
 				},
+				"pty": map[string]any{
+					"type":        "boolean",
+					"description": "Sync mode only. true guarantees a TTY for commands that check isatty (docker, installers): a tmux pane, or ssh -tt if tmux is unavailable. false skips tmux and runs directly over ssh with no TTY and separate stderr. Omit for the default (tmux when available).",
+				},
+				"shell": map[string]any{
+					"type":        "string",
+					"description": "Interpreter for command (default: bash). Use zsh, fish, or sh for scripts written for them; the call fails if the shell is not installed on the codespace. Does not apply to steps.",
+					"enum":        remoteShells,
+				},
+				"jq": map[string]any{
+					"type":        "string",
+					"description": "Optional jq filter applied on the codespace to the command's stdout, e.g. '.items[] | {name: .metadata.name, phase: .status.phase}'. Use it for large JSON from kubectl -o json, gh api, or curl, so only the fields you need come back. jq is installed with mise if missing. Does not apply to steps.",
+				},
+				"confirm_discard": map[string]any{
+					"type":        "boolean",
+					"description": "Run a git command that discards uncommitted changes (reset --hard, checkout ., restore, clean -f) even though the working tree is dirty. Only needed when a previous call was refused with policy_denied, and only after the user agrees.",
+				},
+				"no_cache": map[string]any{
+					"type":        "boolean",
+					"description": fmt.Sprintf("Re-run the command even if it is a read-only probe (git status, ls, cat, ...) whose result from the last %d seconds is cached. The cache is cleared by any mutating tool call.", int(bashCacheTTL.Seconds())),
+				},
 			},
-			Required: []string{"command"},
 		},
 	}
 }
 
 func bashHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return bashHandlerWithStatus(reg, nil)
+}
+
+func bashHandlerWithStatus(reg *registry.Registry, status *statusRecorder) server.ToolHandlerFunc {
 	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
-		c, err := resolveExecutor(reg, req)
+		cs, err := resolveCodespace(reg, req)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
+		c := cs.Executor
+		shell, err := shellFromRequest(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		if steps, ok, err := bashSteps(req); ok {
+			if err != nil {
+				return categorizedError(errInvalidArgument, err.Error()), nil
+			}
+			if _, both := req.GetArguments()["command"]; both {
+				return categorizedError(errInvalidArgument, "pass either command or steps, not both"), nil
+			}
+			if shell != "" {
+				return categorizedError(errInvalidArgument, "steps always run in bash; pass command to use shell "+shell), nil
+			}
+			return runBashSteps(ctx, cs, steps, optionalString(req, "cwd"), status), nil
+		}
 		command, err := requiredString(req, "command")
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
 
+		// script is what runs; command is what gets reported.
+		script := command
+		if shell != "" {
+			script = shellCommand(shell, script, cs.ExecAgent)
+		}
+		if filter := optionalString(req, "jq"); filter != "" {
+			script = jqPipeline(misePathSetup(c), script, filter)
+		}
 		mode := optionalString(req, "mode")
 		shellId := optionalString(req, "shellId")
 		cwd := optionalString(req, "cwd")
@@ -301,22 +465,47 @@ func bashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		}
 
 		if mode == "async" {
-			if err := c.StartSession(ctx, shellId, command, cwd); err != nil {
+			if err := c.StartSession(ctx, shellId, script, cwd); err != nil {
 				return toolError(err.Error()), nil
 			}
+			status.start(cs.Alias, shellId, command)
 			// Wait briefly and capture initial output
 			time.Sleep(time.Duration(asyncRemoteBashInitialDelay * float64(time.Second)))
 			output, _ := c.ReadSession(ctx, shellId)
+			status.observe(shellId, output)
 			return toolSuccess(fmt.Sprintf("Started async session: %s\n\n%s", shellId, output)), nil
 		}
 
+		runDirect := func(pty bool) *mcpsdk.CallToolResult {
+			status.start(cs.Alias, "", command)
+			result, exitCode := runBashSyncFallback(ctx, c, script, cwd, pty)
+			if result.IsError {
+				status.finish("", "failed", nil)
+			} else {
+				status.finish("", "exited", &exitCode)
+			}
+			return result
+		}
+
+		_, ptySet := req.GetArguments()["pty"]
+		pty := optionalBool(req, "pty", false)
+		if ptySet && !pty {
+			return runDirect(false), nil
+		}
+
+		if tty, ok := c.(sessionTTYReporter); ok && pty && !tty.SessionsHaveTTY(ctx) {
+			return runDirect(true), nil
+		}
+
 		initialWait := optionalFloat(req, "initial_wait", defaultRemoteBashInitialWait)
-		if err := c.StartSession(ctx, shellId, command, cwd); err != nil {
-			return runBashSyncFallback(ctx, c, command, cwd), nil
+		if err := c.StartSession(ctx, shellId, script, cwd); err != nil {
+			return runDirect(pty), nil
 		}
+		status.start(cs.Alias, shellId, command)
 		time.Sleep(time.Duration(initialWait * float64(time.Second)))
 		output, err := c.ReadSession(ctx, shellId)
 		if err != nil {
+			status.finish(shellId, "stopped", nil)
 			if stopErr := c.StopSession(ctx, shellId); stopErr != nil {
 				return toolError(fmt.Sprintf("%s\n\nAdditionally, failed to stop session %s after read failure: %v", err.Error(), shellId, stopErr)), nil
 			}
@@ -324,6 +513,7 @@ func bashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		}
 
 		if sessionOutputExited(output) {
+			status.observe(shellId, output)
 			finalOutput := trimSessionExitMarker(output)
 			if err := c.StopSession(ctx, shellId); err != nil {
 				if finalOutput != "" {
@@ -338,14 +528,53 @@ func bashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 	}
 }
 
-func runBashSyncFallback(ctx context.Context, c ssh.Executor, command, cwd string) *mcpsdk.CallToolResult {
-	stdout, stderr, exitCode, err := c.RunBash(ctx, command, cwd)
+// sessionTTYReporter is implemented by executors whose sessions may run
+// without a terminal (*ssh.Client, when tmux is unavailable).
+type sessionTTYReporter interface {
+	SessionsHaveTTY(ctx context.Context) bool
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// misePather is implemented by executors that know the codespace user's home
+// (*ssh.Client).
+type misePather interface {
+	MisePathSetup() string
+}
+
+// misePathSetup returns a PATH assignment that puts mise's shims and
+// ~/.local/bin first, for scripts that use or install mise tools.
+func misePathSetup(c ssh.Executor) string {
+	if m, ok := c.(misePather); ok {
+		return m.MisePathSetup()
+	}
+	return ssh.MisePATH
+}
+
+// runBashSyncFallback runs command over a plain ssh exec instead of a tmux
+// session. With pty set, a TTY is allocated (ssh -tt).
+func runBashSyncFallback(ctx context.Context, c ssh.Executor, command, cwd string, pty bool) (*mcpsdk.CallToolResult, int) {
+	run := c.RunBash
+	if pty {
+		run = c.RunBashTTY
+	}
+	stdout, stderr, exitCode, err := run(ctx, command, cwd)
 	if err != nil {
 		errMsg := err.Error()
 		if ctx.Err() != nil {
 			errMsg += "\n\nHint: This command may have timed out. Use initial_wait parameter (e.g., initial_wait=60) or mode='async' for long-running commands."
 		}
-		return toolError(errMsg)
+		return toolError(errMsg), -1
 	}
 
 	var result strings.Builder
@@ -363,7 +592,7 @@ func runBashSyncFallback(ctx context.Context, c ssh.Executor, command, cwd strin
 		result.WriteString(fmt.Sprintf("\n[exit code: %d]", exitCode))
 	}
 
-	return toolSuccess(result.String())
+	return toolSuccess(result.String()), exitCode
 }
 
 func sessionOutputExited(output string) bool {
@@ -387,7 +616,9 @@ func trimSessionExitMarker(output string) string {
 func writeBashTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_write_bash",
-		Description: "Send input to a remote bash session on the codespace. Supports special keys: {enter}, {up}, {down}, {left}, {right}, {backspace}. Replaces the local 'write_bash' tool.",
+		Annotations: mutatingHints("Send input to remote shell", true, false, true),
+		Description: "Send input to a remote bash session on the codespace. Supports special keys: {enter}, {up}, {down}, {left}, {right}, {backspace}. Replaces the local 'write_bash' tool. " +
+			"For large text, set paste to deliver it as one bracketed paste instead of typed keys, or paste_file to paste a file that is already on the codespace.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -400,6 +631,22 @@ func writeBashTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "The input to send. Can include special keys like {enter}, {up}, {down}.",
 				},
+				"paste": map[string]any{
+					"type":        "boolean",
+					"description": "Deliver the literal text in input as a bracketed paste rather than keystrokes (default: false). Special keys are still sent as keys.",
+				},
+				"paste_file": map[string]any{
+					"type":        "string",
+					"description": "Optional file on the codespace to paste into the session before input, for multi-kilobyte text. Relative paths resolve against the current working directory.",
+				},
+				"chunk_size": map[string]any{
+					"type":        "integer",
+					"description": "Split literal text into pieces of at most this many bytes, for applications that drop long bursts of input",
+				},
+				"chunk_delay_ms": map[string]any{
+					"type":        "integer",
+					"description": "Milliseconds to wait between pieces and special keys (default: 0, everything in one round trip)",
+				},
 				"delay": map[string]any{
 					"type":        "number",
 					"description": "Seconds to wait before reading output (default: 2)",
@@ -411,6 +658,10 @@ func writeBashTool() mcpsdk.Tool {
 }
 
 func writeBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return writeBashHandlerWithStatus(reg, nil)
+}
+
+func writeBashHandlerWithStatus(reg *registry.Registry, status *statusRecorder) server.ToolHandlerFunc {
 	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
 		c, err := resolveExecutor(reg, req)
 		if err != nil {
@@ -421,9 +672,13 @@ func writeBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 			return toolError(err.Error()), nil
 		}
 
+		opts, err := writeOptions(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
 		input := optionalString(req, "input")
-		if input != "" {
-			if err := c.WriteSession(ctx, shellId, input); err != nil {
+		if input != "" || opts.PasteFile != "" {
+			if err := c.WriteSession(ctx, shellId, input, opts); err != nil {
 				return toolError(err.Error()), nil
 			}
 		}
@@ -435,6 +690,7 @@ func writeBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
+		status.observe(shellId, output)
 		return toolSuccess(output), nil
 	}
 }
@@ -444,7 +700,8 @@ func writeBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func readBashTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_read_bash",
-		Description: "Read output from a remote bash session on the codespace. Returns the last 100 lines of the session's terminal output. If a command hasn't completed, call again with a longer delay. Use exponential backoff between reads to minimize overhead. Replaces the local 'read_bash' tool.",
+		Annotations: readOnlyHints("Read remote shell output", false),
+		Description: "Read output from a remote bash session on the codespace. Returns the last 100 lines of the session's terminal output. If a command hasn't completed, call again with a longer delay, or set wait_until_idle to return once its output stops changing. Use exponential backoff between reads to minimize overhead. Replaces the local 'read_bash' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -455,7 +712,15 @@ func readBashTool() mcpsdk.Tool {
 				},
 				"delay": map[string]any{
 					"type":        "number",
-					"description": "Seconds to wait before reading output (default: 2). Use longer delays for slow commands to avoid unnecessary reads.",
+					"description": "Seconds to wait before reading output (default: 2, or 0 with wait_until_idle). Use longer delays for slow commands to avoid unnecessary reads.",
+				},
+				"wait_until_idle": map[string]any{
+					"type":        "number",
+					"description": "Keep reading until the output has not changed for this many seconds, or the command exits, then return. Use this instead of guessing a delay for builds and tests.",
+				},
+				"timeout": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Seconds to wait_until_idle before returning the output so far (default: %d, max: %d)", defaultWaitTimeout, maxWaitTimeout),
 				},
 			},
 			Required: []string{"shellId"},
@@ -464,6 +729,10 @@ func readBashTool() mcpsdk.Tool {
 }
 
 func readBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return readBashHandlerWithStatus(reg, nil)
+}
+
+func readBashHandlerWithStatus(reg *registry.Registry, status *statusRecorder) server.ToolHandlerFunc {
 	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
 		c, err := resolveExecutor(reg, req)
 		if err != nil {
@@ -474,22 +743,86 @@ func readBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 			return toolError(err.Error()), nil
 		}
 
-		delay := optionalFloat(req, "delay", 2)
+		idle := optionalFloat(req, "wait_until_idle", 0)
+		timeout := optionalFloat(req, "timeout", defaultWaitTimeout)
+		if idle < 0 {
+			return categorizedError(errInvalidArgument, "wait_until_idle must not be negative"), nil
+		}
+		if idle > 0 && (timeout <= 0 || timeout > maxWaitTimeout || idle > timeout) {
+			return categorizedError(errInvalidArgument, fmt.Sprintf("timeout must be between wait_until_idle and %d seconds", maxWaitTimeout)), nil
+		}
+
+		delay := 2.0
+		if idle > 0 {
+			delay = 0
+		}
+		delay = optionalFloat(req, "delay", delay)
 		time.Sleep(time.Duration(delay * float64(time.Second)))
 
+		if idle > 0 {
+			output, idled, err := readSessionUntilIdle(ctx, c, shellId, time.Duration(idle*float64(time.Second)), time.Duration(timeout*float64(time.Second)))
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			status.observe(shellId, output)
+			if !idled {
+				output += fmt.Sprintf("\n\n[output still changing after %gs — call remote_read_bash again to keep waiting]", timeout)
+			}
+			return toolSuccess(output), nil
+		}
+
 		output, err := c.ReadSession(ctx, shellId)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
+		status.observe(shellId, output)
 		return toolSuccess(output), nil
 	}
 }
 
+// idlePollInterval is the longest pause between reads while waiting for a
+// session's output to settle, and minIdlePollInterval the shortest, so a tiny
+// wait_until_idle does not turn into a busy loop of SSH reads.
+const (
+	idlePollInterval    = time.Second
+	minIdlePollInterval = 200 * time.Millisecond
+)
+
+// readSessionUntilIdle reads the session until its output has not changed for
+// idle, or the command has exited, and reports whether that happened before
+// timeout passed. Either way it returns the latest output.
+func readSessionUntilIdle(ctx context.Context, c ssh.Executor, shellId string, idle, timeout time.Duration) (string, bool, error) {
+	interval := max(minIdlePollInterval, min(idlePollInterval, idle/4))
+	deadline := time.Now().Add(timeout)
+	output, err := c.ReadSession(ctx, shellId)
+	changed := time.Now()
+	for err == nil {
+		if sessionOutputExited(output) || time.Since(changed) >= idle {
+			return output, true, nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return output, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		case <-time.After(min(interval, remaining)):
+		}
+		var next string
+		if next, err = c.ReadSession(ctx, shellId); err == nil && next != output {
+			output, changed = next, time.Now()
+		}
+	}
+	return "", false, err
+}
+
 // --- remote_stop_bash ---
 
 func stopBashTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_stop_bash",
+		Annotations: mutatingHints("Stop remote shell session", true, true, false),
 		Description: "Stop a remote bash session on the codespace. Replaces the local 'stop_bash' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -506,6 +839,10 @@ func stopBashTool() mcpsdk.Tool {
 }
 
 func stopBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return stopBashHandlerWithStatus(reg, nil)
+}
+
+func stopBashHandlerWithStatus(reg *registry.Registry, status *statusRecorder) server.ToolHandlerFunc {
 	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
 		c, err := resolveExecutor(reg, req)
 		if err != nil {
@@ -519,6 +856,7 @@ func stopBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		if err := c.StopSession(ctx, shellId); err != nil {
 			return toolError(err.Error()), nil
 		}
+		status.finish(shellId, "stopped", nil)
 		return toolSuccess(fmt.Sprintf("Session %s stopped.", shellId)), nil
 	}
 }
@@ -528,7 +866,9 @@ func stopBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func listBashTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_list_bash",
-		Description: "List active remote bash sessions on the codespace. Replaces the local 'list_bash' tool.",
+		Annotations: readOnlyHints("List remote shell sessions", false),
+		Description: "List active remote bash sessions on the codespace with a summary line, and for each session whether it is still running, its exit code, and its last line of output. " +
+			"Use this to triage several background jobs at once before reading individual sessions with remote_read_bash. Replaces the local 'list_bash' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -560,7 +900,9 @@ func listBashHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func grepTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_grep",
-		Description: "Search for a pattern in files on the remote codespace using ripgrep (with grep fallback). Replaces the local 'grep' tool.",
+		Annotations: readOnlyHints("Search remote files", false),
+		Description: "Search for a pattern in files on the remote codespace using ripgrep (with grep fallback). " +
+			"To narrow a previous search on a large repo, pass its result as paths_from instead of re-scanning everything. Replaces the local 'grep' tool.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -577,6 +919,19 @@ func grepTool() mcpsdk.Tool {
 					"type":        "string",
 					"description": "Glob pattern to filter files (e.g., '*.go', '*.ts')",
 				},
+				"hidden": map[string]any{
+					"type":        "boolean",
+					"description": "Also search dotfiles and dot-directories such as .github or .config (default: false; .git is always skipped)",
+				},
+				"max_depth": map[string]any{
+					"type":        "integer",
+					"description": "Descend at most this many directories below path (1 = only path's own entries)",
+				},
+				"paths_from": map[string]any{
+					"type":        "array",
+					"description": fmt.Sprintf("Search only these files: the paths returned by a previous remote_glob, or the match lines of a previous remote_grep (reduced to their files). Up to %d paths; cannot be combined with path, glob, hidden, or max_depth.", maxPathsFrom),
+					"items":       map[string]any{"type": "string"},
+				},
 				"cwd": map[string]any{
 					"type":        "string",
 					"description": "Optional working directory for this call. Pass it explicitly for parallel-safe remote_grep usage instead of relying on remote_cd ordering.",
@@ -602,9 +957,33 @@ func grepHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		glob := optionalString(req, "glob")
 		cwd := optionalString(req, "cwd")
 
-		result, err := c.Grep(ctx, pattern, path, glob, cwd)
+		opts, err := searchOptions(req)
 		if err != nil {
-			return toolError(err.Error()), nil
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		paths, narrowing, err := grepPathsFrom(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		var result string
+		if narrowing {
+			if path != "" || glob != "" || opts != (ssh.SearchOptions{}) {
+				return categorizedError(errInvalidArgument, "paths_from cannot be combined with path, glob, hidden, or max_depth"), nil
+			}
+			stdout, stderr, exitCode, err := c.RunBash(ctx, grepPathsCommand(pattern, paths), cwd)
+			if err != nil {
+				return toolError(fmt.Sprintf("grep: %v", err)), nil
+			}
+			// Exit code 1 means no matches (normal for grep/rg)
+			if exitCode > 1 {
+				return toolError(fmt.Sprintf("grep failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+			}
+			result = stdout
+		} else {
+			result, err = c.Grep(ctx, pattern, path, glob, cwd, opts)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
 		}
 		if result == "" {
 			return toolSuccess("No matches found."), nil
@@ -618,19 +997,30 @@ func grepHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func globTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_glob",
-		Description: "Find files matching a glob pattern on the remote codespace. Replaces the local 'glob' tool.",
+		Annotations: readOnlyHints("Find remote files", false),
+		Description: "Find files matching a glob pattern on the remote codespace. Replaces the local 'glob' tool. " +
+			"Pass several patterns as an array to find files of a feature that spans file types in one call; each file is listed once.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
 				"codespace": codespaceParam,
 				"pattern": map[string]any{
-					"type":        "string",
-					"description": "The glob pattern to match files against (e.g., '*.go', '**/*.ts')",
+					"type":        []string{"string", "array"},
+					"description": fmt.Sprintf("The glob pattern to match files against (e.g., '*.go', '**/*.ts'), or an array of up to %d patterns to match any of (e.g., ['**/*.go', '**/*.proto']). Patterns in an array cannot use {a,b} braces or commas; list the alternatives as separate patterns.", maxGlobPatterns),
+					"items":       map[string]any{"type": "string"},
 				},
 				"path": map[string]any{
 					"type":        "string",
 					"description": "Directory to search in (defaults to '.' within cwd)",
 				},
+				"hidden": map[string]any{
+					"type":        "boolean",
+					"description": "Also match dotfiles and dot-directories such as .github or .config (default: false; .git is always skipped)",
+				},
+				"max_depth": map[string]any{
+					"type":        "integer",
+					"description": "Descend at most this many directories below path (1 = only path's own entries)",
+				},
 				"cwd": map[string]any{
 					"type":        "string",
 					"description": "Optional working directory for this call. Pass it explicitly for parallel-safe remote_glob usage instead of relying on remote_cd ordering.",
@@ -647,15 +1037,19 @@ func globHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-		pattern, err := requiredString(req, "pattern")
+		patterns, err := globPatterns(req)
 		if err != nil {
-			return toolError(err.Error()), nil
+			return categorizedError(errInvalidArgument, err.Error()), nil
 		}
 
 		path := optionalString(req, "path")
 		cwd := optionalString(req, "cwd")
+		opts, err := searchOptions(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
 
-		result, err := c.Glob(ctx, pattern, path, cwd)
+		result, err := c.Glob(ctx, patterns, path, cwd, opts)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
@@ -668,6 +1062,82 @@ func globHandler(reg *registry.Registry) server.ToolHandlerFunc {
 
 // --- helpers ---
 
+// maxGlobPatterns caps the patterns one remote_glob call matches at once.
+const maxGlobPatterns = 20
+
+// globPatterns reads remote_glob's pattern: a string, or an array of patterns
+// with duplicates dropped. fd matches an array as one {a,b} glob, so its
+// patterns cannot contain braces or commas themselves.
+func globPatterns(req mcpsdk.CallToolRequest) ([]string, error) {
+	raw, ok := req.GetArguments()["pattern"]
+	if !ok {
+		return nil, fmt.Errorf("missing required parameter: pattern")
+	}
+	switch v := raw.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		var patterns []string
+		seen := make(map[string]bool)
+		for i, item := range v {
+			p, isString := item.(string)
+			if !isString || p == "" {
+				return nil, fmt.Errorf("pattern[%d] must be a non-empty string", i)
+			}
+			if len(v) > 1 && strings.ContainsAny(p, "{},") {
+				return nil, fmt.Errorf("pattern[%d] %q uses braces or commas, which an array of patterns cannot; list the alternatives as separate patterns", i, p)
+			}
+			if !seen[p] {
+				seen[p] = true
+				patterns = append(patterns, p)
+			}
+		}
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("pattern array is empty")
+		}
+		if len(patterns) > maxGlobPatterns {
+			return nil, fmt.Errorf("pattern has %d patterns (max %d)", len(patterns), maxGlobPatterns)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("pattern must be a string or an array of strings")
+	}
+}
+
+// searchOptions reads the hidden and max_depth parameters of remote_grep and
+// remote_glob.
+func searchOptions(req mcpsdk.CallToolRequest) (ssh.SearchOptions, error) {
+	opts := ssh.SearchOptions{Hidden: optionalBool(req, "hidden", false)}
+	if raw, ok := req.GetArguments()["max_depth"]; ok {
+		depth, isInt := toInt(raw)
+		if !isInt || depth < 1 {
+			return ssh.SearchOptions{}, fmt.Errorf("max_depth must be a positive integer")
+		}
+		opts.MaxDepth = depth
+	}
+	return opts, nil
+}
+
+// writeOptions reads remote_write_bash's delivery parameters.
+func writeOptions(req mcpsdk.CallToolRequest) (ssh.WriteOptions, error) {
+	opts := ssh.WriteOptions{Paste: optionalBool(req, "paste", false), PasteFile: optionalString(req, "paste_file")}
+	if raw, ok := req.GetArguments()["chunk_size"]; ok {
+		size, isInt := toInt(raw)
+		if !isInt || size < 1 {
+			return ssh.WriteOptions{}, fmt.Errorf("chunk_size must be a positive integer")
+		}
+		opts.ChunkSize = size
+	}
+	if raw, ok := req.GetArguments()["chunk_delay_ms"]; ok {
+		ms, isInt := toInt(raw)
+		if !isInt || ms < 0 {
+			return ssh.WriteOptions{}, fmt.Errorf("chunk_delay_ms must be a non-negative integer")
+		}
+		opts.ChunkDelay = time.Duration(ms) * time.Millisecond
+	}
+	return opts, nil
+}
+
 func requiredString(req mcpsdk.CallToolRequest, key string) (string, error) {
 	args := req.GetArguments()
 	val, ok := args[key]
@@ -704,6 +1174,19 @@ func optionalFloat(req mcpsdk.CallToolRequest, key string, defaultVal float64) f
 	return f
 }
 
+func optionalBool(req mcpsdk.CallToolRequest, key string, defaultVal bool) bool {
+	args := req.GetArguments()
+	val, ok := args[key]
+	if !ok {
+		return defaultVal
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return defaultVal
+	}
+	return b
+}
+
 func toInt(v any) (int, bool) {
 	switch n := v.(type) {
 	case float64:
@@ -725,16 +1208,9 @@ func toolSuccess(text string) *mcpsdk.CallToolResult {
 	}
 }
 
+// toolError builds an error result, inferring its category from the message.
 func toolError(text string) *mcpsdk.CallToolResult {
-	return &mcpsdk.CallToolResult{
-		IsError: true,
-		Content: []mcpsdk.Content{
-			mcpsdk.TextContent{
-				Type: "text",
-				Text: text,
-			},
-		},
-	}
+	return categorizedError(classifyError(text), text)
 }
 
 // --- remote_cd ---
@@ -742,6 +1218,7 @@ func toolError(text string) *mcpsdk.CallToolResult {
 func cdTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_cd",
+		Annotations: mutatingHints("Change remote working directory", false, true, false),
 		Description: "Change the default working directory on the remote codespace for later sequential remote_bash, remote_grep, and remote_glob calls that omit cwd. For parallel calls, pass cwd explicitly instead of relying on remote_cd ordering. The directory must exist on the codespace.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -772,10 +1249,10 @@ func cdHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		quoted := "'" + strings.ReplaceAll(path, "'", "'\"'\"'") + "'"
 		stdout, _, exitCode, execErr := c.RunBash(ctx, fmt.Sprintf("cd %s && pwd", quoted), c.GetWorkdir())
 		if execErr != nil {
-			return toolError(fmt.Sprintf("failed to change directory: %v", execErr)), nil
+			return categorizedError(errConnection, fmt.Sprintf("failed to change directory: %v", execErr)), nil
 		}
 		if exitCode != 0 {
-			return toolError(fmt.Sprintf("directory does not exist: %s", path)), nil
+			return categorizedError(errNotFound, fmt.Sprintf("directory does not exist: %s", path)), nil
 		}
 
 		resolved := strings.TrimSpace(stdout)
@@ -792,6 +1269,7 @@ func cdHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func cwdTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "remote_cwd",
+		Annotations: readOnlyHints("Show remote working directory", false),
 		Description: "Get the current default working directory used by remote_bash, remote_grep, and remote_glob when cwd is not provided.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -817,6 +1295,7 @@ func cwdHandler(reg *registry.Registry) server.ToolHandlerFunc {
 func openShellTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "open_shell",
+		Annotations: mutatingHints("Open interactive shell", false, false, false),
 		Description: "Open an interactive SSH shell to the codespace in a new terminal tab/window. Use this when the user asks for a shell, terminal, or SSH access to the codespace.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type: "object",
@@ -836,7 +1315,7 @@ func openShellHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		}
 		codespaceName := cs.Name
 
-		sshCmd := fmt.Sprintf("gh codespace ssh -c %s", codespaceName)
+		sshCmd := ssh.GHSSHCommand(codespaceName)
 
 		if err := openTerminalTab(sshCmd, "codespace: "+codespaceName); err != nil {
 			return toolError(fmt.Sprintf("Failed to open shell: %v", err)), nil
@@ -956,6 +1435,7 @@ end tell`, escaped)
 func listCodespacesTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "list_codespaces",
+		Annotations: readOnlyHints("List connected codespaces", false),
 		Description: "List codespaces that are currently connected in this session, with their aliases, repositories, branches, and working directories.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type:       "object",
@@ -972,14 +1452,18 @@ func listCodespacesHandler(reg *registry.Registry) server.ToolHandlerFunc {
 		}
 
 		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("%-12s %-30s %-20s %s\n", "Alias", "Repository", "Branch", "Workdir"))
-		sb.WriteString(strings.Repeat("-", 80) + "\n")
+		sb.WriteString(fmt.Sprintf("%-12s %-30s %-20s %-30s %s\n", "Alias", "Repository", "Branch", "Workdir", "Machine"))
+		sb.WriteString(strings.Repeat("-", 110) + "\n")
 		for _, cs := range all {
 			branch := cs.Branch
 			if branch == "" {
 				branch = "(unknown)"
 			}
-			sb.WriteString(fmt.Sprintf("%-12s %-30s %-20s %s\n", cs.Alias, cs.Repository, branch, cs.Workdir))
+			machine := MachineSummary(cs.CPUs, cs.MemoryBytes)
+			if machine == "" {
+				machine = "(unknown)"
+			}
+			sb.WriteString(fmt.Sprintf("%-12s %-30s %-20s %-30s %s\n", cs.Alias, registry.RepositoryLabel(cs.Repository), branch, cs.Workdir, machine))
 		}
 		return toolSuccess(sb.String()), nil
 	}
@@ -987,9 +1471,14 @@ func listCodespacesHandler(reg *registry.Registry) server.ToolHandlerFunc {
 
 // --- list_available_codespaces ---
 
+// codespaceListLimit is high enough that gh codespace list, which pages
+// through the API itself, returns every codespace.
+const codespaceListLimit = "10000"
+
 func listAvailableCodespacesTool() mcpsdk.Tool {
 	return mcpsdk.Tool{
 		Name:        "list_available_codespaces",
+		Annotations: readOnlyHints("List available codespaces", true),
 		Description: "List all GitHub Codespaces available to connect to (runs gh codespace list locally). Use this to discover codespaces before connecting with connect_codespace.",
 		InputSchema: mcpsdk.ToolInputSchema{
 			Type:       "object",
@@ -1009,7 +1498,7 @@ func listAvailableCodespacesHandlerWithState(state *lifecycleState) server.ToolH
 	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
 		output, err := state.cfg.GHRunner.Run(ctx, "codespace", "list",
 			"--json", "name,displayName,repository,state",
-			"--limit", "50")
+			"--limit", codespaceListLimit)
 		if err != nil {
 			return toolError(fmt.Sprintf("failed to list codespaces: %v", err)), nil
 		}
@@ -1042,7 +1531,7 @@ func listAvailableCodespacesHandlerWithState(state *lifecycleState) server.ToolH
 		sb.WriteString(fmt.Sprintf("%-45s %-30s %-12s %s\n", "Name", "Repository", "State", "Display Name"))
 		sb.WriteString(strings.Repeat("-", 100) + "\n")
 		for _, cs := range codespaces {
-			sb.WriteString(fmt.Sprintf("%-45s %-30s %-12s %s\n", cs.Name, cs.Repository, cs.State, cs.DisplayName))
+			sb.WriteString(fmt.Sprintf("%-45s %-30s %-12s %s\n", cs.Name, registry.RepositoryLabel(cs.Repository), cs.State, cs.DisplayName))
 		}
 		sb.WriteString("\nConnect with: connect_codespace(name=\"<codespace-name>\")")
 		return toolSuccess(sb.String()), nil
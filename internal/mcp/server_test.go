@@ -3,10 +3,13 @@ package mcp
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
 	mcpsdk "github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -196,8 +199,8 @@ func TestToolError(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected TextContent, got %T", result.Content[0])
 	}
-	if tc.Text != "fail" {
-		t.Errorf("got text %q, want %q", tc.Text, "fail")
+	if tc.Text != "[error:internal] fail" {
+		t.Errorf("got text %q, want %q", tc.Text, "[error:internal] fail")
 	}
 }
 
@@ -222,6 +225,7 @@ type mockExecutor struct {
 	editFileErr         error
 	createFileErr       error
 	runBashCalls        int
+	runBashTTYCalls     int
 	lastRunBashCommand  string
 	lastRunBashCwd      string
 	runBashStdout       string
@@ -234,11 +238,14 @@ type mockExecutor struct {
 	lastGrepCwd         string
 	grepResult          string
 	grepErr             error
-	lastGlobPattern     string
+	lastGlobPatterns    []string
 	lastGlobPath        string
 	lastGlobCwd         string
 	globResult          string
 	globErr             error
+	lastSearchOptions   ssh.SearchOptions
+	lastWriteInput      string
+	lastWriteOptions    ssh.WriteOptions
 	startSessionCalls   int
 	lastSessionID       string
 	lastCommand         string
@@ -275,7 +282,15 @@ func (m *mockExecutor) RunBash(_ context.Context, command, cwd string) (string,
 	return m.runBashStdout, m.runBashStderr, m.runBashExit, m.runBashErr
 }
 
-func (m *mockExecutor) Grep(_ context.Context, pattern, path, glob, cwd string) (string, error) {
+func (m *mockExecutor) RunBashTTY(_ context.Context, command, cwd string) (string, string, int, error) {
+	m.runBashTTYCalls++
+	m.lastRunBashCommand = command
+	m.lastRunBashCwd = cwd
+	return m.runBashStdout, m.runBashStderr, m.runBashExit, m.runBashErr
+}
+
+func (m *mockExecutor) Grep(_ context.Context, pattern, path, glob, cwd string, opts ssh.SearchOptions) (string, error) {
+	m.lastSearchOptions = opts
 	m.lastGrepPattern = pattern
 	m.lastGrepPath = path
 	m.lastGrepGlob = glob
@@ -283,8 +298,9 @@ func (m *mockExecutor) Grep(_ context.Context, pattern, path, glob, cwd string)
 	return m.grepResult, m.grepErr
 }
 
-func (m *mockExecutor) Glob(_ context.Context, pattern, path, cwd string) (string, error) {
-	m.lastGlobPattern = pattern
+func (m *mockExecutor) Glob(_ context.Context, patterns []string, path, cwd string, opts ssh.SearchOptions) (string, error) {
+	m.lastSearchOptions = opts
+	m.lastGlobPatterns = patterns
 	m.lastGlobPath = path
 	m.lastGlobCwd = cwd
 	return m.globResult, m.globErr
@@ -298,7 +314,9 @@ func (m *mockExecutor) StartSession(_ context.Context, sessionID, command, cwd s
 	return m.startSessionErr
 }
 
-func (m *mockExecutor) WriteSession(_ context.Context, _, _ string) error {
+func (m *mockExecutor) WriteSession(_ context.Context, _, input string, opts ssh.WriteOptions) error {
+	m.lastWriteInput = input
+	m.lastWriteOptions = opts
 	return m.writeSessionErr
 }
 
@@ -355,6 +373,19 @@ func testReg(mock *mockExecutor) *registry.Registry {
 	return reg
 }
 
+// testRegWithWorkdir is testReg with the codespace's workdir set, for tools
+// that resolve or confine paths against it.
+func testRegWithWorkdir(mock *mockExecutor, workdir string) *registry.Registry {
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{
+		Alias:    "test",
+		Name:     "test-cs",
+		Workdir:  workdir,
+		Executor: mock,
+	})
+	return reg
+}
+
 // --- Handler Tests ---
 
 func TestViewHandler(t *testing.T) {
@@ -768,8 +799,146 @@ func TestGlobHandler_PassesExplicitCwd(t *testing.T) {
 	if res.IsError {
 		t.Fatalf("expected success, got tool error: %s", resultText(res))
 	}
-	if mock.lastGlobPattern != "**/*.go" || mock.lastGlobPath != "pkg" || mock.lastGlobCwd != "/workspaces/repo" {
-		t.Fatalf("glob args = pattern:%q path:%q cwd:%q", mock.lastGlobPattern, mock.lastGlobPath, mock.lastGlobCwd)
+	if !reflect.DeepEqual(mock.lastGlobPatterns, []string{"**/*.go"}) || mock.lastGlobPath != "pkg" || mock.lastGlobCwd != "/workspaces/repo" {
+		t.Fatalf("glob args = patterns:%q path:%q cwd:%q", mock.lastGlobPatterns, mock.lastGlobPath, mock.lastGlobCwd)
+	}
+}
+
+func TestGlobHandler_PatternArray(t *testing.T) {
+	mock := &mockExecutor{globResult: "api/user.go\napi/user.proto\n"}
+	handler := globHandler(testReg(mock))
+
+	res, _ := handler(context.Background(), makeReq(map[string]any{
+		"pattern": []any{"**/*.go", "**/*.proto", "**/*.go"},
+	}))
+	if res.IsError {
+		t.Fatalf("expected success, got tool error: %s", resultText(res))
+	}
+	if want := []string{"**/*.go", "**/*.proto"}; !reflect.DeepEqual(mock.lastGlobPatterns, want) {
+		t.Fatalf("patterns = %q, want %q", mock.lastGlobPatterns, want)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		pattern any
+		want    string
+	}{
+		{"empty array", []any{}, "pattern array is empty"},
+		{"non-string", []any{"*.go", 3.0}, "pattern[1] must be a non-empty string"},
+		{"braces", []any{"*.go", "*.{ts,tsx}"}, "pattern[1]"},
+		{"wrong type", 3.0, "must be a string or an array"},
+	} {
+		res, _ := handler(context.Background(), makeReq(map[string]any{"pattern": tt.pattern}))
+		if text := resultText(res); !res.IsError || !strings.Contains(text, "invalid_argument") || !strings.Contains(text, tt.want) {
+			t.Errorf("%s: got %q, want invalid_argument containing %q", tt.name, text, tt.want)
+		}
+	}
+
+	// A single pattern may still use braces.
+	res, _ = handler(context.Background(), makeReq(map[string]any{"pattern": []any{"**/*.{ts,tsx}"}}))
+	if res.IsError {
+		t.Fatalf("single braced pattern: %s", resultText(res))
+	}
+}
+
+func TestSearchHandlers_PassSearchOptions(t *testing.T) {
+	args := map[string]any{"pattern": "*.yml", "path": ".github", "hidden": true, "max_depth": float64(2)}
+	want := ssh.SearchOptions{Hidden: true, MaxDepth: 2}
+
+	mock := &mockExecutor{grepResult: ".github/workflows/ci.yml:1:name: ci\n"}
+	if res, _ := grepHandler(testReg(mock))(context.Background(), makeReq(args)); res.IsError {
+		t.Fatalf("grep: %s", resultText(res))
+	}
+	if mock.lastSearchOptions != want {
+		t.Errorf("grep options = %+v, want %+v", mock.lastSearchOptions, want)
+	}
+
+	mock = &mockExecutor{globResult: ".github/workflows/ci.yml\n"}
+	if res, _ := globHandler(testReg(mock))(context.Background(), makeReq(args)); res.IsError {
+		t.Fatalf("glob: %s", resultText(res))
+	}
+	if mock.lastSearchOptions != want {
+		t.Errorf("glob options = %+v, want %+v", mock.lastSearchOptions, want)
+	}
+
+	for _, depth := range []any{float64(0), "2"} {
+		res, _ := globHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(map[string]any{"pattern": "*", "max_depth": depth}))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("max_depth %v = %q", depth, resultText(res))
+		}
+	}
+}
+
+func TestWriteBashHandler_PassesWriteOptions(t *testing.T) {
+	mock := &mockExecutor{readSessionResults: []string{"ok"}}
+	args := map[string]any{"shellId": "s1", "input": "{enter}", "paste": true, "paste_file": "big.txt", "chunk_size": float64(512), "chunk_delay_ms": float64(20), "delay": float64(0)}
+	if res, _ := writeBashHandler(testReg(mock))(context.Background(), makeReq(args)); res.IsError {
+		t.Fatalf("write: %s", resultText(res))
+	}
+	want := ssh.WriteOptions{Paste: true, PasteFile: "big.txt", ChunkSize: 512, ChunkDelay: 20 * time.Millisecond}
+	if mock.lastWriteOptions != want || mock.lastWriteInput != "{enter}" {
+		t.Errorf("write = %q with %+v, want %+v", mock.lastWriteInput, mock.lastWriteOptions, want)
+	}
+
+	for _, bad := range []map[string]any{{"chunk_size": float64(0)}, {"chunk_delay_ms": float64(-1)}} {
+		bad["shellId"] = "s1"
+		res, _ := writeBashHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(bad))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("%v = %q", bad, resultText(res))
+		}
+	}
+}
+
+func TestReadBashHandler_WaitUntilIdle(t *testing.T) {
+	read := func(mock *mockExecutor, args map[string]any) string {
+		t.Helper()
+		args["shellId"] = "s1"
+		res, _ := readBashHandler(testReg(mock))(context.Background(), makeReq(args))
+		if res.IsError {
+			t.Fatalf("read %v: %s", args, resultText(res))
+		}
+		return resultText(res)
+	}
+
+	mock := &mockExecutor{readSessionResults: []string{"compiling", "compiling\nlinking"}, readSessionResult: "compiling\nlinking\nwaiting for input"}
+	if got := read(mock, map[string]any{"wait_until_idle": 0.05}); got != "compiling\nlinking\nwaiting for input" {
+		t.Errorf("idle output = %q", got)
+	}
+	if mock.readSessionCalls < 4 {
+		t.Errorf("read %d times, want reads until the output settled", mock.readSessionCalls)
+	}
+
+	mock = &mockExecutor{readSessionResults: []string{"testing"}, readSessionResult: "ok\n[session exited]"}
+	start := time.Now()
+	if got := read(mock, map[string]any{"wait_until_idle": float64(30)}); got != "ok\n[session exited]" {
+		t.Errorf("exited output = %q", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s after the command exited", elapsed)
+	}
+
+	var changing []string
+	for i := range 100 {
+		changing = append(changing, fmt.Sprintf("line %d", i))
+	}
+	mock = &mockExecutor{readSessionResults: changing}
+	if got := read(mock, map[string]any{"wait_until_idle": 0.1, "timeout": 0.2}); !strings.Contains(got, "[output still changing after 0.2s") {
+		t.Errorf("timed out output = %q", got)
+	}
+
+	// A short idle window still polls at the minimum interval.
+	mock = &mockExecutor{readSessionResults: changing}
+	read(mock, map[string]any{"wait_until_idle": 0.1, "timeout": 0.5})
+	if limit := int(500*time.Millisecond/minIdlePollInterval) + 2; mock.readSessionCalls > limit {
+		t.Errorf("read %d times in 0.5s, want at most %d", mock.readSessionCalls, limit)
+	}
+
+	for _, bad := range []map[string]any{{"wait_until_idle": float64(-1)}, {"wait_until_idle": float64(5), "timeout": float64(2)}, {"wait_until_idle": float64(5), "timeout": float64(601)}} {
+		bad["shellId"] = "s1"
+		res, _ := readBashHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(bad))
+		if !res.IsError || !strings.HasPrefix(resultText(res), "[error:invalid_argument]") {
+			t.Errorf("%v = %q", bad, resultText(res))
+		}
 	}
 }
 
@@ -824,8 +993,8 @@ func TestListBashHandler(t *testing.T) {
 	}{
 		{
 			name:     "success with sessions",
-			mock:     &mockExecutor{listSessionsResult: "copilot-s1 123 456\n"},
-			wantText: "copilot-s1",
+			mock:     &mockExecutor{listSessionsResult: "1 session(s): 0 running, 1 exited (1 failed)\n\ns1: exited (code 2)\n  last line: boom"},
+			wantText: "s1: exited (code 2)",
 		},
 		{
 			name:     "empty returns no active",
@@ -1034,3 +1203,129 @@ func TestResolveExecutor_MultiCS_WithAlias(t *testing.T) {
 		t.Errorf("expected result from codespace b, got %q", resultText(res))
 	}
 }
+
+func TestToolNames_ReadOnlyOmitsMutatingTools(t *testing.T) {
+	all := ToolNames(LifecycleConfig{GHRunner: &mockGHRunner{}, RemoteTask: true})
+	readOnly := ToolNames(LifecycleConfig{GHRunner: &mockGHRunner{}, RemoteTask: true, ReadOnly: true})
+
+	has := func(names []string, want string) bool {
+		for _, n := range names {
+			if n == want {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range mutatingTools {
+		if !has(all, name) {
+			t.Errorf("default tool list missing %q", name)
+		}
+		if has(readOnly, name) {
+			t.Errorf("read-only tool list should not include %q", name)
+		}
+	}
+	for _, name := range []string{"remote_view", "remote_grep", "remote_bash", "connect_codespace"} {
+		if !has(readOnly, name) {
+			t.Errorf("read-only tool list missing %q", name)
+		}
+	}
+	if len(all)-len(readOnly) != len(mutatingTools) {
+		t.Errorf("len(all)=%d len(readOnly)=%d, want difference %d", len(all), len(readOnly), len(mutatingTools))
+	}
+}
+
+func TestToolAnnotations(t *testing.T) {
+	tools := NewServer(registry.New(), LifecycleConfig{GHRunner: &mockGHRunner{}}).ListTools()
+	mutating := make(map[string]bool, len(mutatingTools))
+	for _, name := range mutatingTools {
+		mutating[name] = true
+	}
+	for name, st := range tools {
+		a := st.Tool.Annotations
+		if a.Title == "" || a.ReadOnlyHint == nil || a.DestructiveHint == nil || a.IdempotentHint == nil || a.OpenWorldHint == nil {
+			t.Errorf("%s: incomplete annotations %+v", name, a)
+			continue
+		}
+		if *a.ReadOnlyHint && *a.DestructiveHint {
+			t.Errorf("%s: read-only tool marked destructive", name)
+		}
+		if mutating[name] && *a.ReadOnlyHint {
+			t.Errorf("%s: withheld in read-only sessions but annotated read-only", name)
+		}
+	}
+	for name, wantReadOnly := range map[string]bool{"remote_view": true, "remote_grep": true, "remote_edit": false, "remote_bash": false} {
+		if got := *tools[name].Tool.Annotations.ReadOnlyHint; got != wantReadOnly {
+			t.Errorf("%s: readOnlyHint = %v, want %v", name, got, wantReadOnly)
+		}
+	}
+	if !*tools["delete_codespace"].Tool.Annotations.DestructiveHint {
+		t.Error("delete_codespace should be destructive")
+	}
+}
+
+func TestBashHandler_PTY(t *testing.T) {
+	tests := []struct {
+		name         string
+		pty          any
+		startErr     error
+		wantRunBash  int
+		wantRunTTY   int
+		wantSessions bool
+	}{
+		{name: "pty true uses tmux when available", pty: true, wantSessions: true},
+		{name: "pty true falls back to ssh -tt", pty: true, startErr: fmt.Errorf("tmux unavailable"), wantRunTTY: 1, wantSessions: true},
+		{name: "pty false skips tmux", pty: false, wantRunBash: 1},
+		{name: "default falls back without tty", startErr: fmt.Errorf("tmux unavailable"), wantRunBash: 1, wantSessions: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockExecutor{
+				startSessionErr:   tt.startErr,
+				readSessionResult: "ok\n[session exited]",
+				runBashStdout:     "ok\n",
+			}
+			args := map[string]any{"command": "docker ps", "shellId": "p1", "initial_wait": 0.001}
+			if tt.pty != nil {
+				args["pty"] = tt.pty
+			}
+			res, err := bashHandler(testReg(mock))(context.Background(), makeReq(args))
+			if err != nil || res.IsError {
+				t.Fatalf("unexpected failure: %v %s", err, resultText(res))
+			}
+			if mock.runBashCalls != tt.wantRunBash || mock.runBashTTYCalls != tt.wantRunTTY {
+				t.Fatalf("runBashCalls=%d runBashTTYCalls=%d, want %d/%d", mock.runBashCalls, mock.runBashTTYCalls, tt.wantRunBash, tt.wantRunTTY)
+			}
+			if gotSession := mock.startSessionCalls > 0; gotSession != tt.wantSessions {
+				t.Fatalf("session started = %v, want %v", gotSession, tt.wantSessions)
+			}
+		})
+	}
+}
+
+// ttylessExecutor runs sessions without a terminal, like *ssh.Client once
+// tmux could not be provisioned.
+type ttylessExecutor struct{ *mockExecutor }
+
+func (ttylessExecutor) SessionsHaveTTY(context.Context) bool { return false }
+
+func TestBashHandler_PTYWithoutSessionTerminal(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "ok\n", readSessionResult: "ok\n[session exited]"}
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "test", Name: "test-cs", Executor: ttylessExecutor{mock}})
+
+	res, _ := bashHandler(reg)(context.Background(), makeReq(map[string]any{"command": "docker ps", "pty": true}))
+	if res.IsError {
+		t.Fatalf("unexpected failure: %s", resultText(res))
+	}
+	if mock.runBashTTYCalls != 1 || mock.startSessionCalls != 0 {
+		t.Fatalf("runBashTTYCalls=%d startSessionCalls=%d, want ssh -tt without a session", mock.runBashTTYCalls, mock.startSessionCalls)
+	}
+
+	// Without pty a session without a terminal is fine.
+	if res, _ := bashHandler(reg)(context.Background(), makeReq(map[string]any{"command": "make", "initial_wait": 0.001})); res.IsError {
+		t.Fatalf("unexpected failure: %s", resultText(res))
+	}
+	if mock.startSessionCalls != 1 {
+		t.Fatalf("startSessionCalls = %d, want 1", mock.startSessionCalls)
+	}
+}
@@ -0,0 +1,36 @@
+package mcp
+
+import (
+	"fmt"
+	"slices"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// remoteShells are the interpreters remote_bash can run a command in.
+var remoteShells = []string{"bash", "zsh", "fish", "sh"}
+
+// shellFromRequest returns the shell parameter, "" for the default bash.
+func shellFromRequest(req mcpsdk.CallToolRequest) (string, error) {
+	shell := optionalString(req, "shell")
+	if shell == "" || shell == "bash" {
+		return "", nil
+	}
+	if !slices.Contains(remoteShells, shell) {
+		return "", fmt.Errorf("shell must be one of %v", remoteShells)
+	}
+	return shell, nil
+}
+
+// shellCommand wraps script so it runs in shell rather than the bash every
+// command is started from: through the exec agent when one is deployed, which
+// sets up the codespace environment the same way as for other commands, or
+// directly otherwise. A shell that is not installed fails with exit 127 and a
+// message saying so, rather than bash misreading the script.
+func shellCommand(shell, script, execAgent string) string {
+	run := fmt.Sprintf("%s -c %s", shell, shellQuote(script))
+	if execAgent != "" {
+		run = fmt.Sprintf("%s exec -- %s", shellQuote(execAgent), run)
+	}
+	return fmt.Sprintf("{ command -v %[1]s >/dev/null 2>&1 || { echo '%[1]s is not installed on the codespace; omit shell to use bash' >&2; exit 127; }; %[2]s; }", shell, run)
+}
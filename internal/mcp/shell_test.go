@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellCommand(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	// A stand-in fish that reports the script it was given.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "fish"), []byte("#!"+bash+"\necho \"fish got: $2\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(shell string) (string, int) {
+		t.Helper()
+		cmd := exec.Command(bash, "-c", shellCommand(shell, "echo $fish_pid's'", ""))
+		cmd.Env = []string{"PATH=" + bin}
+		out, err := cmd.CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return string(out), exitErr.ExitCode()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out), 0
+	}
+
+	if out, code := run("fish"); code != 0 || out != "fish got: echo $fish_pid's'\n" {
+		t.Errorf("fish: %q (exit %d)", out, code)
+	}
+	if out, code := run("zsh"); code != 127 || !strings.Contains(out, "zsh is not installed on the codespace") {
+		t.Errorf("missing zsh: %q (exit %d), want 127", out, code)
+	}
+}
+
+func TestShellCommandUsesExecAgent(t *testing.T) {
+	got := shellCommand("zsh", "print -l *(.)", "/tmp/agent")
+	if !strings.Contains(got, "'/tmp/agent' exec -- zsh -c 'print -l *(.)'") {
+		t.Errorf("shellCommand() = %q, want it to run zsh through the exec agent", got)
+	}
+}
+
+func TestBashHandler_Shell(t *testing.T) {
+	mock := &mockExecutor{readSessionResult: "ok\n[session exited]"}
+	res, _ := bashHandler(testReg(mock))(context.Background(), makeReq(map[string]any{
+		"command":      "setopt extendedglob; print -l **/*.go",
+		"shell":        "zsh",
+		"initial_wait": 0.001,
+	}))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	if !strings.Contains(mock.lastCommand, "zsh -c 'setopt extendedglob; print -l **/*.go'") {
+		t.Errorf("command = %q, want it run by zsh", mock.lastCommand)
+	}
+
+	// bash is the default and leaves the command alone.
+	mock = &mockExecutor{readSessionResult: "ok\n[session exited]"}
+	bashHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"command": "echo hi", "shell": "bash", "initial_wait": 0.001}))
+	if mock.lastCommand != "echo hi" {
+		t.Errorf("command = %q, want it unchanged", mock.lastCommand)
+	}
+
+	// A jq filter reads the output of the command as the shell ran it.
+	mock = &mockExecutor{readSessionResult: "ok\n[session exited]"}
+	bashHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"command": "print -l *(.)", "shell": "zsh", "jq": ".", "initial_wait": 0.001}))
+	if !strings.Contains(mock.lastCommand, "zsh -c 'print -l *(.)'") || !strings.Contains(mock.lastCommand, "| jq") {
+		t.Errorf("command = %q, want zsh output piped to jq", mock.lastCommand)
+	}
+
+	for _, args := range []map[string]any{
+		{"command": "echo hi", "shell": "powershell"},
+		{"steps": []any{"echo hi"}, "shell": "fish"},
+	} {
+		mock = &mockExecutor{}
+		res, _ := bashHandler(testReg(mock))(context.Background(), makeReq(args))
+		if !res.IsError || !strings.Contains(resultText(res), string(errInvalidArgument)) {
+			t.Errorf("%v: result = %q, want invalid_argument", args, resultText(res))
+		}
+		if mock.startSessionCalls+mock.runBashCalls != 0 {
+			t.Errorf("%v: command ran", args)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// statBinaryProbeBytes is how much of a file is checked for NUL bytes before
+// counting lines.
+const statBinaryProbeBytes = 8192
+
+// statCommand prints one keyword line per fact about p: "link TARGET" for a
+// symlink, then "missing", or "stat TYPE|SIZE|MODE|PERMS|MTIME|USER|GROUP"
+// followed by "binary" or "lines N" for readable regular files.
+func statCommand(p string) string {
+	return fmt.Sprintf(`p=%s; if [ -L "$p" ]; then printf 'link %%s\n' "$(readlink -- "$p")"; fi; `+
+		`if [ ! -e "$p" ]; then echo missing; exit 0; fi; `+
+		`stat -L -c 'stat %%F|%%s|%%a|%%A|%%Y|%%U|%%G' -- "$p" || exit 1; `+
+		`if [ -f "$p" ] && [ -r "$p" ]; then `+
+		`if [ "$(head -c %d -- "$p" | tr -d -c '\000' | wc -c)" -gt 0 ]; then echo binary; else echo "lines $(wc -l < "$p")"; fi; fi`,
+		shellQuote(p), statBinaryProbeBytes)
+}
+
+// fileStat is parsed statCommand output.
+type fileStat struct {
+	exists     bool
+	linkTarget string
+	fileType   string
+	size       int64
+	mode       string
+	perms      string
+	mtime      time.Time
+	owner      string
+	group      string
+	binary     bool
+	lines      int
+	hasLines   bool
+}
+
+func parseStatOutput(out string) (fileStat, error) {
+	var st fileStat
+	sawStat := false
+	for _, line := range strings.Split(out, "\n") {
+		keyword, rest, _ := strings.Cut(line, " ")
+		switch keyword {
+		case "link":
+			st.linkTarget = rest
+		case "missing":
+			return st, nil
+		case "stat":
+			fields := strings.Split(rest, "|")
+			if len(fields) != 7 {
+				return st, fmt.Errorf("unexpected stat output: %q", line)
+			}
+			st.exists, sawStat = true, true
+			st.fileType = statFileType(fields[0])
+			st.size, _ = strconv.ParseInt(fields[1], 10, 64)
+			st.mode = fields[2]
+			if len(st.mode) < 4 {
+				st.mode = strings.Repeat("0", 4-len(st.mode)) + st.mode
+			}
+			st.perms = fields[3]
+			if secs, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+				st.mtime = time.Unix(secs, 0)
+			}
+			st.owner, st.group = fields[5], fields[6]
+		case "binary":
+			st.binary = true
+		case "lines":
+			if n, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil {
+				st.lines, st.hasLines = n, true
+			}
+		}
+	}
+	if !sawStat {
+		return st, fmt.Errorf("unexpected stat output: %q", strings.TrimSpace(out))
+	}
+	return st, nil
+}
+
+// statFileType shortens stat's %F names.
+func statFileType(f string) string {
+	switch f {
+	case "regular file", "regular empty file":
+		return "file"
+	case "symbolic link":
+		return "symlink"
+	}
+	return f
+}
+
+// formatStat renders st for the model. Times are shown in the codespace's
+// zone when known, with UTC alongside.
+func formatStat(p string, st fileStat, cs *registry.ManagedCodespace) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "path: %s\n", p)
+	if st.linkTarget != "" {
+		fmt.Fprintf(&sb, "symlink: -> %s\n", st.linkTarget)
+	}
+	if !st.exists {
+		if st.linkTarget != "" {
+			sb.WriteString("exists: false (dangling symlink)\n")
+		} else {
+			sb.WriteString("exists: false\n")
+		}
+		return strings.TrimRight(sb.String(), "\n")
+	}
+	sb.WriteString("exists: true\n")
+	fmt.Fprintf(&sb, "type: %s\n", st.fileType)
+	fmt.Fprintf(&sb, "size: %d\n", st.size)
+	fmt.Fprintf(&sb, "mode: %s (%s)\n", st.mode, st.perms)
+	if !st.mtime.IsZero() {
+		fmt.Fprintf(&sb, "mtime: %s\n", formatRemoteTime(st.mtime, cs))
+	}
+	fmt.Fprintf(&sb, "owner: %s:%s\n", st.owner, st.group)
+	switch {
+	case st.binary:
+		sb.WriteString("lines: n/a (binary)\n")
+	case st.hasLines:
+		fmt.Fprintf(&sb, "lines: %d\n", st.lines)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatRemoteTime formats t in the codespace's UTC offset, adding the UTC
+// time when the offset is not zero.
+func formatRemoteTime(t time.Time, cs *registry.ManagedCodespace) string {
+	utc := t.UTC().Format(time.RFC3339)
+	if cs.UTCOffset == 0 {
+		return utc
+	}
+	return fmt.Sprintf("%s (%s)", t.In(time.FixedZone(cs.TimeZone, cs.UTCOffset)).Format(time.RFC3339), utc)
+}
+
+// --- remote_stat ---
+
+func statTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_stat",
+		Annotations: readOnlyHints("Stat remote path", false),
+		Description: "Get metadata for a path on the remote codespace: whether it exists, type, size, mode, modification time, owner, and line count for text files. " +
+			"Use this instead of running ls -la and wc -l through remote_bash when deciding whether to view or edit a file. A missing path is reported as exists: false, not as an error.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to inspect. Relative paths resolve against cwd.",
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory for a relative path (default: current working directory)",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}
+}
+
+func statHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		p, err := requiredString(req, "path")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		cwd := optionalString(req, "cwd")
+		if cwd == "" {
+			cwd = cs.Executor.GetWorkdir()
+		}
+
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, statCommand(p), cwd)
+		if err != nil {
+			return toolError(fmt.Sprintf("failed to stat %s: %v", p, err)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("stat failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		st, err := parseStatOutput(stdout)
+		if err != nil {
+			return categorizedError(errInternal, err.Error()), nil
+		}
+
+		display := p
+		if !path.IsAbs(display) && cwd != "" {
+			display = path.Join(cwd, display)
+		}
+		return toolSuccess(formatStat(display, st, cs)), nil
+	}
+}
@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// statLocally runs statCommand with the local bash, like the codespace would.
+func statLocally(t *testing.T, p string) fileStat {
+	t.Helper()
+	out, err := exec.Command("bash", "-c", statCommand(p)).Output()
+	if err != nil {
+		t.Fatalf("running stat command: %v", err)
+	}
+	st, err := parseStatOutput(string(out))
+	if err != nil {
+		t.Fatalf("parseStatOutput() error = %v", err)
+	}
+	return st
+}
+
+func TestStatCommand(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "it's.txt")
+	if err := os.WriteFile(text, []byte("a\nb\nc\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(bin, []byte{'x', 0, 'y', '\n'}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(text, link); err != nil {
+		t.Fatal(err)
+	}
+	dangling := filepath.Join(dir, "dangling")
+	if err := os.Symlink(filepath.Join(dir, "nope"), dangling); err != nil {
+		t.Fatal(err)
+	}
+
+	st := statLocally(t, text)
+	if !st.exists || st.fileType != "file" || st.size != 6 || st.mode != "0640" || !st.hasLines || st.lines != 3 || st.binary {
+		t.Errorf("text file = %+v", st)
+	}
+	if time.Since(st.mtime) > time.Minute {
+		t.Errorf("mtime = %v, want about now", st.mtime)
+	}
+
+	if st := statLocally(t, bin); !st.binary || st.hasLines {
+		t.Errorf("binary file = %+v", st)
+	}
+	if st := statLocally(t, dir); st.fileType != "directory" || st.hasLines {
+		t.Errorf("directory = %+v", st)
+	}
+	if st := statLocally(t, link); st.linkTarget != text || st.fileType != "file" || st.lines != 3 {
+		t.Errorf("symlink = %+v", st)
+	}
+	if st := statLocally(t, dangling); st.exists || st.linkTarget == "" {
+		t.Errorf("dangling symlink = %+v", st)
+	}
+	if st := statLocally(t, filepath.Join(dir, "missing")); st.exists {
+		t.Errorf("missing file = %+v", st)
+	}
+}
+
+func TestFormatStat(t *testing.T) {
+	st := fileStat{exists: true, fileType: "file", size: 120, mode: "0644", perms: "-rw-r--r--", mtime: time.Unix(1700000000, 0), owner: "vscode", group: "vscode", lines: 7, hasLines: true}
+	cs := &registry.ManagedCodespace{TimeZone: "CET", UTCOffset: 3600}
+	got := formatStat("/workspaces/app/main.go", st, cs)
+	want := "path: /workspaces/app/main.go\nexists: true\ntype: file\nsize: 120\nmode: 0644 (-rw-r--r--)\n" +
+		"mtime: 2023-11-14T23:13:20+01:00 (2023-11-14T22:13:20Z)\nowner: vscode:vscode\nlines: 7"
+	if got != want {
+		t.Errorf("formatStat() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := formatStat("/x", fileStat{linkTarget: "/gone"}, &registry.ManagedCodespace{}); got != "path: /x\nsymlink: -> /gone\nexists: false (dangling symlink)" {
+		t.Errorf("dangling = %q", got)
+	}
+}
+
+func TestStatHandler(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "stat regular file|42|644|-rw-r--r--|1700000000|root|root\nlines 2\n"}
+	result, _ := statHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"path": "go.mod", "cwd": "/workspaces/app"}))
+	text := resultText(result)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", text)
+	}
+	if !strings.HasPrefix(text, "path: /workspaces/app/go.mod\nexists: true\ntype: file\nsize: 42\n") || !strings.Contains(text, "lines: 2") {
+		t.Errorf("result:\n%s", text)
+	}
+	if mock.lastRunBashCommand != statCommand("go.mod") || mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("command = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	mock = &mockExecutor{runBashStdout: "missing\n"}
+	result, _ = statHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"path": "/workspaces/app/nope"}))
+	if result.IsError || !strings.Contains(resultText(result), "exists: false") {
+		t.Errorf("missing path result = %q", resultText(result))
+	}
+
+	mock = &mockExecutor{runBashStderr: "stat: cannot statx: Permission denied", runBashExit: 1}
+	result, _ = statHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"path": "/root/secret"}))
+	if !result.IsError || !strings.HasPrefix(resultText(result), "[error:permission]") {
+		t.Errorf("permission result = %q", resultText(result))
+	}
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// StatusFileName is the workspace-relative path of the last-command state file.
+// Statusline hooks run from the workspace directory and can read it directly.
+const StatusFileName = ".codespace/last-command.json"
+
+// CommandStatus describes the most recent remote_bash command.
+type CommandStatus struct {
+	Codespace  string     `json:"codespace,omitempty"`
+	ShellID    string     `json:"shellId,omitempty"`
+	Command    string     `json:"command"`
+	State      string     `json:"state"` // running, exited, stopped, failed
+	ExitCode   *int       `json:"exitCode,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// statusRecorder persists the latest CommandStatus to a JSON file. A nil
+// recorder (no workspace directory) records nothing.
+type statusRecorder struct {
+	mu      sync.Mutex
+	path    string
+	current CommandStatus
+	now     func() time.Time
+}
+
+func newStatusRecorder(workspaceDir string) *statusRecorder {
+	if workspaceDir == "" {
+		return nil
+	}
+	return &statusRecorder{
+		path: filepath.Join(workspaceDir, StatusFileName),
+		now:  time.Now,
+	}
+}
+
+// start records a newly launched command as running.
+func (r *statusRecorder) start(codespace, shellID, command string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = CommandStatus{
+		Codespace: codespace,
+		ShellID:   shellID,
+		Command:   command,
+		State:     "running",
+		StartedAt: r.timestamp(),
+	}
+	r.write()
+}
+
+// finish marks the tracked command as done. Updates for a shellId other than
+// the most recent command are ignored so an old session can't clobber it.
+func (r *statusRecorder) finish(shellID, state string, exitCode *int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current.Command == "" || r.current.ShellID != shellID || r.current.State != "running" {
+		return
+	}
+	now := r.timestamp()
+	r.current.State = state
+	r.current.ExitCode = exitCode
+	r.current.FinishedAt = &now
+	r.write()
+}
+
+// observe updates the tracked command from captured session output.
+func (r *statusRecorder) observe(shellID, output string) {
+	if !sessionOutputExited(output) {
+		return
+	}
+	code := sessionExitCode(output)
+	r.finish(shellID, "exited", &code)
+}
+
+// timestamp returns the current time in UTC with whole seconds, which keeps the
+// JSON parseable by simple consumers such as jq's fromdate.
+func (r *statusRecorder) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
+
+func (r *statusRecorder) write() {
+	_ = writeStatusFile(r.path, r.current)
+}
+
+// writeStatusFile replaces path with v as indented JSON, through a temp file
+// so a statusline never reads a half-written file.
+func writeStatusFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ConnectionFileName is the workspace-relative path of the connected-codespace
+// state file rendered by the statusline subcommand.
+const ConnectionFileName = ".codespace/connection.json"
+
+// ConnectionStatus lists the codespaces connected to a session.
+type ConnectionStatus struct {
+	Codespaces []CodespaceConnection `json:"codespaces"`
+	UpdatedAt  time.Time             `json:"updatedAt"`
+}
+
+// CodespaceConnection describes one connected codespace. SSHConfig and
+// SSHHost are set when the session opened a multiplexed SSH master; without
+// them every call goes through the slower gh codespace ssh.
+type CodespaceConnection struct {
+	Alias      string `json:"alias"`
+	Name       string `json:"name"`
+	Repository string `json:"repository,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Workdir    string `json:"workdir,omitempty"`
+	SSHConfig  string `json:"sshConfig,omitempty"`
+	SSHHost    string `json:"sshHost,omitempty"`
+}
+
+// WriteConnectionStatus records the registry's codespaces in workspaceDir.
+// It is a no-op without a workspace directory.
+func WriteConnectionStatus(workspaceDir string, reg *registry.Registry) error {
+	if workspaceDir == "" {
+		return nil
+	}
+	status := ConnectionStatus{
+		Codespaces: []CodespaceConnection{},
+		UpdatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	for _, cs := range reg.All() {
+		conn := CodespaceConnection{
+			Alias:      cs.Alias,
+			Name:       cs.Name,
+			Repository: cs.Repository,
+			Branch:     cs.Branch,
+			Workdir:    cs.Workdir,
+		}
+		if mux, ok := cs.Executor.(interface {
+			SSHConfigPath() string
+			SSHHost() string
+		}); ok && mux.SSHConfigPath() != "" {
+			conn.SSHConfig = mux.SSHConfigPath()
+			conn.SSHHost = mux.SSHHost()
+		}
+		status.Codespaces = append(status.Codespaces, conn)
+	}
+	return writeStatusFile(filepath.Join(workspaceDir, ConnectionFileName), status)
+}
+
+var sessionExitCodeRe = regexp.MustCompile(`\[exit code: (-?\d+)\]`)
+
+// sessionExitCode extracts the exit code ReadSession appends after a pane dies.
+// ReadSession omits the marker for a zero exit, so absence means success.
+func sessionExitCode(output string) int {
+	m := sessionExitCodeRe.FindAllStringSubmatch(output, -1)
+	if len(m) == 0 {
+		return 0
+	}
+	code, err := strconv.Atoi(m[len(m)-1][1])
+	if err != nil {
+		return 0
+	}
+	return code
+}
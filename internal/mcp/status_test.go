@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+func readStatusFile(t *testing.T, dir string) CommandStatus {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, StatusFileName))
+	if err != nil {
+		t.Fatalf("reading status file: %v", err)
+	}
+	var st CommandStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatalf("parsing status file: %v", err)
+	}
+	return st
+}
+
+func TestStatusRecorder_Lifecycle(t *testing.T) {
+	dir := t.TempDir()
+	r := newStatusRecorder(dir)
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+
+	r.start("app", "sh-1", "npm test")
+	st := readStatusFile(t, dir)
+	if st.State != "running" || st.Command != "npm test" || st.Codespace != "app" || st.ShellID != "sh-1" {
+		t.Fatalf("status after start = %+v", st)
+	}
+
+	// Output from an unrelated session must not clobber the tracked command.
+	r.observe("sh-other", "done\n[session exited]\n[exit code: 9]")
+	if st := readStatusFile(t, dir); st.State != "running" {
+		t.Fatalf("state = %q after unrelated session exit, want running", st.State)
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	r.observe("sh-1", "FAIL\n[session exited]\n[exit code: 1]")
+	st = readStatusFile(t, dir)
+	if st.State != "exited" || st.ExitCode == nil || *st.ExitCode != 1 {
+		t.Fatalf("status after exit = %+v", st)
+	}
+	if st.FinishedAt == nil || st.FinishedAt.Sub(st.StartedAt) != 2*time.Minute {
+		t.Fatalf("finishedAt = %v, startedAt = %v", st.FinishedAt, st.StartedAt)
+	}
+}
+
+func TestStatusRecorder_NilIsNoop(t *testing.T) {
+	var r *statusRecorder
+	r.start("a", "b", "c")
+	r.finish("b", "exited", nil)
+	r.observe("b", "[session exited]")
+	if newStatusRecorder("") != nil {
+		t.Fatal("expected nil recorder without workspace dir")
+	}
+}
+
+func TestSessionExitCode(t *testing.T) {
+	tests := []struct {
+		output string
+		want   int
+	}{
+		{"ok\n[session exited]", 0},
+		{"boom\n[session exited]\n[exit code: 2]", 2},
+		{"[exit code: 1]\nmore\n[session exited]\n[exit code: 127]", 127},
+	}
+	for _, tt := range tests {
+		if got := sessionExitCode(tt.output); got != tt.want {
+			t.Errorf("sessionExitCode(%q) = %d, want %d", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestBashHandler_RecordsLastCommand(t *testing.T) {
+	dir := t.TempDir()
+	status := newStatusRecorder(dir)
+	mock := &mockExecutor{readSessionResult: "done\n[session exited]"}
+
+	handler := bashHandlerWithStatus(testReg(mock), status)
+	res, err := handler(context.Background(), makeReq(map[string]any{
+		"command":      "make build",
+		"shellId":      "s-build",
+		"initial_wait": 0.001,
+	}))
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected failure: %v %s", err, resultText(res))
+	}
+
+	st := readStatusFile(t, dir)
+	if st.Command != "make build" || st.State != "exited" || st.Codespace != "test" {
+		t.Fatalf("status = %+v", st)
+	}
+	if st.ExitCode == nil || *st.ExitCode != 0 {
+		t.Fatalf("exitCode = %v, want 0", st.ExitCode)
+	}
+}
+
+// muxExecutor is a mockExecutor with a shared SSH master.
+type muxExecutor struct {
+	mockExecutor
+}
+
+func (m *muxExecutor) SSHConfigPath() string { return "/tmp/.ssh-config-app" }
+func (m *muxExecutor) SSHHost() string       { return "cs.app" }
+
+func TestWriteConnectionStatus(t *testing.T) {
+	dir := t.TempDir()
+	reg := registry.New()
+	reg.Register(&registry.ManagedCodespace{Alias: "app", Name: "app-123", Repository: "o/app", Branch: "main", Workdir: "/workspaces/app", Executor: &muxExecutor{}})
+	reg.Register(&registry.ManagedCodespace{Alias: "api", Name: "api-456", Executor: &mockExecutor{}})
+
+	if err := WriteConnectionStatus(dir, reg); err != nil {
+		t.Fatalf("WriteConnectionStatus: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ConnectionFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ConnectionStatus
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Codespaces) != 2 {
+		t.Fatalf("codespaces = %+v, want 2", st.Codespaces)
+	}
+	byAlias := map[string]CodespaceConnection{}
+	for _, c := range st.Codespaces {
+		byAlias[c.Alias] = c
+	}
+	if c := byAlias["app"]; c.SSHConfig != "/tmp/.ssh-config-app" || c.SSHHost != "cs.app" || c.Branch != "main" {
+		t.Errorf("app connection = %+v", c)
+	}
+	if c := byAlias["api"]; c.SSHConfig != "" {
+		t.Errorf("api without a master should have no SSH config: %+v", c)
+	}
+
+	reg.Deregister("app")
+	reg.Deregister("api")
+	if err := WriteConnectionStatus(dir, reg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, ConnectionFileName))
+	if !strings.Contains(string(data), `"codespaces": []`) {
+		t.Errorf("empty registry should write an empty list:\n%s", data)
+	}
+
+	if err := WriteConnectionStatus("", reg); err != nil {
+		t.Errorf("no workspace dir should be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxBashSteps bounds one remote_bash steps call.
+const maxBashSteps = 50
+
+// stepMarker starts the lines StepsScript prints around each step. The ASCII
+// record separator does not occur in normal command output.
+const stepMarker = "\x1e"
+
+// StepsScript returns a bash script that runs steps in order in one shell, so
+// cd and export carry over like in an && chain, and stops at the first step
+// that fails. Each step's output (stderr merged into stdout) is preceded by a
+// "start N" marker line and followed by an "end N EXIT MS" marker line.
+func StepsScript(steps []string) string {
+	var sb strings.Builder
+	sb.WriteString(`__step() { printf '\036start %d\n' "$1"; local __t0=$(date +%s%N); eval "$2" 2>&1; local __rc=$?; ` +
+		`printf '\n\036end %d %d %d\n' "$1" "$__rc" $(( ($(date +%s%N) - __t0) / 1000000 )); return $__rc; }`)
+	for i, step := range steps {
+		if i == 0 {
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(" && ")
+		}
+		fmt.Fprintf(&sb, "__step %d %s", i+1, shellQuote(step))
+	}
+	return sb.String()
+}
+
+// EncodeSteps and DecodeSteps pass steps to the exec agent as one argument
+// that needs no shell escaping.
+func EncodeSteps(steps []string) string {
+	data, _ := json.Marshal(steps)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func DecodeSteps(encoded string) ([]string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding steps: %w", err)
+	}
+	var steps []string
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("decoding steps: %w", err)
+	}
+	return steps, nil
+}
+
+// stepResult is the outcome of one step. started without finished means the
+// step ended the shell itself (for example with exit).
+type stepResult struct {
+	started  bool
+	finished bool
+	exitCode int
+	duration time.Duration
+	output   string
+}
+
+// parseStepsOutput splits StepsScript output into per-step results.
+func parseStepsOutput(out string, n int) []stepResult {
+	results := make([]stepResult, n)
+	current := -1
+	var buf strings.Builder
+	flush := func() {
+		if current >= 0 && current < n {
+			results[current].output = strings.TrimRight(buf.String(), "\n")
+		}
+		buf.Reset()
+	}
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if !strings.HasPrefix(line, stepMarker) {
+			buf.WriteString(line)
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, stepMarker))
+		if len(fields) < 2 {
+			buf.WriteString(line)
+			continue
+		}
+		idx, err := strconv.Atoi(fields[1])
+		if err != nil || idx < 1 || idx > n {
+			buf.WriteString(line)
+			continue
+		}
+		switch {
+		case fields[0] == "start":
+			flush()
+			current = idx - 1
+			results[current].started = true
+		case fields[0] == "end" && len(fields) == 4:
+			flush()
+			code, _ := strconv.Atoi(fields[2])
+			ms, _ := strconv.Atoi(fields[3])
+			results[idx-1].finished = true
+			results[idx-1].exitCode = code
+			results[idx-1].duration = time.Duration(ms) * time.Millisecond
+			current = -1
+		default:
+			buf.WriteString(line)
+		}
+	}
+	flush()
+	return results
+}
+
+// formatStepsResult renders per-step status and output, and reports the step
+// that failed (1-based, 0 when every step succeeded).
+func formatStepsResult(steps []string, results []stepResult, shellExit int, stderr string) (string, int) {
+	var sb strings.Builder
+	failed := 0
+	for i, step := range steps {
+		r := results[i]
+		label := fmt.Sprintf("[step %d/%d", i+1, len(steps))
+		switch {
+		case !r.started:
+			fmt.Fprintf(&sb, "%s skipped] %s\n", label, step)
+			continue
+		case !r.finished:
+			fmt.Fprintf(&sb, "%s ✗ ended the shell with exit code %d] %s\n", label, shellExit, step)
+			if failed == 0 {
+				failed = i + 1
+			}
+		case r.exitCode != 0:
+			fmt.Fprintf(&sb, "%s ✗ exit %d, %s] %s\n", label, r.exitCode, r.duration.Round(time.Millisecond), step)
+			if failed == 0 {
+				failed = i + 1
+			}
+		default:
+			fmt.Fprintf(&sb, "%s ✓ %s] %s\n", label, r.duration.Round(time.Millisecond), step)
+		}
+		if r.output != "" {
+			sb.WriteString(r.output)
+			sb.WriteString("\n")
+		}
+	}
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		sb.WriteString("STDERR:\n")
+		sb.WriteString(stderr)
+		sb.WriteString("\n")
+	}
+	if failed > 0 {
+		code := shellExit
+		if results[failed-1].finished {
+			code = results[failed-1].exitCode
+		}
+		fmt.Fprintf(&sb, "\nStep %d of %d failed (exit code %d): %s", failed, len(steps), code, steps[failed-1])
+	} else {
+		fmt.Fprintf(&sb, "\nAll %d steps succeeded.", len(steps))
+	}
+	return sb.String(), failed
+}
+
+// bashSteps reads the steps argument. ok is false when it is absent.
+func bashSteps(req mcpsdk.CallToolRequest) (steps []string, ok bool, err error) {
+	raw, present := req.GetArguments()["steps"]
+	if !present {
+		return nil, false, nil
+	}
+	list, isList := raw.([]any)
+	if !isList || len(list) == 0 {
+		return nil, true, fmt.Errorf("steps must be a non-empty array of commands")
+	}
+	if len(list) > maxBashSteps {
+		return nil, true, fmt.Errorf("too many steps: %d (max %d)", len(list), maxBashSteps)
+	}
+	for i, item := range list {
+		s, isString := item.(string)
+		if !isString || strings.TrimSpace(s) == "" {
+			return nil, true, fmt.Errorf("steps[%d] must be a non-empty string", i)
+		}
+		steps = append(steps, s)
+	}
+	return steps, true, nil
+}
+
+// runBashSteps runs steps through the exec agent when it is deployed, or as a
+// plain script otherwise; both produce StepsScript output.
+func runBashSteps(ctx context.Context, cs *registry.ManagedCodespace, steps []string, cwd string, status *statusRecorder) *mcpsdk.CallToolResult {
+	command := StepsScript(steps)
+	if cs.ExecAgent != "" {
+		command = fmt.Sprintf("%s exec --steps %s", shellQuote(cs.ExecAgent), EncodeSteps(steps))
+	}
+	summary := strings.Join(steps, " && ")
+	status.start(cs.Alias, "", summary)
+	stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, command, cwd)
+	if err != nil {
+		status.finish("", "failed", nil)
+		return toolError(fmt.Sprintf("failed to execute steps: %v", err))
+	}
+	results := parseStepsOutput(stdout, len(steps))
+	if !results[0].started {
+		status.finish("", "failed", nil)
+		return categorizedError(errCommandFailed, fmt.Sprintf("steps did not start (exit code %d): %s", exitCode, strings.TrimSpace(stderr+"\n"+stdout)))
+	}
+	text, failed := formatStepsResult(steps, results, exitCode, stderr)
+	status.finish("", "exited", &exitCode)
+	if failed > 0 {
+		return categorizedError(errCommandFailed, text)
+	}
+	return toolSuccess(text)
+}
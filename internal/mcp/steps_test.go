@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+)
+
+// runStepsLocally runs StepsScript with the local bash, like the codespace would.
+func runStepsLocally(t *testing.T, steps []string) (string, int) {
+	t.Helper()
+	cmd := exec.Command("bash", "-c", StepsScript(steps))
+	cmd.Dir = t.TempDir()
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("running steps: %v", err)
+	}
+	return string(out), 0
+}
+
+func TestStepsScript(t *testing.T) {
+	steps := []string{"mkdir sub && cd sub", "export GREETING=hi; echo \"$GREETING from $(basename \"$PWD\")\"", "echo oops >&2; exit_code=3; (exit $exit_code)", "echo never"}
+	out, code := runStepsLocally(t, steps)
+	if code != 3 {
+		t.Fatalf("exit code = %d, want 3\n%s", code, out)
+	}
+	results := parseStepsOutput(out, len(steps))
+	if !results[0].finished || results[0].exitCode != 0 || results[0].output != "" {
+		t.Errorf("step 1 = %+v", results[0])
+	}
+	if results[1].output != "hi from sub" {
+		t.Errorf("step 2 output = %q; cd and export should carry over", results[1].output)
+	}
+	if !results[2].finished || results[2].exitCode != 3 || results[2].output != "oops" {
+		t.Errorf("step 3 = %+v", results[2])
+	}
+	if results[3].started {
+		t.Error("step 4 should not run after a failure")
+	}
+
+	text, failed := formatStepsResult(steps, results, code, "")
+	if failed != 3 {
+		t.Errorf("failed = %d, want 3", failed)
+	}
+	for _, want := range []string{"[step 1/4 ✓", "[step 3/4 ✗ exit 3,", "[step 4/4 skipped] echo never", "Step 3 of 4 failed (exit code 3)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestStepsScriptExitInStep(t *testing.T) {
+	steps := []string{"echo before", "exit 7", "echo after"}
+	out, code := runStepsLocally(t, steps)
+	results := parseStepsOutput(out, len(steps))
+	if !results[1].started || results[1].finished {
+		t.Fatalf("step 2 = %+v, want started but not finished", results[1])
+	}
+	text, failed := formatStepsResult(steps, results, code, "")
+	if failed != 2 || !strings.Contains(text, "ended the shell with exit code 7") {
+		t.Errorf("failed = %d, text:\n%s", failed, text)
+	}
+}
+
+func TestEncodeDecodeSteps(t *testing.T) {
+	steps := []string{"echo 'quoted' \"double\" $HOME", "a && b"}
+	got, err := DecodeSteps(EncodeSteps(steps))
+	if err != nil || strings.Join(got, "\n") != strings.Join(steps, "\n") {
+		t.Errorf("round trip = %q, %v", got, err)
+	}
+	if _, err := DecodeSteps("!!"); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
+
+func TestBashHandlerSteps(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "\x1estart 1\nok\n\n\x1eend 1 0 12\n\x1estart 2\n\n\x1eend 2 0 5\n"}
+	reg := testReg(mock)
+	handler := bashHandler(reg)
+
+	result, _ := handler(context.Background(), makeReq(map[string]any{"steps": []any{"go build ./...", "go vet ./..."}, "cwd": "/workspaces/app"}))
+	text := resultText(result)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", text)
+	}
+	if !strings.Contains(text, "[step 1/2 ✓ 12ms] go build ./...\nok\n") || !strings.Contains(text, "All 2 steps succeeded.") {
+		t.Errorf("result:\n%s", text)
+	}
+	if mock.lastRunBashCommand != StepsScript([]string{"go build ./...", "go vet ./..."}) || mock.lastRunBashCwd != "/workspaces/app" {
+		t.Errorf("command = %q in %q", mock.lastRunBashCommand, mock.lastRunBashCwd)
+	}
+
+	agentReg := registry.New()
+	agentReg.Register(&registry.ManagedCodespace{Alias: "test", Name: "test-cs", Executor: mock, ExecAgent: "/tmp/bin/agent"})
+	bashHandler(agentReg)(context.Background(), makeReq(map[string]any{"steps": []any{"make"}}))
+	if want := "'/tmp/bin/agent' exec --steps " + EncodeSteps([]string{"make"}); mock.lastRunBashCommand != want {
+		t.Errorf("agent command = %q, want %q", mock.lastRunBashCommand, want)
+	}
+
+	for _, args := range []map[string]any{
+		{"steps": []any{}},
+		{"steps": []any{"ok", 3}},
+		{"steps": []any{"ok"}, "command": "ls"},
+	} {
+		result, _ := handler(context.Background(), makeReq(args))
+		if !result.IsError || !strings.HasPrefix(resultText(result), "[error:invalid_argument]") {
+			t.Errorf("args %v: result = %q, want invalid_argument", args, resultText(result))
+		}
+	}
+}
+
+func TestBashHandlerStepsFailure(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "\x1estart 1\nboom\n\x1eend 1 2 30\n", runBashExit: 2}
+	result, _ := bashHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"steps": []any{"npm run build", "npm test"}}))
+	text := resultText(result)
+	if !result.IsError || !strings.HasPrefix(text, "[error:command_failed]") {
+		t.Fatalf("result = %q, want command_failed", text)
+	}
+	if !strings.Contains(text, "Step 1 of 2 failed (exit code 2): npm run build") || !strings.Contains(text, "[step 2/2 skipped] npm test") {
+		t.Errorf("result:\n%s", text)
+	}
+}
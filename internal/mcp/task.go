@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// defaultRemoteTaskInitialWait is how long remote_task waits for the
+// sub-agent's first output. Agents rarely finish this fast, so it is a first
+// look rather than a result.
+const defaultRemoteTaskInitialWait = 10.0
+
+// remoteTaskCommand runs Copilot CLI non-interactively on the codespace,
+// falling back to npx when it isn't installed. Tools are pre-approved because
+// nobody is attached to the tmux pane to answer prompts.
+// pathSetup comes from misePathSetup.
+func remoteTaskCommand(pathSetup, prompt, taskContext, model string) string {
+	if taskContext != "" {
+		prompt = "Context from the parent session:\n\n" + taskContext + "\n\nTask:\n\n" + prompt
+	}
+	args := "-p " + shellQuote(prompt) + " --allow-all-tools"
+	if model != "" {
+		args += " --model " + shellQuote(model)
+	}
+	return pathSetup + `; ` +
+		`if command -v copilot >/dev/null 2>&1; then copilot ` + args + `; else npx -y @github/copilot ` + args + `; fi`
+}
+
+// --- remote_task ---
+
+func remoteTaskTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_task",
+		Annotations: mutatingHints("Run sub-agent on codespace", true, false, true),
+		Description: "Experimental: run a Copilot CLI sub-agent on the codespace itself, in a tmux session, for a self-contained task that only needs the codespace (fix failing tests, apply a refactor, investigate a build). " +
+			"The sub-agent does not see this conversation: put everything it needs in prompt and context. It runs with all tools allowed. " +
+			"Returns its output so far and a shellId; follow up with remote_read_bash until it exits, or remote_stop_bash to cancel.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"prompt": map[string]any{
+					"type":        "string",
+					"description": "The task for the sub-agent, including what to report back when done",
+				},
+				"context": map[string]any{
+					"type":        "string",
+					"description": "Optional context from this session to hand over: relevant files, findings so far, constraints, decisions already made",
+				},
+				"model": map[string]any{
+					"type":        "string",
+					"description": "Optional model for the sub-agent (Copilot CLI --model)",
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Optional working directory on the codespace (defaults to the current remote cwd)",
+				},
+				"initial_wait": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Seconds to wait for initial output (default: %g)", defaultRemoteTaskInitialWait),
+				},
+			},
+			Required: []string{"prompt"},
+		},
+	}
+}
+
+func remoteTaskHandler(reg *registry.Registry, status *statusRecorder) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		prompt, err := requiredString(req, "prompt")
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		if strings.TrimSpace(prompt) == "" {
+			return categorizedError(errInvalidArgument, "prompt must not be empty"), nil
+		}
+
+		c := cs.Executor
+		shellId := fmt.Sprintf("task-%d", time.Now().UnixMilli())
+		command := remoteTaskCommand(misePathSetup(c), prompt, optionalString(req, "context"), optionalString(req, "model"))
+		if err := c.StartSession(ctx, shellId, command, optionalString(req, "cwd")); err != nil {
+			return categorizedError(errUnavailable, fmt.Sprintf("starting sub-agent session: %v (remote_task needs tmux on the codespace)", err)), nil
+		}
+		status.start(cs.Alias, shellId, "copilot -p "+firstLine(prompt))
+
+		initialWait := optionalFloat(req, "initial_wait", defaultRemoteTaskInitialWait)
+		if err := sleepContext(ctx, time.Duration(initialWait*float64(time.Second))); err != nil {
+			return categorizedError(errTimeout, fmt.Sprintf("cancelled while waiting for sub-agent session %s; it keeps running, use remote_read_bash or remote_stop_bash", shellId)), nil
+		}
+		output, err := c.ReadSession(ctx, shellId)
+		if err != nil {
+			return toolError(fmt.Sprintf("reading sub-agent session %s: %v", shellId, err)), nil
+		}
+		status.observe(shellId, output)
+		if sessionOutputExited(output) {
+			finalOutput := trimSessionExitMarker(output)
+			if err := c.StopSession(ctx, shellId); err != nil {
+				finalOutput += fmt.Sprintf("\n[cleanup warning: failed to stop completed session %s: %v]", shellId, err)
+			}
+			return toolSuccess(finalOutput), nil
+		}
+		return toolSuccess(fmt.Sprintf("Started sub-agent on %s: %s\n\n%s\n\n[shellId: %s — use remote_read_bash to follow the sub-agent until it exits]", cs.Alias, shellId, output, shellId)), nil
+	}
+}
+
+// firstLine returns the first line of s, shortened for status displays.
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if r := []rune(s); len(r) > 80 {
+		s = string(r[:77]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+func TestRemoteTaskCommand(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor a in \"$@\"; do printf '<%s>\\n' \"$a\"; done\n"
+	if err := os.WriteFile(filepath.Join(bin, "copilot"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("bash", "-c", remoteTaskCommand(ssh.MisePATH, "fix the 'flaky' test", "tests live in pkg/", "gpt-5"))
+	cmd.Env = append(os.Environ(), "HOME="+t.TempDir(), "PATH="+bin+":"+os.Getenv("PATH"))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running command: %v", err)
+	}
+	want := "<-p>\n<Context from the parent session:\n\ntests live in pkg/\n\nTask:\n\nfix the 'flaky' test>\n<--allow-all-tools>\n<--model>\n<gpt-5>\n"
+	if string(out) != want {
+		t.Errorf("copilot args = %q, want %q", out, want)
+	}
+}
+
+func TestRemoteTaskHandler(t *testing.T) {
+	mock := &mockExecutor{readSessionResult: "Working on it..."}
+	res, err := remoteTaskHandler(testReg(mock), nil)(context.Background(), makeReq(map[string]any{
+		"prompt":       "run the tests and report failures",
+		"cwd":          "/workspaces/repo",
+		"initial_wait": float64(0),
+	}))
+	if err != nil || res.IsError {
+		t.Fatalf("unexpected error: %v %s", err, resultText(res))
+	}
+	text := resultText(res)
+	if !strings.Contains(text, "Working on it...") || !strings.Contains(text, "[shellId: task-") {
+		t.Errorf("result = %q", text)
+	}
+	if !strings.HasPrefix(mock.lastSessionID, "task-") || mock.lastStartSessionCwd != "/workspaces/repo" ||
+		!strings.Contains(mock.lastCommand, "-p 'run the tests and report failures' --allow-all-tools") {
+		t.Errorf("session = %q in %q running %q", mock.lastSessionID, mock.lastStartSessionCwd, mock.lastCommand)
+	}
+
+	mock = &mockExecutor{readSessionResult: "All 12 tests pass.\n[session exited]"}
+	res, _ = remoteTaskHandler(testReg(mock), nil)(context.Background(), makeReq(map[string]any{"prompt": "check", "initial_wait": float64(0)}))
+	if text := resultText(res); text != "All 12 tests pass." || mock.stopSessionCalls != 1 {
+		t.Errorf("finished result = %q, stopSessionCalls = %d", text, mock.stopSessionCalls)
+	}
+
+	// Cancelling the call stops the initial wait.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, _ = remoteTaskHandler(testReg(&mockExecutor{}), nil)(ctx, makeReq(map[string]any{"prompt": "check", "initial_wait": float64(30)}))
+	if !res.IsError || !strings.Contains(resultText(res), "timeout") || time.Since(start) > 5*time.Second {
+		t.Errorf("cancelled result = %q after %s", resultText(res), time.Since(start))
+	}
+
+	res, _ = remoteTaskHandler(testReg(&mockExecutor{}), nil)(context.Background(), makeReq(map[string]any{"prompt": "  "}))
+	if !res.IsError || !strings.Contains(resultText(res), "invalid_argument") {
+		t.Errorf("empty prompt result = %q", resultText(res))
+	}
+}
+
+func TestRemoteTaskIsOptIn(t *testing.T) {
+	if slices.Contains(ToolNames(LifecycleConfig{GHRunner: &mockGHRunner{}}), "remote_task") {
+		t.Error("remote_task registered without RemoteTask")
+	}
+	if !slices.Contains(ToolNames(LifecycleConfig{GHRunner: &mockGHRunner{}, RemoteTask: true}), "remote_task") {
+		t.Error("remote_task missing with RemoteTask")
+	}
+}
@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultTopProcesses = 10
+	maxTopProcesses     = 50
+	// Thresholds at which remote_top flags a resource as the likely problem.
+	topDiskFullPercent    = 90
+	topMemoryLowPercent   = 10
+	topLoadPerCoreWarning = 1.0
+)
+
+// topCommand prints the sections parseTop reads, each after a "== name"
+// line: load average and CPU count, /proc/meminfo, the cgroup v2 memory and
+// CPU limits, df for the filesystems holding /, /workspaces, /tmp, and
+// workdir, and the top processes sorted by CPU or memory.
+func topCommand(workdir, sortBy string, processes int) string {
+	sortKey := "-pcpu"
+	if sortBy == "memory" {
+		sortKey = "-rss"
+	}
+	paths := "/ /workspaces /tmp"
+	if workdir != "" {
+		paths += " " + shellQuote(workdir)
+	}
+	return strings.Join([]string{
+		`echo '== load'; cat /proc/loadavg; nproc`,
+		`echo '== memory'; grep -E '^(MemTotal|MemAvailable|SwapTotal|SwapFree):' /proc/meminfo`,
+		`echo '== cgroup'; for f in memory.max memory.current cpu.max; do printf '%s ' "$f"; cat /sys/fs/cgroup/$f 2>/dev/null || echo; done`,
+		`echo '== disk'; df -Pk ` + paths + ` 2>/dev/null | awk 'NR > 1 && !seen[$1]++'`,
+		fmt.Sprintf(`echo '== processes'; ps -eo pid=,pcpu=,pmem=,rss=,etime=,comm= --sort=%s | grep -v ' ps$' | head -n %d`, sortKey, processes),
+	}, "; ")
+}
+
+// topDisk is one filesystem from df -Pk.
+type topDisk struct {
+	mount                  string
+	sizeKB, usedKB, freeKB int64
+}
+
+type topReport struct {
+	load      [3]float64
+	cpus      int
+	memTotal  int64 // bytes
+	memAvail  int64
+	swapTotal int64
+	swapFree  int64
+	cgroupMem int64 // memory.max in bytes; 0 when unlimited or unknown
+	cgroupCur int64
+	cgroupCPU float64 // cores allowed by cpu.max; 0 when unlimited or unknown
+	disks     []topDisk
+	processes []string
+}
+
+// parseTop reads topCommand output. Missing sections leave zero values.
+func parseTop(out string) topReport {
+	var r topReport
+	section := ""
+	for _, line := range strings.Split(out, "\n") {
+		if name, ok := strings.CutPrefix(line, "== "); ok {
+			section = name
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch section {
+		case "load":
+			if len(fields) >= 3 && strings.Contains(fields[0], ".") {
+				for i := range r.load {
+					r.load[i], _ = strconv.ParseFloat(fields[i], 64)
+				}
+			} else if n, err := strconv.Atoi(fields[0]); err == nil {
+				r.cpus = n
+			}
+		case "memory":
+			if len(fields) < 2 {
+				continue
+			}
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			switch fields[0] {
+			case "MemTotal:":
+				r.memTotal = kb * 1024
+			case "MemAvailable:":
+				r.memAvail = kb * 1024
+			case "SwapTotal:":
+				r.swapTotal = kb * 1024
+			case "SwapFree:":
+				r.swapFree = kb * 1024
+			}
+		case "cgroup":
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "memory.max":
+				r.cgroupMem, _ = strconv.ParseInt(fields[1], 10, 64)
+			case "memory.current":
+				r.cgroupCur, _ = strconv.ParseInt(fields[1], 10, 64)
+			case "cpu.max":
+				quota, err1 := strconv.ParseFloat(fields[1], 64)
+				if len(fields) >= 3 {
+					period, err2 := strconv.ParseFloat(fields[2], 64)
+					if err1 == nil && err2 == nil && period > 0 {
+						r.cgroupCPU = quota / period
+					}
+				}
+			}
+		case "disk":
+			if len(fields) < 6 {
+				continue
+			}
+			size, _ := strconv.ParseInt(fields[1], 10, 64)
+			used, _ := strconv.ParseInt(fields[2], 10, 64)
+			free, _ := strconv.ParseInt(fields[3], 10, 64)
+			r.disks = append(r.disks, topDisk{mount: fields[5], sizeKB: size, usedKB: used, freeKB: free})
+		case "processes":
+			r.processes = append(r.processes, strings.TrimSpace(line))
+		}
+	}
+	return r
+}
+
+// humanBytes renders n like "1.5 GB".
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// format renders the report, flagging resources past the thresholds.
+func (r topReport) format(sortBy string) string {
+	var sb strings.Builder
+	var warnings []string
+
+	fmt.Fprintf(&sb, "Load: %.2f %.2f %.2f (1/5/15 min)", r.load[0], r.load[1], r.load[2])
+	if r.cpus > 0 {
+		fmt.Fprintf(&sb, " on %d cores", r.cpus)
+		if r.load[0] > float64(r.cpus)*topLoadPerCoreWarning {
+			warnings = append(warnings, fmt.Sprintf("load %.2f exceeds %d cores: commands are waiting for CPU", r.load[0], r.cpus))
+		}
+	}
+	sb.WriteString("\n")
+
+	if r.memTotal > 0 {
+		used := r.memTotal - r.memAvail
+		fmt.Fprintf(&sb, "Memory: %s used of %s (%s available)", humanBytes(used), humanBytes(r.memTotal), humanBytes(r.memAvail))
+		if r.swapTotal > 0 {
+			fmt.Fprintf(&sb, "; swap %s used of %s", humanBytes(r.swapTotal-r.swapFree), humanBytes(r.swapTotal))
+		}
+		sb.WriteString("\n")
+		if r.memAvail*100 < r.memTotal*topMemoryLowPercent {
+			warnings = append(warnings, fmt.Sprintf("only %s of memory available: builds may be killed or swap heavily", humanBytes(r.memAvail)))
+		}
+	}
+
+	if r.cgroupMem > 0 || r.cgroupCPU > 0 {
+		var limits []string
+		if r.cgroupMem > 0 {
+			limits = append(limits, fmt.Sprintf("memory %s of %s limit", humanBytes(r.cgroupCur), humanBytes(r.cgroupMem)))
+			if (r.cgroupMem-r.cgroupCur)*100 < r.cgroupMem*topMemoryLowPercent {
+				warnings = append(warnings, "the container is near its cgroup memory limit: the OOM killer may end processes")
+			}
+		}
+		if r.cgroupCPU > 0 {
+			limits = append(limits, fmt.Sprintf("CPU limited to %.1f cores", r.cgroupCPU))
+		}
+		fmt.Fprintf(&sb, "Container (cgroup): %s\n", strings.Join(limits, "; "))
+	}
+
+	if len(r.disks) > 0 {
+		sb.WriteString("Disk:\n")
+		for _, d := range r.disks {
+			// Like df, round up and leave out the root-reserved blocks.
+			percent := int64(0)
+			if avail := d.usedKB + d.freeKB; avail > 0 {
+				percent = (d.usedKB*100 + avail - 1) / avail
+			}
+			fmt.Fprintf(&sb, "  %s: %s used of %s (%d%%), %s free\n", d.mount, humanBytes(d.usedKB*1024), humanBytes(d.sizeKB*1024), percent, humanBytes(d.freeKB*1024))
+			if percent >= topDiskFullPercent {
+				warnings = append(warnings, fmt.Sprintf("%s is %d%% full: clean build caches, docker images, or node_modules", d.mount, percent))
+			}
+		}
+	}
+
+	if len(r.processes) > 0 {
+		by := "CPU"
+		if sortBy == "memory" {
+			by = "memory"
+		}
+		fmt.Fprintf(&sb, "Top processes by %s (PID %%CPU %%MEM RSS-KB ELAPSED COMMAND):\n", by)
+		for _, p := range r.processes {
+			sb.WriteString("  " + p + "\n")
+		}
+	}
+
+	for _, w := range warnings {
+		fmt.Fprintf(&sb, "[warning: %s]\n", w)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// --- remote_top ---
+
+func topTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_top",
+		Annotations: readOnlyHints("Resource usage", false),
+		Description: "One-shot resource summary of the codespace: load average against the core count, memory and swap, container (cgroup) limits, disk usage of /, /workspaces, /tmp and the workdir, and the busiest processes. " +
+			"Resources past their limits are flagged. Use it when builds are slow, get killed, or fail with \"no space left on device\", instead of running uptime, free, df, and ps separately.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"sort": map[string]any{
+					"type":        "string",
+					"description": "Order processes by cpu (default) or memory",
+					"enum":        []string{"cpu", "memory"},
+				},
+				"processes": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("How many processes to list (default: %d, max: %d)", defaultTopProcesses, maxTopProcesses),
+				},
+			},
+		},
+	}
+}
+
+func topHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		cs, err := resolveCodespace(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		sortBy := optionalString(req, "sort")
+		if sortBy != "" && sortBy != "cpu" && sortBy != "memory" {
+			return categorizedError(errInvalidArgument, "sort must be cpu or memory"), nil
+		}
+		processes := defaultTopProcesses
+		if v, ok := req.GetArguments()["processes"]; ok {
+			n, ok := toInt(v)
+			if !ok || n < 1 || n > maxTopProcesses {
+				return categorizedError(errInvalidArgument, fmt.Sprintf("processes must be between 1 and %d", maxTopProcesses)), nil
+			}
+			processes = n
+		}
+		stdout, stderr, exitCode, err := cs.Executor.RunBash(ctx, topCommand(cs.Executor.GetWorkdir(), sortBy, processes), "")
+		if err != nil {
+			return toolError(fmt.Sprintf("reading resource usage: %v", err)), nil
+		}
+		if exitCode != 0 && !strings.Contains(stdout, "== load") {
+			return toolError(fmt.Sprintf("reading resource usage failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		return toolSuccess(parseTop(stdout).format(sortBy)), nil
+	}
+}
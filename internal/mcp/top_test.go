@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const topOutput = `== load
+3.50 2.10 1.00 4/512 12345
+2
+== memory
+MemTotal:        8000000 kB
+MemAvailable:     400000 kB
+SwapTotal:             0 kB
+SwapFree:              0 kB
+== cgroup
+memory.max max
+memory.current 123456
+cpu.max 200000 100000
+== disk
+overlay 32000000 16000000 16000000 50% /
+/dev/sdb1 100000000 95000000 5000000 95% /workspaces
+== processes
+  4242 180.0 12.5 1000000 01:02:03 node
+  99 2.0 0.1 2048 5-00:00:00 sshd
+`
+
+func TestParseTop(t *testing.T) {
+	r := parseTop(topOutput)
+	if r.load != [3]float64{3.5, 2.1, 1.0} || r.cpus != 2 {
+		t.Errorf("load = %v on %d cores", r.load, r.cpus)
+	}
+	if r.memTotal != 8000000*1024 || r.memAvail != 400000*1024 {
+		t.Errorf("memory = %d / %d", r.memAvail, r.memTotal)
+	}
+	if r.cgroupMem != 0 || r.cgroupCPU != 2 {
+		t.Errorf("cgroup memory = %d, cpu = %v; want unlimited memory, 2 cores", r.cgroupMem, r.cgroupCPU)
+	}
+	if len(r.disks) != 2 || r.disks[1].mount != "/workspaces" || r.disks[1].freeKB != 5000000 {
+		t.Errorf("disks = %+v", r.disks)
+	}
+	if len(r.processes) != 2 || !strings.HasSuffix(r.processes[0], "node") {
+		t.Errorf("processes = %q", r.processes)
+	}
+}
+
+func TestTopReportFormat_FlagsExhaustedResources(t *testing.T) {
+	text := parseTop(topOutput).format("cpu")
+	for _, want := range []string{
+		"Load: 3.50 2.10 1.00 (1/5/15 min) on 2 cores",
+		"Memory: 7.2 GB used of 7.6 GB (390.6 MB available)",
+		"Container (cgroup): CPU limited to 2.0 cores",
+		"/workspaces: 90.6 GB used of 95.4 GB (95%), 4.8 GB free",
+		"Top processes by CPU",
+		"4242 180.0 12.5 1000000 01:02:03 node",
+		"[warning: load 3.50 exceeds 2 cores",
+		"[warning: only 390.6 MB of memory available",
+		"[warning: /workspaces is 95% full",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("format missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "[warning: / is") {
+		t.Errorf("half-full root disk flagged:\n%s", text)
+	}
+}
+
+func TestTopReportFormat_HealthyHasNoWarnings(t *testing.T) {
+	r := parseTop("== load\n0.10 0.20 0.30 1/100 1\n4\n== memory\nMemTotal: 8000000 kB\nMemAvailable: 6000000 kB\n")
+	if text := r.format("memory"); strings.Contains(text, "warning") {
+		t.Errorf("unexpected warning:\n%s", text)
+	}
+}
+
+func TestTopHandler(t *testing.T) {
+	mock := &mockExecutor{workdir: "/workspaces/repo", runBashStdout: topOutput}
+	res, _ := topHandler(testReg(mock))(context.Background(), makeReq(map[string]any{"sort": "memory", "processes": float64(5)}))
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", resultText(res))
+	}
+	if !strings.Contains(resultText(res), "Top processes by memory") {
+		t.Errorf("result = %s", resultText(res))
+	}
+	for _, want := range []string{"--sort=-rss", "head -n 5", "/workspaces/repo"} {
+		if !strings.Contains(mock.lastRunBashCommand, want) {
+			t.Errorf("command missing %q: %s", want, mock.lastRunBashCommand)
+		}
+	}
+}
+
+func TestTopHandler_RejectsBadArguments(t *testing.T) {
+	for _, args := range []map[string]any{
+		{"sort": "disk"},
+		{"processes": float64(0)},
+		{"processes": float64(maxTopProcesses + 1)},
+	} {
+		res, _ := topHandler(testReg(&mockExecutor{}))(context.Background(), makeReq(args))
+		if !res.IsError || !strings.Contains(resultText(res), "invalid_argument") {
+			t.Errorf("%v: result = %s, want invalid_argument", args, resultText(res))
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package mcp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// maxViewManyFiles bounds one remote_view_many call.
+	maxViewManyFiles = 20
+	// maxViewManyFileBytes skips files too large to return whole; remote_view
+	// with a view_range still reads them.
+	maxViewManyFileBytes = 1 << 20
+)
+
+// viewManyFile is one requested file and its optional [start, end] range.
+type viewManyFile struct {
+	path      string
+	viewRange []int
+}
+
+// --- remote_view_many ---
+
+func viewManyTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_view_many",
+		Annotations: readOnlyHints("View several remote files", false),
+		Description: fmt.Sprintf("View up to %d files on the remote codespace in one call, each with line numbers like remote_view. "+
+			"The files are transferred together in a single round trip, so prefer this over several remote_view calls when reading related files. "+
+			"Missing files, directories, and files over %d KB are reported per file without failing the others.", maxViewManyFiles, maxViewManyFileBytes>>10),
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"files": map[string]any{
+					"type":        "array",
+					"description": "Files to view, in order",
+					"maxItems":    maxViewManyFiles,
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"path": map[string]any{
+								"type":        "string",
+								"description": "Path to the file. Relative paths resolve against cwd.",
+							},
+							"view_range": map[string]any{
+								"type":        "array",
+								"description": "Optional [start_line, end_line] range. Use -1 for end_line to read to end of file.",
+								"items":       map[string]any{"type": "integer"},
+							},
+						},
+						"required": []string{"path"},
+					},
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory for relative paths (default: current working directory)",
+				},
+			},
+			Required: []string{"files"},
+		},
+	}
+}
+
+func viewManyHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		c, err := resolveExecutor(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		files, err := viewManyFiles(req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+		cwd := optionalString(req, "cwd")
+		if cwd == "" {
+			cwd = c.GetWorkdir()
+		}
+
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.path
+		}
+		stdout, stderr, exitCode, execErr := c.RunBash(ctx, viewManyCommand(paths), cwd)
+		if execErr != nil {
+			return toolError(fmt.Sprintf("failed to execute command: %v", execErr)), nil
+		}
+		if exitCode != 0 {
+			return toolError(fmt.Sprintf("view files failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+		}
+		statuses, contents, err := parseViewManyOutput(stdout, len(files))
+		if err != nil {
+			return categorizedError(errInternal, err.Error()), nil
+		}
+
+		var sb strings.Builder
+		for i, f := range files {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "==> %s <==\n", f.path)
+			switch statuses[i] {
+			case "ok":
+				sb.WriteString(numberLines(contents[i], f.viewRange))
+			case "missing":
+				sb.WriteString("[not found]\n")
+			case "dir":
+				sb.WriteString("[is a directory; use remote_view or remote_glob to list it]\n")
+			case "unreadable":
+				sb.WriteString("[permission denied]\n")
+			case "large":
+				fmt.Fprintf(&sb, "[larger than %d KB; use remote_view with view_range]\n", maxViewManyFileBytes>>10)
+			default:
+				fmt.Fprintf(&sb, "[unexpected status %q]\n", statuses[i])
+			}
+		}
+		if countStatus(statuses, "missing") == len(files) {
+			return categorizedError(errNotFound, "none of the files were found:\n"+sb.String()), nil
+		}
+		return toolSuccess(sb.String()), nil
+	}
+}
+
+// viewManyFiles parses the files argument.
+func viewManyFiles(req mcpsdk.CallToolRequest) ([]viewManyFile, error) {
+	raw, ok := req.GetArguments()["files"].([]any)
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("missing required parameter: files")
+	}
+	if len(raw) > maxViewManyFiles {
+		return nil, fmt.Errorf("too many files: %d (max %d)", len(raw), maxViewManyFiles)
+	}
+	files := make([]viewManyFile, 0, len(raw))
+	for i, item := range raw {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("files[%d] must be an object with a path", i)
+		}
+		p, _ := obj["path"].(string)
+		if p == "" {
+			return nil, fmt.Errorf("files[%d].path must be a non-empty string", i)
+		}
+		f := viewManyFile{path: p}
+		if arr, ok := obj["view_range"].([]any); ok && len(arr) == 2 {
+			start, ok1 := toInt(arr[0])
+			end, ok2 := toInt(arr[1])
+			if ok1 && ok2 {
+				f.viewRange = []int{start, end}
+			}
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// viewManyCommand prints one status line per path, a blank line, then a
+// base64 tar of the readable files in request order. The status check runs
+// again when building the file list so both passes agree on which files are
+// in the archive.
+func viewManyCommand(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	list := strings.Join(quoted, " ")
+	return fmt.Sprintf(`st() { if [ -d "$1" ]; then echo dir; elif [ ! -e "$1" ]; then echo missing; elif [ ! -r "$1" ]; then echo unreadable; elif [ "$(wc -c < "$1")" -gt %d ]; then echo large; else echo ok; fi; }; `+
+		`for p in %s; do st "$p"; done; echo; `+
+		`for p in %s; do [ "$(st "$p")" = ok ] && printf '%%s\0' "$p"; done | tar -chPf - --no-recursion --null -T - | base64 -w0`,
+		maxViewManyFileBytes, list, list)
+}
+
+// parseViewManyOutput splits viewManyCommand output into per-path statuses
+// and contents. The archive holds the "ok" files in request order; tar stores
+// a repeated path as a hard link to its first copy.
+func parseViewManyOutput(out string, n int) (statuses, contents []string, err error) {
+	lines := strings.SplitN(out, "\n", n+2)
+	if len(lines) < n+1 || lines[n] != "" {
+		return nil, nil, fmt.Errorf("unexpected view output: missing file statuses")
+	}
+	statuses = lines[:n]
+	var encoded string
+	if len(lines) > n+1 {
+		encoded = strings.TrimSpace(lines[n+1])
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding file archive: %w", err)
+	}
+
+	var entries []string
+	byName := make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading file archive: %w", err)
+		}
+		var body string
+		if hdr.Typeflag == tar.TypeLink {
+			body = byName[hdr.Linkname]
+		} else {
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading file archive: %w", err)
+			}
+			body = string(b)
+		}
+		byName[hdr.Name] = body
+		entries = append(entries, body)
+	}
+
+	contents = make([]string, n)
+	next := 0
+	for i, status := range statuses {
+		if status != "ok" {
+			continue
+		}
+		if next >= len(entries) {
+			return nil, nil, fmt.Errorf("file archive is missing %d file(s)", countStatus(statuses, "ok")-len(entries))
+		}
+		contents[i] = entries[next]
+		next++
+	}
+	return statuses, contents, nil
+}
+
+// numberLines formats content like remote_view: "N. line" per line, limited
+// to viewRange when set.
+func numberLines(content string, viewRange []int) string {
+	if content == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	start, end := 1, len(lines)
+	if len(viewRange) == 2 {
+		start = max(viewRange[0], 1)
+		if viewRange[1] != -1 {
+			end = min(viewRange[1], len(lines))
+		}
+	}
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%d. %s\n", i, lines[i-1])
+	}
+	return sb.String()
+}
+
+func countStatus(statuses []string, want string) int {
+	n := 0
+	for _, s := range statuses {
+		if s == want {
+			n++
+		}
+	}
+	return n
+}
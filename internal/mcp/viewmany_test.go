@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// viewManyOutput builds remote output for statuses and the ok files' contents.
+func viewManyOutput(t *testing.T, statuses []string, files map[string]string, order ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range order {
+		body := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	return strings.Join(statuses, "\n") + "\n\n" + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestViewManyHandler(t *testing.T) {
+	out := viewManyOutput(t,
+		[]string{"ok", "missing", "ok", "dir"},
+		map[string]string{"main.go": "package main\n\nfunc main() {}\n", "/etc/hosts": "127.0.0.1 localhost"},
+		"main.go", "/etc/hosts")
+	mock := &mockExecutor{runBashStdout: out, workdir: "/workspaces/repo"}
+	args := map[string]any{"files": []any{
+		map[string]any{"path": "main.go", "view_range": []any{float64(2), float64(-1)}},
+		map[string]any{"path": "gone.go"},
+		map[string]any{"path": "/etc/hosts"},
+		map[string]any{"path": "src"},
+	}}
+
+	result, err := viewManyHandler(testReg(mock))(context.Background(), makeReq(args))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	want := "==> main.go <==\n2. \n3. func main() {}\n" +
+		"\n==> gone.go <==\n[not found]\n" +
+		"\n==> /etc/hosts <==\n1. 127.0.0.1 localhost\n" +
+		"\n==> src <==\n[is a directory; use remote_view or remote_glob to list it]\n"
+	if got := resultText(result); got != want {
+		t.Errorf("result =\n%s\nwant\n%s", got, want)
+	}
+	if mock.runBashCalls != 1 {
+		t.Errorf("RunBash calls = %d, want 1", mock.runBashCalls)
+	}
+	if mock.lastRunBashCwd != "/workspaces/repo" {
+		t.Errorf("cwd = %q, want workdir", mock.lastRunBashCwd)
+	}
+}
+
+func TestViewManyHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		mock    *mockExecutor
+		wantErr string
+	}{
+		{"missing files", map[string]any{}, &mockExecutor{}, "[error:invalid_argument] missing required parameter: files"},
+		{"bad item", map[string]any{"files": []any{"main.go"}}, &mockExecutor{}, "files[0] must be an object"},
+		{"too many", map[string]any{"files": make([]any, maxViewManyFiles+1)}, &mockExecutor{}, "too many files"},
+		{"all missing", map[string]any{"files": []any{map[string]any{"path": "a"}, map[string]any{"path": "b"}}},
+			&mockExecutor{runBashStdout: "missing\nmissing\n\n"}, "[error:not_found] none of the files were found"},
+		{"garbled output", map[string]any{"files": []any{map[string]any{"path": "a"}}},
+			&mockExecutor{runBashStdout: "ok"}, "[error:internal] unexpected view output"},
+		{"archive short", map[string]any{"files": []any{map[string]any{"path": "a"}}},
+			&mockExecutor{runBashStdout: "ok\n\n"}, "file archive is missing 1 file(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := viewManyHandler(testReg(tt.mock))(context.Background(), makeReq(tt.args))
+			if text := resultText(result); !result.IsError || !strings.Contains(text, tt.wantErr) {
+				t.Errorf("result = %q, want error containing %q", text, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	content := "a\nb\nc\n"
+	tests := []struct {
+		viewRange []int
+		want      string
+	}{
+		{nil, "1. a\n2. b\n3. c\n"},
+		{[]int{2, 2}, "2. b\n"},
+		{[]int{2, -1}, "2. b\n3. c\n"},
+		{[]int{3, 10}, "3. c\n"},
+		{[]int{5, -1}, ""},
+	}
+	for _, tt := range tests {
+		if got := numberLines(content, tt.viewRange); got != tt.want {
+			t.Errorf("numberLines(%v) = %q, want %q", tt.viewRange, got, tt.want)
+		}
+	}
+	if got := numberLines("no newline", nil); got != "1. no newline\n" {
+		t.Errorf("numberLines without trailing newline = %q", got)
+	}
+}
+
+// TestViewManyCommandRuns executes the generated command with the local shell
+// and decodes the result.
+func TestViewManyCommandRuns(t *testing.T) {
+	for _, tool := range []string{"bash", "tar", "base64"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "big.txt"), bytes.Repeat([]byte("x"), maxViewManyFileBytes+1), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+
+	paths := []string{"a.txt", "missing.txt", "sub", "big.txt", filepath.Join(dir, "a.txt"), "a.txt"}
+	cmd := exec.Command("bash", "-c", viewManyCommand(paths))
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running command: %v", err)
+	}
+	statuses, contents, err := parseViewManyOutput(string(out), len(paths))
+	if err != nil {
+		t.Fatalf("parseViewManyOutput: %v", err)
+	}
+	wantStatuses := []string{"ok", "missing", "dir", "large", "ok", "ok"}
+	if strings.Join(statuses, ",") != strings.Join(wantStatuses, ",") {
+		t.Errorf("statuses = %v, want %v", statuses, wantStatuses)
+	}
+	for _, i := range []int{0, 4, 5} {
+		if contents[i] != "alpha\n" {
+			t.Errorf("contents[%d] = %q, want alpha", i, contents[i])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultWaitTimeout  = 60
+	maxWaitTimeout      = 600
+	defaultWaitInterval = 1
+)
+
+// waitTimeoutExit is the exit status the polling loop uses when the deadline
+// passes, matching coreutils timeout(1).
+const waitTimeoutExit = 124
+
+var waitHostPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// --- remote_wait ---
+
+func waitTool() mcpsdk.Tool {
+	return mcpsdk.Tool{
+		Name:        "remote_wait",
+		Annotations: readOnlyHints("Wait for remote condition", false),
+		Description: "Block until a condition holds on the remote codespace, or fail after a timeout. " +
+			"Conditions: 'file_exists' (path exists), 'port_listening' (a TCP port accepts connections), " +
+			"'process_exited' (a PID, or no process matching a pattern, is running), 'url_ok' (a URL returns HTTP 200). " +
+			"Polling runs on the codespace in a single command, so use this instead of sleep-and-retry loops.",
+		InputSchema: mcpsdk.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"codespace": codespaceParam,
+				"condition": map[string]any{
+					"type":        "string",
+					"enum":        []string{"file_exists", "port_listening", "process_exited", "url_ok"},
+					"description": "Condition to wait for",
+				},
+				"path": map[string]any{
+					"type":        "string",
+					"description": "File or directory to wait for ('file_exists'). Relative paths resolve against cwd.",
+				},
+				"port": map[string]any{
+					"type":        "number",
+					"description": "TCP port to wait for ('port_listening')",
+				},
+				"host": map[string]any{
+					"type":        "string",
+					"description": "Host to connect to ('port_listening', default: 127.0.0.1)",
+				},
+				"pid": map[string]any{
+					"type":        "number",
+					"description": "Process ID to wait for ('process_exited')",
+				},
+				"pattern": map[string]any{
+					"type":        "string",
+					"description": "Wait until no process command line matches this pgrep -f pattern ('process_exited')",
+				},
+				"url": map[string]any{
+					"type":        "string",
+					"description": "URL to poll until it returns HTTP 200 ('url_ok')",
+				},
+				"timeout": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Seconds to wait before failing (default: %d, max: %d)", defaultWaitTimeout, maxWaitTimeout),
+				},
+				"interval": map[string]any{
+					"type":        "number",
+					"description": fmt.Sprintf("Seconds between checks (default: %d)", defaultWaitInterval),
+				},
+				"cwd": map[string]any{
+					"type":        "string",
+					"description": "Working directory for relative paths (default: current working directory)",
+				},
+			},
+			Required: []string{"condition"},
+		},
+	}
+}
+
+// waitHandler runs one remote polling loop for the requested condition. The
+// loop prints the elapsed seconds and exits 0 when the condition holds, or
+// exits waitTimeoutExit once the deadline passes.
+func waitHandler(reg *registry.Registry) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		c, err := resolveExecutor(reg, req)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		condition, err := requiredString(req, "condition")
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		check, desc, err := waitCheck(condition, req)
+		if err != nil {
+			return categorizedError(errInvalidArgument, err.Error()), nil
+		}
+
+		timeout := int(optionalFloat(req, "timeout", defaultWaitTimeout))
+		if timeout < 1 || timeout > maxWaitTimeout {
+			return categorizedError(errInvalidArgument, fmt.Sprintf("timeout must be between 1 and %d seconds", maxWaitTimeout)), nil
+		}
+		interval := optionalFloat(req, "interval", defaultWaitInterval)
+		if interval <= 0 || interval > float64(timeout) {
+			return categorizedError(errInvalidArgument, "interval must be greater than 0 and at most the timeout"), nil
+		}
+
+		cwd := optionalString(req, "cwd")
+		if cwd == "" {
+			cwd = c.GetWorkdir()
+		}
+
+		// Leave headroom for SSH setup so the remote deadline fires first.
+		runCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second+30*time.Second)
+		defer cancel()
+		stdout, stderr, exitCode, execErr := c.RunBash(runCtx, waitScript(check, timeout, interval), cwd)
+		if execErr != nil {
+			return toolError(fmt.Sprintf("failed to execute command: %v", execErr)), nil
+		}
+		switch exitCode {
+		case 0:
+			elapsed := strings.TrimSpace(stdout)
+			return toolSuccess(fmt.Sprintf("Condition met: %s (after %ss).", desc, elapsed)), nil
+		case waitTimeoutExit:
+			return categorizedError(errTimeout, fmt.Sprintf("timed out after %ds waiting for %s", timeout, desc)), nil
+		}
+		return categorizedError(errCommandFailed, fmt.Sprintf("wait failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))), nil
+	}
+}
+
+// waitCheck returns the shell test for condition and a description of it for
+// the result message.
+func waitCheck(condition string, req mcpsdk.CallToolRequest) (check, desc string, err error) {
+	args := req.GetArguments()
+	switch condition {
+	case "file_exists":
+		p, err := requiredString(req, "path")
+		if err != nil {
+			return "", "", err
+		}
+		return "test -e " + shellQuote(p), fmt.Sprintf("%s to exist", p), nil
+	case "port_listening":
+		port, ok := toInt(args["port"])
+		if !ok {
+			return "", "", fmt.Errorf("missing required parameter: port")
+		}
+		if port < 1 || port > 65535 {
+			return "", "", fmt.Errorf("invalid port %d", port)
+		}
+		host := optionalString(req, "host")
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		if !waitHostPattern.MatchString(host) {
+			return "", "", fmt.Errorf("invalid host %q", host)
+		}
+		// bash's /dev/tcp needs no extra tools on the codespace image.
+		return fmt.Sprintf("(exec 3<>/dev/tcp/%s/%d) 2>/dev/null", host, port), fmt.Sprintf("%s:%d to accept connections", host, port), nil
+	case "process_exited":
+		if pid, ok := toInt(args["pid"]); ok {
+			if pid < 1 {
+				return "", "", fmt.Errorf("invalid pid %d", pid)
+			}
+			return fmt.Sprintf("! kill -0 %d 2>/dev/null", pid), fmt.Sprintf("process %d to exit", pid), nil
+		}
+		pattern := optionalString(req, "pattern")
+		if pattern == "" {
+			return "", "", fmt.Errorf("process_exited needs pid or pattern")
+		}
+		// The pattern is decoded at runtime so this loop's own command line
+		// never matches it.
+		encoded := base64.StdEncoding.EncodeToString([]byte(pattern))
+		return fmt.Sprintf("! pgrep -f -- \"$(printf %%s %s | base64 -d)\" >/dev/null", encoded),
+			fmt.Sprintf("no process matching %q", pattern), nil
+	case "url_ok":
+		u, err := requiredString(req, "url")
+		if err != nil {
+			return "", "", err
+		}
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return "", "", fmt.Errorf("invalid url %q: must start with http:// or https://", u)
+		}
+		return fmt.Sprintf("[ \"$(curl -s -o /dev/null -w '%%{http_code}' --max-time 5 %s)\" = 200 ]", shellQuote(u)),
+			fmt.Sprintf("%s to return 200", u), nil
+	}
+	return "", "", fmt.Errorf("invalid condition %q: use file_exists, port_listening, process_exited, or url_ok", condition)
+}
+
+// waitScript wraps check in a polling loop bounded by timeout seconds.
+func waitScript(check string, timeout int, interval float64) string {
+	return fmt.Sprintf("start=$SECONDS; while :; do if %s; then echo $((SECONDS-start)); exit 0; fi; "+
+		"if [ $((SECONDS-start)) -ge %d ]; then exit %d; fi; sleep %g; done",
+		check, timeout, waitTimeoutExit, interval)
+}
@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWaitHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]any
+		mock     *mockExecutor
+		wantErr  string
+		wantText string
+		wantCmd  string
+	}{
+		{
+			name:     "file exists",
+			args:     map[string]any{"condition": "file_exists", "path": "/tmp/ready", "timeout": float64(10)},
+			mock:     &mockExecutor{runBashStdout: "3\n"},
+			wantText: "Condition met: /tmp/ready to exist (after 3s).",
+			wantCmd:  "test -e '/tmp/ready'",
+		},
+		{
+			name:     "port listening on default host",
+			args:     map[string]any{"condition": "port_listening", "port": float64(3000)},
+			mock:     &mockExecutor{runBashStdout: "0\n"},
+			wantText: "127.0.0.1:3000 to accept connections",
+			wantCmd:  "/dev/tcp/127.0.0.1/3000",
+		},
+		{
+			name:     "process exit by pid",
+			args:     map[string]any{"condition": "process_exited", "pid": float64(4242)},
+			mock:     &mockExecutor{runBashStdout: "1\n"},
+			wantText: "process 4242 to exit",
+			wantCmd:  "! kill -0 4242",
+		},
+		{
+			name:     "url ok",
+			args:     map[string]any{"condition": "url_ok", "url": "http://localhost:8080/health"},
+			mock:     &mockExecutor{runBashStdout: "2\n"},
+			wantText: "http://localhost:8080/health to return 200",
+			wantCmd:  "'http://localhost:8080/health'",
+		},
+		{
+			name:    "timeout",
+			args:    map[string]any{"condition": "file_exists", "path": "out.log", "timeout": float64(5)},
+			mock:    &mockExecutor{runBashExit: waitTimeoutExit},
+			wantErr: "[error:timeout] timed out after 5s waiting for out.log to exist",
+		},
+		{
+			name:    "remote failure",
+			args:    map[string]any{"condition": "url_ok", "url": "https://example.com"},
+			mock:    &mockExecutor{runBashExit: 2, runBashStderr: "syntax error"},
+			wantErr: "[error:command_failed] wait failed with exit code 2: syntax error",
+		},
+		{
+			name:    "missing port",
+			args:    map[string]any{"condition": "port_listening"},
+			mock:    &mockExecutor{},
+			wantErr: "[error:invalid_argument] missing required parameter: port",
+		},
+		{
+			name:    "invalid host",
+			args:    map[string]any{"condition": "port_listening", "port": float64(80), "host": "x; rm -rf /"},
+			mock:    &mockExecutor{},
+			wantErr: "invalid host",
+		},
+		{
+			name:    "process needs pid or pattern",
+			args:    map[string]any{"condition": "process_exited"},
+			mock:    &mockExecutor{},
+			wantErr: "needs pid or pattern",
+		},
+		{
+			name:    "url must be http",
+			args:    map[string]any{"condition": "url_ok", "url": "file:///etc/passwd"},
+			mock:    &mockExecutor{},
+			wantErr: "invalid url",
+		},
+		{
+			name:    "timeout above max",
+			args:    map[string]any{"condition": "file_exists", "path": "x", "timeout": float64(maxWaitTimeout + 1)},
+			mock:    &mockExecutor{},
+			wantErr: "timeout must be between",
+		},
+		{
+			name:    "unknown condition",
+			args:    map[string]any{"condition": "cpu_idle"},
+			mock:    &mockExecutor{},
+			wantErr: "invalid condition",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := waitHandler(testReg(tt.mock))
+			result, err := handler(context.Background(), makeReq(tt.args))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			text := resultText(result)
+			if tt.wantErr != "" {
+				if !result.IsError || !strings.Contains(text, tt.wantErr) {
+					t.Fatalf("result = %q (IsError=%v), want error containing %q", text, result.IsError, tt.wantErr)
+				}
+				if tt.mock.runBashCalls != 0 && tt.mock.runBashExit == 0 {
+					t.Errorf("expected no remote command, got %q", tt.mock.lastRunBashCommand)
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("unexpected error result: %s", text)
+			}
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("text = %q, want containing %q", text, tt.wantText)
+			}
+			if tt.mock.runBashCalls != 1 {
+				t.Errorf("RunBash calls = %d, want 1", tt.mock.runBashCalls)
+			}
+			if !strings.Contains(tt.mock.lastRunBashCommand, tt.wantCmd) {
+				t.Errorf("command = %q, want containing %q", tt.mock.lastRunBashCommand, tt.wantCmd)
+			}
+		})
+	}
+}
+
+// TestWaitScriptRuns executes generated loops with the local bash to check the
+// shell syntax and exit statuses.
+func TestWaitScriptRuns(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	run := func(args map[string]any, timeout int) int {
+		t.Helper()
+		check, _, err := waitCheck(args["condition"].(string), makeReq(args))
+		if err != nil {
+			t.Fatalf("waitCheck: %v", err)
+		}
+		err = exec.Command("bash", "-c", waitScript(check, timeout, 0.1)).Run()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		if err != nil {
+			t.Fatalf("running script: %v", err)
+		}
+		return 0
+	}
+
+	existing := filepath.Join(t.TempDir(), "ready")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(map[string]any{"condition": "file_exists", "path": existing}, 1); got != 0 {
+		t.Errorf("existing file: exit %d, want 0", got)
+	}
+	if got := run(map[string]any{"condition": "file_exists", "path": existing + ".missing"}, 1); got != waitTimeoutExit {
+		t.Errorf("missing file: exit %d, want %d", got, waitTimeoutExit)
+	}
+	if _, err := exec.LookPath("pgrep"); err == nil {
+		// The loop's own command line must not count as a match.
+		if got := run(map[string]any{"condition": "process_exited", "pattern": "no-such-process-xyzzy"}, 1); got != 0 {
+			t.Errorf("absent process: exit %d, want 0", got)
+		}
+	}
+}
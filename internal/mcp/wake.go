@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/registry"
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// codespaceWakeEstimate is how long a suspended codespace usually takes to
+// accept SSH again; it is the retry hint given to the model.
+const codespaceWakeEstimate = 60 * time.Second
+
+// codespaceWakeTimeout bounds one background start attempt.
+const codespaceWakeTimeout = 5 * time.Minute
+
+// codespaceStateTimeout bounds the gh codespace view call that confirms a
+// codespace is not running.
+const codespaceStateTimeout = 15 * time.Second
+
+// unavailableSignatures are failure texts that point at a stopped codespace
+// rather than a flaky connection.
+var unavailableSignatures = []string{
+	"codespace is not running",
+	"not available",
+	"is shutdown",
+	"shutting down",
+	"codespace is starting",
+	"connection refused",
+}
+
+// multiplexer is implemented by executors that keep a shared SSH connection,
+// which has to be re-established once a codespace is running again.
+type multiplexer interface {
+	SetupMultiplexing(ctx context.Context) error
+}
+
+// codespaceWaker tracks codespaces that are being started after they were
+// found suspended, so concurrent tool calls share one start attempt.
+type codespaceWaker struct {
+	gh        GHRunner
+	autoStart bool
+	now       func() time.Time
+
+	mu     sync.Mutex
+	waking map[string]time.Time // codespace name -> start requested at
+}
+
+func newCodespaceWaker(gh GHRunner, autoStart bool) *codespaceWaker {
+	return &codespaceWaker{gh: gh, autoStart: autoStart, now: time.Now, waking: make(map[string]time.Time)}
+}
+
+// wakingSince reports when a start was requested for name, if one is running.
+func (w *codespaceWaker) wakingSince(name string) (time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	since, ok := w.waking[name]
+	return since, ok
+}
+
+// state asks gh for the codespace state, such as Available or Shutdown.
+func (w *codespaceWaker) state(ctx context.Context, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, codespaceStateTimeout)
+	defer cancel()
+	out, err := w.gh.Run(ctx, "codespace", "view", "-c", name, "--json", "state", "-q", ".state")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// wake starts cs in the background unless a start is already running. gh
+// codespace ssh starts a stopped codespace before connecting, the same way the
+// launcher does.
+func (w *codespaceWaker) wake(cs *registry.ManagedCodespace) {
+	w.mu.Lock()
+	if _, ok := w.waking[cs.Name]; ok {
+		w.mu.Unlock()
+		return
+	}
+	w.waking[cs.Name] = w.now()
+	w.mu.Unlock()
+
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			delete(w.waking, cs.Name)
+			w.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), codespaceWakeTimeout)
+		defer cancel()
+		if _, err := w.gh.Run(ctx, append(ssh.GHSSHArgs(cs.Name), "--", "echo ready")...); err != nil {
+			fmt.Fprintf(os.Stderr, "codespace-mcp: starting suspended codespace %s failed: %v\n", cs.Name, err)
+			return
+		}
+		if m, ok := cs.Executor.(multiplexer); ok {
+			if err := m.SetupMultiplexing(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "codespace-mcp: multiplexing warning for %s: %v\n", cs.Alias, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "codespace-mcp: codespace %s is running again\n", cs.Name)
+	}()
+}
+
+// wakingResult tells the model to retry once the codespace has started.
+func (w *codespaceWaker) wakingResult(cs *registry.ManagedCodespace, state string, since time.Time) *mcpsdk.CallToolResult {
+	wait := codespaceWakeEstimate - w.now().Sub(since)
+	if wait < 10*time.Second {
+		wait = 10 * time.Second
+	}
+	if state == "" {
+		state = "starting"
+	}
+	return categorizedError(errUnavailable, fmt.Sprintf("codespace %q (%s) was %s and is waking up; retry in ~%ds.",
+		cs.Alias, cs.Name, state, int(wait.Round(time.Second).Seconds())))
+}
+
+// isUnavailableFailure reports whether result looks like the codespace could
+// not be reached at all.
+func isUnavailableFailure(result *mcpsdk.CallToolResult) bool {
+	if result == nil || !result.IsError {
+		return false
+	}
+	if result.Meta != nil {
+		if category, _ := result.Meta.AdditionalFields[errorCategoryMetaKey].(string); category == string(errConnection) {
+			return true
+		}
+	}
+	text := ""
+	for _, c := range result.Content {
+		if tc, ok := c.(mcpsdk.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	lower := strings.ToLower(text)
+	for _, sig := range unavailableSignatures {
+		if strings.Contains(lower, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// wakeMiddleware turns failures against a suspended codespace into a clear
+// "waking up, retry" result. When a codespace tool fails to reach its
+// codespace, it asks gh for the codespace state; if the codespace is not
+// Available it starts it in the background (unless auto-start is off) and
+// answers calls for that codespace immediately until the start completes.
+func wakeMiddleware(reg *registry.Registry, w *codespaceWaker) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			if lifecycleTools[req.Params.Name] {
+				return next(ctx, req)
+			}
+			cs, err := resolveCodespace(reg, req)
+			if err != nil {
+				return next(ctx, req)
+			}
+			if since, ok := w.wakingSince(cs.Name); ok {
+				return w.wakingResult(cs, "", since), nil
+			}
+
+			result, err := next(ctx, req)
+			if err != nil || !isUnavailableFailure(result) {
+				return result, err
+			}
+			state, serr := w.state(ctx, cs.Name)
+			if serr != nil || state == "" || state == "Available" {
+				return result, nil
+			}
+			if !w.autoStart {
+				return categorizedError(errUnavailable, fmt.Sprintf("codespace %q (%s) is %s. Automatic start is disabled; start it with `%s` and retry.",
+					cs.Alias, cs.Name, state, ssh.GHSSHCommand(cs.Name))), nil
+			}
+			w.wake(cs)
+			since, _ := w.wakingSince(cs.Name)
+			return w.wakingResult(cs, state, since), nil
+		}
+	}
+}
+
+// lifecycleTools manage codespaces themselves, or report on their
+// connections, and are not wrapped by wakeMiddleware.
+var lifecycleTools = map[string]bool{
+	"remote_status":             true,
+	"list_codespaces":           true,
+	"list_available_codespaces": true,
+	"get_codespace_options":     true,
+	"create_codespace":          true,
+	"connect_codespace":         true,
+	"delete_codespace":          true,
+}
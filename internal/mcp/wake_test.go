@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+)
+
+// wakeGHRunner reports a codespace state and blocks gh codespace ssh until
+// release is closed.
+type wakeGHRunner struct {
+	state   string
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (g *wakeGHRunner) Run(_ context.Context, args ...string) (string, error) {
+	g.mu.Lock()
+	g.calls = append(g.calls, strings.Join(args, " "))
+	g.mu.Unlock()
+	if args[1] == "ssh" {
+		<-g.release
+		return "ready\n", nil
+	}
+	return g.state + "\n", nil
+}
+
+func (g *wakeGHRunner) count(prefix string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, c := range g.calls {
+		if strings.HasPrefix(c, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func connectionFailure(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+	return toolError("failed to execute command: exit status 255"), nil
+}
+
+func TestWakeMiddlewareStartsSuspendedCodespace(t *testing.T) {
+	gh := &wakeGHRunner{state: "Shutdown", release: make(chan struct{})}
+	handler := wakeMiddleware(testReg(&mockExecutor{}), newCodespaceWaker(gh, true))(connectionFailure)
+
+	result, _ := handler(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	text := resultText(result)
+	if !strings.HasPrefix(text, "[error:unavailable]") || !strings.Contains(text, `was Shutdown and is waking up; retry in ~60s`) {
+		t.Fatalf("result = %q", text)
+	}
+
+	close(gh.release)
+	if n := gh.count("codespace view -c test-cs --json state"); n != 1 {
+		t.Errorf("state checked %d times, want 1", n)
+	}
+}
+
+func TestWakeMiddlewareSharesOneStart(t *testing.T) {
+	gh := &wakeGHRunner{state: "Shutdown", release: make(chan struct{})}
+	reg := testReg(&mockExecutor{})
+	waker := newCodespaceWaker(gh, true)
+	mw := wakeMiddleware(reg, waker)
+
+	mw(connectionFailure)(context.Background(), makeReq(map[string]any{"command": "ls"}))
+
+	called := false
+	result, _ := mw(func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		called = true
+		return toolSuccess("ok"), nil
+	})(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	if called {
+		t.Error("tool should not run while the codespace is waking up")
+	}
+	if !strings.Contains(resultText(result), "is waking up") {
+		t.Errorf("result = %q", resultText(result))
+	}
+	if n := gh.count("codespace ssh"); n > 1 {
+		t.Errorf("started %d times, want once", n)
+	}
+
+	close(gh.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, waking := waker.wakingSince("test-cs"); !waking {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("wake did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	result, _ = mw(func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolSuccess("ok"), nil
+	})(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	if resultText(result) != "ok" {
+		t.Errorf("result after wake = %q, want the tool result", resultText(result))
+	}
+}
+
+func TestWakeMiddlewareAutoStartDisabled(t *testing.T) {
+	gh := &wakeGHRunner{state: "Shutdown", release: make(chan struct{})}
+	handler := wakeMiddleware(testReg(&mockExecutor{}), newCodespaceWaker(gh, false))(connectionFailure)
+
+	result, _ := handler(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	text := resultText(result)
+	if !strings.HasPrefix(text, "[error:unavailable]") || !strings.Contains(text, "gh codespace ssh -c test-cs") {
+		t.Errorf("result = %q", text)
+	}
+	if n := gh.count("codespace ssh"); n != 0 {
+		t.Errorf("started %d times with auto-start disabled", n)
+	}
+}
+
+func TestWakeMiddlewareKeepsOtherFailures(t *testing.T) {
+	gh := &wakeGHRunner{state: "Available"}
+	mw := wakeMiddleware(testReg(&mockExecutor{}), newCodespaceWaker(gh, true))
+
+	result, _ := mw(connectionFailure)(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	if !strings.HasPrefix(resultText(result), "[error:connection]") {
+		t.Errorf("available codespace: result = %q, want the original error", resultText(result))
+	}
+
+	result, _ = mw(func(context.Context, mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		return toolError("file not found: a.go"), nil
+	})(context.Background(), makeReq(map[string]any{"path": "a.go"}))
+	if !strings.HasPrefix(resultText(result), "[error:not_found]") {
+		t.Errorf("result = %q", resultText(result))
+	}
+	if n := gh.count("codespace view"); n != 1 {
+		t.Errorf("state checked %d times, want only for the connection failure", n)
+	}
+
+	failing := wakeMiddleware(testReg(&mockExecutor{}), newCodespaceWaker(&mockGHRunner{results: map[string]mockGHResult{"codespace view": {err: errors.New("gh: not logged in")}}}, true))
+	result, _ = failing(connectionFailure)(context.Background(), makeReq(map[string]any{"command": "ls"}))
+	if !strings.HasPrefix(resultText(result), "[error:connection]") {
+		t.Errorf("unknown state: result = %q, want the original error", resultText(result))
+	}
+}
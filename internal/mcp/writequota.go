@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// writeQuota counts the bytes remote_create, remote_edit, and remote_scaffold
+// write in a session against a limit. Each approval past the limit allows
+// another quota's worth.
+type writeQuota struct {
+	mu      sync.Mutex
+	quota   int64
+	allowed int64
+	written int64
+}
+
+// newWriteQuota returns nil, which disables the check, for a quota of zero.
+func newWriteQuota(quota int64) *writeQuota {
+	if quota <= 0 {
+		return nil
+	}
+	return &writeQuota{quota: quota, allowed: quota}
+}
+
+// reserve counts n bytes against the quota, reporting false if they would
+// exceed it. With approved they are counted anyway and the allowance grows.
+func (q *writeQuota) reserve(n int64, approved bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.written+n > q.allowed {
+		if !approved {
+			return false
+		}
+		q.allowed = q.written + n + q.quota
+	}
+	q.written += n
+	return true
+}
+
+// release returns n bytes reserved for a write that failed.
+func (q *writeQuota) release(n int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.written -= n
+}
+
+func (q *writeQuota) usage() (written, allowed int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.written, q.allowed
+}
+
+// confirmWriteQuotaParam is added to remote_create, remote_edit, and
+// remote_scaffold.
+var confirmWriteQuotaParam = map[string]any{
+	"type":        "boolean",
+	"description": "Write even though the session has used up its write quota. Only needed when a previous call was refused with policy_denied, and only after the user agrees. Ignored when the client can ask the user itself.",
+}
+
+// stringArgSize measures a write by the length of a string argument.
+func stringArgSize(param string) func(mcpsdk.CallToolRequest) int64 {
+	return func(req mcpsdk.CallToolRequest) int64 {
+		return int64(len(optionalString(req, param)))
+	}
+}
+
+// scaffoldWriteSize measures a remote_scaffold call by the content it
+// unpacks. Invalid arguments count as nothing; the handler rejects them.
+func scaffoldWriteSize(req mcpsdk.CallToolRequest) int64 {
+	var entries []scaffoldEntry
+	if archive := optionalString(req, "archive"); archive != "" {
+		entries, _ = scaffoldEntriesFromArchive(archive)
+	} else if files, ok := req.GetArguments()["files"].(map[string]any); ok {
+		entries, _ = scaffoldEntriesFromFiles(files)
+	}
+	var n int64
+	for _, e := range entries {
+		n += int64(len(e.content))
+	}
+	return n
+}
+
+// askUserApproval asks the user through MCP elicitation. asked is false when
+// the client did not declare elicitation support, so nobody could be asked.
+func askUserApproval(ctx context.Context, message string) (approved, asked bool) {
+	session := server.ClientSessionFromContext(ctx)
+	info, ok := session.(server.SessionWithClientInfo)
+	if !ok || info.GetClientCapabilities().Elicitation == nil {
+		return false, false
+	}
+	elicitor, ok := session.(server.SessionWithElicitation)
+	if !ok {
+		return false, false
+	}
+	result, err := elicitor.RequestElicitation(ctx, mcpsdk.ElicitationRequest{
+		Params: mcpsdk.ElicitationParams{
+			Message:         message,
+			RequestedSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+		},
+	})
+	return err == nil && result.Action == mcpsdk.ElicitationResponseActionAccept, true
+}
+
+// withWriteQuota refuses a write of size(req) bytes once the session's
+// writes would pass the quota. When the client supports elicitation the user
+// is asked directly and confirm_write_quota is ignored; otherwise the call
+// needs confirm_write_quota, which the agent sets and the server cannot
+// verify came from the user.
+func withWriteQuota(q *writeQuota, size func(mcpsdk.CallToolRequest) int64, next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if q == nil {
+		return next
+	}
+	return func(ctx context.Context, req mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+		n := size(req)
+		if !q.reserve(n, false) {
+			written, allowed := q.usage()
+			approved, asked := askUserApproval(ctx, fmt.Sprintf(
+				"The agent has written %s to the codespace this session and wants to write %s more with %s, past the write quota of %s. Allow another %s?",
+				humanBytes(written), humanBytes(n), req.Params.Name, humanBytes(allowed), humanBytes(q.quota)))
+			if !asked {
+				approved = optionalBool(req, "confirm_write_quota", false)
+			}
+			if !approved {
+				if asked {
+					return categorizedError(errPolicyDenied, fmt.Sprintf(
+						"the user did not allow writing %s more past this session's write quota of %s; don't retry this write unless they ask for it.",
+						humanBytes(n), humanBytes(allowed))), nil
+				}
+				return categorizedError(errPolicyDenied, fmt.Sprintf(
+					"this session has written %s with remote_create, remote_edit, and remote_scaffold, and %s more would pass its write quota of %s. "+
+						"Check that the writes are intended (not a runaway loop or generated output that belongs elsewhere), then ask the user and re-run with confirm_write_quota: true, which allows another %s.",
+					humanBytes(written), humanBytes(n), humanBytes(allowed), humanBytes(q.quota))), nil
+			}
+			q.reserve(n, true)
+		}
+		result, err := next(ctx, req)
+		if err != nil || result == nil || result.IsError {
+			q.release(n)
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	mcpsdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestWithWriteQuota(t *testing.T) {
+	mock := &mockExecutor{}
+	create := withWriteQuota(newWriteQuota(10), stringArgSize("file_text"), createHandler(testReg(mock)))
+	call := func(text string, confirm bool) (string, bool) {
+		args := map[string]any{"path": "/tmp/f", "file_text": text}
+		if confirm {
+			args["confirm_write_quota"] = true
+		}
+		res, _ := create(context.Background(), makeReq(args))
+		return resultText(res), res.IsError
+	}
+
+	if text, isErr := call("123456", false); isErr {
+		t.Fatalf("write within quota refused: %s", text)
+	}
+	text, isErr := call("123456", false)
+	if !isErr || !strings.Contains(text, "policy_denied") || !strings.Contains(text, "confirm_write_quota") {
+		t.Fatalf("write past quota = %s, want policy_denied", text)
+	}
+	if text, isErr := call("123456", true); isErr {
+		t.Fatalf("confirmed write refused: %s", text)
+	}
+	// The approval allows another quota past the confirmed write.
+	if text, isErr := call("1234567890", false); isErr {
+		t.Fatalf("write within the new allowance refused: %s", text)
+	}
+	if _, isErr := call("x", false); !isErr {
+		t.Fatal("write past the new allowance was not refused")
+	}
+}
+
+func TestWithWriteQuota_FailedWritesDoNotCount(t *testing.T) {
+	mock := &mockExecutor{createFileErr: errors.New("disk full")}
+	create := withWriteQuota(newWriteQuota(10), stringArgSize("file_text"), createHandler(testReg(mock)))
+	for range 3 {
+		res, _ := create(context.Background(), makeReq(map[string]any{"path": "/tmp/f", "file_text": "12345678"}))
+		if text := resultText(res); strings.Contains(text, "policy_denied") {
+			t.Fatalf("failed writes counted against the quota: %s", text)
+		}
+	}
+}
+
+func TestNewWriteQuota_ZeroDisables(t *testing.T) {
+	if newWriteQuota(0) != nil {
+		t.Fatal("newWriteQuota(0) should disable the check")
+	}
+	next := createHandler(testReg(&mockExecutor{}))
+	res, _ := withWriteQuota(nil, stringArgSize("file_text"), next)(context.Background(), makeReq(map[string]any{"path": "/tmp/f", "file_text": strings.Repeat("x", 1<<20)}))
+	if res.IsError {
+		t.Fatalf("write without quota refused: %s", resultText(res))
+	}
+}
+
+func TestWithWriteQuota_Scaffold(t *testing.T) {
+	mock := &mockExecutor{runBashStdout: "a.txt\n"}
+	scaffold := withWriteQuota(newWriteQuota(10), scaffoldWriteSize, scaffoldHandler(testReg(mock)))
+	res, _ := scaffold(context.Background(), makeReq(map[string]any{"directory": "pkg", "files": map[string]any{"a.txt": "123456", "b.txt": "123456"}}))
+	if !res.IsError || !strings.Contains(resultText(res), "policy_denied") {
+		t.Fatalf("scaffold past quota = %s, want policy_denied", resultText(res))
+	}
+	archive, err := scaffoldArchive([]scaffoldEntry{{path: "a.txt", content: []byte("12345678901")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := scaffoldWriteSize(makeReq(map[string]any{"archive": base64.StdEncoding.EncodeToString(archive)})); n != 11 {
+		t.Errorf("archive size = %d, want its unpacked 11 bytes", n)
+	}
+}
+
+// elicitingSession is a client session that answers every elicitation with
+// action.
+type elicitingSession struct {
+	action mcpsdk.ElicitationResponseAction
+	asked  int
+}
+
+func (s *elicitingSession) Initialize()       {}
+func (s *elicitingSession) Initialized() bool { return true }
+func (s *elicitingSession) NotificationChannel() chan<- mcpsdk.JSONRPCNotification {
+	return make(chan mcpsdk.JSONRPCNotification, 1)
+}
+func (s *elicitingSession) SessionID() string                               { return "eliciting" }
+func (s *elicitingSession) GetClientInfo() mcpsdk.Implementation            { return mcpsdk.Implementation{} }
+func (s *elicitingSession) SetClientInfo(mcpsdk.Implementation)             {}
+func (s *elicitingSession) SetClientCapabilities(mcpsdk.ClientCapabilities) {}
+func (s *elicitingSession) GetClientCapabilities() mcpsdk.ClientCapabilities {
+	return mcpsdk.ClientCapabilities{Elicitation: &mcpsdk.ElicitationCapability{}}
+}
+func (s *elicitingSession) RequestElicitation(context.Context, mcpsdk.ElicitationRequest) (*mcpsdk.ElicitationResult, error) {
+	s.asked++
+	return &mcpsdk.ElicitationResult{ElicitationResponse: mcpsdk.ElicitationResponse{Action: s.action}}, nil
+}
+
+func TestWithWriteQuota_AsksUserThroughElicitation(t *testing.T) {
+	for _, tc := range []struct {
+		action  mcpsdk.ElicitationResponseAction
+		confirm bool
+		allowed bool
+	}{
+		{mcpsdk.ElicitationResponseActionAccept, false, true},
+		// The agent's confirm_write_quota can't stand in for the user's answer.
+		{mcpsdk.ElicitationResponseActionDecline, true, false},
+		{mcpsdk.ElicitationResponseActionCancel, true, false},
+	} {
+		session := &elicitingSession{action: tc.action}
+		ctx := server.NewMCPServer("test", "0").WithContext(context.Background(), session)
+		create := withWriteQuota(newWriteQuota(10), stringArgSize("file_text"), createHandler(testReg(&mockExecutor{})))
+		create(ctx, makeReq(map[string]any{"path": "/tmp/f", "file_text": "123456"}))
+		res, _ := create(ctx, makeReq(map[string]any{"path": "/tmp/f", "file_text": "123456", "confirm_write_quota": tc.confirm}))
+		if session.asked != 1 || res.IsError == tc.allowed {
+			t.Errorf("%s: asked %d times, result %q", tc.action, session.asked, resultText(res))
+		}
+	}
+}
@@ -67,10 +67,18 @@ func (p *TerminfoProvisioner) Run(ctx context.Context, target CodespaceTarget) e
 	return nil
 }
 
+// TerminalEnv names the terminal to provision for and match against instead
+// of the detected one. The launcher sets it from its config file's terminal.
+const TerminalEnv = "COPILOT_CODESPACE_TERMINAL"
+
 // DetectedTerminal normalizes the current local terminal into the identifier
 // used for provisioner matching. Ghostty sessions always normalize to
-// xterm-ghostty even when the local TERM is overridden.
+// xterm-ghostty even when the local TERM is overridden. TerminalEnv, when set,
+// wins over both.
 func DetectedTerminal(term string) string {
+	if configured := strings.TrimSpace(os.Getenv(TerminalEnv)); configured != "" {
+		return configured
+	}
 	if isGhosttySession() {
 		return "xterm-ghostty"
 	}
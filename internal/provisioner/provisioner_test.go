@@ -68,6 +68,16 @@ func TestDetectedTerminal_GhosttyNormalizesToXtermGhostty(t *testing.T) {
 	}
 }
 
+func TestDetectedTerminal_ConfiguredTerminalWins(t *testing.T) {
+	t.Setenv("TERM", "xterm-color")
+	t.Setenv("TERM_PROGRAM", "ghostty")
+	t.Setenv(TerminalEnv, "xterm-kitty")
+
+	if got := DetectedTerminal(os.Getenv("TERM")); got != "xterm-kitty" {
+		t.Fatalf("DetectedTerminal() = %q, want %q", got, "xterm-kitty")
+	}
+}
+
 func TestTerminfoProvisioner_Run_UploadsGhosttyTerminfoWhenTERMOverridden(t *testing.T) {
 	p := &TerminfoProvisioner{}
 	target := &mockCSInfo{}
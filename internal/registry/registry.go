@@ -5,6 +5,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
 )
@@ -18,6 +19,52 @@ type ManagedCodespace struct {
 	Workdir    string       // detected workspace directory on the codespace
 	Executor   ssh.Executor // SSH client for this codespace
 	ExecAgent  string       // remote path to deployed binary (may be empty)
+
+	CPUs        int   // CPU cores probed at connect time (0 if unknown)
+	MemoryBytes int64 // total memory probed at connect time (0 if unknown)
+
+	TimeZone    string        // zone abbreviation probed at connect time ("" if unknown)
+	UTCOffset   int           // zone offset east of UTC in seconds
+	ClockOffset time.Duration // codespace clock minus local clock
+
+	Remotes       []GitRemote // fetch remotes probed at connect time
+	DefaultBranch string      // repository default branch ("" if unknown)
+
+	DevcontainerHooks []DevcontainerHook // lifecycle commands read from devcontainer.json at connect time
+}
+
+// GitRemote is a fetch remote of the codespace's repository.
+type GitRemote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// DevcontainerHook is a lifecycle command property of devcontainer.json, such
+// as postCreateCommand, with the commands it declares.
+type DevcontainerHook struct {
+	Property string                `json:"property"`
+	Commands []DevcontainerCommand `json:"commands"`
+}
+
+// DevcontainerCommand is one command of a hook: a string run through
+// /bin/sh -c, or an argument list run without a shell. Name is set for the
+// object form, whose commands run in parallel.
+type DevcontainerCommand struct {
+	Name  string   `json:"name,omitempty"`
+	Shell string   `json:"shell,omitempty"`
+	Args  []string `json:"args,omitempty"`
+}
+
+// defaultParallelism applies when the machine size is unknown.
+const defaultParallelism = 4
+
+// Parallelism returns how many operations a batch tool should run at once on
+// the codespace: one per CPU core, or defaultParallelism when unknown.
+func (cs *ManagedCodespace) Parallelism() int {
+	if cs.CPUs > 0 {
+		return cs.CPUs
+	}
+	return defaultParallelism
 }
 
 // Registry manages multiple codespace connections keyed by alias.
@@ -137,6 +184,19 @@ func (r *Registry) Len() int {
 	return len(r.codespaces)
 }
 
+// unpublishedRepository labels codespaces with no GitHub repository, such as
+// ones created from a template and not yet published.
+const unpublishedRepository = "(unpublished)"
+
+// RepositoryLabel returns repository for display, or "(unpublished)" for a
+// codespace whose repository hasn't been published to GitHub.
+func RepositoryLabel(repository string) string {
+	if repository == "" {
+		return unpublishedRepository
+	}
+	return repository
+}
+
 // DefaultAlias derives an alias from a repository name (e.g., "github/github" → "github").
 // If the derived alias conflicts with existing aliases, a numeric suffix is appended.
 func DefaultAlias(repository string, existing []string) string {
@@ -183,3 +183,21 @@ func TestDefaultAlias_MultipleConflicts(t *testing.T) {
 		t.Errorf("got %q, want %q", got, "github-4")
 	}
 }
+
+func TestParallelism(t *testing.T) {
+	if got := (&ManagedCodespace{CPUs: 8}).Parallelism(); got != 8 {
+		t.Errorf("Parallelism() with 8 CPUs = %d, want 8", got)
+	}
+	if got := (&ManagedCodespace{}).Parallelism(); got != defaultParallelism {
+		t.Errorf("Parallelism() with unknown CPUs = %d, want %d", got, defaultParallelism)
+	}
+}
+
+func TestRepositoryLabel(t *testing.T) {
+	if got := RepositoryLabel("github/github"); got != "github/github" {
+		t.Errorf("RepositoryLabel() = %q", got)
+	}
+	if got := RepositoryLabel(""); got != "(unpublished)" {
+		t.Errorf("RepositoryLabel(\"\") = %q, want (unpublished)", got)
+	}
+}
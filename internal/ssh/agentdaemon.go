@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/agentd"
+)
+
+// agentDaemonStartTimeout bounds how long StartAgentDaemon waits for a
+// freshly started daemon to answer through the forwarded socket.
+const agentDaemonStartTimeout = 5 * time.Second
+
+// RemoteAgentSocket is where the daemon of the exec agent at agentPath
+// listens on the codespace. Sessions sharing the agent share the daemon; one
+// that runs commands in the login environment has a socket of its own.
+func RemoteAgentSocket(agentPath string, loginEnv bool) string {
+	if loginEnv {
+		return path.Join(path.Dir(agentPath), "agent-login.sock")
+	}
+	return path.Join(path.Dir(agentPath), "agent.sock")
+}
+
+// StartAgentDaemon runs the exec agent at agentPath as a daemon on the
+// codespace, unless one is already listening, and forwards its socket over the
+// SSH master. From then on Exec, RunBash, and the file and search tools send
+// their commands to the daemon instead of starting an ssh process per call;
+// RunBashTTY and ExecStream stay on ssh. With loginEnv, the daemon runs them
+// in the codespace user's login environment (see codespaceenv.LoginEnv). It
+// needs multiplexing. If the daemon later stops answering, the client goes
+// back to ssh.
+func (c *Client) StartAgentDaemon(ctx context.Context, agentPath string, loginEnv bool) error {
+	sshConfigPath, _, controlSocket := c.sshState()
+	if sshConfigPath == "" || controlSocket == "" {
+		return fmt.Errorf("SSH multiplexing not active")
+	}
+	remote := RemoteAgentSocket(agentPath, loginEnv)
+	flags := "--socket " + shellQuote(remote)
+	if loginEnv {
+		flags += " --login-env"
+	}
+	// The subshell lets the ssh session end at once; the daemon holds only
+	// /dev/null, and exits by itself if another one already answers.
+	start := fmt.Sprintf("(nohup %s agent %s </dev/null >/dev/null 2>&1 &)", shellQuote(agentPath), flags)
+	if _, stderr, exitCode, err := c.Exec(ctx, start); err != nil || exitCode != 0 {
+		if err == nil {
+			err = formatCommandFailure("starting agent daemon", exitCode, stderr)
+		}
+		return err
+	}
+
+	local := filepath.Join(filepath.Dir(controlSocket), fmt.Sprintf(".agent-%s-%d", c.codespaceName, os.Getpid()))
+	if err := c.ForwardSocket(ctx, local, remote); err != nil {
+		return err
+	}
+	if err := waitForAgentDaemon(ctx, local, agentDaemonStartTimeout); err != nil {
+		c.CancelForward(ctx, local, remote)
+		os.Remove(local)
+		return err
+	}
+
+	c.mu.Lock()
+	c.agentSocket, c.agentRemote = local, remote
+	c.mu.Unlock()
+	return nil
+}
+
+// waitForAgentDaemon pings socketPath until the daemon answers or timeout
+// passes, since the daemon may still be starting.
+func waitForAgentDaemon(ctx context.Context, socketPath string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, time.Second)
+		_, err := agentd.Ping(pingCtx, socketPath)
+		cancel()
+		if err == nil || ctx.Err() != nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// StopAgentDaemon cancels the socket forwarding set up by StartAgentDaemon.
+// The daemon keeps running for other sessions on the codespace.
+func (c *Client) StopAgentDaemon(ctx context.Context) {
+	c.mu.Lock()
+	local, remote := c.agentSocket, c.agentRemote
+	c.agentSocket, c.agentRemote = "", ""
+	c.mu.Unlock()
+	if local == "" {
+		return
+	}
+	c.CancelForward(ctx, local, remote)
+	os.Remove(local)
+}
+
+// AgentDaemonSocket returns the local end of the daemon's forwarded socket,
+// or "" when commands go over ssh.
+func (c *Client) AgentDaemonSocket() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.agentSocket
+}
+
+// runOnAgentDaemon runs wrapped with bash on the daemon. When the daemon
+// stops answering, the client stops using it. An error wrapping
+// agentd.ErrUnreachable means the command was never sent, so the caller can
+// run it over ssh instead; a connection lost after that is an error of its
+// own, as the command may have run.
+func (c *Client) runOnAgentDaemon(ctx context.Context, socket, wrapped string, input []byte) (stdout string, stderr string, exitCode int, err error) {
+	res, err := agentd.Exec(ctx, socket, agentd.ExecParams{Argv: []string{"bash", "-c", wrapped}, Stdin: input})
+	var rpcErr *agentd.Error
+	switch {
+	case err == nil:
+		return sanitizeOutput(string(res.Stdout)), sanitizeOutput(string(res.Stderr)), res.ExitCode, nil
+	case ctx.Err() != nil:
+		return "", "", -1, fmt.Errorf("command cancelled: %w", ctx.Err())
+	case errors.As(err, &rpcErr):
+		return "", "", -1, fmt.Errorf("failed to execute command: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "codespace-mcp: agent daemon for %s stopped answering, running commands over ssh: %v\n", c.codespaceName, err)
+	c.mu.Lock()
+	if c.agentSocket == socket {
+		c.agentSocket, c.agentRemote = "", ""
+	}
+	c.mu.Unlock()
+	return "", "", -1, fmt.Errorf("failed to execute command: %w", err)
+}
@@ -0,0 +1,119 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/agentd"
+)
+
+// agentSocketPath returns a socket path short enough for macOS's 104-byte
+// limit, which t.TempDir can exceed.
+func agentSocketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "agent.sock")
+}
+
+func listenAgent(t *testing.T, serve func(net.Listener)) string {
+	t.Helper()
+	socketPath := agentSocketPath(t)
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go serve(ln)
+	return socketPath
+}
+
+func newAgentClient(t *testing.T, socketPath string, calls *[]fakeExecCall, responses []fakeExecResponse) *Client {
+	t.Helper()
+	client := NewClient("cs-demo")
+	client.sshConfigPath = "/tmp/ssh-config"
+	client.sshHost = "cs.demo"
+	client.agentSocket = socketPath
+	client.commandContext = fakeCommandContext(t, calls, responses)
+	return client
+}
+
+func TestRunBash_UsesAgentDaemon(t *testing.T) {
+	socketPath := listenAgent(t, func(ln net.Listener) { agentd.Serve(ln) })
+	var calls []fakeExecCall
+	client := newAgentClient(t, socketPath, &calls, nil)
+
+	dir := t.TempDir()
+	stdout, stderr, exitCode, err := client.RunBash(context.Background(), `pwd; printf '%s\n' "it's"; echo oops >&2; exit 4`, dir)
+	if err != nil {
+		t.Fatalf("RunBash() error = %v", err)
+	}
+	wantDir, _ := filepath.EvalSymlinks(dir)
+	if stdout != wantDir+"\nit's\n" || strings.TrimSpace(stderr) != "oops" || exitCode != 4 {
+		t.Fatalf("RunBash() = %q, %q, %d", stdout, stderr, exitCode)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("RunBash() started %v, want no ssh process", calls)
+	}
+	if client.LastSuccess().IsZero() {
+		t.Error("LastSuccess() not updated by a daemon call")
+	}
+}
+
+func TestRunBash_FallsBackToSSHWhenDaemonIsGone(t *testing.T) {
+	var calls []fakeExecCall
+	client := newAgentClient(t, agentSocketPath(t), &calls, []fakeExecResponse{{stdout: "via ssh\n"}})
+
+	stdout, _, exitCode, err := client.RunBash(context.Background(), "echo hi", "/workspaces/repo")
+	if err != nil || stdout != "via ssh\n" || exitCode != 0 {
+		t.Fatalf("RunBash() = %q, %d, %v", stdout, exitCode, err)
+	}
+	if len(calls) != 1 || calls[0].name != "ssh" {
+		t.Fatalf("calls = %v, want one ssh call", calls)
+	}
+	if client.AgentDaemonSocket() != "" {
+		t.Error("daemon still in use after it stopped answering")
+	}
+}
+
+func TestRunBash_DaemonLostMidCommandIsNotRetried(t *testing.T) {
+	socketPath := listenAgent(t, func(ln net.Listener) {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(conn, `{"jsonrpc":"2.0","method":"ready","params":{"version":%d}}`+"\n", agentd.ProtocolVersion)
+		bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+	})
+	var calls []fakeExecCall
+	client := newAgentClient(t, socketPath, &calls, nil)
+
+	if _, _, _, err := client.RunBash(context.Background(), "rm -rf build", "/workspaces/repo"); err == nil {
+		t.Fatal("RunBash() error = nil, want the lost connection reported")
+	}
+	if len(calls) != 0 {
+		t.Fatalf("command re-run over ssh after it was sent: %v", calls)
+	}
+	if client.AgentDaemonSocket() != "" {
+		t.Error("daemon still in use after the connection was lost")
+	}
+}
+
+func TestRemoteAgentSocket(t *testing.T) {
+	if got := RemoteAgentSocket("/tmp/gh-copilot-codespace-bin/gh-copilot-codespace", false); got != "/tmp/gh-copilot-codespace-bin/agent.sock" {
+		t.Fatalf("RemoteAgentSocket() = %q", got)
+	}
+	if got := RemoteAgentSocket("/tmp/gh-copilot-codespace-bin/gh-copilot-codespace", true); got != "/tmp/gh-copilot-codespace-bin/agent-login.sock" {
+		t.Fatalf("RemoteAgentSocket() with login env = %q", got)
+	}
+}
@@ -6,15 +6,20 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ekroon/gh-copilot-codespace/internal/agentd"
 	"github.com/ekroon/gh-copilot-codespace/internal/codespaceenv"
 )
 
@@ -22,10 +27,20 @@ import (
 type Client struct {
 	codespaceName  string
 	mu             sync.Mutex
-	sshConfigPath  string // path to generated SSH config with ControlMaster
-	sshHost        string // SSH host alias (e.g., "cs.develop-xxx")
-	controlSocket  string // path to control socket
-	workdir        string // current working directory on the codespace
+	sshConfigPath  string          // path to generated SSH config with ControlMaster
+	sshHost        string          // SSH host alias (e.g., "cs.develop-xxx")
+	controlSocket  string          // path to control socket
+	workdir        string          // current working directory on the codespace
+	pinHostKeys    bool            // verify the host key against a per-codespace known_hosts file
+	connectOpts    []string        // -o flags for calls over the multiplexed connection; see connectOptionsFor
+	remoteUser     RemoteUser      // detected user and home; zero until DetectRemoteUser or SetRemoteUser
+	recordSessions bool            // record async sessions; see SetSessionRecording
+	recorded       map[string]bool // tmux sessions recorded since the client was created
+	nohupSessions  bool            // tmux could not be provisioned; sessions run under nohup (see nohup.go)
+	lastSuccess    time.Time       // when a remote command last got through; see LastSuccess
+	agentSocket    string          // local end of the forwarded agent daemon socket; see StartAgentDaemon
+	agentRemote    string          // the daemon's socket on the codespace
+	native         *nativeConn     // in-process SSH connection; see ConnectNative
 	commandContext func(ctx context.Context, name string, args ...string) *exec.Cmd
 }
 
@@ -35,10 +50,11 @@ type Executor interface {
 	EditFile(ctx context.Context, path, oldStr, newStr string) error
 	CreateFile(ctx context.Context, path, content string) error
 	RunBash(ctx context.Context, command, cwd string) (stdout, stderr string, exitCode int, err error)
-	Grep(ctx context.Context, pattern, path, glob, cwd string) (string, error)
-	Glob(ctx context.Context, pattern, path, cwd string) (string, error)
+	RunBashTTY(ctx context.Context, command, cwd string) (stdout, stderr string, exitCode int, err error)
+	Grep(ctx context.Context, pattern, path, glob, cwd string, opts SearchOptions) (string, error)
+	Glob(ctx context.Context, patterns []string, path, cwd string, opts SearchOptions) (string, error)
 	StartSession(ctx context.Context, sessionID, command, cwd string) error
-	WriteSession(ctx context.Context, sessionID, input string) error
+	WriteSession(ctx context.Context, sessionID, input string, opts WriteOptions) error
 	ReadSession(ctx context.Context, sessionID string) (string, error)
 	StopSession(ctx context.Context, sessionID string) error
 	ListSessions(ctx context.Context) (string, error)
@@ -54,6 +70,15 @@ func NewClient(codespaceName string) *Client {
 	}
 }
 
+// NewClientWithConfig creates a client for a host reachable through an existing
+// SSH config file, bypassing gh codespace ssh --config. Used for non-codespace
+// hosts such as the local sshd test harness.
+func NewClientWithConfig(name, sshConfigPath, sshHost string) *Client {
+	c := NewClient(name)
+	c.setSSHState(sshConfigPath, sshHost, "")
+	return c
+}
+
 func (c *Client) command(ctx context.Context, name string, args ...string) *exec.Cmd {
 	if c.commandContext != nil {
 		return c.commandContext(ctx, name, args...)
@@ -103,41 +128,69 @@ func (c *Client) setSSHConfigPath(sshConfigPath string) {
 	c.sshConfigPath = sshConfigPath
 }
 
+// WorkdirsDir is the directory under homeDir that holds each codespace's
+// instruction mirror, next to its SSH control socket, config, and pinned host keys.
+func WorkdirsDir(homeDir string) string {
+	return filepath.Join(homeDir, ".copilot", "codespace-workdirs")
+}
+
 // SetupMultiplexing generates an SSH config with ControlMaster and establishes
 // a persistent connection. Subsequent Exec calls use this connection (~0.1s vs ~3s).
+// With COPILOT_CODESPACE_NATIVE_SSH set, it also opens a native connection
+// (see ConnectNative), and keeps going on ssh alone if that fails.
 func (c *Client) SetupMultiplexing(ctx context.Context) error {
+	if err := c.setupMultiplexing(ctx); err != nil {
+		return err
+	}
+	if !nativeSSHEnabled() {
+		return nil
+	}
+	if err := c.ConnectNative(ctx); err != nil {
+		if errors.Is(err, ErrHostKeyChanged) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "codespace-mcp: native SSH unavailable, using ssh: %v\n", err)
+	}
+	return nil
+}
+
+func (c *Client) setupMultiplexing(ctx context.Context) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("getting home dir: %w", err)
 	}
 
-	configDir := filepath.Join(homeDir, ".copilot", "codespace-workdirs")
+	configDir := WorkdirsDir(homeDir)
 	if err := os.MkdirAll(configDir, 0o755); err != nil {
 		return fmt.Errorf("creating config dir: %w", err)
 	}
 
 	controlSocket := filepath.Join(configDir, ".ssh-"+c.codespaceName)
 	sshConfigPath := filepath.Join(configDir, ".ssh-config-"+c.codespaceName)
+	knownHostsPath := filepath.Join(configDir, ".known_hosts-"+c.codespaceName)
+	pinHostKeys := c.hostKeyPinningEnabled(knownHostsPath)
+	connectOpts := connectOptionsFor(pinHostKeys)
 
 	// Reuse existing multiplexed connection if alive (e.g., set up by the launcher).
 	// Avoids calling gh codespace ssh --config which creates a new tunnel and may
 	// invalidate the existing ControlMaster's connection and its socket forwardings.
 	var sshHost string
 	if data, err := os.ReadFile(sshConfigPath); err == nil {
-		for _, line := range strings.Split(string(data), "\n") {
-			if strings.HasPrefix(strings.TrimSpace(line), "Host ") {
-				sshHost = strings.TrimPrefix(strings.TrimSpace(line), "Host ")
-				break
-			}
+		_, sshHost, _ = parseSSHConfig(string(data)).codespaceHost(c.codespaceName)
+		// A config written without pinning must not be reused once pinning is on.
+		if pinHostKeys && !strings.Contains(string(data), knownHostsPath) {
+			sshHost = ""
 		}
 		if sshHost != "" {
 			check := c.command(ctx, "ssh", "-F", sshConfigPath, "-O", "check", sshHost)
 			if check.Run() == nil {
 				// Smoke-test the tunnel: ssh -O check only verifies the master
 				// process is alive, not that the underlying relay still works.
-				probe := c.command(ctx, "ssh", "-F", sshConfigPath, "-o", "ConnectTimeout=5", sshHost, "echo ok")
+				probeArgs := append([]string{"-F", sshConfigPath, "-o", "ConnectTimeout=5"}, connectOpts...)
+				probe := c.command(ctx, "ssh", append(probeArgs, sshHost, "echo ok")...)
 				if out, err := probe.Output(); err == nil && strings.TrimSpace(string(out)) == "ok" {
 					c.setSSHState(sshConfigPath, sshHost, controlSocket)
+					c.setConnectOptions(connectOpts)
 					fmt.Fprintf(os.Stderr, "codespace-mcp: reusing existing SSH multiplexing\n")
 					return nil
 				}
@@ -147,32 +200,15 @@ func (c *Client) SetupMultiplexing(ctx context.Context) error {
 	}
 
 	// Get SSH config from gh (contains ProxyCommand, identity file, etc.)
-	ghConfig, err := c.command(ctx, "gh", "codespace", "ssh",
-		"--config", "-c", c.codespaceName).Output()
+	configArgs := append([]string{"codespace", "ssh", "--config", "-c", c.codespaceName}, ghSSHFlags()...)
+	ghConfig, err := c.command(ctx, "gh", configArgs...).Output()
 	if err != nil {
 		return fmt.Errorf("getting SSH config: %w", err)
 	}
 
-	config := string(ghConfig)
-
-	// Parse the Host line from gh config (e.g., "Host cs.develop-xxx.main")
-	for _, line := range strings.Split(config, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "Host ") {
-			sshHost = strings.TrimPrefix(line, "Host ")
-			break
-		}
-	}
-	if sshHost == "" {
-		return fmt.Errorf("could not parse Host from SSH config")
-	}
-
-	// Add ControlPath + ControlPersist if not present
-	if !strings.Contains(config, "ControlPath") {
-		config += fmt.Sprintf("\tControlPath %s\n", controlSocket)
-	}
-	if !strings.Contains(config, "ControlPersist") {
-		config += "\tControlPersist 600\n"
+	config, sshHost, err := multiplexConfig(string(ghConfig), c.codespaceName, controlSocket, knownHostsPath, pinHostKeys)
+	if err != nil {
+		return err
 	}
 
 	if err := os.WriteFile(sshConfigPath, []byte(config), 0o600); err != nil {
@@ -192,6 +228,10 @@ func (c *Client) SetupMultiplexing(ctx context.Context) error {
 		cmd.Stderr = &sshErr
 		if err := cmd.Run(); err != nil {
 			errDetail := strings.TrimSpace(sshErr.String())
+			if pinHostKeys && isHostKeyMismatch(errDetail) {
+				// Never fall back to gh's unverified connection on a mismatch.
+				return hostKeyChangedError(c.codespaceName, knownHostsPath)
+			}
 			if attempt == 0 {
 				fmt.Fprintf(os.Stderr, "codespace-mcp: SSH multiplexing attempt 1 failed (%v), retrying...\n", errDetail)
 				time.Sleep(3 * time.Second)
@@ -199,6 +239,9 @@ func (c *Client) SetupMultiplexing(ctx context.Context) error {
 			}
 			// Final attempt failed — fall back to non-multiplexed mode
 			fmt.Fprintf(os.Stderr, "codespace-mcp: SSH multiplexing failed, using fallback: %v (%s)\n", err, errDetail)
+			if pinHostKeys {
+				fmt.Fprintf(os.Stderr, "codespace-mcp: warning: host key pinning does not apply to the fallback connection for %s\n", c.codespaceName)
+			}
 			c.setSSHState("", sshHost, controlSocket)
 			return nil
 		}
@@ -206,10 +249,35 @@ func (c *Client) SetupMultiplexing(ctx context.Context) error {
 	}
 
 	c.setSSHState(sshConfigPath, sshHost, controlSocket)
+	c.setConnectOptions(connectOpts)
 	fmt.Fprintf(os.Stderr, "codespace-mcp: SSH multiplexing established\n")
 	return nil
 }
 
+// multiplexConfig adds ControlPath and ControlPersist, and host key pinning
+// when pin is set, to the codespace's Host block of a gh-generated SSH config.
+// Other blocks are kept as gh wrote them. It returns the config and the host
+// alias to connect with.
+func multiplexConfig(ghConfig, codespaceName, controlSocket, knownHostsPath string, pin bool) (string, string, error) {
+	config := parseSSHConfig(ghConfig)
+	block, sshHost, err := config.codespaceHost(codespaceName)
+	if err != nil {
+		return "", "", err
+	}
+	var options []string
+	if !block.hasOption("ControlPath") {
+		options = append(options, "ControlPath "+controlSocket)
+	}
+	if !block.hasOption("ControlPersist") {
+		options = append(options, "ControlPersist 600")
+	}
+	block.addOptions(options...)
+	if pin {
+		pinHostKey(block, knownHostsPath, codespaceName)
+	}
+	return config.String(), sshHost, nil
+}
+
 // ControlSocketPath returns the path to the SSH control socket, if multiplexing is active.
 func (c *Client) ControlSocketPath() string {
 	sshConfigPath, _, controlSocket := c.sshState()
@@ -243,73 +311,113 @@ var sshTransportErrorMarkers = []string{
 }
 
 func (c *Client) runRemoteCommand(ctx context.Context, wrapped string, useMultiplex bool) (stdout string, stderr string, exitCode int, err error) {
-	var cmd *exec.Cmd
-	if useMultiplex {
-		sshConfigPath, sshHost, _ := c.sshState()
-		cmd = c.command(ctx, "ssh", "-F", sshConfigPath, sshHost, wrapped)
-	} else {
-		cmd = c.command(ctx, "gh", "codespace", "ssh",
-			"-c", c.codespaceName,
-			"--", wrapped,
-		)
+	if socket := c.AgentDaemonSocket(); socket != "" && useMultiplex {
+		stdout, stderr, exitCode, err = c.runOnAgentDaemon(ctx, socket, wrapped, nil)
+		if !errors.Is(err, agentd.ErrUnreachable) {
+			c.noteResult(exitCode, err)
+			return stdout, stderr, exitCode, err
+		}
 	}
+	if n := c.nativeConnection(); n != nil {
+		var outBuf bytes.Buffer
+		stderr, exitCode, err = c.runOnNative(ctx, n, wrapped, nil, &outBuf)
+		if !errors.Is(err, errNativeUnreachable) {
+			c.noteResult(exitCode, err)
+			return sanitizeOutput(outBuf.String()), stderr, exitCode, err
+		}
+	}
+	stdout, stderr, exitCode, err = runCaptured(ctx, c.remoteCommand(ctx, wrapped, useMultiplex))
+	c.noteResult(exitCode, err)
+	return stdout, stderr, exitCode, err
+}
 
-	var outBuf, errBuf bytes.Buffer
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
-
-	runErr := cmd.Run()
-	stdout = outBuf.String()
-	stderr = errBuf.String()
-
-	if runErr != nil {
-		if ctx.Err() != nil {
-			return stdout, stderr, -1, fmt.Errorf("command cancelled: %w", ctx.Err())
+func (c *Client) runRemoteCommandWithInput(ctx context.Context, wrapped string, input []byte, useMultiplex bool) (stdout string, stderr string, exitCode int, err error) {
+	if socket := c.AgentDaemonSocket(); socket != "" && useMultiplex {
+		stdout, stderr, exitCode, err = c.runOnAgentDaemon(ctx, socket, wrapped, input)
+		if !errors.Is(err, agentd.ErrUnreachable) {
+			c.noteResult(exitCode, err)
+			return stdout, stderr, exitCode, err
 		}
-		if exitErr, ok := runErr.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			return stdout, stderr, -1, fmt.Errorf("failed to execute command: %w", runErr)
+	}
+	if n := c.nativeConnection(); n != nil {
+		var outBuf bytes.Buffer
+		stderr, exitCode, err = c.runOnNative(ctx, n, wrapped, bytes.NewReader(input), &outBuf)
+		if !errors.Is(err, errNativeUnreachable) {
+			c.noteResult(exitCode, err)
+			return sanitizeOutput(outBuf.String()), stderr, exitCode, err
 		}
 	}
-
-	return stdout, stderr, exitCode, nil
+	cmd := c.remoteCommand(ctx, wrapped, useMultiplex)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout, stderr, exitCode, err = runCaptured(ctx, cmd)
+	c.noteResult(exitCode, err)
+	return stdout, stderr, exitCode, err
 }
 
-func (c *Client) runRemoteCommandWithInput(ctx context.Context, wrapped string, input []byte, useMultiplex bool) (stdout string, stderr string, exitCode int, err error) {
-	var cmd *exec.Cmd
+// remoteCommand builds the ssh invocation for wrapped, either over the
+// multiplexed connection or via gh codespace ssh. sshFlags (e.g. -tt) are
+// passed to ssh ahead of the remote command.
+func (c *Client) remoteCommand(ctx context.Context, wrapped string, useMultiplex bool, sshFlags ...string) *exec.Cmd {
 	if useMultiplex {
-		sshConfigPath, sshHost, _ := c.sshState()
-		cmd = c.command(ctx, "ssh", "-F", sshConfigPath, sshHost, wrapped)
-	} else {
-		cmd = c.command(ctx, "gh", "codespace", "ssh",
-			"-c", c.codespaceName,
-			"--", wrapped,
-		)
+		_, sshHost, _ := c.sshState()
+		args := append(c.multiplexArgs(), sshFlags...)
+		return c.command(ctx, "ssh", append(args, sshHost, wrapped)...)
 	}
+	args := append(append(GHSSHArgs(c.codespaceName), "--"), sshFlags...)
+	return c.command(ctx, "gh", append(args, wrapped)...)
+}
 
-	cmd.Stdin = bytes.NewReader(input)
+// InteractiveCommand builds an ssh invocation whose stdio the caller attaches,
+// over the multiplexed connection when it is up. An empty command opens a
+// login shell; tty forces a pseudo-terminal for interactive commands.
+func (c *Client) InteractiveCommand(ctx context.Context, command string, tty bool) *exec.Cmd {
+	var flags []string
+	if tty {
+		flags = append(flags, "-t")
+	}
+	sshConfigPath, sshHost, _ := c.sshState()
+	name := "gh"
+	args := append(append(GHSSHArgs(c.codespaceName), "--"), flags...)
+	if sshConfigPath != "" {
+		name = "ssh"
+		args = append(append([]string{"-F", sshConfigPath}, flags...), sshHost)
+	}
+	if command != "" {
+		args = append(args, command)
+	}
+	return c.command(ctx, name, args...)
+}
 
-	var outBuf, errBuf bytes.Buffer
-	cmd.Stdout = &outBuf
+// runCaptured runs cmd and returns its output and exit code. A non-zero exit is
+// not an error; only failures to run the command (or cancellation) are.
+// Output is sanitized to valid UTF-8.
+func runCaptured(ctx context.Context, cmd *exec.Cmd) (stdout string, stderr string, exitCode int, err error) {
+	var outBuf bytes.Buffer
+	stderr, exitCode, err = runStreamed(ctx, cmd, &outBuf)
+	return sanitizeOutput(outBuf.String()), stderr, exitCode, err
+}
+
+// runStreamed is like runCaptured but writes stdout to w as it arrives.
+func runStreamed(ctx context.Context, cmd *exec.Cmd, w io.Writer) (stderr string, exitCode int, err error) {
+	var errBuf bytes.Buffer
+	cmd.Stdout = w
 	cmd.Stderr = &errBuf
 
 	runErr := cmd.Run()
-	stdout = outBuf.String()
-	stderr = errBuf.String()
+	stderr = sanitizeOutput(errBuf.String())
 
 	if runErr != nil {
 		if ctx.Err() != nil {
-			return stdout, stderr, -1, fmt.Errorf("command cancelled: %w", ctx.Err())
+			return stderr, -1, fmt.Errorf("command cancelled: %w", ctx.Err())
 		}
 		if exitErr, ok := runErr.(*exec.ExitError); ok {
 			exitCode = exitErr.ExitCode()
 		} else {
-			return stdout, stderr, -1, fmt.Errorf("failed to execute command: %w", runErr)
+			return stderr, -1, fmt.Errorf("failed to execute command: %w", runErr)
 		}
 	}
 
-	return stdout, stderr, exitCode, nil
+	return stderr, exitCode, nil
 }
 
 func (c *Client) disableMultiplexing() {
@@ -350,7 +458,10 @@ func (c *Client) probeMultiplexing(ctx context.Context) bool {
 		return false
 	}
 
-	probe := c.command(ctx, "ssh", "-F", sshConfigPath, "-o", "ConnectTimeout=5", sshHost, "echo ok")
+	// ssh keeps the first value given for an option, so the short probe
+	// timeout goes ahead of the connect options.
+	args := append([]string{"-F", sshConfigPath, "-o", "ConnectTimeout=5"}, c.connectOptions()...)
+	probe := c.command(ctx, "ssh", append(args, sshHost, "echo ok")...)
 	out, err := probe.Output()
 	return err == nil && strings.TrimSpace(string(out)) == "ok"
 }
@@ -363,6 +474,28 @@ func (c *Client) Exec(ctx context.Context, command string) (stdout string, stder
 	return c.runRemoteCommand(ctx, wrapped, sshConfigPath != "")
 }
 
+// ExecWithInput is like Exec but feeds input to the command's stdin, for
+// payloads too large to pass as an argument.
+func (c *Client) ExecWithInput(ctx context.Context, command string, input []byte) (stdout string, stderr string, exitCode int, err error) {
+	wrapped := envSecretsLoader + " && " + command
+	sshConfigPath, _, _ := c.sshState()
+	return c.runRemoteCommandWithInput(ctx, wrapped, input, sshConfigPath != "")
+}
+
+// ExecStream is like Exec but writes stdout to w as it arrives, for long
+// transfers that report progress.
+func (c *Client) ExecStream(ctx context.Context, command string, w io.Writer) (stderr string, exitCode int, err error) {
+	wrapped := envSecretsLoader + " && " + command
+	if n := c.nativeConnection(); n != nil {
+		stderr, exitCode, err = c.runOnNative(ctx, n, wrapped, nil, w)
+		if !errors.Is(err, errNativeUnreachable) {
+			return stderr, exitCode, err
+		}
+	}
+	sshConfigPath, _, _ := c.sshState()
+	return runStreamed(ctx, c.remoteCommand(ctx, wrapped, sshConfigPath != ""), w)
+}
+
 // UploadTerminfo compiles a local terminfo entry into the remote codespace.
 func (c *Client) UploadTerminfo(ctx context.Context, term string) error {
 	var outBuf, errBuf bytes.Buffer
@@ -492,7 +625,7 @@ func (c *Client) EditFile(ctx context.Context, path, oldStr, newStr string) erro
 
 	newContent := strings.Replace(contentStr, oldStr, newStr, 1)
 
-	// Write back via SSH
+	// Write back via SSH; truncating in place keeps the file's owner and mode
 	b64 := base64.StdEncoding.EncodeToString([]byte(newContent))
 	cmd := fmt.Sprintf("echo %s | base64 -d > %s", shellQuote(b64), shellQuote(path))
 	_, stderr, exitCode, err = c.Exec(ctx, cmd)
@@ -508,12 +641,7 @@ func (c *Client) EditFile(ctx context.Context, path, oldStr, newStr string) erro
 // CreateFile creates a new file with the given content, creating parent directories as needed.
 func (c *Client) CreateFile(ctx context.Context, path, content string) error {
 	b64 := base64.StdEncoding.EncodeToString([]byte(content))
-	dir := pathDir(path)
-
-	cmd := fmt.Sprintf("mkdir -p %s && echo %s | base64 -d > %s",
-		shellQuote(dir), shellQuote(b64), shellQuote(path))
-
-	_, stderr, exitCode, err := c.Exec(ctx, cmd)
+	_, stderr, exitCode, err := c.Exec(ctx, createFileCommand(path, b64, c.fileOwner()))
 	if err != nil {
 		return fmt.Errorf("create file: %w", err)
 	}
@@ -528,11 +656,34 @@ func (c *Client) RunBash(ctx context.Context, command, cwd string) (stdout strin
 	return c.Exec(ctx, wrapCommandInWorkdir(command, c.resolveWorkdir(cwd)))
 }
 
+// RunBashTTY executes a bash command with a pseudo-terminal allocated (ssh -tt),
+// for tools that change behavior when stdout is not a TTY. The PTY merges
+// stderr into stdout, and carriage returns from terminal line endings are removed.
+func (c *Client) RunBashTTY(ctx context.Context, command, cwd string) (stdout string, stderr string, exitCode int, err error) {
+	wrapped := envSecretsLoader + " && " + wrapCommandInWorkdir(command, c.resolveWorkdir(cwd))
+	sshConfigPath, _, _ := c.sshState()
+	cmd := c.remoteCommand(ctx, wrapped, sshConfigPath != "", "-tt")
+	stdout, stderr, exitCode, err = runCaptured(ctx, cmd)
+	return strings.ReplaceAll(stdout, "\r\n", "\n"), strings.ReplaceAll(stderr, "\r\n", "\n"), exitCode, err
+}
+
+// SearchOptions widens or bounds a Grep or Glob walk.
+type SearchOptions struct {
+	Hidden   bool // include dotfiles and dot-directories; .git stays excluded
+	MaxDepth int  // descend at most this many directories below path; 0 means no limit
+}
+
 // Grep searches for a pattern in files on the codespace.
-func (c *Client) Grep(ctx context.Context, pattern, path, globPattern, cwd string) (string, error) {
+func (c *Client) Grep(ctx context.Context, pattern, path, globPattern, cwd string, opts SearchOptions) (string, error) {
 	var args []string
 	args = append(args, "rg", "--color=never", "-n")
 
+	if opts.Hidden {
+		args = append(args, "--hidden", "--glob", shellQuote("!.git"))
+	}
+	if opts.MaxDepth > 0 {
+		args = append(args, "--max-depth", strconv.Itoa(opts.MaxDepth))
+	}
 	if globPattern != "" {
 		args = append(args, "--glob", shellQuote(globPattern))
 	}
@@ -547,9 +698,14 @@ func (c *Client) Grep(ctx context.Context, pattern, path, globPattern, cwd strin
 
 	cmd := strings.Join(args, " ")
 
-	// Fallback to grep if rg is not available
-	cmd = fmt.Sprintf("(%s) 2>/dev/null || grep -rn %s %s",
-		cmd, shellQuote(pattern), shellQuote(searchPath))
+	// Fallback to grep if rg is not available. grep -r has no depth limit, so
+	// a bounded search walks with find instead.
+	fallback := fmt.Sprintf("grep -rn %s %s", shellQuote(pattern), shellQuote(searchPath))
+	if opts.MaxDepth > 0 {
+		fallback = fmt.Sprintf("find %s -maxdepth %d -type f -not -path '*/.git/*' -exec grep -Hn %s {} +",
+			shellQuote(searchPath), opts.MaxDepth, shellQuote(pattern))
+	}
+	cmd = fmt.Sprintf("(%s) 2>/dev/null || %s", cmd, fallback)
 
 	stdout, _, exitCode, err := c.execReadOnly(ctx, wrapCommandInWorkdir(cmd, c.resolveWorkdir(cwd)))
 	if err != nil {
@@ -562,19 +718,30 @@ func (c *Client) Grep(ctx context.Context, pattern, path, globPattern, cwd strin
 	return stdout, nil
 }
 
-// Glob finds files matching a glob pattern on the codespace.
+// Glob finds files matching any of the glob patterns on the codespace, in one
+// walk so a file matching several is listed once.
 // Supports standard glob patterns like **/*.go, *.ts, src/**/*.test.js.
-func (c *Client) Glob(ctx context.Context, pattern, path, cwd string) (string, error) {
+func (c *Client) Glob(ctx context.Context, patterns []string, path, cwd string, opts SearchOptions) (string, error) {
 	searchPath := path
 	if searchPath == "" {
 		searchPath = "."
 	}
 
+	// fd skips dotfiles unless asked; find always includes them.
+	var fdFlags, findFlags string
+	if opts.Hidden {
+		fdFlags += " --hidden"
+	}
+	if opts.MaxDepth > 0 {
+		fdFlags += fmt.Sprintf(" --max-depth %d", opts.MaxDepth)
+		findFlags = fmt.Sprintf(" -maxdepth %d", opts.MaxDepth)
+	}
+
 	// Use fd if available (supports glob natively), fallback to find with -name
 	// Extract the filename pattern from globs like **/*.go → *.go for find -name
 	cmd := fmt.Sprintf(
-		"(fd --type f --glob %s --exclude .git %s 2>/dev/null || find %s -name %s -not -path '*/.git/*' 2>/dev/null) | head -200",
-		shellQuote(pattern), shellQuote(searchPath), shellQuote(searchPath), shellQuote(globToFindName(pattern)))
+		"(fd --type f%s --glob %s --exclude .git %s 2>/dev/null || find %s%s %s -not -path '*/.git/*' 2>/dev/null) | head -200",
+		fdFlags, shellQuote(fdGlob(patterns)), shellQuote(searchPath), shellQuote(searchPath), findFlags, findNameTest(patterns))
 
 	stdout, _, exitCode, err := c.execReadOnly(ctx, wrapCommandInWorkdir(cmd, c.resolveWorkdir(cwd)))
 	if err != nil {
@@ -586,6 +753,33 @@ func (c *Client) Glob(ctx context.Context, pattern, path, cwd string) (string, e
 	return stdout, nil
 }
 
+// fdGlob joins patterns into one glob with {a,b} alternation, which fd
+// matches in a single walk. The patterns must not contain braces or commas.
+func fdGlob(patterns []string) string {
+	if len(patterns) == 1 {
+		return patterns[0]
+	}
+	return "{" + strings.Join(patterns, ",") + "}"
+}
+
+// findNameTest is the find expression matching any of patterns by filename:
+// -name for one, a parenthesized -o chain for several.
+func findNameTest(patterns []string) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		name := globToFindName(p)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, "-name "+shellQuote(name))
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return `\( ` + strings.Join(names, " -o ") + ` \)`
+}
+
 // globToFindName extracts a filename pattern from a glob for use with find -name.
 // e.g., "**/*.go" → "*.go", "src/**/*.test.js" → "*.test.js", "*.ts" → "*.ts"
 func globToFindName(pattern string) string {
@@ -595,13 +789,15 @@ func globToFindName(pattern string) string {
 }
 
 // envSecretsLoader restores the GitHub auth env that Codespaces login shells
-// normally provide. Non-login SSH commands skip /etc/profile.d/ scripts.
-var envSecretsLoader = codespaceenv.BuildShellBootstrap()
+// normally provide. Non-login SSH commands skip /etc/profile.d/ scripts, so it
+// also sets up a UTF-8 locale.
+var envSecretsLoader = codespaceenv.BuildShellBootstrap() + "\n" + utf8LocaleSetup
 
 const tmuxPrefix = "copilot-"
 
-// misePATH is prepended to PATH for commands that need mise-installed tools.
-const misePATH = `PATH="$HOME/.local/bin:$HOME/.local/share/mise/shims:$PATH"`
+// MisePATH is prepended to PATH for commands that need mise-installed tools
+// when the remote user's home has not been detected; see MisePathSetup.
+const MisePATH = `PATH="$HOME/.local/bin:$HOME/.local/share/mise/shims:$PATH"`
 
 // tmuxSessionName returns the prefixed tmux session name.
 func tmuxSessionName(sessionID string) string {
@@ -610,19 +806,27 @@ func tmuxSessionName(sessionID string) string {
 
 // execTmux runs a tmux command with mise shims on the PATH.
 func (c *Client) execTmux(ctx context.Context, tmuxCmd string) (string, string, int, error) {
-	return c.Exec(ctx, misePATH+" && "+tmuxCmd)
+	return c.Exec(ctx, c.MisePathSetup()+" && "+tmuxCmd)
 }
 
 // StartSession creates a named tmux session running the given command on the codespace.
 // Uses remain-on-exit so the pane stays readable even after the command exits.
+// When tmux cannot be provisioned the command runs under nohup instead.
 func (c *Client) StartSession(ctx context.Context, sessionID, command, cwd string) error {
 	name := tmuxSessionName(sessionID)
 
-	if err := c.ensureTmux(ctx); err != nil {
+	backend, err := c.sessionBackend(ctx)
+	if err != nil {
 		return err
 	}
 
 	wrappedCommand := envSecretsLoader + " && " + wrapCommandInWorkdir(command, c.resolveWorkdir(cwd))
+	if c.recordingSession(name) {
+		wrappedCommand = recordCommand(recordingFileName(name), wrappedCommand)
+	}
+	if backend == backendNohup {
+		return c.startNohupSession(ctx, name, wrappedCommand)
+	}
 
 	// Create session with remain-on-exit so we can read output after command finishes
 	cmd := fmt.Sprintf(
@@ -648,25 +852,25 @@ func (c *Client) ensureTmux(ctx context.Context) error {
 	fmt.Fprintln(os.Stderr, "codespace-mcp: tmux not found, installing via mise...")
 
 	// Install mise if not available, then install tmux
-	installScript := misePATH + ` && (command -v mise >/dev/null 2>&1 || curl -fsSL https://mise.jdx.dev/install.sh | sh) && mise use -g tmux`
+	installScript := c.MisePathSetup() + ` && (command -v mise >/dev/null 2>&1 || curl -fsSL https://mise.jdx.dev/install.sh | sh) && mise use -g tmux`
 	_, stderr, exitCode, err := c.Exec(ctx, installScript)
 	if err != nil {
 		return fmt.Errorf("installing tmux: %w", err)
 	}
 	if exitCode != 0 {
 		logDiagnostic("tmux install via mise failed", stderr)
-		return fmt.Errorf("failed to install tmux via mise (exit %d); verify that the codespace can run `mise use -g tmux`", exitCode)
+		return &tmuxUnavailableError{fmt.Sprintf("failed to install tmux via mise (exit %d); verify that the codespace can run `mise use -g tmux`", exitCode)}
 	}
 
 	// Verify tmux is now available and distinguish PATH problems from missing shims.
-	verifyCmd := `command -v tmux >/dev/null 2>&1 || { if [ -x "$HOME/.local/share/mise/shims/tmux" ]; then echo 'tmux shim exists but is not on PATH' >&2; else echo 'tmux shim not found after install' >&2; fi; exit 1; }`
+	verifyCmd := `command -v tmux >/dev/null 2>&1 || { if [ -x ` + c.homeExpr() + `/.local/share/mise/shims/tmux ]; then echo 'tmux shim exists but is not on PATH' >&2; else echo 'tmux shim not found after install' >&2; fi; exit 1; }`
 	_, verifyStderr, ec, err := c.execTmux(ctx, verifyCmd)
 	if err != nil {
 		return fmt.Errorf("verifying tmux installation: %w", err)
 	}
 	if ec != 0 {
 		logDiagnostic("tmux verification after mise install failed", verifyStderr)
-		return fmt.Errorf("tmux installation completed but tmux is still unavailable; %s", summarizeTmuxVerificationFailure(verifyStderr))
+		return &tmuxUnavailableError{"tmux installation completed but tmux is still unavailable; " + summarizeTmuxVerificationFailure(verifyStderr, c.RemoteUser().Home)}
 	}
 	return nil
 }
@@ -713,22 +917,34 @@ func parseInput(input string) []string {
 	return segments
 }
 
+// WriteOptions controls how WriteSession delivers input.
+type WriteOptions struct {
+	Paste      bool          // deliver literal text as a bracketed paste instead of typed keys
+	ChunkSize  int           // split literal text into pieces of at most this many bytes; 0 sends it whole
+	ChunkDelay time.Duration // pause between deliveries; 0 sends everything in one round trip
+	PasteFile  string        // codespace file pasted into the session before input
+}
+
 // WriteSession sends keystrokes to a tmux session on the codespace.
 // Special key sequences like {enter}, {up}, {down}, {left}, {right}, {backspace}
 // are translated to their tmux equivalents.
-func (c *Client) WriteSession(ctx context.Context, sessionID, input string) error {
-	name := tmuxSessionName(sessionID)
-	segments := parseInput(input)
+func (c *Client) WriteSession(ctx context.Context, sessionID, input string, opts WriteOptions) error {
+	if c.usesNohupSessions() {
+		return c.writeNohupSession(ctx, sessionID, input, opts)
+	}
+	cmds := writeSessionCommands(tmuxSessionName(sessionID), input, opts, c.GetWorkdir())
+	if opts.ChunkDelay <= 0 {
+		cmds = []string{strings.Join(cmds, " && ")}
+	}
 
-	for _, seg := range segments {
-		var cmd string
-		if strings.HasPrefix(seg, "\x00") {
-			tmuxKey := seg[1:]
-			cmd = fmt.Sprintf("tmux send-keys -t %s %s", shellQuote(name), tmuxKey)
-		} else {
-			cmd = fmt.Sprintf("tmux send-keys -t %s %s", shellQuote(name), shellQuote(seg))
+	for i, cmd := range cmds {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("write session: %w", ctx.Err())
+			case <-time.After(opts.ChunkDelay):
+			}
 		}
-
 		_, stderr, exitCode, err := c.execTmux(ctx, cmd)
 		if err != nil {
 			return fmt.Errorf("write session: %w", err)
@@ -740,6 +956,64 @@ func (c *Client) WriteSession(ctx context.Context, sessionID, input string) erro
 	return nil
 }
 
+// writeSessionCommands returns the tmux commands that deliver input to the
+// named session, one per delivery. Pasted text goes through a tmux buffer
+// with paste-buffer -p, so applications that enable bracketed paste see one
+// paste rather than a burst of typing.
+func writeSessionCommands(name, input string, opts WriteOptions, workdir string) []string {
+	buffer := shellQuote(name + "-paste")
+	paste := func(source string) string {
+		return fmt.Sprintf("tmux load-buffer -b %s %s && tmux paste-buffer -d -p -b %s -t %s", buffer, source, buffer, shellQuote(name))
+	}
+
+	var cmds []string
+	if opts.PasteFile != "" {
+		file := opts.PasteFile
+		if !path.IsAbs(file) && workdir != "" {
+			file = path.Join(workdir, file)
+		}
+		cmds = append(cmds, paste(shellQuote(file)))
+	}
+	for _, seg := range parseInput(input) {
+		if strings.HasPrefix(seg, "\x00") {
+			cmds = append(cmds, fmt.Sprintf("tmux send-keys -t %s %s", shellQuote(name), seg[1:]))
+			continue
+		}
+		for _, piece := range chunkText(seg, opts.ChunkSize) {
+			if opts.Paste {
+				cmds = append(cmds, fmt.Sprintf("printf '%%s' %s | %s", shellQuote(piece), paste("-")))
+			} else {
+				cmds = append(cmds, fmt.Sprintf("tmux send-keys -t %s %s", shellQuote(name), shellQuote(piece)))
+			}
+		}
+	}
+	return cmds
+}
+
+// chunkText splits s into pieces of at most size bytes without splitting a
+// UTF-8 sequence. A size of 0 or less returns s whole.
+func chunkText(s string, size int) []string {
+	if size <= 0 || len(s) <= size {
+		return []string{s}
+	}
+	var pieces []string
+	for len(s) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			_, cut = utf8.DecodeRuneInString(s)
+		}
+		pieces = append(pieces, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		pieces = append(pieces, s)
+	}
+	return pieces
+}
+
 // paneDeadRe matches the tmux "Pane is dead" decoration that appears when remain-on-exit is on.
 var paneDeadRe = regexp.MustCompile(`(?m)^Pane is dead.*$`)
 
@@ -755,6 +1029,9 @@ func cleanPaneOutput(s string) string {
 // ReadSession captures the current tmux pane content (last 100 lines) from the codespace.
 // Works even after the command has exited (thanks to remain-on-exit).
 func (c *Client) ReadSession(ctx context.Context, sessionID string) (string, error) {
+	if c.usesNohupSessions() {
+		return c.readNohupSession(ctx, sessionID)
+	}
 	name := tmuxSessionName(sessionID)
 
 	// Check if session exists
@@ -807,6 +1084,9 @@ func parsePaneStatus(status string) (paneDead bool, exitCode int, err error) {
 
 // StopSession kills a tmux session on the codespace.
 func (c *Client) StopSession(ctx context.Context, sessionID string) error {
+	if c.usesNohupSessions() {
+		return c.stopNohupSession(ctx, sessionID)
+	}
 	name := tmuxSessionName(sessionID)
 	cmd := fmt.Sprintf("tmux kill-session -t %s", shellQuote(name))
 
@@ -820,19 +1100,113 @@ func (c *Client) StopSession(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-// ListSessions lists active copilot-prefixed tmux sessions on the codespace.
-func (c *Client) ListSessions(ctx context.Context) (string, error) {
-	cmd := "tmux list-sessions -F '#{session_name} #{session_created} #{session_activity}' 2>/dev/null | grep '^" + tmuxPrefix + "'"
+// listSessionsCommand prints one "name|created|activity|dead|status|last line"
+// record per copilot-prefixed tmux session. Sessions are inspected in
+// parallel so a long list still costs a single round trip.
+var listSessionsCommand = "tmux list-sessions -F '#{session_name}' 2>/dev/null | grep '^" + tmuxPrefix + "' | " +
+	`while IFS= read -r s; do { ` +
+	`st=$(tmux display-message -p -t "$s" '#{session_created}|#{session_activity}|#{pane_dead}|#{pane_dead_status}' 2>/dev/null); ` +
+	`last=$(tmux capture-pane -p -t "$s" -S -50 2>/dev/null | grep -v '^Pane is dead' | grep -v '^[[:space:]]*$' | tail -n 1); ` +
+	`printf '%s|%s|%s\n' "$s" "$st" "$last"; } & done; wait`
+
+// SessionStatus describes one background session as reported by ListSessions.
+type SessionStatus struct {
+	ID       string
+	Created  time.Time
+	Activity time.Time
+	Exited   bool
+	ExitCode int // -1 when tmux has not recorded the exit status
+	LastLine string
+}
+
+// parseSessionList parses listSessionsCommand output, sorted by session ID.
+// Malformed records are skipped.
+func parseSessionList(out string) []SessionStatus {
+	var sessions []SessionStatus
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "|", 6)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], tmuxPrefix) {
+			continue
+		}
+		s := SessionStatus{ID: strings.TrimPrefix(fields[0], tmuxPrefix)}
+		if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			s.Created = time.Unix(secs, 0)
+		}
+		if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			s.Activity = time.Unix(secs, 0)
+		}
+		s.Exited = fields[3] == "1"
+		if s.Exited {
+			code, err := strconv.Atoi(fields[4])
+			if err != nil {
+				code = -1
+			}
+			s.ExitCode = code
+		}
+		if len(fields) == 6 {
+			s.LastLine = strings.TrimSpace(fields[5])
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
 
-	stdout, _, exitCode, err := c.execTmux(ctx, cmd)
+// formatSessionList renders sessions as a summary line followed by one line
+// per session, so several background jobs can be triaged from one call.
+func formatSessionList(sessions []SessionStatus) string {
+	if len(sessions) == 0 {
+		return ""
+	}
+	running, failed := 0, 0
+	for _, s := range sessions {
+		switch {
+		case !s.Exited:
+			running++
+		case s.ExitCode > 0:
+			failed++
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d session(s): %d running, %d exited", len(sessions), running, len(sessions)-running)
+	if failed > 0 {
+		fmt.Fprintf(&sb, " (%d failed)", failed)
+	}
+	sb.WriteString("\n")
+	for _, s := range sessions {
+		status := "running"
+		switch {
+		case s.Exited && s.ExitCode < 0:
+			status = "exited"
+		case s.Exited:
+			status = fmt.Sprintf("exited (code %d)", s.ExitCode)
+		}
+		fmt.Fprintf(&sb, "\n%s: %s", s.ID, status)
+		if !s.Created.IsZero() {
+			fmt.Fprintf(&sb, ", started %s", s.Created.UTC().Format(time.RFC3339))
+		}
+		if !s.Activity.IsZero() {
+			fmt.Fprintf(&sb, ", last activity %s", s.Activity.UTC().Format(time.RFC3339))
+		}
+		if s.LastLine != "" {
+			fmt.Fprintf(&sb, "\n  last line: %s", s.LastLine)
+		}
+	}
+	return sb.String()
+}
+
+// ListSessions lists active copilot-prefixed tmux and nohup sessions on the
+// codespace with a summary of whether each is still running, its exit code,
+// and its last line of output.
+func (c *Client) ListSessions(ctx context.Context) (string, error) {
+	stdout, stderr, exitCode, err := c.execTmux(ctx, listSessionsCommand+"; "+nohupListCommand(nohupSessionsDir))
 	if err != nil {
 		return "", fmt.Errorf("list sessions: %w", err)
 	}
-	// Exit code 1 means no matching sessions (grep found nothing)
-	if exitCode > 1 {
-		return "", fmt.Errorf("list sessions failed with exit code %d", exitCode)
+	if exitCode != 0 {
+		return "", formatCommandFailure("list sessions", exitCode, stderr)
 	}
-	return stdout, nil
+	return formatSessionList(parseSessionList(stdout)), nil
 }
 
 func shellQuote(s string) string {
@@ -847,13 +1221,16 @@ func formatCommandFailure(action string, exitCode int, stderr string) error {
 	return fmt.Errorf("%s failed (exit %d): %s", action, exitCode, trimmed)
 }
 
-func summarizeTmuxVerificationFailure(stderr string) string {
+func summarizeTmuxVerificationFailure(stderr, home string) string {
+	if home == "" {
+		home = "$HOME"
+	}
 	lower := strings.ToLower(stderr)
 	switch {
 	case strings.Contains(lower, "shim exists but is not on path"):
-		return "the tmux shim exists, but `$HOME/.local/share/mise/shims` is not on PATH"
+		return "the tmux shim exists, but `" + home + "/.local/share/mise/shims` is not on PATH"
 	case strings.Contains(lower, "shim not found after install"):
-		return "mise did not create a tmux shim in `$HOME/.local/share/mise/shims`"
+		return "mise did not create a tmux shim in `" + home + "/.local/share/mise/shims`"
 	default:
 		return "verify that `mise use -g tmux` succeeds and that tmux is on PATH in the codespace"
 	}
@@ -882,13 +1259,8 @@ func (c *Client) ForwardSocket(ctx context.Context, localPath, remotePath string
 
 	// StreamLocalBindUnlink=yes tells SSH to remove the socket atomically before
 	// binding, avoiding a TOCTOU race between our Remove and the bind.
-	cmd := c.command(ctx, "ssh",
-		"-F", sshConfigPath,
-		"-o", "StreamLocalBindUnlink=yes",
-		"-O", "forward",
-		"-L", fwdSpec,
-		sshHost,
-	)
+	args := append(c.multiplexArgs(), "-o", "StreamLocalBindUnlink=yes", "-O", "forward", "-L", fwdSpec, sshHost)
+	cmd := c.command(ctx, "ssh", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("ssh forward: %w: %s", err, strings.TrimSpace(string(output)))
@@ -903,12 +1275,7 @@ func (c *Client) CancelForward(ctx context.Context, localPath, remotePath string
 		return
 	}
 	fwdSpec := localPath + ":" + remotePath
-	cancel := c.command(ctx, "ssh",
-		"-F", sshConfigPath,
-		"-O", "cancel",
-		"-L", fwdSpec,
-		sshHost,
-	)
+	cancel := c.command(ctx, "ssh", append(c.multiplexArgs(), "-O", "cancel", "-L", fwdSpec, sshHost)...)
 	cancel.Run() // ignore error — forwarding may not exist
 }
 
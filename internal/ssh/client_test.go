@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseInput(t *testing.T) {
@@ -41,6 +43,53 @@ func TestParseInput(t *testing.T) {
 	}
 }
 
+func TestWriteSessionCommands(t *testing.T) {
+	name := "cs-s1"
+	got := writeSessionCommands(name, "abcdef{enter}", WriteOptions{ChunkSize: 4}, "/workspaces/app")
+	want := []string{
+		"tmux send-keys -t 'cs-s1' 'abcd'",
+		"tmux send-keys -t 'cs-s1' 'ef'",
+		"tmux send-keys -t 'cs-s1' Enter",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keystrokes = %q, want %q", got, want)
+	}
+
+	got = writeSessionCommands(name, "a b{enter}", WriteOptions{Paste: true, PasteFile: "notes/big.txt"}, "/workspaces/app")
+	want = []string{
+		"tmux load-buffer -b 'cs-s1-paste' '/workspaces/app/notes/big.txt' && tmux paste-buffer -d -p -b 'cs-s1-paste' -t 'cs-s1'",
+		"printf '%s' 'a b' | tmux load-buffer -b 'cs-s1-paste' - && tmux paste-buffer -d -p -b 'cs-s1-paste' -t 'cs-s1'",
+		"tmux send-keys -t 'cs-s1' Enter",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paste = %q, want %q", got, want)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	if got := chunkText("héllo", 2); !reflect.DeepEqual(got, []string{"h", "é", "ll", "o"}) {
+		t.Errorf("chunkText() = %q", got)
+	}
+	if got := chunkText("hello", 0); !reflect.DeepEqual(got, []string{"hello"}) {
+		t.Errorf("chunkText() without size = %q", got)
+	}
+}
+
+func TestWriteSessionBatchesWithoutDelay(t *testing.T) {
+	client := NewClient("demo")
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{}})
+
+	if err := client.WriteSession(context.Background(), "s1", "ls{enter}", WriteOptions{}); err != nil {
+		t.Fatalf("WriteSession() error = %v", err)
+	}
+	name := shellQuote(tmuxSessionName("s1"))
+	want := envSecretsLoader + " && " + MisePATH + " && tmux send-keys -t " + name + " 'ls' && tmux send-keys -t " + name + " Enter"
+	if len(calls) != 1 || calls[0].args[len(calls[0].args)-1] != want {
+		t.Fatalf("calls = %#v, want one call running %q", calls, want)
+	}
+}
+
 func TestGlobToFindName(t *testing.T) {
 	tests := []struct {
 		pattern string
@@ -361,6 +410,116 @@ func TestRunBashUsesExplicitCwd(t *testing.T) {
 	}
 }
 
+func TestExecWithInputFeedsStdin(t *testing.T) {
+	client := NewClient("demo")
+	stdinPath := filepath.Join(t.TempDir(), "stdin")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdinPath: stdinPath},
+	})
+
+	if _, _, exitCode, err := client.ExecWithInput(context.Background(), "cat > /tmp/x", []byte("payload")); err != nil || exitCode != 0 {
+		t.Fatalf("ExecWithInput() = exit %d, err %v", exitCode, err)
+	}
+	if got, _ := os.ReadFile(stdinPath); string(got) != "payload" {
+		t.Errorf("stdin = %q, want payload", got)
+	}
+	wantArgs := []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && cat > /tmp/x"}
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0].args, wantArgs) {
+		t.Errorf("calls = %#v", calls)
+	}
+}
+
+func TestRunBashTTYAllocatesTerminal(t *testing.T) {
+	tests := []struct {
+		name      string
+		multiplex bool
+		wantCall  fakeExecCall
+	}{
+		{
+			name:      "multiplexed",
+			multiplex: true,
+			wantCall:  fakeExecCall{name: "ssh", args: []string{"-F", "/tmp/ssh-config", "-tt", "cs.demo", envSecretsLoader + " && cd '/workspaces/repo' && docker ps"}},
+		},
+		{
+			name:     "gh fallback",
+			wantCall: fakeExecCall{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", "-tt", envSecretsLoader + " && cd '/workspaces/repo' && docker ps"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("demo")
+			if tt.multiplex {
+				client.sshConfigPath = "/tmp/ssh-config"
+				client.sshHost = "cs.demo"
+			}
+			var calls []fakeExecCall
+			client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+				{stdout: "CONTAINER ID\r\nabc\r\n"},
+			})
+
+			stdout, _, _, err := client.RunBashTTY(context.Background(), "docker ps", "/workspaces/repo")
+			if err != nil {
+				t.Fatalf("RunBashTTY() error = %v", err)
+			}
+			if stdout != "CONTAINER ID\nabc\n" {
+				t.Fatalf("stdout = %q, want CRLF normalized", stdout)
+			}
+			if !reflect.DeepEqual(calls, []fakeExecCall{tt.wantCall}) {
+				t.Fatalf("calls = %#v, want %#v", calls, tt.wantCall)
+			}
+		})
+	}
+}
+
+func TestExecStreamWritesStdout(t *testing.T) {
+	client := NewClient("demo")
+	client.sshConfigPath = "/tmp/ssh-config"
+	client.sshHost = "cs.demo"
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "chunk-1\nchunk-2\n", stderr: "warn", exitCode: 3},
+	})
+
+	var out bytes.Buffer
+	stderr, exitCode, err := client.ExecStream(context.Background(), "fetch", &out)
+	if err != nil {
+		t.Fatalf("ExecStream() error = %v", err)
+	}
+	if out.String() != "chunk-1\nchunk-2\n" || stderr != "warn" || exitCode != 3 {
+		t.Fatalf("ExecStream() = %q, %q, %d", out.String(), stderr, exitCode)
+	}
+	want := []fakeExecCall{{name: "ssh", args: []string{"-F", "/tmp/ssh-config", "cs.demo", envSecretsLoader + " && fetch"}}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %#v, want %#v", calls, want)
+	}
+}
+
+func TestNewClientWithConfigUsesGivenSSHConfig(t *testing.T) {
+	client := NewClientWithConfig("local", "/tmp/sshd/ssh_config", "local-sshd")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "ok\n"},
+	})
+
+	if _, _, _, err := client.RunBash(context.Background(), "pwd", "/srv"); err != nil {
+		t.Fatalf("RunBash() error = %v", err)
+	}
+
+	wantCalls := []fakeExecCall{
+		{name: "ssh", args: []string{"-F", "/tmp/sshd/ssh_config", "local-sshd", envSecretsLoader + " && cd '/srv' && pwd"}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("calls = %#v, want %#v", calls, wantCalls)
+	}
+	if client.ControlSocketPath() != "" {
+		t.Fatalf("ControlSocketPath() = %q, want empty", client.ControlSocketPath())
+	}
+}
+
 func TestUploadTerminfoPipesLocalOutputToRemote(t *testing.T) {
 	client := NewClient("demo")
 	client.sshConfigPath = "/tmp/ssh-config"
@@ -403,7 +562,7 @@ func TestGrepUsesExplicitCwd(t *testing.T) {
 		{stdout: "cmd/main.go:3:match\n"},
 	})
 
-	got, err := client.Grep(context.Background(), "match", "cmd", "*.go", "/workspaces/repo")
+	got, err := client.Grep(context.Background(), "match", "cmd", "*.go", "/workspaces/repo", SearchOptions{})
 	if err != nil {
 		t.Fatalf("Grep() error = %v", err)
 	}
@@ -427,7 +586,7 @@ func TestGlobUsesExplicitCwd(t *testing.T) {
 		{stdout: "pkg/foo.go\n"},
 	})
 
-	got, err := client.Glob(context.Background(), "**/*.go", "pkg", "/workspaces/repo")
+	got, err := client.Glob(context.Background(), []string{"**/*.go"}, "pkg", "/workspaces/repo", SearchOptions{})
 	if err != nil {
 		t.Fatalf("Glob() error = %v", err)
 	}
@@ -443,6 +602,60 @@ func TestGlobUsesExplicitCwd(t *testing.T) {
 	}
 }
 
+func TestGrepSearchOptions(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{}})
+
+	if _, err := client.Grep(context.Background(), "on:", ".github", "", "/workspaces/repo", SearchOptions{Hidden: true, MaxDepth: 2}); err != nil {
+		t.Fatalf("Grep() error = %v", err)
+	}
+
+	wantCalls := []fakeExecCall{
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && cd '/workspaces/repo' && (rg --color=never -n --hidden --glob '!.git' --max-depth 2 'on:' '.github') 2>/dev/null || find '.github' -maxdepth 2 -type f -not -path '*/.git/*' -exec grep -Hn 'on:' {} +"}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("calls = %#v, want %#v", calls, wantCalls)
+	}
+}
+
+func TestGlobSearchOptions(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{}})
+
+	if _, err := client.Glob(context.Background(), []string{"*.yml"}, ".", "/workspaces/repo", SearchOptions{Hidden: true, MaxDepth: 3}); err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+
+	wantCalls := []fakeExecCall{
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && cd '/workspaces/repo' && (fd --type f --hidden --max-depth 3 --glob '*.yml' --exclude .git '.' 2>/dev/null || find '.' -maxdepth 3 -name '*.yml' -not -path '*/.git/*' 2>/dev/null) | head -200"}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("calls = %#v, want %#v", calls, wantCalls)
+	}
+}
+
+func TestGlobPatternSetRunsOneWalk(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{}})
+
+	if _, err := client.Glob(context.Background(), []string{"**/*.go", "**/*.proto", "cmd/**/*.go"}, "", "/workspaces/repo", SearchOptions{}); err != nil {
+		t.Fatalf("Glob() error = %v", err)
+	}
+
+	wantCalls := []fakeExecCall{
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + ` && cd '/workspaces/repo' && (fd --type f --glob '{**/*.go,**/*.proto,cmd/**/*.go}' --exclude .git '.' 2>/dev/null || find '.' \( -name '*.go' -o -name '*.proto' \) -not -path '*/.git/*' 2>/dev/null) | head -200`}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("calls = %#v, want %#v", calls, wantCalls)
+	}
+}
+
 func TestStartSessionBootstrapsAuthInsideTmuxCommand(t *testing.T) {
 	client := NewClient("demo")
 
@@ -463,8 +676,8 @@ func TestStartSessionBootstrapsAuthInsideTmuxCommand(t *testing.T) {
 		shellQuote(name), shellQuote(sessionCommand), shellQuote(name))
 
 	wantCalls := []fakeExecCall{
-		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && " + misePATH + " && command -v tmux"}},
-		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && " + misePATH + " && " + tmuxCommand}},
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && " + MisePATH + " && command -v tmux"}},
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--", envSecretsLoader + " && " + MisePATH + " && " + tmuxCommand}},
 	}
 	if !reflect.DeepEqual(calls, wantCalls) {
 		t.Fatalf("calls = %#v, want %#v", calls, wantCalls)
@@ -576,3 +789,95 @@ func TestEnsureTmuxVerificationExplainsShimPathProblem(t *testing.T) {
 		t.Fatalf("len(calls) = %d, want 3", len(calls))
 	}
 }
+
+func TestInteractiveCommand(t *testing.T) {
+	multiplexed := NewClientWithConfig("cs", "/tmp/.ssh-config-cs", "cs.host")
+	if got := multiplexed.InteractiveCommand(context.Background(), "", true).Args; !reflect.DeepEqual(got, []string{"ssh", "-F", "/tmp/.ssh-config-cs", "-t", "cs.host"}) {
+		t.Errorf("multiplexed shell args = %q", got)
+	}
+	if got := multiplexed.InteractiveCommand(context.Background(), "make test", false).Args; !reflect.DeepEqual(got, []string{"ssh", "-F", "/tmp/.ssh-config-cs", "cs.host", "make test"}) {
+		t.Errorf("multiplexed command args = %q", got)
+	}
+
+	fallback := NewClient("cs")
+	if got := fallback.InteractiveCommand(context.Background(), "make test", true).Args; !reflect.DeepEqual(got, []string{"gh", "codespace", "ssh", "-c", "cs", "--", "-t", "make test"}) {
+		t.Errorf("fallback command args = %q", got)
+	}
+}
+
+func TestListSessionsCommand(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("tmux", args...).CombinedOutput(); err != nil {
+			t.Fatalf("tmux %v: %v\n%s", args, err, out)
+		}
+	}
+	defer exec.Command("tmux", "kill-server").Run()
+	run("new-session", "-d", "-s", "other", "sleep 30")
+	run("new-session", "-d", "-s", tmuxPrefix+"busy", "echo working; sleep 30")
+	run("new-session", "-d", "-s", tmuxPrefix+"done", "echo 'a|b'; sleep 0.2; exit 3")
+	run("set-option", "-t", tmuxPrefix+"done", "remain-on-exit", "on")
+
+	var sessions []SessionStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		out, err := exec.Command("bash", "-c", listSessionsCommand).Output()
+		if err != nil {
+			t.Fatalf("listSessionsCommand: %v", err)
+		}
+		sessions = parseSessionList(string(out))
+		if len(sessions) == 2 && sessions[1].Exited && sessions[0].LastLine != "" {
+			break
+		}
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %+v, want 2", sessions)
+	}
+	if busy := sessions[0]; busy.ID != "busy" || busy.Exited || busy.LastLine != "working" || busy.Created.IsZero() {
+		t.Errorf("busy = %+v", busy)
+	}
+	if done := sessions[1]; done.ID != "done" || !done.Exited || (done.ExitCode != 3 && done.ExitCode != -1) || done.LastLine != "a|b" {
+		t.Errorf("done = %+v", done)
+	}
+}
+
+func TestFormatSessionList(t *testing.T) {
+	if got := formatSessionList(nil); got != "" {
+		t.Errorf("formatSessionList(nil) = %q, want empty", got)
+	}
+	got := formatSessionList(parseSessionList(
+		"copilot-test|1700000000|1700000060|1|1|FAIL ./...\n" +
+			"copilot-build|1700000000|1700000030|0||\n" +
+			"copilot-lint|1700000000|1700000010|1|0|ok\n" +
+			"copilot-gone|1700000000|1700000000|1||\n" +
+			"garbage\n"))
+	want := "4 session(s): 1 running, 3 exited (1 failed)\n" +
+		"\nbuild: running, started 2023-11-14T22:13:20Z, last activity 2023-11-14T22:13:50Z" +
+		"\ngone: exited, started 2023-11-14T22:13:20Z, last activity 2023-11-14T22:13:20Z" +
+		"\nlint: exited (code 0), started 2023-11-14T22:13:20Z, last activity 2023-11-14T22:13:30Z\n  last line: ok" +
+		"\ntest: exited (code 1), started 2023-11-14T22:13:20Z, last activity 2023-11-14T22:14:20Z\n  last line: FAIL ./..."
+	if got != want {
+		t.Errorf("formatSessionList() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestListSessionsSingleCall(t *testing.T) {
+	client := NewClient("demo")
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "copilot-s1|1700000000|1700000000|0||compiling\n"},
+	})
+	got, err := client.ListSessions(context.Background())
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if !strings.HasPrefix(got, "1 session(s): 1 running, 0 exited\n") || !strings.Contains(got, "last line: compiling") {
+		t.Errorf("ListSessions() = %q", got)
+	}
+	if len(calls) != 1 {
+		t.Errorf("calls = %d, want 1 batched call", len(calls))
+	}
+}
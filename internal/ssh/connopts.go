@@ -0,0 +1,73 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// connectTimeoutEnv overrides how long each ssh invocation waits to connect,
+// in seconds or as a Go duration; 0 waits as long as ssh does by default.
+const connectTimeoutEnv = "COPILOT_CODESPACE_SSH_CONNECT_TIMEOUT"
+
+// defaultConnectTimeout leaves room for gh's ProxyCommand, which takes a few
+// seconds to open the tunnel, while failing a dead one well before a tool
+// call would otherwise time out.
+const defaultConnectTimeout = 20 * time.Second
+
+// connectTimeout returns the ConnectTimeout from connectTimeoutEnv, or the
+// default when it is unset or invalid.
+func connectTimeout() time.Duration {
+	v := strings.TrimSpace(os.Getenv(connectTimeoutEnv))
+	if v == "" {
+		return defaultConnectTimeout
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return d
+	}
+	fmt.Fprintf(os.Stderr, "codespace-mcp: ignoring %s=%q: want seconds or a duration such as 30s\n", connectTimeoutEnv, v)
+	return defaultConnectTimeout
+}
+
+// connectOptionsFor returns the -o flags for every non-interactive ssh call
+// over the multiplexed connection. BatchMode stops ssh from prompting, which
+// would hang a tool call with no terminal to answer it; ConnectTimeout bounds
+// the reconnect when the master is gone; StrictHostKeyChecking restates the
+// config's policy so no ssh_config elsewhere can turn on a prompt.
+func connectOptionsFor(pinHostKeys bool) []string {
+	opts := []string{"-o", "BatchMode=yes"}
+	if d := connectTimeout(); d > 0 {
+		// ssh takes whole seconds; round up so 500ms does not become 0 (off).
+		opts = append(opts, "-o", fmt.Sprintf("ConnectTimeout=%d", int((d+time.Second-1)/time.Second)))
+	}
+	if pinHostKeys {
+		opts = append(opts, "-o", "StrictHostKeyChecking=accept-new")
+	} else {
+		opts = append(opts, "-o", "StrictHostKeyChecking=no")
+	}
+	return opts
+}
+
+func (c *Client) setConnectOptions(opts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connectOpts = opts
+}
+
+func (c *Client) connectOptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connectOpts
+}
+
+// multiplexArgs returns the leading ssh arguments for a call over the
+// multiplexed connection: the config file and the connect options.
+func (c *Client) multiplexArgs() []string {
+	sshConfigPath, _, _ := c.sshState()
+	return append([]string{"-F", sshConfigPath}, c.connectOptions()...)
+}
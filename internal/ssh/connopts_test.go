@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnectTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		env  string
+		want time.Duration
+	}{
+		{"", defaultConnectTimeout},
+		{"45", 45 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"0", 0},
+		{"soon", defaultConnectTimeout},
+		{"-5", defaultConnectTimeout},
+	} {
+		t.Setenv(connectTimeoutEnv, tt.env)
+		if got := connectTimeout(); got != tt.want {
+			t.Errorf("connectTimeout() with %q = %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestConnectOptionsFor(t *testing.T) {
+	t.Setenv(connectTimeoutEnv, "")
+	want := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=20", "-o", "StrictHostKeyChecking=no"}
+	if got := connectOptionsFor(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("connectOptionsFor(false) = %q, want %q", got, want)
+	}
+
+	t.Setenv(connectTimeoutEnv, "1500ms")
+	want = []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=2", "-o", "StrictHostKeyChecking=accept-new"}
+	if got := connectOptionsFor(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("connectOptionsFor(true) = %q, want %q", got, want)
+	}
+
+	t.Setenv(connectTimeoutEnv, "0")
+	want = []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=no"}
+	if got := connectOptionsFor(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("connectOptionsFor with timeout off = %q, want %q", got, want)
+	}
+}
+
+func TestMultiplexedCallsPassConnectOptions(t *testing.T) {
+	client := NewClient("demo")
+	client.setSSHState("/tmp/ssh-config", "cs.demo", "/tmp/socket")
+	opts := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=20", "-o", "StrictHostKeyChecking=no"}
+	client.setConnectOptions(opts)
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "ok\n"},
+		{}, // -O cancel
+		{}, // -O forward
+	})
+
+	if _, _, _, err := client.Exec(context.Background(), "true"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	local := filepath.Join(t.TempDir(), "agent.sock")
+	if err := client.ForwardSocket(context.Background(), local, "/tmp/remote.sock"); err != nil {
+		t.Fatalf("ForwardSocket() error = %v", err)
+	}
+
+	spec := local + ":/tmp/remote.sock"
+	want := []fakeExecCall{
+		{name: "ssh", args: append(append([]string{"-F", "/tmp/ssh-config"}, opts...), "cs.demo", envSecretsLoader+" && true")},
+		{name: "ssh", args: append(append([]string{"-F", "/tmp/ssh-config"}, opts...), "-O", "cancel", "-L", spec, "cs.demo")},
+		{name: "ssh", args: append(append([]string{"-F", "/tmp/ssh-config"}, opts...), "-o", "StreamLocalBindUnlink=yes", "-O", "forward", "-L", spec, "cs.demo")},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %#v, want %#v", calls, want)
+	}
+}
@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// utf8LocaleSetup switches remote commands to C.UTF-8 unless the session
+// already uses a UTF-8 locale, so tools print UTF-8 rather than ASCII
+// replacements or legacy encodings.
+const utf8LocaleSetup = `case "${LC_ALL:-${LC_CTYPE:-${LANG:-}}}" in
+*UTF-8*|*utf-8*|*UTF8*|*utf8*) ;;
+*) export LC_ALL=C.UTF-8 ;;
+esac`
+
+// sanitizeOutput returns s as valid UTF-8 so tool results can be JSON-encoded
+// losslessly. Bytes that are not part of a valid UTF-8 sequence are decoded as
+// Latin-1, the usual culprit in mixed-encoding output (e.g. "caf\xe9" becomes
+// "café"); valid sequences pass through unchanged.
+func sanitizeOutput(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + len(s)/8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			// Latin-1 byte values equal their code points.
+			b.WriteRune(rune(s[i]))
+			i++
+			continue
+		}
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", "hello\n", "hello\n"},
+		{"valid utf-8", "café ✓ 日本\n", "café ✓ 日本\n"},
+		{"latin-1", "caf\xe9 na\xefve\n", "café naïve\n"},
+		{"mixed utf-8 and latin-1 lines", "ok: café\nlegacy: caf\xe9\n", "ok: café\nlegacy: café\n"},
+		{"truncated multibyte sequence", "abc\xe6\x97", "abcæ\u0097"},
+		{"replacement character kept", "bad � char", "bad � char"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeOutput(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeOutput(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("sanitizeOutput(%q) is not valid UTF-8", tt.in)
+			}
+			// The point of sanitizing: JSON round-trips without loss.
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			var back string
+			if err := json.Unmarshal(data, &back); err != nil || back != got {
+				t.Errorf("JSON round trip = %q, %v; want %q", back, err, got)
+			}
+		})
+	}
+}
+
+func TestExecSanitizesMixedEncodingOutput(t *testing.T) {
+	client := NewClient("demo")
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "r\xe9sum\xe9.txt\nrésumé.md\n", stderr: "warning: fichier \xab x \xbb\n", exitCode: 1},
+	})
+
+	stdout, stderr, exitCode, err := client.Exec(context.Background(), "ls")
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if stdout != "résumé.txt\nrésumé.md\n" {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if stderr != "warning: fichier « x »\n" {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if exitCode != 1 {
+		t.Errorf("exitCode = %d, want 1", exitCode)
+	}
+}
+
+func TestUTF8LocaleSetup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	tests := []struct {
+		env  []string
+		want string
+	}{
+		{[]string{"LANG=C"}, "C.UTF-8"},
+		{nil, "C.UTF-8"},
+		{[]string{"LANG=en_US.UTF-8"}, ""},
+		{[]string{"LC_ALL=de_DE.utf8"}, "de_DE.utf8"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("sh", "-c", utf8LocaleSetup+"\nprintf %s \"${LC_ALL:-}\"")
+		cmd.Env = append([]string{"PATH=/usr/bin:/bin"}, tt.env...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("env %v: %v", tt.env, err)
+		}
+		if got := strings.TrimSpace(string(out)); got != tt.want {
+			t.Errorf("env %v: LC_ALL = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+	if !strings.Contains(envSecretsLoader, utf8LocaleSetup) {
+		t.Error("envSecretsLoader should include the UTF-8 locale setup")
+	}
+}
@@ -0,0 +1,63 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileOwnerOff in a codespace's file owner override leaves created files
+// owned by the SSH user.
+const fileOwnerOff = "off"
+
+// fileOwnerOverridePath is the per-codespace file that overrides who owns
+// files created through CreateFile: a user name, or "off".
+func fileOwnerOverridePath(codespaceName string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(WorkdirsDir(homeDir), ".file-owner-"+codespaceName)
+}
+
+// fileOwner returns the user that created files and directories should be
+// chowned to, or "" to leave them alone. Only root can give files away, so
+// this is empty unless SSH logs in as root. By default it is the workspace
+// owner, so that devcontainers running as root do not leave root-owned files
+// behind for the non-root builds that run later; the codespace's override
+// file replaces that choice.
+func (c *Client) fileOwner() string {
+	u := c.RemoteUser()
+	if u.Name != "root" {
+		return ""
+	}
+	owner := u.WorkspaceOwner
+	if path := fileOwnerOverridePath(c.codespaceName); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			owner = strings.TrimSpace(string(data))
+		}
+	}
+	if owner == fileOwnerOff || owner == "root" {
+		return ""
+	}
+	return owner
+}
+
+// createFileCommand writes the base64-encoded content to path, creating its
+// directory. With an owner, a new file and any directories mkdir -p creates
+// for it are chowned to owner under a umask that keeps them readable; a file
+// that already existed keeps its ownership, as overwriting does not change it.
+func createFileCommand(path, b64, owner string) string {
+	dir := pathDir(path)
+	if owner == "" {
+		return fmt.Sprintf("mkdir -p %s && echo %s | base64 -d > %s",
+			shellQuote(dir), shellQuote(b64), shellQuote(path))
+	}
+	// t ends up as the topmost directory that does not exist yet, or the
+	// file itself when only the file is new.
+	return fmt.Sprintf(`umask 022; t=; n=%[1]s; while [ ! -e "$n" ]; do t=$n; n=$(dirname "$n"); done; `+
+		`[ -e %[3]s ] || t=${t:-%[3]s}; `+
+		`mkdir -p %[1]s && echo %[2]s | base64 -d > %[3]s && { [ -z "$t" ] || chown -R %[4]s: "$t"; }`,
+		shellQuote(dir), shellQuote(b64), shellQuote(path), shellQuote(owner))
+}
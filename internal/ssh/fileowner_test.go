@@ -0,0 +1,86 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileOwner(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	client := NewClient("demo")
+
+	for _, tc := range []struct {
+		user RemoteUser
+		want string
+	}{
+		{RemoteUser{Name: "root", WorkspaceOwner: "vscode"}, "vscode"},
+		{RemoteUser{Name: "root", WorkspaceOwner: "root"}, ""},
+		{RemoteUser{Name: "root"}, ""},
+		{RemoteUser{Name: "node", WorkspaceOwner: "vscode"}, ""},
+	} {
+		client.SetRemoteUser(tc.user)
+		if got := client.fileOwner(); got != tc.want {
+			t.Errorf("fileOwner() for %+v = %q, want %q", tc.user, got, tc.want)
+		}
+	}
+
+	override := fileOwnerOverridePath("demo")
+	os.MkdirAll(filepath.Dir(override), 0o755)
+	client.SetRemoteUser(RemoteUser{Name: "root", WorkspaceOwner: "vscode"})
+	for content, want := range map[string]string{"off\n": "", "node\n": "node"} {
+		os.WriteFile(override, []byte(content), 0o644)
+		if got := client.fileOwner(); got != want {
+			t.Errorf("fileOwner() with override %q = %q, want %q", content, got, want)
+		}
+	}
+	if got := NewClient("other").fileOwner(); got != "" {
+		t.Errorf("override leaked to another codespace: %q", got)
+	}
+}
+
+func TestCreateFileCommandWithoutOwner(t *testing.T) {
+	got := createFileCommand("/workspaces/app/a.txt", "aGk=", "")
+	if want := "mkdir -p '/workspaces/app' && echo 'aGk=' | base64 -d > '/workspaces/app/a.txt'"; got != want {
+		t.Errorf("createFileCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestCreateFileCommandChownsNewPaths(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	// Chowning to the current user succeeds without root; the trace shows
+	// which paths were chowned.
+	out, err := exec.Command("id", "-un").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := strings.TrimSpace(string(out))
+	dir := t.TempDir()
+	run := func(path string) string {
+		t.Helper()
+		script := "chown() { echo \"chown $*\"; command chown \"$@\"; }; " + createFileCommand(path, "aGk=", owner)
+		out, err := exec.Command("bash", "-c", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("create %s: %v: %s", path, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if got, want := run(filepath.Join(dir, "new", "deep", "a.txt")), "chown -R "+owner+": "+filepath.Join(dir, "new"); got != want {
+		t.Errorf("new directories: %q, want %q", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "new", "deep", "a.txt")); string(data) != "hi" {
+		t.Errorf("content = %q", data)
+	}
+	if got, want := run(filepath.Join(dir, "new", "b.txt")), "chown -R "+owner+": "+filepath.Join(dir, "new", "b.txt"); got != want {
+		t.Errorf("new file: %q, want %q", got, want)
+	}
+	if got := run(filepath.Join(dir, "new", "b.txt")); got != "" {
+		t.Errorf("existing file should keep its owner, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package ssh
+
+import (
+	"os"
+	"strings"
+)
+
+// GHSSHFlagsEnv holds extra flags for every gh codespace ssh invocation,
+// separated by spaces, e.g. "--server-port 2222" or "--profile work" where an
+// organization needs a connection other than gh's default.
+const GHSSHFlagsEnv = "COPILOT_CODESPACE_GH_SSH_FLAGS"
+
+// GHSSHArgs returns the gh arguments that connect to codespaceName, including
+// the flags from GHSSHFlagsEnv. Callers append "--" and the remote command.
+func GHSSHArgs(codespaceName string) []string {
+	return append([]string{"codespace", "ssh", "-c", codespaceName}, ghSSHFlags()...)
+}
+
+func ghSSHFlags() []string {
+	return strings.Fields(os.Getenv(GHSSHFlagsEnv))
+}
+
+// GHSSHCommand is GHSSHArgs as shell text starting with gh, for commands run
+// by a shell such as hooks and terminal tabs.
+func GHSSHCommand(codespaceName string) string {
+	args := GHSSHArgs(codespaceName)
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuoteIfNeeded(arg)
+	}
+	return "gh " + strings.Join(quoted, " ")
+}
+
+// shellQuoteIfNeeded leaves plain words such as flags and codespace names
+// readable and quotes anything else.
+func shellQuoteIfNeeded(s string) string {
+	plain := s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.,:=/@+", r))
+	}) < 0
+	if plain {
+		return s
+	}
+	return shellQuote(s)
+}
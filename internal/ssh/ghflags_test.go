@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGHSSHArgs(t *testing.T) {
+	t.Setenv(GHSSHFlagsEnv, "")
+	if got, want := GHSSHArgs("demo"), []string{"codespace", "ssh", "-c", "demo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GHSSHArgs() = %q, want %q", got, want)
+	}
+	if got, want := GHSSHCommand("demo"), "gh codespace ssh -c demo"; got != want {
+		t.Errorf("GHSSHCommand() = %q, want %q", got, want)
+	}
+
+	t.Setenv(GHSSHFlagsEnv, " --server-port 2222  --profile work ")
+	want := []string{"codespace", "ssh", "-c", "demo", "--server-port", "2222", "--profile", "work"}
+	if got := GHSSHArgs("demo"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GHSSHArgs() with flags = %q, want %q", got, want)
+	}
+	if got, want := GHSSHCommand("demo"), "gh codespace ssh -c demo --server-port 2222 --profile work"; got != want {
+		t.Errorf("GHSSHCommand() with flags = %q, want %q", got, want)
+	}
+
+	t.Setenv(GHSSHFlagsEnv, "--profile=it's")
+	if got, want := GHSSHCommand("demo"), `gh codespace ssh -c demo '--profile=it'"'"'s'`; got != want {
+		t.Errorf("GHSSHCommand() quoting = %q, want %q", got, want)
+	}
+}
+
+func TestGHSSHFlagsReachEveryGHCall(t *testing.T) {
+	t.Setenv(GHSSHFlagsEnv, "--server-port 2222")
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "ok\n"},
+		{}, // interactive shell
+	})
+	if _, _, _, err := client.Exec(context.Background(), "true"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := client.InteractiveCommand(context.Background(), "", true).Run(); err != nil {
+		t.Fatalf("InteractiveCommand() error = %v", err)
+	}
+
+	want := []fakeExecCall{
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--server-port", "2222", "--", envSecretsLoader + " && true"}},
+		{name: "gh", args: []string{"codespace", "ssh", "-c", "demo", "--server-port", "2222", "--", "-t"}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %#v, want %#v", calls, want)
+	}
+}
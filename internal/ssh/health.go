@@ -0,0 +1,44 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// noteResult records when a remote command last got through. Exit code 255
+// is ssh's own failure, so it does not count.
+func (c *Client) noteResult(exitCode int, err error) {
+	if err != nil || exitCode == 255 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSuccess = time.Now()
+}
+
+// LastSuccess returns when a remote command last completed over SSH, or the
+// zero time if none has.
+func (c *Client) LastSuccess() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSuccess
+}
+
+// CheckMaster asks the shared SSH master whether it is running, without
+// opening a connection to the codespace.
+func (c *Client) CheckMaster(ctx context.Context) error {
+	sshConfigPath, sshHost, _ := c.sshState()
+	if sshConfigPath == "" {
+		return fmt.Errorf("no shared SSH master; commands go through gh codespace ssh")
+	}
+	out, err := c.command(ctx, "ssh", "-F", sshConfigPath, "-O", "check", sshHost).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLastSuccess(t *testing.T) {
+	client := NewClient("demo")
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stderr: "kex_exchange_identification: Connection closed", exitCode: 255},
+		{stdout: "", exitCode: 1},
+	})
+
+	client.Exec(context.Background(), "true")
+	if !client.LastSuccess().IsZero() {
+		t.Errorf("an ssh failure counted as success: %v", client.LastSuccess())
+	}
+	// A command that ran and failed still proves the connection works.
+	client.Exec(context.Background(), "false")
+	if client.LastSuccess().IsZero() {
+		t.Error("LastSuccess() not updated after a completed command")
+	}
+}
+
+func TestCheckMaster(t *testing.T) {
+	if err := NewClient("demo").CheckMaster(context.Background()); err == nil || !strings.Contains(err.Error(), "no shared SSH master") {
+		t.Errorf("CheckMaster() without multiplexing = %v", err)
+	}
+
+	client := NewClientWithConfig("demo", "/tmp/ssh-config", "cs.demo")
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stderr: "Master running (pid=42)\n"},
+		{stderr: "Control socket connect(/tmp/sock): No such file or directory\n", exitCode: 255},
+	})
+	if err := client.CheckMaster(context.Background()); err != nil {
+		t.Errorf("CheckMaster() = %v", err)
+	}
+	if got := strings.Join(calls[0].args, " "); calls[0].name != "ssh" || got != "-F /tmp/ssh-config -O check cs.demo" {
+		t.Errorf("call = %s %s", calls[0].name, got)
+	}
+	if err := client.CheckMaster(context.Background()); err == nil || !strings.Contains(err.Error(), "No such file") {
+		t.Errorf("CheckMaster() with a dead master = %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrHostKeyChanged is returned by SetupMultiplexing when the codespace
+// presents a host key that differs from the one pinned for it.
+var ErrHostKeyChanged = errors.New("codespace host key does not match the pinned key")
+
+// hostKeyOptions are the gh-provided settings replaced when pinning. gh
+// disables host key checking for codespaces; LogLevel QUIET would also hide
+// the mismatch warning.
+var hostKeyOptions = []string{"userknownhostsfile", "globalknownhostsfile", "stricthostkeychecking", "hostkeyalias", "loglevel"}
+
+// SetHostKeyPinning makes SetupMultiplexing store the codespace's host key on
+// first connect and reject the connection if it later changes. Pinning stays
+// on for a codespace once its known_hosts file exists.
+func (c *Client) SetHostKeyPinning(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pinHostKeys = enabled
+}
+
+// hostKeyPinningEnabled reports whether connections to the codespace must
+// verify its pinned host key.
+func (c *Client) hostKeyPinningEnabled(knownHostsPath string) bool {
+	c.mu.Lock()
+	enabled := c.pinHostKeys
+	c.mu.Unlock()
+	if enabled {
+		return true
+	}
+	_, err := os.Stat(knownHostsPath)
+	return err == nil
+}
+
+// pinHostKey rewrites a gh-generated Host block so the host key is kept in
+// knownHostsPath: accepted on first use, verified on every later connect.
+// The key is recorded under the codespace name rather than the Host alias,
+// which includes the branch and can change between sessions.
+func pinHostKey(b *sshConfigBlock, knownHostsPath, codespaceName string) {
+	b.removeOptions(hostKeyOptions)
+	b.addOptions(
+		fmt.Sprintf("UserKnownHostsFile \"%s\"", knownHostsPath),
+		"StrictHostKeyChecking accept-new",
+		"HostKeyAlias "+codespaceName,
+		"LogLevel ERROR",
+	)
+}
+
+// isHostKeyMismatch reports whether ssh stderr shows a host key verification
+// failure against the pinned key.
+func isHostKeyMismatch(stderr string) bool {
+	return strings.Contains(stderr, "REMOTE HOST IDENTIFICATION HAS CHANGED") ||
+		strings.Contains(stderr, "Host key verification failed")
+}
+
+// hostKeyChangedError describes a mismatch loudly, including how to re-pin
+// after an expected change such as a codespace rebuild.
+func hostKeyChangedError(codespaceName, knownHostsPath string) error {
+	return fmt.Errorf("%w for %s\n"+
+		"  @@@ WARNING: the codespace host key has changed. This may mean someone is\n"+
+		"  @@@ intercepting the connection, or that the codespace was rebuilt.\n"+
+		"  If the change is expected, remove %s to pin the new key",
+		ErrHostKeyChanged, codespaceName, knownHostsPath)
+}
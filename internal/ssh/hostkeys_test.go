@@ -0,0 +1,92 @@
+package ssh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPinHostKey(t *testing.T) {
+	ghConfig := "Host cs.develop-abc.main\n" +
+		"\tUser codespace\n" +
+		"\tProxyCommand gh cs ssh -c develop-abc --stdio\n" +
+		"\tUserKnownHostsFile=/dev/null\n" +
+		"\tStrictHostKeyChecking no\n" +
+		"\tLogLevel quiet\n" +
+		"\tControlPath /tmp/sock\n"
+
+	config := parseSSHConfig(ghConfig)
+	block, _, err := config.codespaceHost("develop-abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinHostKey(block, "/home/me/.known_hosts-develop-abc", "develop-abc")
+	got := config.String()
+
+	for _, removed := range []string{"/dev/null", "StrictHostKeyChecking no", "LogLevel quiet"} {
+		if strings.Contains(got, removed) {
+			t.Errorf("config still contains %q:\n%s", removed, got)
+		}
+	}
+	for _, kept := range []string{"Host cs.develop-abc.main\n", "\tProxyCommand gh cs ssh", "\tControlPath /tmp/sock\n"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("config lost %q:\n%s", kept, got)
+		}
+	}
+	for _, added := range []string{
+		"\tUserKnownHostsFile \"/home/me/.known_hosts-develop-abc\"\n",
+		"\tStrictHostKeyChecking accept-new\n",
+		"\tHostKeyAlias develop-abc\n",
+		"\tLogLevel ERROR\n",
+	} {
+		if !strings.Contains(got, added) {
+			t.Errorf("config missing %q:\n%s", added, got)
+		}
+	}
+}
+
+func TestIsHostKeyMismatch(t *testing.T) {
+	tests := map[string]bool{
+		"@@@@@@@\n@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @\n": true,
+		"Host key verification failed.":                                          true,
+		"Connection closed by remote host":                                       false,
+	}
+	for stderr, want := range tests {
+		if got := isHostKeyMismatch(stderr); got != want {
+			t.Errorf("isHostKeyMismatch(%q) = %v, want %v", stderr, got, want)
+		}
+	}
+}
+
+func TestHostKeyChangedErrorWrapsSentinel(t *testing.T) {
+	err := hostKeyChangedError("develop-abc", "/home/me/.known_hosts-develop-abc")
+	if !errors.Is(err, ErrHostKeyChanged) {
+		t.Fatalf("expected ErrHostKeyChanged, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "remove /home/me/.known_hosts-develop-abc") {
+		t.Errorf("error should explain how to re-pin: %v", err)
+	}
+}
+
+func TestHostKeyPinningEnabled(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), ".known_hosts-cs")
+	c := NewClient("cs")
+	if c.hostKeyPinningEnabled(knownHosts) {
+		t.Fatal("pinning should be off by default")
+	}
+
+	c.SetHostKeyPinning(true)
+	if !c.hostKeyPinningEnabled(knownHosts) {
+		t.Fatal("pinning should be on when requested")
+	}
+
+	c.SetHostKeyPinning(false)
+	if err := os.WriteFile(knownHosts, []byte("cs ssh-ed25519 AAAA\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if !c.hostKeyPinningEnabled(knownHosts) {
+		t.Fatal("pinning should stay on once a key has been stored")
+	}
+}
@@ -0,0 +1,472 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// nativeSSHEnv turns on the native SSH backend: SetupMultiplexing also opens a
+// connection with golang.org/x/crypto/ssh over gh's ProxyCommand, and Exec,
+// ExecWithInput, and ExecStream open a channel on it instead of starting an
+// ssh process per call.
+const nativeSSHEnv = "COPILOT_CODESPACE_NATIVE_SSH"
+
+// errNativeUnreachable means a command was never started on the native
+// connection, so the caller can run it over ssh instead.
+var errNativeUnreachable = errors.New("native SSH connection unavailable")
+
+// nativeSSHEnabled reports whether nativeSSHEnv is set to a true value.
+func nativeSSHEnabled() bool {
+	v := strings.TrimSpace(os.Getenv(nativeSSHEnv))
+	if v == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "codespace-mcp: ignoring %s=%q: want true or false\n", nativeSSHEnv, v)
+		return false
+	}
+	return enabled
+}
+
+// nativeConn is an SSH connection made in-process, the ProxyCommand it runs
+// over, and the ssh-agent connection it authenticated with, if any.
+type nativeConn struct {
+	client *gossh.Client
+	proxy  *exec.Cmd
+	agent  net.Conn
+}
+
+func (n *nativeConn) close() {
+	n.client.Close()
+	if n.agent != nil {
+		n.agent.Close()
+	}
+	if n.proxy != nil && n.proxy.Process != nil {
+		_ = n.proxy.Process.Kill()
+		_ = n.proxy.Wait()
+	}
+}
+
+// nativeHostConfig holds the options of an SSH config Host block the native
+// backend understands. Everything else in the block is ignored.
+type nativeHostConfig struct {
+	hostName      string
+	port          string
+	user          string
+	proxyCommand  string
+	identityFiles []string
+}
+
+// parseNativeHostConfig reads the connection options for codespaceName's
+// Host block from an SSH config. As with ssh, the first value of an option
+// wins, except IdentityFile, which may be given more than once.
+func parseNativeHostConfig(config, codespaceName string) (*nativeHostConfig, error) {
+	block, alias, err := parseSSHConfig(config).codespaceHost(codespaceName)
+	if err != nil {
+		return nil, err
+	}
+	hc := &nativeHostConfig{}
+	set := func(dst *string, value string) {
+		if *dst == "" {
+			*dst = value
+		}
+	}
+	for _, line := range block.lines[1:] {
+		key, value := configLineValue(line)
+		switch key {
+		case "hostname":
+			set(&hc.hostName, unquoteConfigValue(value))
+		case "port":
+			set(&hc.port, unquoteConfigValue(value))
+		case "user":
+			set(&hc.user, unquoteConfigValue(value))
+		case "proxycommand":
+			set(&hc.proxyCommand, value)
+		case "identityfile":
+			hc.identityFiles = append(hc.identityFiles, expandHome(unquoteConfigValue(value)))
+		}
+	}
+	if hc.hostName == "" {
+		hc.hostName = alias
+	}
+	if hc.port == "" {
+		hc.port = "22"
+	}
+	if hc.user == "" {
+		hc.user = os.Getenv("USER")
+	}
+	if strings.EqualFold(hc.proxyCommand, "none") {
+		hc.proxyCommand = ""
+	}
+	return hc, nil
+}
+
+// configLineValue returns a config line's lowercase keyword and the rest of
+// the line as written, unlike configLineFields, so a ProxyCommand keeps its
+// spaces and '=' signs.
+func configLineValue(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), ""
+	}
+	value := strings.TrimLeft(line[end:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	return strings.ToLower(line[:end]), strings.TrimSpace(value)
+}
+
+func unquoteConfigValue(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// proxyCommandLine expands the %h, %p, %r, and %% tokens of a ProxyCommand.
+func proxyCommandLine(command string, hc *nativeHostConfig) string {
+	return strings.NewReplacer("%%", "%", "%h", hc.hostName, "%p", hc.port, "%r", hc.user).Replace(command)
+}
+
+// ConnectNative opens the native SSH connection used by Exec, ExecWithInput,
+// and ExecStream, replacing any earlier one. It reads the Host block
+// SetupMultiplexing wrote, or the one given to NewClientWithConfig, and runs
+// its ProxyCommand the way ssh would, or dials HostName and Port when there is
+// none. The host key is checked against the pinned known_hosts file when
+// pinning is on, and ignored otherwise, as in gh's own config.
+// SetupMultiplexing calls it when COPILOT_CODESPACE_NATIVE_SSH is set.
+func (c *Client) ConnectNative(ctx context.Context) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home dir: %w", err)
+	}
+	configDir := WorkdirsDir(homeDir)
+	sshConfigPath, _, _ := c.sshState()
+	if sshConfigPath == "" {
+		// The fallback after a failed master still leaves gh's config behind.
+		sshConfigPath = filepath.Join(configDir, ".ssh-config-"+c.codespaceName)
+	}
+	data, err := os.ReadFile(sshConfigPath)
+	if err != nil {
+		return fmt.Errorf("reading SSH config: %w", err)
+	}
+	hc, err := parseNativeHostConfig(string(data), c.codespaceName)
+	if err != nil {
+		return err
+	}
+
+	auth, agentConn := nativeAuthMethods(hc.identityFiles)
+	config := &gossh.ClientConfig{
+		User:            hc.user,
+		Auth:            auth,
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         connectTimeout(),
+	}
+	knownHostsPath := filepath.Join(configDir, ".known_hosts-"+c.codespaceName)
+	if c.hostKeyPinningEnabled(knownHostsPath) {
+		config.HostKeyCallback = pinnedHostKeyCallback(c.codespaceName, knownHostsPath)
+	}
+
+	closeAgent := func() {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+	}
+	conn, proxy, err := c.dialNative(hc)
+	if err != nil {
+		closeAgent()
+		return err
+	}
+	client, err := handshakeNative(ctx, conn, net.JoinHostPort(hc.hostName, hc.port), config)
+	if err != nil {
+		closeAgent()
+		conn.Close()
+		if proxy != nil {
+			_ = proxy.Wait()
+		}
+		return err
+	}
+
+	c.mu.Lock()
+	old := c.native
+	c.native = &nativeConn{client: client, proxy: proxy, agent: agentConn}
+	c.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+	fmt.Fprintf(os.Stderr, "codespace-mcp: native SSH connection established\n")
+	return nil
+}
+
+// dialNative starts the ProxyCommand, or dials the host directly without
+// one. The proxy outlives the call that starts it, so it is not tied to a
+// context; closing the returned conn kills it.
+func (c *Client) dialNative(hc *nativeHostConfig) (net.Conn, *exec.Cmd, error) {
+	if hc.proxyCommand == "" {
+		d := net.Dialer{Timeout: connectTimeout()}
+		conn, err := d.Dial("tcp", net.JoinHostPort(hc.hostName, hc.port))
+		if err != nil {
+			return nil, nil, fmt.Errorf("dialing %s: %w", hc.hostName, err)
+		}
+		return conn, nil, nil
+	}
+	cmd := c.command(context.Background(), "sh", "-c", "exec "+proxyCommandLine(hc.proxyCommand, hc))
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("starting ProxyCommand: %w", err)
+	}
+	return &proxyConn{r: stdout, w: stdin, cmd: cmd}, cmd, nil
+}
+
+// handshakeNative runs the SSH handshake on conn, giving up after the
+// connect timeout or when ctx ends.
+func handshakeNative(ctx context.Context, conn net.Conn, addr string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	type result struct {
+		client *gossh.Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{client: gossh.NewClient(c, chans, reqs)}
+	}()
+	var timeout <-chan time.Time
+	if config.Timeout > 0 {
+		timer := time.NewTimer(config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-done:
+		return r.client, r.err
+	case <-ctx.Done():
+		conn.Close()
+		<-done
+		return nil, fmt.Errorf("SSH handshake: %w", ctx.Err())
+	case <-timeout:
+		conn.Close()
+		<-done
+		return nil, fmt.Errorf("SSH handshake timed out after %s", config.Timeout)
+	}
+}
+
+// nativeAuthMethods offers the config's identity files, or ssh's default
+// ones when it names none, followed by the keys in ssh-agent. It returns the
+// connection to ssh-agent, if one was made, for the caller to close.
+func nativeAuthMethods(identityFiles []string) ([]gossh.AuthMethod, net.Conn) {
+	if len(identityFiles) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+				identityFiles = append(identityFiles, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+	var signers []gossh.Signer
+	for _, path := range identityFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		signer, err := gossh.ParsePrivateKey(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "codespace-mcp: native SSH skipping key %s: %v\n", path, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	var methods []gossh.AuthMethod
+	if len(signers) > 0 {
+		methods = append(methods, gossh.PublicKeys(signers...))
+	}
+	var agentConn net.Conn
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			agentConn = conn
+			methods = append(methods, gossh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	return methods, agentConn
+}
+
+// pinnedHostKeyCallback checks the host key the way ssh does with the config
+// pinHostKey writes: the key is looked up under the codespace name, stored on
+// first connect, and a different key is refused.
+func pinnedHostKeyCallback(codespaceName, knownHostsPath string) gossh.HostKeyCallback {
+	return func(_ string, _ net.Addr, key gossh.PublicKey) error {
+		if _, err := os.Stat(knownHostsPath); err == nil {
+			check, err := knownhosts.New(knownHostsPath)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", knownHostsPath, err)
+			}
+			// The address behind a ProxyCommand means nothing; only the
+			// alias is looked up.
+			err = check(codespaceName+":22", &net.TCPAddr{}, key)
+			var keyErr *knownhosts.KeyError
+			if !errors.As(err, &keyErr) {
+				return err
+			}
+			if len(keyErr.Want) > 0 {
+				return hostKeyChangedError(codespaceName, knownHostsPath)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0o755); err != nil {
+			return fmt.Errorf("pinning host key: %w", err)
+		}
+		f, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("pinning host key: %w", err)
+		}
+		defer f.Close()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{codespaceName}, key))
+		return err
+	}
+}
+
+// nativeConnection returns the native SSH connection, or nil when commands
+// go over ssh.
+func (c *Client) nativeConnection() *nativeConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.native
+}
+
+// dropNative stops using n after it failed, if it is still the current
+// connection.
+func (c *Client) dropNative(n *nativeConn, err error) {
+	c.mu.Lock()
+	current := c.native == n
+	if current {
+		c.native = nil
+	}
+	c.mu.Unlock()
+	if current {
+		fmt.Fprintf(os.Stderr, "codespace-mcp: native SSH connection to %s lost, running commands over ssh: %v\n", c.codespaceName, err)
+		n.close()
+	}
+}
+
+// runOnNative runs wrapped in a session on n, streaming stdout to w. It
+// returns an error wrapping errNativeUnreachable when the command could not
+// be started, so the caller can run it over ssh; a connection lost after that
+// is an error of its own, as the command may have run. Cancelling ctx kills
+// the remote command and closes its channel.
+func (c *Client) runOnNative(ctx context.Context, n *nativeConn, wrapped string, stdin io.Reader, w io.Writer) (stderr string, exitCode int, err error) {
+	session, err := n.client.NewSession()
+	if err != nil {
+		c.dropNative(n, err)
+		return "", -1, fmt.Errorf("%w: %v", errNativeUnreachable, err)
+	}
+	defer session.Close()
+
+	var errBuf bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = w
+	session.Stderr = &errBuf
+	if err := session.Start(wrapped); err != nil {
+		c.dropNative(n, err)
+		return "", -1, fmt.Errorf("%w: %v", errNativeUnreachable, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+	var waitErr error
+	select {
+	case waitErr = <-done:
+	case <-ctx.Done():
+		_ = session.Signal(gossh.SIGKILL)
+		session.Close()
+		<-done
+		return sanitizeOutput(errBuf.String()), -1, fmt.Errorf("command cancelled: %w", ctx.Err())
+	}
+	stderr = sanitizeOutput(errBuf.String())
+
+	var exitErr *gossh.ExitError
+	switch {
+	case waitErr == nil:
+		return stderr, 0, nil
+	case errors.As(waitErr, &exitErr):
+		return stderr, exitErr.ExitStatus(), nil
+	}
+	c.dropNative(n, waitErr)
+	return stderr, -1, fmt.Errorf("failed to execute command: %w", waitErr)
+}
+
+// Close closes the native SSH connection, if one is open. The multiplexed
+// ssh master is left running for other sessions.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	n := c.native
+	c.native = nil
+	c.mu.Unlock()
+	if n != nil {
+		n.close()
+	}
+	return nil
+}
+
+// proxyConn is a net.Conn over a ProxyCommand's stdin and stdout.
+type proxyConn struct {
+	r   io.ReadCloser
+	w   io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (p *proxyConn) Read(b []byte) (int, error)  { return p.r.Read(b) }
+func (p *proxyConn) Write(b []byte) (int, error) { return p.w.Write(b) }
+
+func (p *proxyConn) Close() error {
+	p.w.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	return nil
+}
+
+func (p *proxyConn) LocalAddr() net.Addr                { return proxyAddr{} }
+func (p *proxyConn) RemoteAddr() net.Addr               { return proxyAddr{} }
+func (p *proxyConn) SetDeadline(t time.Time) error      { return nil }
+func (p *proxyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (p *proxyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type proxyAddr struct{}
+
+func (proxyAddr) Network() string { return "proxy" }
+func (proxyAddr) String() string  { return "proxycommand" }
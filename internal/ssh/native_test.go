@@ -0,0 +1,343 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestParseNativeHostConfig(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := `Host cs.fluffy-disco.main
+	User vscode
+	ProxyCommand "/opt/gh cli/gh" cs ssh -c fluffy-disco --stdio -- -i "~/.ssh/codespaces.auto"
+	UserKnownHostsFile=/dev/null
+	User root
+	IdentityFile ~/.ssh/codespaces.auto
+	IdentityFile="/keys/other key"
+	ControlPath /tmp/sock
+`
+	hc, err := parseNativeHostConfig(config, "fluffy-disco")
+	if err != nil {
+		t.Fatalf("parseNativeHostConfig: %v", err)
+	}
+	want := &nativeHostConfig{
+		hostName:      "cs.fluffy-disco.main",
+		port:          "22",
+		user:          "vscode",
+		proxyCommand:  `"/opt/gh cli/gh" cs ssh -c fluffy-disco --stdio -- -i "~/.ssh/codespaces.auto"`,
+		identityFiles: []string{filepath.Join(home, ".ssh/codespaces.auto"), "/keys/other key"},
+	}
+	if !reflect.DeepEqual(hc, want) {
+		t.Fatalf("config = %+v, want %+v", hc, want)
+	}
+
+	hc, err = parseNativeHostConfig("Host local\n\tHostName 127.0.0.1\n\tPort=2222\n\tProxyCommand none\n", "x")
+	if err != nil {
+		t.Fatalf("parseNativeHostConfig: %v", err)
+	}
+	if hc.hostName != "127.0.0.1" || hc.port != "2222" || hc.proxyCommand != "" {
+		t.Fatalf("direct config = %+v", hc)
+	}
+}
+
+func TestProxyCommandLine(t *testing.T) {
+	hc := &nativeHostConfig{hostName: "cs.x", port: "22", user: "vscode"}
+	if got := proxyCommandLine("nc %h %p # %r 100%%", hc); got != "nc cs.x 22 # vscode 100%" {
+		t.Fatalf("proxyCommandLine = %q", got)
+	}
+}
+
+func TestNativeSSHEnabled(t *testing.T) {
+	for env, want := range map[string]bool{"": false, "1": true, "true": true, "0": false, "yes": false} {
+		t.Setenv(nativeSSHEnv, env)
+		if got := nativeSSHEnabled(); got != want {
+			t.Errorf("nativeSSHEnabled() with %q = %v, want %v", env, got, want)
+		}
+	}
+}
+
+func TestPinnedHostKeyCallback(t *testing.T) {
+	knownHosts := filepath.Join(t.TempDir(), ".known_hosts-cs")
+	check := pinnedHostKeyCallback("cs", knownHosts)
+	key, other := testHostKey(t).PublicKey(), testHostKey(t).PublicKey()
+
+	if err := check("cs.cs.main:22", &net.TCPAddr{}, key); err != nil {
+		t.Fatalf("first connect: %v", err)
+	}
+	data, _ := os.ReadFile(knownHosts)
+	if !strings.HasPrefix(string(data), "cs ") {
+		t.Fatalf("known_hosts = %q, want an entry for the codespace name", data)
+	}
+	if err := check("cs.cs.other-branch:22", &net.TCPAddr{}, key); err != nil {
+		t.Fatalf("pinned key: %v", err)
+	}
+	if err := check("cs.cs.main:22", &net.TCPAddr{}, other); !errors.Is(err, ErrHostKeyChanged) {
+		t.Fatalf("changed key: err = %v, want ErrHostKeyChanged", err)
+	}
+}
+
+func TestRunOnNative(t *testing.T) {
+	c := NewClient("native")
+	c.commandContext = func(context.Context, string, ...string) *exec.Cmd {
+		t.Fatal("ran a subprocess with a native connection open")
+		return nil
+	}
+	c.native = startNativeTestServer(t)
+	ctx := context.Background()
+
+	stdout, stderr, exitCode, err := c.runRemoteCommand(ctx, "echo out; echo err >&2; exit 3", true)
+	if err != nil || stdout != "out\n" || stderr != "err\n" || exitCode != 3 {
+		t.Fatalf("runRemoteCommand = (%q, %q, %d, %v)", stdout, stderr, exitCode, err)
+	}
+	stdout, _, exitCode, err = c.runRemoteCommandWithInput(ctx, "tr a-z A-Z", []byte("hello"), true)
+	if err != nil || stdout != "HELLO" || exitCode != 0 {
+		t.Fatalf("runRemoteCommandWithInput = (%q, %d, %v)", stdout, exitCode, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err = c.runRemoteCommand(ctx, "sleep 10", true)
+	if err == nil || !strings.Contains(err.Error(), "command cancelled") {
+		t.Fatalf("cancelled command: err = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancelled command took %s", elapsed)
+	}
+	if c.nativeConnection() == nil {
+		t.Fatal("cancelling a command dropped the native connection")
+	}
+}
+
+func TestRunOnNativeFallsBackWhenConnectionLost(t *testing.T) {
+	var calls []fakeExecCall
+	c := NewClient("native")
+	c.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{stdout: "from ssh"}})
+	c.setSSHState("/tmp/config", "cs.native", "")
+	n := startNativeTestServer(t)
+	n.client.Close()
+	c.native = n
+
+	stdout, _, _, err := c.runRemoteCommand(context.Background(), "echo hi", true)
+	if err != nil || stdout != "from ssh" || len(calls) != 1 {
+		t.Fatalf("runRemoteCommand = (%q, %v) with %d ssh calls, want the ssh result", stdout, err, len(calls))
+	}
+	if c.nativeConnection() != nil {
+		t.Fatal("lost native connection was kept")
+	}
+}
+
+func TestConnectNativeRunsProxyCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	agentConns := listenFakeAgent(t)
+	addr := listenNativeTestServer(t)
+	configPath := filepath.Join(home, "ssh_config")
+	config := "Host cs.native.main\n\tUser test\n\tProxyCommand gh cs ssh -c native --stdio -- %h\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var proxyArgs []string
+	c := NewClientWithConfig("native", configPath, "cs.native.main")
+	c.SetHostKeyPinning(true)
+	c.commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		proxyArgs = append([]string{name}, args...)
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=TestNativeProxyHelperProcess")
+		cmd.Env = append(os.Environ(), "GO_NATIVE_PROXY_ADDR="+addr)
+		return cmd
+	}
+	if err := c.ConnectNative(context.Background()); err != nil {
+		t.Fatalf("ConnectNative: %v", err)
+	}
+
+	if want := []string{"sh", "-c", "exec gh cs ssh -c native --stdio -- cs.native.main"}; !reflect.DeepEqual(proxyArgs, want) {
+		t.Errorf("proxy = %q, want %q", proxyArgs, want)
+	}
+	if _, err := os.Stat(filepath.Join(WorkdirsDir(home), ".known_hosts-native")); err != nil {
+		t.Errorf("host key not pinned: %v", err)
+	}
+	stdout, _, exitCode, err := c.runRemoteCommand(context.Background(), "echo over proxy", true)
+	if err != nil || stdout != "over proxy\n" || exitCode != 0 {
+		t.Fatalf("runRemoteCommand = (%q, %d, %v)", stdout, exitCode, err)
+	}
+
+	c.Close()
+	select {
+	case agentConn := <-agentConns:
+		defer agentConn.Close()
+		agentConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := agentConn.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("ssh-agent connection after Close: read err = %v, want EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConnectNative did not connect to ssh-agent")
+	}
+}
+
+// listenFakeAgent points SSH_AUTH_SOCK at a socket that accepts connections
+// without answering, and returns the server side of each.
+func listenFakeAgent(t *testing.T) <-chan net.Conn {
+	t.Helper()
+	// macOS limits socket paths to 104 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	return conns
+}
+
+// TestNativeProxyHelperProcess stands in for gh's ProxyCommand, relaying
+// stdin and stdout to GO_NATIVE_PROXY_ADDR.
+func TestNativeProxyHelperProcess(t *testing.T) {
+	addr := os.Getenv("GO_NATIVE_PROXY_ADDR")
+	if addr == "" {
+		return
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		os.Exit(1)
+	}
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+		conn.Close()
+	}()
+	_, _ = io.Copy(os.Stdout, conn)
+	os.Exit(0)
+}
+
+func testHostKey(t *testing.T) gossh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// startNativeTestServer runs an in-process SSH server that executes each
+// exec request locally with bash, and returns a connection to it.
+func startNativeTestServer(t *testing.T) *nativeConn {
+	t.Helper()
+	client, err := gossh.Dial("tcp", listenNativeTestServer(t), &gossh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &nativeConn{client: client}
+}
+
+// listenNativeTestServer starts the server behind startNativeTestServer and
+// returns its address.
+func listenNativeTestServer(t *testing.T) string {
+	t.Helper()
+	config := &gossh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(testHostKey(t))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveNativeTestConn(conn, config)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveNativeTestConn(conn net.Conn, config *gossh.ServerConfig) {
+	_, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(reqs)
+	for newCh := range chans {
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go serveNativeTestSession(ch, reqs)
+	}
+}
+
+func serveNativeTestSession(ch gossh.Channel, reqs <-chan *gossh.Request) {
+	var cmd *exec.Cmd
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := gossh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			cmd = exec.Command("bash", "-c", payload.Command)
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = ch, ch, ch.Stderr()
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go func(cmd *exec.Cmd) {
+				_ = cmd.Wait()
+				if code := cmd.ProcessState.ExitCode(); code >= 0 {
+					ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{uint32(code)}))
+				}
+				ch.Close()
+			}(cmd)
+		case "signal":
+			if cmd != nil {
+				_ = cmd.Process.Kill()
+			}
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
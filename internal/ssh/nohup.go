@@ -0,0 +1,250 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// nohupSessionsDir holds the sessions StartSession runs without tmux, one
+// directory per session: the command's output in log, its process group in
+// pid, its exit code in status once it exits, the start time in created, and
+// a FIFO in that feeds its stdin.
+const nohupSessionsDir = "/tmp/copilot-codespace-sessions"
+
+// sessionBackend is how a client runs async sessions.
+type sessionBackend int
+
+const (
+	backendTmux  sessionBackend = iota // tmux, installed with mise when missing
+	backendNohup                       // setsid/nohup with log files, once tmux could not be provisioned
+)
+
+// tmuxUnavailableError reports that the codespace answered but tmux could not
+// be provisioned there, as opposed to a connection failure.
+type tmuxUnavailableError struct{ msg string }
+
+func (e *tmuxUnavailableError) Error() string { return e.msg }
+
+// nohupStateMarker separates a session's output from its state in
+// nohupReadCommand output.
+const nohupStateMarker = "== copilot-session-state"
+
+// nohupStateScript prints "dead exitcode" for the session in $d, in the form
+// tmux reports pane_dead and pane_dead_status; the exit code is -1 when the
+// process is gone without recording one. A zombie counts as gone, since
+// containers without an init may never reap it.
+const nohupStateScript = `if [ -f "$d/status" ]; then echo "1 $(cat "$d/status")"; ` +
+	`elif grep -q '^State:[[:space:]]*[^Z]' "/proc/$(cat "$d/pid" 2>/dev/null)/status" 2>/dev/null; then echo "0 0"; else echo "1 -1"; fi`
+
+// sessionBackend returns how sessions run. Until tmux fails to install it is
+// tmux, and ensureTmux runs so a missing tmux is installed as before; a
+// provisioning failure switches the client to nohup sessions for good.
+func (c *Client) sessionBackend(ctx context.Context) (sessionBackend, error) {
+	if c.usesNohupSessions() {
+		return backendNohup, nil
+	}
+	err := c.ensureTmux(ctx)
+	var unavailable *tmuxUnavailableError
+	if errors.As(err, &unavailable) {
+		fmt.Fprintf(os.Stderr, "codespace-mcp: %v; running async sessions with nohup and log files instead\n", err)
+		c.mu.Lock()
+		c.nohupSessions = true
+		c.mu.Unlock()
+		return backendNohup, nil
+	}
+	return backendTmux, err
+}
+
+func (c *Client) usesNohupSessions() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nohupSessions
+}
+
+// SessionsHaveTTY reports whether sessions run in a terminal: true for tmux
+// panes, false once sessions fall back to nohup.
+func (c *Client) SessionsHaveTTY(ctx context.Context) bool {
+	backend, err := c.sessionBackend(ctx)
+	return err == nil && backend == backendTmux
+}
+
+// nohupSessionDir is the directory of the named session.
+func nohupSessionDir(base, name string) string {
+	return path.Join(base, recordingFileName(name))
+}
+
+// nohupStartCommand starts command detached in its own session (setsid when
+// the codespace has it) so it outlives the ssh call, with stdin on the
+// session's FIFO. The wrapper holds the FIFO open read-write, so writers never
+// block and the command sees no EOF between writes. It returns once the pid
+// is recorded.
+func nohupStartCommand(dir, command string) string {
+	return fmt.Sprintf(`d=%[1]s; rm -rf "$d" && mkdir -p "$d" && mkfifo "$d/in" && : > "$d/log" && date +%%s > "$d/created" || exit 1; `+
+		`if command -v setsid >/dev/null 2>&1; then s=setsid; else s=; fi; `+
+		`$s nohup bash -c 'exec 3<>"$1/in"; echo $$ > "$1/pid"; bash -c "$2" <&3 >>"$1/log" 2>&1; echo $? > "$1/status"' bash "$d" %[2]s </dev/null >/dev/null 2>&1 & `+
+		`for i in $(seq 50); do [ -s "$d/pid" ] && exit 0; sleep 0.1; done; echo 'session process did not start' >&2; exit 1`,
+		shellQuote(dir), shellQuote(command))
+}
+
+// nohupReadCommand prints the last 100 lines of the session's output, then
+// nohupStateMarker and its state. It exits 3 when the session does not exist.
+func nohupReadCommand(dir string) string {
+	return fmt.Sprintf(`d=%s; [ -d "$d" ] || exit 3; tail -n 100 "$d/log"; echo; echo '%s'; %s`,
+		shellQuote(dir), nohupStateMarker, nohupStateScript)
+}
+
+// parseNohupRead splits nohupReadCommand output into the session's output,
+// cleaned like a tmux pane, and its state.
+func parseNohupRead(out string) (output string, dead bool, exitCode int, err error) {
+	i := strings.LastIndex(out, "\n"+nohupStateMarker+"\n")
+	if i < 0 {
+		return "", false, 0, errors.New("invalid session state")
+	}
+	dead, exitCode, err = parsePaneStatus(out[i+len(nohupStateMarker)+2:])
+	return cleanPaneOutput(out[:i]), dead, exitCode, err
+}
+
+// nohupKeys maps tmux key names from parseInput to the bytes a terminal
+// sends for them.
+var nohupKeys = map[string]string{
+	"Enter":  "\n",
+	"Up":     "\x1b[A",
+	"Down":   "\x1b[B",
+	"Right":  "\x1b[C",
+	"Left":   "\x1b[D",
+	"BSpace": "\x7f",
+}
+
+// nohupWriteCommands returns the commands that deliver input to the session's
+// stdin, one per delivery like writeSessionCommands. There is no terminal, so
+// pasted and typed text are written the same way and special keys become
+// their escape sequences.
+func nohupWriteCommands(dir, input string, opts WriteOptions, workdir string) []string {
+	write := func(source string) string {
+		return fmt.Sprintf(`d=%s; [ -p "$d/in" ] || exit 3; exec 4<>"$d/in"; %s >&4`, shellQuote(dir), source)
+	}
+
+	var cmds []string
+	if opts.PasteFile != "" {
+		file := opts.PasteFile
+		if !path.IsAbs(file) && workdir != "" {
+			file = path.Join(workdir, file)
+		}
+		cmds = append(cmds, write("cat "+shellQuote(file)))
+	}
+	for _, seg := range parseInput(input) {
+		if strings.HasPrefix(seg, "\x00") {
+			cmds = append(cmds, write(fmt.Sprintf("printf '%%s' %s", shellQuote(nohupKeys[seg[1:]]))))
+			continue
+		}
+		for _, piece := range chunkText(seg, opts.ChunkSize) {
+			cmds = append(cmds, write(fmt.Sprintf("printf '%%s' %s", shellQuote(piece))))
+		}
+	}
+	return cmds
+}
+
+// nohupStopCommand kills the session's process group and removes it. It
+// exits 3 when the session does not exist.
+func nohupStopCommand(dir string) string {
+	return fmt.Sprintf(`d=%s; [ -d "$d" ] || exit 3; p=$(cat "$d/pid" 2>/dev/null); `+
+		`if [ -n "$p" ]; then kill -TERM -- "-$p" 2>/dev/null || kill -TERM "$p" 2>/dev/null; fi; rm -rf "$d"`,
+		shellQuote(dir))
+}
+
+// nohupListCommand prints listSessionsCommand's records for the sessions
+// under base.
+func nohupListCommand(base string) string {
+	return `for d in ` + shellQuote(base) + `/` + tmuxPrefix + `*/; do [ -d "$d" ] || continue; d=${d%/}; ` +
+		`st=$(` + nohupStateScript + `); ` +
+		`last=$(grep -v '^[[:space:]]*$' "$d/log" 2>/dev/null | tail -n 1); ` +
+		`printf '%s|%s|%s|%s|%s|%s\n' "${d##*/}" "$(cat "$d/created" 2>/dev/null)" "$(stat -c %Y "$d/log" 2>/dev/null)" "${st% *}" "${st#* }" "$last"; done`
+}
+
+// nohupSessionMissing turns nohup's "no such session" exit code into the
+// error tmux sessions report.
+func nohupSessionMissing(sessionID string) error {
+	return fmt.Errorf("session %q does not exist (command may have exited and been cleaned up)", sessionID)
+}
+
+func (c *Client) startNohupSession(ctx context.Context, name, wrappedCommand string) error {
+	_, stderr, exitCode, err := c.Exec(ctx, nohupStartCommand(nohupSessionDir(nohupSessionsDir, name), wrappedCommand))
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	if exitCode != 0 {
+		return formatCommandFailure("start session", exitCode, stderr)
+	}
+	return nil
+}
+
+func (c *Client) readNohupSession(ctx context.Context, sessionID string) (string, error) {
+	stdout, stderr, exitCode, err := c.Exec(ctx, nohupReadCommand(nohupSessionDir(nohupSessionsDir, tmuxSessionName(sessionID))))
+	if err != nil {
+		return "", fmt.Errorf("read session: %w", err)
+	}
+	if exitCode == 3 {
+		return "", nohupSessionMissing(sessionID)
+	}
+	if exitCode != 0 {
+		return "", formatCommandFailure("read session", exitCode, stderr)
+	}
+	output, dead, code, err := parseNohupRead(stdout)
+	if err == nil && dead {
+		if output != "" {
+			output += "\n"
+		}
+		output += "[session exited]"
+		if code > 0 {
+			output += fmt.Sprintf("\n[exit code: %d]", code)
+		}
+	}
+	return output, nil
+}
+
+func (c *Client) writeNohupSession(ctx context.Context, sessionID, input string, opts WriteOptions) error {
+	dir := nohupSessionDir(nohupSessionsDir, tmuxSessionName(sessionID))
+	cmds := nohupWriteCommands(dir, input, opts, c.GetWorkdir())
+	if opts.ChunkDelay <= 0 {
+		cmds = []string{strings.Join(cmds, " && ")}
+	}
+	for i, cmd := range cmds {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("write session: %w", ctx.Err())
+			case <-time.After(opts.ChunkDelay):
+			}
+		}
+		_, stderr, exitCode, err := c.Exec(ctx, cmd)
+		if err != nil {
+			return fmt.Errorf("write session: %w", err)
+		}
+		if exitCode == 3 {
+			return nohupSessionMissing(sessionID)
+		}
+		if exitCode != 0 {
+			return formatCommandFailure("write session", exitCode, stderr)
+		}
+	}
+	return nil
+}
+
+func (c *Client) stopNohupSession(ctx context.Context, sessionID string) error {
+	_, stderr, exitCode, err := c.Exec(ctx, nohupStopCommand(nohupSessionDir(nohupSessionsDir, tmuxSessionName(sessionID))))
+	if err != nil {
+		return fmt.Errorf("stop session: %w", err)
+	}
+	if exitCode == 3 {
+		return nohupSessionMissing(sessionID)
+	}
+	if exitCode != 0 {
+		return formatCommandFailure("stop session", exitCode, stderr)
+	}
+	return nil
+}
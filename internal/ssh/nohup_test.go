@@ -0,0 +1,172 @@
+package ssh
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runNohupScript runs one of the nohup session scripts with the local bash,
+// as the codespace would.
+func runNohupScript(t *testing.T, script string) (string, int) {
+	t.Helper()
+	out, err := exec.Command("bash", "-c", script).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("bash: %v", err)
+	}
+	return string(out), 0
+}
+
+func TestNohupSessionLifecycle(t *testing.T) {
+	if _, err := exec.LookPath("mkfifo"); err != nil {
+		t.Skip("mkfifo not installed")
+	}
+	base := t.TempDir()
+	dir := nohupSessionDir(base, tmuxSessionName("s1"))
+	if _, code := runNohupScript(t, nohupStartCommand(dir, `echo ready; read line; echo "got $line"; exit 4`)); code != 0 {
+		t.Fatalf("start exited %d", code)
+	}
+
+	waitFor := func(want string) (string, bool, int) {
+		t.Helper()
+		var output string
+		var dead bool
+		var exitCode int
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			out, code := runNohupScript(t, nohupReadCommand(dir))
+			if code != 0 {
+				t.Fatalf("read exited %d", code)
+			}
+			var err error
+			output, dead, exitCode, err = parseNohupRead(out)
+			if err != nil {
+				t.Fatalf("parseNohupRead(%q): %v", out, err)
+			}
+			if strings.Contains(output, want) {
+				return output, dead, exitCode
+			}
+		}
+		t.Fatalf("output = %q, want %q", output, want)
+		return "", false, 0
+	}
+
+	if _, dead, _ := waitFor("ready"); dead {
+		t.Fatal("session reported dead while waiting for input")
+	}
+	for _, cmd := range nohupWriteCommands(dir, "hello{enter}", WriteOptions{}, "") {
+		if _, code := runNohupScript(t, cmd); code != 0 {
+			t.Fatalf("write exited %d", code)
+		}
+	}
+	output, dead, exitCode := waitFor("got hello")
+	for deadline := time.Now().Add(5 * time.Second); !dead && time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		out, _ := runNohupScript(t, nohupReadCommand(dir))
+		output, dead, exitCode, _ = parseNohupRead(out)
+	}
+	if !dead || exitCode != 4 || output != "ready\ngot hello" {
+		t.Fatalf("after exit: output=%q dead=%v exitCode=%d", output, dead, exitCode)
+	}
+
+	out, _ := runNohupScript(t, nohupListCommand(base))
+	sessions := parseSessionList(out)
+	if len(sessions) != 1 || sessions[0].ID != "s1" || !sessions[0].Exited || sessions[0].ExitCode != 4 || sessions[0].LastLine != "got hello" || sessions[0].Created.IsZero() {
+		t.Fatalf("sessions = %+v from %q", sessions, out)
+	}
+
+	if _, code := runNohupScript(t, nohupStopCommand(dir)); code != 0 {
+		t.Fatalf("stop exited %d", code)
+	}
+	if _, code := runNohupScript(t, nohupReadCommand(dir)); code != 3 {
+		t.Fatalf("read after stop exited %d, want 3", code)
+	}
+}
+
+func TestNohupStopKillsRunningSession(t *testing.T) {
+	if _, err := exec.LookPath("mkfifo"); err != nil {
+		t.Skip("mkfifo not installed")
+	}
+	base := t.TempDir()
+	dir := nohupSessionDir(base, tmuxSessionName("long"))
+	if _, code := runNohupScript(t, nohupStartCommand(dir, "sleep 30")); code != 0 {
+		t.Fatalf("start exited %d", code)
+	}
+	pid, _ := runNohupScript(t, "cat "+shellQuote(filepath.Join(dir, "pid")))
+	if _, code := runNohupScript(t, nohupStopCommand(dir)); code != 0 {
+		t.Fatalf("stop exited %d", code)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if _, code := runNohupScript(t, "grep -q '^State:[[:space:]]*[^Z]' /proc/"+strings.TrimSpace(pid)+"/status 2>/dev/null"); code != 0 {
+			return
+		}
+	}
+	t.Fatalf("session process %s still running after stop", strings.TrimSpace(pid))
+}
+
+func TestNohupWriteCommands(t *testing.T) {
+	got := nohupWriteCommands("/tmp/s", "ab{up}", WriteOptions{ChunkSize: 1, PasteFile: "in.txt"}, "/workspaces/repo")
+	prefix := `d='/tmp/s'; [ -p "$d/in" ] || exit 3; exec 4<>"$d/in"; `
+	want := []string{
+		prefix + `cat '/workspaces/repo/in.txt' >&4`,
+		prefix + `printf '%s' 'a' >&4`,
+		prefix + `printf '%s' 'b' >&4`,
+		prefix + "printf '%s' '\x1b[A' >&4",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("nohupWriteCommands() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStartSessionFallsBackToNohupWhenTmuxCannotBeInstalled(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{exitCode: 1}, // command -v tmux
+		{stderr: "curl: (6) Could not resolve host: mise.jdx.dev\n", exitCode: 2}, // mise install
+		{}, // nohup start
+		{}, // second start: no tmux check
+		{stdout: "working\n\n" + nohupStateMarker + "\n0 0\n"}, // read
+	})
+
+	if err := client.StartSession(context.Background(), "s1", "make", "/workspaces/repo"); err != nil {
+		t.Fatalf("StartSession() error = %v", err)
+	}
+	if err := client.StartSession(context.Background(), "s2", "make test", "/workspaces/repo"); err != nil {
+		t.Fatalf("second StartSession() error = %v", err)
+	}
+	got, err := client.ReadSession(context.Background(), "s2")
+	if err != nil || got != "working" {
+		t.Fatalf("ReadSession() = %q, %v", got, err)
+	}
+	if client.SessionsHaveTTY(context.Background()) {
+		t.Error("SessionsHaveTTY() = true after falling back to nohup")
+	}
+
+	wrapped := envSecretsLoader + " && " + wrapCommandInWorkdir("make", "/workspaces/repo")
+	wantStart := envSecretsLoader + " && " + nohupStartCommand(nohupSessionDir(nohupSessionsDir, "copilot-s1"), wrapped)
+	if got := calls[2].args[len(calls[2].args)-1]; got != wantStart {
+		t.Errorf("start command = %q, want %q", got, wantStart)
+	}
+	if len(calls) != 5 {
+		t.Errorf("calls = %d, want 5", len(calls))
+	}
+}
+
+func TestStartSessionKeepsTmuxErrorOnConnectionFailure(t *testing.T) {
+	client := NewClient("demo")
+	client.commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "/nonexistent/gh")
+	}
+	if err := client.StartSession(context.Background(), "s1", "make", ""); err == nil {
+		t.Fatal("StartSession() error = nil, want connection error")
+	}
+	if client.usesNohupSessions() {
+		t.Error("client switched to nohup sessions after a connection failure")
+	}
+}
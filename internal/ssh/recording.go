@@ -0,0 +1,212 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// recordingDir holds async session recordings on the codespace until
+// CollectRecordings copies them out.
+const recordingDir = "/tmp/copilot-codespace-casts"
+
+// Recordings use the tmux pane size StartSession creates.
+const (
+	recordingWidth  = 200
+	recordingHeight = 50
+)
+
+// SetSessionRecording makes StartSession record each async session's terminal,
+// with asciinema when the codespace has it and script(1) otherwise.
+func (c *Client) SetSessionRecording(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.recordSessions = enabled
+}
+
+// recordingSession reports whether sessions are recorded and, if so, notes
+// name so CollectRecordings fetches it.
+func (c *Client) recordingSession(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.recordSessions {
+		return false
+	}
+	if c.recorded == nil {
+		c.recorded = make(map[string]bool)
+	}
+	c.recorded[name] = true
+	return true
+}
+
+// recordCommand wraps command so its terminal output is recorded under
+// recordingDir as <name>.cast, or as <name>.log plus a classic <name>.timing
+// file when asciinema is not installed. Either way the command's exit status
+// is preserved for ReadSession.
+func recordCommand(name, command string) string {
+	base := recordingDir + "/" + name
+	inner := "bash -c " + shellQuote(command)
+	return fmt.Sprintf(`mkdir -p %[1]s && rm -f %[2]s.*; `+
+		`if command -v asciinema >/dev/null 2>&1; then `+
+		`asciinema rec -q --overwrite -c %[3]s %[2]s.cast; s=$(cat %[2]s.status 2>/dev/null); rm -f %[2]s.status; exit "${s:-1}"; `+
+		`else exec script -q -e -f --timing=%[2]s.timing -c %[4]s %[2]s.log; fi`,
+		shellQuote(recordingDir), shellQuote(base),
+		shellQuote(inner+"; echo $? > "+shellQuote(base+".status")), shellQuote(inner))
+}
+
+// CollectRecordings copies the recordings of sessions started by this client
+// into localDir as asciicast v2 files named <prefix><session>.cast, and
+// removes them from the codespace. script(1) recordings are converted. It
+// returns the files written; a session still running yields a partial cast.
+func (c *Client) CollectRecordings(ctx context.Context, localDir, prefix string) ([]string, error) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.recorded))
+	for name := range c.recorded {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(localDir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating recordings dir: %w", err)
+	}
+
+	var written []string
+	var errs []string
+	for _, name := range names {
+		name = recordingFileName(name)
+		base := recordingDir + "/" + name
+		cast, ok, err := c.readRemoteFile(ctx, base+".cast")
+		if err == nil && !ok {
+			var timing, typescript []byte
+			if timing, ok, err = c.readRemoteFile(ctx, base+".timing"); err == nil && ok {
+				if typescript, ok, err = c.readRemoteFile(ctx, base+".log"); err == nil && ok {
+					cast, err = scriptToCast(timing, typescript, time.Now())
+				}
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		path := filepath.Join(localDir, prefix+strings.TrimPrefix(name, tmuxPrefix)+".cast")
+		if err := os.WriteFile(path, cast, 0o600); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		written = append(written, path)
+		c.Exec(ctx, "rm -f "+shellQuote(base)+".*")
+	}
+	if len(errs) > 0 {
+		return written, fmt.Errorf("collecting recordings: %s", strings.Join(errs, "; "))
+	}
+	return written, nil
+}
+
+// recordingFileName makes a tmux session name safe to use as a file name.
+func recordingFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// readRemoteFile returns the contents of path on the codespace; ok is false
+// when it does not exist.
+func (c *Client) readRemoteFile(ctx context.Context, path string) (data []byte, ok bool, err error) {
+	stdout, stderr, exitCode, err := c.Exec(ctx, fmt.Sprintf("test -f %s || exit 3; base64 < %s", shellQuote(path), shellQuote(path)))
+	if err != nil {
+		return nil, false, err
+	}
+	if exitCode == 3 {
+		return nil, false, nil
+	}
+	if exitCode != 0 {
+		return nil, false, formatCommandFailure("read "+path, exitCode, stderr)
+	}
+	data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(stdout), ""))
+	if err != nil {
+		return nil, false, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return data, true, nil
+}
+
+// scriptToCast converts a script(1) typescript and its classic timing file
+// ("<delay> <bytes>" per line) into an asciicast v2 recording.
+func scriptToCast(timing, typescript []byte, started time.Time) ([]byte, error) {
+	// Skip the "Script started on ..." header line.
+	if bytes.HasPrefix(typescript, []byte("Script started")) {
+		if i := bytes.IndexByte(typescript, '\n'); i >= 0 {
+			typescript = typescript[i+1:]
+		}
+	}
+
+	var out bytes.Buffer
+	header, _ := json.Marshal(map[string]any{"version": 2, "width": recordingWidth, "height": recordingHeight, "timestamp": started.Unix()})
+	out.Write(header)
+	out.WriteByte('\n')
+
+	var elapsed float64
+	var pending []byte
+	scanner := bufio.NewScanner(bytes.NewReader(timing))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		delay, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timing %q: %w", scanner.Text(), err)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("parsing timing %q", scanner.Text())
+		}
+		elapsed += delay
+		n = min(n, len(typescript))
+		pending = append(pending, typescript[:n]...)
+		typescript = typescript[n:]
+
+		// Hold back a multi-byte character split across chunks.
+		complete := len(pending)
+		for i := len(pending) - 1; i >= 0 && i >= len(pending)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(pending[i]) {
+				if !utf8.FullRune(pending[i:]) {
+					complete = i
+				}
+				break
+			}
+		}
+		if complete == 0 {
+			continue
+		}
+		event, _ := json.Marshal([]any{elapsed, "o", string(pending[:complete])})
+		out.Write(event)
+		out.WriteByte('\n')
+		pending = append([]byte(nil), pending[complete:]...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		event, _ := json.Marshal([]any{elapsed, "o", string(pending)})
+		out.Write(event)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// castOutput concatenates the output events of an asciicast v2 recording.
+func castOutput(t *testing.T, cast []byte) string {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(cast))
+	if !scanner.Scan() {
+		t.Fatal("cast has no header")
+	}
+	var header map[string]any
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header["version"] != float64(2) {
+		t.Fatalf("header = %s (%v)", scanner.Bytes(), err)
+	}
+	var out strings.Builder
+	last := 0.0
+	for scanner.Scan() {
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 || event[1] != "o" {
+			t.Fatalf("event = %s (%v)", scanner.Bytes(), err)
+		}
+		if at := event[0].(float64); at < last {
+			t.Errorf("event time %v before %v", at, last)
+		} else {
+			last = at
+		}
+		out.WriteString(event[2].(string))
+	}
+	return out.String()
+}
+
+func TestScriptToCast(t *testing.T) {
+	typescript := []byte("Script started on 2026-01-01 [COMMAND=\"x\"]\nhi\r\nh\xc3\xa9llo\n")
+	// The é is split across the second and third chunks.
+	timing := []byte("0.5 4\n0.25 2\n1.0 5\n")
+	cast, err := scriptToCast(timing, typescript, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := castOutput(t, cast); got != "hi\r\nhéllo\n" {
+		t.Errorf("output = %q", got)
+	}
+	if !bytes.Contains(cast, []byte(`"timestamp":1700000000`)) || !bytes.Contains(cast, []byte(`[1.75,"o","éllo\n"]`)) {
+		t.Errorf("cast =\n%s", cast)
+	}
+
+	if _, err := scriptToCast([]byte("x 3\n"), typescript, time.Now()); err == nil {
+		t.Error("expected error for malformed timing")
+	}
+}
+
+func TestRecordCommandWithScript(t *testing.T) {
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script not installed")
+	}
+	// Hide asciinema so the script(1) fallback is exercised.
+	binDir := t.TempDir()
+	for _, tool := range []string{"bash", "script", "mkdir", "rm", "cat"} {
+		if p, err := exec.LookPath(tool); err == nil {
+			os.Symlink(p, filepath.Join(binDir, tool))
+		}
+	}
+	name := "copilot-test-" + strconv.Itoa(os.Getpid())
+	base := filepath.Join(recordingDir, name)
+	t.Cleanup(func() {
+		for _, ext := range []string{".log", ".timing", ".cast", ".status"} {
+			os.Remove(base + ext)
+		}
+	})
+
+	cmd := exec.Command(filepath.Join(binDir, "bash"), "-c", recordCommand(name, "echo recorded; exit 3"))
+	cmd.Env = append(os.Environ(), "PATH="+binDir)
+	if err := cmd.Run(); err == nil || cmd.ProcessState.ExitCode() != 3 {
+		t.Fatalf("exit = %v, want 3", err)
+	}
+	timing, err := os.ReadFile(base + ".timing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typescript, err := os.ReadFile(base + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, err := scriptToCast(timing, typescript, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := castOutput(t, cast); !strings.Contains(got, "recorded") {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestCollectRecordings(t *testing.T) {
+	client := NewClient("demo")
+	client.SetSessionRecording(true)
+	if !client.recordingSession("copilot-sh/1") {
+		t.Fatal("recordingSession = false with recording enabled")
+	}
+	cast := "{\"version\":2}\n[0.1,\"o\",\"hi\"]\n"
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: base64.StdEncoding.EncodeToString([]byte(cast)) + "\n"},
+		{},
+	})
+	dir := t.TempDir()
+	files, err := client.CollectRecordings(context.Background(), dir, "stamp-alias-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "stamp-alias-sh_1.cast")
+	if len(files) != 1 || files[0] != want {
+		t.Fatalf("files = %v, want %s", files, want)
+	}
+	if got, _ := os.ReadFile(want); string(got) != cast {
+		t.Errorf("cast = %q", got)
+	}
+	if len(calls) != 2 || !strings.Contains(strings.Join(calls[1].args, " "), "rm -f") {
+		t.Errorf("calls = %v", calls)
+	}
+
+	if NewClient("demo").recordingSession("copilot-x") {
+		t.Error("recordingSession = true with recording disabled")
+	}
+}
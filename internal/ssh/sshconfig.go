@@ -0,0 +1,159 @@
+package ssh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sshConfig is an SSH config split into its Host and Match blocks, so options
+// can be added to one host while every other line is written back unchanged.
+type sshConfig struct {
+	blocks []*sshConfigBlock
+}
+
+// sshConfigBlock is a Host or Match block: its header line, option lines, and
+// any comments or blank lines up to the next block. Lines before the first
+// block form a block with an empty keyword.
+type sshConfigBlock struct {
+	keyword  string   // "host", "match", or ""
+	patterns []string // Host patterns, or Match criteria
+	lines    []string // every line including the header, with line endings
+}
+
+// parseSSHConfig splits config into blocks. It never fails: lines it doesn't
+// understand are kept in whichever block they appear.
+func parseSSHConfig(config string) *sshConfig {
+	c := &sshConfig{blocks: []*sshConfigBlock{{}}}
+	for _, line := range strings.SplitAfter(config, "\n") {
+		if line == "" {
+			continue
+		}
+		if key, args := configLineFields(line); key == "host" || key == "match" {
+			c.blocks = append(c.blocks, &sshConfigBlock{keyword: key, patterns: args})
+		}
+		b := c.blocks[len(c.blocks)-1]
+		b.lines = append(b.lines, line)
+	}
+	return c
+}
+
+// String returns the config text, with a trailing newline.
+func (c *sshConfig) String() string {
+	var b strings.Builder
+	for _, block := range c.blocks {
+		for _, line := range block.lines {
+			b.WriteString(line)
+		}
+	}
+	out := b.String()
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out
+}
+
+// codespaceHost returns the Host block for codespaceName and the alias to
+// connect with. gh names hosts "cs.<codespace>.<branch>"; a config with a
+// single concrete host is accepted whatever its name. Wildcard and negated
+// patterns never match.
+func (c *sshConfig) codespaceHost(codespaceName string) (*sshConfigBlock, string, error) {
+	var concrete []*sshConfigBlock
+	var aliases []string
+	for _, b := range c.blocks {
+		if b.keyword != "host" {
+			continue
+		}
+		alias := ""
+		for _, pattern := range b.patterns {
+			if strings.ContainsAny(pattern, "*?!") {
+				continue
+			}
+			if codespaceName != "" && hostNamesCodespace(pattern, codespaceName) {
+				return b, pattern, nil
+			}
+			if alias == "" {
+				alias = pattern
+			}
+		}
+		if alias != "" {
+			concrete = append(concrete, b)
+			aliases = append(aliases, alias)
+		}
+	}
+	if len(concrete) == 1 {
+		return concrete[0], aliases[0], nil
+	}
+	if len(concrete) == 0 {
+		return nil, "", fmt.Errorf("could not parse Host from SSH config")
+	}
+	return nil, "", fmt.Errorf("SSH config has %d hosts (%s) and none names codespace %s", len(concrete), strings.Join(aliases, ", "), codespaceName)
+}
+
+// hostNamesCodespace reports whether a dot-separated host alias contains the
+// codespace name as one of its parts.
+func hostNamesCodespace(alias, codespaceName string) bool {
+	for _, part := range strings.Split(alias, ".") {
+		if part == codespaceName {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOption reports whether the block sets key (case-insensitive).
+func (b *sshConfigBlock) hasOption(key string) bool {
+	for _, line := range b.lines[min(1, len(b.lines)):] {
+		if k, _ := configLineFields(line); k == strings.ToLower(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOptions drops the block's lines that set any of keys (lowercase).
+func (b *sshConfigBlock) removeOptions(keys []string) {
+	kept := b.lines[:0]
+	for i, line := range b.lines {
+		k, _ := configLineFields(line)
+		if i > 0 && slices.Contains(keys, k) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	b.lines = kept
+}
+
+// addOptions appends option lines after the block's last option, ahead of
+// trailing comments and blank lines that belong visually to the next block.
+func (b *sshConfigBlock) addOptions(options ...string) {
+	at := len(b.lines)
+	for at > 1 {
+		if k, _ := configLineFields(b.lines[at-1]); k != "" {
+			break
+		}
+		at--
+	}
+	if at > 0 && !strings.HasSuffix(b.lines[at-1], "\n") {
+		b.lines[at-1] += "\n"
+	}
+	added := make([]string, len(options))
+	for i, opt := range options {
+		added[i] = "\t" + opt + "\n"
+	}
+	b.lines = append(b.lines[:at], append(added, b.lines[at:]...)...)
+}
+
+// configLineFields returns a config line's lowercase keyword and arguments.
+// Keywords may be separated from arguments by whitespace or '='. Blank lines
+// and comments return an empty keyword.
+func configLineFields(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '='
+	})
+	return strings.ToLower(fields[0]), fields[1:]
+}
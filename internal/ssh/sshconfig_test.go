@@ -0,0 +1,103 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+)
+
+const multiHostConfig = "# generated by gh\n" +
+	"Host *\n" +
+	"\tServerAliveInterval 30\n" +
+	"\n" +
+	"Host cs.develop-abc.main\n" +
+	"\tUser codespace\n" +
+	"\tProxyCommand gh cs ssh -c develop-abc --stdio\n" +
+	"\n" +
+	"# other codespace\n" +
+	"Host cs.develop-xyz.main\n" +
+	"\tUser codespace\n" +
+	"\tProxyCommand gh cs ssh -c develop-xyz --stdio\n"
+
+func TestParseSSHConfigRoundTrips(t *testing.T) {
+	for _, config := range []string{multiHostConfig, "Host only\n\tUser me\n", ""} {
+		if got := parseSSHConfig(config).String(); got != config {
+			t.Errorf("String() = %q, want %q", got, config)
+		}
+	}
+}
+
+func TestCodespaceHost(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		codespace string
+		wantHost  string
+		wantErr   string
+	}{
+		{name: "matches codespace among several", config: multiHostConfig, codespace: "develop-xyz", wantHost: "cs.develop-xyz.main"},
+		{name: "skips wildcard block", config: "Host *\n\tUser x\nHost cs.a.main\n", codespace: "other", wantHost: "cs.a.main"},
+		{name: "picks pattern from multi-pattern line", config: "Host alias cs.develop-abc.main\n", codespace: "develop-abc", wantHost: "cs.develop-abc.main"},
+		{name: "equals separator", config: "Host=cs.develop-abc.main\n", codespace: "develop-abc", wantHost: "cs.develop-abc.main"},
+		{name: "ambiguous", config: multiHostConfig, codespace: "develop", wantErr: "none names codespace develop"},
+		{name: "no host", config: "Host *\n\tUser x\n", codespace: "develop-abc", wantErr: "could not parse Host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, host, err := parseSSHConfig(tt.config).codespaceHost(tt.codespace)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || host != tt.wantHost {
+				t.Fatalf("codespaceHost() = %q, %v, want %q", host, err, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestMultiplexConfigKeepsOtherBlocks(t *testing.T) {
+	got, host, err := multiplexConfig(multiHostConfig, "develop-abc", "/tmp/sock", "/tmp/known", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "cs.develop-abc.main" {
+		t.Errorf("host = %q", host)
+	}
+	want := "# generated by gh\n" +
+		"Host *\n" +
+		"\tServerAliveInterval 30\n" +
+		"\n" +
+		"Host cs.develop-abc.main\n" +
+		"\tUser codespace\n" +
+		"\tProxyCommand gh cs ssh -c develop-abc --stdio\n" +
+		"\tControlPath /tmp/sock\n" +
+		"\tControlPersist 600\n" +
+		"\n" +
+		"# other codespace\n" +
+		"Host cs.develop-xyz.main\n" +
+		"\tUser codespace\n" +
+		"\tProxyCommand gh cs ssh -c develop-xyz --stdio\n"
+	if got != want {
+		t.Errorf("multiplexConfig() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMultiplexConfigRespectsExistingOptions(t *testing.T) {
+	config := "Host cs.develop-abc.main\n\tcontrolpath=/custom\n\tStrictHostKeyChecking no"
+	got, _, err := multiplexConfig(config, "develop-abc", "/tmp/sock", "/tmp/known", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Host cs.develop-abc.main\n" +
+		"\tcontrolpath=/custom\n" +
+		"\tControlPersist 600\n" +
+		"\tUserKnownHostsFile \"/tmp/known\"\n" +
+		"\tStrictHostKeyChecking accept-new\n" +
+		"\tHostKeyAlias develop-abc\n" +
+		"\tLogLevel ERROR\n"
+	if got != want {
+		t.Errorf("multiplexConfig() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build integration
+
+package ssh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+	"github.com/ekroon/gh-copilot-codespace/internal/sshtest"
+)
+
+// These tests run the real ssh.Client against a throwaway local sshd.
+// Run: go test -tags integration -run LocalSSHD -v ./internal/ssh/
+
+func TestLocalSSHD_FileOperations(t *testing.T) {
+	srv := sshtest.Start(t)
+	client := srv.Client("local")
+	ctx := context.Background()
+	wd := t.TempDir()
+	client.SetWorkdir(wd)
+
+	path := filepath.Join(wd, "dir with space", "hello.txt")
+	if err := client.CreateFile(ctx, path, "hello\nworld\n"); err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if err := client.EditFile(ctx, path, "world", "sshd"); err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading created file: %v", err)
+	}
+	if string(got) != "hello\nsshd\n" {
+		t.Fatalf("file content = %q", got)
+	}
+
+	view, err := client.ViewFile(ctx, path, nil)
+	if err != nil {
+		t.Fatalf("ViewFile: %v", err)
+	}
+	if !strings.Contains(view, "sshd") {
+		t.Fatalf("ViewFile output = %q", view)
+	}
+}
+
+func TestLocalSSHD_RunBashGrepGlob(t *testing.T) {
+	srv := sshtest.Start(t)
+	client := srv.Client("local")
+	ctx := context.Background()
+	wd := t.TempDir()
+	client.SetWorkdir(wd)
+
+	if err := os.WriteFile(filepath.Join(wd, "main.go"), []byte("package main // needle\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode, err := client.RunBash(ctx, "pwd && exit 3", "")
+	if err != nil {
+		t.Fatalf("RunBash: %v", err)
+	}
+	if exitCode != 3 || strings.TrimSpace(stdout) != wd {
+		t.Fatalf("RunBash = (%q, %d), want (%q, 3)", stdout, exitCode, wd)
+	}
+
+	grep, err := client.Grep(ctx, "needle", ".", "", "", ssh.SearchOptions{})
+	if err != nil {
+		t.Fatalf("Grep: %v", err)
+	}
+	if !strings.Contains(grep, "main.go:1:") {
+		t.Fatalf("Grep output = %q", grep)
+	}
+
+	glob, err := client.Glob(ctx, []string{"*.go"}, ".", "", ssh.SearchOptions{})
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if !strings.Contains(glob, "main.go") {
+		t.Fatalf("Glob output = %q", glob)
+	}
+}
+
+func TestLocalSSHD_NativeBackend(t *testing.T) {
+	srv := sshtest.Start(t)
+	client := srv.Client("local")
+	ctx := context.Background()
+	if err := client.ConnectNative(ctx); err != nil {
+		t.Fatalf("ConnectNative: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	wd := t.TempDir()
+	client.SetWorkdir(wd)
+
+	stdout, _, exitCode, err := client.RunBash(ctx, "pwd && exit 4", "")
+	if err != nil {
+		t.Fatalf("RunBash: %v", err)
+	}
+	if exitCode != 4 || strings.TrimSpace(stdout) != wd {
+		t.Fatalf("RunBash = (%q, %d), want (%q, 4)", stdout, exitCode, wd)
+	}
+
+	var streamed strings.Builder
+	if _, exitCode, err := client.ExecStream(ctx, "printf 'a\\nb\\n'", &streamed); err != nil || exitCode != 0 {
+		t.Fatalf("ExecStream = (%d, %v)", exitCode, err)
+	}
+	if streamed.String() != "a\nb\n" {
+		t.Fatalf("ExecStream output = %q", streamed.String())
+	}
+}
@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RemoteUser describes who commands run as on the codespace. Devcontainers
+// differ here: some run as root, others as vscode, node, or codespace, which
+// moves every $HOME-based path. The workspace owner is usually the
+// devcontainer's remoteUser, which the VS Code server (and so its IDE lock
+// files) runs as even when SSH logs in as someone else.
+type RemoteUser struct {
+	Name               string `json:"name,omitempty"`
+	Home               string `json:"home,omitempty"`
+	WorkspaceOwner     string `json:"workspaceOwner,omitempty"`
+	WorkspaceOwnerHome string `json:"workspaceOwnerHome,omitempty"`
+}
+
+// remoteUserProbe prints the SSH user, its home ($HOME, or the passwd entry
+// when HOME is unset), the owner of the workdir, and that owner's home.
+func remoteUserProbe(workdir string) string {
+	return fmt.Sprintf(`u=$(id -un); echo "$u"; echo "${HOME:-$(getent passwd "$u" | cut -d: -f6)}"; `+
+		`o=$(stat -c %%U %s 2>/dev/null); echo "$o"; [ -n "$o" ] && getent passwd "$o" | cut -d: -f6`, shellQuote(workdir))
+}
+
+// parseRemoteUser parses remoteUserProbe output.
+func parseRemoteUser(out string) RemoteUser {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	field := func(i int) string {
+		if i < len(lines) {
+			return strings.TrimSpace(lines[i])
+		}
+		return ""
+	}
+	u := RemoteUser{Name: field(0), Home: field(1), WorkspaceOwner: field(2), WorkspaceOwnerHome: field(3)}
+	if u.WorkspaceOwner == "UNKNOWN" {
+		u.WorkspaceOwner, u.WorkspaceOwnerHome = "", ""
+	}
+	return u
+}
+
+// DetectRemoteUser probes the SSH user and the owner of workdir, and uses the
+// result for home-relative paths from then on.
+func (c *Client) DetectRemoteUser(ctx context.Context, workdir string) (RemoteUser, error) {
+	stdout, stderr, exitCode, err := c.Exec(ctx, remoteUserProbe(workdir))
+	if err != nil {
+		return RemoteUser{}, err
+	}
+	if exitCode != 0 {
+		return RemoteUser{}, formatCommandFailure("detect remote user", exitCode, stderr)
+	}
+	u := parseRemoteUser(stdout)
+	if u.Name == "" || u.Home == "" {
+		return RemoteUser{}, fmt.Errorf("detect remote user: unexpected output %q", stdout)
+	}
+	c.SetRemoteUser(u)
+	return u, nil
+}
+
+// SetRemoteUser records a user detected elsewhere, such as by the launcher
+// before it started the MCP server.
+func (c *Client) SetRemoteUser(u RemoteUser) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remoteUser = u
+}
+
+// RemoteUser returns the detected user, or the zero value if unknown.
+func (c *Client) RemoteUser() RemoteUser {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remoteUser
+}
+
+// homeExpr returns a shell expression for the remote home directory: the
+// detected path when known, $HOME otherwise.
+func (c *Client) homeExpr() string {
+	if home := c.RemoteUser().Home; home != "" {
+		return shellQuote(home)
+	}
+	return `"$HOME"`
+}
+
+// MisePathSetup prepends the user's mise shims and ~/.local/bin to PATH.
+func (c *Client) MisePathSetup() string {
+	if c.RemoteUser().Home == "" {
+		return MisePATH
+	}
+	home := c.homeExpr()
+	return fmt.Sprintf(`PATH=%[1]s/.local/bin:%[1]s/.local/share/mise/shims:"$PATH"`, home)
+}
+
+// IDELockDirs returns the directories where VS Code's Copilot extension
+// writes IDE lock files: under the SSH user's home and, when different, the
+// workspace owner's. It returns nil when the user has not been detected.
+func (c *Client) IDELockDirs() []string {
+	u := c.RemoteUser()
+	if u.Home == "" {
+		return nil
+	}
+	dirs := []string{u.Home + "/.copilot/ide"}
+	if u.WorkspaceOwnerHome != "" && u.WorkspaceOwnerHome != u.Home {
+		dirs = append(dirs, u.WorkspaceOwnerHome+"/.copilot/ide")
+	}
+	return dirs
+}
@@ -0,0 +1,100 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteUser(t *testing.T) {
+	got := parseRemoteUser("root\n/root\nvscode\n/home/vscode\n")
+	want := RemoteUser{Name: "root", Home: "/root", WorkspaceOwner: "vscode", WorkspaceOwnerHome: "/home/vscode"}
+	if got != want {
+		t.Errorf("parseRemoteUser() = %+v, want %+v", got, want)
+	}
+
+	got = parseRemoteUser("codespace\n/home/codespace\nUNKNOWN\n")
+	if got.WorkspaceOwner != "" || got.WorkspaceOwnerHome != "" {
+		t.Errorf("unknown owner should be dropped: %+v", got)
+	}
+}
+
+func TestDetectRemoteUser(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{stdout: "node\n/home/node\nvscode\n/home/vscode\n"},
+	})
+
+	u, err := client.DetectRemoteUser(context.Background(), "/workspaces/app")
+	if err != nil {
+		t.Fatalf("DetectRemoteUser() error = %v", err)
+	}
+	if u.Name != "node" || u.Home != "/home/node" || client.RemoteUser() != u {
+		t.Errorf("DetectRemoteUser() = %+v, stored %+v", u, client.RemoteUser())
+	}
+	if len(calls) != 1 || !strings.Contains(calls[0].args[len(calls[0].args)-1], "stat -c %U '/workspaces/app'") {
+		t.Errorf("calls = %#v", calls)
+	}
+}
+
+func TestDetectRemoteUserRejectsEmptyOutput(t *testing.T) {
+	client := NewClient("demo")
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{{stdout: "\n"}})
+
+	if _, err := client.DetectRemoteUser(context.Background(), "/workspaces/app"); err == nil {
+		t.Fatal("DetectRemoteUser() error = nil, want non-nil")
+	}
+	if client.RemoteUser() != (RemoteUser{}) {
+		t.Errorf("RemoteUser() = %+v, want zero value", client.RemoteUser())
+	}
+}
+
+func TestRemoteUserPaths(t *testing.T) {
+	client := NewClient("demo")
+	if got := client.MisePathSetup(); got != MisePATH {
+		t.Errorf("MisePathSetup() = %q, want %q", got, MisePATH)
+	}
+	if dirs := client.IDELockDirs(); dirs != nil {
+		t.Errorf("IDELockDirs() = %q, want nil", dirs)
+	}
+
+	client.SetRemoteUser(RemoteUser{Name: "root", Home: "/root", WorkspaceOwner: "vscode", WorkspaceOwnerHome: "/home/vscode"})
+	if got, want := client.MisePathSetup(), `PATH='/root'/.local/bin:'/root'/.local/share/mise/shims:"$PATH"`; got != want {
+		t.Errorf("MisePathSetup() = %q, want %q", got, want)
+	}
+	if got, want := client.IDELockDirs(), []string{"/root/.copilot/ide", "/home/vscode/.copilot/ide"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IDELockDirs() = %q, want %q", got, want)
+	}
+
+	client.SetRemoteUser(RemoteUser{Name: "vscode", Home: "/home/vscode", WorkspaceOwner: "vscode", WorkspaceOwnerHome: "/home/vscode"})
+	if got, want := client.IDELockDirs(), []string{"/home/vscode/.copilot/ide"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IDELockDirs() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureTmuxUsesDetectedHome(t *testing.T) {
+	client := NewClient("demo")
+	client.SetRemoteUser(RemoteUser{Name: "node", Home: "/home/node"})
+
+	var calls []fakeExecCall
+	client.commandContext = fakeCommandContext(t, &calls, []fakeExecResponse{
+		{exitCode: 1},
+		{exitCode: 0},
+		{stderr: "tmux shim exists but is not on PATH\n", exitCode: 1},
+	})
+
+	err := client.ensureTmux(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "`/home/node/.local/share/mise/shims` is not on PATH") {
+		t.Fatalf("ensureTmux() error = %v", err)
+	}
+	for i, call := range calls[1:] {
+		if cmd := call.args[len(call.args)-1]; !strings.Contains(cmd, "'/home/node'/.local/share/mise/shims") {
+			t.Errorf("call %d does not use the detected home: %q", i+2, cmd)
+		}
+	}
+}
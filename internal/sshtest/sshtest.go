@@ -0,0 +1,169 @@
+// Package sshtest runs a throwaway local sshd so the SSH client and fetch
+// pipeline can be exercised without a GitHub Codespace.
+//
+// The server listens on 127.0.0.1, accepts a freshly generated key for the
+// current user, and is torn down when the test finishes. Tests are skipped
+// when no sshd binary is available; set SSHD_PATH to point at a specific one.
+package sshtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekroon/gh-copilot-codespace/internal/ssh"
+)
+
+// Host is the SSH host alias written to the generated client config.
+const Host = "local-sshd"
+
+// Server is a running local sshd.
+type Server struct {
+	Dir        string // scratch directory holding keys and configs
+	ConfigPath string // client ssh config with a Host entry for Host
+	Port       int
+}
+
+// Start launches sshd for the duration of the test. It skips the test if
+// sshd or ssh-keygen is not installed.
+func Start(t testing.TB) *Server {
+	t.Helper()
+
+	sshd := findSSHD()
+	if sshd == "" {
+		t.Skip("sshd not found (set SSHD_PATH)")
+	}
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not found")
+	}
+
+	dir := t.TempDir()
+	hostKey := filepath.Join(dir, "host_ed25519")
+	clientKey := filepath.Join(dir, "id_ed25519")
+	for _, key := range []string{hostKey, clientKey} {
+		if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+			t.Fatalf("ssh-keygen %s: %v\n%s", key, err, out)
+		}
+	}
+	pub, err := os.ReadFile(clientKey + ".pub")
+	if err != nil {
+		t.Fatalf("reading client key: %v", err)
+	}
+	authorizedKeys := filepath.Join(dir, "authorized_keys")
+	if err := os.WriteFile(authorizedKeys, pub, 0o600); err != nil {
+		t.Fatalf("writing authorized_keys: %v", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("allocating port: %v", err)
+	}
+
+	sshdConfig := filepath.Join(dir, "sshd_config")
+	if err := os.WriteFile(sshdConfig, []byte(fmt.Sprintf(`Port %d
+ListenAddress 127.0.0.1
+HostKey %s
+PidFile %s
+AuthorizedKeysFile %s
+PubkeyAuthentication yes
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+PermitRootLogin prohibit-password
+StrictModes no
+`, port, hostKey, filepath.Join(dir, "sshd.pid"), authorizedKeys)), 0o600); err != nil {
+		t.Fatalf("writing sshd_config: %v", err)
+	}
+
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	clientConfig := filepath.Join(dir, "ssh_config")
+	if err := os.WriteFile(clientConfig, []byte(fmt.Sprintf(`Host %s
+	HostName 127.0.0.1
+	Port %d
+	User %s
+	IdentityFile %s
+	IdentitiesOnly yes
+	StrictHostKeyChecking no
+	UserKnownHostsFile /dev/null
+	LogLevel ERROR
+`, Host, port, username, clientKey)), 0o600); err != nil {
+		t.Fatalf("writing ssh_config: %v", err)
+	}
+
+	var logBuf strings.Builder
+	cmd := exec.Command(sshd, "-D", "-e", "-f", sshdConfig)
+	cmd.Stdout = &logBuf
+	cmd.Stderr = &logBuf
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting sshd: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if t.Failed() {
+			t.Logf("sshd log:\n%s", logBuf.String())
+		}
+	})
+
+	srv := &Server{Dir: dir, ConfigPath: clientConfig, Port: port}
+	if err := srv.waitReady(10 * time.Second); err != nil {
+		t.Fatalf("sshd did not become ready: %v\nsshd log:\n%s", err, logBuf.String())
+	}
+	return srv
+}
+
+// Client returns an ssh.Client that talks to this server.
+func (s *Server) Client(name string) *ssh.Client {
+	return ssh.NewClientWithConfig(name, s.ConfigPath, Host)
+}
+
+func (s *Server) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		out, err := exec.CommandContext(ctx, "ssh", "-F", s.ConfigPath, Host, "echo ok").CombinedOutput()
+		cancel()
+		if err == nil && strings.TrimSpace(string(out)) == "ok" {
+			return nil
+		}
+		lastErr = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		time.Sleep(200 * time.Millisecond)
+	}
+	return lastErr
+}
+
+func findSSHD() string {
+	if p := os.Getenv("SSHD_PATH"); p != "" {
+		return p
+	}
+	if p, err := exec.LookPath("sshd"); err == nil {
+		if abs, err := filepath.Abs(p); err == nil {
+			return abs
+		}
+	}
+	for _, p := range []string{"/usr/sbin/sshd", "/usr/local/sbin/sshd"} {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -34,11 +34,12 @@ type Manifest struct {
 // survive resume.
 type SessionSettings struct {
 	LocalTools bool `json:"localTools,omitempty"`
+	ReadOnly   bool `json:"readOnly,omitempty"`
 }
 
 // IsZero allows json omitempty to drop empty settings blocks.
 func (s SessionSettings) IsZero() bool {
-	return !s.LocalTools
+	return !s.LocalTools && !s.ReadOnly
 }
 
 // SetAccessPolicy updates the persisted access policy fields.
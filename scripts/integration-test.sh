@@ -35,6 +35,19 @@ else
   fail "MCP server did not respond correctly"
 fi
 
+# 3. Local sshd harness (no codespace needed)
+echo ""
+echo "Test: SSH client and fetch pipeline against local sshd..."
+if command -v sshd > /dev/null 2>&1 || [[ -x /usr/sbin/sshd ]] || [[ -n "${SSHD_PATH:-}" ]]; then
+  if go test -tags integration -run LocalSSHD ./internal/ssh/ ./cmd/gh-copilot-codespace/ > /dev/null 2>&1; then
+    pass "Local sshd integration tests"
+  else
+    fail "Local sshd integration tests (re-run: go test -tags integration -run LocalSSHD -v ./...)"
+  fi
+else
+  skip "Local sshd integration tests (sshd not installed)"
+fi
+
 # 4. gh codespace list works (requires auth)
 echo ""
 echo "Test: gh CLI codespace access..."